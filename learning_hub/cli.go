@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"learning_hub/handlers"
+	"learning_hub/pkg/config"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/tracing"
+	"learning_hub/seed"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+// newRootCommand returns the learning_hub command: it serves the API when run without a subcommand,
+// and its subcommands are the routine tasks of operators, so they don't have to write SQL by hand
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "learning_hub",
+		Short:        "LearnHub API server and operator tools",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			serve(setup())
+		},
+	}
+	root.AddCommand(
+		newServeCommand(),
+		newMigrateCommand(),
+		newSeedCommand(),
+		newCreateAdminCommand(),
+		newResetPasswordCommand(),
+		newReissueCertificateCommand(),
+		newReconcilePaymentsCommand(),
+		newResendEmailCommand(),
+	)
+	return root
+}
+
+// operatorTask runs a command against the configured database, until done or interrupted
+func operatorTask(run func(ctx context.Context, cmd *cobra.Command, args []string, cfg *config.Config, db *gorm.DB) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		cfg, db := setup()
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			tracing.Shutdown(ctx)
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		}()
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return run(ctx, cmd, args, cfg, db)
+	}
+}
+
+// printf writes the result of a command to its standard output, apart from the logs
+func printf(cmd *cobra.Command, format string, args ...interface{}) {
+	fmt.Fprintf(cmd.OutOrStdout(), format+"\n", args...)
+}
+
+func newServeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Migrate the database and serve the API (the default)",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			serve(setup())
+		},
+	}
+}
+
+func newMigrateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Create and update the database tables, then exit",
+		Args:  cobra.NoArgs,
+		RunE: operatorTask(func(ctx context.Context, cmd *cobra.Command, args []string, cfg *config.Config, db *gorm.DB) error {
+			if err := migrate(db.WithContext(ctx)); err != nil {
+				return fmt.Errorf("migration failed: %w", err)
+			}
+			printf(cmd, "Database migrated")
+			return nil
+		}),
+	}
+}
+
+func newSeedCommand() *cobra.Command {
+	var adminOnly bool
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Migrate the database and seed the admin account and demo data",
+		Long: "Migrate the database and seed the admin account and, outside production, demo courses, users and " +
+			"payments. Records that already exist are kept, so seeding again is harmless.",
+		Args: cobra.NoArgs,
+		RunE: operatorTask(func(ctx context.Context, cmd *cobra.Command, args []string, cfg *config.Config, db *gorm.DB) error {
+			if err := migrate(db.WithContext(ctx)); err != nil {
+				return fmt.Errorf("migration failed: %w", err)
+			}
+			report, err := seed.Run(db.WithContext(ctx), cfg, seed.Options{AdminOnly: adminOnly})
+			if err != nil {
+				return err
+			}
+			printf(cmd, "Seeded %d users, %d courses, %d enrollments and %d payments",
+				report.Users, report.Courses, report.Enrollments, report.Payments)
+			if !adminOnly {
+				printf(cmd, "Demo users log in with the password %s", seed.DemoPassword)
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().BoolVar(&adminOnly, "admin-only", false, "only seed the admin account, e.g. to bootstrap a production database")
+	return cmd
+}
+
+func newCreateAdminCommand() *cobra.Command {
+	var email, firstName, lastName, password string
+	var promote bool
+	cmd := &cobra.Command{
+		Use:   "create-admin",
+		Short: "Create an admin account, or make an existing user an admin with --promote",
+		Args:  cobra.NoArgs,
+		RunE: operatorTask(func(ctx context.Context, cmd *cobra.Command, args []string, cfg *config.Config, db *gorm.DB) error {
+			generated := password == ""
+			if generated {
+				password = idgen.String(16)
+			}
+			user, created, err := handlers.NewAdminHandler(db).CreateAdmin(ctx, email, firstName, lastName, password, promote)
+			if errors.Is(err, handlers.ErrUserExists) {
+				return fmt.Errorf("%w, pass --promote to make them an admin", err)
+			} else if err != nil {
+				return err
+			}
+			if !created {
+				printf(cmd, "%s (user %d) is an admin", user.Email, user.ID)
+				return nil
+			}
+			printf(cmd, "Created the admin %s (user %d)", user.Email, user.ID)
+			if generated {
+				printf(cmd, "Password: %s", password)
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&email, "email", "", "email address of the admin (required)")
+	cmd.Flags().StringVar(&firstName, "first-name", "Admin", "first name of a new admin")
+	cmd.Flags().StringVar(&lastName, "last-name", "User", "last name of a new admin")
+	cmd.Flags().StringVar(&password, "password", "", "password of a new admin; one is generated and printed when empty")
+	cmd.Flags().BoolVar(&promote, "promote", false, "make the existing user with the email an admin")
+	cmd.MarkFlagRequired("email")
+	return cmd
+}
+
+func newResetPasswordCommand() *cobra.Command {
+	var email, password string
+	cmd := &cobra.Command{
+		Use:   "reset-password",
+		Short: "Set a user's password, unlock their account and log them out everywhere",
+		Args:  cobra.NoArgs,
+		RunE: operatorTask(func(ctx context.Context, cmd *cobra.Command, args []string, cfg *config.Config, db *gorm.DB) error {
+			generated := password == ""
+			if generated {
+				password = idgen.String(16)
+			}
+			user, err := handlers.NewAdminHandler(db).SetUserPassword(ctx, email, password)
+			if user == nil {
+				return err
+			}
+			printf(cmd, "Reset the password of %s (user %d)", user.Email, user.ID)
+			if generated {
+				printf(cmd, "Password: %s", password)
+			}
+			return err
+		}),
+	}
+	cmd.Flags().StringVar(&email, "email", "", "email address of the user (required)")
+	cmd.Flags().StringVar(&password, "password", "", "the new password; one is generated and printed when empty")
+	cmd.MarkFlagRequired("email")
+	return cmd
+}
+
+func newReissueCertificateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reissue-certificate <certificate ID or verification code>",
+		Short: "Issue a certificate again with a fresh grade, verification code and validity, and email it",
+		Args:  cobra.ExactArgs(1),
+		RunE: operatorTask(func(ctx context.Context, cmd *cobra.Command, args []string, cfg *config.Config, db *gorm.DB) error {
+			certificate, err := handlers.NewCertificateHandler(db).ReissueCertificate(ctx, args[0])
+			if certificate == nil {
+				return err
+			}
+			printf(cmd, "Reissued %s with the verification code %s, valid until %s",
+				certificate.ID, certificate.VerificationCode, certificate.ExpiryDate.Format("2006-01-02"))
+			return err
+		}),
+	}
+}
+
+func newReconcilePaymentsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reconcile-payments",
+		Short: "Verify stale pending payments with their provider and enroll unenrolled buyers now, as the reconciliation job does",
+		Args:  cobra.NoArgs,
+		RunE: operatorTask(func(ctx context.Context, cmd *cobra.Command, args []string, cfg *config.Config, db *gorm.DB) error {
+			report, err := handlers.NewReconciliationHandler(db, cfg).Reconcile(ctx)
+			if err != nil {
+				return err
+			}
+			printf(cmd, "Reconciliation %d: %d checked, %d settled, %d failed, %d mismatched amounts, %d enrolled, %d errors",
+				report.ID, report.Checked, report.Settled, report.Failed, report.Mismatches, report.Enrolled, report.Errors)
+			return nil
+		}),
+	}
+}
+
+func newResendEmailCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resend-email",
+		Short: "Send an email again: a verification link, a payment receipt or a certificate",
+	}
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "verification <email>",
+			Short: "Send an unverified user a new verification link",
+			Args:  cobra.ExactArgs(1),
+			RunE: operatorTask(func(ctx context.Context, cmd *cobra.Command, args []string, cfg *config.Config, db *gorm.DB) error {
+				if err := handlers.NewUserHandler(db, cfg).SendNewVerificationEmail(ctx, args[0]); err != nil {
+					return err
+				}
+				printf(cmd, "Sent a new verification link to %s", args[0])
+				return nil
+			}),
+		},
+		&cobra.Command{
+			Use:   "payment-receipt <payment ID or transaction reference>",
+			Short: "Send the receipt of a successful payment to its payer",
+			Args:  cobra.ExactArgs(1),
+			RunE: operatorTask(func(ctx context.Context, cmd *cobra.Command, args []string, cfg *config.Config, db *gorm.DB) error {
+				if err := handlers.NewPaymentHandler(db).ResendPaymentReceipt(ctx, args[0]); err != nil {
+					return err
+				}
+				printf(cmd, "Sent the receipt of payment %s", args[0])
+				return nil
+			}),
+		},
+		&cobra.Command{
+			Use:   "certificate <certificate ID or verification code>",
+			Short: "Send a certificate to its holder",
+			Args:  cobra.ExactArgs(1),
+			RunE: operatorTask(func(ctx context.Context, cmd *cobra.Command, args []string, cfg *config.Config, db *gorm.DB) error {
+				if err := handlers.NewCertificateHandler(db).ResendCertificateEmail(ctx, args[0]); err != nil {
+					return err
+				}
+				printf(cmd, "Sent certificate %s", args[0])
+				return nil
+			}),
+		},
+	)
+	return cmd
+}
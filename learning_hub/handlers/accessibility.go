@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	htmlImagePattern     = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+	htmlAltPattern       = regexp.MustCompile(`(?i)\salt\s*=`)
+	markdownImagePattern = regexp.MustCompile(`!\[([^\]]*)\]\(`)
+)
+
+// contentImages counts the images in lesson content (HTML or Markdown) and how many lack alt text.
+// An HTML image with an empty alt attribute is decorative and counts as described.
+func contentImages(content string) (images, missingAlt int) {
+	for _, tag := range htmlImagePattern.FindAllString(content, -1) {
+		images++
+		if !htmlAltPattern.MatchString(tag) {
+			missingAlt++
+		}
+	}
+	for _, match := range markdownImagePattern.FindAllStringSubmatch(content, -1) {
+		images++
+		if strings.TrimSpace(match[1]) == "" {
+			missingAlt++
+		}
+	}
+	return images, missingAlt
+}
+
+// lessonAccessibility is what a lesson provides and lacks for learners using assistive technology
+type lessonAccessibility struct {
+	LessonID         uint     `json:"lesson_id"`
+	Title            string   `json:"title"`
+	HasVideo         bool     `json:"has_video"`
+	Captions         bool     `json:"captions"`
+	Transcript       bool     `json:"transcript"`
+	Images           int      `json:"images"`
+	ImagesMissingAlt int      `json:"images_missing_alt"`
+	Missing          []string `json:"missing"`
+}
+
+// accessibilityReport summarizes the accessibility items of a course. Percentages are 100 when
+// nothing in the course needs the item.
+type accessibilityReport struct {
+	Score                   int                   `json:"score"`
+	VideoLessons            int                   `json:"video_lessons"`
+	CaptionedPercent        float64               `json:"captioned_percent"`
+	TranscribedPercent      float64               `json:"transcribed_percent"`
+	Images                  int                   `json:"images"` // including the course image
+	AltTextPercent          float64               `json:"alt_text_percent"`
+	CourseImageAltProvided  bool                  `json:"course_image_alt_provided"`
+	LessonsMissingSomething int                   `json:"lessons_missing_something"`
+	Lessons                 []lessonAccessibility `json:"lessons"`
+}
+
+func percentOf(part, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(part)*1000/float64(total)) / 10
+}
+
+// courseAccessibility checks every lesson of a course for captions, transcripts and alt text
+func courseAccessibility(db *gorm.DB, course models.Course) (accessibilityReport, error) {
+	var lessons []models.Lesson
+	if course.ID != 0 {
+		if err := db.Select("lessons.id, lessons.title, lessons.content, lessons.video_url, lessons.captions_url, lessons.transcript").
+			Joins("JOIN modules ON modules.id = lessons.module_id AND modules.deleted_at IS NULL").
+			Where("modules.course_id = ?", course.ID).
+			Order("modules.order_index, lessons.order_index, lessons.id").
+			Find(&lessons).Error; err != nil {
+			return accessibilityReport{}, err
+		}
+	}
+
+	report := accessibilityReport{Lessons: make([]lessonAccessibility, 0, len(lessons))}
+	captioned, transcribed, imagesWithAlt := 0, 0, 0
+	for _, lesson := range lessons {
+		item := lessonAccessibility{
+			LessonID:   lesson.ID,
+			Title:      lesson.Title,
+			HasVideo:   lesson.VideoURL != "",
+			Captions:   lesson.CaptionsURL != "",
+			Transcript: strings.TrimSpace(lesson.Transcript) != "",
+			Missing:    []string{},
+		}
+		item.Images, item.ImagesMissingAlt = contentImages(lesson.Content)
+
+		if item.HasVideo {
+			report.VideoLessons++
+			if item.Captions {
+				captioned++
+			} else {
+				item.Missing = append(item.Missing, "captions")
+			}
+			if item.Transcript {
+				transcribed++
+			} else {
+				item.Missing = append(item.Missing, "transcript")
+			}
+		}
+		report.Images += item.Images
+		imagesWithAlt += item.Images - item.ImagesMissingAlt
+		if item.ImagesMissingAlt > 0 {
+			item.Missing = append(item.Missing, "alt_text")
+		}
+		if len(item.Missing) > 0 {
+			report.LessonsMissingSomething++
+		}
+		report.Lessons = append(report.Lessons, item)
+	}
+
+	// The course image needs alt text like the images in lessons
+	report.CourseImageAltProvided = course.ImageURL == "" || strings.TrimSpace(course.ImageAlt) != ""
+	if course.ImageURL != "" {
+		report.Images++
+		if report.CourseImageAltProvided {
+			imagesWithAlt++
+		}
+	}
+
+	report.CaptionedPercent = percentOf(captioned, report.VideoLessons)
+	report.TranscribedPercent = percentOf(transcribed, report.VideoLessons)
+	report.AltTextPercent = percentOf(imagesWithAlt, report.Images)
+
+	// Every caption, transcript and image description counts the same
+	provided := captioned + transcribed + imagesWithAlt
+	needed := 2*report.VideoLessons + report.Images
+	report.Score = int(math.Round(percentOf(provided, needed)))
+	return report, nil
+}
+
+// refreshAccessibilityScore recomputes the stored accessibility score of a course after its content changed
+func refreshAccessibilityScore(db *gorm.DB, courseID uint) {
+	if err := updateAccessibilityScore(db, courseID); err != nil {
+		slog.Error("Failed to update accessibility score", "course_id", courseID, "error", err)
+	}
+}
+
+func updateAccessibilityScore(db *gorm.DB, courseID uint) error {
+	var course models.Course
+	if err := db.Select("id, image_url, image_alt").First(&course, courseID).Error; err != nil {
+		return err
+	}
+	report, err := courseAccessibility(db, course)
+	if err != nil {
+		return err
+	}
+	return db.Model(&course).UpdateColumn("accessibility_score", report.Score).Error
+}
+
+type AccessibilityHandler struct {
+	DB *gorm.DB
+}
+
+func NewAccessibilityHandler(db *gorm.DB) *AccessibilityHandler {
+	return &AccessibilityHandler{DB: db}
+}
+
+// GetCourseAccessibility lists what each lesson of a course is missing for accessibility
+func (h *AccessibilityHandler) GetCourseAccessibility(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	report, err := courseAccessibility(db, course)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check course accessibility"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"course_id":     course.ID,
+		"accessibility": report,
+	})
+}
+
+// RefreshAccessibilityScores recomputes the score of every course, so courses created before scores
+// were tracked get one. Runs as a scheduled job.
+func (h *AccessibilityHandler) RefreshAccessibilityScores(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	var ids []uint
+	if err := db.Model(&models.Course{}).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := updateAccessibilityScore(db, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
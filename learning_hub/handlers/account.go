@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// How long a user has to change their mind, by logging in, before their account is anonymized
+const accountDeletionGrace = 14 * 24 * time.Hour
+
+// accountDataSection is a kind of record included in a user's data export
+type accountDataSection struct {
+	Name  string
+	Model interface{}
+	Where string // selects the user's records, with the user ID as its only argument
+}
+
+var accountDataSections = []accountDataSection{
+	{"enrollments", &models.Enrollment{}, "user_id = ?"},
+	{"track_enrollments", &models.TrackEnrollment{}, "user_id = ?"},
+	{"payments", &models.Payment{}, "user_id = ?"},
+	{"lesson_progress", &models.LessonProgress{}, "user_id = ?"},
+	{"quiz_attempts", &models.QuizAttempt{}, "user_id = ?"},
+	{"scorm_attempts", &models.ScormAttempt{}, "user_id = ?"},
+	{"xapi_statements", &models.XAPIStatement{}, "user_id = ?"},
+	{"quiz_answers", &models.QuizAnswer{}, "attempt_id IN (SELECT id FROM quiz_attempts WHERE user_id = ?)"},
+	{"submissions", &models.AssignmentSubmission{}, "user_id = ?"},
+	{"certificates", &models.Certificate{}, "user_id = ?"},
+	{"reviews", &models.Review{}, "user_id = ?"},
+	{"forum_threads", &models.ForumThread{}, "user_id = ?"},
+	{"forum_posts", &models.ForumPost{}, "user_id = ?"},
+	{"messages", &models.Message{}, "sender_id = ?"},
+	{"live_attendance", &models.LiveAttendance{}, "user_id = ?"},
+	{"wishlist", &models.Wishlist{}, "user_id = ?"},
+	{"points", &models.PointEntry{}, "user_id = ?"},
+	{"badges", &models.UserBadge{}, "user_id = ?"},
+	{"notification_preferences", &models.NotificationPreference{}, "user_id = ?"},
+	{"linked_logins", &models.UserIdentity{}, "user_id = ?"},
+	{"organizations", &models.OrganizationMember{}, "user_id = ?"},
+	{"sessions", &models.Session{}, "user_id = ?"},
+	{"login_attempts", &models.LoginAttempt{}, "user_id = ?"},
+}
+
+// accountDataRows reads the user's records of a section as stored, column by column
+func accountDataRows(db *gorm.DB, section accountDataSection, userID uint) ([]map[string]interface{}, error) {
+	rows := []map[string]interface{}{}
+	if err := db.Model(section.Model).Where(section.Where, userID).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		for column, value := range row {
+			// JSON and text columns may come back as bytes, which would be encoded as base64
+			if raw, ok := value.([]byte); ok {
+				if json.Valid(raw) {
+					row[column] = json.RawMessage(raw)
+				} else {
+					row[column] = string(raw)
+				}
+			}
+		}
+	}
+	return rows, nil
+}
+
+// ExportAccountData lets the caller download the personal data kept about them: their profile and every
+// record of theirs, as a ZIP of one JSON file per kind of record, or a single JSON document with ?format=json
+func (h *UserHandler) ExportAccountData(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.First(&user, c.MustGet("userID")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+
+	type dataFile struct {
+		name string
+		data interface{}
+	}
+	files := []dataFile{{"profile", gin.H{
+		"id":                 user.ID,
+		"first_name":         user.FirstName,
+		"last_name":          user.LastName,
+		"email":              user.Email,
+		"pending_email":      user.PendingEmail,
+		"phone":              user.Phone,
+		"country":            user.Country,
+		"role":               user.Role,
+		"email_verified":     user.EmailVerified,
+		"two_factor_enabled": user.TwoFactorEnabled,
+		"created_at":         user.CreatedAt,
+		"updated_at":         user.UpdatedAt,
+	}}}
+	for _, section := range accountDataSections {
+		rows, err := accountDataRows(db, section, user.ID)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to export account data", "section", section.Name, "user_id", user.ID, "error", err)
+			apierror.Abort(c, apierror.Internal("Failed to export your data"))
+			return
+		}
+		files = append(files, dataFile{section.Name, rows})
+	}
+
+	filename := fmt.Sprintf("learnhub-data-%d-%s", user.ID, clock.Now().Format("2006-01-02"))
+	if c.Query("format") == "json" {
+		document := gin.H{"exported_at": clock.Now()}
+		for _, file := range files {
+			document[file.name] = file.data
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		c.JSON(http.StatusOK, document)
+		return
+	}
+
+	var out bytes.Buffer
+	archive := zip.NewWriter(&out)
+	for _, file := range files {
+		w, err := archive.Create(filename + "/" + file.name + ".json")
+		if err == nil {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(file.data)
+		}
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to export your data"))
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to export your data"))
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	c.Data(http.StatusOK, "application/zip", out.Bytes())
+}
+
+// DeleteAccount schedules the caller's account for deletion ({"password"}) and logs them out everywhere.
+// Logging in again before the grace period ends keeps the account.
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("Your password is required to delete your account"))
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, c.MustGet("userID")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if user.IsTestStudent {
+		apierror.Abort(c, apierror.Forbidden("Test students can't delete their account"))
+		return
+	}
+	if err := user.CheckPassword(input.Password); err != nil {
+		apierror.Abort(c, apierror.Unauthorized("Password is incorrect"))
+		return
+	}
+	var courses int64
+	db.Model(&models.Course{}).Where("instructor_id = ?", user.ID).Count(&courses)
+	if courses > 0 {
+		apierror.Abort(c, apierror.Conflict("You still teach courses: delete them or ask an admin to hand them over first").With("courses", courses))
+		return
+	}
+
+	deletionAt := clock.Now().Add(accountDeletionGrace)
+	if err := db.Model(&user).Update("deletion_scheduled_at", deletionAt).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to schedule the account deletion"))
+		return
+	}
+	if _, err := revokeSessions(db, user.ID, ""); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to revoke sessions", "user_id", user.ID, "error", err)
+	}
+	go func() {
+		if err := email.SendAccountDeletionEmail(user.Email, user.FirstName+" "+user.LastName, deletionAt); err != nil {
+			slog.Error("Failed to send account deletion email", "user_id", user.ID, "error", err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":               i18n.T(i18n.Locale(c), "Your account will be deleted. Log in again before then to keep it."),
+		"deletion_scheduled_at": deletionAt,
+	})
+}
+
+// cancelAccountDeletion keeps the account of a user who logs in during the grace period
+func cancelAccountDeletion(db *gorm.DB, user *models.User) {
+	if user.DeletionScheduledAt == nil {
+		return
+	}
+	if err := db.Model(user).Update("deletion_scheduled_at", nil).Error; err != nil {
+		slog.Error("Failed to cancel account deletion", "user_id", user.ID, "error", err)
+		return
+	}
+	slog.Info("User logged in, their account is no longer deleted", "user_id", user.ID)
+}
+
+// DeleteScheduledAccounts anonymizes the accounts whose grace period is over
+func (h *UserHandler) DeleteScheduledAccounts(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	var users []models.User
+	if err := db.Where("deletion_scheduled_at <= ?", clock.Now()).Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return anonymizeUser(tx, user)
+		}); err != nil {
+			slog.Error("Failed to delete account", "user_id", user.ID, "error", err)
+			continue
+		}
+		slog.Info("Deleted account", "user_id", user.ID)
+	}
+	return nil
+}
+
+// anonymizeUser erases a user's personal data and soft-deletes them. What they did in courses (reviews,
+// attempts, submissions, posts, messages, progress) stays, now by an anonymous user, as do payments for
+// the accounts and device events for fraud checks. Their certificates are revoked: there is no one left
+// to vouch for.
+func anonymizeUser(tx *gorm.DB, user models.User) error {
+	personal := []struct {
+		model interface{}
+		where string
+	}{
+		{&models.Session{}, "user_id = ?"},
+		{&models.UserIdentity{}, "user_id = ?"},
+		{&models.OrganizationMember{}, "user_id = ?"},
+		{&models.CourseStaff{}, "user_id = ?"},
+		{&models.TwoFactorBackupCode{}, "user_id = ?"},
+		{&models.TwoFactorChallenge{}, "user_id = ?"},
+		{&models.Notification{}, "user_id = ?"},
+		{&models.NotificationPreference{}, "user_id = ?"},
+		{&models.Wishlist{}, "user_id = ?"},
+		{&models.LessonCode{}, "user_id = ?"},
+		{&models.XAPIStatement{}, "user_id = ?"}, // statements name their actor
+		{&models.PointEntry{}, "user_id = ?"},
+		{&models.UserBadge{}, "user_id = ?"},
+		{&models.LearningStreak{}, "user_id = ?"},
+		{&models.UploadQuota{}, "user_id = ?"},
+		{&models.LoginAttempt{}, "user_id = ?"},
+		{&models.FileAccessLog{}, "user_id = ?"},
+	}
+	for _, p := range personal {
+		if err := tx.Unscoped().Where(p.where, user.ID).Delete(p.model).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&models.CourseView{}).Where("user_id = ?", user.ID).Update("user_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Certificate{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Updates(map[string]interface{}{
+		"revoked_at":        clock.Now(),
+		"revocation_reason": "The holder deleted their account",
+	}).Error; err != nil {
+		return err
+	}
+
+	// Nobody can log in with what's left
+	password, err := idgen.Token("", 32)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"first_name":            "Deleted",
+		"last_name":             "User",
+		"email":                 fmt.Sprintf("deleted-%d@deleted.invalid", user.ID),
+		"password":              string(hash),
+		"phone":                 "",
+		"country":               "",
+		"email_verified":        false,
+		"verification_token":    nil,
+		"verification_sent_at":  nil,
+		"pending_email":         nil,
+		"email_change_token":    nil,
+		"email_change_sent_at":  nil,
+		"reset_token":           nil,
+		"reset_sent_at":         nil,
+		"reset_expires_at":      nil,
+		"two_factor_enabled":    false,
+		"two_factor_secret":     "",
+		"calendar_token":        nil,
+		"deletion_scheduled_at": nil,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&user).Error
+}
@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Points per achievement
+const (
+	pointsLessonCompleted = 10
+	pointsQuizPassed      = 50
+	pointsStreakDay       = 5 // each day that continues a streak
+)
+
+// defaultBadges are created on startup unless a badge with the code exists (or was deleted)
+var defaultBadges = []models.Badge{
+	{Code: "first-lesson", Name: "First Steps", Description: "Completed your first lesson", Criterion: models.BadgeLessonsCompleted, Threshold: 1},
+	{Code: "lessons-50", Name: "Dedicated Learner", Description: "Completed 50 lessons", Criterion: models.BadgeLessonsCompleted, Threshold: 50},
+	{Code: "first-quiz", Name: "Quiz Taker", Description: "Passed your first quiz", Criterion: models.BadgeQuizzesPassed, Threshold: 1},
+	{Code: "quizzes-10", Name: "Quiz Master", Description: "Passed 10 quizzes", Criterion: models.BadgeQuizzesPassed, Threshold: 10},
+	{Code: "first-course", Name: "Graduate", Description: "Completed a course", Criterion: models.BadgeCoursesCompleted, Threshold: 1},
+	{Code: "streak-7", Name: "On a Roll", Description: "Learned 7 days in a row", Criterion: models.BadgeStreakDays, Threshold: 7},
+	{Code: "streak-30", Name: "Unstoppable", Description: "Learned 30 days in a row", Criterion: models.BadgeStreakDays, Threshold: 30},
+	{Code: "points-1000", Name: "High Achiever", Description: "Earned 1000 points", Criterion: models.BadgePoints, Threshold: 1000},
+}
+
+var badgeCriteria = []string{models.BadgeLessonsCompleted, models.BadgeQuizzesPassed, models.BadgeCoursesCompleted,
+	models.BadgeStreakDays, models.BadgePoints}
+
+// dayNumber is the UTC day of t, counted from 1970-01-01
+func dayNumber(t time.Time) int64 {
+	return t.UTC().Unix() / 86400
+}
+
+// awardLessonPoints rewards the user's completed lessons of the course that were not rewarded yet
+func awardLessonPoints(db *gorm.DB, userID, courseID uint) error {
+	result := db.Exec(`INSERT INTO point_entries (user_id, course_id, reason, source_id, points, created_at)
+		SELECT lesson_progresses.user_id, ?, ?, lesson_progresses.lesson_id, ?, ?
+		FROM lesson_progresses
+		JOIN lessons ON lessons.id = lesson_progresses.lesson_id
+		JOIN modules ON modules.id = lessons.module_id
+		WHERE lesson_progresses.user_id = ? AND lesson_progresses.completed = ? AND modules.course_id = ?
+			AND lesson_progresses.deleted_at IS NULL
+		ON CONFLICT DO NOTHING`,
+		courseID, models.PointsLessonCompleted, pointsLessonCompleted, clock.Now(), userID, true, courseID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return recordLearningDay(db, userID)
+	}
+	return nil
+}
+
+// awardQuizPoints rewards passing a quiz, once per quiz
+func awardQuizPoints(db *gorm.DB, userID uint, quiz models.Quiz) error {
+	courseID := quiz.CourseID
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PointEntry{
+		UserID:    userID,
+		CourseID:  &courseID,
+		Reason:    models.PointsQuizPassed,
+		SourceID:  quiz.ID,
+		Points:    pointsQuizPassed,
+		CreatedAt: clock.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return recordLearningDay(db, userID)
+	}
+	return nil
+}
+
+// recordLearningDay extends the user's streak with today, rewards continuing it and checks for new badges
+func recordLearningDay(db *gorm.DB, userID uint) error {
+	now := clock.Now()
+	today := dayNumber(now)
+
+	var streak models.LearningStreak
+	if err := db.Where(models.LearningStreak{UserID: userID}).FirstOrInit(&streak).Error; err != nil {
+		return err
+	}
+	if streak.Current == 0 || dayNumber(streak.LastActiveOn) != today {
+		if streak.Current > 0 && dayNumber(streak.LastActiveOn) == today-1 {
+			streak.Current++
+		} else {
+			streak.Current = 1
+		}
+		if streak.Current > streak.Longest {
+			streak.Longest = streak.Current
+		}
+		streak.LastActiveOn = now.UTC().Truncate(24 * time.Hour)
+		if err := db.Save(&streak).Error; err != nil {
+			return err
+		}
+
+		if streak.Current > 1 {
+			if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PointEntry{
+				UserID:    userID,
+				Reason:    models.PointsStreakDay,
+				SourceID:  uint(today),
+				Points:    pointsStreakDay,
+				CreatedAt: now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return awardBadges(db, userID)
+}
+
+// achievementCounts is what badges are measured against
+func achievementCounts(db *gorm.DB, userID uint) (map[string]int, error) {
+	counts := make(map[string]int, len(badgeCriteria))
+
+	var byReason []struct {
+		Reason  string
+		Entries int
+		Points  int
+	}
+	if err := db.Model(&models.PointEntry{}).Where("user_id = ?", userID).
+		Group("reason").Select("reason, COUNT(*) AS entries, SUM(points) AS points").
+		Scan(&byReason).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range byReason {
+		counts[models.BadgePoints] += row.Points
+		switch row.Reason {
+		case models.PointsLessonCompleted:
+			counts[models.BadgeLessonsCompleted] = row.Entries
+		case models.PointsQuizPassed:
+			counts[models.BadgeQuizzesPassed] = row.Entries
+		}
+	}
+
+	var completed int64
+	if err := db.Model(&models.Enrollment{}).Where("user_id = ? AND completed_at IS NOT NULL", userID).
+		Count(&completed).Error; err != nil {
+		return nil, err
+	}
+	counts[models.BadgeCoursesCompleted] = int(completed)
+
+	var streak models.LearningStreak
+	db.Where("user_id = ?", userID).Limit(1).Find(&streak)
+	counts[models.BadgeStreakDays] = streak.Longest
+
+	return counts, nil
+}
+
+// awardBadges gives the user every badge they now qualify for and tells them about it
+func awardBadges(db *gorm.DB, userID uint) error {
+	var badges []models.Badge
+	if err := db.Where("id NOT IN (?)", db.Model(&models.UserBadge{}).Select("badge_id").Where("user_id = ?", userID)).
+		Find(&badges).Error; err != nil {
+		return err
+	}
+	if len(badges) == 0 {
+		return nil
+	}
+	counts, err := achievementCounts(db, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, badge := range badges {
+		if counts[badge.Criterion] < badge.Threshold {
+			continue
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UserBadge{
+			UserID:   userID,
+			BadgeID:  badge.ID,
+			EarnedAt: clock.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			notifyUser(db, userID, models.NotificationBadgeEarned, "You earned the "+badge.Name+" badge", gin.H{
+				"badge_id":   badge.ID,
+				"badge_code": badge.Code,
+			})
+		}
+	}
+	return nil
+}
+
+type AchievementHandler struct {
+	DB *gorm.DB
+}
+
+func NewAchievementHandler(db *gorm.DB) *AchievementHandler {
+	return &AchievementHandler{DB: db}
+}
+
+// SeedBadges creates the default badges that don't exist yet; deleted ones stay deleted
+func (h *AchievementHandler) SeedBadges() {
+	for _, badge := range defaultBadges {
+		badge := badge
+		if err := h.DB.Unscoped().Where(models.Badge{Code: badge.Code}).FirstOrCreate(&badge).Error; err != nil {
+			slog.Error("Failed to create badge", "badge", badge.Code, "error", err)
+		}
+	}
+}
+
+// GetBadges lists the badges that can be earned
+func (h *AchievementHandler) GetBadges(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var badges []models.Badge
+	if err := db.Order("criterion, threshold").Find(&badges).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch badges"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"badges": badges})
+}
+
+// GetMyAchievements shows the caller's points, streak, earned badges and progress towards the others
+func (h *AchievementHandler) GetMyAchievements(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
+	counts, err := achievementCounts(db, userID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch achievements"))
+		return
+	}
+	var pointsByReason []struct {
+		Reason string `json:"reason"`
+		Points int    `json:"points"`
+	}
+	db.Model(&models.PointEntry{}).Where("user_id = ?", userID).
+		Group("reason").Select("reason, SUM(points) AS points").Scan(&pointsByReason)
+
+	var streak models.LearningStreak
+	db.Where("user_id = ?", userID).Limit(1).Find(&streak)
+	current := streak.Current
+	if current > 0 && dayNumber(streak.LastActiveOn) < dayNumber(clock.Now())-1 {
+		current = 0 // broken: no activity yesterday or today
+	}
+
+	var earned []models.UserBadge
+	if err := db.Preload("Badge").Where("user_id = ? AND badge_id IN (?)", userID, db.Model(&models.Badge{}).Select("id")).
+		Order("earned_at DESC").Find(&earned).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch badges"))
+		return
+	}
+	have := make(map[uint]bool, len(earned))
+	for _, badge := range earned {
+		have[badge.BadgeID] = true
+	}
+	var badges []models.Badge
+	db.Order("criterion, threshold").Find(&badges)
+	next := make([]gin.H, 0, len(badges))
+	for _, badge := range badges {
+		if have[badge.ID] {
+			continue
+		}
+		next = append(next, gin.H{
+			"badge":    badge,
+			"progress": counts[badge.Criterion],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"points":           counts[models.BadgePoints],
+		"points_by_reason": pointsByReason,
+		"counts":           counts,
+		"streak": gin.H{
+			"current":        current,
+			"longest":        streak.Longest,
+			"last_active_on": streak.LastActiveOn,
+		},
+		"badges":           earned,
+		"badges_available": next,
+	})
+}
+
+// leaderboardEntry is a user's points on a leaderboard; only first names and initials are shown
+type leaderboardEntry struct {
+	Rank   int    `json:"rank"`
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name"`
+	Points int    `json:"points"`
+}
+
+// leaderboard ranks users by points earned since the ?period= (week, month or all) within the scope
+func (h *AchievementHandler) leaderboard(c *gin.Context, scope func(*gorm.DB) *gorm.DB) {
+	db := h.DB.WithContext(c.Request.Context())
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		apierror.Abort(c, apierror.BadRequest("limit must be between 1 and 100"))
+		return
+	}
+	period := c.DefaultQuery("period", "all")
+	query := db.Table("point_entries").Scopes(scope)
+	switch period {
+	case "week":
+		query = query.Where("point_entries.created_at >= ?", clock.Now().AddDate(0, 0, -7))
+	case "month":
+		query = query.Where("point_entries.created_at >= ?", clock.Now().AddDate(0, -1, 0))
+	case "all":
+	default:
+		apierror.Abort(c, apierror.BadRequest("period must be week, month or all"))
+		return
+	}
+
+	var rows []struct {
+		UserID    uint
+		FirstName string
+		LastName  string
+		Points    int
+	}
+	if err := query.Joins("JOIN users ON users.id = point_entries.user_id AND users.deleted_at IS NULL AND NOT users.is_test_student").
+		Group("point_entries.user_id, users.first_name, users.last_name").
+		Select("point_entries.user_id, users.first_name, users.last_name, SUM(point_entries.points) AS points").
+		Order("points DESC, point_entries.user_id").
+		Scan(&rows).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch leaderboard"))
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	entries := make([]leaderboardEntry, 0, limit)
+	var mine *leaderboardEntry
+	rank := 0
+	for i, row := range rows {
+		if i == 0 || row.Points < rows[i-1].Points {
+			rank = i + 1 // ties share a rank
+		}
+		name := row.FirstName
+		if initial := []rune(strings.TrimSpace(row.LastName)); len(initial) > 0 {
+			name += " " + string(initial[0]) + "."
+		}
+		entry := leaderboardEntry{Rank: rank, UserID: row.UserID, Name: name, Points: row.Points}
+		if len(entries) < limit {
+			entries = append(entries, entry)
+		}
+		if row.UserID == userID {
+			mine = &entry
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"period":      period,
+		"leaderboard": entries,
+		"me":          mine,
+		"total_users": len(rows),
+	})
+}
+
+// GetLeaderboard ranks all users by points (?period=week|month|all, ?limit=)
+func (h *AchievementHandler) GetLeaderboard(c *gin.Context) {
+	h.leaderboard(c, func(db *gorm.DB) *gorm.DB { return db })
+}
+
+// GetCourseLeaderboard ranks a course's students by the points earned in it (?period=, ?limit=)
+func (h *AchievementHandler) GetCourseLeaderboard(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.Select("id").First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	h.leaderboard(c, func(db *gorm.DB) *gorm.DB {
+		return db.Where("point_entries.course_id = ?", course.ID)
+	})
+}
+
+type badgeInput struct {
+	Code        string `json:"code" binding:"required,max=50"`
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+	IconURL     string `json:"icon_url"`
+	Criterion   string `json:"criterion" binding:"required"`
+	Threshold   int    `json:"threshold" binding:"required,min=1"`
+}
+
+func (h *AchievementHandler) bindBadge(c *gin.Context) (badgeInput, bool) {
+	var input badgeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return input, false
+	}
+	for _, criterion := range badgeCriteria {
+		if input.Criterion == criterion {
+			return input, true
+		}
+	}
+	apierror.Abort(c, apierror.BadRequest(fmt.Sprintf("criterion must be one of %s", strings.Join(badgeCriteria, ", "))))
+	return input, false
+}
+
+// CreateBadge adds a badge. Users already qualifying get it with their next achievement.
+func (h *AchievementHandler) CreateBadge(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	input, ok := h.bindBadge(c)
+	if !ok {
+		return
+	}
+	var existing int64
+	db.Unscoped().Model(&models.Badge{}).Where("code = ?", input.Code).Count(&existing)
+	if existing > 0 {
+		apierror.Abort(c, apierror.Conflict("A badge with this code already exists"))
+		return
+	}
+	badge := models.Badge{
+		Code:        input.Code,
+		Name:        input.Name,
+		Description: input.Description,
+		IconURL:     input.IconURL,
+		Criterion:   input.Criterion,
+		Threshold:   input.Threshold,
+	}
+	if err := db.Create(&badge).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create badge"))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"badge": badge})
+}
+
+// UpdateBadge changes a badge; badges already earned are kept
+func (h *AchievementHandler) UpdateBadge(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var badge models.Badge
+	if err := db.First(&badge, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Badge not found"))
+		return
+	}
+	input, ok := h.bindBadge(c)
+	if !ok {
+		return
+	}
+	if input.Code != badge.Code {
+		var existing int64
+		db.Unscoped().Model(&models.Badge{}).Where("code = ?", input.Code).Count(&existing)
+		if existing > 0 {
+			apierror.Abort(c, apierror.Conflict("A badge with this code already exists"))
+			return
+		}
+	}
+	if err := db.Model(&badge).Updates(map[string]interface{}{
+		"code":        input.Code,
+		"name":        input.Name,
+		"description": input.Description,
+		"icon_url":    input.IconURL,
+		"criterion":   input.Criterion,
+		"threshold":   input.Threshold,
+	}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update badge"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"badge": badge})
+}
+
+// DeleteBadge retires a badge: it can no longer be earned and disappears from profiles
+func (h *AchievementHandler) DeleteBadge(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	result := db.Delete(&models.Badge{}, c.Param("id"))
+	if result.Error != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete badge"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Abort(c, apierror.NotFound("Badge not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Badge deleted")})
+}
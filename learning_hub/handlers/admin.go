@@ -2,9 +2,14 @@ package handlers
 
 import (
 	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/chapa"
 	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
 	"learning_hub/pkg/validation"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,6 +27,7 @@ func NewAdminHandler(db *gorm.DB) *AdminHandler {
 
 // AdminStats returns overall platform statistics
 func (h *AdminHandler) AdminStats(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	var stats struct {
 		TotalUsers        int64   `json:"total_users"`
 		TotalCourses      int64   `json:"total_courses"`
@@ -33,22 +39,22 @@ func (h *AdminHandler) AdminStats(c *gin.Context) {
 	}
 
 	// Get total counts
-	h.DB.Model(&models.User{}).Count(&stats.TotalUsers)
-	h.DB.Model(&models.Course{}).Count(&stats.TotalCourses)
-	h.DB.Model(&models.Enrollment{}).Count(&stats.TotalEnrollments)
-	h.DB.Model(&models.Payment{}).Count(&stats.TotalPayments)
+	db.Model(&models.User{}).Where("is_test_student = ?", false).Count(&stats.TotalUsers)
+	db.Model(&models.Course{}).Count(&stats.TotalCourses)
+	db.Model(&models.Enrollment{}).Scopes(withoutTestStudents).Count(&stats.TotalEnrollments)
+	db.Model(&models.Payment{}).Count(&stats.TotalPayments)
 
 	// Calculate total revenue from successful payments
-	h.DB.Model(&models.Payment{}).Where("status = ?", models.PaymentStatusSuccess).
+	db.Model(&models.Payment{}).Where("status = ?", models.PaymentStatusSuccess).
 		Select("COALESCE(SUM(amount), 0)").Scan(&stats.TotalRevenue)
 
 	// Count active students (users with enrollments)
-	h.DB.Model(&models.User{}).Where("role = ?", "student").
+	db.Model(&models.User{}).Where("role = ? AND is_test_student = ?", "student", false).
 		Joins("JOIN enrollments ON enrollments.user_id = users.id").
 		Distinct("users.id").Count(&stats.ActiveStudents)
 
 	// Count active instructors (users with courses)
-	h.DB.Model(&models.User{}).Where("role = ?", "instructor").
+	db.Model(&models.User{}).Where("role = ?", "instructor").
 		Joins("JOIN courses ON courses.instructor_id = users.id").
 		Distinct("users.id").Count(&stats.ActiveInstructors)
 
@@ -58,56 +64,105 @@ func (h *AdminHandler) AdminStats(c *gin.Context) {
 }
 
 func (h *AdminHandler) DeleteUser(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.DB.Delete(&models.User{}, id).Error; err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete user"})
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if err := db.Delete(&user).Error; err != nil {
+		apierror.Abort(c, apierror.New(500, "Failed to delete user"))
 		return
 	}
-	c.JSON(200, gin.H{"message": "User deleted successfully"})
+	if _, err := revokeSessions(db, user.ID, ""); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to revoke sessions", "user_id", user.ID, "error", err)
+	}
+	recordAudit(db, c, models.AuditUserDelete, "user", user.ID, gin.H{
+		"email":      user.Email,
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"role":       user.Role,
+	}, nil)
+	c.JSON(200, gin.H{"message": i18n.T(i18n.Locale(c), "User deleted successfully")})
 }
 
 // GetRecentPayments returns recent payment transactions
 func (h *AdminHandler) GetRecentPayments(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	var payments []models.Payment
 
-	if err := h.DB.Preload("User", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id, first_name, last_name, email")
-	}).Preload("Course", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id, title")
-	}).Order("created_at DESC").Limit(20).Find(&payments).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+	if err := db.Preload("User", contactUserFields).Preload("Course", courseBriefFields).
+		Order("created_at DESC").Limit(20).Find(&payments).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch payments"))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"payments": payments,
+		"payments": newPaymentViews(payments),
 		"count":    len(payments),
 	})
 }
 
+// GetPaymentMethodReport returns successful payment totals grouped by payment method and currency.
+// Optional ?from= and ?to= (YYYY-MM-DD) limit the reporting period.
+func (h *AdminHandler) GetPaymentMethodReport(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	type methodTotal struct {
+		PaymentMethod string  `json:"payment_method"`
+		Label         string  `json:"label"`
+		Currency      string  `json:"currency"`
+		Transactions  int64   `json:"transactions"`
+		TotalAmount   float64 `json:"total_amount"`
+	}
+
+	from, to, ok := parseReportRange(c, time.Time{})
+	if !ok {
+		return
+	}
+	query := db.Model(&models.Payment{}).
+		Where("status = ? AND created_at >= ? AND created_at < ?", models.PaymentStatusSuccess, from, to)
+
+	var totals []methodTotal
+	methodColumn := "COALESCE(NULLIF(payment_method, ''), '" + chapa.MethodUnknown + "')"
+	if err := query.Select(methodColumn + " AS payment_method, currency, COUNT(*) AS transactions, COALESCE(SUM(amount), 0) AS total_amount").
+		Group(methodColumn + ", currency").
+		Order("total_amount DESC").
+		Scan(&totals).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to build payment method report"))
+		return
+	}
+
+	for i := range totals {
+		totals[i].Label = chapa.PaymentMethodLabel(totals[i].PaymentMethod)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"methods": totals,
+		"count":   len(totals),
+	})
+}
+
 // GetRecentEnrollments returns recent course enrollments
 func (h *AdminHandler) GetRecentEnrollments(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	var enrollments []models.Enrollment
 
-	if err := h.DB.Preload("User", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id, first_name, last_name, email")
-	}).Preload("Course", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id, title, instructor_id")
-	}).Preload("Course.Instructor", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id, first_name, last_name")
-	}).Order("enrolled_at DESC").Limit(20).Find(&enrollments).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollments"})
+	if err := db.Preload("User", contactUserFields).Preload("Course", courseBriefFields).
+		Preload("Course.Instructor", publicUserFields).
+		Scopes(withoutTestStudents).Order("enrolled_at DESC").Limit(20).Find(&enrollments).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch enrollments"))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"enrollments": enrollments,
+		"enrollments": newEnrollmentViews(enrollments),
 		"count":       len(enrollments),
 	})
 }
 
 // GetCourseAnalytics returns analytics for a specific course
 func (h *AdminHandler) GetCourseAnalytics(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	courseID := c.Param("id")
 
 	var analytics struct {
@@ -119,40 +174,155 @@ func (h *AdminHandler) GetCourseAnalytics(c *gin.Context) {
 	}
 
 	// Get enrollment count
-	h.DB.Model(&models.Enrollment{}).Where("course_id = ?", courseID).Count(&analytics.TotalEnrollments)
+	db.Model(&models.Enrollment{}).Scopes(withoutTestStudents).Where("course_id = ?", courseID).Count(&analytics.TotalEnrollments)
 
 	// Get total revenue from this course
-	h.DB.Model(&models.Payment{}).Where("course_id = ? AND status = ?", courseID, models.PaymentStatusSuccess).
+	db.Model(&models.Payment{}).Where("course_id = ? AND status = ?", courseID, models.PaymentStatusSuccess).
 		Select("COALESCE(SUM(amount), 0)").Scan(&analytics.TotalRevenue)
 
 	// Get average rating
-	h.DB.Model(&models.Review{}).Where("course_id = ?", courseID).
+	db.Model(&models.Review{}).Where("course_id = ? AND status = ?", courseID, models.ContentPublished).
 		Select("COALESCE(AVG(rating), 0)").Scan(&analytics.AverageRating)
 
 	// Get total reviews
-	h.DB.Model(&models.Review{}).Where("course_id = ?", courseID).Count(&analytics.TotalReviews)
+	db.Model(&models.Review{}).Where("course_id = ?", courseID).Count(&analytics.TotalReviews)
 
 	// Calculate completion rate (simplified - users with progress > 90%)
 	var completedEnrollments int64
-	h.DB.Model(&models.Enrollment{}).Where("course_id = ? AND progress >= ?", courseID, 90).Count(&completedEnrollments)
+	db.Model(&models.Enrollment{}).Scopes(withoutTestStudents).Where("course_id = ? AND progress >= ?", courseID, 90).
+		Count(&completedEnrollments)
 
 	if analytics.TotalEnrollments > 0 {
 		analytics.CompletionRate = float64(completedEnrollments) / float64(analytics.TotalEnrollments) * 100
 	}
 
+	id, err := strconv.ParseUint(courseID, 10, 64)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid course ID"))
+		return
+	}
+	from, to, ok := parseReportRange(c, time.Time{})
+	if !ok {
+		return
+	}
+	funnel, err := buildCourseFunnel(db, uint(id), from, to)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to load conversion funnel"))
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"course_id": courseID,
 		"analytics": analytics,
+		"funnel":    funnel,
+	})
+}
+
+// GetFileAccessLogs returns recent protected file downloads, optionally filtered by ?user_id= or ?course_id=
+func (h *AdminHandler) GetFileAccessLogs(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	query := db.Preload("User", contactUserFields).Order("created_at DESC").Limit(200)
+
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if c.Query("throttled") == "true" {
+		query = query.Where("throttled = ?", true)
+	}
+
+	var logs []models.FileAccessLog
+	if err := query.Find(&logs).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch file access logs"))
+		return
+	}
+
+	views := make([]fileAccessView, len(logs))
+	for i, entry := range logs {
+		views[i] = fileAccessView{FileAccessLog: entry, User: newUserBrief(entry.User)}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"logs":  views,
+		"count": len(logs),
+	})
+}
+
+// GetSuspiciousFileAccess lists users whose download activity looks like scraping:
+// throttled requests or at least ?threshold= downloads (default 50) within the last ?hours= (default 24).
+func (h *AdminHandler) GetSuspiciousFileAccess(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours <= 0 {
+		apierror.Abort(c, apierror.BadRequest("Invalid hours"))
+		return
+	}
+	threshold, err := strconv.Atoi(c.DefaultQuery("threshold", "50"))
+	if err != nil || threshold <= 0 {
+		apierror.Abort(c, apierror.BadRequest("Invalid threshold"))
+		return
+	}
+
+	type suspiciousUser struct {
+		UserID          uint      `json:"user_id"`
+		Email           string    `json:"email"`
+		FirstName       string    `json:"first_name"`
+		LastName        string    `json:"last_name"`
+		Downloads       int64     `json:"downloads"`
+		ThrottledEvents int64     `json:"throttled_events"`
+		DistinctFiles   int64     `json:"distinct_files"`
+		DistinctIPs     int64     `json:"distinct_ips"`
+		Bytes           int64     `json:"bytes"`
+		LastAccessAt    time.Time `json:"last_access_at"`
+	}
+
+	var users []suspiciousUser
+	if err := db.Table("file_access_logs").
+		Joins("JOIN users ON users.id = file_access_logs.user_id").
+		Where("file_access_logs.created_at > ?", time.Now().Add(-time.Duration(hours)*time.Hour)).
+		Select(`file_access_logs.user_id, users.email, users.first_name, users.last_name,
+			COUNT(*) FILTER (WHERE NOT file_access_logs.throttled) AS downloads,
+			COUNT(*) FILTER (WHERE file_access_logs.throttled) AS throttled_events,
+			COUNT(DISTINCT file_access_logs.file_path) AS distinct_files,
+			COUNT(DISTINCT file_access_logs.ip_address) AS distinct_ips,
+			COALESCE(SUM(file_access_logs.bytes), 0) AS bytes,
+			MAX(file_access_logs.created_at) AS last_access_at`).
+		Group("file_access_logs.user_id, users.email, users.first_name, users.last_name").
+		Having("COUNT(*) FILTER (WHERE NOT file_access_logs.throttled) >= ? OR COUNT(*) FILTER (WHERE file_access_logs.throttled) > 0", threshold).
+		Order("downloads DESC").
+		Scan(&users).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to build suspicious activity report"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":     users,
+		"count":     len(users),
+		"hours":     hours,
+		"threshold": threshold,
 	})
 }
 
 // GetUserManagement returns user list for admin management
 func (h *AdminHandler) GetUserManagement(c *gin.Context) {
-	var users []models.User
+	db := h.DB.WithContext(c.Request.Context())
+	type managedUser struct {
+		ID            uint       `json:"id"`
+		FirstName     string     `json:"first_name"`
+		LastName      string     `json:"last_name"`
+		Email         string     `json:"email"`
+		Phone         string     `json:"phone"`
+		Role          string     `json:"role"`
+		EmailVerified bool       `json:"email_verified"`
+		LockedUntil   *time.Time `json:"locked_until,omitempty"`
+		CreatedAt     time.Time  `json:"created_at"`
+	}
+	var users []managedUser
 
-	if err := h.DB.Select("id, first_name, last_name, email, phone, role, created_at").
-		Order("created_at DESC").Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+	if err := db.Model(&models.User{}).Select("id, first_name, last_name, email, phone, role, email_verified, locked_until, created_at").
+		Where("is_test_student = ?", false).Order("created_at DESC").Find(&users).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch users"))
 		return
 	}
 
@@ -164,6 +334,7 @@ func (h *AdminHandler) GetUserManagement(c *gin.Context) {
 
 // UpdateUserRole allows admin to change user roles
 func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID := c.Param("id")
 
 	var request struct {
@@ -171,24 +342,32 @@ func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	var user models.User
-	if err := h.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := db.First(&user, userID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
 		return
 	}
 
+	previousRole := user.Role
 	user.Role = request.Role
-	if err := h.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
+	if err := db.Save(&user).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update user role"))
 		return
 	}
+	if previousRole != user.Role {
+		recordAudit(db, c, models.AuditRoleChange, "user", user.ID, gin.H{"role": previousRole}, gin.H{"role": user.Role})
+		// Tokens carry the role, so the old one would keep working until they expire
+		if _, err := revokeSessions(db, user.ID, ""); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to revoke sessions", "user_id", user.ID, "error", err)
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User role updated successfully",
+		"message": i18n.T(i18n.Locale(c), "User role updated successfully"),
 		"user": gin.H{
 			"id":    user.ID,
 			"email": user.Email,
@@ -214,20 +393,20 @@ func (h *AdminHandler) AddEmailDomain(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	// Basic domain validation
 	if !strings.Contains(request.Domain, ".") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain format"})
+		apierror.Abort(c, apierror.BadRequest("Invalid domain format"))
 		return
 	}
 
 	validation.AddCustomDomain(request.Domain)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":         "Domain added successfully",
+		"message":         i18n.T(i18n.Locale(c), "Domain added successfully"),
 		"domain":          request.Domain,
 		"allowed_domains": validation.GetAllowedDomains(),
 	})
@@ -240,7 +419,7 @@ func (h *AdminHandler) RemoveEmailDomain(c *gin.Context) {
 	validation.RemoveCustomDomain(domain)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":         "Domain removed successfully",
+		"message":         i18n.T(i18n.Locale(c), "Domain removed successfully"),
 		"domain":          domain,
 		"allowed_domains": validation.GetAllowedDomains(),
 	})
@@ -272,19 +451,16 @@ func (h *AdminHandler) TestEmailConfiguration(c *gin.Context) {
 	}
 
 	if err := email.SendEmail(testEmail); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Email test failed: " + err.Error(),
-			"configuration": gin.H{
-				"smtp_host": h.getSMTPHost(), // You'll need to add this method
-				"smtp_port": h.getSMTPPort(),
-				"smtp_user": h.getSMTPUser(),
-			},
-		})
+		apierror.Abort(c, apierror.Internal("Email test failed, the error is in the server logs").Wrap(err).With("configuration", gin.H{
+			"smtp_host": h.getSMTPHost(), // You'll need to add this method
+			"smtp_port": h.getSMTPPort(),
+			"smtp_user": h.getSMTPUser(),
+		}))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Test email sent successfully! Please check your inbox.",
+		"message": i18n.T(i18n.Locale(c), "Test email sent successfully! Please check your inbox."),
 		"sent_to": "ermiasaddisalem18@gmail.com",
 	})
 }
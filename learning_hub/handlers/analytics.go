@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/settings"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AnalyticsHandler struct {
+	DB *gorm.DB
+}
+
+func NewAnalyticsHandler(db *gorm.DB) *AnalyticsHandler {
+	return &AnalyticsHandler{DB: db}
+}
+
+// Repeat views of a course by the same visitor within this window count once
+const courseViewDedupWindow = 30 * time.Minute
+
+// User agent fragments of crawlers, link previewers and scripts that shouldn't count as views
+var botUserAgentMarkers = []string{
+	"bot", "crawl", "spider", "slurp", "preview", "facebookexternalhit", "headless",
+	"curl", "wget", "python-requests", "go-http-client", "httpclient", "okhttp", "postman",
+}
+
+// Supported time buckets for analytics series
+var analyticsIntervals = map[string]bool{"day": true, "week": true, "month": true}
+
+type periodCount struct {
+	Period time.Time `json:"period"`
+	Count  int64     `json:"count"`
+}
+
+type periodRating struct {
+	Period        time.Time `json:"period"`
+	AverageRating float64   `json:"average_rating"`
+	Reviews       int64     `json:"reviews"`
+}
+
+type courseFunnel struct {
+	Views                int64   `json:"views"`
+	UniqueVisitors       int64   `json:"unique_visitors"`
+	Enrollments          int64   `json:"enrollments"`
+	Completions          int64   `json:"completions"`
+	ViewToEnrollRate     float64 `json:"view_to_enroll_rate"`
+	EnrollToCompleteRate float64 `json:"enroll_to_complete_rate"`
+}
+
+type currencyRevenue struct {
+	Currency           string  `json:"currency"`
+	Sales              int64   `json:"sales"`
+	GrossRevenue       float64 `json:"gross_revenue"`
+	PlatformShare      float64 `json:"platform_share"`
+	InstructorEarnings float64 `json:"instructor_earnings"`
+	Refunds            int64   `json:"refunds"`
+	RefundedAmount     float64 `json:"refunded_amount"`
+}
+
+// GetInstructorCourseAnalytics returns enrollment, revenue and rating analytics for one of the instructor's courses.
+// Optional ?from= and ?to= (YYYY-MM-DD) limit the period (default: last 12 months), ?interval= is day, week or month.
+func (h *AnalyticsHandler) GetInstructorCourseAnalytics(c *gin.Context) {
+	// The funnel and trend queries are heavy; they are cancelled if the instructor gives up waiting
+	db := h.DB.WithContext(c.Request.Context())
+	if _, exists := c.Get("userID"); !exists {
+		apierror.Abort(c, apierror.Unauthorized("User not authenticated"))
+		return
+	}
+
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	if !canTeachCourse(c, db, course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to view analytics for this course"))
+		return
+	}
+
+	from, to, ok := parseReportRange(c, time.Now().AddDate(-1, 0, 0))
+	if !ok {
+		return
+	}
+
+	interval := c.DefaultQuery("interval", "month")
+	if !analyticsIntervals[interval] {
+		apierror.Abort(c, apierror.BadRequest("Invalid interval, expected day, week or month"))
+		return
+	}
+	bucket := func(column string) string {
+		return "date_trunc('" + interval + "', " + column + ")"
+	}
+
+	// Enrollments over time
+	var enrollments []periodCount
+	if err := db.Model(&models.Enrollment{}).Scopes(withoutTestStudents).
+		Where("course_id = ? AND enrolled_at >= ? AND enrolled_at < ?", course.ID, from, to).
+		Select(bucket("enrolled_at") + " AS period, COUNT(*) AS count").
+		Group(bucket("enrolled_at")).Order("period").
+		Scan(&enrollments).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to load enrollment analytics"))
+		return
+	}
+
+	// Revenue after platform share, and refunds, per currency
+	var revenue []currencyRevenue
+	if err := db.Model(&models.Payment{}).
+		Where("course_id = ? AND created_at >= ? AND created_at < ?", course.ID, from, to).
+		Where("status IN ?", []models.PaymentStatus{models.PaymentStatusSuccess, models.PaymentStatusRefunded}).
+		Select(`currency,
+			COUNT(*) FILTER (WHERE status = ?) AS sales,
+			COALESCE(SUM(amount) FILTER (WHERE status = ?), 0) AS gross_revenue,
+			COUNT(*) FILTER (WHERE status = ?) AS refunds,
+			COALESCE(SUM(amount) FILTER (WHERE status = ?), 0) AS refunded_amount`,
+			models.PaymentStatusSuccess, models.PaymentStatusSuccess,
+			models.PaymentStatusRefunded, models.PaymentStatusRefunded).
+		Group("currency").
+		Scan(&revenue).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to load revenue analytics"))
+		return
+	}
+	share := settings.PlatformShare()
+	for i := range revenue {
+		revenue[i].PlatformShare = revenue[i].GrossRevenue * share / 100
+		revenue[i].InstructorEarnings = revenue[i].GrossRevenue - revenue[i].PlatformShare
+	}
+
+	// Rating trend
+	var ratings []periodRating
+	if err := db.Model(&models.Review{}).
+		Where("course_id = ? AND created_at >= ? AND created_at < ?", course.ID, from, to).
+		Where("status = ?", models.ContentPublished).
+		Select(bucket("created_at") + " AS period, AVG(rating) AS average_rating, COUNT(*) AS reviews").
+		Group(bucket("created_at")).Order("period").
+		Scan(&ratings).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to load rating analytics"))
+		return
+	}
+
+	funnel, err := buildCourseFunnel(db, course.ID, from, to)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to load conversion funnel"))
+		return
+	}
+
+	var totalEnrollments, completedEnrollments int64
+	var averageRating float64
+	db.Model(&models.Enrollment{}).Scopes(withoutTestStudents).Where("course_id = ?", course.ID).Count(&totalEnrollments)
+	db.Model(&models.Enrollment{}).Scopes(withoutTestStudents).Where("course_id = ? AND completed_at IS NOT NULL", course.ID).
+		Count(&completedEnrollments)
+	db.Model(&models.Review{}).Where("course_id = ? AND status = ?", course.ID, models.ContentPublished).
+		Select("COALESCE(AVG(rating), 0)").Scan(&averageRating)
+
+	completionRate := 0.0
+	if totalEnrollments > 0 {
+		completionRate = float64(completedEnrollments) / float64(totalEnrollments) * 100
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"course_id":              course.ID,
+		"from":                   from.Format("2006-01-02"),
+		"to":                     to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"interval":               interval,
+		"platform_share_percent": share,
+		"enrollments":            enrollments,
+		"revenue":                revenue,
+		"rating_trend":           ratings,
+		"funnel":                 funnel,
+		"totals": gin.H{
+			"views":           course.ViewCount,
+			"enrollments":     totalEnrollments,
+			"completions":     completedEnrollments,
+			"completion_rate": completionRate,
+			"average_rating":  averageRating,
+		},
+	})
+}
+
+// RecordCourseView records a view of a course detail page. Public; bots and repeat views are ignored.
+func (h *AnalyticsHandler) RecordCourseView(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userAgent := c.Request.UserAgent()
+	if isBotUserAgent(userAgent) {
+		c.JSON(http.StatusAccepted, gin.H{"recorded": false})
+		return
+	}
+
+	var course models.Course
+	if err := db.Select("id").Where("published = ?", true).First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+
+	view := models.CourseView{
+		CourseID: course.ID,
+		Referrer: truncate(c.Request.Referer(), 500),
+	}
+	if userID, exists := c.Get("userID"); exists {
+		id := userID.(uint)
+		var testStudents int64
+		db.Model(&models.User{}).Where("id = ? AND is_test_student = ?", id, true).Count(&testStudents)
+		if testStudents > 0 {
+			c.JSON(http.StatusAccepted, gin.H{"recorded": false})
+			return
+		}
+		view.UserID = &id
+		view.VisitorID = fmt.Sprintf("user:%d", id)
+	} else {
+		// Only a hash is kept so raw IP addresses are never stored
+		sum := sha256.Sum256([]byte(c.ClientIP() + "|" + userAgent))
+		view.VisitorID = hex.EncodeToString(sum[:16])
+	}
+
+	var recent int64
+	db.Model(&models.CourseView{}).
+		Where("course_id = ? AND visitor_id = ? AND created_at > ?", course.ID, view.VisitorID, time.Now().Add(-courseViewDedupWindow)).
+		Count(&recent)
+	if recent > 0 {
+		c.JSON(http.StatusAccepted, gin.H{"recorded": false})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&view).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Course{}).Where("id = ?", course.ID).
+			UpdateColumn("view_count", gorm.Expr("view_count + 1")).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to record view"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"recorded": true})
+}
+
+// buildCourseFunnel computes the view → enroll → complete funnel of a course for a period
+func buildCourseFunnel(db *gorm.DB, courseID uint, from, to time.Time) (courseFunnel, error) {
+	var funnel courseFunnel
+
+	viewsInPeriod := func() *gorm.DB {
+		return db.Model(&models.CourseView{}).Where("course_id = ? AND created_at >= ? AND created_at < ?", courseID, from, to)
+	}
+	enrollmentsInPeriod := func() *gorm.DB {
+		return db.Model(&models.Enrollment{}).Scopes(withoutTestStudents).
+			Where("course_id = ? AND enrolled_at >= ? AND enrolled_at < ?", courseID, from, to)
+	}
+
+	if err := viewsInPeriod().Count(&funnel.Views).Error; err != nil {
+		return funnel, err
+	}
+	if err := viewsInPeriod().Distinct("visitor_id").Count(&funnel.UniqueVisitors).Error; err != nil {
+		return funnel, err
+	}
+	if err := enrollmentsInPeriod().Count(&funnel.Enrollments).Error; err != nil {
+		return funnel, err
+	}
+	if err := enrollmentsInPeriod().Where("completed_at IS NOT NULL").Count(&funnel.Completions).Error; err != nil {
+		return funnel, err
+	}
+
+	if funnel.UniqueVisitors > 0 {
+		funnel.ViewToEnrollRate = float64(funnel.Enrollments) / float64(funnel.UniqueVisitors) * 100
+	}
+	if funnel.Enrollments > 0 {
+		funnel.EnrollToCompleteRate = float64(funnel.Completions) / float64(funnel.Enrollments) * 100
+	}
+	return funnel, nil
+}
+
+// isBotUserAgent reports whether a request looks automated
+func isBotUserAgent(userAgent string) bool {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return true
+	}
+	for _, marker := range botUserAgentMarkers {
+		if strings.Contains(ua, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate cuts s to at most max bytes
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
+
+// parseReportRange reads the optional ?from= and ?to= (YYYY-MM-DD) query parameters.
+// The returned end is exclusive; a bad value writes a 400 response and returns ok=false.
+func parseReportRange(c *gin.Context, defaultFrom time.Time) (from, to time.Time, ok bool) {
+	from = defaultFrom
+	to = time.Now().Truncate(24*time.Hour).AddDate(0, 0, 1)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("Invalid from date, expected YYYY-MM-DD"))
+			return from, to, false
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("Invalid to date, expected YYYY-MM-DD"))
+			return from, to, false
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	return from, to, true
+}
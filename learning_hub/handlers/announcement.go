@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AnnouncementHandler struct {
+	DB *gorm.DB
+}
+
+func NewAnnouncementHandler(db *gorm.DB) *AnnouncementHandler {
+	return &AnnouncementHandler{DB: db}
+}
+
+func (h *AnnouncementHandler) loadAnnouncement(c *gin.Context, courseID uint) (models.Announcement, bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	var announcement models.Announcement
+	if err := db.Where("course_id = ?", courseID).First(&announcement, c.Param("announcementId")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Announcement not found"))
+		return announcement, false
+	}
+	return announcement, true
+}
+
+// CreateAnnouncement posts an announcement and delivers it to every actively enrolled student
+func (h *AnnouncementHandler) CreateAnnouncement(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title  string `json:"title" binding:"required,max=200"`
+		Body   string `json:"body" binding:"required"`
+		Pinned bool   `json:"pinned"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	now := clock.Now()
+	announcement := models.Announcement{
+		CourseID:  course.ID,
+		AuthorID:  c.MustGet("userID").(uint),
+		Title:     strings.TrimSpace(input.Title),
+		Body:      strings.TrimSpace(input.Body),
+		Pinned:    input.Pinned,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if input.Pinned {
+		announcement.PinnedAt = &now
+	}
+	if err := db.Omit("Author").Create(&announcement).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create announcement"))
+		return
+	}
+
+	var recipients int64
+	db.Model(&models.Enrollment{}).Where("course_id = ? AND is_active = ?", course.ID, true).Count(&recipients)
+	go deliverAnnouncement(detached(db), course, announcement)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      i18n.T(i18n.Locale(c), "Announcement posted"),
+		"announcement": announcement,
+		"recipients":   recipients,
+	})
+}
+
+// deliverAnnouncement notifies enrolled students in the app and by email; meant to run in a goroutine
+func deliverAnnouncement(db *gorm.DB, course models.Course, announcement models.Announcement) {
+	var author models.User
+	db.Select("id, first_name, last_name").First(&author, announcement.AuthorID)
+	authorName := strings.TrimSpace(author.FirstName + " " + author.LastName)
+
+	var students []models.User
+	if err := db.Model(&models.User{}).Select("users.id, users.first_name, users.email").
+		Joins("JOIN enrollments ON enrollments.user_id = users.id").
+		Where("enrollments.course_id = ? AND enrollments.is_active = ?", course.ID, true).
+		Find(&students).Error; err != nil {
+		slog.Error("Failed to load students for announcement", "announcement_id", announcement.ID, "error", err)
+		return
+	}
+
+	for _, student := range students {
+		notifyUser(db, student.ID, models.NotificationAnnouncement, course.Title+": "+announcement.Title, gin.H{
+			"announcement_id": announcement.ID,
+			"course_id":       course.ID,
+			"title":           announcement.Title,
+			"body":            truncate(announcement.Body, 500),
+		})
+		if err := email.SendAnnouncementEmail(student.Email, student.FirstName, course.Title, course.ID,
+			authorName, announcement.Title, announcement.Body); err != nil {
+			slog.Error("Failed to email announcement", "announcement_id", announcement.ID, "user_id", student.ID, "error", err)
+		}
+	}
+}
+
+// GetAnnouncements lists a course's announcements, pinned ones first, for enrolled students and course managers
+func (h *AnnouncementHandler) GetAnnouncements(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	if !canTeachCourse(c, db, course) {
+		var count int64
+		db.Model(&models.Enrollment{}).
+			Where("user_id = ? AND course_id = ? AND is_active = ?", c.MustGet("userID"), course.ID, true).
+			Count(&count)
+		if count == 0 {
+			apierror.Abort(c, apierror.Forbidden("You must be enrolled in this course to read its announcements"))
+			return
+		}
+	}
+
+	var announcements []models.Announcement
+	if err := db.Where("course_id = ?", course.ID).
+		Preload("Author", func(db *gorm.DB) *gorm.DB { return db.Unscoped().Select("id, first_name, last_name, role") }).
+		Order("pinned DESC, pinned_at DESC, created_at DESC").
+		Find(&announcements).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch announcements"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"announcements": announcements,
+		"count":         len(announcements),
+	})
+}
+
+// PinAnnouncement pins an announcement to the top of the course's list or unpins it
+func (h *AnnouncementHandler) PinAnnouncement(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+	announcement, ok := h.loadAnnouncement(c, course.ID)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Pinned *bool `json:"pinned" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	announcement.Pinned = *input.Pinned
+	announcement.PinnedAt = nil
+	if announcement.Pinned {
+		now := clock.Now()
+		announcement.PinnedAt = &now
+	}
+	if err := db.Model(&announcement).Updates(map[string]interface{}{
+		"pinned":    announcement.Pinned,
+		"pinned_at": announcement.PinnedAt,
+	}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update announcement"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      i18n.T(i18n.Locale(c), "Announcement updated"),
+		"announcement": announcement,
+	})
+}
+
+// DeleteAnnouncement removes an announcement from the course
+func (h *AnnouncementHandler) DeleteAnnouncement(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+	announcement, ok := h.loadAnnouncement(c, course.ID)
+	if !ok {
+		return
+	}
+
+	if err := db.Delete(&announcement).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete announcement"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Announcement deleted")})
+}
@@ -1,9 +1,23 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/fileupload"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/sandbox"
+	"learning_hub/pkg/validation"
+	"log/slog"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -11,57 +25,68 @@ import (
 	"gorm.io/gorm"
 )
 
+var errMaxQuizAttempts = errors.New("maximum attempts reached")
+
 type AssessmentHandler struct {
-	db *gorm.DB
+	db      *gorm.DB
+	uploads *UploadHandler // submission files are uploads of the student, counted towards their quota
 }
 
-func NewAssessmentHandler(db *gorm.DB) *AssessmentHandler {
-	return &AssessmentHandler{db: db}
+func NewAssessmentHandler(db *gorm.DB, uploads *UploadHandler) *AssessmentHandler {
+	return &AssessmentHandler{db: db, uploads: uploads}
 }
 
 // CreateQuiz creates a new quiz
 func (h *AssessmentHandler) CreateQuiz(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	var input struct {
-		Title        string `json:"title" binding:"required"`
-		Description  string `json:"description"`
-		Instructions string `json:"instructions"`
-		CourseID     uint   `json:"course_id" binding:"required"`
-		ModuleID     *uint  `json:"module_id"`
-		LessonID     *uint  `json:"lesson_id"`
-		TimeLimit    int    `json:"time_limit"`
-		MaxAttempts  int    `json:"max_attempts"`
-		PassingScore int    `json:"passing_score"`
+		Title        string     `json:"title" binding:"required,max=200"`
+		Description  string     `json:"description"`
+		Instructions string     `json:"instructions"`
+		CourseID     uint       `json:"course_id" binding:"required"`
+		ModuleID     *uint      `json:"module_id"`
+		LessonID     *uint      `json:"lesson_id"`
+		TimeLimit    int        `json:"time_limit" binding:"gte=0"` // minutes, 0 for no limit
+		MaxAttempts  int        `json:"max_attempts" binding:"gte=0"`
+		PassingScore int        `json:"passing_score" binding:"gte=0,lte=100"`
+		IsRequired   bool       `json:"is_required"`
+		OpensAt      *time.Time `json:"opens_at"`
+		ClosesAt     *time.Time `json:"closes_at"`
 		Questions    []struct {
 			Question      string              `json:"question" binding:"required"`
-			QuestionType  models.QuestionType `json:"question_type" binding:"required"`
+			QuestionType  models.QuestionType `json:"question_type" binding:"required,oneof=multiple_choice true_false short_answer coding"`
 			Options       []string            `json:"options"`
 			CorrectAnswer string              `json:"correct_answer" binding:"required"`
-			Points        int                 `json:"points"`
+			Points        int                 `json:"points" binding:"gte=0"`
 			Explanation   string              `json:"explanation"`
 			OrderIndex    int                 `json:"order_index"`
-		} `json:"questions"`
+			Language      string              `json:"language" binding:"max=30"` // coding questions: run answers in the sandbox
+		} `json:"questions" binding:"dive"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if input.OpensAt != nil && input.ClosesAt != nil && !input.ClosesAt.After(*input.OpensAt) {
+		apierror.Abort(c, apierror.Invalid(validation.Problem{Field: "closes_at", Rule: "after", Param: "opens_at"}))
 		return
 	}
 
 	// Verify course exists and user is instructor
 	var course models.Course
-	if err := h.db.First(&course, input.CourseID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+	if err := db.First(&course, input.CourseID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
 		return
 	}
 
-	userID, exists := c.Get("userID")
-	if !exists || course.InstructorID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to create quiz for this course"})
+	if !canManageCourse(c, db, course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to create quiz for this course"))
 		return
 	}
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := db.Begin()
 
 	quiz := models.Quiz{
 		Title:        input.Title,
@@ -73,11 +98,14 @@ func (h *AssessmentHandler) CreateQuiz(c *gin.Context) {
 		TimeLimit:    input.TimeLimit,
 		MaxAttempts:  input.MaxAttempts,
 		PassingScore: input.PassingScore,
+		IsRequired:   input.IsRequired,
+		OpensAt:      input.OpensAt,
+		ClosesAt:     input.ClosesAt,
 	}
 
 	if err := tx.Create(&quiz).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create quiz"})
+		apierror.Abort(c, apierror.Internal("Failed to create quiz"))
 		return
 	}
 
@@ -92,6 +120,9 @@ func (h *AssessmentHandler) CreateQuiz(c *gin.Context) {
 			Explanation:   qInput.Explanation,
 			OrderIndex:    qInput.OrderIndex,
 		}
+		if qInput.QuestionType == models.QuestionTypeCoding {
+			question.Language = strings.ToLower(strings.TrimSpace(qInput.Language))
+		}
 
 		// Convert options to JSON if provided
 		if len(qInput.Options) > 0 {
@@ -101,56 +132,58 @@ func (h *AssessmentHandler) CreateQuiz(c *gin.Context) {
 
 		if err := tx.Create(&question).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create question"})
+			apierror.Abort(c, apierror.Internal("Failed to create question"))
 			return
 		}
 	}
 
 	tx.Commit()
+	refreshCourseWorkload(db, quiz.CourseID)
 
 	// Reload quiz with questions
-	h.db.Preload("Questions").First(&quiz, quiz.ID)
+	db.Preload("Questions").First(&quiz, quiz.ID)
 	c.JSON(http.StatusCreated, quiz)
 }
 
 // StartQuizAttempt starts a new quiz attempt for a student
 func (h *AssessmentHandler) StartQuizAttempt(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	quizID := c.Param("quizId")
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found"))
 		return
 	}
 
 	var quiz models.Quiz
-	if err := h.db.Preload("Questions", func(db *gorm.DB) *gorm.DB {
+	if err := db.Preload("Questions", func(db *gorm.DB) *gorm.DB {
 		return db.Order("order_index ASC")
 	}).First(&quiz, quizID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		apierror.Abort(c, apierror.NotFound("Quiz not found"))
 		return
 	}
 
 	// Check if quiz is published
 	if !quiz.IsPublished {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Quiz is not available"})
+		apierror.Abort(c, apierror.Forbidden("Quiz is not available"))
 		return
 	}
 
 	// Check if user is enrolled in the course
 	var enrollment models.Enrollment
-	if err := h.db.Where("user_id = ? AND course_id = ?", userID, quiz.CourseID).First(&enrollment).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not enrolled in this course"})
+	if err := db.Where("user_id = ? AND course_id = ?", userID, quiz.CourseID).First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.Forbidden("You are not enrolled in this course"))
 		return
 	}
 
-	// Check attempt limit
-	var attemptCount int64
-	h.db.Model(&models.QuizAttempt{}).
-		Where("user_id = ? AND quiz_id = ?", userID, quizID).
-		Count(&attemptCount)
-
-	if quiz.MaxAttempts > 0 && int(attemptCount) >= quiz.MaxAttempts {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Maximum attempts reached"})
+	// Check the quiz window
+	now := clock.Now()
+	if quiz.OpensAt != nil && now.Before(*quiz.OpensAt) {
+		apierror.Abort(c, apierror.Forbidden("Quiz is not open yet").With("opens_at", quiz.OpensAt))
+		return
+	}
+	if quiz.ClosesAt != nil && !now.Before(*quiz.ClosesAt) {
+		apierror.Abort(c, apierror.Forbidden("Quiz is closed").With("closes_at", quiz.ClosesAt))
 		return
 	}
 
@@ -168,8 +201,24 @@ func (h *AssessmentHandler) StartQuizAttempt(c *gin.Context) {
 		TotalPoints: totalPoints,
 	}
 
-	if err := h.db.Create(&attempt).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start attempt"})
+	// Counted against the limit under the lock of the student's attempts, so concurrent starts can't go over it
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		attempt.ID = 0
+		attempts, err := numberQuizAttempt(tx, &attempt)
+		if err != nil {
+			return err
+		}
+		if quiz.MaxAttempts > 0 && attempts >= int64(quiz.MaxAttempts) {
+			return errMaxQuizAttempts
+		}
+		return tx.Create(&attempt).Error
+	})
+	if errors.Is(err, errMaxQuizAttempts) {
+		apierror.Abort(c, apierror.Forbidden("Maximum attempts reached"))
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to start attempt").Wrap(err))
 		return
 	}
 
@@ -182,12 +231,38 @@ func (h *AssessmentHandler) StartQuizAttempt(c *gin.Context) {
 	})
 }
 
+// quizAttemptLockKey is the advisory lock of a student's attempts at a quiz
+func quizAttemptLockKey(userID, quizID uint) int64 {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "learning_hub:quiz_attempt:%d:%d", userID, quizID)
+	return int64(hash.Sum64())
+}
+
+// numberQuizAttempt locks the student's attempts at the quiz until tx ends and numbers the attempt after
+// their last one. It returns how many attempts they have, for the quiz's limit.
+func numberQuizAttempt(tx *gorm.DB, attempt *models.QuizAttempt) (int64, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", quizAttemptLockKey(attempt.UserID, attempt.QuizID)).Error; err != nil {
+		return 0, err
+	}
+	var taken struct {
+		Count int64
+		Last  int
+	}
+	if err := tx.Model(&models.QuizAttempt{}).Where("user_id = ? AND quiz_id = ?", attempt.UserID, attempt.QuizID).
+		Select("COUNT(*) AS count, COALESCE(MAX(attempt_number), 0) AS last").Scan(&taken).Error; err != nil {
+		return 0, err
+	}
+	attempt.AttemptNumber = taken.Last + 1
+	return taken.Count, nil
+}
+
 // SubmitQuizAnswer submits an answer for a question
 func (h *AssessmentHandler) SubmitQuizAnswer(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	attemptID := c.Param("attemptId")
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found"))
 		return
 	}
 
@@ -197,20 +272,20 @@ func (h *AssessmentHandler) SubmitQuizAnswer(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	// Verify attempt belongs to user and is not completed
 	var attempt models.QuizAttempt
-	if err := h.db.Preload("Quiz").Preload("Quiz.Questions").
+	if err := db.Preload("Quiz").Preload("Quiz.Questions").
 		First(&attempt, "id = ? AND user_id = ?", attemptID, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Attempt not found"})
+		apierror.Abort(c, apierror.NotFound("Attempt not found"))
 		return
 	}
 
 	if attempt.IsCompleted {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Attempt already completed"})
+		apierror.Abort(c, apierror.Forbidden("Attempt already completed"))
 		return
 	}
 
@@ -224,13 +299,20 @@ func (h *AssessmentHandler) SubmitQuizAnswer(c *gin.Context) {
 	}
 
 	if question.ID == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		apierror.Abort(c, apierror.NotFound("Question not found"))
+		return
+	}
+
+	isCorrect, err := h.checkAnswer(c.Request.Context(), question, input.Answer)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to run coding answer", "question_id", question.ID, "error", err)
+		apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, "Your code could not be run right now, please try again later"))
 		return
 	}
 
 	// Check if answer already exists
 	var existingAnswer models.QuizAnswer
-	err := h.db.Where("attempt_id = ? AND question_id = ?", attemptID, input.QuestionID).
+	err = db.Where("attempt_id = ? AND question_id = ?", attemptID, input.QuestionID).
 		First(&existingAnswer).Error
 
 	var answer models.QuizAnswer
@@ -240,29 +322,29 @@ func (h *AssessmentHandler) SubmitQuizAnswer(c *gin.Context) {
 			AttemptID:  attempt.ID,
 			QuestionID: input.QuestionID,
 			Answer:     input.Answer,
-			IsCorrect:  h.checkAnswer(question, input.Answer),
+			IsCorrect:  isCorrect,
 		}
 
 		if answer.IsCorrect {
 			answer.PointsEarned = float64(question.Points)
 		}
 
-		if err := h.db.Create(&answer).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit answer"})
+		if err := db.Create(&answer).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to submit answer"))
 			return
 		}
 	} else {
 		// Update existing answer
 		existingAnswer.Answer = input.Answer
-		existingAnswer.IsCorrect = h.checkAnswer(question, input.Answer)
+		existingAnswer.IsCorrect = isCorrect
 		if existingAnswer.IsCorrect {
 			existingAnswer.PointsEarned = float64(question.Points)
 		} else {
 			existingAnswer.PointsEarned = 0
 		}
 
-		if err := h.db.Save(&existingAnswer).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update answer"})
+		if err := db.Save(&existingAnswer).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to update answer"))
 			return
 		}
 		answer = existingAnswer
@@ -273,17 +355,18 @@ func (h *AssessmentHandler) SubmitQuizAnswer(c *gin.Context) {
 
 // CompleteQuizAttempt completes a quiz attempt and calculates score
 func (h *AssessmentHandler) CompleteQuizAttempt(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	attemptID := c.Param("attemptId")
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found"))
 		return
 	}
 
 	var attempt models.QuizAttempt
-	if err := h.db.Preload("Answers").Preload("Answers.Question").
+	if err := db.Preload("Answers").Preload("Answers.Question").
 		Preload("Quiz").First(&attempt, "id = ? AND user_id = ?", attemptID, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Attempt not found"})
+		apierror.Abort(c, apierror.NotFound("Attempt not found"))
 		return
 	}
 
@@ -311,14 +394,65 @@ func (h *AssessmentHandler) CompleteQuizAttempt(c *gin.Context) {
 	attempt.CompletedAt = &now
 	attempt.TimeSpent = int(now.Sub(attempt.StartedAt).Seconds())
 
-	if err := h.db.Save(&attempt).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete attempt"})
+	if err := db.Save(&attempt).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to complete attempt"))
 		return
 	}
+	emitQuizCompleted(db, attempt, attempt.Quiz.CourseID)
+
+	if isPassed {
+		if err := awardQuizPoints(db, attempt.UserID, attempt.Quiz); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to award quiz points", "user_id", attempt.UserID, "error", err)
+		}
+		// Lessons completed by their quiz complete now
+		if certificate, err := completeQuizLesson(db, attempt.UserID, attempt.Quiz); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to complete the lesson of the quiz", "quiz_id", attempt.QuizID, "user_id", attempt.UserID, "error", err)
+		} else if certificate != nil {
+			go sendCertificateEmail(detached(db), *certificate)
+		}
+	}
+
+	// Passing the last required quiz of a completed course issues the certificate
+	if isPassed && attempt.Quiz.IsRequired {
+		var enrollment models.Enrollment
+		if err := db.Where("user_id = ? AND course_id = ?", attempt.UserID, attempt.Quiz.CourseID).First(&enrollment).Error; err == nil {
+			if certificate, err := issueCertificateIfEligible(db, &enrollment); err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to issue certificate", "enrollment_id", enrollment.ID, "error", err)
+			} else if certificate != nil {
+				go sendCertificateEmail(detached(db), *certificate)
+			}
+		}
+	}
 
 	c.JSON(http.StatusOK, attempt)
 }
 
+// DeleteQuiz moves a quiz to the trash
+func (h *AssessmentHandler) DeleteQuiz(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	var quiz models.Quiz
+	if err := db.Preload("Course").First(&quiz, c.Param("quizId")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Quiz not found"))
+		return
+	}
+
+	if !canManageCourse(c, db, quiz.Course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to delete this quiz"))
+		return
+	}
+
+	if err := db.Delete(&quiz).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete quiz"))
+		return
+	}
+	refreshCourseWorkload(db, quiz.CourseID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Quiz moved to trash"),
+		"purge_at": clock.Now().Add(trashRetention),
+	})
+}
+
 // Helper functions
 func (h *AssessmentHandler) sanitizeQuiz(quiz models.Quiz) models.Quiz {
 	// Remove correct answers from questions
@@ -328,16 +462,27 @@ func (h *AssessmentHandler) sanitizeQuiz(quiz models.Quiz) models.Quiz {
 	return quiz
 }
 
-func (h *AssessmentHandler) checkAnswer(question models.QuizQuestion, answer string) bool {
+// checkAnswer grades an answer. It only fails when a coding answer cannot be run in the sandbox.
+func (h *AssessmentHandler) checkAnswer(ctx context.Context, question models.QuizQuestion, answer string) (bool, error) {
 	switch question.QuestionType {
 	case models.QuestionTypeMultipleChoice, models.QuestionTypeTrueFalse:
-		return normalizeString(question.CorrectAnswer) == normalizeString(answer)
+		return normalizeString(question.CorrectAnswer) == normalizeString(answer), nil
 	case models.QuestionTypeShortAnswer:
 		// For short answer, we might want more flexible matching
 		return strings.Contains(normalizeString(question.CorrectAnswer), normalizeString(answer)) ||
-			strings.Contains(normalizeString(answer), normalizeString(question.CorrectAnswer))
+			strings.Contains(normalizeString(answer), normalizeString(question.CorrectAnswer)), nil
+	case models.QuestionTypeCoding:
+		if question.Language == "" {
+			return normalizeString(question.CorrectAnswer) == normalizeString(answer), nil
+		}
+		// The student's program is correct when it runs and prints the expected output
+		result, err := sandbox.Run(ctx, question.Language, answer, "")
+		if err != nil {
+			return false, err
+		}
+		return result.Succeeded() && sandbox.SameOutput(result.Stdout, question.CorrectAnswer), nil
 	default:
-		return normalizeString(question.CorrectAnswer) == normalizeString(answer)
+		return normalizeString(question.CorrectAnswer) == normalizeString(answer), nil
 	}
 }
 
@@ -347,6 +492,7 @@ func normalizeString(s string) string {
 
 // CreateAssignment creates a new assignment
 func (h *AssessmentHandler) CreateAssignment(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	var input struct {
 		Title        string    `json:"title" binding:"required"`
 		Description  string    `json:"description"`
@@ -358,20 +504,19 @@ func (h *AssessmentHandler) CreateAssignment(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	// Verify course exists and user is instructor
 	var course models.Course
-	if err := h.db.First(&course, input.CourseID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+	if err := db.First(&course, input.CourseID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
 		return
 	}
 
-	userID, exists := c.Get("userID")
-	if !exists || course.InstructorID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to create assignment for this course"})
+	if !canManageCourse(c, db, course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to create assignment for this course"))
 		return
 	}
 
@@ -385,8 +530,8 @@ func (h *AssessmentHandler) CreateAssignment(c *gin.Context) {
 		MaxPoints:    input.MaxPoints,
 	}
 
-	if err := h.db.Create(&assignment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create assignment"})
+	if err := db.Create(&assignment).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create assignment"))
 		return
 	}
 
@@ -395,39 +540,40 @@ func (h *AssessmentHandler) CreateAssignment(c *gin.Context) {
 
 // SubmitAssignment handles assignment submissions
 func (h *AssessmentHandler) SubmitAssignment(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	assignmentID := c.Param("assignmentId")
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found"))
 		return
 	}
 
 	var assignment models.Assignment
-	if err := h.db.First(&assignment, assignmentID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+	if err := db.First(&assignment, assignmentID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Assignment not found"))
 		return
 	}
 
 	// Check if assignment is published
 	if !assignment.IsPublished {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Assignment is not available"})
+		apierror.Abort(c, apierror.Forbidden("Assignment is not available"))
 		return
 	}
 
 	// Check if user is enrolled in the course
 	var enrollment models.Enrollment
-	if err := h.db.Where("user_id = ? AND course_id = ?", userID, assignment.CourseID).First(&enrollment).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not enrolled in this course"})
+	if err := db.Where("user_id = ? AND course_id = ?", userID, assignment.CourseID).First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.Forbidden("You are not enrolled in this course"))
 		return
 	}
 
 	// Check if already submitted
 	var existingSubmission models.AssignmentSubmission
-	err := h.db.Where("assignment_id = ? AND user_id = ?", assignmentID, userID).
+	err := db.Where("assignment_id = ? AND user_id = ?", assignmentID, userID).
 		First(&existingSubmission).Error
 
 	if err == nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Already submitted this assignment"})
+		apierror.Abort(c, apierror.Forbidden("Already submitted this assignment"))
 		return
 	}
 
@@ -435,22 +581,20 @@ func (h *AssessmentHandler) SubmitAssignment(c *gin.Context) {
 	submissionText := c.PostForm("submission_text")
 
 	if file == nil && submissionText == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Either file or text submission is required"})
+		apierror.Abort(c, apierror.BadRequest("Either file or text submission is required"))
 		return
 	}
 
-	var fileURL string
+	var fileURL, fileHash, textHash string
 	if file != nil {
-		// Upload submission file
-		// Note: You'll need to integrate with your existing file upload system
-		// For now, we'll just store the filename
-		fileURL = "/uploads/assignments/" + file.Filename
-
-		// Save the file (you can integrate with your existing upload handler)
-		if err := c.SaveUploadedFile(file, "./uploads/assignments/"+file.Filename); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file"})
+		record, ok := h.storeSubmissionFile(c, db, userID.(uint), file)
+		if !ok {
 			return
 		}
+		fileURL, fileHash = record.FileURL, record.Checksum
+	}
+	if submissionText != "" {
+		textHash = hashText(submissionText)
 	}
 
 	submission := models.AssignmentSubmission{
@@ -458,19 +602,104 @@ func (h *AssessmentHandler) SubmitAssignment(c *gin.Context) {
 		UserID:         userID.(uint),
 		FileURL:        fileURL,
 		SubmissionText: submissionText,
-		SubmittedAt:    time.Now(),
+		SubmittedAt:    clock.Now(),
+		FileHash:       fileHash,
+		TextHash:       textHash,
 	}
 
-	if err := h.db.Create(&submission).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit assignment"})
+	if err := db.Create(&submission).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to submit assignment"))
 		return
 	}
 
+	go sendSubmissionReceipt(detached(db), submission, assignment)
+
 	c.JSON(http.StatusCreated, submission)
 }
 
+// storeSubmissionFile validates a submitted file and stores it under a generated key, registered as an
+// upload of the student. Submissions are never deduplicated: each is kept as its student sent it. On
+// failure the request is aborted and ok is false.
+func (h *AssessmentHandler) storeSubmissionFile(c *gin.Context, db *gorm.DB, userID uint, file *multipart.FileHeader) (record models.UploadedFile, ok bool) {
+	ctx := c.Request.Context()
+	fileType, err := fileupload.DetectFileType(file.Filename)
+	if err != nil || fileType == fileupload.FileTypeVideo {
+		apierror.Abort(c, apierror.BadRequest("Unsupported file type. Please specify file type or upload a supported file."))
+		return record, false
+	}
+	// Validation scans documents; submitted images are scanned too
+	err = fileupload.ValidateFile(ctx, file, fileType).Error
+	if err == nil && fileType != fileupload.FileTypeDocument {
+		err = fileupload.ScanFile(ctx, file)
+	}
+	if err != nil {
+		if !rejectScannedFile(c, db, file, quarantineSourceSubmission, err) {
+			apierror.Abort(c, apierror.BadRequest(err.Error()))
+		}
+		return record, false
+	}
+
+	quota, err := h.uploads.quotaFor(db, userID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check upload quota").Wrap(err))
+		return record, false
+	}
+	if quota.exceededBy(file.Size) {
+		apierror.Abort(c, apierror.New(http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload quota exceeded: %s of %s used, this file needs %s", formatBytes(quota.Usage), formatBytes(quota.Limit), formatBytes(file.Size))).With("usage", quota.Usage).With("limit", quota.Limit).With("file_size", file.Size))
+		return record, false
+	}
+
+	secureFilename, err := generateSecureFilename(file.Filename)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to generate secure filename").Wrap(err))
+		return record, false
+	}
+	key := submissionStoragePrefix + secureFilename
+	contentType := mime.TypeByExtension(filepath.Ext(secureFilename))
+	checksum, err := hashUploadedFile(file)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to read uploaded file").Wrap(err))
+		return record, false
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to read uploaded file").Wrap(err))
+		return record, false
+	}
+	defer src.Close()
+	if err := fileupload.Storage().Save(ctx, key, src, file.Size, contentType); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to upload file").Wrap(err))
+		return record, false
+	}
+
+	now := clock.Now()
+	record = models.UploadedFile{
+		OwnerID:      &userID,
+		FileType:     fileType,
+		StorageKey:   key,
+		FileURL:      "/uploads/" + key,
+		OriginalName: file.Filename,
+		ContentType:  contentType,
+		Size:         file.Size,
+		Checksum:     checksum,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := db.Create(&record).Error; err != nil {
+		if err := fileupload.Storage().Delete(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete unrecorded submission file", "storage_key", key, "error", err)
+		}
+		apierror.Abort(c, apierror.Internal("Failed to record upload").Wrap(err))
+		return record, false
+	}
+	uploadSize.WithLabelValues("assignment").Observe(float64(file.Size))
+	return record, true
+}
+
 // GradeAssignment allows instructors to grade submissions
 func (h *AssessmentHandler) GradeAssignment(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	submissionID := c.Param("submissionId")
 
 	var input struct {
@@ -479,129 +708,156 @@ func (h *AssessmentHandler) GradeAssignment(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	var submission models.AssignmentSubmission
-	if err := h.db.Preload("Assignment").First(&submission, submissionID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+	if err := db.Preload("Assignment").First(&submission, submissionID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Submission not found"))
 		return
 	}
 
-	// Verify user is the course instructor
+	// Verify user is on the course staff
 	var course models.Course
-	if err := h.db.First(&course, submission.Assignment.CourseID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+	if err := db.First(&course, submission.Assignment.CourseID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
 		return
 	}
 
-	userID, exists := c.Get("userID")
-	if !exists || course.InstructorID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to grade this assignment"})
+	if !canTeachCourse(c, db, course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to grade this assignment"))
 		return
 	}
 
 	// Validate grade
 	if input.Grade < 0 || input.Grade > float64(submission.Assignment.MaxPoints) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid grade"})
+		apierror.Abort(c, apierror.BadRequest("Invalid grade"))
 		return
 	}
 
+	var before gin.H
+	if submission.IsGraded {
+		before = gin.H{"grade": submission.Grade, "feedback": submission.Feedback}
+	}
 	submission.Grade = &input.Grade
 	submission.Feedback = input.Feedback
 	submission.IsGraded = true
 	now := time.Now()
 	submission.GradedAt = &now
 
-	if err := h.db.Save(&submission).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grade submission"})
+	if err := db.Save(&submission).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to grade submission"))
 		return
 	}
+	recordAudit(db, c, models.AuditGradeChange, "assignment_submission", submission.ID, before, gin.H{
+		"grade":      input.Grade,
+		"feedback":   input.Feedback,
+		"max_points": submission.Assignment.MaxPoints,
+		"user_id":    submission.UserID,
+	})
+
+	notifyUser(db, submission.UserID, models.NotificationGrading, "Your assignment \""+submission.Assignment.Title+"\" has been graded", gin.H{
+		"submission_id": submission.ID,
+		"assignment_id": submission.AssignmentID,
+		"course_id":     submission.Assignment.CourseID,
+		"grade":         input.Grade,
+		"max_points":    submission.Assignment.MaxPoints,
+		"feedback":      submission.Feedback,
+	})
 
 	c.JSON(http.StatusOK, submission)
 }
 
 // GetQuizAttempts returns all attempts for a quiz (for instructors)
 func (h *AssessmentHandler) GetQuizAttempts(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	quizID := c.Param("quizId")
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+	if _, exists := c.Get("userID"); !exists {
+		apierror.Abort(c, apierror.Unauthorized("User ID not found"))
 		return
 	}
 
-	// Verify user is instructor of the course
+	// Verify user is on the course staff
 	var quiz models.Quiz
-	if err := h.db.Preload("Course").First(&quiz, quizID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+	if err := db.Preload("Course").First(&quiz, quizID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Quiz not found"))
 		return
 	}
 
-	if quiz.Course.InstructorID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view these attempts"})
+	if !canTeachCourse(c, db, quiz.Course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to view these attempts"))
 		return
 	}
 
 	var attempts []models.QuizAttempt
-	if err := h.db.Preload("User").Preload("Answers").
+	if err := db.Preload("User", contactUserFields).Preload("Answers").
 		Where("quiz_id = ?", quizID).
 		Order("created_at DESC").
 		Find(&attempts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attempts"})
+		apierror.Abort(c, apierror.Internal("Failed to fetch attempts"))
 		return
 	}
 
-	c.JSON(http.StatusOK, attempts)
+	views := make([]quizAttemptView, len(attempts))
+	for i, attempt := range attempts {
+		views[i] = quizAttemptView{QuizAttempt: attempt, User: newUserBrief(attempt.User)}
+	}
+	c.JSON(http.StatusOK, views)
 }
 
 // GetAssignmentSubmissions returns all submissions for an assignment (for instructors)
 func (h *AssessmentHandler) GetAssignmentSubmissions(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	assignmentID := c.Param("assignmentId")
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+	if _, exists := c.Get("userID"); !exists {
+		apierror.Abort(c, apierror.Unauthorized("User ID not found"))
 		return
 	}
 
-	// Verify user is instructor of the course
+	// Verify user is on the course staff
 	var assignment models.Assignment
-	if err := h.db.Preload("Course").First(&assignment, assignmentID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+	if err := db.Preload("Course").First(&assignment, assignmentID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Assignment not found"))
 		return
 	}
 
-	if assignment.Course.InstructorID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view these submissions"})
+	if !canTeachCourse(c, db, assignment.Course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to view these submissions"))
 		return
 	}
 
 	var submissions []models.AssignmentSubmission
-	if err := h.db.Preload("User").Where("assignment_id = ?", assignmentID).
+	if err := db.Preload("User", contactUserFields).Where("assignment_id = ?", assignmentID).
 		Order("submitted_at DESC").
 		Find(&submissions).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch submissions"})
+		apierror.Abort(c, apierror.Internal("Failed to fetch submissions"))
 		return
 	}
 
-	c.JSON(http.StatusOK, submissions)
+	views := make([]submissionView, len(submissions))
+	for i, submission := range submissions {
+		views[i] = submissionView{AssignmentSubmission: submission, User: newUserBrief(submission.User)}
+	}
+	c.JSON(http.StatusOK, views)
 }
 
 // GetStudentQuizAttempts returns a student's own quiz attempts
 func (h *AssessmentHandler) GetStudentQuizAttempts(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	quizID := c.Param("quizId")
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found"))
 		return
 	}
 
 	var attempts []models.QuizAttempt
-	if err := h.db.Preload("Quiz").Preload("Answers").
+	if err := db.Preload("Quiz").Preload("Answers").
 		Where("quiz_id = ? AND user_id = ?", quizID, userID).
 		Order("created_at DESC").
 		Find(&attempts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attempts"})
+		apierror.Abort(c, apierror.Internal("Failed to fetch attempts"))
 		return
 	}
 
@@ -610,19 +866,20 @@ func (h *AssessmentHandler) GetStudentQuizAttempts(c *gin.Context) {
 
 // GetStudentAssignmentSubmissions returns a student's own assignment submissions
 func (h *AssessmentHandler) GetStudentAssignmentSubmissions(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	assignmentID := c.Param("assignmentId")
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found"))
 		return
 	}
 
 	var submissions []models.AssignmentSubmission
-	if err := h.db.Preload("Assignment").
+	if err := db.Preload("Assignment").
 		Where("assignment_id = ? AND user_id = ?", assignmentID, userID).
 		Order("submitted_at DESC").
 		Find(&submissions).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch submissions"})
+		apierror.Abort(c, apierror.Internal("Failed to fetch submissions"))
 		return
 	}
 
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordAudit adds an audit log entry. The actor is the request's user; pass a nil context for actions
+// of the system. before and after are the record's relevant fields, nil when it didn't exist or is gone.
+// Pass the transaction when the action runs in one, so the entry is kept only when the action is.
+func recordAudit(db *gorm.DB, c *gin.Context, action, entityType string, entityID uint, before, after interface{}) {
+	entry := models.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		CreatedAt:  clock.Now(),
+	}
+	if c != nil {
+		if userID, ok := c.Get("userID"); ok {
+			id := userID.(uint)
+			entry.ActorID = &id
+		}
+		if role, ok := c.Get("userRole"); ok {
+			entry.ActorRole, _ = role.(string)
+		}
+		// Actions made while impersonating a user are the admin's
+		if impersonatorID := c.GetUint("impersonatorID"); impersonatorID != 0 {
+			entry.OnBehalfOfID = entry.ActorID
+			entry.ActorID = &impersonatorID
+			entry.ActorRole = "admin"
+		}
+		entry.IP = c.ClientIP()
+	}
+	if before != nil {
+		data, _ := json.Marshal(before)
+		entry.Before = models.JSON(data)
+	}
+	if after != nil {
+		data, _ := json.Marshal(after)
+		entry.After = models.JSON(data)
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to record audit log", "action", action, "entity_type", entityType, "entity_id", entityID, "error", err)
+	}
+}
+
+// auditPaymentStatus records a payment moving from one status to another, and counts it for /metrics; a
+// move to refunded is a refund
+func auditPaymentStatus(db *gorm.DB, c *gin.Context, payment models.Payment, from models.PaymentStatus) {
+	if payment.Status == from {
+		return
+	}
+	countPayment(payment)
+	action := models.AuditPaymentStatus
+	if payment.Status == models.PaymentStatusRefunded {
+		action = models.AuditRefund
+	}
+	recordAudit(db, c, action, "payment", payment.ID,
+		gin.H{"status": from},
+		gin.H{"status": payment.Status, "amount": payment.Amount, "currency": payment.Currency, "tx_ref": payment.ChapaTxRef})
+}
+
+type AuditHandler struct {
+	DB *gorm.DB
+}
+
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{DB: db}
+}
+
+// GetAuditLogs lists audit log entries, newest first (?actor_id=, ?action=, ?entity_type= with ?entity_id=,
+// ?from= and ?to= as YYYY-MM-DD, ?page=)
+func (h *AuditHandler) GetAuditLogs(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	from, to, ok := parseReportRange(c, time.Time{})
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	const pageSize = 50
+
+	query := db.Model(&models.AuditLog{}).Where("created_at >= ? AND created_at < ?", from, to)
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+		if entityID := c.Query("entity_id"); entityID != "" {
+			query = query.Where("entity_id = ?", entityID)
+		}
+	}
+	var total int64
+	query.Count(&total)
+
+	var entries []models.AuditLog
+	if err := query.Preload("Actor", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id, first_name, last_name, email, role")
+	}).Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&entries).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch audit logs"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"page":    page,
+	})
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Longest single away period an instructor can set
+	maxAwayPeriod        = 365 * 24 * time.Hour
+	maxAutoReplyLength   = 2000
+	defaultAwayAutoReply = "I'm currently away and will reply when I'm back."
+)
+
+type AvailabilityHandler struct {
+	DB *gorm.DB
+}
+
+func NewAvailabilityHandler(db *gorm.DB) *AvailabilityHandler {
+	return &AvailabilityHandler{DB: db}
+}
+
+// currentAwayPeriod returns the away period covering now, or nil when the instructor is available
+func currentAwayPeriod(db *gorm.DB, instructorID uint) *models.InstructorAwayPeriod {
+	now := clock.Now()
+	var period models.InstructorAwayPeriod
+	if err := db.Where("instructor_id = ? AND starts_at <= ? AND ends_at > ?", instructorID, now, now).
+		Order("ends_at DESC").First(&period).Error; err != nil {
+		return nil
+	}
+	return &period
+}
+
+// instructorAvailability describes whether an instructor is away, as shown to students
+func instructorAvailability(db *gorm.DB, instructorID uint) gin.H {
+	period := currentAwayPeriod(db, instructorID)
+	if period == nil {
+		return gin.H{"away": false}
+	}
+
+	autoReply := period.AutoReply
+	if autoReply == "" {
+		autoReply = defaultAwayAutoReply
+	}
+	return gin.H{
+		"away":       true,
+		"away_until": period.EndsAt,
+		"auto_reply": autoReply,
+	}
+}
+
+// awayTimeBetween returns how much of [from, to) the instructor was away, counting overlapping periods once
+func awayTimeBetween(db *gorm.DB, instructorID uint, from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+
+	var periods []models.InstructorAwayPeriod
+	db.Where("instructor_id = ? AND starts_at < ? AND ends_at > ?", instructorID, to, from).
+		Find(&periods)
+	sort.Slice(periods, func(i, j int) bool { return periods[i].StartsAt.Before(periods[j].StartsAt) })
+
+	var away time.Duration
+	covered := from
+	for _, p := range periods {
+		start, end := p.StartsAt, p.EndsAt
+		if start.Before(covered) {
+			start = covered
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			away += end.Sub(start)
+			covered = end
+		}
+	}
+	return away
+}
+
+// responseTime returns how long an instructor took to respond, leaving out time they were away
+func responseTime(db *gorm.DB, instructorID uint, askedAt, answeredAt time.Time) time.Duration {
+	if !answeredAt.After(askedAt) {
+		return 0
+	}
+	return answeredAt.Sub(askedAt) - awayTimeBetween(db, instructorID, askedAt, answeredAt)
+}
+
+// GetMyAvailability returns the instructor's current status and upcoming away periods
+func (h *AvailabilityHandler) GetMyAvailability(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, _ := c.Get("userID")
+
+	var periods []models.InstructorAwayPeriod
+	if err := db.Where("instructor_id = ? AND ends_at > ?", userID, clock.Now()).
+		Order("starts_at").Find(&periods).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch away periods"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       instructorAvailability(db, userID.(uint)),
+		"away_periods": periods,
+	})
+}
+
+// AddAwayPeriod schedules an away period; starts_at defaults to now
+func (h *AvailabilityHandler) AddAwayPeriod(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, _ := c.Get("userID")
+
+	var input struct {
+		StartsAt  *time.Time `json:"starts_at"`
+		EndsAt    time.Time  `json:"ends_at" binding:"required"`
+		AutoReply string     `json:"auto_reply"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	now := clock.Now()
+	startsAt := now
+	if input.StartsAt != nil && input.StartsAt.After(now) {
+		startsAt = *input.StartsAt
+	}
+	if !input.EndsAt.After(startsAt) {
+		apierror.Abort(c, apierror.BadRequest("ends_at must be after starts_at and in the future"))
+		return
+	}
+	if input.EndsAt.Sub(startsAt) > maxAwayPeriod {
+		apierror.Abort(c, apierror.BadRequest("An away period can last at most one year"))
+		return
+	}
+	if len(input.AutoReply) > maxAutoReplyLength {
+		apierror.Abort(c, apierror.BadRequest("auto_reply must be at most 2000 characters"))
+		return
+	}
+
+	period := models.InstructorAwayPeriod{
+		InstructorID: userID.(uint),
+		StartsAt:     startsAt,
+		EndsAt:       input.EndsAt,
+		AutoReply:    input.AutoReply,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := db.Create(&period).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save away period"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, period)
+}
+
+// EndAwayPeriod cancels an upcoming away period, or ends a current one now so its elapsed part
+// still counts for response-time metrics
+func (h *AvailabilityHandler) EndAwayPeriod(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, _ := c.Get("userID")
+
+	var period models.InstructorAwayPeriod
+	if err := db.Where("instructor_id = ?", userID).First(&period, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Away period not found"))
+		return
+	}
+
+	now := clock.Now()
+	if !period.EndsAt.After(now) {
+		apierror.Abort(c, apierror.BadRequest("Away period has already ended"))
+		return
+	}
+
+	var err error
+	if period.StartsAt.After(now) {
+		err = db.Delete(&period).Error
+	} else {
+		err = db.Model(&period).Updates(map[string]interface{}{"ends_at": now, "updated_at": now}).Error
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update away period"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Away period ended"),
+		"status":  instructorAvailability(db, period.InstructorID),
+	})
+}
+
+// GetInstructorAvailability tells students whether an instructor is away and shows the auto-reply
+func (h *AvailabilityHandler) GetInstructorAvailability(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var instructor models.User
+	if err := db.Where("role IN ?", []string{"instructor", "admin"}).First(&instructor, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Instructor not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, instructorAvailability(db, instructor.ID))
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/config"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/ical"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/settings"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Past events stay in the feed this long so recent deadlines don't vanish from calendars
+const calendarHistory = 30 * 24 * time.Hour
+
+type CalendarHandler struct {
+	DB         *gorm.DB
+	appBaseURL string
+}
+
+func NewCalendarHandler(db *gorm.DB, cfg *config.Config) *CalendarHandler {
+	return &CalendarHandler{
+		DB:         db,
+		appBaseURL: strings.TrimRight(cfg.AppBaseURL, "/"),
+	}
+}
+
+func (h *CalendarHandler) feedURL(token string) string {
+	return h.appBaseURL + "/api/calendar.ics?token=" + token
+}
+
+func newCalendarToken() (string, error) {
+	return idgen.Token("cal_", 24)
+}
+
+// GetCalendarFeed returns the caller's calendar subscription URL, creating the token on first use
+func (h *CalendarHandler) GetCalendarFeed(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.Select("id, calendar_token").First(&user, c.MustGet("userID").(uint)).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if user.CalendarToken == nil {
+		token, err := newCalendarToken()
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to create calendar feed"))
+			return
+		}
+		if err := db.Model(&user).Update("calendar_token", token).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to create calendar feed"))
+			return
+		}
+		user.CalendarToken = &token
+	}
+	c.JSON(http.StatusOK, gin.H{"url": h.feedURL(*user.CalendarToken)})
+}
+
+// ResetCalendarFeed replaces the caller's calendar token; subscriptions with the old URL stop updating
+func (h *CalendarHandler) ResetCalendarFeed(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	token, err := newCalendarToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to reset calendar feed"))
+		return
+	}
+	if err := db.Model(&models.User{}).Where("id = ?", c.MustGet("userID").(uint)).
+		Update("calendar_token", token).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to reset calendar feed"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Calendar feed reset. Subscribe again with the new URL."),
+		"url":     h.feedURL(token),
+	})
+}
+
+// GetCalendarICS serves the iCalendar feed of the token's user: assignment due dates, quiz windows
+// and live sessions of the courses they are actively enrolled in
+func (h *CalendarHandler) GetCalendarICS(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	token := c.Query("token")
+	if token == "" {
+		apierror.Abort(c, apierror.Unauthorized("Calendar token required"))
+		return
+	}
+	var user models.User
+	if err := db.Select("id").Where("calendar_token = ?", token).First(&user).Error; err != nil {
+		apierror.Abort(c, apierror.Unauthorized("Invalid calendar token"))
+		return
+	}
+
+	var courses []models.Course
+	if err := db.Select("courses.id, courses.title").
+		Joins("JOIN enrollments ON enrollments.course_id = courses.id").
+		Where("enrollments.user_id = ? AND enrollments.is_active = ?", user.ID, true).
+		Find(&courses).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to build calendar"))
+		return
+	}
+	titles := make(map[uint]string, len(courses))
+	courseIDs := make([]uint, len(courses))
+	for i, course := range courses {
+		titles[course.ID], courseIDs[i] = course.Title, course.ID
+	}
+
+	now := clock.Now()
+	since := now.Add(-calendarHistory)
+	frontendURL := settings.FrontendURL()
+	cal := ical.Calendar{Name: "LearnHub"}
+	if len(courseIDs) > 0 {
+		var assignments []models.Assignment
+		if err := db.Where("course_id IN ? AND is_published = ? AND due_date >= ?", courseIDs, true, since).
+			Find(&assignments).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to build calendar"))
+			return
+		}
+		for _, assignment := range assignments {
+			cal.Events = append(cal.Events, ical.Event{
+				UID:          fmt.Sprintf("assignment-%d@learnhub", assignment.ID),
+				Summary:      "Due: " + assignment.Title,
+				Description:  titles[assignment.CourseID],
+				URL:          fmt.Sprintf("%s/courses/%d", frontendURL, assignment.CourseID),
+				Start:        assignment.DueDate,
+				LastModified: assignment.UpdatedAt,
+			})
+		}
+
+		var quizzes []models.Quiz
+		if err := db.Where("course_id IN ? AND is_published = ?", courseIDs, true).
+			Where("(opens_at IS NOT NULL OR closes_at IS NOT NULL) AND COALESCE(closes_at, opens_at) >= ?", since).
+			Find(&quizzes).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to build calendar"))
+			return
+		}
+		for _, quiz := range quizzes {
+			event := ical.Event{
+				UID:          fmt.Sprintf("quiz-%d@learnhub", quiz.ID),
+				Description:  titles[quiz.CourseID],
+				URL:          fmt.Sprintf("%s/courses/%d", frontendURL, quiz.CourseID),
+				LastModified: quiz.UpdatedAt,
+			}
+			switch {
+			case quiz.OpensAt != nil && quiz.ClosesAt != nil:
+				event.Summary, event.Start, event.End = "Quiz open: "+quiz.Title, *quiz.OpensAt, *quiz.ClosesAt
+			case quiz.ClosesAt != nil:
+				event.Summary, event.Start = "Quiz closes: "+quiz.Title, *quiz.ClosesAt
+			default:
+				event.Summary, event.Start = "Quiz opens: "+quiz.Title, *quiz.OpensAt
+			}
+			cal.Events = append(cal.Events, event)
+		}
+
+		var sessions []models.LiveSession
+		if err := db.Where("course_id IN ? AND starts_at >= ?", courseIDs, since).
+			Find(&sessions).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to build calendar"))
+			return
+		}
+		for _, session := range sessions {
+			description := titles[session.CourseID]
+			if session.Description != "" {
+				description += "\n\n" + session.Description
+			}
+			cal.Events = append(cal.Events, ical.Event{
+				UID:          fmt.Sprintf("live-session-%d@learnhub", session.ID),
+				Summary:      "Live class: " + session.Title,
+				Description:  description,
+				URL:          fmt.Sprintf("%s/courses/%d/live/%d", frontendURL, session.CourseID, session.ID),
+				Start:        session.StartsAt,
+				End:          session.EndsAt(),
+				LastModified: session.UpdatedAt,
+				Cancelled:    session.Status == models.LiveSessionCancelled,
+			})
+		}
+	}
+
+	c.Header("Cache-Control", "private, max-age=900")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", cal.Encode(now))
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"learning_hub/pkg/config"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/oauth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CapabilitiesHandler tells clients which optional features this deployment has, so they can hide
+// what is not available instead of assuming an environment
+type CapabilitiesHandler struct {
+	capabilities gin.H
+}
+
+// NewCapabilitiesHandler works out the capabilities once, they only change with the configuration
+func NewCapabilitiesHandler(cfg *config.Config, transcoding bool) *CapabilitiesHandler {
+	providers := []string{}
+	if cfg.IsChapaEnabled() {
+		providers = append(providers, "chapa")
+	}
+
+	return &CapabilitiesHandler{capabilities: gin.H{
+		"payments": gin.H{
+			"enabled":   len(providers) > 0,
+			"providers": providers,
+		},
+		"push": gin.H{
+			"server_sent_events": true, // GET /api/notifications/stream
+			"mobile_push":        false,
+		},
+		"email":             cfg.SMTPUsername != "",
+		"live_sessions":     cfg.MeetingProvider != "",
+		"ai_assistant":      false,
+		"video_transcoding": transcoding,
+		"code_execution":    cfg.SandboxURL != "",
+		"youtube_import":    cfg.YouTubeAPIKey != "",
+		"virus_scanning":    cfg.ClamAVAddress != "",
+		"country_pricing":   cfg.CountryHeader != "",
+		"test_mode":         cfg.TestMode,
+		"languages":         i18n.Supported(), // preferred_language of users, also matched from Accept-Language
+		"social_login":      oauth.Enabled(),  // GET /api/auth/:provider
+		"uploads": gin.H{
+			"max_image_size":    cfg.MaxImageSize,
+			"max_video_size":    cfg.MaxVideoSize,
+			"max_document_size": cfg.MaxDocumentSize,
+		},
+	}}
+}
+
+// GetCapabilities returns the optional features enabled on this deployment. Public and cacheable.
+func (h *CapabilitiesHandler) GetCapabilities(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.capabilities)
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/certificate"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// How long before expiry holders are notified
+const certificateExpiryNoticeWindow = 30 * 24 * time.Hour
+
+type CertificateHandler struct {
+	DB *gorm.DB
+}
+
+func NewCertificateHandler(db *gorm.DB) *CertificateHandler {
+	return &CertificateHandler{DB: db}
+}
+
+// certificateTemplateInput is the request body for saving or previewing a template
+type certificateTemplateInput struct {
+	BackgroundImageURL string                           `json:"background_image_url"`
+	SignatureImageURL  string                           `json:"signature_image_url"`
+	Title              string                           `json:"title"`
+	Wording            string                           `json:"wording"`
+	SignatoryName      string                           `json:"signatory_name"`
+	SignatoryTitle     string                           `json:"signatory_title"`
+	Placements         map[string]certificate.Placement `json:"placements"`
+	IsPublished        bool                             `json:"is_published"`
+}
+
+func (in certificateTemplateInput) toTemplate() certificate.Template {
+	return certificate.Template{
+		BackgroundImageURL: in.BackgroundImageURL,
+		SignatureImageURL:  in.SignatureImageURL,
+		Title:              in.Title,
+		Wording:            in.Wording,
+		SignatoryName:      in.SignatoryName,
+		SignatoryTitle:     in.SignatoryTitle,
+		Placements:         in.Placements,
+	}
+}
+
+// GetCertificateTemplate returns the certificate template configured for a course
+func (h *CertificateHandler) GetCertificateTemplate(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	var tmpl models.CertificateTemplate
+	if err := db.Where("course_id = ?", course.ID).First(&tmpl).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusOK, gin.H{
+				"template":   nil,
+				"default":    certificate.DefaultTemplate(),
+				"is_default": true,
+			})
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Failed to fetch certificate template"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"template":   tmpl,
+		"is_default": false,
+	})
+}
+
+// SaveCertificateTemplate creates or updates the certificate template of a course
+func (h *CertificateHandler) SaveCertificateTemplate(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	var input certificateTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	if err := input.toTemplate().Validate(); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	placements, err := json.Marshal(input.Placements)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid placements"))
+		return
+	}
+
+	var tmpl models.CertificateTemplate
+	err = db.Where("course_id = ?", course.ID).First(&tmpl).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		apierror.Abort(c, apierror.Internal("Failed to fetch certificate template"))
+		return
+	}
+
+	tmpl.CourseID = course.ID
+	tmpl.BackgroundImageURL = input.BackgroundImageURL
+	tmpl.SignatureImageURL = input.SignatureImageURL
+	tmpl.Title = input.Title
+	tmpl.Wording = input.Wording
+	tmpl.SignatoryName = input.SignatoryName
+	tmpl.SignatoryTitle = input.SignatoryTitle
+	tmpl.Placements = models.JSON(placements)
+	tmpl.IsPublished = input.IsPublished
+
+	if err := db.Save(&tmpl).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save certificate template"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Certificate template saved successfully"),
+		"template": tmpl,
+	})
+}
+
+// PreviewCertificateTemplate renders a course certificate with sample data.
+// If a template is posted it is previewed as-is, otherwise the saved (possibly unpublished) template is used.
+func (h *CertificateHandler) PreviewCertificateTemplate(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	var tmpl certificate.Template
+	if c.Request.ContentLength > 0 {
+		var input certificateTemplateInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.Abort(c, apierror.Bind(err))
+			return
+		}
+		tmpl = input.toTemplate()
+	} else {
+		var saved models.CertificateTemplate
+		if err := db.Where("course_id = ?", course.ID).First(&saved).Error; err == nil {
+			tmpl = templateFromModel(saved)
+		} else {
+			tmpl = certificate.DefaultTemplate()
+		}
+	}
+
+	if err := tmpl.Validate(); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	var instructor models.User
+	db.Scopes(publicUserFields).First(&instructor, course.InstructorID)
+	data := certificate.SampleData(course.Title, instructor.FirstName+" "+instructor.LastName)
+	c.Data(http.StatusOK, "image/svg+xml", certificate.RenderSVG(tmpl, data))
+}
+
+// DownloadCertificate renders an issued certificate using the course's published template
+func (h *CertificateHandler) DownloadCertificate(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Abort(c, apierror.Unauthorized("User not authenticated"))
+		return
+	}
+
+	var cert models.Certificate
+	if err := db.Preload("Enrollment.User").Preload("Enrollment.Course.Instructor").
+		Where("id = ?", c.Param("id")).First(&cert).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Certificate not found"))
+		return
+	}
+
+	role, _ := c.Get("userRole")
+	if cert.UserID != userID.(uint) && role != "admin" {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to download this certificate"))
+		return
+	}
+
+	if cert.RevokedAt != nil {
+		apierror.Abort(c, apierror.New(http.StatusGone, "This certificate has been revoked"))
+		return
+	}
+
+	svg := renderCertificate(db, cert)
+	// The template's background and signature may be images of other hosts, such as S3
+	c.Header("Content-Security-Policy", "default-src 'none'; img-src 'self' data: https:; style-src 'unsafe-inline'")
+	c.Header("Content-Disposition", "inline; filename=\""+cert.ID+".svg\"")
+	c.Data(http.StatusOK, "image/svg+xml", svg)
+}
+
+// RevokeCertificate revokes an issued certificate with a reason (admin only)
+func (h *CertificateHandler) RevokeCertificate(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	adminID, exists := c.Get("userID")
+	if !exists {
+		apierror.Abort(c, apierror.Unauthorized("User not authenticated"))
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("A revocation reason is required"))
+		return
+	}
+
+	var cert models.Certificate
+	if err := db.Where("id = ?", c.Param("id")).First(&cert).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Certificate not found"))
+		return
+	}
+
+	if cert.RevokedAt != nil {
+		apierror.Abort(c, apierror.BadRequest("Certificate already revoked"))
+		return
+	}
+
+	now := time.Now()
+	revokedBy := adminID.(uint)
+	cert.RevokedAt = &now
+	cert.RevokedBy = &revokedBy
+	cert.RevocationReason = strings.TrimSpace(input.Reason)
+
+	if err := db.Save(&cert).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to revoke certificate"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     i18n.T(i18n.Locale(c), "Certificate revoked successfully"),
+		"certificate": cert,
+	})
+}
+
+// NotifyExpiringCertificates flags certificates expiring within the notice window and emails their holders.
+// Runs as a scheduled job.
+func (h *CertificateHandler) NotifyExpiringCertificates(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	now := clock.Now()
+
+	var certs []models.Certificate
+	if err := db.Preload("Enrollment.User").Preload("Enrollment.Course").
+		Where("revoked_at IS NULL AND expiry_notified_at IS NULL").
+		Where("expiry_date > ? AND expiry_date <= ?", now, now.Add(certificateExpiryNoticeWindow)).
+		Find(&certs).Error; err != nil {
+		return err
+	}
+
+	for _, cert := range certs {
+		if err := db.Model(&cert).Update("expiry_notified_at", now).Error; err != nil {
+			return err
+		}
+
+		user := cert.Enrollment.User
+		if err := email.SendCertificateExpiringEmail(user.Email, user.FirstName+" "+user.LastName,
+			cert.Enrollment.Course.Title, cert.ID, *cert.ExpiryDate); err != nil {
+			slog.Error("Failed to send certificate expiry email", "certificate_id", cert.ID, "error", err)
+		}
+	}
+
+	if len(certs) > 0 {
+		slog.Info("Notified holders of expiring certificates", "count", len(certs))
+	}
+	return nil
+}
+
+// renderCertificate renders an issued certificate with the course template, falling back to the default
+func renderCertificate(db *gorm.DB, cert models.Certificate) []byte {
+	tmpl := certificate.DefaultTemplate()
+
+	var saved models.CertificateTemplate
+	if err := db.Where("course_id = ? AND is_published = ?", cert.CourseID, true).First(&saved).Error; err == nil {
+		tmpl = templateFromModel(saved)
+	}
+
+	user := cert.Enrollment.User
+	course := cert.Enrollment.Course
+	data := certificate.Data{
+		CertificateID:    cert.ID,
+		StudentName:      user.FirstName + " " + user.LastName,
+		CourseTitle:      course.Title,
+		InstructorName:   course.Instructor.FirstName + " " + course.Instructor.LastName,
+		IssueDate:        cert.IssueDate,
+		VerificationCode: cert.VerificationCode,
+		Grade:            cert.Grade,
+	}
+	return certificate.RenderSVG(tmpl, data)
+}
+
+// templateFromModel converts a stored template into the renderer representation
+func templateFromModel(m models.CertificateTemplate) certificate.Template {
+	tmpl := certificate.Template{
+		BackgroundImageURL: m.BackgroundImageURL,
+		SignatureImageURL:  m.SignatureImageURL,
+		Title:              m.Title,
+		Wording:            m.Wording,
+		SignatoryName:      m.SignatoryName,
+		SignatoryTitle:     m.SignatoryTitle,
+	}
+	if len(m.Placements) > 0 {
+		json.Unmarshal(m.Placements, &tmpl.Placements)
+	}
+	return tmpl
+}
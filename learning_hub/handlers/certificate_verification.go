@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Employers send their key in this header to verify certificates with the key's limits
+const verificationKeyHeader = "X-API-Key"
+
+// Limits of POST /api/verify-certificates with and without an API key
+const (
+	anonymousVerifyBatch     = 10
+	anonymousVerifyPerMinute = 10
+	apiKeyVerifyBatch        = 100
+	defaultAPIKeyPerMinute   = 60
+)
+
+// Certificate statuses besides models.CertificateStatus* in bulk verification results
+const certificateStatusNotFound = "not_found"
+
+func hashVerificationKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type CertificateVerificationHandler struct {
+	DB *gorm.DB
+}
+
+func NewCertificateVerificationHandler(db *gorm.DB) *CertificateVerificationHandler {
+	return &CertificateVerificationHandler{DB: db}
+}
+
+// APIKeyAuth identifies the employer when an X-API-Key is sent. Anonymous requests pass through;
+// unknown or revoked keys are rejected rather than silently downgraded.
+func (h *CertificateVerificationHandler) APIKeyAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(verificationKeyHeader))
+		if key == "" {
+			c.Next()
+			return
+		}
+		var apiKey models.VerificationAPIKey
+		if err := h.DB.WithContext(c.Request.Context()).Where("key_hash = ? AND revoked_at IS NULL", hashVerificationKey(key)).
+			First(&apiKey).Error; err != nil {
+			apierror.Abort(c, apierror.Unauthorized("Invalid or revoked API key"))
+			return
+		}
+		c.Set("verificationKey", apiKey)
+		c.Next()
+	}
+}
+
+// VerificationRateLimitKey is the middleware.RateLimitBy client of certificate verification:
+// each API key has its own limit, anonymous callers share a small one per IP
+func VerificationRateLimitKey(c *gin.Context) (string, int) {
+	if value, ok := c.Get("verificationKey"); ok {
+		apiKey := value.(models.VerificationAPIKey)
+		return "key:" + strconv.FormatUint(uint64(apiKey.ID), 10), apiKey.RateLimit
+	}
+	return "ip:" + c.ClientIP(), anonymousVerifyPerMinute
+}
+
+// VerifyCertificates checks a batch of course or track certificate codes in one call and returns
+// each one's status in the order given: valid, expired, revoked or not_found
+func (h *CertificateVerificationHandler) VerifyCertificates(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Codes []string `json:"codes" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	value, keyed := c.Get("verificationKey")
+	maxCodes := anonymousVerifyBatch
+	if keyed {
+		maxCodes = apiKeyVerifyBatch
+	}
+	if len(input.Codes) > maxCodes {
+		apierror.Abort(c, apierror.BadRequest("Too many codes in one request").With("max_codes", maxCodes))
+		return
+	}
+
+	codes := make([]string, 0, len(input.Codes))
+	for _, code := range input.Codes {
+		codes = append(codes, strings.TrimSpace(code))
+	}
+
+	var certificates []models.Certificate
+	if err := db.Preload("Enrollment").Preload("Enrollment.User", publicUserFields).
+		Preload("Enrollment.Course", courseBriefFields).
+		Where("verification_code IN ?", codes).Find(&certificates).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to verify certificates"))
+		return
+	}
+	var trackEnrollments []models.TrackEnrollment
+	if err := db.Preload("Track", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("certificate_code IN ?", codes).Find(&trackEnrollments).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to verify certificates"))
+		return
+	}
+	trackHolders := make(map[uint]models.User)
+	if len(trackEnrollments) > 0 {
+		userIDs := make([]uint, len(trackEnrollments))
+		for i, enrollment := range trackEnrollments {
+			userIDs[i] = enrollment.UserID
+		}
+		var users []models.User
+		db.Select("id, first_name, last_name").Where("id IN ?", userIDs).Find(&users)
+		for _, user := range users {
+			trackHolders[user.ID] = user
+		}
+	}
+
+	now := clock.Now()
+	found := make(map[string]gin.H, len(certificates)+len(trackEnrollments))
+	for _, certificate := range certificates {
+		status := certificate.Status(now)
+		details := gin.H{
+			"type":         "course",
+			"student_name": certificate.Enrollment.User.FirstName + " " + certificate.Enrollment.User.LastName,
+			"course_title": certificate.Enrollment.Course.Title,
+			"issue_date":   certificate.IssueDate.Format("January 2, 2006"),
+		}
+		if certificate.Grade != "" {
+			details["grade"] = certificate.Grade
+		}
+		if certificate.ExpiryDate != nil {
+			details["expiry_date"] = certificate.ExpiryDate.Format("January 2, 2006")
+		}
+		if certificate.RevokedAt != nil {
+			details["revoked_at"] = certificate.RevokedAt.Format("January 2, 2006")
+		}
+		found[certificate.VerificationCode] = gin.H{
+			"code":        certificate.VerificationCode,
+			"valid":       status == models.CertificateStatusValid,
+			"status":      status,
+			"certificate": details,
+		}
+	}
+	for _, enrollment := range trackEnrollments {
+		holder := trackHolders[enrollment.UserID]
+		found[*enrollment.CertificateCode] = gin.H{
+			"code":   *enrollment.CertificateCode,
+			"valid":  true,
+			"status": models.CertificateStatusValid,
+			"certificate": gin.H{
+				"type":         "track",
+				"student_name": holder.FirstName + " " + holder.LastName,
+				"track_title":  enrollment.Track.Title,
+				"issue_date":   enrollment.CertificateIssuedAt.Format("January 2, 2006"),
+			},
+		}
+	}
+
+	results := make([]gin.H, len(codes))
+	summary := map[string]int{}
+	for i, code := range codes {
+		result, ok := found[code]
+		if !ok {
+			result = gin.H{"code": code, "valid": false, "status": certificateStatusNotFound}
+		}
+		results[i] = result
+		summary[result["status"].(string)]++
+	}
+
+	if keyed {
+		apiKey := value.(models.VerificationAPIKey)
+		db.Model(&apiKey).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"requests":     gorm.Expr("requests + 1"),
+			"certificates": gorm.Expr("certificates + ?", len(codes)),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"summary": summary,
+	})
+}
+
+// GetVerificationKeys lists employer API keys, newest first
+func (h *CertificateVerificationHandler) GetVerificationKeys(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var keys []models.VerificationAPIKey
+	if err := db.Order("created_at DESC").Find(&keys).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch API keys"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// CreateVerificationKey issues an employer API key. The key is only returned in this response.
+func (h *CertificateVerificationHandler) CreateVerificationKey(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Name         string `json:"name" binding:"required,max=200"`
+		ContactEmail string `json:"contact_email" binding:"omitempty,email"`
+		RateLimit    int    `json:"rate_limit" binding:"omitempty,min=1,max=6000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if input.RateLimit == 0 {
+		input.RateLimit = defaultAPIKeyPerMinute
+	}
+
+	key := "lhv_" + idgen.String(40)
+	apiKey := models.VerificationAPIKey{
+		Name:         strings.TrimSpace(input.Name),
+		ContactEmail: input.ContactEmail,
+		Prefix:       key[:12],
+		KeyHash:      hashVerificationKey(key),
+		RateLimit:    input.RateLimit,
+		CreatedByID:  c.MustGet("userID").(uint),
+	}
+	if err := db.Create(&apiKey).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create API key"))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(i18n.Locale(c), "API key created. Store it now, it won't be shown again."),
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+// UpdateVerificationKey renames a key or changes its rate limit
+func (h *CertificateVerificationHandler) UpdateVerificationKey(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Name         *string `json:"name" binding:"omitempty,min=1,max=200"`
+		ContactEmail *string `json:"contact_email" binding:"omitempty,email"`
+		RateLimit    *int    `json:"rate_limit" binding:"omitempty,min=1,max=6000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	var apiKey models.VerificationAPIKey
+	if err := db.First(&apiKey, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("API key not found"))
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Name != nil {
+		updates["name"] = strings.TrimSpace(*input.Name)
+	}
+	if input.ContactEmail != nil {
+		updates["contact_email"] = *input.ContactEmail
+	}
+	if input.RateLimit != nil {
+		updates["rate_limit"] = *input.RateLimit
+	}
+	if len(updates) > 0 {
+		if err := db.Model(&apiKey).Updates(updates).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to update API key"))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "API key updated"),
+		"api_key": apiKey,
+	})
+}
+
+// RevokeVerificationKey stops a key from working. Revoked keys are kept for their usage history.
+func (h *CertificateVerificationHandler) RevokeVerificationKey(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var apiKey models.VerificationAPIKey
+	if err := db.First(&apiKey, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("API key not found"))
+		return
+	}
+	if apiKey.RevokedAt == nil {
+		if err := db.Model(&apiKey).Update("revoked_at", clock.Now()).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to revoke API key"))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "API key revoked")})
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/chapa"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/settings"
+	"learning_hub/pkg/stripe"
+	"learning_hub/pkg/validation"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// pendingPaymentTTL is how long a started checkout can be resumed; reconciliation fails older unpaid ones
+const pendingPaymentTTL = time.Hour
+
+// maxIdempotencyKeyLength is the longest Idempotency-Key header accepted
+const maxIdempotencyKeyLength = 100
+
+// errIdempotencyKeyReused is returned when an idempotency key comes back with a different purchase
+var errIdempotencyKeyReused = errors.New("this Idempotency-Key was already used for another payment")
+
+// Payment providers
+const (
+	providerChapa  = "chapa"
+	providerStripe = "stripe"
+)
+
+// checkoutProviders routes the currencies payments can be taken in to their provider: Chapa takes birr,
+// Stripe takes cards in dollars and euros
+var checkoutProviders = map[string]string{
+	"ETB": providerChapa,
+	"USD": providerStripe,
+	"EUR": providerStripe,
+}
+
+// paymentCurrencies lists the currencies payments can be taken in
+func paymentCurrencies() []string {
+	codes := make([]string, 0, len(checkoutProviders))
+	for code := range checkoutProviders {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// checkPaymentCurrency normalizes a currency code and checks payments can be taken in it
+func checkPaymentCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := checkoutProviders[code]; !ok {
+		return "", fmt.Errorf("currency must be one of %s", strings.Join(paymentCurrencies(), ", "))
+	}
+	return code, nil
+}
+
+// paymentCurrencyProblem is the answer to a field holding a currency payments can't be taken in
+func paymentCurrencyProblem(field string) validation.Problem {
+	return validation.Problem{Field: field, Rule: "oneof", Param: strings.Join(paymentCurrencies(), " ")}
+}
+
+// courseCurrency returns the currency of a course's price
+func courseCurrency(course models.Course) string {
+	if course.Currency != "" {
+		return course.Currency
+	}
+	return settings.DefaultCurrency()
+}
+
+// checkout is a payment to start with the provider of its currency
+type checkout struct {
+	Payment     *models.Payment // its amount, currency and reference; Provider and ProviderRef are set
+	User        models.User
+	Title       string // what is bought, for the payment page
+	Description string
+	Meta        map[string]interface{}
+}
+
+// startCheckout starts the payment with the provider of its currency and returns the page to send the
+// buyer to. The payment isn't saved.
+func startCheckout(ctx context.Context, co checkout) (string, error) {
+	payment := co.Payment
+	switch checkoutProviders[payment.Currency] {
+	case providerChapa:
+		payment.Provider = providerChapa
+		resp, err := chapa.InitializePayment(ctx, &chapa.PaymentRequest{
+			Amount:      fmt.Sprintf("%.2f", payment.Amount),
+			Currency:    payment.Currency,
+			Email:       co.User.Email,
+			FirstName:   co.User.FirstName,
+			LastName:    co.User.LastName,
+			PhoneNumber: co.User.Phone,
+			TxRef:       payment.ChapaTxRef,
+			CallbackURL: chapaCallbackURL,
+			ReturnURL:   paymentReturnURL,
+			Customization: chapa.Customization{
+				Title:       "LearnHub", // Chapa allows 16 characters
+				Description: co.Description,
+			},
+			Meta: co.Meta,
+		})
+		if err != nil {
+			return "", err
+		}
+		payment.CheckoutURL = resp.Data.CheckoutURL
+		return payment.CheckoutURL, nil
+
+	case providerStripe:
+		returnURL := func(status string) string {
+			return paymentReturnURL + "?" + url.Values{"tx_ref": {payment.ChapaTxRef}, "status": {status}}.Encode()
+		}
+		session, err := stripe.CreateCheckoutSession(ctx, stripe.CheckoutRequest{
+			Amount:      payment.Amount,
+			Currency:    payment.Currency,
+			Name:        co.Title,
+			Description: co.Description,
+			Email:       co.User.Email,
+			Reference:   payment.ChapaTxRef,
+			SuccessURL:  returnURL("success"),
+			CancelURL:   returnURL("cancelled"),
+			ExpiresAt:   clock.Now().Add(pendingPaymentTTL),
+		})
+		if err != nil {
+			return "", err
+		}
+		payment.Provider, payment.ProviderRef, payment.CheckoutURL = providerStripe, session.ID, session.URL
+		return session.URL, nil
+	}
+	return "", fmt.Errorf("payments in %s are not supported", payment.Currency)
+}
+
+// idempotencyKey returns the Idempotency-Key header of a payment request, answering 400 when it is too long
+func idempotencyKey(c *gin.Context) (*string, bool) {
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if key == "" {
+		return nil, true
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		apierror.Abort(c, apierror.BadRequest(fmt.Sprintf("Idempotency-Key can be at most %d characters", maxIdempotencyKeyLength)))
+		return nil, false
+	}
+	return &key, true
+}
+
+// resumableCheckout finds a payment the buyer already started for the purchase described by want: the one
+// sent with the same idempotency key, else one still pending from the last pendingPaymentTTL at the same
+// amount and currency. It returns nil when a new checkout is needed.
+func resumableCheckout(db *gorm.DB, want models.Payment) (*models.Payment, error) {
+	var payment models.Payment
+	if want.IdempotencyKey != nil {
+		err := db.Where("user_id = ? AND idempotency_key = ?", want.UserID, *want.IdempotencyKey).First(&payment).Error
+		if err == nil {
+			if !samePurchase(payment, want) {
+				return nil, errIdempotencyKeyReused
+			}
+			return &payment, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	query := db.Where("user_id = ? AND course_id = ? AND status = ? AND created_at > ? AND checkout_url <> ''",
+		want.UserID, want.CourseID, models.PaymentStatusPending, clock.Now().Add(-pendingPaymentTTL)).
+		Where("amount = ? AND currency = ?", want.Amount, want.Currency)
+	if want.TrackID != nil {
+		query = query.Where("track_id = ?", *want.TrackID)
+	} else {
+		query = query.Where("track_id IS NULL")
+	}
+	if want.OrganizationID != nil {
+		query = query.Where("organization_id = ? AND seats = ?", *want.OrganizationID, want.Seats)
+	} else {
+		query = query.Where("organization_id IS NULL")
+	}
+	err := query.Order("created_at DESC").First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// samePurchase reports whether two payments buy the same thing
+func samePurchase(a, b models.Payment) bool {
+	sameID := func(x, y *uint) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && *x == *y)
+	}
+	return a.CourseID == b.CourseID && sameID(a.TrackID, b.TrackID) &&
+		sameID(a.OrganizationID, b.OrganizationID) && a.Seats == b.Seats
+}
+
+// resumeCheckout looks for a checkout to resume before a new one is started. It answers the request and
+// returns true when it found one or failed.
+func resumeCheckout(c *gin.Context, db *gorm.DB, want models.Payment) bool {
+	payment, err := resumableCheckout(db, want)
+	if errors.Is(err, errIdempotencyKeyReused) {
+		apierror.Abort(c, apierror.New(http.StatusUnprocessableEntity, err.Error()))
+		return true
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to look up earlier payments"))
+		return true
+	}
+	if payment == nil {
+		return false
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":         i18n.T(i18n.Locale(c), "Payment already started, continue it at the checkout URL"),
+		"checkout_url":    payment.CheckoutURL,
+		"transaction_ref": payment.ChapaTxRef,
+		"payment_id":      payment.ID,
+		"provider":        payment.Provider,
+		"status":          payment.Status,
+		"resumed":         true,
+	})
+	return true
+}
+
+// createPayment saves a payment whose checkout was just started. When a concurrent request with the same
+// idempotency key won the race, that request's payment is answered instead and false returned.
+func createPayment(c *gin.Context, db *gorm.DB, payment *models.Payment) bool {
+	err := db.Create(payment).Error
+	if err == nil {
+		return true
+	}
+	if payment.IdempotencyKey != nil && resumeCheckout(c, db, *payment) {
+		return false
+	}
+	apierror.Abort(c, apierror.Internal("Failed to create payment record"))
+	return false
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CohortHandler struct {
+	DB *gorm.DB
+}
+
+func NewCohortHandler(db *gorm.DB) *CohortHandler {
+	return &CohortHandler{DB: db}
+}
+
+type cohortInput struct {
+	Name     string    `json:"name" binding:"required,max=100"`
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+	Changes  string    `json:"changes"`
+}
+
+// cohortOutcomes are the results of one cohort's students
+type cohortOutcomes struct {
+	Students              int      `json:"students"`
+	Completed             int      `json:"completed"`
+	CompletionRate        float64  `json:"completion_rate"`
+	AverageProgress       float64  `json:"average_progress"`
+	AverageDaysToComplete *float64 `json:"average_days_to_complete"`
+	Graded                int      `json:"graded"`
+	AverageGrade          *float64 `json:"average_grade"`
+	PassRate              *float64 `json:"pass_rate"` // of graded students
+	AverageMinutesSpent   float64  `json:"average_minutes_spent"`
+	AverageLessonsDone    float64  `json:"average_lessons_completed"`
+	ForumPostsPerStudent  float64  `json:"forum_posts_per_student"`
+	AverageRating         *float64 `json:"average_rating"`
+	Reviews               int64    `json:"reviews"`
+}
+
+// bindCohort validates a cohort's window against the course's other cohorts, which it may not overlap
+func (h *CohortHandler) bindCohort(c *gin.Context, courseID, cohortID uint) (cohortInput, bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input cohortInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return input, false
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if !input.EndsAt.After(input.StartsAt) {
+		apierror.Abort(c, apierror.BadRequest("ends_at must be after starts_at"))
+		return input, false
+	}
+
+	var overlapping models.CourseCohort
+	err := db.Where("course_id = ? AND id <> ? AND starts_at < ? AND ends_at > ?", courseID, cohortID, input.EndsAt, input.StartsAt).
+		First(&overlapping).Error
+	if err == nil {
+		apierror.Abort(c, apierror.Conflict("Cohorts cannot overlap, this one overlaps \""+overlapping.Name+"\""))
+		return input, false
+	}
+	return input, true
+}
+
+// GetCohorts lists a course's cohorts in order
+func (h *CohortHandler) GetCohorts(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadTaughtCourse(c, db)
+	if !ok {
+		return
+	}
+
+	var cohorts []models.CourseCohort
+	if err := db.Where("course_id = ?", course.ID).Order("starts_at").Find(&cohorts).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch cohorts"))
+		return
+	}
+	c.JSON(http.StatusOK, cohorts)
+}
+
+// CreateCohort adds a run of a course
+func (h *CohortHandler) CreateCohort(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+	input, ok := h.bindCohort(c, course.ID, 0)
+	if !ok {
+		return
+	}
+
+	now := clock.Now()
+	cohort := models.CourseCohort{
+		CourseID:    course.ID,
+		Name:        input.Name,
+		StartsAt:    input.StartsAt,
+		EndsAt:      input.EndsAt,
+		Changes:     input.Changes,
+		CreatedByID: c.MustGet("userID").(uint),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := db.Create(&cohort).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create cohort"))
+		return
+	}
+	c.JSON(http.StatusCreated, cohort)
+}
+
+// UpdateCohort changes a cohort's name, window or notes
+func (h *CohortHandler) UpdateCohort(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+	var cohort models.CourseCohort
+	if err := db.Where("course_id = ?", course.ID).First(&cohort, c.Param("cohortId")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Cohort not found"))
+		return
+	}
+	input, ok := h.bindCohort(c, course.ID, cohort.ID)
+	if !ok {
+		return
+	}
+
+	cohort.Name, cohort.StartsAt, cohort.EndsAt, cohort.Changes = input.Name, input.StartsAt, input.EndsAt, input.Changes
+	cohort.UpdatedAt = clock.Now()
+	if err := db.Save(&cohort).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update cohort"))
+		return
+	}
+	c.JSON(http.StatusOK, cohort)
+}
+
+// DeleteCohort removes a cohort. Its students are simply no longer grouped.
+func (h *CohortHandler) DeleteCohort(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+	result := db.Where("course_id = ?", course.ID).Delete(&models.CourseCohort{}, c.Param("cohortId"))
+	if result.Error != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete cohort"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Abort(c, apierror.NotFound("Cohort not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Cohort deleted")})
+}
+
+// cohortOutcomesFor measures the outcomes of a group of enrollments
+func cohortOutcomesFor(db *gorm.DB, courseID uint, enrollments []models.Enrollment) (cohortOutcomes, error) {
+	out := cohortOutcomes{Students: len(enrollments)}
+	if len(enrollments) == 0 {
+		return out, nil
+	}
+
+	userIDs := make([]uint, len(enrollments))
+	var progress, daysToComplete float64
+	for i, e := range enrollments {
+		userIDs[i] = e.UserID
+		progress += e.Progress
+		if e.CompletedAt != nil {
+			out.Completed++
+			daysToComplete += e.CompletedAt.Sub(e.EnrolledAt).Hours() / 24
+		}
+	}
+	out.CompletionRate = percentOf(out.Completed, out.Students)
+	out.AverageProgress = progress / float64(out.Students)
+	if out.Completed > 0 {
+		days := daysToComplete / float64(out.Completed)
+		out.AverageDaysToComplete = &days
+	}
+
+	grades, err := courseGrades(db, courseID, userIDs)
+	if err != nil {
+		return out, err
+	}
+	if len(grades) > 0 {
+		scale, _ := effectiveScale(db, courseID)
+		var total float64
+		passed := 0
+		for _, percent := range grades {
+			total += percent
+			if scale.Passed(percent) {
+				passed++
+			}
+		}
+		average := total / float64(len(grades))
+		passRate := percentOf(passed, len(grades))
+		out.Graded, out.AverageGrade, out.PassRate = len(grades), &average, &passRate
+	}
+
+	var engagement struct {
+		Minutes int64
+		Lessons int64
+	}
+	if err := db.Model(&models.LessonProgress{}).
+		Select("COALESCE(SUM(time_spent), 0) AS minutes, COUNT(*) FILTER (WHERE completed) AS lessons").
+		Where("course_id = ? AND user_id IN ?", courseID, userIDs).
+		Scan(&engagement).Error; err != nil {
+		return out, err
+	}
+	out.AverageMinutesSpent = float64(engagement.Minutes) / float64(out.Students)
+	out.AverageLessonsDone = float64(engagement.Lessons) / float64(out.Students)
+
+	var threads, posts int64
+	db.Model(&models.ForumThread{}).Where("course_id = ? AND user_id IN ? AND status = ?", courseID, userIDs, models.ContentPublished).
+		Count(&threads)
+	db.Model(&models.ForumPost{}).
+		Joins("JOIN forum_threads ON forum_threads.id = forum_posts.thread_id").
+		Where("forum_threads.course_id = ? AND forum_posts.user_id IN ? AND forum_posts.status = ?", courseID, userIDs, models.ContentPublished).
+		Count(&posts)
+	out.ForumPostsPerStudent = float64(threads+posts) / float64(out.Students)
+
+	var rating struct {
+		Average *float64
+		Count   int64
+	}
+	if err := db.Model(&models.Review{}).Select("AVG(rating) AS average, COUNT(*) AS count").
+		Where("course_id = ? AND user_id IN ? AND status = ?", courseID, userIDs, models.ContentPublished).
+		Scan(&rating).Error; err != nil {
+		return out, err
+	}
+	out.AverageRating, out.Reviews = rating.Average, rating.Count
+	return out, nil
+}
+
+// difference returns b - a when both are known
+func difference(a, b *float64) *float64 {
+	if a == nil || b == nil {
+		return nil
+	}
+	d := *b - *a
+	return &d
+}
+
+// CompareCohorts measures completion, grades and engagement per cohort, with the change from the
+// previous cohort, so instructors can see whether content changes improved outcomes
+func (h *CohortHandler) CompareCohorts(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadTaughtCourse(c, db)
+	if !ok {
+		return
+	}
+
+	var cohorts []models.CourseCohort
+	if err := db.Where("course_id = ?", course.ID).Order("starts_at").Find(&cohorts).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch cohorts"))
+		return
+	}
+	var enrollments []models.Enrollment
+	if err := db.Select("id, user_id, progress, completed_at, enrolled_at").Scopes(withoutTestStudents).
+		Where("course_id = ?", course.ID).Find(&enrollments).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch enrollments"))
+		return
+	}
+
+	members := make([][]models.Enrollment, len(cohorts))
+	unassigned := 0
+	for _, e := range enrollments {
+		found := false
+		for i, cohort := range cohorts {
+			if !e.EnrolledAt.Before(cohort.StartsAt) && e.EnrolledAt.Before(cohort.EndsAt) {
+				members[i] = append(members[i], e)
+				found = true
+				break
+			}
+		}
+		if !found {
+			unassigned++
+		}
+	}
+
+	results := make([]gin.H, len(cohorts))
+	var previous *cohortOutcomes
+	for i, cohort := range cohorts {
+		outcomes, err := cohortOutcomesFor(db, course.ID, members[i])
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to measure cohort outcomes"))
+			return
+		}
+
+		result := gin.H{
+			"cohort":   cohort,
+			"outcomes": outcomes,
+		}
+		// Changes are only meaningful between cohorts that both have students
+		if previous != nil && previous.Students > 0 && outcomes.Students > 0 {
+			completion, prevCompletion := outcomes.CompletionRate, previous.CompletionRate
+			minutes, prevMinutes := outcomes.AverageMinutesSpent, previous.AverageMinutesSpent
+			result["change_from_previous"] = gin.H{
+				"completion_rate":       difference(&prevCompletion, &completion),
+				"average_grade":         difference(previous.AverageGrade, outcomes.AverageGrade),
+				"pass_rate":             difference(previous.PassRate, outcomes.PassRate),
+				"average_minutes_spent": difference(&prevMinutes, &minutes),
+				"average_rating":        difference(previous.AverageRating, outcomes.AverageRating),
+			}
+		}
+		results[i] = result
+		if outcomes.Students > 0 {
+			previous = &outcomes
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"course_id":           course.ID,
+		"cohorts":             results,
+		"unassigned_students": unassigned,
+	})
+}
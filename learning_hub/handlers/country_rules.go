@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/geo"
+	"learning_hub/pkg/i18n"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// requestCountry returns the country of the signed-in user's profile, falling back to the country
+// of the request's IP address. "" means the country is unknown.
+func requestCountry(c *gin.Context, db *gorm.DB) string {
+	if userID, exists := c.Get("userID"); exists {
+		var user models.User
+		if err := db.Select("id, country").First(&user, userID).Error; err == nil && user.Country != "" {
+			return user.Country
+		}
+	}
+	return geo.FromRequest(c.Request)
+}
+
+// availableInCountry restricts a course query to courses offered in a country. Courses with allow
+// rules are hidden when the country is unknown.
+func availableInCountry(query *gorm.DB, country string) *gorm.DB {
+	return query.
+		Where("NOT EXISTS (SELECT 1 FROM course_country_rules r WHERE r.course_id = courses.id AND r.country = ? AND r.action = ?)",
+			country, models.CountryRuleBlock).
+		Where("(NOT EXISTS (SELECT 1 FROM course_country_rules r WHERE r.course_id = courses.id AND r.action = ?)"+
+			" OR EXISTS (SELECT 1 FROM course_country_rules r WHERE r.course_id = courses.id AND r.country = ? AND r.action = ?))",
+			models.CountryRuleAllow, country, models.CountryRuleAllow)
+}
+
+// coursePriceIn returns what a course costs in a country and whether it is offered there at all
+func coursePriceIn(db *gorm.DB, course models.Course, country string) (float64, bool, error) {
+	var rules []models.CourseCountryRule
+	if err := db.Where("course_id = ? AND (country = ? OR action = ?)", course.ID, country, models.CountryRuleAllow).
+		Find(&rules).Error; err != nil {
+		return 0, false, err
+	}
+
+	price := course.Price
+	allowListed, allowed := false, false
+	for _, rule := range rules {
+		if rule.Action == models.CountryRuleAllow {
+			allowListed = true
+		}
+		if rule.Country != country {
+			continue
+		}
+		switch rule.Action {
+		case models.CountryRuleBlock:
+			return 0, false, nil
+		case models.CountryRuleAllow:
+			allowed = true
+		}
+		if rule.Price != nil {
+			price = *rule.Price
+		}
+	}
+	if allowListed && !allowed {
+		return 0, false, nil
+	}
+	return price, true, nil
+}
+
+// applyCountryPrices replaces the prices of listed courses with their price in a country
+func applyCountryPrices(db *gorm.DB, courses []models.Course, country string) error {
+	if country == "" || len(courses) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(courses))
+	for i, course := range courses {
+		ids[i] = course.ID
+	}
+
+	var rules []models.CourseCountryRule
+	if err := db.Where("course_id IN ? AND country = ? AND price IS NOT NULL", ids, country).Find(&rules).Error; err != nil {
+		return err
+	}
+	prices := make(map[uint]float64, len(rules))
+	for _, rule := range rules {
+		prices[rule.CourseID] = *rule.Price
+	}
+	for i := range courses {
+		if price, ok := prices[courses[i].ID]; ok {
+			courses[i].Price = price
+		}
+	}
+	return nil
+}
+
+type CountryRuleHandler struct {
+	DB *gorm.DB
+}
+
+func NewCountryRuleHandler(db *gorm.DB) *CountryRuleHandler {
+	return &CountryRuleHandler{DB: db}
+}
+
+// GetCountryRules lists country rules, optionally filtered by ?course_id= and ?country=
+func (h *CountryRuleHandler) GetCountryRules(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	query := db.Preload("Course", func(db *gorm.DB) *gorm.DB { return db.Unscoped().Select("id, title, price") })
+	if courseID := c.Query("course_id"); courseID != "" {
+		id, err := strconv.ParseUint(courseID, 10, 64)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("Invalid course_id"))
+			return
+		}
+		query = query.Where("course_id = ?", id)
+	}
+	if value := c.Query("country"); value != "" {
+		country, ok := geo.Normalize(value)
+		if !ok {
+			apierror.Abort(c, apierror.BadRequest("country must be an ISO 3166-1 alpha-2 code"))
+			return
+		}
+		query = query.Where("country = ?", country)
+	}
+
+	var rules []models.CourseCountryRule
+	if err := query.Order("course_id, country").Find(&rules).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch country rules"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// SaveCountryRule creates or replaces the rule of a course for one country
+func (h *CountryRuleHandler) SaveCountryRule(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	country, ok := geo.Normalize(c.Param("country"))
+	if !ok {
+		apierror.Abort(c, apierror.BadRequest("country must be an ISO 3166-1 alpha-2 code"))
+		return
+	}
+
+	var input struct {
+		Action string   `json:"action" binding:"required,oneof=block allow price"`
+		Price  *float64 `json:"price" binding:"omitempty,min=0"`
+		Note   string   `json:"note" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if input.Action == models.CountryRuleBlock && input.Price != nil {
+		apierror.Abort(c, apierror.BadRequest("A block rule cannot have a price"))
+		return
+	}
+	if input.Action == models.CountryRulePrice && input.Price == nil {
+		apierror.Abort(c, apierror.BadRequest("A price rule needs a price"))
+		return
+	}
+
+	adminID, _ := c.Get("userID")
+	now := clock.Now()
+	var rule models.CourseCountryRule
+	err := db.Where("course_id = ? AND country = ?", course.ID, country).First(&rule).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Abort(c, apierror.Internal("Failed to fetch country rule"))
+		return
+	}
+	if rule.ID == 0 {
+		rule = models.CourseCountryRule{CourseID: course.ID, Country: country, CreatedAt: now}
+	}
+	rule.Action, rule.Price, rule.Note = input.Action, input.Price, input.Note
+	rule.CreatedByID, rule.UpdatedAt = adminID.(uint), now
+
+	if err := db.Omit("Course").Save(&rule).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save country rule"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Country rule saved"),
+		"rule":    rule,
+	})
+}
+
+// DeleteCountryRule removes the rule of a course for one country
+func (h *CountryRuleHandler) DeleteCountryRule(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	country, ok := geo.Normalize(c.Param("country"))
+	if !ok {
+		apierror.Abort(c, apierror.BadRequest("country must be an ISO 3166-1 alpha-2 code"))
+		return
+	}
+
+	result := db.Where("course_id = ? AND country = ?", c.Param("id"), country).Delete(&models.CourseCountryRule{})
+	if result.Error != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete country rule"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Abort(c, apierror.NotFound("Country rule not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Country rule deleted")})
+}
@@ -0,0 +1,18 @@
+package handlers
+
+// Cache groups of the course reads: the catalogue by country and filters, and each course with its
+// modules and lessons
+const (
+	courseListCache = "course-list"
+	courseCache     = "course"
+)
+
+// CacheInvalidations names the cache groups read from each table, which the dbcache plugin invalidates
+// when the table is written. Instructor names and emails are read from users, which changes too often
+// to invalidate on; they are as stale as the TTL at most.
+var CacheInvalidations = map[string][]string{
+	"courses":              {courseListCache, courseCache},
+	"modules":              {courseCache},
+	"lessons":              {courseCache},
+	"course_country_rules": {courseListCache},
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/i18n"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// courseContent is what instructors author in a course, as copied by CloneCourse
+type courseContent struct {
+	Modules     []models.Module // with their lessons, each with its language variants and blocks
+	Quizzes     []models.Quiz   // with their questions
+	Assignments []models.Assignment
+}
+
+// loadCourseContent loads the course's content in order
+func loadCourseContent(db *gorm.DB, courseID uint) (courseContent, error) {
+	var content courseContent
+	if err := db.Preload("Lessons", func(db *gorm.DB) *gorm.DB {
+		return db.Order("order_index, id")
+	}).Preload("Lessons.Variants").Preload("Lessons.Blocks", func(db *gorm.DB) *gorm.DB {
+		return db.Order("order_index, id")
+	}).Where("course_id = ?", courseID).Order("order_index, id").Find(&content.Modules).Error; err != nil {
+		return content, err
+	}
+	if err := db.Preload("Questions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("order_index, id")
+	}).Where("course_id = ?", courseID).Order("id").Find(&content.Quizzes).Error; err != nil {
+		return content, err
+	}
+	err := db.Where("course_id = ?", courseID).Order("id").Find(&content.Assignments).Error
+	return content, err
+}
+
+// shiftTime moves an optional date by shift
+func shiftTime(t *time.Time, shift time.Duration) *time.Time {
+	if t == nil {
+		return nil
+	}
+	shifted := t.Add(shift)
+	return &shifted
+}
+
+// createCourseContent adds a copy of the content to the course. Quizzes and assignments stay in the copies
+// of their modules and lessons, and quiz blocks show the copies of their quizzes; dates move by shift. It
+// returns the IDs of the new lessons by the IDs of the ones they copy.
+func createCourseContent(tx *gorm.DB, courseID uint, content courseContent, shift time.Duration) (map[uint]uint, error) {
+	tx = tx.Omit(clause.Associations)
+	moduleIDs := map[uint]uint{}
+	lessonIDs := map[uint]uint{}
+	quizIDs := map[uint]uint{}
+	var blocks []models.LessonBlock // created once the quizzes they show exist
+	for _, source := range content.Modules {
+		module := source
+		module.Model, module.CourseID, module.Course, module.Lessons = gorm.Model{}, courseID, models.Course{}, nil
+		if err := tx.Create(&module).Error; err != nil {
+			return nil, err
+		}
+		moduleIDs[source.ID] = module.ID
+
+		for _, sourceLesson := range source.Lessons {
+			lesson := sourceLesson
+			lesson.Model, lesson.ModuleID, lesson.Module, lesson.Variants = gorm.Model{}, module.ID, models.Module{}, nil
+			lesson.Blocks = nil
+			if err := tx.Create(&lesson).Error; err != nil {
+				return nil, err
+			}
+			lessonIDs[sourceLesson.ID] = lesson.ID
+			for _, block := range sourceLesson.Blocks {
+				block.Model, block.LessonID, block.Quiz = gorm.Model{}, lesson.ID, nil
+				blocks = append(blocks, block)
+			}
+			for _, sourceVariant := range sourceLesson.Variants {
+				variant := sourceVariant
+				variant.Model, variant.LessonID = gorm.Model{}, lesson.ID
+				if err := tx.Create(&variant).Error; err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// Placements in modules or lessons that aren't copied (e.g. in the trash) are dropped
+	place := func(ids map[uint]uint, id *uint) *uint {
+		if id == nil {
+			return nil
+		}
+		if mapped, ok := ids[*id]; ok {
+			return &mapped
+		}
+		return nil
+	}
+	for _, source := range content.Quizzes {
+		quiz := source
+		quiz.Model, quiz.CourseID, quiz.Course, quiz.Questions = gorm.Model{}, courseID, models.Course{}, nil
+		quiz.ModuleID, quiz.Module = place(moduleIDs, source.ModuleID), nil
+		quiz.LessonID, quiz.Lesson = place(lessonIDs, source.LessonID), nil
+		quiz.OpensAt, quiz.ClosesAt = shiftTime(source.OpensAt, shift), shiftTime(source.ClosesAt, shift)
+		if err := tx.Create(&quiz).Error; err != nil {
+			return nil, err
+		}
+		if source.ID != 0 {
+			quizIDs[source.ID] = quiz.ID
+		}
+		for _, sourceQuestion := range source.Questions {
+			question := sourceQuestion
+			question.Model, question.QuizID = gorm.Model{}, quiz.ID
+			if err := tx.Create(&question).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, source := range content.Assignments {
+		assignment := source
+		assignment.Model, assignment.CourseID, assignment.Course = gorm.Model{}, courseID, models.Course{}
+		assignment.ModuleID, assignment.Module, assignment.Submissions = place(moduleIDs, source.ModuleID), nil, nil
+		if !assignment.DueDate.IsZero() {
+			assignment.DueDate = assignment.DueDate.Add(shift)
+		}
+		if err := tx.Create(&assignment).Error; err != nil {
+			return nil, err
+		}
+	}
+	for _, block := range blocks {
+		if block.QuizID != nil {
+			if block.QuizID = place(quizIDs, block.QuizID); block.QuizID == nil {
+				continue
+			}
+		}
+		if err := tx.Create(&block).Error; err != nil {
+			return nil, err
+		}
+	}
+	return lessonIDs, nil
+}
+
+// CloneCourse copies a course the caller manages into a new draft they own ({"title", "shift_days"}, both
+// optional), e.g. to run it again next term: its modules, lessons with their language variants and blocks,
+// quizzes with their questions and assignments. Quiz windows and due dates move by shift_days. Students,
+// progress, submissions, reviews and discussions stay with the original.
+func (h *CourseHandler) CloneCourse(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Title     string `json:"title" binding:"max=200"`
+		ShiftDays int    `json:"shift_days" binding:"min=-3650,max=3650"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.Abort(c, apierror.Bind(err))
+			return
+		}
+	}
+	var source models.Course
+	if err := db.First(&source, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	if !canManageCourse(c, db, source) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to copy this course"))
+		return
+	}
+	content, err := loadCourseContent(db, source.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to load the course content"))
+		return
+	}
+
+	course := models.Course{
+		Title:        input.Title,
+		Description:  source.Description,
+		Price:        source.Price,
+		Currency:     source.Currency,
+		Category:     source.Category,
+		Level:        source.Level,
+		ImageURL:     source.ImageURL,
+		ThumbnailURL: source.ThumbnailURL,
+		ImageAlt:     source.ImageAlt,
+		Published:    false,
+		InstructorID: c.MustGet("userID").(uint),
+	}
+	if course.Title == "" {
+		course.Title = truncateRunes(source.Title+" (copy)", 200)
+	}
+	shift := time.Duration(input.ShiftDays) * 24 * time.Hour
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&course).Error; err != nil {
+			return err
+		}
+		lessonIDs, err := createCourseContent(tx, course.ID, content, shift)
+		if err != nil {
+			return err
+		}
+
+		// Uploaded videos and SCORM packages are shared, so their renditions and unpacked files serve the
+		// copies as well
+		var transcodes []models.VideoTranscode
+		var packages []models.ScormPackage
+		if len(lessonIDs) > 0 {
+			sourceLessons := make([]uint, 0, len(lessonIDs))
+			for id := range lessonIDs {
+				sourceLessons = append(sourceLessons, id)
+			}
+			if err := tx.Where("lesson_id IN ? AND status = ?", sourceLessons, models.TranscodeStatusReady).
+				Find(&transcodes).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("lesson_id IN ?", sourceLessons).Find(&packages).Error; err != nil {
+				return err
+			}
+		}
+		for _, transcode := range transcodes {
+			transcode.ID, transcode.LessonID = 0, lessonIDs[transcode.LessonID]
+			if err := tx.Create(&transcode).Error; err != nil {
+				return err
+			}
+		}
+		for _, pkg := range packages {
+			pkg.ID, pkg.LessonID = 0, lessonIDs[pkg.LessonID]
+			if err := tx.Create(&pkg).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to copy the course").Wrap(err))
+		return
+	}
+	refreshAccessibilityScore(db, course.ID)
+	refreshCourseWorkload(db, course.ID)
+
+	lessons := 0
+	for _, module := range content.Modules {
+		lessons += len(module.Lessons)
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Course copied as a draft"),
+		"course":  course,
+		"copied": gin.H{
+			"modules":     len(content.Modules),
+			"lessons":     lessons,
+			"quizzes":     len(content.Quizzes),
+			"assignments": len(content.Assignments),
+		},
+	})
+}
@@ -1,16 +1,25 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/cache"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/config"
 	"learning_hub/pkg/fileupload"
+	"learning_hub/pkg/i18n"
+	"log/slog"
 	"mime"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,47 +28,67 @@ import (
 )
 
 type CourseHandler struct {
-	DB *gorm.DB
+	DB           *gorm.DB
+	ListCacheTTL time.Duration // how long the catalogue of a country is cached, 0 disables the cache
+	CacheTTL     time.Duration // how long a course with its modules and lessons is cached
 }
 
 type UploadHandler struct {
-	DB *gorm.DB
+	DB                    *gorm.DB
+	DocumentDownloadLimit int           // per user per hour, 0 disables throttling
+	OrphanGracePeriod     time.Duration // unused uploads younger than this are kept
+	StudentQuota          int64         // default upload quota in bytes, 0 means unlimited
+	InstructorQuota       int64
 }
 
-func NewCourseHandler(db *gorm.DB) *CourseHandler {
-	return &CourseHandler{DB: db}
+func NewCourseHandler(db *gorm.DB, cfg *config.Config) *CourseHandler {
+	return &CourseHandler{DB: db, ListCacheTTL: cfg.CacheCourseListTTL, CacheTTL: cfg.CacheCourseTTL}
 }
 
-func NewUploadHandler(db *gorm.DB) *UploadHandler {
-	return &UploadHandler{DB: db}
+func NewUploadHandler(db *gorm.DB, cfg *config.Config) *UploadHandler {
+	return &UploadHandler{
+		DB:                    db,
+		DocumentDownloadLimit: cfg.DocumentDownloadLimit,
+		OrphanGracePeriod:     cfg.UploadOrphanGracePeriod,
+		StudentQuota:          cfg.StudentUploadQuota,
+		InstructorQuota:       cfg.InstructorUploadQuota,
+	}
 }
 
 // CreateCourse - Only instructors can create courses
 func (h *CourseHandler) CreateCourse(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	// Use a dedicated input struct without Instructor validation
 	var input struct {
-		Title        string  `json:"title" binding:"required"`
+		Title        string  `json:"title" binding:"required,max=200"`
 		Description  string  `json:"description" binding:"required"`
-		Price        float64 `json:"price"`
-		Category     string  `json:"category"`
+		Price        float64 `json:"price" binding:"gte=0"`
+		Currency     string  `json:"currency" binding:"omitempty,len=3"`
+		Category     string  `json:"category" binding:"max=100"`
 		Level        string  `json:"level" binding:"required,oneof=beginner intermediate advanced"`
-		ImageURL     string  `json:"image_url"`
-		ThumbnailURL string  `json:"thumbnail_url"`
+		ImageURL     string  `json:"image_url" binding:"max=500"`
+		ThumbnailURL string  `json:"thumbnail_url" binding:"max=500"`
+		ImageAlt     string  `json:"image_alt" binding:"max=300"`
 		Published    bool    `json:"published"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid course data: " + err.Error(),
-		})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
+	if input.Currency != "" {
+		code, err := checkPaymentCurrency(input.Currency)
+		if err != nil {
+			apierror.Abort(c, apierror.Invalid(paymentCurrencyProblem("currency")))
+			return
+		}
+		input.Currency = code
+	}
+
 	instructorID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Unauthorized: User ID not found in context",
-		})
+		apierror.Abort(c, apierror.Unauthorized("Unauthorized: User ID not found in context"))
 		return
 	}
 
@@ -68,86 +97,185 @@ func (h *CourseHandler) CreateCourse(c *gin.Context) {
 		Title:        input.Title,
 		Description:  input.Description,
 		Price:        input.Price,
+		Currency:     input.Currency,
 		Category:     input.Category,
 		Level:        input.Level,
 		ImageURL:     input.ImageURL,
 		ThumbnailURL: input.ThumbnailURL,
+		ImageAlt:     input.ImageAlt,
 		Published:    input.Published,
 		InstructorID: instructorID.(uint),
 	}
+	if newCourse.ThumbnailURL == "" && newCourse.ImageURL != "" {
+		newCourse.ThumbnailURL = courseThumbnail(c.Request.Context(), newCourse.ImageURL)
+	}
+	if report, err := courseAccessibility(db, newCourse); err == nil {
+		newCourse.AccessibilityScore = report.Score
+	}
 
-	if err := h.DB.Create(&newCourse).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create course: " + err.Error(),
-		})
+	// A course that doesn't meet the publish checklist yet is created as a draft
+	var checks []publishCheck
+	if newCourse.Published {
+		var passed bool
+		var err error
+		checks, passed, err = publishChecklist(c.Request.Context(), db, newCourse)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to evaluate publish checklist"))
+			return
+		}
+		newCourse.Published = passed
+	}
+
+	if err := db.Create(&newCourse).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create course").Wrap(err))
 		return
 	}
 
+	if input.Published && !newCourse.Published {
+		c.JSON(http.StatusCreated, gin.H{
+			"message":        i18n.T(i18n.Locale(c), "Course created as a draft: it does not meet the publish checklist yet"),
+			"course":         newCourse,
+			"publish_checks": checks,
+		})
+		return
+	}
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Course created successfully",
+		"message": i18n.T(i18n.Locale(c), "Course created successfully"),
 		"course":  newCourse,
 	})
 }
 
-// GetCourses - Get all published courses (public)
+// GetCourses - Get all published courses offered in the visitor's country, at its prices (public).
+// ?min_accessibility= only lists courses with at least that accessibility score, ?max_hours= courses
+// whose workload fits in that many hours.
 func (h *CourseHandler) GetCourses(c *gin.Context) {
-	var courses []models.Course
-	if err := h.DB.Where("published = ?", true).Preload("Instructor", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id, name, email") // Only load necessary instructor fields
-	}).Find(&courses).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch courses: " + err.Error(),
-		})
+	db := h.DB.WithContext(c.Request.Context())
+	query := db.Where("published = ?", true)
+	minScore, maxHours := -1, 0.0
+	if value := c.Query("min_accessibility"); value != "" {
+		var err error
+		minScore, err = strconv.Atoi(value)
+		if err != nil || minScore < 0 || minScore > 100 {
+			apierror.Abort(c, apierror.BadRequest("min_accessibility must be a number from 0 to 100"))
+			return
+		}
+		query = query.Where("accessibility_score >= ?", minScore)
+	}
+	if value := c.Query("max_hours"); value != "" {
+		var err error
+		maxHours, err = strconv.ParseFloat(value, 64)
+		if err != nil || maxHours <= 0 {
+			apierror.Abort(c, apierror.BadRequest("max_hours must be a positive number"))
+			return
+		}
+		query = query.Where("workload_hours <= ?", maxHours)
+	}
+
+	country := requestCountry(c, db)
+	key := fmt.Sprintf("%s:%d:%g", country, minScore, maxHours)
+	courses, err := cache.Get(c.Request.Context(), courseListCache, key, h.ListCacheTTL, func() ([]models.Course, error) {
+		var courses []models.Course
+		if err := availableInCountry(query, country).Preload("Instructor", contactUserFields).Find(&courses).Error; err != nil {
+			return nil, err
+		}
+		if err := applyCountryPrices(db, courses, country); err != nil {
+			return nil, fmt.Errorf("failed to fetch course prices: %w", err)
+		}
+		return courses, nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch courses").Wrap(err))
 		return
 	}
+	applyDisplayPrices(c, courses, displayCurrency(c, db))
 	c.JSON(http.StatusOK, gin.H{
 		"courses": courses,
+		"country": country,
 	})
 }
 
 // GetCourseByID - Get single course with modules and lessons
 func (h *CourseHandler) GetCourseByID(c *gin.Context) {
-	var course models.Course
-	courseID := c.Param("id")
-	if err := h.DB.Preload("Modules.Lessons").Preload("Instructor").First(&course, courseID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Course not found: " + err.Error(),
-		})
+	db := h.DB.WithContext(c.Request.Context())
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	course, err := cache.Get(c.Request.Context(), courseCache, strconv.FormatUint(courseID, 10), h.CacheTTL, func() (models.Course, error) {
+		var course models.Course
+		err := db.Preload("Modules.Lessons").Preload("Instructor", contactUserFields).First(&course, courseID).Error
+		return course, err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
 		return
 	}
+	if err != nil {
+		apierror.Abort(c, apierror.From(err))
+		return
+	}
+
+	// Courses not offered in the visitor's country are hidden, except from their managers
+	country := requestCountry(c, db)
+	if !canTeachCourse(c, db, course) {
+		price, available, err := coursePriceIn(db, course, country)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to fetch course price").Wrap(err))
+			return
+		}
+		if !available {
+			apierror.Abort(c, apierror.NotFound("Course not found: not available in your country"))
+			return
+		}
+		course.Price = price
+		hideCourseCodeTests(&course)
+
+		userID := c.GetUint("userID")
+		enrolled := false
+		if userID != 0 {
+			if enrolled, err = isActivelyEnrolled(db, userID, course.ID); err != nil {
+				apierror.Abort(c, apierror.Internal("Failed to check enrollment").Wrap(err))
+				return
+			}
+		}
+		showLessonsTo(&course, enrolled, userID)
+	}
+	applyDisplayPrice(c, &course, displayCurrency(c, db))
 	c.JSON(http.StatusOK, gin.H{
-		"course": course,
+		"course":                  course,
+		"country":                 country,
+		"instructor_availability": instructorAvailability(db, course.InstructorID),
 	})
 }
 
 // UpdateCourse - Only course instructor can update
 func (h *CourseHandler) UpdateCourse(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	var course models.Course
 	courseID := c.Param("id")
-	if err := h.DB.First(&course, courseID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Course not found: " + err.Error(),
-		})
+	if err := db.First(&course, courseID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	} else if err != nil {
+		apierror.Abort(c, apierror.From(err))
 		return
 	}
 
-	userID, exists := c.Get("userID")
-	if !exists || course.InstructorID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Forbidden: You are not the instructor of this course",
-		})
+	if !canManageCourse(c, db, course) {
+		apierror.Abort(c, apierror.Forbidden("Forbidden: You are not the instructor of this course"))
 		return
 	}
 
 	// Use the dedicated update struct
 	var updateData models.UpdateCourseInput
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid course data: " + err.Error(),
-		})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
+	wasPublished := course.Published
+
 	// Update only provided fields
 	if updateData.Title != "" {
 		course.Title = updateData.Title
@@ -155,89 +283,177 @@ func (h *CourseHandler) UpdateCourse(c *gin.Context) {
 	if updateData.Description != "" {
 		course.Description = updateData.Description
 	}
-	course.Price = updateData.Price // Can be 0, so no empty check
+	// Wishlisters hear of a price drop only when the update set the price
+	var oldPrice *float64
+	if updateData.Price != nil {
+		previous := course.Price
+		oldPrice = &previous
+		course.Price = *updateData.Price
+	}
+	if updateData.Currency != "" {
+		code, err := checkPaymentCurrency(updateData.Currency)
+		if err != nil {
+			apierror.Abort(c, apierror.Invalid(paymentCurrencyProblem("currency")))
+			return
+		}
+		course.Currency = code
+	}
 	if updateData.Category != "" {
 		course.Category = updateData.Category
 	}
 	if updateData.Level != "" {
 		course.Level = updateData.Level
 	}
-	if updateData.ImageURL != "" {
+	if updateData.ImageURL != "" && updateData.ImageURL != course.ImageURL {
 		course.ImageURL = updateData.ImageURL
+		// A new image gets a new thumbnail unless one is given explicitly
+		if updateData.ThumbnailURL == "" {
+			course.ThumbnailURL = courseThumbnail(c.Request.Context(), course.ImageURL)
+		}
 	}
 	if updateData.ThumbnailURL != "" {
 		course.ThumbnailURL = updateData.ThumbnailURL
 	}
-	course.Published = updateData.Published
+	if updateData.ImageAlt != "" {
+		course.ImageAlt = updateData.ImageAlt
+	}
+	report, err := courseAccessibility(db, course)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check course accessibility"))
+		return
+	}
+	course.AccessibilityScore = report.Score
+	if updateData.Published != nil && *updateData.Published && !course.Published {
+		checks, passed, err := publishChecklist(c.Request.Context(), db, course)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to evaluate publish checklist"))
+			return
+		}
+		if !passed {
+			apierror.Abort(c, apierror.New(http.StatusUnprocessableEntity, "Course does not meet the publish checklist").With("checks", checks))
+			return
+		}
+	}
+	if updateData.Published != nil {
+		course.Published = *updateData.Published
+	}
 
-	if err := h.DB.Save(&course).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update course: " + err.Error(),
-		})
+	if err := db.Save(&course).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update course").Wrap(err))
 		return
 	}
+	courseChanged(db, course, wasPublished, oldPrice)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Course updated successfully",
+		"message": i18n.T(i18n.Locale(c), "Course updated successfully"),
 		"course":  course,
 	})
 }
 
 // EnrollCourse - Student enrolls in a course
 func (h *CourseHandler) EnrollCourse(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	courseID := c.Param("id")
 	var course models.Course
-	if err := h.DB.First(&course, courseID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+	if err := db.First(&course, courseID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
 		return
 	}
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
-	// Check if already enrolled
-	var existingEnrollment models.Enrollment
-	if err := h.DB.Where("user_id = ? AND course_id = ?", userID, course.ID).First(&existingEnrollment).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Already enrolled in this course"})
+	if rejectTestStudent(c, db, "enroll in other courses") {
 		return
 	}
-	enrollment := models.Enrollment{
-		UserID:     userID.(uint),
-		CourseID:   course.ID,
-		EnrolledAt: time.Now(),
-		Progress:   0,
+	price, available, err := coursePriceIn(db, course, requestCountry(c, db))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check course availability"))
+		return
+	} else if !available {
+		apierror.Abort(c, apierror.Forbidden("This course is not available in your country"))
+		return
+	}
+	// Check if already enrolled; a deactivated enrollment is reactivated below
+	enrolled, err := isActivelyEnrolled(db, userID.(uint), course.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check enrollment").Wrap(err))
+		return
 	}
-	if err := h.DB.Create(&enrollment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll in course: " + err.Error()})
+	if enrolled {
+		apierror.Abort(c, apierror.BadRequest("Already enrolled in this course"))
 		return
 	}
+	// Only free courses are joined directly, paid ones enroll the student once their payment succeeds
+	if price > 0 {
+		apierror.Abort(c, apierror.New(http.StatusPaymentRequired, "This course must be paid for, start a payment with POST /api/payments/initiate").With("payment_required", true).With("price", price).With("currency", courseCurrency(course)))
+		return
+	}
+	enrollment, created, err := enrollForFree(db, userID.(uint), course.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to enroll in course").Wrap(err))
+		return
+	}
+	if created {
+		emitEnrollmentCreated(db, enrollment)
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"message":    "Enrolled successfully",
+		"message":    i18n.T(i18n.Locale(c), "Enrolled successfully"),
 		"enrollment": enrollment,
 	})
 
 }
 
+// isActivelyEnrolled reports whether the user has an active enrollment in the course. Deactivated ones,
+// such as of a seat taken back by an organization, don't keep them from enrolling again.
+func isActivelyEnrolled(db *gorm.DB, userID, courseID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.Enrollment{}).Where("user_id = ? AND course_id = ? AND is_active", userID, courseID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// enrollForFree enrolls the user in a course without a payment, reactivating their deactivated enrollment
+// if they have one. It reports whether the enrollment was created.
+func enrollForFree(db *gorm.DB, userID, courseID uint) (models.Enrollment, bool, error) {
+	var enrollment models.Enrollment
+	err := db.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error
+	if err == nil {
+		return enrollment, false, db.Model(&enrollment).Update("is_active", true).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return enrollment, false, err
+	}
+	enrollment = models.Enrollment{
+		UserID:     userID,
+		CourseID:   courseID,
+		IsActive:   true,
+		EnrolledAt: clock.Now(),
+	}
+	return enrollment, true, db.Create(&enrollment).Error
+}
+
 // GetStudentCourses - Get all courses a student is enrolled in
 // GetStudentCourses - Get all courses a student is enrolled in
 func (h *CourseHandler) GetStudentCourses(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userIDInterface, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	// Convert userID to uint properly
 	userID, ok := userIDInterface.(uint)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID type"})
+		apierror.Abort(c, apierror.Internal("Invalid user ID type"))
 		return
 	}
 
 	var enrollments []models.Enrollment
-	if err := h.DB.Preload("Course").Where("user_id = ?", userID).Find(&enrollments).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollments: " + err.Error()})
+	if err := db.Preload("Course").Where("user_id = ?", userID).Find(&enrollments).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch enrollments").Wrap(err))
 		return
 	}
 
@@ -248,9 +464,10 @@ func (h *CourseHandler) GetStudentCourses(c *gin.Context) {
 
 // UpdateLessonProgress - Mark lesson as completed and update progress
 func (h *CourseHandler) UpdateLessonProgress(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
@@ -259,63 +476,76 @@ func (h *CourseHandler) UpdateLessonProgress(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	// Fetch lesson with Module preloaded to get CourseID
 	var lesson models.Lesson
-	if err := h.DB.Preload("Module").First(&lesson, input.LessonID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+	if err := db.Preload("Module").First(&lesson, input.LessonID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Lesson not found"))
 		return
 	}
 
 	// Check if user is enrolled in the course
 	var enrollment models.Enrollment
-	if err := h.DB.Where("user_id = ? AND course_id = ?", userID, lesson.Module.CourseID).First(&enrollment).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not enrolled in this course"})
+	if err := db.Where("user_id = ? AND course_id = ?", userID, lesson.Module.CourseID).First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.Forbidden("You are not enrolled in this course"))
+		return
+	}
+
+	reason, err := lessonCompletionBlocked(db, userID.(uint), lesson)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check lesson completion"))
+		return
+	}
+	if reason != "" {
+		apierror.Abort(c, apierror.Conflict(reason).With("completion_criterion", lesson.CompletionCriterion))
 		return
 	}
 
 	// Mark lesson as completed
 	var progress models.LessonProgress
-	if err := h.DB.Where("user_id = ? AND lesson_id = ?", userID, input.LessonID).First(&progress).Error; err != nil {
+	if err := db.Where("user_id = ? AND lesson_id = ?", userID, input.LessonID).First(&progress).Error; err != nil {
 		// Not found, create new progress
 		progress = models.LessonProgress{
 			UserID:      userID.(uint),
 			LessonID:    input.LessonID,
 			CourseID:    lesson.Module.CourseID,
 			Completed:   true,
-			CompletedAt: time.Now(), // Add completion timestamp
+			CompletedAt: clock.Now(), // Add completion timestamp
 		}
-		if err := h.DB.Create(&progress).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark lesson as completed: " + err.Error()})
+		if err := db.Create(&progress).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to mark lesson as completed").Wrap(err))
 			return
 		}
 	} else {
 		// Already exists, update to completed
 		progress.Completed = true
-		progress.CompletedAt = time.Now() // Update timestamp
-		if err := h.DB.Save(&progress).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update lesson progress: " + err.Error()})
+		progress.CompletedAt = clock.Now() // Update timestamp
+		if err := db.Save(&progress).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to update lesson progress").Wrap(err))
 			return
 		}
 	}
 
 	// Update overall course progress
 	var totalLessons int64
-	h.DB.Model(&models.Lesson{}).Joins("JOIN modules ON lessons.module_id = modules.id").Where("modules.course_id = ?", lesson.Module.CourseID).Count(&totalLessons)
+	db.Model(&models.Lesson{}).Joins("JOIN modules ON lessons.module_id = modules.id").Where("modules.course_id = ?", lesson.Module.CourseID).Count(&totalLessons)
 
 	var completedLessons int64
-	h.DB.Model(&models.LessonProgress{}).Where("user_id = ? AND course_id = ? AND completed = ?", userID, lesson.Module.CourseID, true).Count(&completedLessons)
+	db.Model(&models.LessonProgress{}).Where("user_id = ? AND course_id = ? AND completed = ?", userID, lesson.Module.CourseID, true).Count(&completedLessons)
 
 	if totalLessons > 0 {
 		enrollment.Progress = (float64(completedLessons) / float64(totalLessons)) * 100
-		h.DB.Save(&enrollment)
+		db.Save(&enrollment)
+	}
+	if err := awardLessonPoints(db, userID.(uint), lesson.Module.CourseID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to award lesson points", "user_id", userID, "error", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":         "Lesson marked as completed",
+		"message":         i18n.T(i18n.Locale(c), "Lesson marked as completed"),
 		"lesson_progress": progress,
 		"course_progress": enrollment.Progress,
 		"completed":       completedLessons,
@@ -325,15 +555,16 @@ func (h *CourseHandler) UpdateLessonProgress(c *gin.Context) {
 
 // GetCourseProgress - Get student's progress in a specific course
 func (h *CourseHandler) GetCourseProgress(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 	courseID := c.Param("id")
 	var enrollment models.Enrollment
-	if err := h.DB.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Enrollment not found for this course"})
+	if err := db.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Enrollment not found for this course"))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
@@ -345,17 +576,18 @@ func (h *CourseHandler) GetCourseProgress(c *gin.Context) {
 
 // GetStudentDashboard - Get student's learning dashboard
 func (h *CourseHandler) GetStudentDashboard(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	var enrollments []models.Enrollment
-	if err := h.DB.Preload("Course").Preload("Course.Instructor").
+	if err := db.Preload("Course").Preload("Course.Instructor", publicUserFields).
 		Where("user_id = ?", userID).
 		Find(&enrollments).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dashboard data"})
+		apierror.Abort(c, apierror.Internal("Failed to fetch dashboard data"))
 		return
 	}
 
@@ -387,15 +619,16 @@ func (h *CourseHandler) GetStudentDashboard(c *gin.Context) {
 }
 
 func (h *CourseHandler) SubmitCourseReview(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 	courseID := c.Param("id")
 	var course models.Course
-	if err := h.DB.First(&course, courseID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+	if err := db.First(&course, courseID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
 		return
 	}
 	var input struct {
@@ -403,44 +636,92 @@ func (h *CourseHandler) SubmitCourseReview(c *gin.Context) {
 		Comment string `json:"comment"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if rejectTestStudent(c, db, "review courses") {
+		return
+	}
+	// Only enrolled students review, once per enrollment
+	var enrollment models.Enrollment
+	if err := db.Where("user_id = ? AND course_id = ? AND is_active = ?", userID, course.ID, true).
+		First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.Forbidden("Only students enrolled in this course can review it"))
+		return
+	}
+	var existing int64
+	db.Model(&models.Review{}).
+		Where("enrollment_id = ? OR (user_id = ? AND course_id = ?)", enrollment.ID, userID, course.ID).
+		Count(&existing)
+	if existing > 0 {
+		apierror.Abort(c, apierror.Conflict("You have already reviewed this course"))
+		return
+	}
+	verified := false
+	if enrollment.PaymentID != nil {
+		var payment models.Payment
+		verified = db.Select("status").First(&payment, *enrollment.PaymentID).Error == nil &&
+			payment.Status == models.PaymentStatusSuccess
+	}
+
+	spamCheck, ok := checkPosting(c, db, "review", input.Comment)
+	if !ok {
 		return
 	}
 	review := models.Review{
-		UserID:   userID.(uint),
-		CourseID: course.ID,
-		Rating:   input.Rating,
-		Comment:  input.Comment,
+		UserID:           userID.(uint),
+		CourseID:         course.ID,
+		Rating:           input.Rating,
+		Comment:          input.Comment,
+		Status:           contentStatus(spamCheck),
+		EnrollmentID:     &enrollment.ID,
+		VerifiedPurchase: verified,
+	}
+	review.CreatedAt = clock.Now()
+	review.UpdatedAt = review.CreatedAt
+	if err := db.Create(&review).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to submit review").Wrap(err))
+		return
 	}
-	if err := h.DB.Create(&review).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit review: " + err.Error()})
+	// Reviews that look like spam wait for an admin instead of being published
+	if spamCheck.Flagged() {
+		if err := queueForModeration(db, "review", review.ID, review.UserID, review.Comment, spamCheck); err != nil {
+			slog.WarnContext(c.Request.Context(), "Failed to queue review for moderation", "review_id", review.ID, "error", err)
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": i18n.T(i18n.Locale(c), "Review submitted and awaiting moderation"),
+			"review":  review,
+		})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Review submitted successfully",
+		"message": i18n.T(i18n.Locale(c), "Review submitted successfully"),
 		"review":  review,
 	})
 }
 
 // GetCourseReviews handles GET /courses/:id/reviews
 func (h *CourseHandler) GetCourseReviews(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	courseID := c.Param("id")
 	var reviews []models.Review
-	if err := h.DB.Where("course_id = ?", courseID).Find(&reviews).Error; err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch reviews"})
+	if err := db.Where("course_id = ? AND status = ?", courseID, models.ContentPublished).Find(&reviews).Error; err != nil {
+		apierror.Abort(c, apierror.New(500, "Failed to fetch reviews"))
 		return
 	}
 	c.JSON(200, gin.H{"reviews": reviews})
 }
 
-// UploadFile handles file uploads for course materials
+// UploadFile handles file uploads for course materials. Uploads are owned by the signed-in user and
+// count towards their quota; videos are for lessons, so only instructors and admins may upload them.
 func (h *UploadHandler) UploadFile(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
 	// Get the uploaded file from the form
 	file, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No file uploaded or invalid form data",
-		})
+		apierror.Abort(c, apierror.BadRequest("No file uploaded or invalid form data"))
 		return
 	}
 
@@ -450,82 +731,165 @@ func (h *UploadHandler) UploadFile(c *gin.Context) {
 		// Auto-detect file type from extension
 		detectedType, err := fileupload.DetectFileType(file.Filename)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Unsupported file type. Please specify file type or upload a supported file.",
-			})
+			apierror.Abort(c, apierror.BadRequest("Unsupported file type. Please specify file type or upload a supported file."))
 			return
 		}
 		fileType = detectedType
 	} else {
 		// Validate that the specified file type is valid
 		if !isValidFileType(fileType) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid file type specified",
-			})
+			apierror.Abort(c, apierror.BadRequest("Invalid file type specified"))
 			return
 		}
 	}
+	if role := c.GetString("userRole"); fileType == fileupload.FileTypeVideo && role != "instructor" && role != "admin" {
+		apierror.Abort(c, apierror.Forbidden("Only instructors can upload videos"))
+		return
+	}
 
 	// Validate the file
-	validationResult := fileupload.ValidateFile(file, fileType)
+	validationResult := fileupload.ValidateFile(c.Request.Context(), file, fileType)
 	if !validationResult.IsValid {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": validationResult.Error.Error(),
-		})
+		if rejectScannedFile(c, db, file, quarantineSourceUpload, validationResult.Error) {
+			return
+		}
+		apierror.Abort(c, apierror.BadRequest(validationResult.Error.Error()))
+		return
+	}
+
+	quota, err := h.quotaFor(db, userID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check upload quota"))
+		return
+	}
+	if quota.exceededBy(file.Size) {
+		apierror.Abort(c, apierror.New(http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload quota exceeded: %s of %s used, this file needs %s", formatBytes(quota.Usage), formatBytes(quota.Limit), formatBytes(file.Size))).With("usage", quota.Usage).With("limit", quota.Limit).With("file_size", file.Size))
 		return
 	}
 
 	// Generate secure filename
 	secureFilename, err := generateSecureFilename(file.Filename)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to generate secure filename",
-		})
+		apierror.Abort(c, apierror.Internal("Failed to generate secure filename"))
 		return
 	}
 
 	// Get upload path for the file type
 	uploadSubdir := fileupload.GetUploadPath(fileType)
-	uploadPath := filepath.Join("uploads", uploadSubdir)
+	key := uploadSubdir + "/" + secureFilename
+	contentType := mime.TypeByExtension(filepath.Ext(secureFilename))
 
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(uploadPath, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create upload directory",
-		})
+	checksum, err := hashUploadedFile(file)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to read uploaded file"))
 		return
 	}
 
-	// Construct full file path
-	fullPath := filepath.Join(uploadPath, secureFilename)
-
-	// Save the file
-	if err := c.SaveUploadedFile(file, fullPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to save file",
-		})
-		return
-	}
+	// Identical content that is already stored is reused instead of stored again
+	duplicate := findDuplicateUpload(c.Request.Context(), db, fileType, checksum, file.Size)
+	if duplicate != nil {
+		key = duplicate.StorageKey
+		secureFilename = path.Base(key)
+	} else {
+		// Save the file to the configured storage backend
+		src, err := file.Open()
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to read uploaded file"))
+			return
+		}
+		defer src.Close()
 
-	// Set appropriate file permissions (readable by owner/group, not executable)
-	if err := os.Chmod(fullPath, 0644); err != nil {
-		// Log the error but don't fail the upload
-		fmt.Printf("Warning: failed to set file permissions for %s: %v\n", fullPath, err)
+		if err := fileupload.Storage().Save(c.Request.Context(), key, src, file.Size, contentType); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to store upload", "storage_key", key, "error", err)
+			apierror.Abort(c, apierror.Internal("Failed to save file"))
+			return
+		}
 	}
 
 	// Generate file URL for client access
 	// Note: In production, you might want to serve files through a dedicated endpoint
 	// or use a CDN/base URL configuration
-	fileURL := fmt.Sprintf("/uploads/%s/%s", uploadSubdir, secureFilename)
+	fileURL := "/uploads/" + key
+
+	// Track the file so it can be listed by its owner, counted towards their quota and cleaned up once
+	// unused. Every uploader of deduplicated content gets their own record of the shared file.
+	record := models.UploadedFile{
+		OwnerID:      &userID,
+		FileType:     fileType,
+		StorageKey:   key,
+		FileURL:      fileURL,
+		OriginalName: file.Filename,
+		ContentType:  contentType,
+		Size:         file.Size,
+		Checksum:     checksum,
+		CreatedAt:    clock.Now(),
+		UpdatedAt:    clock.Now(),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		// A file missing from the registry would be used free of quota and never cleaned up
+		if duplicate == nil {
+			if err := fileupload.Storage().Delete(c.Request.Context(), key); err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to delete unrecorded upload", "storage_key", key, "error", err)
+			}
+		}
+		apierror.Abort(c, apierror.Internal("Failed to record upload").Wrap(err))
+		return
+	}
+	uploadSize.WithLabelValues(fileType).Observe(float64(file.Size))
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":       "File uploaded successfully",
+	response := gin.H{
+		"message":       i18n.T(i18n.Locale(c), "File uploaded successfully"),
+		"file_id":       record.ID,
 		"file_url":      fileURL,
 		"file_name":     secureFilename,
 		"file_type":     fileType,
 		"file_size":     file.Size,
 		"original_name": file.Filename,
-	})
+		"checksum":      checksum,
+		"deduplicated":  duplicate != nil,
+	}
+
+	// Images get card, banner and avatar sized variants
+	if fileType == fileupload.FileTypeImage {
+		thumbnails, err := imageVariants(c.Request.Context(), key)
+		if err == nil {
+			response["thumbnails"] = thumbnails
+		} else if err != fileupload.ErrUnsupportedImage {
+			slog.WarnContext(c.Request.Context(), "Failed to generate image variants", "storage_key", key, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// courseThumbnail returns the card-size variant of an uploaded course image, generating it when missing.
+// External images and formats that can't be resized are used as their own thumbnail.
+func courseThumbnail(ctx context.Context, imageURL string) string {
+	key, ok := fileupload.ImageKeyFromURL(imageURL)
+	if !ok {
+		return imageURL
+	}
+
+	urls, err := imageVariants(ctx, key)
+	if err != nil {
+		if err != fileupload.ErrUnsupportedImage {
+			slog.WarnContext(ctx, "Failed to generate thumbnail", "storage_key", key, "error", err)
+		}
+		return imageURL
+	}
+	return urls[fileupload.ImageSizeCard.Name]
+}
+
+// imageVariants returns the size variant URLs of a stored image, generating them when missing
+func imageVariants(ctx context.Context, key string) (map[string]string, error) {
+	if _, err := fileupload.Storage().Stat(ctx, fileupload.VariantKey(key, fileupload.ImageSizeCard)); err == nil {
+		urls := make(map[string]string, len(fileupload.ImageSizes))
+		for _, size := range fileupload.ImageSizes {
+			urls[size.Name] = "/uploads/" + fileupload.VariantKey(key, size)
+		}
+		return urls, nil
+	}
+	return fileupload.GenerateImageVariants(ctx, key)
 }
 
 // generateSecureFilename creates a secure filename to prevent path traversal attacks
@@ -544,7 +908,7 @@ func generateSecureFilename(originalFilename string) (string, error) {
 	randomPrefix := hex.EncodeToString(randomBytes)
 
 	// Get current timestamp for additional uniqueness
-	timestamp := time.Now().Unix()
+	timestamp := clock.Now().Unix()
 
 	// Clean the original filename (remove any path components, keep only basename)
 	cleanName := filepath.Base(strings.TrimSuffix(originalFilename, ext))
@@ -610,11 +974,16 @@ func (h *UploadHandler) ServeFile(c *gin.Context) {
 	fileType := c.Param("type")
 	filename := c.Param("filename")
 
-	// Validate file type
-	if !isValidFileType(fileType) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid file type",
-		})
+	// Accept both the file type ("document") and its upload directory ("documents"),
+	// which is what generated file URLs contain
+	fileType = strings.TrimSuffix(fileType, "s")
+
+	// Submitted assignment files have a directory of their own
+	uploadSubdir := fileupload.GetUploadPath(fileType)
+	if c.Param("type")+"/" == submissionStoragePrefix {
+		uploadSubdir = c.Param("type")
+	} else if !isValidFileType(fileType) {
+		apierror.Abort(c, apierror.BadRequest("Invalid file type"))
 		return
 	}
 
@@ -622,68 +991,55 @@ func (h *UploadHandler) ServeFile(c *gin.Context) {
 	// Use filepath.Base to get only the filename part
 	cleanFilename := filepath.Base(filename)
 	if cleanFilename != filename {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid filename - path traversal detected",
-		})
+		apierror.Abort(c, apierror.BadRequest("Invalid filename - path traversal detected"))
 		return
 	}
 
 	// Additional filename validation
 	if !isValidFilename(cleanFilename) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid filename characters",
-		})
+		apierror.Abort(c, apierror.BadRequest("Invalid filename characters"))
 		return
 	}
 
-	// Construct the file path
-	uploadSubdir := fileupload.GetUploadPath(fileType)
-	filePath := filepath.Join("uploads", uploadSubdir, cleanFilename)
-
-	// Clean the path to prevent directory traversal
-	cleanPath := filepath.Clean(filePath)
+	// Check if file exists
+	key := uploadSubdir + "/" + cleanFilename
+	storage := fileupload.Storage()
 
-	// Ensure the cleaned path is still within our uploads directory
-	// This prevents attacks like "../../../etc/passwd"
-	uploadsDir, err := filepath.Abs("uploads")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to resolve uploads directory",
-		})
+	info, err := storage.Stat(c.Request.Context(), key)
+	if err == fileupload.ErrObjectNotFound {
+		apierror.Abort(c, apierror.NotFound("File not found"))
+		return
+	} else if err != nil {
+		apierror.Abort(c, apierror.Internal("Error accessing file"))
 		return
 	}
 
-	cleanAbsPath, err := filepath.Abs(cleanPath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to resolve file path",
-		})
+	// Lesson materials require enrollment and are logged and throttled
+	if !h.authorizeProtectedFile(c, fmt.Sprintf("/uploads/%s/%s", uploadSubdir, cleanFilename), info.Size) {
 		return
 	}
 
-	// Verify that the file is within the uploads directory
-	if !strings.HasPrefix(cleanAbsPath, uploadsDir) {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Access denied - file outside allowed directory",
-		})
-		return
+	// Set Content-Disposition for certain file types (optional)
+	// This prevents automatic execution of potentially dangerous files
+	downloadName := ""
+	if shouldForceDownload(cleanFilename) {
+		downloadName = cleanFilename
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(cleanAbsPath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "File not found",
-		})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error accessing file",
-		})
-		return
+	// Object storage: send the client straight to a short-lived presigned URL
+	if fileupload.RedirectDownloads() {
+		if url, err := storage.PresignGet(key, fileupload.PresignExpiry(), downloadName); err == nil {
+			c.Header("Cache-Control", "private, no-store")
+			c.Redirect(http.StatusFound, url)
+			return
+		} else if err != fileupload.ErrPresignNotSupported {
+			apierror.Abort(c, apierror.Internal("Failed to create download URL"))
+			return
+		}
 	}
 
 	// Determine and set Content-Type
-	contentType := mime.TypeByExtension(filepath.Ext(cleanAbsPath))
+	contentType := mime.TypeByExtension(filepath.Ext(cleanFilename))
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
@@ -691,17 +1047,29 @@ func (h *UploadHandler) ServeFile(c *gin.Context) {
 
 	// Set cache control headers for performance
 	// Cache for 1 hour for static assets
-	c.Header("Cache-Control", "public, max-age=3600")
-	c.Header("Expires", time.Now().Add(time.Hour).Format(http.TimeFormat))
+	if c.Writer.Header().Get("Cache-Control") == "" {
+		c.Header("Cache-Control", "public, max-age=3600")
+		c.Header("Expires", clock.Now().Add(time.Hour).Format(http.TimeFormat))
+	}
 
-	// Set Content-Disposition for certain file types (optional)
-	// This prevents automatic execution of potentially dangerous files
-	if shouldForceDownload(cleanAbsPath) {
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", cleanFilename))
+	if downloadName != "" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", downloadName))
+	}
+
+	// Serve (or proxy) the file
+	reader, _, err := storage.Open(c.Request.Context(), key)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Error accessing file"))
+		return
 	}
+	defer reader.Close()
 
-	// Serve the file
-	c.File(cleanAbsPath)
+	// Local files support range requests, which video seeking relies on
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, cleanFilename, info.ModTime, seeker)
+		return
+	}
+	c.DataFromReader(http.StatusOK, info.Size, contentType, reader, nil)
 }
 
 // isValidFilename checks if the filename contains only safe characters
@@ -744,32 +1112,60 @@ func shouldForceDownload(filePath string) bool {
 
 // (Removed duplicate CreateModule method)
 func (h *CourseHandler) DeleteCourse(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.DB.Delete(&models.Course{}, "id = ?", id).Error; err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete course"})
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, "id = ?", c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	if !ownsCourse(c, course) {
+		apierror.Abort(c, apierror.Forbidden("Only the course's instructor can delete it"))
+		return
+	}
+	if err := db.Delete(&course).Error; err != nil {
+		apierror.Abort(c, apierror.New(500, "Failed to delete course"))
 		return
 	}
-	c.JSON(200, gin.H{"message": "Course deleted successfully"})
+	recordAudit(db, c, models.AuditCourseDelete, "course", course.ID, gin.H{
+		"title":         course.Title,
+		"instructor_id": course.InstructorID,
+		"price":         course.Price,
+		"published":     course.Published,
+	}, nil)
+	c.JSON(200, gin.H{"message": i18n.T(i18n.Locale(c), "Course deleted successfully")})
 }
 
-// GetInstructorCourses returns all courses for the authenticated instructor
+// GetInstructorCourses returns the courses the authenticated instructor teaches or is on the staff of;
+// staff_roles maps the IDs of the latter to their role
 func (h *CourseHandler) GetInstructorCourses(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(401, gin.H{"error": "Unauthorized"})
+	db := h.DB.WithContext(c.Request.Context())
+	instructorID := c.MustGet("userID").(uint)
+	var staff []models.CourseStaff
+	if err := db.Where("user_id = ?", instructorID).Find(&staff).Error; err != nil {
+		apierror.Abort(c, apierror.New(500, "Failed to fetch courses"))
 		return
 	}
-	instructorID := user.(*models.User).ID
+	staffRoles := make(map[uint]string, len(staff))
+	staffed := make([]uint, len(staff))
+	for i, member := range staff {
+		staffRoles[member.CourseID] = member.Role
+		staffed[i] = member.CourseID
+	}
 	var courses []models.Course
-	if err := h.DB.Where("instructor_id = ?", instructorID).Find(&courses).Error; err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch courses"})
+	query := db.Where("instructor_id = ?", instructorID)
+	if len(staffed) > 0 {
+		query = query.Or("id IN ?", staffed)
+	}
+	if err := query.Find(&courses).Error; err != nil {
+		apierror.Abort(c, apierror.New(500, "Failed to fetch courses"))
 		return
 	}
-	c.JSON(200, gin.H{"courses": courses})
+	c.JSON(200, gin.H{"courses": courses, "staff_roles": staffRoles})
 }
 
 // CreateModule creates a new module for a course
 func (h *CourseHandler) CreateModule(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	courseID := c.Param("id")
 
 	var input struct {
@@ -779,21 +1175,20 @@ func (h *CourseHandler) CreateModule(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	// Verify course exists
 	var course models.Course
-	if err := h.DB.First(&course, courseID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+	if err := db.First(&course, courseID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
 		return
 	}
 
-	// Check if user is the course instructor
-	userID, exists := c.Get("userID")
-	if !exists || course.InstructorID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this course"})
+	// Check if user may change the course
+	if !canManageCourse(c, db, course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to modify this course"))
 		return
 	}
 
@@ -804,10 +1199,94 @@ func (h *CourseHandler) CreateModule(c *gin.Context) {
 		CourseID:    course.ID,
 	}
 
-	if err := h.DB.Create(&module).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create module"})
+	if err := db.Create(&module).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create module"))
 		return
 	}
 
 	c.JSON(http.StatusCreated, module)
 }
+
+// DeleteModule moves a module and its lessons to the trash
+func (h *CourseHandler) DeleteModule(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var module models.Module
+	if err := db.Preload("Course").Where("course_id = ?", c.Param("id")).
+		First(&module, c.Param("moduleId")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Module not found"))
+		return
+	}
+
+	if !canManageCourse(c, db, module.Course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to modify this course"))
+		return
+	}
+
+	// Same rule as lessons: content with student progress can't be removed
+	var progressCount int64
+	db.Model(&models.LessonProgress{}).
+		Joins("JOIN lessons ON lessons.id = lesson_progresses.lesson_id").
+		Where("lessons.module_id = ? AND lessons.deleted_at IS NULL", module.ID).
+		Count(&progressCount)
+	if progressCount > 0 {
+		apierror.Abort(c, apierror.BadRequest("Cannot delete module with user progress records"))
+		return
+	}
+
+	// Lessons share the module's deletion time so restoring the module brings them back
+	now := clock.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Lesson{}).Where("module_id = ?", module.ID).
+			Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&module).Update("deleted_at", now).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete module"))
+		return
+	}
+	refreshAccessibilityScore(db, module.CourseID)
+	refreshCourseWorkload(db, module.CourseID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Module moved to trash"),
+		"purge_at": now.Add(trashRetention),
+	})
+}
+
+// ownsCourse reports whether the authenticated user is the course instructor or an admin
+func ownsCourse(c *gin.Context, course models.Course) bool {
+	if role, _ := c.Get("userRole"); role == "admin" {
+		return true
+	}
+	userID, exists := c.Get("userID")
+	return exists && course.InstructorID == userID.(uint)
+}
+
+// canManageCourse reports whether the authenticated user may change the course: its instructor, one of its
+// co-instructors or an admin
+func canManageCourse(c *gin.Context, db *gorm.DB, course models.Course) bool {
+	return ownsCourse(c, course) || courseStaffRole(c, db, course.ID) == models.StaffCoInstructor
+}
+
+// canTeachCourse reports whether the authenticated user is on the course's staff, teaching assistants
+// included: they may grade, moderate and see the course's analytics
+func canTeachCourse(c *gin.Context, db *gorm.DB, course models.Course) bool {
+	return ownsCourse(c, course) || courseStaffRole(c, db, course.ID) != ""
+}
+
+// loadManagedCourse loads the :id course and checks the caller may work on it as allowed, by canManageCourse
+// or canTeachCourse. It answers 404 or 403 and returns false when not.
+func loadManagedCourse(c *gin.Context, db *gorm.DB, allowed func(*gin.Context, *gorm.DB, models.Course) bool) (models.Course, bool) {
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return course, false
+	}
+	if !allowed(c, db, course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to manage this course"))
+		return course, false
+	}
+	return course, true
+}
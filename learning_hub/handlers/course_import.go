@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/youtube"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CourseImportHandler creates draft courses from external sources
+type CourseImportHandler struct {
+	DB *gorm.DB
+}
+
+func NewCourseImportHandler(db *gorm.DB) *CourseImportHandler {
+	return &CourseImportHandler{DB: db}
+}
+
+// truncateRunes shortens s to at most n characters
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// ImportYouTubePlaylist creates a draft course from a public YouTube playlist: one lesson per video
+// with its title, description, duration and embedded player, in playlist order. With
+// lessons_per_module the lessons are split into modules of that size, otherwise they share one module.
+func (h *CourseImportHandler) ImportYouTubePlaylist(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		PlaylistURL      string  `json:"playlist_url" binding:"required"`
+		Title            string  `json:"title"` // defaults to the playlist title
+		Category         string  `json:"category"`
+		Level            string  `json:"level" binding:"omitempty,oneof=beginner intermediate advanced"`
+		Price            float64 `json:"price" binding:"min=0"`
+		LessonsPerModule int     `json:"lessons_per_module" binding:"min=0,max=100"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if !youtube.Enabled() {
+		apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, "YouTube import is not available"))
+		return
+	}
+	playlistID, err := youtube.ParsePlaylistID(input.PlaylistURL)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	playlist, err := youtube.FetchPlaylist(c.Request.Context(), playlistID)
+	if errors.Is(err, youtube.ErrNotFound) {
+		apierror.Abort(c, apierror.NotFound("Playlist not found or not public"))
+		return
+	}
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to read YouTube playlist", "playlist_id", playlistID, "error", err)
+		apierror.Abort(c, apierror.New(http.StatusBadGateway, "Failed to read the playlist from YouTube"))
+		return
+	}
+	if len(playlist.Videos) == 0 {
+		apierror.Abort(c, apierror.New(http.StatusUnprocessableEntity, "The playlist has no public videos to import"))
+		return
+	}
+
+	course := models.Course{
+		Title:        strings.TrimSpace(input.Title),
+		Description:  strings.TrimSpace(playlist.Description),
+		Price:        input.Price,
+		Category:     input.Category,
+		Level:        input.Level,
+		ImageURL:     playlist.ThumbnailURL,
+		ThumbnailURL: playlist.ThumbnailURL,
+		Published:    false,
+		InstructorID: c.MustGet("userID").(uint),
+	}
+	if course.Title == "" {
+		course.Title = playlist.Title
+	}
+	course.Title = truncateRunes(course.Title, 200)
+	if course.Description == "" {
+		course.Description = "Imported from the YouTube playlist \"" + playlist.Title + "\""
+	}
+	if course.Level == "" {
+		course.Level = "beginner"
+	}
+
+	perModule := input.LessonsPerModule
+	if perModule == 0 {
+		perModule = len(playlist.Videos)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&course).Error; err != nil {
+			return err
+		}
+		for start := 0; start < len(playlist.Videos); start += perModule {
+			end := start + perModule
+			if end > len(playlist.Videos) {
+				end = len(playlist.Videos)
+			}
+			module := models.Module{
+				Title:      truncateRunes(playlist.Title, 200),
+				OrderIndex: start / perModule,
+				CourseID:   course.ID,
+			}
+			if perModule < len(playlist.Videos) {
+				module.Title = fmt.Sprintf("Part %d", start/perModule+1)
+			}
+			if err := tx.Create(&module).Error; err != nil {
+				return err
+			}
+
+			lessons := make([]models.Lesson, 0, end-start)
+			for i, video := range playlist.Videos[start:end] {
+				minutes := int((video.Duration.Seconds() + 59) / 60)
+				lessons = append(lessons, models.Lesson{
+					Title:      truncateRunes(video.Title, 200),
+					Content:    strings.TrimSpace(video.Description),
+					VideoURL:   video.EmbedURL(),
+					Duration:   minutes,
+					OrderIndex: i,
+					ModuleID:   module.ID,
+					Type:       models.LessonTypeStandard,
+				})
+			}
+			if err := tx.Create(&lessons).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create course").Wrap(err))
+		return
+	}
+	refreshAccessibilityScore(db, course.ID)
+	refreshCourseWorkload(db, course.ID)
+
+	db.Preload("Modules", func(db *gorm.DB) *gorm.DB { return db.Order("order_index") }).
+		Preload("Modules.Lessons", func(db *gorm.DB) *gorm.DB { return db.Order("order_index") }).
+		First(&course, course.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        i18n.T(i18n.Locale(c), "Draft course created from the playlist, review it before publishing"),
+		"course":         course,
+		"lessons":        len(playlist.Videos),
+		"skipped_videos": playlist.Skipped,
+	})
+}
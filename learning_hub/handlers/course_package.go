@@ -0,0 +1,732 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/sandbox"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Course packages are versioned so older exports keep importing as the format grows
+const (
+	coursePackageFormat  = "learnhub.course"
+	coursePackageVersion = 1
+	MaxCoursePackageSize = 20 << 20
+)
+
+// coursePackage is a course's content in a form independent of this database: modules and lessons
+// carry package-local refs that quizzes and assignments use to say where they belong, and order is
+// the order of the lists
+type coursePackage struct {
+	Format      string              `json:"format"`
+	Version     int                 `json:"version"`
+	ExportedAt  time.Time           `json:"exported_at"`
+	Course      packageCourse       `json:"course"`
+	Modules     []packageModule     `json:"modules"`
+	Quizzes     []packageQuiz       `json:"quizzes"`
+	Assignments []packageAssignment `json:"assignments"`
+	Media       []packageMedia      `json:"media,omitempty"` // in manifest.json in zip packages
+}
+
+type packageCourse struct {
+	Title        string  `json:"title"`
+	Description  string  `json:"description"`
+	Price        float64 `json:"price"`
+	Currency     string  `json:"currency,omitempty"`
+	Category     string  `json:"category"`
+	Level        string  `json:"level"`
+	ImageURL     string  `json:"image_url"`
+	ThumbnailURL string  `json:"thumbnail_url"`
+	ImageAlt     string  `json:"image_alt"`
+}
+
+type packageModule struct {
+	Ref         uint            `json:"ref"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Lessons     []packageLesson `json:"lessons"`
+}
+
+type packageLesson struct {
+	Ref                    uint               `json:"ref"`
+	Title                  string             `json:"title"`
+	Content                string             `json:"content"`
+	ContentFormat          string             `json:"content_format,omitempty"`
+	IsPreview              bool               `json:"is_preview,omitempty"`
+	VideoURL               string             `json:"video_url"`
+	DocumentURL            string             `json:"document_url"`
+	Duration               int                `json:"duration"`
+	CaptionsURL            string             `json:"captions_url"`
+	Transcript             string             `json:"transcript"`
+	Type                   string             `json:"type"`
+	CodeLanguage           string             `json:"code_language,omitempty"`
+	StarterCode            string             `json:"starter_code,omitempty"`
+	CodeTests              []sandbox.TestCase `json:"code_tests,omitempty"`
+	CompletionCriterion    string             `json:"completion_criterion"`
+	CompletionVideoPercent int                `json:"completion_video_percent,omitempty"`
+	Variants               []packageVariant   `json:"variants,omitempty"`
+	Blocks                 []packageBlock     `json:"blocks,omitempty"`
+}
+
+type packageBlock struct {
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Text        string `json:"text"`
+	URL         string `json:"url"`
+	Duration    int    `json:"duration"`
+	CaptionsURL string `json:"captions_url"`
+	QuizRef     *uint  `json:"quiz_ref"`
+}
+
+type packageVariant struct {
+	Language    string `json:"language"`
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	VideoURL    string `json:"video_url"`
+	CaptionsURL string `json:"captions_url"`
+}
+
+type packageQuiz struct {
+	Ref          uint              `json:"ref,omitempty"` // for the quiz blocks showing it
+	Title        string            `json:"title"`
+	Description  string            `json:"description"`
+	Instructions string            `json:"instructions"`
+	ModuleRef    *uint             `json:"module_ref"`
+	LessonRef    *uint             `json:"lesson_ref"`
+	TimeLimit    int               `json:"time_limit"`
+	MaxAttempts  int               `json:"max_attempts"`
+	PassingScore int               `json:"passing_score"`
+	IsPublished  bool              `json:"is_published"`
+	IsRequired   bool              `json:"is_required"`
+	OpensAt      *time.Time        `json:"opens_at"`
+	ClosesAt     *time.Time        `json:"closes_at"`
+	Questions    []packageQuestion `json:"questions"`
+}
+
+type packageQuestion struct {
+	Question      string              `json:"question"`
+	QuestionType  models.QuestionType `json:"question_type"`
+	Options       models.JSON         `json:"options,omitempty"`
+	CorrectAnswer string              `json:"correct_answer"`
+	Points        int                 `json:"points"`
+	Explanation   string              `json:"explanation"`
+	Language      string              `json:"language,omitempty"`
+}
+
+type packageAssignment struct {
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Instructions string     `json:"instructions"`
+	ModuleRef    *uint      `json:"module_ref"`
+	DueDate      *time.Time `json:"due_date"`
+	MaxPoints    int        `json:"max_points"`
+	IsPublished  bool       `json:"is_published"`
+}
+
+// packageMedia is a file the course links to. Files uploaded here carry what is known about them, so
+// they can be copied to the other environment and checked there.
+type packageMedia struct {
+	URL         string   `json:"url"`
+	UsedBy      []string `json:"used_by"` // e.g. "lesson 4 video_url"
+	Uploaded    bool     `json:"uploaded"`
+	FileName    string   `json:"file_name,omitempty"`
+	ContentType string   `json:"content_type,omitempty"`
+	Size        int64    `json:"size,omitempty"`
+	Checksum    string   `json:"checksum,omitempty"` // SHA-256, hex
+}
+
+// buildCoursePackage describes the course and its content; lesson and module refs are their IDs here
+func buildCoursePackage(db *gorm.DB, course models.Course, content courseContent) (coursePackage, error) {
+	pkg := coursePackage{
+		Format:     coursePackageFormat,
+		Version:    coursePackageVersion,
+		ExportedAt: clock.Now(),
+		Course: packageCourse{
+			Title:        course.Title,
+			Description:  course.Description,
+			Price:        course.Price,
+			Currency:     course.Currency,
+			Category:     course.Category,
+			Level:        course.Level,
+			ImageURL:     course.ImageURL,
+			ThumbnailURL: course.ThumbnailURL,
+			ImageAlt:     course.ImageAlt,
+		},
+		Modules:     []packageModule{},
+		Quizzes:     []packageQuiz{},
+		Assignments: []packageAssignment{},
+	}
+
+	media := map[string][]string{}
+	use := func(url, where string) {
+		if url != "" {
+			media[url] = append(media[url], where)
+		}
+	}
+	use(course.ImageURL, "course image_url")
+	use(course.ThumbnailURL, "course thumbnail_url")
+	quizzes := map[uint]bool{}
+	for _, quiz := range content.Quizzes {
+		quizzes[quiz.ID] = true
+	}
+	for _, module := range content.Modules {
+		out := packageModule{Ref: module.ID, Title: module.Title, Description: module.Description, Lessons: []packageLesson{}}
+		for _, lesson := range module.Lessons {
+			where := fmt.Sprintf("lesson %d ", lesson.ID)
+			use(lesson.VideoURL, where+"video_url")
+			use(lesson.DocumentURL, where+"document_url")
+			use(lesson.CaptionsURL, where+"captions_url")
+			item := packageLesson{
+				Ref:                    lesson.ID,
+				Title:                  lesson.Title,
+				Content:                lesson.Content,
+				ContentFormat:          lesson.ContentFormat,
+				IsPreview:              lesson.IsPreview,
+				VideoURL:               lesson.VideoURL,
+				DocumentURL:            lesson.DocumentURL,
+				Duration:               lesson.Duration,
+				CaptionsURL:            lesson.CaptionsURL,
+				Transcript:             lesson.Transcript,
+				Type:                   lesson.Type,
+				CodeLanguage:           lesson.CodeLanguage,
+				StarterCode:            lesson.StarterCode,
+				CodeTests:              codeTests(lesson),
+				CompletionCriterion:    lesson.CompletionCriterion,
+				CompletionVideoPercent: lesson.CompletionVideoPercent,
+			}
+			for i, block := range lesson.Blocks {
+				if block.QuizID != nil && !quizzes[*block.QuizID] {
+					continue // the quiz is in the trash
+				}
+				blockWhere := fmt.Sprintf("%sblock %d ", where, i+1)
+				use(block.URL, blockWhere+"url")
+				use(block.CaptionsURL, blockWhere+"captions_url")
+				item.Blocks = append(item.Blocks, packageBlock{
+					Type:        block.Type,
+					Title:       block.Title,
+					Text:        block.Text,
+					URL:         block.URL,
+					Duration:    block.Duration,
+					CaptionsURL: block.CaptionsURL,
+					QuizRef:     block.QuizID,
+				})
+			}
+			for _, variant := range lesson.Variants {
+				use(variant.VideoURL, where+variant.Language+" video_url")
+				use(variant.CaptionsURL, where+variant.Language+" captions_url")
+				item.Variants = append(item.Variants, packageVariant{
+					Language:    variant.Language,
+					Title:       variant.Title,
+					Content:     variant.Content,
+					VideoURL:    variant.VideoURL,
+					CaptionsURL: variant.CaptionsURL,
+				})
+			}
+			out.Lessons = append(out.Lessons, item)
+		}
+		pkg.Modules = append(pkg.Modules, out)
+	}
+	for _, quiz := range content.Quizzes {
+		item := packageQuiz{
+			Ref:          quiz.ID,
+			Title:        quiz.Title,
+			Description:  quiz.Description,
+			Instructions: quiz.Instructions,
+			ModuleRef:    quiz.ModuleID,
+			LessonRef:    quiz.LessonID,
+			TimeLimit:    quiz.TimeLimit,
+			MaxAttempts:  quiz.MaxAttempts,
+			PassingScore: quiz.PassingScore,
+			IsPublished:  quiz.IsPublished,
+			IsRequired:   quiz.IsRequired,
+			OpensAt:      quiz.OpensAt,
+			ClosesAt:     quiz.ClosesAt,
+			Questions:    []packageQuestion{},
+		}
+		for _, question := range quiz.Questions {
+			item.Questions = append(item.Questions, packageQuestion{
+				Question:      question.Question,
+				QuestionType:  question.QuestionType,
+				Options:       question.Options,
+				CorrectAnswer: question.CorrectAnswer,
+				Points:        question.Points,
+				Explanation:   question.Explanation,
+				Language:      question.Language,
+			})
+		}
+		pkg.Quizzes = append(pkg.Quizzes, item)
+	}
+	for _, assignment := range content.Assignments {
+		item := packageAssignment{
+			Title:        assignment.Title,
+			Description:  assignment.Description,
+			Instructions: assignment.Instructions,
+			ModuleRef:    assignment.ModuleID,
+			MaxPoints:    assignment.MaxPoints,
+			IsPublished:  assignment.IsPublished,
+		}
+		if !assignment.DueDate.IsZero() {
+			due := assignment.DueDate
+			item.DueDate = &due
+		}
+		pkg.Assignments = append(pkg.Assignments, item)
+	}
+
+	urls := make([]string, 0, len(media))
+	for url := range media {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	var uploads []models.UploadedFile
+	if len(urls) > 0 {
+		if err := db.Where("file_url IN ?", urls).Find(&uploads).Error; err != nil {
+			return pkg, err
+		}
+	}
+	uploaded := map[string]models.UploadedFile{}
+	for _, file := range uploads {
+		uploaded[file.FileURL] = file
+	}
+	for _, url := range urls {
+		item := packageMedia{URL: url, UsedBy: media[url]}
+		if file, ok := uploaded[url]; ok {
+			item.Uploaded = true
+			item.FileName, item.ContentType = file.OriginalName, file.ContentType
+			item.Size, item.Checksum = file.Size, file.Checksum
+		}
+		pkg.Media = append(pkg.Media, item)
+	}
+	return pkg, nil
+}
+
+// ExportCourse downloads a course the caller manages as a package another LearnHub can import: a zip
+// with course.json (the course, its modules, lessons, quizzes with their answers and assignments) and
+// manifest.json (the media files it links to), or one JSON document with ?format=json. Students and
+// their work aren't part of it.
+func (h *CourseImportHandler) ExportCourse(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	if !canManageCourse(c, db, course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to export this course"))
+		return
+	}
+	content, err := loadCourseContent(db, course.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to load the course content"))
+		return
+	}
+	pkg, err := buildCoursePackage(db, course, content)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to export the course"))
+		return
+	}
+
+	filename := fmt.Sprintf("course-%d-%s", course.ID, clock.Now().Format("2006-01-02"))
+	if c.Query("format") == "json" {
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		c.JSON(http.StatusOK, pkg)
+		return
+	}
+
+	manifest := gin.H{
+		"format":      pkg.Format,
+		"version":     pkg.Version,
+		"exported_at": pkg.ExportedAt,
+		"media":       pkg.Media,
+	}
+	pkg.Media = nil
+	var out bytes.Buffer
+	archive := zip.NewWriter(&out)
+	files := []struct {
+		name string
+		data interface{}
+	}{{"course.json", pkg}, {"manifest.json", manifest}}
+	for _, file := range files {
+		w, err := archive.Create(file.name)
+		if err == nil {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(file.data)
+		}
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to export the course"))
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to export the course"))
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	c.Data(http.StatusOK, "application/zip", out.Bytes())
+}
+
+// readCoursePackage reads a package sent as JSON, as a zip body (application/zip) or as the file field
+// of a form holding either
+func readCoursePackage(c *gin.Context) (coursePackage, error) {
+	var pkg coursePackage
+	var body io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return pkg, errors.New("upload the package as the file field")
+		}
+		file, err := header.Open()
+		if err != nil {
+			return pkg, err
+		}
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(io.LimitReader(body, MaxCoursePackageSize+1))
+	if err != nil {
+		return pkg, err
+	}
+	if len(data) > MaxCoursePackageSize {
+		return pkg, fmt.Errorf("package is larger than %d MB", MaxCoursePackageSize>>20)
+	}
+
+	// Zip archives start with "PK"
+	if bytes.HasPrefix(data, []byte("PK")) {
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return pkg, errors.New("package is not a valid zip file")
+		}
+		var found *zip.File
+		for _, file := range archive.File {
+			if file.Name == "course.json" || strings.HasSuffix(file.Name, "/course.json") {
+				found = file
+				break
+			}
+		}
+		if found == nil {
+			return pkg, errors.New("package has no course.json")
+		}
+		r, err := found.Open()
+		if err != nil {
+			return pkg, err
+		}
+		defer r.Close()
+		data, err = io.ReadAll(io.LimitReader(r, MaxCoursePackageSize+1))
+		if err != nil {
+			return pkg, err
+		}
+		if len(data) > MaxCoursePackageSize {
+			return pkg, fmt.Errorf("course.json is larger than %d MB", MaxCoursePackageSize>>20)
+		}
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return pkg, errors.New("package is not valid JSON: " + err.Error())
+	}
+	if pkg.Format != coursePackageFormat {
+		return pkg, fmt.Errorf("format must be %s", coursePackageFormat)
+	}
+	if pkg.Version < 1 || pkg.Version > coursePackageVersion {
+		return pkg, fmt.Errorf("package version %d is not supported, this server reads up to %d", pkg.Version, coursePackageVersion)
+	}
+	return pkg, nil
+}
+
+// packageContent checks the package's content and turns it into what createCourseContent copies, with
+// the refs standing in for IDs
+func packageContent(pkg coursePackage) (courseContent, error) {
+	var content courseContent
+	modules := map[uint]bool{}
+	lessons := map[uint]bool{}
+	quizzes := map[uint]bool{}
+	for i, quiz := range pkg.Quizzes {
+		if quiz.Ref != 0 {
+			if quizzes[quiz.Ref] {
+				return content, fmt.Errorf("quiz %d needs a unique ref", i+1)
+			}
+			quizzes[quiz.Ref] = true
+		}
+	}
+	for i, source := range pkg.Modules {
+		if source.Ref == 0 || modules[source.Ref] {
+			return content, fmt.Errorf("module %d needs a unique ref", i+1)
+		}
+		modules[source.Ref] = true
+		if strings.TrimSpace(source.Title) == "" {
+			return content, fmt.Errorf("module %d needs a title", i+1)
+		}
+		module := models.Module{Title: truncateRunes(source.Title, 200), Description: source.Description, OrderIndex: i}
+		module.ID = source.Ref
+
+		for j, sourceLesson := range source.Lessons {
+			where := fmt.Sprintf("module %d, lesson %d", i+1, j+1)
+			if sourceLesson.Ref == 0 || lessons[sourceLesson.Ref] {
+				return content, fmt.Errorf("%s needs a unique ref", where)
+			}
+			lessons[sourceLesson.Ref] = true
+			if strings.TrimSpace(sourceLesson.Title) == "" {
+				return content, fmt.Errorf("%s needs a title", where)
+			}
+			lesson := models.Lesson{
+				Title:       truncateRunes(sourceLesson.Title, 200),
+				Content:     sourceLesson.Content,
+				VideoURL:    truncate(sourceLesson.VideoURL, 500),
+				DocumentURL: truncate(sourceLesson.DocumentURL, 500),
+				Duration:    sourceLesson.Duration,
+				OrderIndex:  j,
+				CaptionsURL: truncate(sourceLesson.CaptionsURL, 500),
+				Transcript:  sourceLesson.Transcript,
+
+				ContentFormat: sourceLesson.ContentFormat,
+				IsPreview:     sourceLesson.IsPreview,
+			}
+			lesson.ID = sourceLesson.Ref
+			switch lesson.ContentFormat {
+			case "":
+				lesson.ContentFormat = models.ContentFormatMarkdown
+			case models.ContentFormatMarkdown, models.ContentFormatHTML:
+			default:
+				return content, fmt.Errorf("%s: content_format must be %s or %s", where,
+					models.ContentFormatMarkdown, models.ContentFormatHTML)
+			}
+			// Packages don't carry SCORM files, those lessons need theirs uploaded again
+			if sourceLesson.Type == models.LessonTypeScorm {
+				sourceLesson.Type = models.LessonTypeStandard
+			}
+			tests := sourceLesson.CodeTests
+			if tests == nil {
+				tests = []sandbox.TestCase{}
+			}
+			if err := applyCodeLesson(&lesson, codeLessonInput{
+				Type:         sourceLesson.Type,
+				CodeLanguage: sourceLesson.CodeLanguage,
+				StarterCode:  sourceLesson.StarterCode,
+				CodeTests:    &tests,
+			}); err != nil {
+				return content, fmt.Errorf("%s: %v", where, err)
+			}
+			if err := applyCompletion(&lesson, completionInput{
+				CompletionCriterion:    sourceLesson.CompletionCriterion,
+				CompletionVideoPercent: sourceLesson.CompletionVideoPercent,
+			}); err != nil {
+				return content, fmt.Errorf("%s: %v", where, err)
+			}
+			languages := map[string]bool{}
+			for _, variant := range sourceLesson.Variants {
+				language := strings.TrimSpace(variant.Language)
+				if language == "" || len(language) > 16 || languages[language] {
+					return content, fmt.Errorf("%s: variants need a unique language", where)
+				}
+				languages[language] = true
+				lesson.Variants = append(lesson.Variants, models.LessonVariant{
+					Language:    language,
+					Title:       truncateRunes(variant.Title, 200),
+					Content:     variant.Content,
+					VideoURL:    truncate(variant.VideoURL, 500),
+					CaptionsURL: truncate(variant.CaptionsURL, 500),
+				})
+			}
+			for k, source := range sourceLesson.Blocks {
+				block := models.LessonBlock{
+					Type:        source.Type,
+					OrderIndex:  k,
+					Title:       strings.TrimSpace(source.Title),
+					Text:        source.Text,
+					URL:         source.URL,
+					Duration:    source.Duration,
+					CaptionsURL: source.CaptionsURL,
+					QuizID:      source.QuizRef,
+				}
+				if err := checkBlockFields(&block); err != nil {
+					return content, fmt.Errorf("%s, block %d: %v", where, k+1, err)
+				}
+				if block.QuizID != nil && !quizzes[*block.QuizID] {
+					return content, fmt.Errorf("%s, block %d refers to a quiz the package doesn't have", where, k+1)
+				}
+				lesson.Blocks = append(lesson.Blocks, block)
+			}
+			module.Lessons = append(module.Lessons, lesson)
+		}
+		content.Modules = append(content.Modules, module)
+	}
+
+	// Refs to modules or lessons the package doesn't have are refused rather than dropped
+	placed := func(refs map[uint]bool, ref *uint) bool {
+		return ref == nil || refs[*ref]
+	}
+	for i, source := range pkg.Quizzes {
+		if strings.TrimSpace(source.Title) == "" {
+			return content, fmt.Errorf("quiz %d needs a title", i+1)
+		}
+		if !placed(modules, source.ModuleRef) || !placed(lessons, source.LessonRef) {
+			return content, fmt.Errorf("quiz %d refers to a module or lesson the package doesn't have", i+1)
+		}
+		quiz := models.Quiz{
+			Title:        truncateRunes(source.Title, 200),
+			Description:  source.Description,
+			Instructions: source.Instructions,
+			ModuleID:     source.ModuleRef,
+			LessonID:     source.LessonRef,
+			TimeLimit:    source.TimeLimit,
+			MaxAttempts:  source.MaxAttempts,
+			PassingScore: source.PassingScore,
+			IsPublished:  source.IsPublished,
+			IsRequired:   source.IsRequired,
+			OpensAt:      source.OpensAt,
+			ClosesAt:     source.ClosesAt,
+		}
+		quiz.ID = source.Ref
+		if quiz.MaxAttempts == 0 {
+			quiz.MaxAttempts = 1
+		}
+		if quiz.PassingScore == 0 {
+			quiz.PassingScore = 70
+		}
+		for j, sourceQuestion := range source.Questions {
+			switch sourceQuestion.QuestionType {
+			case models.QuestionTypeMultipleChoice, models.QuestionTypeTrueFalse,
+				models.QuestionTypeShortAnswer, models.QuestionTypeCoding:
+			default:
+				return content, fmt.Errorf("quiz %d, question %d has an unknown question_type", i+1, j+1)
+			}
+			if strings.TrimSpace(sourceQuestion.Question) == "" {
+				return content, fmt.Errorf("quiz %d, question %d needs a question", i+1, j+1)
+			}
+			question := models.QuizQuestion{
+				Question:      sourceQuestion.Question,
+				QuestionType:  sourceQuestion.QuestionType,
+				CorrectAnswer: sourceQuestion.CorrectAnswer,
+				Points:        sourceQuestion.Points,
+				Explanation:   sourceQuestion.Explanation,
+				OrderIndex:    j,
+			}
+			if string(sourceQuestion.Options) != "null" {
+				question.Options = sourceQuestion.Options
+			}
+			if question.QuestionType == models.QuestionTypeCoding {
+				question.Language = strings.ToLower(strings.TrimSpace(sourceQuestion.Language))
+			}
+			if question.Points == 0 {
+				question.Points = 1
+			}
+			quiz.Questions = append(quiz.Questions, question)
+		}
+		content.Quizzes = append(content.Quizzes, quiz)
+	}
+	for i, source := range pkg.Assignments {
+		if strings.TrimSpace(source.Title) == "" {
+			return content, fmt.Errorf("assignment %d needs a title", i+1)
+		}
+		if !placed(modules, source.ModuleRef) {
+			return content, fmt.Errorf("assignment %d refers to a module the package doesn't have", i+1)
+		}
+		assignment := models.Assignment{
+			Title:        truncateRunes(source.Title, 200),
+			Description:  source.Description,
+			Instructions: source.Instructions,
+			ModuleID:     source.ModuleRef,
+			MaxPoints:    source.MaxPoints,
+			IsPublished:  source.IsPublished,
+		}
+		if source.DueDate != nil {
+			assignment.DueDate = *source.DueDate
+		}
+		if assignment.MaxPoints == 0 {
+			assignment.MaxPoints = 100
+		}
+		content.Assignments = append(content.Assignments, assignment)
+	}
+	return content, nil
+}
+
+// ImportCourse creates a draft course owned by the caller from an exported package (see ExportCourse).
+// Media keep the URLs they had: files uploaded to the exporting server have to be copied over, as
+// listed in the package's manifest.
+func (h *CourseImportHandler) ImportCourse(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	pkg, err := readCoursePackage(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	content, err := packageContent(pkg)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	course := models.Course{
+		Title:        truncateRunes(strings.TrimSpace(pkg.Course.Title), 200),
+		Description:  pkg.Course.Description,
+		Price:        pkg.Course.Price,
+		Currency:     pkg.Course.Currency,
+		Category:     truncateRunes(pkg.Course.Category, 100),
+		Level:        pkg.Course.Level,
+		ImageURL:     truncate(pkg.Course.ImageURL, 500),
+		ThumbnailURL: truncate(pkg.Course.ThumbnailURL, 500),
+		ImageAlt:     truncateRunes(pkg.Course.ImageAlt, 300),
+		Published:    false,
+		InstructorID: c.MustGet("userID").(uint),
+	}
+	if course.Title == "" {
+		apierror.Abort(c, apierror.BadRequest("The package's course needs a title"))
+		return
+	}
+	if course.Price < 0 {
+		apierror.Abort(c, apierror.BadRequest("The package's course price can't be negative"))
+		return
+	}
+	if course.Currency != "" {
+		if course.Currency, err = checkPaymentCurrency(course.Currency); err != nil {
+			apierror.Abort(c, apierror.BadRequest("The package's course "+err.Error()))
+			return
+		}
+	}
+	switch course.Level {
+	case "beginner", "intermediate", "advanced":
+	default:
+		course.Level = "beginner"
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&course).Error; err != nil {
+			return err
+		}
+		_, err := createCourseContent(tx, course.ID, content, 0)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to import the course").Wrap(err))
+		return
+	}
+	refreshAccessibilityScore(db, course.ID)
+	refreshCourseWorkload(db, course.ID)
+
+	lessons := 0
+	for _, module := range content.Modules {
+		lessons += len(module.Lessons)
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Draft course imported, review it before publishing"),
+		"course":  course,
+		"imported": gin.H{
+			"modules":     len(content.Modules),
+			"lessons":     lessons,
+			"quizzes":     len(content.Quizzes),
+			"assignments": len(content.Assignments),
+		},
+	})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isOpenPreview reports whether anyone may see the lesson and its media: a preview lesson of a published
+// course. The lesson needs its Module.Course loaded.
+func isOpenPreview(lesson models.Lesson) bool {
+	return lesson.IsPreview && lesson.Module.Course.Published
+}
+
+// showLessonsTo prepares the loaded lessons of a course for a caller not on its staff. Their content is
+// given as sanitized content_html; lessons other than open previews keep only their outline, without
+// content, transcript or media, unless the caller is enrolled.
+func showLessonsTo(course *models.Course, enrolled bool, userID uint) {
+	for i := range course.Modules {
+		for j := range course.Modules[i].Lessons {
+			lesson := &course.Modules[i].Lessons[j]
+			if !enrolled && !(lesson.IsPreview && course.Published) {
+				lesson.Content, lesson.Transcript = "", ""
+				lesson.VideoURL, lesson.DocumentURL, lesson.CaptionsURL = "", "", ""
+				continue
+			}
+			lesson.ContentHTML = renderContent(lesson.Content, lesson.ContentFormat, userID)
+			lesson.Content = ""
+		}
+	}
+}
+
+// previewLesson is a preview lesson as shown to prospective students
+type previewLesson struct {
+	ID               uint   `json:"id"`
+	ModuleID         uint   `json:"module_id"`
+	ModuleTitle      string `json:"module_title"`
+	Title            string `json:"title"`
+	Type             string `json:"type"`
+	ContentHTML      string `json:"content_html"`
+	VideoURL         string `json:"video_url"`
+	CaptionsURL      string `json:"captions_url"`
+	Transcript       string `json:"transcript"`
+	Duration         int    `json:"duration"`
+	EstimatedMinutes int    `json:"estimated_minutes"`
+}
+
+// GetCoursePreview returns the preview lessons of a published course, in course order, so anyone can
+// sample it before enrolling. Their videos play without enrollment.
+func (h *CourseHandler) GetCoursePreview(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	if !canTeachCourse(c, db, course) {
+		if !course.Published {
+			apierror.Abort(c, apierror.NotFound("Course not found"))
+			return
+		}
+		if _, available, err := coursePriceIn(db, course, requestCountry(c, db)); err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to fetch course price").Wrap(err))
+			return
+		} else if !available {
+			apierror.Abort(c, apierror.NotFound("Course not found: not available in your country"))
+			return
+		}
+	}
+
+	var lessons []models.Lesson
+	if err := db.Preload("Module").
+		Joins("JOIN modules ON modules.id = lessons.module_id AND modules.deleted_at IS NULL").
+		Where("modules.course_id = ? AND lessons.is_preview = ?", course.ID, true).
+		Order("modules.order_index, modules.id, lessons.order_index, lessons.id").
+		Find(&lessons).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch preview lessons"))
+		return
+	}
+
+	userID := c.GetUint("userID")
+	previews := make([]previewLesson, 0, len(lessons))
+	for _, lesson := range lessons {
+		previews = append(previews, previewLesson{
+			ID:               lesson.ID,
+			ModuleID:         lesson.ModuleID,
+			ModuleTitle:      lesson.Module.Title,
+			Title:            lesson.Title,
+			Type:             lesson.Type,
+			ContentHTML:      renderContent(lesson.Content, lesson.ContentFormat, userID),
+			VideoURL:         lesson.VideoURL,
+			CaptionsURL:      lesson.CaptionsURL,
+			Transcript:       lesson.Transcript,
+			Duration:         lesson.Duration,
+			EstimatedMinutes: lesson.EstimatedMinutes,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"course_id": course.ID,
+		"title":     course.Title,
+		"lessons":   previews,
+		"count":     len(previews),
+	})
+}
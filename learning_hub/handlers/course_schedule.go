@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Instructors are told this long before their course is unpublished
+const unpublishNoticeLead = 7 * 24 * time.Hour
+
+type CourseScheduleHandler struct {
+	DB *gorm.DB
+}
+
+func NewCourseScheduleHandler(db *gorm.DB) *CourseScheduleHandler {
+	return &CourseScheduleHandler{DB: db}
+}
+
+// SetUnpublishDate schedules the course to leave the catalog, e.g. an exam-prep course after exam season
+// ({"unpublish_at": "2026-06-30T00:00:00Z"}, null clears it). Enrolled students keep their access.
+func (h *CourseScheduleHandler) SetUnpublishDate(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		UnpublishAt *time.Time `json:"unpublish_at"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if input.UnpublishAt != nil && !input.UnpublishAt.After(clock.Now()) {
+		apierror.Abort(c, apierror.BadRequest("unpublish_at must be in the future"))
+		return
+	}
+
+	// A new date gets a new notice
+	if err := db.Model(&course).Updates(map[string]interface{}{
+		"unpublish_at":             input.UnpublishAt,
+		"unpublish_notice_sent_at": nil,
+	}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to schedule unpublishing"))
+		return
+	}
+	course.UnpublishAt = input.UnpublishAt
+
+	message := "Unpublishing scheduled"
+	if input.UnpublishAt == nil {
+		message = "Scheduled unpublishing cleared"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), message),
+		"course":  course,
+	})
+}
+
+// UnpublishScheduledCourses warns instructors of courses leaving the catalog within a week and
+// unpublishes the courses whose date has come; meant to run as a background job
+func (h *CourseScheduleHandler) UnpublishScheduledCourses(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	now := clock.Now()
+
+	var upcoming []models.Course
+	if err := db.Preload("Instructor").
+		Where("published = ? AND unpublish_notice_sent_at IS NULL AND unpublish_at > ? AND unpublish_at <= ?",
+			true, now, now.Add(unpublishNoticeLead)).
+		Find(&upcoming).Error; err != nil {
+		return err
+	}
+	for _, course := range upcoming {
+		// Claim the course first so a slow run can't warn twice
+		result := db.Model(&models.Course{}).Where("id = ? AND unpublish_notice_sent_at IS NULL", course.ID).
+			Update("unpublish_notice_sent_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		notifyUser(db, course.InstructorID, models.NotificationUnpublish,
+			fmt.Sprintf("%s leaves the catalog on %s", course.Title, course.UnpublishAt.UTC().Format("January 2")),
+			gin.H{"course_id": course.ID, "unpublish_at": course.UnpublishAt})
+		if err := email.SendCourseUnpublishEmail(course.Instructor.Email, course.Instructor.FirstName,
+			course.Title, course.ID, *course.UnpublishAt); err != nil {
+			slog.Error("Failed to email unpublish notice", "course_id", course.ID, "error", err)
+		}
+	}
+
+	var due []models.Course
+	if err := db.Where("published = ? AND unpublish_at <= ?", true, now).
+		Find(&due).Error; err != nil {
+		return err
+	}
+	for _, course := range due {
+		// The date is cleared so republishing the course doesn't unpublish it again
+		if err := db.Model(&course).Updates(map[string]interface{}{
+			"published":                false,
+			"unpublish_at":             nil,
+			"unpublish_notice_sent_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		notifyUser(db, course.InstructorID, models.NotificationUnpublish, course.Title+" was unpublished as scheduled",
+			gin.H{"course_id": course.ID, "unpublished_at": now})
+		slog.Info("Unpublished course as scheduled", "course_id", course.ID)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/i18n"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CourseStaffHandler manages the co-instructors and teaching assistants of courses
+type CourseStaffHandler struct {
+	DB *gorm.DB
+}
+
+func NewCourseStaffHandler(db *gorm.DB) *CourseStaffHandler {
+	return &CourseStaffHandler{DB: db}
+}
+
+// courseStaffRole returns the caller's staff role on the course, or "" when they aren't on its staff. The
+// answer is kept on the request, which often checks the same course more than once.
+func courseStaffRole(c *gin.Context, db *gorm.DB, courseID uint) string {
+	if courseID == 0 {
+		return ""
+	}
+	userID, exists := c.Get("userID")
+	if !exists {
+		return ""
+	}
+	key := fmt.Sprintf("courseStaff.%d", courseID)
+	if role, ok := c.Get(key); ok {
+		return role.(string)
+	}
+	var staff models.CourseStaff
+	role := ""
+	if err := db.Select("role").Where("course_id = ? AND user_id = ?", courseID, userID).First(&staff).Error; err == nil {
+		role = staff.Role
+	}
+	c.Set(key, role)
+	return role
+}
+
+// loadTaughtCourse loads the :id course if the caller is on its staff (see canTeachCourse)
+func loadTaughtCourse(c *gin.Context, db *gorm.DB) (models.Course, bool) {
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return course, false
+	}
+	if !canTeachCourse(c, db, course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to view this course"))
+		return course, false
+	}
+	return course, true
+}
+
+func validStaffRole(role string) bool {
+	return role == models.StaffCoInstructor || role == models.StaffTA
+}
+
+// GetCourseStaff lists the course's instructor and staff, for anyone on it
+func (h *CourseStaffHandler) GetCourseStaff(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadTaughtCourse(c, db)
+	if !ok {
+		return
+	}
+	var owner models.User
+	db.Select("id, first_name, last_name, email").First(&owner, course.InstructorID)
+	var staff []models.CourseStaff
+	if err := db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, first_name, last_name, email")
+	}).Where("course_id = ?", course.ID).Order("created_at").Find(&staff).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch course staff"))
+		return
+	}
+
+	members := []gin.H{{
+		"user_id":    owner.ID,
+		"first_name": owner.FirstName,
+		"last_name":  owner.LastName,
+		"email":      owner.Email,
+		"role":       "instructor",
+	}}
+	for _, member := range staff {
+		members = append(members, gin.H{
+			"user_id":    member.UserID,
+			"first_name": member.User.FirstName,
+			"last_name":  member.User.LastName,
+			"email":      member.User.Email,
+			"role":       member.Role,
+			"added_at":   member.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"staff": members})
+}
+
+// AddCourseStaff adds an instructor to the course's staff ({"email", "role"}: co_instructor or ta). Only
+// the course's instructor and admins can change its staff.
+func (h *CourseStaffHandler) AddCourseStaff(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Email string `json:"email" binding:"required"`
+		Role  string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("email and role are required"))
+		return
+	}
+	if !validStaffRole(input.Role) {
+		apierror.Abort(c, apierror.BadRequest("Role must be co_instructor or ta"))
+		return
+	}
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	if !ownsCourse(c, course) {
+		apierror.Abort(c, apierror.Forbidden("Only the course's instructor can change its staff"))
+		return
+	}
+
+	var user models.User
+	if err := db.Where("LOWER(email) = ?", strings.ToLower(strings.TrimSpace(input.Email))).First(&user).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("No user has this email address"))
+		return
+	}
+	// Staff work through the instructor endpoints, which need an instructor account
+	if user.Role != "instructor" {
+		apierror.Abort(c, apierror.BadRequest("Only instructors can join a course's staff; an admin can change the user's role"))
+		return
+	}
+	if user.ID == course.InstructorID {
+		apierror.Abort(c, apierror.BadRequest("This is the course's instructor"))
+		return
+	}
+	var existing int64
+	db.Model(&models.CourseStaff{}).Where("course_id = ? AND user_id = ?", course.ID, user.ID).Count(&existing)
+	if existing > 0 {
+		apierror.Abort(c, apierror.Conflict("This user is already on the course's staff"))
+		return
+	}
+
+	staff := models.CourseStaff{
+		CourseID:  course.ID,
+		UserID:    user.ID,
+		Role:      input.Role,
+		AddedByID: c.MustGet("userID").(uint),
+	}
+	if err := db.Create(&staff).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to add staff member"))
+		return
+	}
+	notifyUser(db, user.ID, models.NotificationCourseStaff, "You were added to the staff of "+course.Title, gin.H{
+		"course_id": course.ID,
+		"role":      staff.Role,
+	})
+	c.JSON(http.StatusCreated, gin.H{"staff": staff})
+}
+
+// loadStaffMember loads the :userId staff member of the :id course for its instructor
+func (h *CourseStaffHandler) loadStaffMember(c *gin.Context, allowSelf bool) (models.CourseStaff, bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	var staff models.CourseStaff
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return staff, false
+	}
+	err := db.Where("course_id = ? AND user_id = ?", course.ID, c.Param("userId")).First(&staff).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Abort(c, apierror.NotFound("Staff member not found"))
+		return staff, false
+	} else if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch staff member"))
+		return staff, false
+	}
+	self := allowSelf && staff.UserID == c.MustGet("userID").(uint)
+	if !self && !ownsCourse(c, course) {
+		apierror.Abort(c, apierror.Forbidden("Only the course's instructor can change its staff"))
+		return staff, false
+	}
+	return staff, true
+}
+
+// UpdateCourseStaff changes a staff member's role ({"role"})
+func (h *CourseStaffHandler) UpdateCourseStaff(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || !validStaffRole(input.Role) {
+		apierror.Abort(c, apierror.BadRequest("Role must be co_instructor or ta"))
+		return
+	}
+	staff, ok := h.loadStaffMember(c, false)
+	if !ok {
+		return
+	}
+	if err := db.Model(&staff).Update("role", input.Role).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update staff member"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"staff": staff})
+}
+
+// RemoveCourseStaff takes someone off the course's staff; staff members may also leave on their own
+func (h *CourseStaffHandler) RemoveCourseStaff(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	staff, ok := h.loadStaffMember(c, true)
+	if !ok {
+		return
+	}
+	if err := db.Delete(&staff).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to remove staff member"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Staff member removed")})
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Clients send a stable browser or device fingerprint (e.g. a FingerprintJS visitor ID) in this header
+const deviceFingerprintHeader = "X-Device-Fingerprint"
+
+// Correlation rules: a device is flagged when more distinct accounts than allowed registered
+// or checked out from it within deviceWindow
+const (
+	deviceWindow              = 30 * 24 * time.Hour
+	deviceMaxAccounts         = 3
+	deviceMaxCheckoutAccounts = 3
+)
+
+// deviceHash hashes the request's fingerprint; empty when the client sent none
+func deviceHash(c *gin.Context) string {
+	raw := strings.TrimSpace(c.GetHeader(deviceFingerprintHeader))
+	if len(raw) < 8 || len(raw) > 512 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// deviceAllowed answers 403 and returns false when the request comes from a blocked device
+func deviceAllowed(c *gin.Context, db *gorm.DB, action string) bool {
+	hash := deviceHash(c)
+	if hash == "" {
+		return true
+	}
+	var blocked int64
+	db.Model(&models.DeviceFingerprint{}).Where("hash = ? AND blocked = ?", hash, true).Count(&blocked)
+	if blocked > 0 {
+		apierror.Abort(c, apierror.Forbidden("This device is not allowed to "+action))
+		return false
+	}
+	return true
+}
+
+// recordDevice logs a registration or checkout with the request's fingerprint and applies the
+// correlation rules. Failures are logged: fingerprinting never fails the action itself.
+func recordDevice(db *gorm.DB, c *gin.Context, userID uint, event string) {
+	hash := deviceHash(c)
+	if hash == "" {
+		return
+	}
+	now := clock.Now()
+	var device models.DeviceFingerprint
+	if err := db.Where(models.DeviceFingerprint{Hash: hash}).Attrs(models.DeviceFingerprint{FirstSeenAt: now}).
+		FirstOrCreate(&device).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to save device fingerprint", "user_id", userID, "error", err)
+		return
+	}
+	db.Model(&device).Update("last_seen_at", now)
+
+	if err := db.Create(&models.DeviceEvent{
+		FingerprintID: device.ID,
+		UserID:        userID,
+		Event:         event,
+		IP:            c.ClientIP(),
+		UserAgent:     c.Request.UserAgent(),
+		CreatedAt:     now,
+	}).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to save device event", "user_id", userID, "error", err)
+		return
+	}
+
+	if err := correlateDevice(db, &device); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to check device", "device_id", device.ID, "error", err)
+	}
+}
+
+// deviceAccounts counts the distinct accounts with the event on the device since the given time
+func deviceAccounts(db *gorm.DB, deviceID uint, event string, since time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&models.DeviceEvent{}).
+		Where("fingerprint_id = ? AND event = ? AND created_at >= ?", deviceID, event, since).
+		Distinct("user_id").Count(&count).Error
+	return count, err
+}
+
+// correlateDevice flags the device when it matches an abuse rule and tells the admins the first time
+func correlateDevice(db *gorm.DB, device *models.DeviceFingerprint) error {
+	since := clock.Now().Add(-deviceWindow)
+	days := int(deviceWindow.Hours() / 24)
+	// Only what happened after an admin looked at the device counts again
+	if device.ReviewedAt != nil && device.ReviewedAt.After(since) {
+		since = *device.ReviewedAt
+	}
+
+	var reasons []string
+	registered, err := deviceAccounts(db, device.ID, models.DeviceEventRegister, since)
+	if err != nil {
+		return err
+	}
+	if registered > deviceMaxAccounts {
+		reasons = append(reasons, fmt.Sprintf("%d accounts registered within %d days", registered, days))
+	}
+	checkedOut, err := deviceAccounts(db, device.ID, models.DeviceEventCheckout, since)
+	if err != nil {
+		return err
+	}
+	if checkedOut > deviceMaxCheckoutAccounts {
+		reasons = append(reasons, fmt.Sprintf("%d accounts checked out within %d days", checkedOut, days))
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	encoded, _ := json.Marshal(reasons)
+	updates := map[string]interface{}{"flag_reasons": models.JSON(encoded)}
+	newlyFlagged := !device.Flagged
+	if newlyFlagged {
+		now := clock.Now()
+		updates["flagged"], updates["flagged_at"] = true, now
+	}
+	if err := db.Model(device).Updates(updates).Error; err != nil {
+		return err
+	}
+	if newlyFlagged {
+		notifyAdmins(db, models.NotificationDeviceFlagged, "A device was flagged for possible account farming", gin.H{
+			"device_id": device.ID,
+			"reasons":   reasons,
+		})
+	}
+	return nil
+}
+
+type DeviceHandler struct {
+	DB *gorm.DB
+}
+
+func NewDeviceHandler(db *gorm.DB) *DeviceHandler {
+	return &DeviceHandler{DB: db}
+}
+
+// GetDevices lists device fingerprints, most recently seen first, with their account counts
+// (?flagged=true, ?blocked=true, ?page=)
+func (h *DeviceHandler) GetDevices(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	const pageSize = 50
+
+	query := db.Model(&models.DeviceFingerprint{})
+	if flagged := c.Query("flagged"); flagged != "" {
+		query = query.Where("flagged = ?", flagged == "true")
+	}
+	if blocked := c.Query("blocked"); blocked != "" {
+		query = query.Where("blocked = ?", blocked == "true")
+	}
+	var total int64
+	query.Count(&total)
+
+	var devices []models.DeviceFingerprint
+	if err := query.Order("last_seen_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&devices).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch devices"))
+		return
+	}
+
+	ids := make([]uint, len(devices))
+	for i, device := range devices {
+		ids[i] = device.ID
+	}
+	var counts []struct {
+		FingerprintID uint
+		Accounts      int64
+	}
+	if len(ids) > 0 {
+		db.Model(&models.DeviceEvent{}).Where("fingerprint_id IN ?", ids).
+			Group("fingerprint_id").Select("fingerprint_id, COUNT(DISTINCT user_id) AS accounts").Scan(&counts)
+	}
+	accounts := make(map[uint]int64, len(counts))
+	for _, row := range counts {
+		accounts[row.FingerprintID] = row.Accounts
+	}
+
+	results := make([]gin.H, len(devices))
+	for i, device := range devices {
+		results[i] = gin.H{"device": device, "accounts": accounts[device.ID]}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"devices": results,
+		"total":   total,
+		"page":    page,
+	})
+}
+
+// GetDevice shows a device with its registrations and checkouts and the accounts behind them
+func (h *DeviceHandler) GetDevice(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var device models.DeviceFingerprint
+	if err := db.First(&device, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Device not found"))
+		return
+	}
+	var events []models.DeviceEvent
+	if err := db.Where("fingerprint_id = ?", device.ID).
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, first_name, last_name, email, role, created_at")
+		}).
+		Order("created_at DESC").Limit(500).Find(&events).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch device events"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"device": device,
+		"events": events,
+	})
+}
+
+// ReviewDevice acts on a device: "block" stops registrations and checkouts from it, "unblock" lifts that,
+// and "dismiss" clears a flag found to be harmless, e.g. a shared family or lab computer
+func (h *DeviceHandler) ReviewDevice(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Action string `json:"action" binding:"required,oneof=block unblock dismiss"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	var device models.DeviceFingerprint
+	if err := db.First(&device, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Device not found"))
+		return
+	}
+
+	now := clock.Now()
+	updates := map[string]interface{}{
+		"reviewed_by_id": c.MustGet("userID").(uint),
+		"reviewed_at":    now,
+	}
+	switch input.Action {
+	case "block":
+		updates["blocked"], updates["block_reason"] = true, strings.TrimSpace(input.Reason)
+	case "unblock":
+		updates["blocked"], updates["block_reason"] = false, ""
+	case "dismiss":
+		updates["flagged"], updates["flagged_at"], updates["flag_reasons"] = false, nil, nil
+	}
+	if err := db.Model(&device).Updates(updates).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update device"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Device updated"),
+		"device":  device,
+	})
+}
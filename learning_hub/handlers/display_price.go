@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/fx"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// displayCurrency returns the currency the caller wants prices shown in: the ?currency= query, else their
+// preferred currency. Empty shows prices in their own currency only.
+func displayCurrency(c *gin.Context, db *gorm.DB) string {
+	if code := strings.ToUpper(strings.TrimSpace(c.Query("currency"))); currencyCodePattern.MatchString(code) {
+		return code
+	}
+	if userID, exists := c.Get("userID"); exists {
+		var user models.User
+		if err := db.Select("id, preferred_currency").First(&user, userID).Error; err == nil {
+			return user.PreferredCurrency
+		}
+	}
+	return ""
+}
+
+// applyDisplayPrice converts a course's price when it isn't priced in the display currency. Conversion is
+// a courtesy: when rates are unavailable the course keeps only its own price, and false is returned.
+func applyDisplayPrice(c *gin.Context, course *models.Course, code string) bool {
+	from := courseCurrency(*course)
+	if code == "" || from == code {
+		return true
+	}
+	price, err := fx.Convert(c.Request.Context(), course.Price, from, code)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to convert course price", "course_id", course.ID, "from", from, "currency", code, "error", err)
+		return false
+	}
+	course.DisplayPrice, course.DisplayCurrency = &price, code
+	return true
+}
+
+// applyDisplayPrices converts the prices of listed courses, giving up at the first failed conversion
+func applyDisplayPrices(c *gin.Context, courses []models.Course, code string) {
+	for i := range courses {
+		if !applyDisplayPrice(c, &courses[i], code) {
+			return
+		}
+	}
+}
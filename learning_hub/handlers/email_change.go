@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/utils"
+	"learning_hub/pkg/validation"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errEmailTaken = errors.New("email address is in use")
+
+// emailTaken reports whether another user has the address
+func emailTaken(db *gorm.DB, address string, userID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.User{}).Where("LOWER(email) = ? AND id <> ?", strings.ToLower(address), userID).Count(&count).Error
+	return count > 0, err
+}
+
+// RequestEmailChange starts changing the caller's address ({"new_email", "password"}): the new address
+// gets a link to confirm it and the current one a notice. The address only changes once confirmed.
+func (h *UserHandler) RequestEmailChange(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		NewEmail string `json:"new_email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	newEmail := strings.TrimSpace(input.NewEmail)
+
+	var user models.User
+	if err := db.First(&user, c.MustGet("userID")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if user.IsTestStudent {
+		apierror.Abort(c, apierror.Forbidden("Test students have no email address to change"))
+		return
+	}
+	if err := user.CheckPassword(input.Password); err != nil {
+		apierror.Abort(c, apierror.Unauthorized("Password is incorrect"))
+		return
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		apierror.Abort(c, apierror.BadRequest("This is already your email address"))
+		return
+	}
+	if valid, message := validation.IsValidEmail(newEmail); !valid {
+		apierror.Abort(c, apierror.BadRequest(message))
+		return
+	}
+	taken, err := emailTaken(db, newEmail, user.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check the email address"))
+		return
+	}
+	if taken {
+		apierror.Abort(c, apierror.Conflict("This email address is already in use"))
+		return
+	}
+
+	token, err := idgen.Token("email_", 32)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to start the email change"))
+		return
+	}
+	now := clock.Now()
+	if err := db.Model(&user).Updates(map[string]interface{}{
+		"pending_email":        newEmail,
+		"email_change_token":   token,
+		"email_change_sent_at": now,
+	}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to start the email change"))
+		return
+	}
+
+	name := user.FirstName + " " + user.LastName
+	go func() {
+		if err := email.SendEmailChangeConfirmEmail(newEmail, name, token); err != nil {
+			slog.Error("Failed to send email change confirmation", "user_id", user.ID, "error", err)
+		}
+		if err := email.SendEmailChangeNoticeEmail(user.Email, name, newEmail, false); err != nil {
+			slog.Error("Failed to send email change notice", "user_id", user.ID, "error", err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":       i18n.T(i18n.Locale(c), "We sent a confirmation link to %s. Your email address changes once you open it.", newEmail),
+		"pending_email": newEmail,
+	})
+}
+
+// CancelEmailChange drops the caller's pending email change, making its link invalid
+func (h *UserHandler) CancelEmailChange(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	result := db.Model(&models.User{}).Where("id = ? AND pending_email IS NOT NULL", c.MustGet("userID")).
+		Updates(map[string]interface{}{"pending_email": nil, "email_change_token": nil, "email_change_sent_at": nil})
+	if result.Error != nil {
+		apierror.Abort(c, apierror.Internal("Failed to cancel the email change"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Abort(c, apierror.NotFound("No email change is pending"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Email change cancelled")})
+}
+
+// ConfirmEmailChange swaps in the new address of the user whose link this is (?token=). Opening the link
+// proves the user owns the address, so the account counts as verified from then on.
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	token := c.Query("token")
+	if token == "" {
+		apierror.Abort(c, apierror.BadRequest("Confirmation token is required"))
+		return
+	}
+
+	var user models.User
+	if err := db.Where("email_change_token = ?", token).First(&user).Error; err != nil || user.PendingEmail == nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid or expired confirmation link"))
+		return
+	}
+	if utils.IsTokenExpired(user.EmailChangeSentAt) {
+		apierror.Abort(c, apierror.BadRequest("This confirmation link has expired. Please request the change again."))
+		return
+	}
+
+	oldEmail, newEmail := user.Email, *user.PendingEmail
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Someone may have registered the address since the change was requested
+		taken, err := emailTaken(tx, newEmail, user.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return errEmailTaken
+		}
+		return tx.Model(&user).Where("email_change_token = ?", token).Updates(map[string]interface{}{
+			"email":                newEmail,
+			"email_verified":       true,
+			"verification_token":   nil,
+			"pending_email":        nil,
+			"email_change_token":   nil,
+			"email_change_sent_at": nil,
+		}).Error
+	})
+	if errors.Is(err, errEmailTaken) {
+		apierror.Abort(c, apierror.Conflict("This email address is now used by another account"))
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to change the email address"))
+		return
+	}
+
+	go func() {
+		if err := email.SendEmailChangeNoticeEmail(oldEmail, user.FirstName+" "+user.LastName, newEmail, true); err != nil {
+			slog.Error("Failed to send email changed notice", "user_id", user.ID, "error", err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Your email address is now %s. Use it to log in.", newEmail),
+		"user": gin.H{
+			"id":             user.ID,
+			"email":          newEmail,
+			"email_verified": true,
+		},
+	})
+}
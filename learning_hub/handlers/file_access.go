@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/mediaurl"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Window used for per-user download throttling
+const documentDownloadWindow = time.Hour
+
+// authorizeProtectedFile checks access to files attached to lessons or assignment submissions and records
+// lesson downloads. Other files are public. Returns false after writing an error response.
+func (h *UploadHandler) authorizeProtectedFile(c *gin.Context, fileURL string, size int64) bool {
+	if strings.HasPrefix(fileURL, submissionFilePrefix) {
+		return h.authorizeSubmissionFile(c, fileURL)
+	}
+
+	db := h.DB.WithContext(c.Request.Context())
+	// Source videos stay protected after the lesson switched to their HLS version
+	transcodedSources := db.Model(&models.VideoTranscode{}).Select("lesson_id").
+		Where("source_url = ? OR source_url LIKE ?", fileURL, "%"+fileURL)
+	// Translated versions and blocks of a lesson are as protected as the lesson itself
+	variants := db.Model(&models.LessonVariant{}).Select("lesson_id").
+		Where("video_url = ? OR captions_url = ? OR video_url LIKE ? OR captions_url LIKE ?",
+			fileURL, fileURL, "%"+fileURL, "%"+fileURL)
+	blocks := db.Model(&models.LessonBlock{}).Select("lesson_id").
+		Where("url = ? OR captions_url = ? OR url LIKE ? OR captions_url LIKE ?",
+			fileURL, fileURL, "%"+fileURL, "%"+fileURL)
+
+	// A file a preview lesson shows is open anyway
+	var lesson models.Lesson
+	if err := db.Preload("Module.Course").
+		Where("document_url = ? OR video_url = ? OR captions_url = ? OR document_url LIKE ? OR video_url LIKE ? OR captions_url LIKE ? "+
+			"OR id IN (?) OR id IN (?) OR id IN (?)",
+			fileURL, fileURL, fileURL, "%"+fileURL, "%"+fileURL, "%"+fileURL, transcodedSources, variants, blocks).
+		Order("is_preview DESC").
+		First(&lesson).Error; err != nil {
+		return true
+	}
+	return h.authorizeLessonFile(c, lesson, fileURL, size, true)
+}
+
+// authorizeSubmissionFile lets the student who submitted a file and the staff of the assignment's course
+// download it. Files no submission uses are not served.
+func (h *UploadHandler) authorizeSubmissionFile(c *gin.Context, fileURL string) bool {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Abort(c, apierror.Unauthorized("Authentication required to access submitted files"))
+		return false
+	}
+
+	var submissions []models.AssignmentSubmission
+	if err := db.Preload("Assignment.Course").
+		Where("file_url = ? OR file_url LIKE ?", fileURL, "%"+fileURL).
+		Find(&submissions).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check file access").Wrap(err))
+		return false
+	}
+	for _, submission := range submissions {
+		if submission.UserID == userID.(uint) || canTeachCourse(c, db, submission.Assignment.Course) {
+			c.Header("Cache-Control", "private, no-store")
+			return true
+		}
+	}
+	apierror.Abort(c, apierror.NotFound("File not found"))
+	return false
+}
+
+// authorizeLessonFile requires enrollment in (or management of) the lesson's course, except for preview lessons.
+// With logAccess the download is recorded and counted towards the download limit.
+func (h *UploadHandler) authorizeLessonFile(c *gin.Context, lesson models.Lesson, fileURL string, size int64, logAccess bool) bool {
+	db := h.DB.WithContext(c.Request.Context())
+	if isOpenPreview(lesson) {
+		c.Header("Cache-Control", "public, max-age=3600")
+		return true
+	}
+
+	// Media players can't always send the Authorization header, a signed link identifies the user instead
+	if _, exists := c.Get("userID"); !exists && mediaurl.Signed(c.Request.URL.Query()) {
+		if !h.identifyMediaSigner(c) {
+			return false
+		}
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Abort(c, apierror.Unauthorized("Authentication required to access course materials"))
+		return false
+	}
+
+	// Enrollment is checked again so revoked access also ends links already handed out
+	course := lesson.Module.Course
+	manager := canTeachCourse(c, db, course)
+	if !manager {
+		var enrollment models.Enrollment
+		if err := db.Where("user_id = ? AND course_id = ? AND is_active = ?", userID, course.ID, true).
+			First(&enrollment).Error; err != nil {
+			apierror.Abort(c, apierror.Forbidden("You must be enrolled in this course to access its materials"))
+			return false
+		}
+	}
+
+	if !logAccess {
+		c.Header("Cache-Control", "private, max-age=3600")
+		return true
+	}
+
+	entry := models.FileAccessLog{
+		UserID:    userID.(uint),
+		LessonID:  &lesson.ID,
+		CourseID:  course.ID,
+		FilePath:  fileURL,
+		Bytes:     size,
+		IPAddress: c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 500),
+	}
+
+	// Instructors and admins managing the course are never throttled
+	if !manager && h.DocumentDownloadLimit > 0 {
+		var recent int64
+		db.Model(&models.FileAccessLog{}).
+			Where("user_id = ? AND throttled = ? AND created_at > ?", entry.UserID, false, clock.Now().Add(-documentDownloadWindow)).
+			Count(&recent)
+		if recent >= int64(h.DocumentDownloadLimit) {
+			entry.Throttled = true
+			entry.Bytes = 0
+			db.Create(&entry)
+
+			c.Header("Retry-After", strconv.Itoa(int(documentDownloadWindow.Seconds())))
+			apierror.Abort(c, apierror.New(http.StatusTooManyRequests, "Download limit reached, please try again later"))
+			return false
+		}
+	}
+
+	db.Create(&entry)
+
+	// Paid materials must not be cached by shared proxies
+	c.Header("Cache-Control", "private, no-store")
+	return true
+}
+
+// identifyMediaSigner authenticates the request from its signed media link
+func (h *UploadHandler) identifyMediaSigner(c *gin.Context) bool {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, ok := mediaurl.Verify(c.Request.URL.Path, c.Request.URL.Query())
+	if !ok {
+		apierror.Abort(c, apierror.Forbidden("Invalid or expired media link"))
+		return false
+	}
+
+	var user models.User
+	if err := db.Select("id, role").First(&user, userID).Error; err != nil {
+		apierror.Abort(c, apierror.Forbidden("Invalid or expired media link"))
+		return false
+	}
+	c.Set("userID", user.ID)
+	c.Set("userRole", user.Role)
+	return true
+}
@@ -0,0 +1,637 @@
+package handlers
+
+import (
+	"errors"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Threads listed per page
+const forumPageSize = 20
+
+type ForumHandler struct {
+	DB *gorm.DB
+}
+
+func NewForumHandler(db *gorm.DB) *ForumHandler {
+	return &ForumHandler{DB: db}
+}
+
+// forumAuthor loads the public fields of post and thread authors
+func forumAuthor(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Select("id, first_name, last_name, role")
+}
+
+// visibleContent limits threads or posts to published ones and the caller's own held content
+func visibleContent(query *gorm.DB, table string, userID uint) *gorm.DB {
+	return query.Where("("+table+".status = ? OR "+table+".user_id = ?)", models.ContentPublished, userID)
+}
+
+// discussionAccess checks the caller may take part in a course's discussions: course managers and
+// actively enrolled students. It responds with 403 otherwise.
+func (h *ForumHandler) discussionAccess(c *gin.Context, course models.Course) (manager, ok bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	if canTeachCourse(c, db, course) {
+		return true, true
+	}
+	var count int64
+	db.Model(&models.Enrollment{}).
+		Where("user_id = ? AND course_id = ? AND is_active = ?", c.MustGet("userID"), course.ID, true).
+		Count(&count)
+	if count == 0 {
+		apierror.Abort(c, apierror.Forbidden("You must be enrolled in this course to join its discussions"))
+		return false, false
+	}
+	return false, true
+}
+
+// loadThread loads the :id thread the caller can see, with its course and whether they manage it
+func (h *ForumHandler) loadThread(c *gin.Context, id string) (models.ForumThread, models.Course, bool, bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	var thread models.ForumThread
+	var course models.Course
+	if err := db.First(&thread, id).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Thread not found"))
+		return thread, course, false, false
+	}
+	if err := db.First(&course, thread.CourseID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return thread, course, false, false
+	}
+	manager, ok := h.discussionAccess(c, course)
+	if !ok {
+		return thread, course, false, false
+	}
+	if thread.Status != models.ContentPublished && thread.UserID != c.MustGet("userID").(uint) {
+		apierror.Abort(c, apierror.NotFound("Thread not found"))
+		return thread, course, false, false
+	}
+	return thread, course, manager, true
+}
+
+// loadPost loads the :id post with its thread, checking the caller can see both
+func (h *ForumHandler) loadPost(c *gin.Context) (models.ForumPost, models.ForumThread, models.Course, bool, bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	var post models.ForumPost
+	if err := db.First(&post, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Post not found"))
+		return post, models.ForumThread{}, models.Course{}, false, false
+	}
+	thread, course, manager, ok := h.loadThread(c, strconv.FormatUint(uint64(post.ThreadID), 10))
+	if !ok {
+		return post, thread, course, false, false
+	}
+	if post.Status != models.ContentPublished && post.UserID != c.MustGet("userID").(uint) {
+		apierror.Abort(c, apierror.NotFound("Post not found"))
+		return post, thread, course, false, false
+	}
+	return post, thread, course, manager, true
+}
+
+// GetThreads lists a course's threads, pinned first (?lesson_id=, ?unanswered=true, ?sort=activity|votes|newest, ?page=)
+func (h *ForumHandler) GetThreads(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	if _, ok := h.discussionAccess(c, course); !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+
+	query := visibleContent(db.Model(&models.ForumThread{}).Where("course_id = ?", course.ID), "forum_threads", userID)
+	if lessonID := c.Query("lesson_id"); lessonID != "" {
+		id, err := strconv.ParseUint(lessonID, 10, 64)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("Invalid lesson_id"))
+			return
+		}
+		query = query.Where("lesson_id = ?", id)
+	}
+	if c.Query("unanswered") == "true" {
+		query = query.Where("answered_post_id IS NULL")
+	}
+
+	order := "last_activity_at DESC"
+	switch c.DefaultQuery("sort", "activity") {
+	case "activity":
+	case "votes":
+		order = "upvotes DESC, last_activity_at DESC"
+	case "newest":
+		order = "created_at DESC"
+	default:
+		apierror.Abort(c, apierror.BadRequest("sort must be activity, votes or newest"))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch threads"))
+		return
+	}
+	var threads []models.ForumThread
+	if err := query.Preload("User", forumAuthor).
+		Order("pinned DESC, " + order).
+		Offset((page - 1) * forumPageSize).Limit(forumPageSize).
+		Find(&threads).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch threads"))
+		return
+	}
+	if err := countReplies(db, threads); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch threads"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"threads": threads,
+		"total":   total,
+		"page":    page,
+	})
+}
+
+// countReplies fills in the number of published replies of each thread
+func countReplies(db *gorm.DB, threads []models.ForumThread) error {
+	if len(threads) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(threads))
+	for i, thread := range threads {
+		ids[i] = thread.ID
+	}
+
+	var counts []struct {
+		ThreadID uint
+		Count    int64
+	}
+	if err := db.Model(&models.ForumPost{}).Select("thread_id, COUNT(*) AS count").
+		Where("thread_id IN ? AND status = ?", ids, models.ContentPublished).
+		Group("thread_id").Scan(&counts).Error; err != nil {
+		return err
+	}
+	byThread := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		byThread[count.ThreadID] = count.Count
+	}
+	for i := range threads {
+		threads[i].ReplyCount = byThread[threads[i].ID]
+	}
+	return nil
+}
+
+// CreateThread starts a discussion in a course, optionally about one of its lessons
+func (h *ForumHandler) CreateThread(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	if _, ok := h.discussionAccess(c, course); !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Title    string `json:"title" binding:"required,max=200"`
+		Body     string `json:"body" binding:"required"`
+		LessonID *uint  `json:"lesson_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if input.LessonID != nil {
+		var count int64
+		db.Model(&models.Lesson{}).
+			Joins("JOIN modules ON modules.id = lessons.module_id AND modules.deleted_at IS NULL").
+			Where("lessons.id = ? AND modules.course_id = ?", *input.LessonID, course.ID).
+			Count(&count)
+		if count == 0 {
+			apierror.Abort(c, apierror.BadRequest("Lesson not found in this course"))
+			return
+		}
+	}
+
+	title, body := strings.TrimSpace(input.Title), strings.TrimSpace(input.Body)
+	result, ok := checkPosting(c, db, "forum_thread", title+"\n"+body)
+	if !ok {
+		return
+	}
+
+	now := clock.Now()
+	thread := models.ForumThread{
+		CourseID:       course.ID,
+		LessonID:       input.LessonID,
+		UserID:         userID,
+		Title:          title,
+		Body:           body,
+		Status:         contentStatus(result),
+		LastActivityAt: now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User", "Posts").Create(&thread).Error; err != nil {
+			return err
+		}
+		if result.Flagged() {
+			return queueForModeration(tx, "forum_thread", thread.ID, userID, title+"\n"+body, result)
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create thread"))
+		return
+	}
+
+	response := gin.H{"thread": thread}
+	// Let students know up front when the instructor is away
+	if availability := instructorAvailability(db, course.InstructorID); availability["away"] == true {
+		response["instructor_availability"] = availability
+	}
+	if result.Flagged() {
+		response["message"] = "Your thread will be visible once a moderator approves it"
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+	response["message"] = "Thread created"
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetThread returns a thread with its replies, oldest first
+func (h *ForumHandler) GetThread(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	thread, _, _, ok := h.loadThread(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+
+	if err := db.Preload("User", forumAuthor).First(&thread, thread.ID).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch thread"))
+		return
+	}
+	if err := visibleContent(db.Where("thread_id = ?", thread.ID), "forum_posts", userID).
+		Preload("User", forumAuthor).Order("created_at, id").Find(&thread.Posts).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch replies"))
+		return
+	}
+	for _, post := range thread.Posts {
+		if post.Status == models.ContentPublished {
+			thread.ReplyCount++
+		}
+	}
+
+	// Which of these the caller upvoted
+	var votes []models.ForumVote
+	db.Where("user_id = ? AND ((content_type = 'thread' AND content_id = ?) OR "+
+		"(content_type = 'post' AND content_id IN (SELECT id FROM forum_posts WHERE thread_id = ?)))",
+		userID, thread.ID, thread.ID).
+		Find(&votes)
+	upvotedPosts := []uint{}
+	upvotedThread := false
+	for _, vote := range votes {
+		if vote.ContentType == "thread" {
+			upvotedThread = true
+		} else {
+			upvotedPosts = append(upvotedPosts, vote.ContentID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"thread":        thread,
+		"upvoted":       upvotedThread,
+		"upvoted_posts": upvotedPosts,
+	})
+}
+
+// CreatePost replies to a thread. Locked threads only take replies from course managers.
+func (h *ForumHandler) CreatePost(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	thread, course, manager, ok := h.loadThread(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+	if thread.Locked && !manager {
+		apierror.Abort(c, apierror.Conflict("This thread is locked"))
+		return
+	}
+	if thread.Status != models.ContentPublished {
+		apierror.Abort(c, apierror.Conflict("This thread is waiting for moderation"))
+		return
+	}
+
+	var input struct {
+		Body string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	body := strings.TrimSpace(input.Body)
+
+	result, ok := checkPosting(c, db, "forum_post", body)
+	if !ok {
+		return
+	}
+
+	now := clock.Now()
+	post := models.ForumPost{
+		ThreadID:  thread.ID,
+		UserID:    userID,
+		Body:      body,
+		Status:    contentStatus(result),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User").Create(&post).Error; err != nil {
+			return err
+		}
+		if result.Flagged() {
+			return queueForModeration(tx, "forum_post", post.ID, userID, body, result)
+		}
+
+		updates := map[string]interface{}{"last_activity_at": now}
+		// The instructor's first answer counts toward their response time
+		if userID == course.InstructorID && thread.InstructorRepliedAt == nil && thread.UserID != userID {
+			seconds := int64(responseTime(tx, course.InstructorID, thread.CreatedAt, now).Seconds())
+			updates["instructor_replied_at"], updates["response_seconds"] = now, seconds
+		}
+		return tx.Model(&thread).UpdateColumns(updates).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to post reply"))
+		return
+	}
+
+	if result.Flagged() {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": i18n.T(i18n.Locale(c), "Your reply will be visible once a moderator approves it"),
+			"post":    post,
+		})
+		return
+	}
+
+	if thread.UserID != userID {
+		notifyUser(db, thread.UserID, models.NotificationForumReply, "New reply to \""+thread.Title+"\"", gin.H{
+			"thread_id": thread.ID,
+			"post_id":   post.ID,
+			"course_id": course.ID,
+		})
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Reply posted"),
+		"post":    post,
+	})
+}
+
+// vote adds or removes the caller's upvote of a thread or post and keeps its counter in step
+func (h *ForumHandler) vote(c *gin.Context, contentType string, contentID uint, add bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+	model := interface{}(&models.ForumThread{})
+	if contentType == "post" {
+		model = &models.ForumPost{}
+	}
+
+	var upvotes int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var changed int64
+		if add {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ForumVote{
+				UserID:      userID,
+				ContentType: contentType,
+				ContentID:   contentID,
+				CreatedAt:   clock.Now(),
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			changed = result.RowsAffected
+		} else {
+			result := tx.Where("user_id = ? AND content_type = ? AND content_id = ?", userID, contentType, contentID).
+				Delete(&models.ForumVote{})
+			if result.Error != nil {
+				return result.Error
+			}
+			changed = -result.RowsAffected
+		}
+		if changed != 0 {
+			if err := tx.Model(model).Where("id = ?", contentID).
+				UpdateColumn("upvotes", gorm.Expr("upvotes + ?", changed)).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(model).Where("id = ?", contentID).Select("upvotes").Scan(&upvotes).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save vote"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"upvoted": add,
+		"upvotes": upvotes,
+	})
+}
+
+// VoteThread upvotes a thread (POST) or takes the upvote back (DELETE)
+func (h *ForumHandler) VoteThread(c *gin.Context) {
+	thread, _, _, ok := h.loadThread(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	h.vote(c, "thread", thread.ID, c.Request.Method == http.MethodPost)
+}
+
+// VotePost upvotes a reply (POST) or takes the upvote back (DELETE)
+func (h *ForumHandler) VotePost(c *gin.Context) {
+	post, _, _, _, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+	h.vote(c, "post", post.ID, c.Request.Method == http.MethodPost)
+}
+
+// MarkAnswer marks a reply as the answer of a thread, or clears it with {"post_id": null}.
+// Allowed for the thread's author and course managers.
+func (h *ForumHandler) MarkAnswer(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	thread, course, manager, ok := h.loadThread(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+	if !manager && thread.UserID != userID {
+		apierror.Abort(c, apierror.Forbidden("Only the thread's author or the instructor can mark the answer"))
+		return
+	}
+
+	var input struct {
+		PostID *uint `json:"post_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	var answer models.ForumPost
+	if input.PostID != nil {
+		err := db.Where("thread_id = ? AND status = ?", thread.ID, models.ContentPublished).First(&answer, *input.PostID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Abort(c, apierror.BadRequest("Reply not found in this thread"))
+			return
+		} else if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to fetch reply"))
+			return
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ForumPost{}).Where("thread_id = ? AND is_answer = ?", thread.ID, true).
+			UpdateColumn("is_answer", false).Error; err != nil {
+			return err
+		}
+		if input.PostID != nil {
+			if err := tx.Model(&answer).UpdateColumn("is_answer", true).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&thread).UpdateColumn("answered_post_id", input.PostID).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to mark answer"))
+		return
+	}
+
+	if input.PostID != nil && answer.UserID != userID {
+		notifyUser(db, answer.UserID, models.NotificationForumReply, "Your reply to \""+thread.Title+"\" was marked as the answer", gin.H{
+			"thread_id": thread.ID,
+			"post_id":   answer.ID,
+			"course_id": course.ID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          i18n.T(i18n.Locale(c), "Answer updated"),
+		"answered_post_id": input.PostID,
+	})
+}
+
+// ModerateThread pins, unpins, locks or unlocks a thread (course managers)
+func (h *ForumHandler) ModerateThread(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	thread, _, manager, ok := h.loadThread(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !manager {
+		apierror.Abort(c, apierror.Forbidden("Only the course instructor can moderate threads"))
+		return
+	}
+
+	var input struct {
+		Pinned *bool `json:"pinned"`
+		Locked *bool `json:"locked"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Pinned != nil {
+		updates["pinned"], thread.Pinned = *input.Pinned, *input.Pinned
+	}
+	if input.Locked != nil {
+		updates["locked"], thread.Locked = *input.Locked, *input.Locked
+	}
+	if len(updates) == 0 {
+		apierror.Abort(c, apierror.BadRequest("Nothing to update, send pinned and/or locked"))
+		return
+	}
+	if err := db.Model(&thread).UpdateColumns(updates).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update thread"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Thread updated"),
+		"thread":  thread,
+	})
+}
+
+// DeleteThread deletes a thread and its replies (author or course managers)
+func (h *ForumHandler) DeleteThread(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	thread, _, manager, ok := h.loadThread(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !manager && thread.UserID != c.MustGet("userID").(uint) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to delete this thread"))
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("thread_id = ?", thread.ID).Delete(&models.ForumPost{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&thread).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete thread"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Thread deleted")})
+}
+
+// DeletePost deletes a reply (author or course managers)
+func (h *ForumHandler) DeletePost(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	post, thread, _, manager, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+	if !manager && post.UserID != c.MustGet("userID").(uint) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to delete this reply"))
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if post.IsAnswer {
+			if err := tx.Model(&thread).UpdateColumn("answered_post_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&post).Error
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to delete forum post", "post_id", post.ID, "error", err)
+		apierror.Abort(c, apierror.Internal("Failed to delete reply"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Reply deleted")})
+}
@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"encoding/json"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/grading"
+	"learning_hub/pkg/i18n"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Where an effective grading scale comes from
+const (
+	gradingScaleSourceCourse   = "course"
+	gradingScaleSourcePlatform = "platform"
+	gradingScaleSourceBuiltIn  = "built_in"
+)
+
+type GradingHandler struct {
+	DB *gorm.DB
+}
+
+func NewGradingHandler(db *gorm.DB) *GradingHandler {
+	return &GradingHandler{DB: db}
+}
+
+// scaleFromModel converts a stored grading scale into the grading representation
+func scaleFromModel(m models.GradingScale) grading.Scale {
+	scale := grading.Scale{Name: m.Name, PassThreshold: m.PassThreshold}
+	if len(m.Bands) > 0 {
+		json.Unmarshal(m.Bands, &scale.Bands)
+	}
+	return scale.Normalize()
+}
+
+// effectiveScale returns the course's grading scale, falling back to the platform default
+// and then to the built-in scale
+func effectiveScale(db *gorm.DB, courseID uint) (grading.Scale, string) {
+	var saved models.GradingScale
+	if err := db.Where("course_id = ?", courseID).First(&saved).Error; err == nil {
+		return scaleFromModel(saved), gradingScaleSourceCourse
+	}
+	if err := db.Where("course_id IS NULL").First(&saved).Error; err == nil {
+		return scaleFromModel(saved), gradingScaleSourcePlatform
+	}
+	return grading.Default(), gradingScaleSourceBuiltIn
+}
+
+// effectiveScales returns the grading scale of each course as effectiveScale does, in one query
+func effectiveScales(db *gorm.DB, courseIDs []uint) (map[uint]grading.Scale, error) {
+	var saved []models.GradingScale
+	if err := db.Where("course_id IN ? OR course_id IS NULL", courseIDs).Order("id").Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	var fallback *grading.Scale
+	byCourse := make(map[uint]grading.Scale, len(saved))
+	for _, s := range saved {
+		if s.CourseID == nil {
+			if fallback == nil {
+				scale := scaleFromModel(s)
+				fallback = &scale
+			}
+		} else {
+			byCourse[*s.CourseID] = scaleFromModel(s)
+		}
+	}
+	scales := make(map[uint]grading.Scale, len(courseIDs))
+	for _, id := range courseIDs {
+		scale, ok := byCourse[id]
+		if !ok && fallback != nil {
+			scale = *fallback
+		} else if !ok {
+			scale = grading.Default()
+		}
+		scales[id] = scale
+	}
+	return scales, nil
+}
+
+// itemScore is a user's percentage on one graded quiz or assignment of a course
+type itemScore struct {
+	CourseID uint
+	UserID   uint
+	Percent  float64
+}
+
+// itemScores returns the best completed attempt per published quiz and best grade per published
+// assignment of the users in the courses
+func itemScores(db *gorm.DB, courseIDs, userIDs []uint) ([]itemScore, error) {
+	var quizScores []itemScore
+	if err := db.Table("quiz_attempts").
+		Select("quizzes.course_id, quiz_attempts.user_id, MAX(quiz_attempts.score) AS percent").
+		Joins("JOIN quizzes ON quizzes.id = quiz_attempts.quiz_id AND quizzes.deleted_at IS NULL").
+		Where("quizzes.course_id IN ? AND quizzes.is_published = ?", courseIDs, true).
+		Where("quiz_attempts.user_id IN ? AND quiz_attempts.is_completed = ? AND quiz_attempts.deleted_at IS NULL", userIDs, true).
+		Group("quizzes.course_id, quiz_attempts.user_id, quiz_attempts.quiz_id").
+		Scan(&quizScores).Error; err != nil {
+		return nil, err
+	}
+
+	var assignmentScores []itemScore
+	if err := db.Table("assignment_submissions").
+		Select("assignments.course_id, assignment_submissions.user_id, MAX(assignment_submissions.grade * 100.0 / assignments.max_points) AS percent").
+		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id AND assignments.deleted_at IS NULL").
+		Where("assignments.course_id IN ? AND assignments.is_published = ? AND assignments.max_points > 0", courseIDs, true).
+		Where("assignment_submissions.user_id IN ? AND assignment_submissions.is_graded = ? AND assignment_submissions.deleted_at IS NULL", userIDs, true).
+		Group("assignments.course_id, assignment_submissions.user_id, assignment_submissions.assignment_id").
+		Scan(&assignmentScores).Error; err != nil {
+		return nil, err
+	}
+	return append(quizScores, assignmentScores...), nil
+}
+
+// meanBy averages the scores by the key of each, leaving out keys without scores
+func meanBy(scores []itemScore, key func(itemScore) uint) map[uint]float64 {
+	totals := make(map[uint]float64)
+	counts := make(map[uint]int)
+	for _, s := range scores {
+		totals[key(s)] += s.Percent
+		counts[key(s)]++
+	}
+	means := make(map[uint]float64, len(totals))
+	for k, total := range totals {
+		means[k] = total / float64(counts[k])
+	}
+	return means
+}
+
+// courseGrades returns the course grade percentage of each user that has graded work:
+// the mean of their best completed attempt per published quiz and best grade per published assignment
+func courseGrades(db *gorm.DB, courseID uint, userIDs []uint) (map[uint]float64, error) {
+	scores, err := itemScores(db, []uint{courseID}, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	return meanBy(scores, func(s itemScore) uint { return s.UserID }), nil
+}
+
+// studentGrades returns the user's grade percentage in each of the courses with graded work, as
+// courseGrades does, in one pass over all the courses
+func studentGrades(db *gorm.DB, userID uint, courseIDs []uint) (map[uint]float64, error) {
+	scores, err := itemScores(db, courseIDs, []uint{userID})
+	if err != nil {
+		return nil, err
+	}
+	return meanBy(scores, func(s itemScore) uint { return s.CourseID }), nil
+}
+
+// gradeSummary describes a course grade on a scale; nil fields mean nothing has been graded yet
+func gradeSummary(scale grading.Scale, percent float64, graded bool) gin.H {
+	if !graded {
+		return gin.H{"percent": nil, "letter": nil, "passed": nil}
+	}
+	return gin.H{
+		"percent": percent,
+		"letter":  scale.Letter(percent),
+		"passed":  scale.Passed(percent),
+	}
+}
+
+type gradingScaleInput struct {
+	Name          string         `json:"name"`
+	PassThreshold *float64       `json:"pass_threshold" binding:"required"`
+	Bands         []grading.Band `json:"bands" binding:"required"`
+}
+
+// bindScale reads and validates a grading scale from the request body
+func bindScale(c *gin.Context) (grading.Scale, models.JSON, bool) {
+	var input gradingScaleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return grading.Scale{}, nil, false
+	}
+
+	scale := grading.Scale{Name: input.Name, PassThreshold: *input.PassThreshold, Bands: input.Bands}.Normalize()
+	if err := scale.Validate(); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return scale, nil, false
+	}
+
+	bands, err := json.Marshal(scale.Bands)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid grade bands"))
+		return scale, nil, false
+	}
+	return scale, models.JSON(bands), true
+}
+
+// saveScale creates or replaces the grading scale stored for courseID (nil for the platform default)
+func (h *GradingHandler) saveScale(c *gin.Context, courseID *uint) {
+	db := h.DB.WithContext(c.Request.Context())
+	scale, bands, ok := bindScale(c)
+	if !ok {
+		return
+	}
+
+	query := db.Where("course_id IS NULL")
+	if courseID != nil {
+		query = db.Where("course_id = ?", *courseID)
+	}
+
+	var saved models.GradingScale
+	if err := query.First(&saved).Error; err != nil && err != gorm.ErrRecordNotFound {
+		apierror.Abort(c, apierror.Internal("Failed to fetch grading scale"))
+		return
+	}
+
+	saved.CourseID = courseID
+	saved.Name = scale.Name
+	saved.PassThreshold = scale.PassThreshold
+	saved.Bands = bands
+	if err := db.Save(&saved).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save grading scale"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       i18n.T(i18n.Locale(c), "Grading scale saved successfully"),
+		"grading_scale": scale,
+	})
+}
+
+// GetCourseGradingScale returns the grading scale in effect for a course and where it comes from
+func (h *GradingHandler) GetCourseGradingScale(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+
+	scale, source := effectiveScale(db, course.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"grading_scale": scale,
+		"source":        source,
+	})
+}
+
+// SaveCourseGradingScale sets a course's own grading scale
+func (h *GradingHandler) SaveCourseGradingScale(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+	h.saveScale(c, &course.ID)
+}
+
+// DeleteCourseGradingScale removes a course's own grading scale so the platform default applies
+func (h *GradingHandler) DeleteCourseGradingScale(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	if err := db.Unscoped().Where("course_id = ?", course.ID).Delete(&models.GradingScale{}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete grading scale"))
+		return
+	}
+
+	scale, source := effectiveScale(db, course.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":       i18n.T(i18n.Locale(c), "Course grading scale removed"),
+		"grading_scale": scale,
+		"source":        source,
+	})
+}
+
+// GetDefaultGradingScale returns the platform default grading scale
+func (h *GradingHandler) GetDefaultGradingScale(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var saved models.GradingScale
+	if err := db.Where("course_id IS NULL").First(&saved).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"grading_scale": grading.Default(),
+			"source":        gradingScaleSourceBuiltIn,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"grading_scale": scaleFromModel(saved),
+		"source":        gradingScaleSourcePlatform,
+	})
+}
+
+// SaveDefaultGradingScale sets the platform default grading scale used by courses without their own
+func (h *GradingHandler) SaveDefaultGradingScale(c *gin.Context) {
+	h.saveScale(c, nil)
+}
+
+// GetGradebook lists every enrolled student's course grade on the course's grading scale
+func (h *GradingHandler) GetGradebook(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadTaughtCourse(c, db)
+	if !ok {
+		return
+	}
+
+	var enrollments []models.Enrollment
+	if err := db.Preload("User").Where("course_id = ? AND is_active = ?", course.ID, true).
+		Order("enrolled_at").Find(&enrollments).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch enrollments"))
+		return
+	}
+
+	userIDs := make([]uint, len(enrollments))
+	for i, e := range enrollments {
+		userIDs[i] = e.UserID
+	}
+	grades, err := courseGrades(db, course.ID, userIDs)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to calculate grades"))
+		return
+	}
+
+	scale, source := effectiveScale(db, course.ID)
+	students := make([]gin.H, len(enrollments))
+	passed := 0
+	for i, e := range enrollments {
+		percent, graded := grades[e.UserID]
+		if graded && scale.Passed(percent) {
+			passed++
+		}
+		students[i] = gin.H{
+			"user_id":      e.UserID,
+			"name":         e.User.FirstName + " " + e.User.LastName,
+			"email":        e.User.Email,
+			"progress":     e.Progress,
+			"completed_at": e.CompletedAt,
+			"grade":        gradeSummary(scale, percent, graded),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"course_id":     course.ID,
+		"grading_scale": scale,
+		"scale_source":  source,
+		"students":      students,
+		"count":         len(students),
+		"graded_count":  len(grades),
+		"passed_count":  passed,
+	})
+}
+
+// GetTranscript lists the student's courses with their grades, newest enrollment first
+func (h *GradingHandler) GetTranscript(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	var enrollments []models.Enrollment
+	if err := db.Preload("Course", func(db *gorm.DB) *gorm.DB { return db.Unscoped().Select("id, title") }).
+		Where("user_id = ? AND is_active = ?", uid, true).
+		Order("enrolled_at DESC").Find(&enrollments).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch enrollments"))
+		return
+	}
+
+	courseIDs := make([]uint, len(enrollments))
+	for i, e := range enrollments {
+		courseIDs[i] = e.CourseID
+	}
+	grades, err := studentGrades(db, uid, courseIDs)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to calculate grades"))
+		return
+	}
+	scales, err := effectiveScales(db, courseIDs)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to load grading scales"))
+		return
+	}
+
+	courses := make([]gin.H, 0, len(enrollments))
+	for _, e := range enrollments {
+		percent, graded := grades[e.CourseID]
+		scale := scales[e.CourseID]
+
+		courses = append(courses, gin.H{
+			"course_id":      e.CourseID,
+			"course_title":   e.Course.Title,
+			"enrolled_at":    e.EnrolledAt,
+			"completed_at":   e.CompletedAt,
+			"progress":       e.Progress,
+			"certificate_id": e.CertificateID,
+			"pass_threshold": scale.PassThreshold,
+			"grade":          gradeSummary(scale, percent, graded),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"courses": courses,
+		"count":   len(courses),
+	})
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/cache"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/fileupload"
+	"learning_hub/pkg/jobs"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// healthCheckTimeout bounds each dependency check, below the usual probe timeout of Kubernetes
+	healthCheckTimeout = 2 * time.Second
+	// remoteCheckInterval spaces out the checks that write a file or connect to the SMTP server, which
+	// probes would otherwise repeat every few seconds; their last result is answered in between
+	remoteCheckInterval = 30 * time.Second
+)
+
+// Statuses of a dependency check
+const (
+	checkUp       = "up"
+	checkDown     = "down"
+	checkDisabled = "disabled" // not set up in this environment, e.g. SMTP in development
+)
+
+// checkResult is how a dependency answered
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Critical  bool    `json:"critical"`
+	Error     string  `json:"error,omitempty"`
+	checkedAt time.Time
+}
+
+// dependencyCheck tells whether a dependency works. The API isn't ready while a critical one is down;
+// the others only degrade it, since taking the instance out of service would not bring them back.
+type dependencyCheck struct {
+	Name     string
+	Critical bool
+	CacheFor time.Duration
+	Run      func(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	DB     *gorm.DB
+	tables []string
+	checks []dependencyCheck
+
+	shuttingDown atomic.Bool
+
+	mu     sync.Mutex
+	cached map[string]checkResult
+	last   map[string]string // status each check had, to log when it changes
+}
+
+func NewHealthHandler(db *gorm.DB) *HealthHandler {
+	h := &HealthHandler{DB: db, cached: map[string]checkResult{}, last: map[string]string{}}
+	for _, model := range models.All() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			slog.Warn("Failed to read the table of a model", "model", fmt.Sprintf("%T", model), "error", err)
+			continue
+		}
+		h.tables = append(h.tables, stmt.Schema.Table)
+	}
+	h.checks = []dependencyCheck{
+		{Name: "database", Critical: true, Run: h.checkDatabase},
+		{Name: "migrations", Critical: true, Run: h.checkMigrations},
+		{Name: "storage", Critical: true, CacheFor: remoteCheckInterval, Run: fileupload.CheckWritable},
+		{Name: "smtp", CacheFor: remoteCheckInterval, Run: email.Ping},
+		{Name: "workers", Run: checkWorkers},
+		{Name: "cache", CacheFor: remoteCheckInterval, Run: cache.Ping},
+	}
+	return h
+}
+
+// Liveness tells Kubernetes the process is answering. It checks no dependency, so an outage of the
+// database doesn't get every instance restarted.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// Readiness tells Kubernetes whether to send the instance traffic, with the status and latency of each
+// dependency. It answers 503 while a critical dependency (database, migrations, storage) is down, and
+// 200 with status "degraded" when only SMTP or the background workers are.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	if h.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+	results := h.runChecks(c.Request.Context())
+
+	status, code := "ready", http.StatusOK
+	for _, result := range results {
+		if result.Status != checkDown {
+			continue
+		}
+		if result.Critical {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+		status = "degraded"
+	}
+	c.JSON(code, gin.H{
+		"status": status,
+		"checks": results,
+	})
+}
+
+// ShuttingDown makes readiness fail from now on, so no new traffic is sent while requests drain
+func (h *HealthHandler) ShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// runChecks runs the checks concurrently, each within healthCheckTimeout
+func (h *HealthHandler) runChecks(ctx context.Context) map[string]checkResult {
+	results := make(map[string]checkResult, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := h.run(ctx, check)
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (h *HealthHandler) run(ctx context.Context, check dependencyCheck) checkResult {
+	if check.CacheFor > 0 {
+		h.mu.Lock()
+		cached, ok := h.cached[check.Name]
+		h.mu.Unlock()
+		if ok && time.Since(cached.checkedAt) < check.CacheFor {
+			return cached
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	err := check.Run(ctx)
+	result := checkResult{
+		Status:    checkUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Critical:  check.Critical,
+		checkedAt: start,
+	}
+	switch {
+	case errors.Is(err, email.ErrNotConfigured):
+		result.Status = checkDisabled
+	case err != nil:
+		result.Status, result.Error = checkDown, err.Error()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if check.CacheFor > 0 {
+		h.cached[check.Name] = result
+	}
+	// Probes run every few seconds, so only changes are logged
+	if previous := h.last[check.Name]; previous != result.Status {
+		h.last[check.Name] = result.Status
+		if result.Status == checkDown {
+			slog.Warn("Dependency is down", "dependency", check.Name, "error", err)
+		} else if previous == checkDown {
+			slog.Info("Dependency recovered", "dependency", check.Name)
+		}
+	}
+	return result
+}
+
+func (h *HealthHandler) checkDatabase(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkMigrations looks for the table of every model, which AutoMigrate creates at startup
+func (h *HealthHandler) checkMigrations(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	var found []string
+	err := db.Raw(
+		"SELECT table_name FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name IN ?",
+		h.tables).Scan(&found).Error
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(found))
+	for _, table := range found {
+		existing[table] = true
+	}
+	var missing []string
+	for _, table := range h.tables {
+		if !existing[table] {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkWorkers reports the background jobs that missed their schedule
+func checkWorkers(ctx context.Context) error {
+	states, started := jobs.States()
+	if started.IsZero() {
+		return errors.New("background jobs are not started")
+	}
+	now := time.Now()
+	var stalled []string
+	for _, state := range states {
+		if state.Stalled(started, now) {
+			stalled = append(stalled, state.Name)
+		}
+	}
+	if len(stalled) > 0 {
+		return fmt.Errorf("stalled jobs: %s", strings.Join(stalled, ", "))
+	}
+	return nil
+}
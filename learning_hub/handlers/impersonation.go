@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/jwt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How long an admin can act as a user with one impersonation token
+const impersonationTTL = 30 * time.Minute
+
+// ImpersonateUser gives the calling admin a token to use the app as the user does ({"reason"}), e.g. to
+// reproduce a problem they reported. The token expires after 30 minutes and carries the admin's ID; every
+// request made with it is audit-logged. Admins can't be impersonated.
+func (h *AdminHandler) ImpersonateUser(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Reason string `json:"reason" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("A reason is required to act as a user"))
+		return
+	}
+
+	adminID := c.MustGet("userID").(uint)
+	var user models.User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if user.ID == adminID || user.Role == "admin" {
+		apierror.Abort(c, apierror.Forbidden("Admins can't be impersonated"))
+		return
+	}
+
+	sessionID, err := idgen.Token("ses_", 16)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to start impersonation"))
+		return
+	}
+	now := clock.Now()
+	session := models.Session{
+		ID:             sessionID,
+		UserID:         user.ID,
+		IP:             c.ClientIP(),
+		UserAgent:      truncate(c.Request.UserAgent(), 300),
+		CreatedAt:      now,
+		LastSeenAt:     now,
+		LastSeenIP:     c.ClientIP(),
+		ExpiresAt:      now.Add(impersonationTTL),
+		ImpersonatorID: &adminID,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to start impersonation"))
+		return
+	}
+	token, err := jwt.GenerateImpersonationToken(user.ID, user.Email, user.Role, sessionID, adminID, impersonationTTL)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to generate token"))
+		return
+	}
+	recordAudit(db, c, models.AuditImpersonate, "user", user.ID, nil, gin.H{
+		"reason":     input.Reason,
+		"session_id": sessionID,
+		"expires_at": session.ExpiresAt,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       i18n.T(i18n.Locale(c), "Use this token to see the app as %s %s. POST /api/logout ends it.", user.FirstName, user.LastName),
+		"token":         token,
+		"impersonating": true,
+		"expires_at":    session.ExpiresAt,
+		"user": gin.H{
+			"id":         user.ID,
+			"first_name": user.FirstName,
+			"last_name":  user.LastName,
+			"email":      user.Email,
+			"role":       user.Role,
+		},
+	})
+}
+
+// RecordImpersonatedRequest audit-logs a request an admin made while acting as a user, once handled
+func (h *AdminHandler) RecordImpersonatedRequest(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	recordAudit(db, c, models.AuditImpersonation, "user", c.GetUint("userID"), nil, gin.H{
+		"method":     c.Request.Method,
+		"path":       c.Request.URL.Path,
+		"status":     c.Writer.Status(),
+		"session_id": c.GetString("sessionID"),
+	})
+}
@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Payments this recent may still be enrolling, so they aren't checked yet
+const integrityPaymentGrace = 10 * time.Minute
+
+var errIntegrityCheckRunning = errors.New("integrity check already running")
+
+// integrityFinding is one anomaly found by a check
+type integrityFinding struct {
+	EntityID string
+	UserID   uint
+	CourseID uint
+	Details  gin.H
+}
+
+// integrityCheck finds one kind of anomaly and repairs it
+type integrityCheck struct {
+	Type       string
+	EntityType string
+	Repair     string // what the repair action does, shown on the dashboard
+	find       func(db *gorm.DB) ([]integrityFinding, error)
+	repair     func(tx *gorm.DB, issue models.IntegrityIssue, adminID uint) error
+}
+
+var integrityChecks = []integrityCheck{
+	{
+		Type:       models.IntegrityEnrollmentWithoutPayment,
+		EntityType: "enrollment",
+		Repair:     "Deactivate the enrollment",
+		find:       findEnrollmentsWithoutPayment,
+		repair: func(tx *gorm.DB, issue models.IntegrityIssue, adminID uint) error {
+			return tx.Model(&models.Enrollment{}).Where("id = ?", issue.EntityID).Update("is_active", false).Error
+		},
+	},
+	{
+		Type:       models.IntegrityPaymentWithoutEnrollment,
+		EntityType: "payment",
+		Repair:     "Enroll the student in the course or track paid for",
+		find:       findPaymentsWithoutEnrollment,
+		repair:     enrollPaymentOwner,
+	},
+	{
+		Type:       models.IntegrityOrphanedProgress,
+		EntityType: "lesson",
+		Repair:     "Delete the lesson's progress and recalculate the students' course progress",
+		find:       findOrphanedProgress,
+		repair:     deleteOrphanedProgress,
+	},
+	{
+		Type:       models.IntegrityUnearnedCertificate,
+		EntityType: "certificate",
+		Repair:     "Revoke the certificate",
+		find:       findUnearnedCertificates,
+		repair: func(tx *gorm.DB, issue models.IntegrityIssue, adminID uint) error {
+			return tx.Model(&models.Certificate{}).Where("id = ? AND revoked_at IS NULL", issue.EntityID).
+				Updates(map[string]interface{}{
+					"revoked_at":        clock.Now(),
+					"revoked_by":        adminID,
+					"revocation_reason": "Issued for an enrollment that was never completed",
+				}).Error
+		},
+	},
+}
+
+func integrityCheckOf(issueType string) *integrityCheck {
+	for i := range integrityChecks {
+		if integrityChecks[i].Type == issueType {
+			return &integrityChecks[i]
+		}
+	}
+	return nil
+}
+
+// findEnrollmentsWithoutPayment finds active enrollments of real students in paid courses without a
+// successful payment for the course or a track containing it, e.g. from the free enrollment endpoint.
+// Enrollments on an organization's seat are paid by the organization.
+func findEnrollmentsWithoutPayment(db *gorm.DB) ([]integrityFinding, error) {
+	var rows []struct {
+		ID         uint
+		UserID     uint
+		CourseID   uint
+		Price      float64
+		EnrolledAt time.Time
+	}
+	err := db.Table("enrollments").
+		Select("enrollments.id, enrollments.user_id, enrollments.course_id, courses.price, enrollments.enrolled_at").
+		Joins("JOIN courses ON courses.id = enrollments.course_id AND courses.deleted_at IS NULL").
+		Where("enrollments.is_active = ? AND courses.price > 0 AND enrollments.organization_id IS NULL", true).
+		Where("enrollments.user_id NOT IN (SELECT id FROM users WHERE is_test_student)").
+		Where(`NOT EXISTS (SELECT 1 FROM payments WHERE payments.user_id = enrollments.user_id AND payments.status = ?
+			AND (payments.course_id = enrollments.course_id
+				OR payments.track_id IN (SELECT track_id FROM track_courses WHERE track_courses.course_id = enrollments.course_id)))`,
+			models.PaymentStatusSuccess).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]integrityFinding, len(rows))
+	for i, row := range rows {
+		findings[i] = integrityFinding{
+			EntityID: strconv.FormatUint(uint64(row.ID), 10),
+			UserID:   row.UserID,
+			CourseID: row.CourseID,
+			Details:  gin.H{"price": row.Price, "enrolled_at": row.EnrolledAt},
+		}
+	}
+	return findings, nil
+}
+
+// paymentsWithoutEnrollment finds successful payments whose student isn't actively enrolled in the
+// course, or not enrolled in the track for a track purchase
+func paymentsWithoutEnrollment(db *gorm.DB) *gorm.DB {
+	return db.Where("status = ? AND updated_at < ?", models.PaymentStatusSuccess, clock.Now().Add(-integrityPaymentGrace)).
+		Where("organization_id IS NULL"). // seats are credited to the organization, nobody is enrolled
+		Where(`(track_id IS NULL AND NOT EXISTS (SELECT 1 FROM enrollments WHERE enrollments.user_id = payments.user_id
+				AND enrollments.course_id = payments.course_id AND enrollments.is_active))
+			OR (track_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM track_enrollments
+				WHERE track_enrollments.user_id = payments.user_id AND track_enrollments.track_id = payments.track_id))`)
+}
+
+func findPaymentsWithoutEnrollment(db *gorm.DB) ([]integrityFinding, error) {
+	var payments []models.Payment
+	if err := paymentsWithoutEnrollment(db).Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	findings := make([]integrityFinding, len(payments))
+	for i, payment := range payments {
+		findings[i] = integrityFinding{
+			EntityID: strconv.FormatUint(uint64(payment.ID), 10),
+			UserID:   payment.UserID,
+			CourseID: payment.CourseID,
+			Details: gin.H{
+				"amount":   payment.Amount,
+				"currency": payment.Currency,
+				"tx_ref":   payment.ChapaTxRef,
+				"track_id": payment.TrackID,
+				"paid_at":  payment.UpdatedAt,
+			},
+		}
+	}
+	return findings, nil
+}
+
+// findOrphanedProgress finds lessons that are gone or past trash retention but still have progress
+// rows, which also keeps the trash purge from removing them
+func findOrphanedProgress(db *gorm.DB) ([]integrityFinding, error) {
+	var rows []struct {
+		LessonID     uint
+		CourseID     uint
+		ProgressRows int64
+		Students     int64
+	}
+	err := db.Model(&models.LessonProgress{}).
+		Select("lesson_id, MAX(course_id) AS course_id, COUNT(*) AS progress_rows, COUNT(DISTINCT user_id) AS students").
+		Where(`NOT EXISTS (SELECT 1 FROM lessons WHERE lessons.id = lesson_progresses.lesson_id
+			AND (lessons.deleted_at IS NULL OR lessons.deleted_at >= ?))`, clock.Now().Add(-trashRetention)).
+		Group("lesson_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]integrityFinding, len(rows))
+	for i, row := range rows {
+		findings[i] = integrityFinding{
+			EntityID: strconv.FormatUint(uint64(row.LessonID), 10),
+			CourseID: row.CourseID,
+			Details:  gin.H{"progress_rows": row.ProgressRows, "students": row.Students},
+		}
+	}
+	return findings, nil
+}
+
+// findUnearnedCertificates finds unrevoked certificates whose enrollment is gone or was never completed
+func findUnearnedCertificates(db *gorm.DB) ([]integrityFinding, error) {
+	var rows []struct {
+		ID           string
+		UserID       uint
+		CourseID     uint
+		EnrollmentID uint
+		Progress     *float64
+		IssueDate    time.Time
+	}
+	err := db.Table("certificates").
+		Select(`certificates.id, certificates.user_id, certificates.course_id, certificates.enrollment_id,
+			enrollments.progress, certificates.issue_date`).
+		Joins("LEFT JOIN enrollments ON enrollments.id = certificates.enrollment_id").
+		Where("certificates.revoked_at IS NULL AND (enrollments.id IS NULL OR enrollments.completed_at IS NULL)").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]integrityFinding, len(rows))
+	for i, row := range rows {
+		findings[i] = integrityFinding{
+			EntityID: row.ID,
+			UserID:   row.UserID,
+			CourseID: row.CourseID,
+			Details: gin.H{
+				"enrollment_id":      row.EnrollmentID,
+				"enrollment_missing": row.Progress == nil,
+				"progress":           row.Progress,
+				"issue_date":         row.IssueDate,
+			},
+		}
+	}
+	return findings, nil
+}
+
+// enrollPaymentOwner enrolls the payer in what they paid for, reactivating an existing course enrollment
+func enrollPaymentOwner(tx *gorm.DB, issue models.IntegrityIssue, adminID uint) error {
+	var payment models.Payment
+	if err := tx.First(&payment, issue.EntityID).Error; err != nil {
+		return err
+	}
+	_, err := enrollPayer(tx, payment)
+	return err
+}
+
+// deleteOrphanedProgress removes the progress of a deleted lesson and recalculates the progress of
+// the students who had it
+func deleteOrphanedProgress(tx *gorm.DB, issue models.IntegrityIssue, adminID uint) error {
+	var rows []models.LessonProgress
+	if err := tx.Select("DISTINCT user_id, course_id").Where("lesson_id = ?", issue.EntityID).Find(&rows).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("lesson_id = ?", issue.EntityID).Delete(&models.LessonProgress{}).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := updateEnrollmentProgress(tx, row.UserID, row.CourseID); err != nil &&
+			!errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+type IntegrityHandler struct {
+	DB *gorm.DB
+
+	running       sync.Mutex
+	lastCheckedAt atomic.Pointer[time.Time]
+}
+
+func NewIntegrityHandler(db *gorm.DB) *IntegrityHandler {
+	return &IntegrityHandler{DB: db}
+}
+
+// RunIntegrityChecks runs every check, recording the anomalies found and clearing open issues that
+// are no longer found; meant to run as a background job
+func (h *IntegrityHandler) RunIntegrityChecks(ctx context.Context) error {
+	if !h.running.TryLock() {
+		return errIntegrityCheckRunning
+	}
+	defer h.running.Unlock()
+
+	db := h.DB.WithContext(ctx)
+	now := clock.Now()
+	for _, check := range integrityChecks {
+		findings, err := check.find(db)
+		if err != nil {
+			return fmt.Errorf("%s check: %w", check.Type, err)
+		}
+		if err := recordIntegrityFindings(db, check, findings, now); err != nil {
+			return fmt.Errorf("recording %s issues: %w", check.Type, err)
+		}
+	}
+	h.lastCheckedAt.Store(&now)
+	return nil
+}
+
+// recordIntegrityFindings upserts the findings of a check and clears its open issues that weren't found
+func recordIntegrityFindings(db *gorm.DB, check integrityCheck, findings []integrityFinding, now time.Time) error {
+	found := make([]string, len(findings))
+	if len(findings) > 0 {
+		issues := make([]models.IntegrityIssue, len(findings))
+		for i, finding := range findings {
+			details, _ := json.Marshal(finding.Details)
+			issues[i] = models.IntegrityIssue{
+				Type:       check.Type,
+				EntityType: check.EntityType,
+				EntityID:   finding.EntityID,
+				UserID:     finding.UserID,
+				CourseID:   finding.CourseID,
+				Details:    models.JSON(details),
+				DetectedAt: now,
+				LastSeenAt: now,
+			}
+			found[i] = finding.EntityID
+		}
+		// Issues found again are reopened unless an admin dismissed them
+		err := db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "type"}, {Name: "entity_id"}},
+			DoUpdates: clause.Set{
+				{Column: clause.Column{Name: "detected_at"}, Value: gorm.Expr(
+					"CASE WHEN integrity_issues.resolved_at IS NULL THEN integrity_issues.detected_at ELSE excluded.detected_at END")},
+				{Column: clause.Column{Name: "details"}, Value: gorm.Expr("excluded.details")},
+				{Column: clause.Column{Name: "last_seen_at"}, Value: now},
+				{Column: clause.Column{Name: "resolved_at"}, Value: nil},
+				{Column: clause.Column{Name: "resolved_by"}, Value: nil},
+				{Column: clause.Column{Name: "resolution"}, Value: ""},
+			},
+			Where: clause.Where{Exprs: []clause.Expression{
+				gorm.Expr("integrity_issues.resolution <> ?", models.IntegrityDismissed),
+			}},
+		}).CreateInBatches(&issues, 500).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	stale := db.Model(&models.IntegrityIssue{}).Where("type = ? AND resolved_at IS NULL", check.Type)
+	if len(found) > 0 {
+		stale = stale.Where("entity_id NOT IN ?", found)
+	}
+	return stale.Updates(map[string]interface{}{
+		"resolved_at": now,
+		"resolution":  models.IntegrityCleared,
+	}).Error
+}
+
+// GetIntegritySummary counts the open issues of each check, with what its repair does
+func (h *IntegrityHandler) GetIntegritySummary(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var counts []struct {
+		Type       string
+		OpenIssues int64
+	}
+	if err := db.Model(&models.IntegrityIssue{}).Select("type, COUNT(*) AS open_issues").
+		Where("resolved_at IS NULL").Group("type").Scan(&counts).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch integrity issues"))
+		return
+	}
+	open := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		open[count.Type] = count.OpenIssues
+	}
+
+	var total int64
+	checks := make([]gin.H, len(integrityChecks))
+	for i, check := range integrityChecks {
+		checks[i] = gin.H{
+			"type":        check.Type,
+			"entity_type": check.EntityType,
+			"repair":      check.Repair,
+			"open_issues": open[check.Type],
+		}
+		total += open[check.Type]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"checks":          checks,
+		"open_issues":     total,
+		"last_checked_at": h.lastCheckedAt.Load(),
+	})
+}
+
+// GetIntegrityIssues lists integrity issues, newest first (?type=, ?status=open|resolved|all, default open,
+// ?resolution=, ?page=)
+func (h *IntegrityHandler) GetIntegrityIssues(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	const pageSize = 50
+
+	query := db.Model(&models.IntegrityIssue{})
+	if issueType := c.Query("type"); issueType != "" {
+		query = query.Where("type = ?", issueType)
+	}
+	switch c.DefaultQuery("status", "open") {
+	case "open":
+		query = query.Where("resolved_at IS NULL")
+	case "resolved":
+		query = query.Where("resolved_at IS NOT NULL")
+	case "all":
+	default:
+		apierror.Abort(c, apierror.BadRequest("status must be open, resolved or all"))
+		return
+	}
+	if resolution := c.Query("resolution"); resolution != "" {
+		query = query.Where("resolution = ?", resolution)
+	}
+	var total int64
+	query.Count(&total)
+
+	var issues []models.IntegrityIssue
+	if err := query.Order("detected_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&issues).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch integrity issues"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"issues": issues,
+		"total":  total,
+		"page":   page,
+	})
+}
+
+// RunIntegrityCheck runs the checks now instead of waiting for the job, then returns the summary
+func (h *IntegrityHandler) RunIntegrityCheck(c *gin.Context) {
+	if err := h.RunIntegrityChecks(c.Request.Context()); err != nil {
+		if errors.Is(err, errIntegrityCheckRunning) {
+			apierror.Abort(c, apierror.Conflict("An integrity check is already running"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Integrity check failed").Wrap(err))
+		return
+	}
+	h.GetIntegritySummary(c)
+}
+
+// loadOpenIssue loads the issue of the request, writing an error response unless it is still open
+func (h *IntegrityHandler) loadOpenIssue(c *gin.Context) (models.IntegrityIssue, bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	var issue models.IntegrityIssue
+	if err := db.First(&issue, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Integrity issue not found"))
+		return issue, false
+	}
+	if issue.ResolvedAt != nil {
+		apierror.Abort(c, apierror.Conflict("This issue is already "+issue.Resolution))
+		return issue, false
+	}
+	return issue, true
+}
+
+// RepairIntegrityIssue applies the repair of the issue's check and resolves it. The next check
+// reopens the issue if the repair didn't fix it.
+func (h *IntegrityHandler) RepairIntegrityIssue(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	issue, ok := h.loadOpenIssue(c)
+	if !ok {
+		return
+	}
+	check := integrityCheckOf(issue.Type)
+	if check == nil {
+		apierror.Abort(c, apierror.BadRequest("Issues of this type can't be repaired"))
+		return
+	}
+	adminID := c.MustGet("userID").(uint)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := check.repair(tx, issue, adminID); err != nil {
+			return err
+		}
+		now := clock.Now()
+		if err := tx.Model(&issue).Updates(map[string]interface{}{
+			"resolved_at": now,
+			"resolved_by": adminID,
+			"resolution":  models.IntegrityRepaired,
+		}).Error; err != nil {
+			return err
+		}
+		recordAudit(tx, c, models.AuditIntegrityRepair, "integrity_issue", issue.ID,
+			gin.H{"type": issue.Type, "entity_type": issue.EntityType, "entity_id": issue.EntityID, "details": issue.Details},
+			gin.H{"resolution": models.IntegrityRepaired, "repair": check.Repair})
+		return nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to repair issue").Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Issue repaired"),
+		"repair":  check.Repair,
+		"issue":   issue,
+	})
+}
+
+// DismissIntegrityIssue accepts the anomaly as intended, e.g. a student enrolled for free on purpose
+// ({"note": "..."} optional); the checker won't raise it again
+func (h *IntegrityHandler) DismissIntegrityIssue(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	issue, ok := h.loadOpenIssue(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.Abort(c, apierror.Bind(err))
+			return
+		}
+	}
+
+	adminID := c.MustGet("userID").(uint)
+	if err := db.Model(&issue).Updates(map[string]interface{}{
+		"resolved_at": clock.Now(),
+		"resolved_by": adminID,
+		"resolution":  models.IntegrityDismissed,
+		"note":        truncate(input.Note, 1000),
+	}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to dismiss issue"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Issue dismissed"),
+		"issue":   issue,
+	})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"hash/fnv"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/jobs"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Runs of background jobs are kept this long
+const jobRunRetention = 30 * 24 * time.Hour
+
+// JobHandler is the store of the background jobs: the history of their runs is kept in the database, and
+// an advisory lock of Postgres lets one instance of the API at a time run each job
+type JobHandler struct {
+	DB *gorm.DB
+}
+
+func NewJobHandler(db *gorm.DB) *JobHandler {
+	return &JobHandler{DB: db}
+}
+
+// jobLockKey is the advisory lock of a job; the prefix keeps it apart from other users of advisory locks
+func jobLockKey(job string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte("learning_hub:job:" + job))
+	return int64(hash.Sum64())
+}
+
+// Lock takes the advisory lock of the job on a connection of its own, which holds it until unlocked
+func (h *JobHandler) Lock(ctx context.Context, job string) (func(), bool, error) {
+	db := h.DB.WithContext(ctx)
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	key := jobLockKey(job)
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	return func() {
+		// The job's context may be cancelled by now
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key); err != nil {
+			slog.Error("Failed to unlock job", "job", job, "error", err)
+			// A pooled connection would keep holding the lock; a closed one releases it
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, true, nil
+}
+
+// LastStarted returns when the job last started a run that wasn't interrupted, on any instance
+func (h *JobHandler) LastStarted(ctx context.Context, job string) (time.Time, error) {
+	db := h.DB.WithContext(ctx)
+	var last *time.Time
+	err := db.Model(&models.JobRun{}).
+		Where("job = ? AND status <> ?", job, jobs.ResultInterrupted).
+		Select("MAX(started_at)").Scan(&last).Error
+	if err != nil || last == nil {
+		return time.Time{}, err
+	}
+	return *last, nil
+}
+
+// Record saves a finished run
+func (h *JobHandler) Record(ctx context.Context, run jobs.Run) error {
+	db := h.DB.WithContext(ctx)
+	return db.Create(&models.JobRun{
+		Job:        run.Job,
+		Instance:   run.Instance,
+		Status:     run.Result,
+		Error:      run.Error,
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+		DurationMs: run.FinishedAt.Sub(run.StartedAt).Milliseconds(),
+	}).Error
+}
+
+// PurgeJobRuns deletes the runs older than jobRunRetention. Runs as a scheduled job.
+func (h *JobHandler) PurgeJobRuns(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	return db.Where("started_at < ?", clock.Now().Add(-jobRunRetention)).
+		Delete(&models.JobRun{}).Error
+}
+
+type jobView struct {
+	Name      string         `json:"name"`
+	Interval  string         `json:"interval,omitempty"`
+	Schedule  string         `json:"schedule,omitempty"`
+	Running   bool           `json:"running"` // on the instance answering
+	Stalled   bool           `json:"stalled"`
+	NextRun   *time.Time     `json:"next_run"`
+	LastError string         `json:"last_error,omitempty"`
+	LastRun   *models.JobRun `json:"last_run"` // on any instance
+}
+
+// GetJobs lists the background jobs with their schedule, their state on the instance answering and
+// their last recorded run
+func (h *JobHandler) GetJobs(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var lastRuns []models.JobRun
+	if err := db.
+		Raw("SELECT DISTINCT ON (job) * FROM job_runs ORDER BY job, started_at DESC").
+		Scan(&lastRuns).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch job runs").Wrap(err))
+		return
+	}
+	lastRunByJob := make(map[string]*models.JobRun, len(lastRuns))
+	for i := range lastRuns {
+		lastRunByJob[lastRuns[i].Job] = &lastRuns[i]
+	}
+
+	states, started := jobs.States()
+	now := time.Now()
+	views := make([]jobView, len(states))
+	for i, state := range states {
+		views[i] = jobView{
+			Name:      state.Name,
+			Schedule:  state.Schedule,
+			Running:   state.Running,
+			Stalled:   !started.IsZero() && state.Stalled(started, now),
+			LastError: state.LastError,
+			LastRun:   lastRunByJob[state.Name],
+		}
+		if state.Schedule == "" {
+			views[i].Interval = state.Interval.String()
+		}
+		if !state.NextRun.IsZero() {
+			nextRun := state.NextRun
+			views[i].NextRun = &nextRun
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": views})
+}
+
+// GetJobRuns lists the recorded runs of background jobs, newest first (?job=, ?status=, ?page=)
+func (h *JobHandler) GetJobRuns(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	const pageSize = 50
+
+	query := db.Model(&models.JobRun{})
+	if job := c.Query("job"); job != "" {
+		query = query.Where("job = ?", job)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	query.Count(&total)
+
+	var runs []models.JobRun
+	if err := query.Order("started_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&runs).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch job runs"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"runs":  runs,
+		"total": total,
+		"page":  page,
+	})
+}
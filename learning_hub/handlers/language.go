@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"learning_hub/models"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/validation"
+	"strings"
+)
+
+// languageProblem is the answer to a field holding a language we have no translations for
+func languageProblem(field string) validation.Problem {
+	return validation.Problem{Field: field, Rule: "oneof", Param: strings.Join(i18n.Supported(), " ")}
+}
+
+// userLocale is the language to write to a user in outside of a request, e.g. in notifications
+func userLocale(user models.User) string {
+	if locale, ok := i18n.Normalize(user.PreferredLanguage); ok {
+		return locale
+	}
+	return i18n.DefaultLocale
+}
+
+// PreferredLanguage is the language a user chose, for i18n.SetUserLanguage
+func (h *UserHandler) PreferredLanguage(ctx context.Context, userID uint) string {
+	var user models.User
+	if err := h.DB.WithContext(ctx).Select("id, preferred_language").Limit(1).Find(&user, userID).Error; err != nil {
+		return ""
+	}
+	return user.PreferredLanguage
+}
+
+// RecipientLanguage is the language emails to an address are written in, for email.SetRecipientLanguage
+func (h *UserHandler) RecipientLanguage(ctx context.Context, to string) string {
+	var user models.User
+	if err := h.DB.WithContext(ctx).Select("id, preferred_language").Where("email = ?", to).Limit(1).Find(&user).Error; err != nil {
+		return ""
+	}
+	return user.PreferredLanguage
+}
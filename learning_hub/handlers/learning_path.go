@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"errors"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/i18n"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LearningPathHandler struct {
+	DB *gorm.DB
+}
+
+func NewLearningPathHandler(db *gorm.DB) *LearningPathHandler {
+	return &LearningPathHandler{DB: db}
+}
+
+// pathLesson is a lesson in study order along with the student's completion
+type pathLesson struct {
+	ID          uint   `json:"id"`
+	Title       string `json:"title"`
+	ModuleID    uint   `json:"module_id"`
+	ModuleTitle string `json:"module_title"`
+	Duration    int    `json:"duration"`
+	Completed   bool   `json:"completed"`
+}
+
+// orderedLessons returns the lessons a student works through, in order: the learning path's
+// lessons by position, or every lesson of the course by module and lesson order
+func orderedLessons(db *gorm.DB, courseID, userID uint, pathID *uint) ([]pathLesson, error) {
+	query := db.Table("lessons").
+		Select("lessons.id, lessons.title, lessons.module_id, modules.title AS module_title, lessons.duration, "+
+			"COALESCE(lesson_progresses.completed, false) AS completed").
+		Joins("JOIN modules ON modules.id = lessons.module_id AND modules.deleted_at IS NULL").
+		Joins("LEFT JOIN lesson_progresses ON lesson_progresses.lesson_id = lessons.id AND lesson_progresses.user_id = ? AND lesson_progresses.deleted_at IS NULL", userID).
+		Where("modules.course_id = ? AND lessons.deleted_at IS NULL", courseID)
+
+	if pathID != nil {
+		query = query.
+			Joins("JOIN learning_path_items ON learning_path_items.lesson_id = lessons.id AND learning_path_items.learning_path_id = ?", *pathID).
+			Order("learning_path_items.position")
+	} else {
+		query = query.Order("modules.order_index, modules.id, lessons.order_index, lessons.id")
+	}
+
+	var lessons []pathLesson
+	err := query.Scan(&lessons).Error
+	return lessons, err
+}
+
+// nextIncompleteLesson returns the first lesson not completed yet, or nil when all are done
+func nextIncompleteLesson(lessons []pathLesson) *pathLesson {
+	for i := range lessons {
+		if !lessons[i].Completed {
+			return &lessons[i]
+		}
+	}
+	return nil
+}
+
+// GetLearningPaths lists the learning paths of a course with their lessons in order
+func (h *LearningPathHandler) GetLearningPaths(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var paths []models.LearningPath
+	if err := db.Where("course_id = ?", c.Param("id")).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Items.Lesson", func(db *gorm.DB) *gorm.DB { return db.Select("id, title, module_id, duration") }).
+		Order("id").Find(&paths).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch learning paths"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"learning_paths": paths,
+		"count":          len(paths),
+	})
+}
+
+type learningPathInput struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	LessonIDs   []uint `json:"lesson_ids" binding:"required,min=1"`
+}
+
+// validatePathLessons checks every lesson belongs to the course and appears only once
+func validatePathLessons(db *gorm.DB, courseID uint, lessonIDs []uint) error {
+	seen := make(map[uint]bool, len(lessonIDs))
+	for _, id := range lessonIDs {
+		if seen[id] {
+			return errors.New("a lesson can only appear once in a learning path")
+		}
+		seen[id] = true
+	}
+
+	var count int64
+	db.Model(&models.Lesson{}).
+		Joins("JOIN modules ON modules.id = lessons.module_id AND modules.deleted_at IS NULL").
+		Where("modules.course_id = ? AND lessons.id IN ?", courseID, lessonIDs).
+		Count(&count)
+	if int(count) != len(lessonIDs) {
+		return errors.New("all lessons must belong to this course")
+	}
+	return nil
+}
+
+func pathItems(pathID uint, lessonIDs []uint) []models.LearningPathItem {
+	items := make([]models.LearningPathItem, len(lessonIDs))
+	for i, lessonID := range lessonIDs {
+		items[i] = models.LearningPathItem{LearningPathID: pathID, LessonID: lessonID, Position: i + 1}
+	}
+	return items
+}
+
+// CreateLearningPath adds a learning path to a course
+func (h *LearningPathHandler) CreateLearningPath(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	var input learningPathInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if err := validatePathLessons(db, course.ID, input.LessonIDs); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	path := models.LearningPath{CourseID: course.ID, Title: input.Title, Description: input.Description}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&path).Error; err != nil {
+			return err
+		}
+		path.Items = pathItems(path.ID, input.LessonIDs)
+		return tx.Create(&path.Items).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create learning path"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, path)
+}
+
+// UpdateLearningPath replaces a learning path's details and lessons.
+// Progress of students following it is recalculated.
+func (h *LearningPathHandler) UpdateLearningPath(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	var path models.LearningPath
+	if err := db.Where("course_id = ?", course.ID).First(&path, c.Param("pathId")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Learning path not found"))
+		return
+	}
+
+	var input learningPathInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if err := validatePathLessons(db, course.ID, input.LessonIDs); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	var certificates []*models.Certificate
+	err := db.Transaction(func(tx *gorm.DB) error {
+		path.Title, path.Description = input.Title, input.Description
+		if err := tx.Save(&path).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("learning_path_id = ?", path.ID).Delete(&models.LearningPathItem{}).Error; err != nil {
+			return err
+		}
+		path.Items = pathItems(path.ID, input.LessonIDs)
+		if err := tx.Create(&path.Items).Error; err != nil {
+			return err
+		}
+
+		var err error
+		certificates, err = recalculatePathProgress(tx, path)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update learning path"))
+		return
+	}
+	for _, certificate := range certificates {
+		go sendCertificateEmail(detached(db), *certificate)
+	}
+
+	c.JSON(http.StatusOK, path)
+}
+
+// DeleteLearningPath removes a learning path; its students go back to the full course
+func (h *LearningPathHandler) DeleteLearningPath(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	var path models.LearningPath
+	if err := db.Where("course_id = ?", course.ID).First(&path, c.Param("pathId")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Learning path not found"))
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var userIDs []uint
+		if err := tx.Model(&models.Enrollment{}).Where("learning_path_id = ?", path.ID).Pluck("user_id", &userIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Enrollment{}).Where("learning_path_id = ?", path.ID).
+			Update("learning_path_id", nil).Error; err != nil {
+			return err
+		}
+		// Back on the full course nobody can newly complete it, so no certificates come out of this
+		for _, userID := range userIDs {
+			if _, err := updateEnrollmentProgress(tx, userID, course.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("learning_path_id = ?", path.ID).Delete(&models.LearningPathItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&path).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete learning path"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Learning path deleted successfully")})
+}
+
+// recalculatePathProgress refreshes the progress of every student following a path
+func recalculatePathProgress(tx *gorm.DB, path models.LearningPath) ([]*models.Certificate, error) {
+	var userIDs []uint
+	if err := tx.Model(&models.Enrollment{}).Where("learning_path_id = ?", path.ID).Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var certificates []*models.Certificate
+	for _, userID := range userIDs {
+		certificate, err := updateEnrollmentProgress(tx, userID, path.CourseID)
+		if err != nil {
+			return nil, err
+		}
+		if certificate != nil {
+			certificates = append(certificates, certificate)
+		}
+	}
+	return certificates, nil
+}
+
+// ChooseLearningPath sets the learning path the student follows in a course (null for the full course)
+func (h *LearningPathHandler) ChooseLearningPath(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, _ := c.Get("userID")
+
+	var input struct {
+		LearningPathID *uint `json:"learning_path_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	var enrollment models.Enrollment
+	if err := db.Where("user_id = ? AND course_id = ? AND is_active = ?", userID, c.Param("id"), true).
+		First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Enrollment not found"))
+		return
+	}
+
+	if input.LearningPathID != nil {
+		var path models.LearningPath
+		if err := db.Where("course_id = ?", enrollment.CourseID).First(&path, *input.LearningPathID).Error; err != nil {
+			apierror.Abort(c, apierror.NotFound("Learning path not found"))
+			return
+		}
+	}
+
+	var certificate *models.Certificate
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&enrollment).Update("learning_path_id", input.LearningPathID).Error; err != nil {
+			return err
+		}
+		var err error
+		certificate, err = updateEnrollmentProgress(tx, enrollment.UserID, enrollment.CourseID)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update learning path"))
+		return
+	}
+
+	response := gin.H{
+		"message":          i18n.T(i18n.Locale(c), "Learning path updated"),
+		"learning_path_id": input.LearningPathID,
+		"progress":         calculateDetailedProgress(db, enrollment.CourseID, enrollment.UserID),
+	}
+	if certificate != nil {
+		go sendCertificateEmail(detached(db), *certificate)
+		response["certificate"] = certificate
+		response["message"] = "Course completed! Your certificate has been issued."
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ContinueCourse returns the next lesson to study, following the student's learning path
+func (h *LearningPathHandler) ContinueCourse(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, _ := c.Get("userID")
+
+	var enrollment models.Enrollment
+	if err := db.Where("user_id = ? AND course_id = ? AND is_active = ?", userID, c.Param("id"), true).
+		First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Enrollment not found"))
+		return
+	}
+
+	lessons, err := orderedLessons(db, enrollment.CourseID, enrollment.UserID, enrollment.LearningPathID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch lessons"))
+		return
+	}
+
+	next := nextIncompleteLesson(lessons)
+	completed := 0
+	for _, lesson := range lessons {
+		if lesson.Completed {
+			completed++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"next_lesson":       next, // null once every lesson is done
+		"learning_path_id":  enrollment.LearningPathID,
+		"completed_lessons": completed,
+		"total_lessons":     len(lessons),
+		"lessons":           lessons,
+	})
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/i18n"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -8,6 +11,9 @@ import (
 	"gorm.io/gorm"
 
 	"learning_hub/models"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/mediaurl"
+	"learning_hub/pkg/validation"
 )
 
 type LessonHandler struct {
@@ -20,6 +26,7 @@ func NewLessonHandler(db *gorm.DB) *LessonHandler {
 
 // CreateLesson creates a new lesson within a module
 func (h *LessonHandler) CreateLesson(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	var input struct {
 		Title       string `json:"title" binding:"required"`
 		Content     string `json:"content"`
@@ -28,61 +35,146 @@ func (h *LessonHandler) CreateLesson(c *gin.Context) {
 		Duration    int    `json:"duration"`
 		OrderIndex  int    `json:"order_index"`
 		ModuleID    uint   `json:"module_id" binding:"required"`
+		CaptionsURL string `json:"captions_url"`
+		Transcript  string `json:"transcript"`
+		// markdown (the default) or html
+		ContentFormat string `json:"content_format" binding:"omitempty,oneof=markdown html"`
+		IsPreview     bool   `json:"is_preview"`
+		codeLessonInput
+		completionInput
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	// Verify module exists
 	var module models.Module
-	if err := h.db.First(&module, input.ModuleID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
+	if err := db.Preload("Course").First(&module, input.ModuleID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Module not found"))
+		return
+	}
+	if !canManageCourse(c, db, module.Course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to add lessons to this course"))
 		return
 	}
 
 	lesson := models.Lesson{
 		Title:       input.Title,
 		Content:     input.Content,
-		VideoURL:    input.VideoURL,
-		DocumentURL: input.DocumentURL,
+		VideoURL:    mediaurl.Strip(input.VideoURL),
+		DocumentURL: mediaurl.Strip(input.DocumentURL),
 		Duration:    input.Duration,
 		OrderIndex:  input.OrderIndex,
 		ModuleID:    input.ModuleID,
+		CaptionsURL: mediaurl.Strip(input.CaptionsURL),
+		Transcript:  input.Transcript,
+
+		ContentFormat: input.ContentFormat,
+		IsPreview:     input.IsPreview,
+	}
+	if lesson.ContentFormat == "" {
+		lesson.ContentFormat = models.ContentFormatMarkdown
+	}
+	if err := applyCodeLesson(&lesson, input.codeLessonInput); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	if err := applyCompletion(&lesson, input.completionInput); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
 	}
 
-	if err := h.db.Create(&lesson).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create lesson"})
+	if err := db.Create(&lesson).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create lesson"))
 		return
 	}
+	refreshAccessibilityScore(db, module.CourseID)
+	refreshCourseWorkload(db, module.CourseID)
+
+	// Uploaded videos are converted to HLS in the background, see GET /lessons/:id/video
+	if _, err := queueTranscode(db, lesson.ID, lesson.VideoURL); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to queue transcoding", "lesson_id", lesson.ID, "error", err)
+	}
 
 	c.JSON(http.StatusCreated, lesson)
 }
 
 // GetLesson returns a specific lesson with progress
 func (h *LessonHandler) GetLesson(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	lessonID := c.Param("id")
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	var lesson models.Lesson
-	if err := h.db.Preload("Module").Preload("Module.Course").
+	if err := db.Preload("Module").Preload("Module.Course").
 		First(&lesson, lessonID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+		apierror.Abort(c, apierror.NotFound("Lesson not found"))
+		return
+	}
+
+	if !isOpenPreview(lesson) && !h.canAccessCourseContent(c, lesson.Module.Course) {
+		apierror.Abort(c, apierror.Forbidden("You must be enrolled in this course to access its lessons"))
 		return
 	}
 
 	// Get user progress for this lesson
 	var progress models.LessonProgress
-	h.db.Where("user_id = ? AND lesson_id = ?", userID, lessonID).First(&progress)
+	db.Where("user_id = ? AND lesson_id = ?", userID, lessonID).First(&progress)
+
+	// Pick the content language: ?lang= overrides the student's preference for the course
+	language := c.Query("lang")
+	if language == "" {
+		var enrollment models.Enrollment
+		if err := db.Select("preferred_language").
+			Where("user_id = ? AND course_id = ?", userID, lesson.Module.CourseID).
+			First(&enrollment).Error; err == nil {
+			language = enrollment.PreferredLanguage
+		}
+	}
+
+	var variants []models.LessonVariant
+	db.Where("lesson_id = ?", lesson.ID).Order("language").Find(&variants)
+
+	// An unknown or invalid language simply falls back to the default content
+	language, _ = validation.NormalizeLanguage(language)
+
+	availableLanguages := make([]string, 0, len(variants))
+	servedLanguage := ""
+	for _, variant := range variants {
+		availableLanguages = append(availableLanguages, variant.Language)
+		if language != "" && variant.Language == language {
+			lesson = lesson.Localized(variant)
+			servedLanguage = variant.Language
+		}
+	}
+
+	// Uploaded media is only reachable through short-lived links signed for this user
+	lesson.ContentHTML = renderContent(lesson.Content, lesson.ContentFormat, userID.(uint))
+	signLessonMedia(&lesson, userID.(uint))
+	if !canTeachCourse(c, db, lesson.Module.Course) {
+		hideCodeTests(&lesson)
+	}
+
+	blocks, err := lessonBlocks(c, db, lesson)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch lesson blocks"))
+		return
+	}
+	lesson.Blocks = blocks
 
 	response := gin.H{
-		"lesson":   lesson,
-		"progress": progress,
+		"lesson":              lesson,
+		"progress":            progress,
+		"language":            servedLanguage, // empty when the default content is served
+		"available_languages": availableLanguages,
+		"captions_url":        lesson.CaptionsURL,
+		"media_expires_at":    clock.Now().Add(mediaurl.Expiry()),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -90,6 +182,7 @@ func (h *LessonHandler) GetLesson(c *gin.Context) {
 
 // UpdateLesson updates an existing lesson
 func (h *LessonHandler) UpdateLesson(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	lessonID := c.Param("id")
 
 	var input struct {
@@ -99,16 +192,23 @@ func (h *LessonHandler) UpdateLesson(c *gin.Context) {
 		DocumentURL string `json:"document_url"`
 		Duration    int    `json:"duration"`
 		OrderIndex  int    `json:"order_index"`
+		CaptionsURL string `json:"captions_url"`
+		Transcript  string `json:"transcript"`
+		// markdown (the default) or html
+		ContentFormat string `json:"content_format" binding:"omitempty,oneof=markdown html"`
+		IsPreview     *bool  `json:"is_preview"`
+		codeLessonInput
+		completionInput
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	var lesson models.Lesson
-	if err := h.db.First(&lesson, lessonID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+	if err := db.Preload("Module").First(&lesson, lessonID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Lesson not found"))
 		return
 	}
 
@@ -119,113 +219,373 @@ func (h *LessonHandler) UpdateLesson(c *gin.Context) {
 	if input.Content != "" {
 		lesson.Content = input.Content
 	}
+	if input.ContentFormat != "" {
+		lesson.ContentFormat = input.ContentFormat
+	}
+	if input.IsPreview != nil {
+		lesson.IsPreview = *input.IsPreview
+	}
+	// Clients may send back the signed links they received
+	input.VideoURL, input.DocumentURL = mediaurl.Strip(input.VideoURL), mediaurl.Strip(input.DocumentURL)
+	videoChanged := input.VideoURL != "" && input.VideoURL != lesson.VideoURL
 	if input.VideoURL != "" {
 		lesson.VideoURL = input.VideoURL
 	}
 	if input.DocumentURL != "" {
 		lesson.DocumentURL = input.DocumentURL
 	}
+	if input.CaptionsURL != "" {
+		lesson.CaptionsURL = mediaurl.Strip(input.CaptionsURL)
+	}
+	if input.Transcript != "" {
+		lesson.Transcript = input.Transcript
+	}
 	if input.Duration > 0 {
 		lesson.Duration = input.Duration
 	}
 	if input.OrderIndex >= 0 {
 		lesson.OrderIndex = input.OrderIndex
 	}
+	if err := applyCodeLesson(&lesson, input.codeLessonInput); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	if err := applyCompletion(&lesson, input.completionInput); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
 
-	if err := h.db.Save(&lesson).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update lesson"})
+	if err := db.Omit("Module").Save(&lesson).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update lesson"))
 		return
 	}
+	refreshAccessibilityScore(db, lesson.Module.CourseID)
+	refreshCourseWorkload(db, lesson.Module.CourseID)
+
+	if videoChanged {
+		if _, err := queueTranscode(db, lesson.ID, lesson.VideoURL); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to queue transcoding", "lesson_id", lesson.ID, "error", err)
+		}
+	}
 
 	c.JSON(http.StatusOK, lesson)
 }
 
-// UpdateLessonProgress tracks user progress in a lesson (time tracking version)
+// GetLessonVariants lists the language variants of a lesson
+func (h *LessonHandler) GetLessonVariants(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
+		return
+	}
+
+	var variants []models.LessonVariant
+	if err := db.Where("lesson_id = ?", lesson.ID).Order("language").Find(&variants).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch lesson variants"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"variants": variants,
+		"count":    len(variants),
+	})
+}
+
+// SaveLessonVariant creates or updates the variant of a lesson for a language
+func (h *LessonHandler) SaveLessonVariant(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
+		return
+	}
+
+	language, err := validation.NormalizeLanguage(c.Param("lang"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	var input struct {
+		Title       string `json:"title"`
+		Content     string `json:"content"`
+		VideoURL    string `json:"video_url"`
+		CaptionsURL string `json:"captions_url"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if input.Title == "" && input.Content == "" && input.VideoURL == "" && input.CaptionsURL == "" {
+		apierror.Abort(c, apierror.BadRequest("A variant needs at least one of title, content, video_url or captions_url"))
+		return
+	}
+
+	var variant models.LessonVariant
+	err = db.Where("lesson_id = ? AND language = ?", lesson.ID, language).First(&variant).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		apierror.Abort(c, apierror.Internal("Failed to fetch lesson variant"))
+		return
+	}
+
+	variant.LessonID = lesson.ID
+	variant.Language = language
+	variant.Title = input.Title
+	variant.Content = input.Content
+	variant.VideoURL = mediaurl.Strip(input.VideoURL)
+	variant.CaptionsURL = mediaurl.Strip(input.CaptionsURL)
+
+	if err := db.Save(&variant).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save lesson variant"))
+		return
+	}
+
+	c.JSON(http.StatusOK, variant)
+}
+
+// DeleteLessonVariant removes the variant of a lesson for a language
+func (h *LessonHandler) DeleteLessonVariant(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
+		return
+	}
+
+	language, err := validation.NormalizeLanguage(c.Param("lang"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	result := db.Where("lesson_id = ? AND language = ?", lesson.ID, language).Delete(&models.LessonVariant{})
+	if result.Error != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete lesson variant"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Abort(c, apierror.NotFound("Lesson variant not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Lesson variant deleted successfully")})
+}
+
+// SetCourseLanguage sets the student's preferred content language for an enrolled course.
+// An empty language resets to the course default.
+func (h *LessonHandler) SetCourseLanguage(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	var input struct {
+		Language string `json:"language"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	language := ""
+	if input.Language != "" {
+		normalized, err := validation.NormalizeLanguage(input.Language)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		language = normalized
+	}
+
+	var enrollment models.Enrollment
+	if err := db.Where("user_id = ? AND course_id = ?", userID, c.Param("id")).First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Not enrolled in this course"))
+		return
+	}
+
+	if err := db.Model(&enrollment).Update("preferred_language", language).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update language preference"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            i18n.T(i18n.Locale(c), "Language preference updated"),
+		"course_id":          enrollment.CourseID,
+		"preferred_language": language,
+	})
+}
+
+// canAccessCourseContent reports whether the caller manages the course or is actively enrolled in it
+func (h *LessonHandler) canAccessCourseContent(c *gin.Context, course models.Course) bool {
+	db := h.db.WithContext(c.Request.Context())
+	if canTeachCourse(c, db, course) {
+		return true
+	}
+	userID, _ := c.Get("userID")
+	var count int64
+	db.Model(&models.Enrollment{}).
+		Where("user_id = ? AND course_id = ? AND is_active = ?", userID, course.ID, true).
+		Count(&count)
+	return count > 0
+}
+
+// signLessonMedia replaces the lesson's uploaded video, document and captions URLs with links signed for userID
+func signLessonMedia(lesson *models.Lesson, userID uint) {
+	lesson.VideoURL = mediaurl.Sign(lesson.VideoURL, userID)
+	lesson.DocumentURL = mediaurl.Sign(lesson.DocumentURL, userID)
+	lesson.CaptionsURL = mediaurl.Sign(lesson.CaptionsURL, userID)
+}
+
+// loadManagedLesson loads the :id lesson and checks the caller may manage its course
+func (h *LessonHandler) loadManagedLesson(c *gin.Context) (models.Lesson, bool) {
+	db := h.db.WithContext(c.Request.Context())
+	var lesson models.Lesson
+	if err := db.Preload("Module.Course").First(&lesson, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Lesson not found"))
+		return lesson, false
+	}
+
+	if !canManageCourse(c, db, lesson.Module.Course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to manage this lesson"))
+		return lesson, false
+	}
+	return lesson, true
+}
+
+// UpdateLessonProgress tracks user progress in a lesson (time tracking version). Players also report
+// video_seconds watched since their last report; lessons completed by video complete once enough was watched.
 func (h *LessonHandler) UpdateLessonProgress(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	lessonID := c.Param("id")
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	var input struct {
-		TimeSpent int `json:"time_spent" binding:"required"` // in seconds
+		TimeSpent    int `json:"time_spent" binding:"required"` // in seconds
+		VideoSeconds int `json:"video_seconds" binding:"min=0"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	// Get lesson to access course ID
 	var lesson models.Lesson
-	if err := h.db.Preload("Module").First(&lesson, lessonID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+	if err := db.Preload("Module").First(&lesson, lessonID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Lesson not found"))
 		return
 	}
 
 	// Check if user is enrolled in the course
 	var enrollment models.Enrollment
-	if err := h.db.Where("user_id = ? AND course_id = ?", userID, lesson.Module.CourseID).First(&enrollment).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not enrolled in this course"})
+	if err := db.Where("user_id = ? AND course_id = ?", userID, lesson.Module.CourseID).First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.Forbidden("You are not enrolled in this course"))
 		return
 	}
 
+	now := clock.Now()
 	var progress models.LessonProgress
-	err := h.db.Where("user_id = ? AND lesson_id = ?", userID, lessonID).First(&progress).Error
+	err := db.Where("user_id = ? AND lesson_id = ?", userID, lessonID).First(&progress).Error
 
 	if err == gorm.ErrRecordNotFound {
 		// Create new progress record
 		progress = models.LessonProgress{
 			UserID:    userID.(uint),
-			LessonID:  parseUint(lessonID),
+			LessonID:  lesson.ID,
 			CourseID:  lesson.Module.CourseID,
 			TimeSpent: input.TimeSpent,
 			Completed: false,
 		}
-		if err := h.db.Create(&progress).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create progress"})
+		if input.VideoSeconds > 0 {
+			creditVideoWatched(&progress, lesson, input.VideoSeconds, now)
+		}
+		if err := db.Create(&progress).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to create progress"))
 			return
 		}
 	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		apierror.Abort(c, apierror.Internal("Database error"))
 		return
 	} else {
 		// Update existing progress - accumulate time spent
 		progress.TimeSpent += input.TimeSpent
-		if err := h.db.Save(&progress).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update progress"})
+		if input.VideoSeconds > 0 {
+			creditVideoWatched(&progress, lesson, input.VideoSeconds, now)
+		}
+		if err := db.Omit("User", "Lesson", "Course").Save(&progress).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to update progress"))
 			return
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":  "Lesson progress updated",
+	response := gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Lesson progress updated"),
 		"progress": progress,
-	})
+	}
+
+	if lesson.CompletionCriterion == models.CompletionVideo && !progress.Completed && videoWatchedEnough(lesson, progress) {
+		if reason, err := lessonCompletionBlocked(db, userID.(uint), lesson); err == nil && reason == "" {
+			certificate, err := completeLesson(db, userID.(uint), lesson.ID, lesson.Module.CourseID)
+			if err != nil {
+				apierror.Abort(c, apierror.Internal("Failed to complete lesson"))
+				return
+			}
+			response["lesson_completed"] = true
+			if certificate != nil {
+				go sendCertificateEmail(detached(db), *certificate)
+				response["certificate"] = certificate
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // GetModuleLessons returns all lessons for a module with user progress
 func (h *LessonHandler) GetModuleLessons(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	moduleID := c.Param("moduleId")
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
+		return
+	}
+
+	var module models.Module
+	if err := db.Preload("Course").First(&module, moduleID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Module not found"))
 		return
 	}
 
 	var lessons []models.Lesson
-	if err := h.db.Where("module_id = ?", moduleID).
+	if err := db.Where("module_id = ?", moduleID).
 		Order("order_index ASC").
 		Find(&lessons).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lessons"})
+		apierror.Abort(c, apierror.Internal("Failed to fetch lessons"))
 		return
 	}
 
+	// The outline stays visible, the media only to enrolled students and the course's managers
+	hasAccess := h.canAccessCourseContent(c, module.Course)
+	manager := canTeachCourse(c, db, module.Course)
+	for i := range lessons {
+		if hasAccess {
+			signLessonMedia(&lessons[i], userID.(uint))
+		} else {
+			lessons[i].VideoURL, lessons[i].DocumentURL = "", ""
+		}
+		if !manager {
+			hideCodeTests(&lessons[i])
+		}
+	}
+
 	// Get user progress for all lessons in this module
 	var progress []models.LessonProgress
-	h.db.Where("user_id = ? AND lesson_id IN (SELECT id FROM lessons WHERE module_id = ?)",
+	db.Where("user_id = ? AND lesson_id IN (SELECT id FROM lessons WHERE module_id = ?)",
 		userID, moduleID).Find(&progress)
 
 	progressMap := make(map[uint]models.LessonProgress)
@@ -252,63 +612,69 @@ func (h *LessonHandler) GetModuleLessons(c *gin.Context) {
 
 // DeleteLesson deletes a lesson
 func (h *LessonHandler) DeleteLesson(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	lessonID := c.Param("id")
 
-	var lesson models.Lesson
-	if err := h.db.First(&lesson, lessonID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
 		return
 	}
 
 	// Check if there are any progress records
 	var progressCount int64
-	h.db.Model(&models.LessonProgress{}).Where("lesson_id = ?", lessonID).Count(&progressCount)
+	db.Model(&models.LessonProgress{}).Where("lesson_id = ?", lessonID).Count(&progressCount)
 
 	if progressCount > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete lesson with user progress records"})
+		apierror.Abort(c, apierror.BadRequest("Cannot delete lesson with user progress records"))
 		return
 	}
 
-	if err := h.db.Delete(&lesson).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete lesson"})
+	if err := db.Delete(&lesson).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete lesson"))
 		return
 	}
+	refreshAccessibilityScore(db, lesson.Module.CourseID)
+	refreshCourseWorkload(db, lesson.Module.CourseID)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Lesson deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Lesson moved to trash"),
+		"purge_at": clock.Now().Add(trashRetention),
+	})
 }
 
 // GetLessonAnalytics returns analytics for a lesson (for instructors)
 func (h *LessonHandler) GetLessonAnalytics(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	lessonID := c.Param("id")
 
-	// Verify the user is the instructor of this course
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+	if _, exists := c.Get("userID"); !exists {
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	var lesson models.Lesson
-	if err := h.db.Preload("Module.Course").First(&lesson, lessonID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Lesson not found"})
+	if err := db.Preload("Module.Course").First(&lesson, lessonID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Lesson not found"))
 		return
 	}
 
-	// Check if user is the course instructor
-	if lesson.Module.Course.InstructorID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: You are not the instructor of this course"})
+	// Check if user is on the course staff
+	if !canTeachCourse(c, db, lesson.Module.Course) {
+		apierror.Abort(c, apierror.Forbidden("Access denied: You are not the instructor of this course"))
 		return
 	}
 
 	// Get analytics data
 	var totalEnrollments int64
-	h.db.Model(&models.Enrollment{}).Where("course_id = ?", lesson.Module.CourseID).Count(&totalEnrollments)
+	db.Model(&models.Enrollment{}).Scopes(withoutTestStudents).Where("course_id = ?", lesson.Module.CourseID).Count(&totalEnrollments)
 
 	var completedCount int64
-	h.db.Model(&models.LessonProgress{}).Where("lesson_id = ? AND completed = ?", lessonID, true).Count(&completedCount)
+	db.Model(&models.LessonProgress{}).Scopes(withoutTestStudents).Where("lesson_id = ? AND completed = ?", lessonID, true).
+		Count(&completedCount)
 
 	var averageTimeSpent float64
-	h.db.Model(&models.LessonProgress{}).Where("lesson_id = ?").Select("AVG(time_spent)").Row().Scan(&averageTimeSpent)
+	db.Model(&models.LessonProgress{}).Scopes(withoutTestStudents).Where("lesson_id = ?", lessonID).
+		Select("COALESCE(AVG(time_spent), 0)").Row().Scan(&averageTimeSpent)
 
 	analytics := gin.H{
 		"lesson_id":          lessonID,
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/mediaurl"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxLessonBlocks caps the blocks of a lesson page
+const maxLessonBlocks = 100
+
+var errTooManyBlocks = fmt.Errorf("a lesson can have at most %d blocks", maxLessonBlocks)
+
+// lessonBlockInput holds the fields of a block being added or changed. Omitted fields keep their values.
+type lessonBlockInput struct {
+	Type        *string `json:"type"`
+	Title       *string `json:"title"`
+	Text        *string `json:"text"`
+	URL         *string `json:"url"`
+	Duration    *int    `json:"duration"`
+	CaptionsURL *string `json:"captions_url"`
+	QuizID      *uint   `json:"quiz_id"`
+}
+
+// checkLessonBlock validates a block of a lesson in the course: the fields its type needs and, for quiz
+// blocks, that the quiz is one of the course's
+func checkLessonBlock(db *gorm.DB, block *models.LessonBlock, courseID uint) error {
+	if err := checkBlockFields(block); err != nil {
+		return err
+	}
+	if block.Type != models.BlockQuiz {
+		return nil
+	}
+	var quizzes int64
+	if err := db.Model(&models.Quiz{}).Where("id = ? AND course_id = ?", *block.QuizID, courseID).Count(&quizzes).Error; err != nil {
+		return err
+	}
+	if quizzes == 0 {
+		return errors.New("quiz_id must be a quiz of this course")
+	}
+	return nil
+}
+
+// checkBlockFields checks the block has the fields its type needs and clears those it doesn't use
+func checkBlockFields(block *models.LessonBlock) error {
+	switch block.Type {
+	case models.BlockText:
+		if strings.TrimSpace(block.Text) == "" {
+			return errors.New("text blocks need text")
+		}
+	case models.BlockVideo, models.BlockFile:
+		if block.URL == "" {
+			return fmt.Errorf("%s blocks need a url", block.Type)
+		}
+	case models.BlockEmbed:
+		// Embedded pages run in the student's browser, so only secure external pages are allowed
+		parsed, err := url.Parse(block.URL)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return errors.New("embed blocks need an https url")
+		}
+	case models.BlockQuiz:
+		if block.QuizID == nil {
+			return errors.New("quiz blocks need a quiz_id")
+		}
+	default:
+		return fmt.Errorf("type must be %s, %s, %s, %s or %s",
+			models.BlockText, models.BlockVideo, models.BlockFile, models.BlockEmbed, models.BlockQuiz)
+	}
+	if len([]rune(block.Title)) > 200 || len(block.URL) > 500 || len(block.CaptionsURL) > 500 {
+		return errors.New("title is at most 200 characters and urls at most 500")
+	}
+	if block.Duration < 0 {
+		return errors.New("duration can't be negative")
+	}
+	if block.Type != models.BlockVideo {
+		block.Duration, block.CaptionsURL = 0, ""
+	}
+	if block.Type != models.BlockQuiz {
+		block.QuizID = nil
+	}
+	return nil
+}
+
+// applyLessonBlock copies the given fields of the input to the block
+func applyLessonBlock(block *models.LessonBlock, input lessonBlockInput) {
+	if input.Type != nil {
+		block.Type = strings.TrimSpace(*input.Type)
+	}
+	if input.Title != nil {
+		block.Title = strings.TrimSpace(*input.Title)
+	}
+	if input.Text != nil {
+		block.Text = *input.Text
+	}
+	if input.URL != nil {
+		block.URL = mediaurl.Strip(strings.TrimSpace(*input.URL))
+	}
+	if input.Duration != nil {
+		block.Duration = *input.Duration
+	}
+	if input.CaptionsURL != nil {
+		block.CaptionsURL = mediaurl.Strip(strings.TrimSpace(*input.CaptionsURL))
+	}
+	if input.QuizID != nil {
+		block.QuizID = input.QuizID
+	}
+}
+
+// lessonBlocks returns the lesson's blocks in order as the caller may see them: uploaded media signed for
+// them and, for students, without blocks of unpublished quizzes
+func lessonBlocks(c *gin.Context, db *gorm.DB, lesson models.Lesson) ([]models.LessonBlock, error) {
+	var blocks []models.LessonBlock
+	if err := db.Preload("Quiz", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, title, description, time_limit, max_attempts, passing_score, is_published")
+	}).Where("lesson_id = ?", lesson.ID).Order("order_index, id").Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	staff := canTeachCourse(c, db, lesson.Module.Course)
+	userID := c.MustGet("userID").(uint)
+	visible := make([]models.LessonBlock, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == models.BlockQuiz && !staff && (block.Quiz == nil || !block.Quiz.IsPublished) {
+			continue
+		}
+		block.URL = mediaurl.Sign(block.URL, userID)
+		block.CaptionsURL = mediaurl.Sign(block.CaptionsURL, userID)
+		block.HTML = renderContent(block.Text, models.ContentFormatMarkdown, userID)
+		visible = append(visible, block)
+	}
+	return visible, nil
+}
+
+// GetLessonBlocks returns the blocks of a lesson's page in order
+func (h *LessonHandler) GetLessonBlocks(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	var lesson models.Lesson
+	if err := db.Preload("Module.Course").First(&lesson, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Lesson not found"))
+		return
+	}
+	if !isOpenPreview(lesson) && !h.canAccessCourseContent(c, lesson.Module.Course) {
+		apierror.Abort(c, apierror.Forbidden("You must be enrolled in this course to access its lessons"))
+		return
+	}
+	blocks, err := lessonBlocks(c, db, lesson)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch lesson blocks"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"blocks": blocks, "count": len(blocks)})
+}
+
+// CreateLessonBlock adds a block to a lesson's page ({"type", "title", "text", "url", "duration",
+// "captions_url", "quiz_id"}), at the end or at {"position"} (0 is first)
+func (h *LessonHandler) CreateLessonBlock(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		lessonBlockInput
+		Position *int `json:"position" binding:"omitempty,min=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	block := models.LessonBlock{LessonID: lesson.ID}
+	applyLessonBlock(&block, input.lessonBlockInput)
+	if err := checkLessonBlock(db, &block, lesson.Module.CourseID); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var blocks []models.LessonBlock
+		if err := tx.Select("id").Where("lesson_id = ?", lesson.ID).Order("order_index, id").Find(&blocks).Error; err != nil {
+			return err
+		}
+		if len(blocks) >= maxLessonBlocks {
+			return errTooManyBlocks
+		}
+		position := len(blocks)
+		if input.Position != nil && *input.Position < position {
+			position = *input.Position
+		}
+		block.OrderIndex = position
+		if err := tx.Create(&block).Error; err != nil {
+			return err
+		}
+		// Blocks after it move down one place
+		for i, other := range blocks[position:] {
+			if err := tx.Model(&other).UpdateColumn("order_index", position+i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errTooManyBlocks) {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	} else if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to add the block"))
+		return
+	}
+	refreshCourseWorkload(db, lesson.Module.CourseID)
+	c.JSON(http.StatusCreated, block)
+}
+
+// loadLessonBlock loads the :blockId block of the :id lesson for someone managing its course
+func (h *LessonHandler) loadLessonBlock(c *gin.Context) (models.Lesson, models.LessonBlock, bool) {
+	db := h.db.WithContext(c.Request.Context())
+	var block models.LessonBlock
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
+		return lesson, block, false
+	}
+	if err := db.Where("id = ? AND lesson_id = ?", c.Param("blockId"), lesson.ID).First(&block).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Block not found"))
+		return lesson, block, false
+	}
+	return lesson, block, true
+}
+
+// UpdateLessonBlock changes the given fields of a block
+func (h *LessonHandler) UpdateLessonBlock(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, block, ok := h.loadLessonBlock(c)
+	if !ok {
+		return
+	}
+	var input lessonBlockInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	applyLessonBlock(&block, input)
+	if err := checkLessonBlock(db, &block, lesson.Module.CourseID); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	if err := db.Select("type", "title", "text", "url", "duration", "captions_url", "quiz_id").
+		Updates(&block).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update the block"))
+		return
+	}
+	refreshCourseWorkload(db, lesson.Module.CourseID)
+	c.JSON(http.StatusOK, block)
+}
+
+// DeleteLessonBlock removes a block from a lesson's page
+func (h *LessonHandler) DeleteLessonBlock(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, block, ok := h.loadLessonBlock(c)
+	if !ok {
+		return
+	}
+	if err := db.Delete(&block).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete the block"))
+		return
+	}
+	refreshCourseWorkload(db, lesson.Module.CourseID)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Block deleted")})
+}
+
+// ReorderLessonBlocks puts a lesson's blocks in the given order ({"block_ids"}, all of them)
+func (h *LessonHandler) ReorderLessonBlocks(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		BlockIDs []uint `json:"block_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("block_ids is required"))
+		return
+	}
+
+	var blocks []models.LessonBlock
+	if err := db.Select("id").Where("lesson_id = ?", lesson.ID).Find(&blocks).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch lesson blocks"))
+		return
+	}
+	remaining := make(map[uint]bool, len(blocks))
+	for _, block := range blocks {
+		remaining[block.ID] = true
+	}
+	for _, id := range input.BlockIDs {
+		if !remaining[id] {
+			apierror.Abort(c, apierror.BadRequest(fmt.Sprintf("Block %d isn't on this lesson or is listed twice", id)))
+			return
+		}
+		delete(remaining, id)
+	}
+	if len(remaining) > 0 {
+		apierror.Abort(c, apierror.BadRequest("block_ids must list all of the lesson's blocks"))
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range input.BlockIDs {
+			if err := tx.Model(&models.LessonBlock{}).Where("id = ?", id).UpdateColumn("order_index", i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to reorder the blocks"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Blocks reordered")})
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/clock"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultCompletionVideoPercent = 90
+	// Players go up to 2x, so watched video can't be credited faster than twice the wall clock
+	maxPlaybackRate = 2
+	// What the first report of a lesson video can credit, there is no earlier report to compare with
+	firstVideoReportCredit = 30 * time.Second
+)
+
+type completionInput struct {
+	CompletionCriterion    string `json:"completion_criterion"`
+	CompletionVideoPercent int    `json:"completion_video_percent"`
+}
+
+// applyCompletion validates the completion criterion and applies it to the lesson.
+// Empty fields keep the lesson's current values.
+func applyCompletion(lesson *models.Lesson, input completionInput) error {
+	switch input.CompletionCriterion {
+	case "":
+	case models.CompletionManual, models.CompletionVideo, models.CompletionQuiz:
+		lesson.CompletionCriterion = input.CompletionCriterion
+	default:
+		return fmt.Errorf("completion_criterion must be %s, %s or %s",
+			models.CompletionManual, models.CompletionVideo, models.CompletionQuiz)
+	}
+	if lesson.CompletionCriterion == "" {
+		lesson.CompletionCriterion = models.CompletionManual
+	}
+	if input.CompletionVideoPercent != 0 {
+		if input.CompletionVideoPercent < 1 || input.CompletionVideoPercent > 100 {
+			return fmt.Errorf("completion_video_percent must be between 1 and 100")
+		}
+		lesson.CompletionVideoPercent = input.CompletionVideoPercent
+	}
+
+	if lesson.CompletionCriterion == models.CompletionVideo {
+		if lesson.VideoURL == "" || lesson.Duration <= 0 {
+			return fmt.Errorf("video completion needs a video_url and its duration")
+		}
+		if lesson.CompletionVideoPercent == 0 {
+			lesson.CompletionVideoPercent = defaultCompletionVideoPercent
+		}
+	}
+	return nil
+}
+
+// videoWatchedEnough reports whether the progress meets the lesson's video completion share
+func videoWatchedEnough(lesson models.Lesson, progress models.LessonProgress) bool {
+	return progress.VideoWatchedSeconds*100 >= lesson.CompletionVideoPercent*lesson.Duration*60
+}
+
+// lessonCompletionBlocked returns why the student can't complete the lesson yet, empty when they can.
+// The lesson's completion criterion and code tests are checked on the server, never taken from the client.
+func lessonCompletionBlocked(db *gorm.DB, userID uint, lesson models.Lesson) (string, error) {
+	passed, err := codeTestsPassed(db, userID, lesson.ID)
+	if err != nil {
+		return "", err
+	}
+	if !passed {
+		return "Pass the lesson's code tests to complete it", nil
+	}
+
+	if lesson.Type == models.LessonTypeScorm {
+		var attempt models.ScormAttempt
+		if err := db.Where("user_id = ? AND lesson_id = ?", userID, lesson.ID).Limit(1).Find(&attempt).Error; err != nil {
+			return "", err
+		}
+		if attempt.CompletionStatus != "completed" || attempt.SuccessStatus == "failed" {
+			return "Finish the lesson's SCORM package to complete it", nil
+		}
+	}
+
+	switch lesson.CompletionCriterion {
+	case models.CompletionVideo:
+		var progress models.LessonProgress
+		if err := db.Where("user_id = ? AND lesson_id = ?", userID, lesson.ID).Limit(1).Find(&progress).Error; err != nil {
+			return "", err
+		}
+		if !videoWatchedEnough(lesson, progress) {
+			return fmt.Sprintf("Watch at least %d%% of the video to complete this lesson", lesson.CompletionVideoPercent), nil
+		}
+	case models.CompletionQuiz:
+		// Lessons whose quizzes were all removed or unpublished can be completed by hand again
+		var quizzes int64
+		if err := db.Model(&models.Quiz{}).Where("lesson_id = ? AND is_published = ?", lesson.ID, true).
+			Count(&quizzes).Error; err != nil {
+			return "", err
+		}
+		if quizzes == 0 {
+			return "", nil
+		}
+		var passedAttempts int64
+		if err := db.Model(&models.QuizAttempt{}).
+			Joins("JOIN quizzes ON quizzes.id = quiz_attempts.quiz_id AND quizzes.deleted_at IS NULL").
+			Where("quiz_attempts.user_id = ? AND quizzes.lesson_id = ? AND quizzes.is_published = ? AND quiz_attempts.is_passed = ?",
+				userID, lesson.ID, true, true).
+			Count(&passedAttempts).Error; err != nil {
+			return "", err
+		}
+		if passedAttempts == 0 {
+			return "Pass the lesson's quiz to complete it", nil
+		}
+	}
+	return "", nil
+}
+
+// completeLesson marks the lesson completed for a student actively enrolled in the course and
+// updates the course progress, returning the certificate when that completed the course
+func completeLesson(db *gorm.DB, userID, lessonID, courseID uint) (*models.Certificate, error) {
+	var enrollment models.Enrollment
+	if err := db.Where("user_id = ? AND course_id = ? AND is_active = ?", userID, courseID, true).
+		First(&enrollment).Error; err != nil {
+		return nil, nil
+	}
+
+	var certificate *models.Certificate
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var progress models.LessonProgress
+		err := tx.Where("user_id = ? AND lesson_id = ?", userID, lessonID).First(&progress).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if progress.Completed {
+			return nil
+		}
+		progress.UserID, progress.LessonID, progress.CourseID = userID, lessonID, courseID
+		progress.Completed, progress.CompletedAt = true, clock.Now()
+		if err := tx.Omit("User", "Lesson", "Course").Save(&progress).Error; err != nil {
+			return err
+		}
+		certificate, err = updateEnrollmentProgress(tx, userID, courseID)
+		return err
+	})
+	return certificate, err
+}
+
+// completeQuizLesson completes the lesson a passed quiz is embedded in when the lesson is completed
+// by its quiz
+func completeQuizLesson(db *gorm.DB, userID uint, quiz models.Quiz) (*models.Certificate, error) {
+	if quiz.LessonID == nil {
+		return nil, nil
+	}
+	var lesson models.Lesson
+	if err := db.Select("id, completion_criterion").First(&lesson, *quiz.LessonID).Error; err != nil {
+		return nil, nil
+	}
+	if lesson.CompletionCriterion != models.CompletionQuiz {
+		return nil, nil
+	}
+	return completeLesson(db, userID, lesson.ID, quiz.CourseID)
+}
+
+// creditVideoWatched adds the seconds of video the player reports as watched since its last report.
+// The credit is capped by the time that passed at the fastest playback rate and by the video's length.
+func creditVideoWatched(progress *models.LessonProgress, lesson models.Lesson, seconds int, now time.Time) {
+	limit := firstVideoReportCredit
+	if progress.VideoReportedAt != nil {
+		limit = now.Sub(*progress.VideoReportedAt) * maxPlaybackRate
+	}
+	credit := time.Duration(seconds) * time.Second
+	if credit > limit {
+		credit = limit
+	}
+	if credit > 0 {
+		progress.VideoWatchedSeconds += int(credit / time.Second)
+	}
+	if length := lesson.Duration * 60; length > 0 && progress.VideoWatchedSeconds > length {
+		progress.VideoWatchedSeconds = length
+	}
+	progress.VideoReportedAt = &now
+}
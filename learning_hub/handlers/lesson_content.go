@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/markdown"
+	"learning_hub/pkg/mediaurl"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxContentPreviewSize caps the content instructors preview at once
+const maxContentPreviewSize = 512 << 10
+
+// renderContent returns lesson content as sanitized HTML, with the uploaded files it shows or links signed
+// for userID (visitors, 0, get them unsigned)
+func renderContent(content, format string, userID uint) string {
+	if content == "" {
+		return ""
+	}
+	sign := func(fileURL string) string {
+		if userID == 0 {
+			return mediaurl.Strip(fileURL)
+		}
+		return mediaurl.Sign(mediaurl.Strip(fileURL), userID)
+	}
+	if format == models.ContentFormatHTML {
+		return markdown.Sanitize(content, sign)
+	}
+	return markdown.ToHTML(content, sign)
+}
+
+// PreviewLessonContent renders content as students would see it ({"content", "format"}, markdown by
+// default), without saving anything
+func (h *LessonHandler) PreviewLessonContent(c *gin.Context) {
+	var input struct {
+		Content string `json:"content"`
+		Format  string `json:"format" binding:"omitempty,oneof=markdown html"`
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxContentPreviewSize)
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"html": renderContent(input.Content, input.Format, c.MustGet("userID").(uint))})
+}
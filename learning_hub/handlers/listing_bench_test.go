@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"learning_hub/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The benchmarks run the listing, transcript and track queries against a Postgres database given as a
+// DSN in BENCH_DATABASE_DSN, e.g. "host=localhost user=postgres password=postgres dbname=learnhub
+// sslmode=disable". They work in a schema of their own, dropped once they are done, and are skipped
+// without one:
+//
+//	BENCH_DATABASE_DSN="..." go test ./handlers -run '^$' -bench . -benchmem
+
+const (
+	benchCourses        = 30
+	benchQuizzesPerItem = 2
+	benchTracks         = 5
+	benchPayments       = 40
+)
+
+// benchData is what the benchmarks query: a student enrolled in every course and track, with graded
+// quizzes in each course
+type benchData struct {
+	db        *gorm.DB
+	studentID uint
+}
+
+func benchDB(b *testing.B) benchData {
+	b.Helper()
+	dsn := os.Getenv("BENCH_DATABASE_DSN")
+	if dsn == "" {
+		b.Skip("BENCH_DATABASE_DSN is not set")
+	}
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+	schema := fmt.Sprintf("bench_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		b.Fatalf("create schema: %v", err)
+	}
+	b.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		b.Fatalf("migrate: %v", err)
+	}
+	return benchData{db: db, studentID: seedBench(b, db)}
+}
+
+// seedBench creates the rows of benchData and returns the student's ID
+func seedBench(b *testing.B, db *gorm.DB) uint {
+	b.Helper()
+	must := func(err error) {
+		if err != nil {
+			b.Fatalf("seed: %v", err)
+		}
+	}
+	instructor := models.User{FirstName: "Ada", LastName: "Teacher", Email: "teacher@bench.test", Role: "instructor"}
+	student := models.User{FirstName: "Abebe", LastName: "Student", Email: "student@bench.test", Role: "student"}
+	must(db.Create(&instructor).Error)
+	must(db.Create(&student).Error)
+
+	now := time.Now()
+	courseIDs := make([]uint, benchCourses)
+	for i := range courseIDs {
+		course := models.Course{Title: fmt.Sprintf("Course %d", i), Description: "Benchmark course", Level: "beginner",
+			Price: 100, Published: true, InstructorID: instructor.ID}
+		must(db.Create(&course).Error)
+		courseIDs[i] = course.ID
+		must(db.Create(&models.Enrollment{UserID: student.ID, CourseID: course.ID, IsActive: true, EnrolledAt: now}).Error)
+		for q := 0; q < benchQuizzesPerItem; q++ {
+			quiz := models.Quiz{Title: fmt.Sprintf("Quiz %d", q), CourseID: course.ID, IsPublished: true}
+			must(db.Create(&quiz).Error)
+			must(db.Create(&models.QuizAttempt{UserID: student.ID, QuizID: quiz.ID, AttemptNumber: 1,
+				Score: float64(60 + q*10), IsCompleted: true}).Error)
+		}
+	}
+
+	perTrack := benchCourses / benchTracks
+	for t := 0; t < benchTracks; t++ {
+		track := models.Track{Title: fmt.Sprintf("Track %d", t), InstructorID: instructor.ID, Published: true}
+		must(db.Create(&track).Error)
+		for p, courseID := range courseIDs[t*perTrack : (t+1)*perTrack] {
+			must(db.Create(&models.TrackCourse{TrackID: track.ID, CourseID: courseID, Position: p}).Error)
+		}
+		must(db.Create(&models.TrackEnrollment{UserID: student.ID, TrackID: track.ID}).Error)
+	}
+
+	for p := 0; p < benchPayments; p++ {
+		must(db.Create(&models.Payment{UserID: student.ID, CourseID: courseIDs[p%benchCourses], Amount: 100,
+			Currency: "ETB", ChapaTxRef: fmt.Sprintf("bench-%d", p), Status: models.PaymentStatusSuccess}).Error)
+	}
+	return student.ID
+}
+
+// benchHandler runs handle as a request of userID with role, failing on anything but a 200
+func benchHandler(b *testing.B, handle gin.HandlerFunc, path string, userID uint, role string) {
+	b.Helper()
+	gin.SetMode(gin.TestMode)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodGet, path, nil)
+		c.Set("userID", userID)
+		c.Set("userRole", role)
+		handle(c)
+		if recorder.Code != http.StatusOK {
+			b.Fatalf("%s answered %d: %s", path, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func BenchmarkGetRecentPayments(b *testing.B) {
+	data := benchDB(b)
+	benchHandler(b, NewAdminHandler(data.db).GetRecentPayments, "/api/admin/payments/recent", data.studentID, "admin")
+}
+
+func BenchmarkGetRecentEnrollments(b *testing.B) {
+	data := benchDB(b)
+	benchHandler(b, NewAdminHandler(data.db).GetRecentEnrollments, "/api/admin/enrollments/recent", data.studentID, "admin")
+}
+
+func BenchmarkGetTranscript(b *testing.B) {
+	data := benchDB(b)
+	benchHandler(b, NewGradingHandler(data.db).GetTranscript, "/api/my-transcript", data.studentID, "student")
+}
+
+func BenchmarkGetMyTracks(b *testing.B) {
+	data := benchDB(b)
+	benchHandler(b, NewTrackHandler(data.db).GetMyTracks, "/api/my-tracks", data.studentID, "student")
+}
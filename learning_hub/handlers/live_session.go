@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/meeting"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Students can join this long before a session starts
+	liveSessionEarlyJoin = 15 * time.Minute
+	// Reminders go out this long before a session starts
+	liveSessionReminderLead = time.Hour
+)
+
+type LiveSessionHandler struct {
+	DB *gorm.DB
+}
+
+func NewLiveSessionHandler(db *gorm.DB) *LiveSessionHandler {
+	return &LiveSessionHandler{DB: db}
+}
+
+type liveSessionInput struct {
+	Title           string    `json:"title" binding:"required,max=200"`
+	Description     string    `json:"description"`
+	StartsAt        time.Time `json:"starts_at" binding:"required"`
+	DurationMinutes int       `json:"duration_minutes" binding:"required,min=5,max=480"`
+}
+
+// loadCourseSession loads the :sessionId live session of the :id course the caller manages
+func (h *LiveSessionHandler) loadCourseSession(c *gin.Context) (models.LiveSession, models.Course, bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	var session models.LiveSession
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return session, course, false
+	}
+	if !canManageCourse(c, db, course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to manage this course"))
+		return session, course, false
+	}
+	if err := db.Where("course_id = ?", course.ID).First(&session, c.Param("sessionId")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Live session not found"))
+		return session, course, false
+	}
+	return session, course, true
+}
+
+// notifyLiveSession tells the course's active students about a live session; meant to run in a goroutine
+func notifyLiveSession(db *gorm.DB, course models.Course, session models.LiveSession, title string) {
+	var students []uint
+	if err := db.Model(&models.Enrollment{}).Where("course_id = ? AND is_active = ?", course.ID, true).
+		Pluck("user_id", &students).Error; err != nil {
+		slog.Error("Failed to load students for live session", "live_session_id", session.ID, "error", err)
+		return
+	}
+	for _, studentID := range students {
+		notifyUser(db, studentID, models.NotificationLiveSession, title, gin.H{
+			"session_id": session.ID,
+			"course_id":  course.ID,
+			"title":      session.Title,
+			"starts_at":  session.StartsAt,
+			"status":     session.Status,
+		})
+	}
+}
+
+// CreateLiveSession schedules a live class and creates its meeting with the configured provider
+func (h *LiveSessionHandler) CreateLiveSession(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+	var input liveSessionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if !input.StartsAt.After(clock.Now()) {
+		apierror.Abort(c, apierror.BadRequest("starts_at must be in the future"))
+		return
+	}
+	if !meeting.Enabled() {
+		apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, "Live classes are not available"))
+		return
+	}
+
+	created, err := meeting.Create(c.Request.Context(), meeting.Request{
+		Topic:    course.Title + ": " + strings.TrimSpace(input.Title),
+		StartsAt: input.StartsAt,
+		Duration: time.Duration(input.DurationMinutes) * time.Minute,
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to create meeting", "course_id", course.ID, "error", err)
+		apierror.Abort(c, apierror.New(http.StatusBadGateway, "Failed to create the meeting"))
+		return
+	}
+
+	session := models.LiveSession{
+		CourseID:        course.ID,
+		Title:           strings.TrimSpace(input.Title),
+		Description:     input.Description,
+		StartsAt:        input.StartsAt,
+		DurationMinutes: input.DurationMinutes,
+		Status:          models.LiveSessionScheduled,
+		CreatedByID:     c.MustGet("userID").(uint),
+		Provider:        created.Provider,
+		MeetingID:       created.ID,
+		JoinURL:         created.JoinURL,
+		HostURL:         created.HostURL,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		meeting.Delete(context.Background(), created.Provider, created.ID)
+		apierror.Abort(c, apierror.Internal("Failed to save live session"))
+		return
+	}
+	go notifyLiveSession(detached(db), course, session, "Live class scheduled: "+session.Title)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Live session scheduled"),
+		"session":  session,
+		"join_url": session.JoinURL,
+		"host_url": session.HostURL,
+	})
+}
+
+// UpdateLiveSession changes a scheduled session. A new time gets a new meeting and a new reminder.
+func (h *LiveSessionHandler) UpdateLiveSession(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	session, course, ok := h.loadCourseSession(c)
+	if !ok {
+		return
+	}
+	if session.Status != models.LiveSessionScheduled {
+		apierror.Abort(c, apierror.Conflict("Cancelled sessions can't be changed"))
+		return
+	}
+	var input liveSessionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	updates := map[string]interface{}{
+		"title":            strings.TrimSpace(input.Title),
+		"description":      input.Description,
+		"starts_at":        input.StartsAt,
+		"duration_minutes": input.DurationMinutes,
+	}
+	rescheduled := !input.StartsAt.Equal(session.StartsAt) || input.DurationMinutes != session.DurationMinutes
+	if rescheduled {
+		if !input.StartsAt.After(clock.Now()) {
+			apierror.Abort(c, apierror.BadRequest("starts_at must be in the future"))
+			return
+		}
+		if !meeting.Enabled() {
+			apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, "Live classes are not available"))
+			return
+		}
+		created, err := meeting.Create(c.Request.Context(), meeting.Request{
+			Topic:    course.Title + ": " + strings.TrimSpace(input.Title),
+			StartsAt: input.StartsAt,
+			Duration: time.Duration(input.DurationMinutes) * time.Minute,
+		})
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to create meeting", "live_session_id", session.ID, "error", err)
+			apierror.Abort(c, apierror.New(http.StatusBadGateway, "Failed to create the meeting"))
+			return
+		}
+		if err := meeting.Delete(c.Request.Context(), session.Provider, session.MeetingID); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to delete meeting", "live_session_id", session.ID, "error", err)
+		}
+		updates["provider"], updates["meeting_id"] = created.Provider, created.ID
+		updates["join_url"], updates["host_url"] = created.JoinURL, created.HostURL
+		updates["reminder_sent_at"] = nil
+	}
+	if err := db.Model(&session).Updates(updates).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update live session"))
+		return
+	}
+	if rescheduled {
+		go notifyLiveSession(detached(db), course, session, "Live class rescheduled: "+session.Title)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Live session updated"),
+		"session":  session,
+		"join_url": session.JoinURL,
+		"host_url": session.HostURL,
+	})
+}
+
+// CancelLiveSession cancels a session, removes its meeting and tells the students
+func (h *LiveSessionHandler) CancelLiveSession(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	session, course, ok := h.loadCourseSession(c)
+	if !ok {
+		return
+	}
+	if session.Status == models.LiveSessionCancelled {
+		c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Live session already cancelled")})
+		return
+	}
+	if err := meeting.Delete(c.Request.Context(), session.Provider, session.MeetingID); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to delete meeting", "live_session_id", session.ID, "error", err)
+	}
+	if err := db.Model(&session).Update("status", models.LiveSessionCancelled).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to cancel live session"))
+		return
+	}
+	if session.EndsAt().After(clock.Now()) {
+		go notifyLiveSession(detached(db), course, session, "Live class cancelled: "+session.Title)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Live session cancelled")})
+}
+
+// GetLiveSessions lists a course's live sessions for enrolled students and course managers
+// (?upcoming=true for those not over yet). Managers also get the meeting links.
+func (h *LiveSessionHandler) GetLiveSessions(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+	manager := canTeachCourse(c, db, course)
+	if !manager {
+		var count int64
+		db.Model(&models.Enrollment{}).
+			Where("user_id = ? AND course_id = ? AND is_active = ?", userID, course.ID, true).Count(&count)
+		if count == 0 {
+			apierror.Abort(c, apierror.Forbidden("You must be enrolled in this course to see its live classes"))
+			return
+		}
+	}
+
+	query := db.Where("course_id = ?", course.ID)
+	if c.Query("upcoming") == "true" {
+		query = query.Where("status = ? AND starts_at + duration_minutes * interval '1 minute' > ?",
+			models.LiveSessionScheduled, clock.Now())
+	}
+	var sessions []models.LiveSession
+	if err := query.Order("starts_at").Find(&sessions).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch live sessions"))
+		return
+	}
+
+	var attended []uint
+	db.Model(&models.LiveAttendance{}).Where("user_id = ?", userID).Pluck("session_id", &attended)
+	joined := make(map[uint]bool, len(attended))
+	for _, id := range attended {
+		joined[id] = true
+	}
+
+	now := clock.Now()
+	results := make([]gin.H, len(sessions))
+	for i, session := range sessions {
+		result := gin.H{
+			"session":  session,
+			"ends_at":  session.EndsAt(),
+			"joinable": session.Status == models.LiveSessionScheduled && !now.Before(session.StartsAt.Add(-liveSessionEarlyJoin)) && now.Before(session.EndsAt()),
+			"attended": joined[session.ID],
+		}
+		if manager {
+			result["join_url"], result["host_url"] = session.JoinURL, session.HostURL
+		}
+		results[i] = result
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": results})
+}
+
+// JoinLiveSession records the caller's attendance and returns the meeting link. Students can join
+// from 15 minutes before the start until the scheduled end; course managers any time and as host.
+func (h *LiveSessionHandler) JoinLiveSession(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var session models.LiveSession
+	if err := db.Preload("Course").First(&session, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Live session not found"))
+		return
+	}
+	if session.Status != models.LiveSessionScheduled {
+		apierror.Abort(c, apierror.New(http.StatusGone, "This live session was cancelled"))
+		return
+	}
+	if canTeachCourse(c, db, session.Course) {
+		c.JSON(http.StatusOK, gin.H{"join_url": session.HostURL, "host": true})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	var count int64
+	db.Model(&models.Enrollment{}).
+		Where("user_id = ? AND course_id = ? AND is_active = ?", userID, session.CourseID, true).Count(&count)
+	if count == 0 {
+		apierror.Abort(c, apierror.Forbidden("You must be enrolled in this course to join its live classes"))
+		return
+	}
+	now := clock.Now()
+	if opens := session.StartsAt.Add(-liveSessionEarlyJoin); now.Before(opens) {
+		apierror.Abort(c, apierror.Conflict("This live session opens 15 minutes before it starts").With("opens_at", opens))
+		return
+	}
+	if !now.Before(session.EndsAt()) {
+		apierror.Abort(c, apierror.New(http.StatusGone, "This live session is over"))
+		return
+	}
+
+	var attendance models.LiveAttendance
+	err := db.Where("session_id = ? AND user_id = ?", session.ID, userID).Limit(1).Find(&attendance).Error
+	if err == nil {
+		if attendance.ID == 0 {
+			attendance = models.LiveAttendance{SessionID: session.ID, UserID: userID, FirstJoinedAt: now, LastJoinedAt: now, Joins: 1}
+			err = db.Create(&attendance).Error
+		} else {
+			err = db.Model(&attendance).Updates(map[string]interface{}{
+				"last_joined_at": now,
+				"joins":          gorm.Expr("joins + 1"),
+			}).Error
+		}
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to record attendance"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"join_url": session.JoinURL, "host": false})
+}
+
+// GetLiveSessionAttendance lists the course's active students with whether and when they joined
+func (h *LiveSessionHandler) GetLiveSessionAttendance(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	session, _, ok := h.loadCourseSession(c)
+	if !ok {
+		return
+	}
+
+	var rows []struct {
+		UserID        uint       `json:"user_id"`
+		FirstName     string     `json:"first_name"`
+		LastName      string     `json:"last_name"`
+		Email         string     `json:"email"`
+		FirstJoinedAt *time.Time `json:"first_joined_at"`
+		LastJoinedAt  *time.Time `json:"last_joined_at"`
+		Joins         int        `json:"joins"`
+		Attended      bool       `json:"attended"`
+	}
+	if err := db.Table("enrollments").
+		Select("users.id AS user_id, users.first_name, users.last_name, users.email, "+
+			"live_attendances.first_joined_at, live_attendances.last_joined_at, COALESCE(live_attendances.joins, 0) AS joins, "+
+			"live_attendances.id IS NOT NULL AS attended").
+		Joins("JOIN users ON users.id = enrollments.user_id").
+		Joins("LEFT JOIN live_attendances ON live_attendances.user_id = enrollments.user_id AND live_attendances.session_id = ?", session.ID).
+		Where("enrollments.course_id = ? AND enrollments.is_active = ?", session.CourseID, true).
+		Order("users.last_name, users.first_name").
+		Scan(&rows).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch attendance"))
+		return
+	}
+
+	attended := 0
+	for _, row := range rows {
+		if row.Attended {
+			attended++
+		}
+	}
+	rate := 0.0
+	if len(rows) > 0 {
+		rate = float64(attended) / float64(len(rows)) * 100
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"session":         session,
+		"students":        rows,
+		"enrolled":        len(rows),
+		"attended":        attended,
+		"attendance_rate": rate,
+	})
+}
+
+// SendLiveSessionReminders emails and notifies the students of sessions starting within the hour,
+// once per session; meant to run as a background job
+func (h *LiveSessionHandler) SendLiveSessionReminders(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	now := clock.Now()
+	var sessions []models.LiveSession
+	if err := db.Preload("Course").
+		Where("status = ? AND reminder_sent_at IS NULL AND starts_at > ? AND starts_at <= ?",
+			models.LiveSessionScheduled, now, now.Add(liveSessionReminderLead)).
+		Find(&sessions).Error; err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		// Claim the session first so a slow run can't remind twice
+		result := db.Model(&models.LiveSession{}).Where("id = ? AND reminder_sent_at IS NULL", session.ID).
+			Update("reminder_sent_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		var students []models.User
+		if err := db.Model(&models.User{}).Select("users.id, users.first_name, users.email").
+			Joins("JOIN enrollments ON enrollments.user_id = users.id").
+			Where("enrollments.course_id = ? AND enrollments.is_active = ?", session.CourseID, true).
+			Find(&students).Error; err != nil {
+			return err
+		}
+		title := fmt.Sprintf("Live class starts at %s UTC: %s", session.StartsAt.UTC().Format("15:04"), session.Title)
+		for _, student := range students {
+			notifyUser(db, student.ID, models.NotificationLiveSession, title, gin.H{
+				"session_id": session.ID,
+				"course_id":  session.CourseID,
+				"title":      session.Title,
+				"starts_at":  session.StartsAt,
+				"status":     session.Status,
+			})
+			if err := email.SendLiveSessionReminderEmail(student.Email, student.FirstName, session.Course.Title,
+				session.CourseID, session.ID, session.Title, session.StartsAt, session.DurationMinutes); err != nil {
+				slog.Error("Failed to email live session reminder", "live_session_id", session.ID, "user_id", student.ID, "error", err)
+			}
+		}
+	}
+	return nil
+}
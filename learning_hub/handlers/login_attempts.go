@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recordLoginAttempt logs a password login; an empty reason means it succeeded
+func recordLoginAttempt(db *gorm.DB, c *gin.Context, userID *uint, address, reason string) {
+	attempt := models.LoginAttempt{
+		UserID:    userID,
+		Email:     truncate(address, 100),
+		IP:        c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 300),
+		Success:   reason == "",
+		Reason:    reason,
+		CreatedAt: clock.Now(),
+	}
+	if err := db.Create(&attempt).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to record login attempt", "email", address, "error", err)
+	}
+}
+
+// loginFailed counts a wrong password or two-factor code and locks the account once the limit is reached,
+// emailing the user; otherwise it responds 401 with message
+func (h *UserHandler) loginFailed(c *gin.Context, user models.User, reason, message string) {
+	db := h.DB.WithContext(c.Request.Context())
+	recordLoginAttempt(db, c, &user.ID, user.Email, reason)
+
+	if h.MaxLoginFailures > 0 {
+		var failures int
+		if err := db.Raw("UPDATE users SET failed_login_attempts = failed_login_attempts + 1 WHERE id = ? RETURNING failed_login_attempts",
+			user.ID).Scan(&failures).Error; err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to count failed login", "user_id", user.ID, "error", err)
+		}
+		if failures >= h.MaxLoginFailures {
+			lockedUntil := clock.Now().Add(h.LockoutDuration)
+			if err := db.Model(&user).Updates(map[string]interface{}{
+				"failed_login_attempts": 0,
+				"locked_until":          lockedUntil,
+			}).Error; err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to lock user", "user_id", user.ID, "error", err)
+			}
+			ip := c.ClientIP()
+			go func() {
+				if err := email.SendAccountLockedEmail(user.Email, user.FirstName, failures, ip, lockedUntil); err != nil {
+					slog.Error("Failed to send account locked email", "user_id", user.ID, "error", err)
+				}
+			}()
+			apierror.Abort(c, apierror.New(http.StatusLocked, "Too many failed logins: this account is locked. Try again later or reset your password").
+				WithCode(apierror.CodeAccountLocked).With("locked_until", lockedUntil))
+			return
+		}
+	}
+
+	apierror.Abort(c, apierror.Unauthorized(message).WithCode(apierror.CodeInvalidCredentials))
+}
+
+// GetLoginAttempts lists a user's login attempts, newest first (?failed=true for failures only, ?page=)
+func (h *AdminHandler) GetLoginAttempts(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.Select("id, email, failed_login_attempts, locked_until").First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	const pageSize = 50
+
+	query := db.Model(&models.LoginAttempt{}).Where("user_id = ?", user.ID)
+	if c.Query("failed") == "true" {
+		query = query.Where("success = ?", false)
+	}
+	var total int64
+	query.Count(&total)
+
+	var attempts []models.LoginAttempt
+	if err := query.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&attempts).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch login attempts"))
+		return
+	}
+
+	locked := user.LockedUntil != nil && user.LockedUntil.After(clock.Now())
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":               user.ID,
+		"locked":                locked,
+		"locked_until":          user.LockedUntil,
+		"failed_login_attempts": user.FailedLoginAttempts,
+		"attempts":              attempts,
+		"total":                 total,
+		"page":                  page,
+	})
+}
+
+// UnlockUser lifts a login lockout and clears the count of failed logins
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+
+	before := gin.H{"locked_until": user.LockedUntil, "failed_login_attempts": user.FailedLoginAttempts}
+	if err := db.Model(&user).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to unlock user"))
+		return
+	}
+	recordAudit(db, c, models.AuditUserUnlock, "user", user.ID, before, gin.H{"locked_until": nil, "failed_login_attempts": 0})
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "User unlocked")})
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"encoding/json"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/realtime"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Page sizes of conversation and message lists
+const (
+	conversationPageSize = 20
+	messagePageSize      = 50
+)
+
+// Event telling a sender their messages were read
+const eventMessagesRead = "messages_read"
+
+type MessageHandler struct {
+	DB *gorm.DB
+}
+
+func NewMessageHandler(db *gorm.DB) *MessageHandler {
+	return &MessageHandler{DB: db}
+}
+
+// participant loads the public fields of the people in a conversation
+func participant(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Select("id, first_name, last_name, role")
+}
+
+// otherParticipant returns who receives userID's messages in a conversation
+func otherParticipant(conversation models.Conversation, userID uint) uint {
+	if conversation.StudentID == userID {
+		return conversation.InstructorID
+	}
+	return conversation.StudentID
+}
+
+// loadConversation loads the :id conversation if the caller takes part in it
+func (h *MessageHandler) loadConversation(c *gin.Context) (models.Conversation, bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	var conversation models.Conversation
+	userID := c.MustGet("userID").(uint)
+	if err := db.Where("student_id = ? OR instructor_id = ?", userID, userID).
+		First(&conversation, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Conversation not found"))
+		return conversation, false
+	}
+	return conversation, true
+}
+
+// sendMessage saves a message, notifies the recipient and emails them when they are offline. Only the
+// first unread message of a conversation is emailed so a chat does not turn into a stream of emails.
+func sendMessage(db *gorm.DB, conversation models.Conversation, senderID uint, body string) (models.Message, error) {
+	now := clock.Now()
+	message := models.Message{
+		ConversationID: conversation.ID,
+		SenderID:       senderID,
+		Body:           body,
+		CreatedAt:      now,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		return tx.Model(&conversation).UpdateColumn("last_message_at", now).Error
+	})
+	if err != nil {
+		return message, err
+	}
+
+	recipientID := otherParticipant(conversation, senderID)
+	var sender, recipient models.User
+	db.Select("id, first_name, last_name").First(&sender, senderID)
+	if err := db.Select("id, first_name, email").First(&recipient, recipientID).Error; err != nil {
+		slog.Error("Failed to load message recipients", "message_id", message.ID, "error", err)
+		return message, nil
+	}
+	senderName := strings.TrimSpace(sender.FirstName + " " + sender.LastName)
+
+	var earlierUnread int64
+	db.Model(&models.Message{}).
+		Where("conversation_id = ? AND sender_id = ? AND read_at IS NULL AND id <> ?", conversation.ID, senderID, message.ID).
+		Count(&earlierUnread)
+
+	online := realtime.Online(recipientID)
+	notifyUser(db, recipientID, models.NotificationMessage, "New message from "+senderName, gin.H{
+		"conversation_id": conversation.ID,
+		"message_id":      message.ID,
+		"course_id":       conversation.CourseID,
+		"sender_id":       senderID,
+		"body":            truncate(body, 500),
+	})
+	if !online && earlierUnread == 0 {
+		var course models.Course
+		db.Select("id, title").First(&course, conversation.CourseID)
+		go func() {
+			if err := email.SendMessageEmail(recipient.Email, recipient.FirstName, senderName, course.Title, conversation.ID, body); err != nil {
+				slog.Error("Failed to email message", "message_id", message.ID, "user_id", recipientID, "error", err)
+			}
+		}()
+	}
+	return message, nil
+}
+
+// StartConversation sends a message to a course's instructor, continuing the student's conversation
+// with them when there already is one
+func (h *MessageHandler) StartConversation(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+	if course.InstructorID == userID {
+		apierror.Abort(c, apierror.BadRequest("You cannot message yourself"))
+		return
+	}
+
+	var enrolled int64
+	db.Model(&models.Enrollment{}).
+		Where("user_id = ? AND course_id = ? AND is_active = ?", userID, course.ID, true).
+		Count(&enrolled)
+	if enrolled == 0 {
+		apierror.Abort(c, apierror.Forbidden("You must be enrolled in this course to message its instructor"))
+		return
+	}
+
+	var input struct {
+		Body string `json:"body" binding:"required,max=5000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	body := strings.TrimSpace(input.Body)
+	if body == "" {
+		apierror.Abort(c, apierror.BadRequest("Message cannot be empty"))
+		return
+	}
+
+	now := clock.Now()
+	conversation := models.Conversation{}
+	if err := db.Where(models.Conversation{CourseID: course.ID, StudentID: userID}).
+		Attrs(models.Conversation{InstructorID: course.InstructorID, LastMessageAt: now, CreatedAt: now}).
+		FirstOrCreate(&conversation).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to start conversation"))
+		return
+	}
+
+	message, err := sendMessage(db, conversation, userID, body)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to send message"))
+		return
+	}
+
+	response := gin.H{
+		"conversation": conversation,
+		"message":      message,
+	}
+	if availability := instructorAvailability(db, conversation.InstructorID); availability["away"] == true {
+		response["instructor_availability"] = availability
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetConversations lists the caller's conversations, most recent first, with the last message and unread count
+func (h *MessageHandler) GetConversations(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	query := db.Model(&models.Conversation{}).Where("student_id = ? OR instructor_id = ?", userID, userID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch conversations"))
+		return
+	}
+
+	var conversations []models.Conversation
+	if err := query.
+		Preload("Course", func(db *gorm.DB) *gorm.DB { return db.Unscoped().Select("id, title, thumbnail_url") }).
+		Preload("Student", participant).Preload("Instructor", participant).
+		Order("last_message_at DESC").
+		Offset((page - 1) * conversationPageSize).Limit(conversationPageSize).
+		Find(&conversations).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch conversations"))
+		return
+	}
+
+	if len(conversations) > 0 {
+		ids := make([]uint, len(conversations))
+		for i, conversation := range conversations {
+			ids[i] = conversation.ID
+		}
+
+		var unread []struct {
+			ConversationID uint
+			Count          int64
+		}
+		var last []models.Message
+		if err := db.Model(&models.Message{}).Select("conversation_id, COUNT(*) AS count").
+			Where("conversation_id IN ? AND sender_id <> ? AND read_at IS NULL", ids, userID).
+			Group("conversation_id").Scan(&unread).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to fetch conversations"))
+			return
+		}
+		if err := db.Where("id IN (?)", db.Model(&models.Message{}).Select("MAX(id)").
+			Where("conversation_id IN ?", ids).Group("conversation_id")).
+			Find(&last).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to fetch conversations"))
+			return
+		}
+
+		unreadBy := make(map[uint]int64, len(unread))
+		for _, u := range unread {
+			unreadBy[u.ConversationID] = u.Count
+		}
+		lastBy := make(map[uint]*models.Message, len(last))
+		for i := range last {
+			lastBy[last[i].ConversationID] = &last[i]
+		}
+		for i := range conversations {
+			conversations[i].UnreadCount = unreadBy[conversations[i].ID]
+			conversations[i].LastMessage = lastBy[conversations[i].ID]
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversations": conversations,
+		"total":         total,
+		"page":          page,
+	})
+}
+
+// GetUnreadMessageCount returns how many messages the caller has not read yet
+func (h *MessageHandler) GetUnreadMessageCount(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
+	var count int64
+	if err := db.Model(&models.Message{}).
+		Joins("JOIN conversations ON conversations.id = messages.conversation_id").
+		Where("(conversations.student_id = ? OR conversations.instructor_id = ?) AND messages.sender_id <> ? AND messages.read_at IS NULL",
+			userID, userID, userID).
+		Count(&count).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to count messages"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": count})
+}
+
+// GetMessages returns a page of a conversation's messages, oldest first (?before=<message id> for earlier
+// ones), and marks the messages sent to the caller as read
+func (h *MessageHandler) GetMessages(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	conversation, ok := h.loadConversation(c)
+	if !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+
+	query := db.Where("conversation_id = ?", conversation.ID)
+	if before := c.Query("before"); before != "" {
+		id, err := strconv.ParseUint(before, 10, 64)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("Invalid before"))
+			return
+		}
+		query = query.Where("id < ?", id)
+	}
+
+	var messages []models.Message
+	if err := query.Order("id DESC").Limit(messagePageSize + 1).Find(&messages).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch messages"))
+		return
+	}
+	hasMore := len(messages) > messagePageSize
+	if hasMore {
+		messages = messages[:messagePageSize]
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	now := clock.Now()
+	result := db.Model(&models.Message{}).
+		Where("conversation_id = ? AND sender_id <> ? AND read_at IS NULL", conversation.ID, userID).
+		UpdateColumn("read_at", now)
+	if result.Error != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to mark conversation read", "conversation_id", conversation.ID, "user_id", userID, "error", result.Error)
+	} else if result.RowsAffected > 0 {
+		for i := range messages {
+			if messages[i].SenderID != userID && messages[i].ReadAt == nil {
+				messages[i].ReadAt = &now
+			}
+		}
+		// Let the sender's open sessions show the messages as read
+		if data, err := json.Marshal(gin.H{"conversation_id": conversation.ID, "read_at": now}); err == nil {
+			realtime.Publish(otherParticipant(conversation, userID), realtime.Event{Type: eventMessagesRead, Data: data})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id": conversation.ID,
+		"messages":        messages,
+		"has_more":        hasMore,
+	})
+}
+
+// SendMessage adds a message to a conversation
+func (h *MessageHandler) SendMessage(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	conversation, ok := h.loadConversation(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Body string `json:"body" binding:"required,max=5000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	body := strings.TrimSpace(input.Body)
+	if body == "" {
+		apierror.Abort(c, apierror.BadRequest("Message cannot be empty"))
+		return
+	}
+
+	message, err := sendMessage(db, conversation, c.MustGet("userID").(uint), body)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to send message"))
+		return
+	}
+	c.JSON(http.StatusCreated, message)
+}
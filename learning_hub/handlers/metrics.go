@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/config"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	paymentsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "payments_total",
+		Help: "Payments that reached a status, by provider and status (success, failed, refunded...)",
+	}, []string{"provider", "status"})
+	uploadSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upload_size_bytes",
+		Help:    "Size of uploaded files, by type",
+		Buckets: prometheus.ExponentialBuckets(16<<10, 4, 9),
+	}, []string{"type"})
+)
+
+// countPayment counts a payment reaching its status
+func countPayment(payment models.Payment) {
+	paymentsTotal.WithLabelValues(payment.Provider, string(payment.Status)).Inc()
+}
+
+type MetricsHandler struct {
+	Token   string
+	handler http.Handler
+}
+
+func NewMetricsHandler(cfg *config.Config) *MetricsHandler {
+	return &MetricsHandler{Token: cfg.MetricsToken, handler: promhttp.Handler()}
+}
+
+// GetMetrics serves the metrics of the default Prometheus registry, the Go runtime and process
+// collectors included. With METRICS_TOKEN set, scrapers send it as a bearer token.
+func (h *MetricsHandler) GetMetrics(c *gin.Context) {
+	if h.Token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+h.Token)) != 1 {
+		apierror.Abort(c, apierror.Unauthorized("A valid metrics token is required"))
+		return
+	}
+	h.handler.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/spam"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// moderatedTable describes where a kind of user-posted content is stored. The table needs
+// user_id, created_at and status columns.
+type moderatedTable struct {
+	Table, TextColumn string
+}
+
+// moderatedContent lists the content types that go through the spam check, by content type
+var moderatedContent = map[string]moderatedTable{
+	"review":       {"reviews", "comment"},
+	"forum_thread": {"forum_threads", "body"},
+	"forum_post":   {"forum_posts", "body"},
+}
+
+// moderationExcerptLength caps the text copied into the moderation queue
+const moderationExcerptLength = 500
+
+// checkPosting enforces the hourly posting limit and scores text for spam before it is saved.
+// It responds with 429 and returns false when the user has posted too much.
+func checkPosting(c *gin.Context, db *gorm.DB, contentType, text string) (spam.Result, bool) {
+	userID, _ := c.Get("userID")
+	content := moderatedContent[contentType]
+	now := clock.Now()
+
+	if limit := spam.PostingLimit(); limit > 0 {
+		var lastHour int64
+		db.Table(content.Table).Where("user_id = ? AND created_at >= ?", userID, now.Add(-time.Hour)).
+			Count(&lastHour)
+		if lastHour >= int64(limit) {
+			apierror.Abort(c, apierror.New(http.StatusTooManyRequests, "You are posting too quickly, please try again later").With("limit", limit))
+			return spam.Result{}, false
+		}
+	}
+
+	var recent []string
+	db.Table(content.Table).Where("user_id = ? AND created_at >= ?", userID, now.Add(-24*time.Hour)).
+		Order("created_at DESC").Limit(20).Pluck(content.TextColumn, &recent)
+	var velocity int64
+	db.Table(content.Table).Where("user_id = ? AND created_at >= ?", userID, now.Add(-spam.VelocityWindow)).
+		Count(&velocity)
+
+	return spam.Check(text, recent, int(velocity)), true
+}
+
+// contentStatus is the status new content is saved with
+func contentStatus(result spam.Result) string {
+	if result.Flagged() {
+		return models.ContentPending
+	}
+	return models.ContentPublished
+}
+
+// queueForModeration adds flagged content to the admin moderation queue
+func queueForModeration(db *gorm.DB, contentType string, contentID, userID uint, text string, result spam.Result) error {
+	reasons, err := json.Marshal(result.Reasons)
+	if err != nil {
+		return err
+	}
+	excerpt := []rune(text)
+	if len(excerpt) > moderationExcerptLength {
+		excerpt = excerpt[:moderationExcerptLength]
+	}
+
+	return db.Create(&models.ModerationItem{
+		ContentType: contentType,
+		ContentID:   contentID,
+		UserID:      userID,
+		Excerpt:     string(excerpt),
+		Score:       result.Score,
+		Reasons:     models.JSON(reasons),
+		Status:      "pending",
+		CreatedAt:   clock.Now(),
+	}).Error
+}
+
+type ModerationHandler struct {
+	DB *gorm.DB
+}
+
+func NewModerationHandler(db *gorm.DB) *ModerationHandler {
+	return &ModerationHandler{DB: db}
+}
+
+// GetModerationQueue lists held content (?status=pending|approved|rejected, ?type=review)
+func (h *ModerationHandler) GetModerationQueue(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	status := c.DefaultQuery("status", "pending")
+	if status != "pending" && status != "approved" && status != "rejected" {
+		apierror.Abort(c, apierror.BadRequest("status must be pending, approved or rejected"))
+		return
+	}
+
+	query := db.Preload("User", contactUserFields).Where("status = ?", status)
+	if contentType := c.Query("type"); contentType != "" {
+		query = query.Where("content_type = ?", contentType)
+	}
+
+	var items []models.ModerationItem
+	if err := query.Order("created_at").Limit(200).Find(&items).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch moderation queue"))
+		return
+	}
+
+	views := make([]moderationItemView, len(items))
+	for i, item := range items {
+		views[i] = moderationItemView{ModerationItem: item, User: newUserBrief(item.User)}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items": views,
+		"count": len(items),
+	})
+}
+
+// ModerateItem publishes (approve) or rejects held content
+func (h *ModerationHandler) ModerateItem(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	adminID, _ := c.Get("userID")
+
+	var input struct {
+		Action string `json:"action" binding:"required,oneof=approve reject"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	var item models.ModerationItem
+	if err := db.First(&item, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Moderation item not found"))
+		return
+	}
+	content, ok := moderatedContent[item.ContentType]
+	if !ok {
+		apierror.Abort(c, apierror.BadRequest("Unknown content type "+strconv.Quote(item.ContentType)))
+		return
+	}
+
+	itemStatus, contentState, message := "approved", models.ContentPublished, "Content approved"
+	if input.Action == "reject" {
+		itemStatus, contentState, message = "rejected", models.ContentRejected, "Content rejected"
+	}
+
+	now := clock.Now()
+	reviewerID := adminID.(uint)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(content.Table).Where("id = ?", item.ContentID).
+			Update("status", contentState).Error; err != nil {
+			return err
+		}
+		return tx.Model(&item).Updates(map[string]interface{}{
+			"status":         itemStatus,
+			"reviewed_by_id": reviewerID,
+			"reviewed_at":    now,
+		}).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update content"))
+		return
+	}
+	item.Status, item.ReviewedByID, item.ReviewedAt = itemStatus, &reviewerID, &now
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), message),
+		"item":    item,
+	})
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// notificationChannels is whether a user receives a category by email and in the app
+type notificationChannels struct {
+	Email bool `json:"email"`
+	InApp bool `json:"in_app"`
+}
+
+// notificationPreferences returns a user's choices for every category, defaults included
+func notificationPreferences(db *gorm.DB, userID uint) (map[string]notificationChannels, error) {
+	preferences := make(map[string]notificationChannels, len(models.NotificationCategories))
+	for category, enabled := range models.NotificationCategories {
+		preferences[category] = notificationChannels{Email: enabled, InApp: enabled}
+	}
+
+	var saved []models.NotificationPreference
+	if err := db.Where("user_id = ?", userID).Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range saved {
+		if _, ok := preferences[p.Category]; ok {
+			preferences[p.Category] = notificationChannels{Email: p.Email, InApp: p.InApp}
+		}
+	}
+	return preferences, nil
+}
+
+// notificationPreference returns a user's choice for one category
+func notificationPreference(db *gorm.DB, userID uint, category string) (notificationChannels, error) {
+	enabled := models.NotificationCategories[category]
+	channels := notificationChannels{Email: enabled, InApp: enabled}
+
+	var saved models.NotificationPreference
+	err := db.Where("user_id = ? AND category = ?", userID, category).Limit(1).Find(&saved).Error
+	if err != nil {
+		return channels, err
+	}
+	if saved.ID != 0 {
+		channels = notificationChannels{Email: saved.Email, InApp: saved.InApp}
+	}
+	return channels, nil
+}
+
+// saveNotificationPreference stores a user's choice for one category
+func saveNotificationPreference(db *gorm.DB, userID uint, category string, channels notificationChannels) error {
+	var saved models.NotificationPreference
+	if err := db.Where("user_id = ? AND category = ?", userID, category).Limit(1).Find(&saved).Error; err != nil {
+		return err
+	}
+	saved.UserID, saved.Category = userID, category
+	saved.Email, saved.InApp = channels.Email, channels.InApp
+	saved.UpdatedAt = clock.Now()
+	return db.Save(&saved).Error
+}
+
+// wantsInApp reports whether a user receives in-app notifications of a type
+func wantsInApp(db *gorm.DB, userID uint, kind string) bool {
+	category, ok := models.NotificationTypeCategories[kind]
+	if !ok {
+		return true
+	}
+	channels, err := notificationPreference(db, userID, category)
+	if err != nil {
+		slog.Error("Failed to load notification preferences", "user_id", userID, "error", err)
+	}
+	return channels.InApp
+}
+
+type NotificationPreferenceHandler struct {
+	DB *gorm.DB
+}
+
+func NewNotificationPreferenceHandler(db *gorm.DB) *NotificationPreferenceHandler {
+	return &NotificationPreferenceHandler{DB: db}
+}
+
+// AllowEmail is the email package's recipient filter: it looks up the user of an address and
+// whether they want emails of the category. Unknown addresses always get the email.
+func (h *NotificationPreferenceHandler) AllowEmail(ctx context.Context, to, category string) (uint, bool) {
+	db := h.DB.WithContext(ctx)
+	var user models.User
+	if err := db.Select("id, is_test_student").Where("email = ?", to).Limit(1).Find(&user).Error; err != nil || user.ID == 0 {
+		return 0, true
+	}
+	if user.IsTestStudent {
+		return user.ID, false
+	}
+	channels, err := notificationPreference(db, user.ID, category)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load notification preferences", "user_id", user.ID, "error", err)
+	}
+	return user.ID, channels.Email
+}
+
+// GetNotificationPreferences returns the user's email and in-app settings per category
+func (h *NotificationPreferenceHandler) GetNotificationPreferences(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
+	preferences, err := notificationPreferences(db, userID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch notification preferences"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preferences": preferences})
+}
+
+// UpdateNotificationPreferences changes the given channels of the given categories, e.g.
+// {"preferences": {"marketing": {"email": true}, "grading": {"in_app": false}}}
+func (h *NotificationPreferenceHandler) UpdateNotificationPreferences(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
+	var input struct {
+		Preferences map[string]struct {
+			Email *bool `json:"email"`
+			InApp *bool `json:"in_app"`
+		} `json:"preferences" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	for category := range input.Preferences {
+		if _, ok := models.NotificationCategories[category]; !ok {
+			apierror.Abort(c, apierror.BadRequest("Unknown notification category: "+category))
+			return
+		}
+	}
+
+	preferences, err := notificationPreferences(db, userID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch notification preferences"))
+		return
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for category, change := range input.Preferences {
+			channels := preferences[category]
+			if change.Email != nil {
+				channels.Email = *change.Email
+			}
+			if change.InApp != nil {
+				channels.InApp = *change.InApp
+			}
+			if err := saveNotificationPreference(tx, userID, category, channels); err != nil {
+				return err
+			}
+			preferences[category] = channels
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save notification preferences"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     i18n.T(i18n.Locale(c), "Notification preferences saved"),
+		"preferences": preferences,
+	})
+}
+
+// Unsubscribe turns off a category of emails from the signed link in an email, without signing in.
+// Also answers one-click unsubscribe POSTs from mail clients.
+func (h *NotificationPreferenceHandler) Unsubscribe(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, err := strconv.ParseUint(c.Query("user"), 10, 64)
+	category := c.Query("category")
+	if err != nil || !email.ValidUnsubscribeToken(uint(userID), category, c.Query("token")) {
+		apierror.Abort(c, apierror.BadRequest("Invalid or expired unsubscribe link"))
+		return
+	}
+	if _, ok := models.NotificationCategories[category]; !ok {
+		apierror.Abort(c, apierror.BadRequest("Unknown notification category: "+category))
+		return
+	}
+
+	channels, err := notificationPreference(db, uint(userID), category)
+	if err == nil {
+		channels.Email = false
+		err = saveNotificationPreference(db, uint(userID), category, channels)
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to unsubscribe"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "You will no longer receive these emails. You can turn them back on in your profile."),
+		"category": category,
+	})
+}
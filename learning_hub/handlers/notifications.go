@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/realtime"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Comments are sent this often on idle streams so proxies keep the connection open
+const streamHeartbeat = 25 * time.Second
+
+// Deleted notifications are kept this long so devices can sync the deletion
+const notificationTombstoneRetention = 30 * 24 * time.Hour
+
+// Events telling a user's other devices that notifications changed
+const (
+	eventNotificationState   = "notification_state"
+	eventNotificationDeleted = "notification_deleted"
+)
+
+// notifyUser stores a notification and pushes it to the user's open connections, unless they turned
+// off its category. Failures are logged: notifications never fail the action that caused them.
+func notifyUser(db *gorm.DB, userID uint, kind, title string, data interface{}) {
+	if !wantsInApp(db, userID, kind) {
+		return
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Failed to encode notification", "kind", kind, "user_id", userID, "error", err)
+		return
+	}
+	now := clock.Now()
+	notification := models.Notification{
+		UserID:    userID,
+		Type:      kind,
+		Title:     title,
+		Data:      models.JSON(payload),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		slog.Error("Failed to save notification", "kind", kind, "user_id", userID, "error", err)
+		return
+	}
+	publishNotification(notification)
+}
+
+// notifyAdmins sends a notification to every admin
+func notifyAdmins(db *gorm.DB, kind, title string, data interface{}) {
+	var admins []uint
+	db.Model(&models.User{}).Where("role = ?", "admin").Pluck("id", &admins)
+	for _, adminID := range admins {
+		notifyUser(db, adminID, kind, title, data)
+	}
+}
+
+func publishNotification(notification models.Notification) {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return
+	}
+	realtime.Publish(notification.UserID, realtime.Event{ID: notification.ID, Type: notification.Type, Data: data})
+}
+
+// publishStateChange pushes a change of existing notifications to all of a user's devices. These
+// events have no ID: devices that were offline catch up through GET /notifications/sync.
+func publishStateChange(userID uint, kind string, change gin.H) {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return
+	}
+	realtime.Publish(userID, realtime.Event{Type: kind, Data: data})
+}
+
+type NotificationHandler struct {
+	DB *gorm.DB
+}
+
+func NewNotificationHandler(db *gorm.DB) *NotificationHandler {
+	return &NotificationHandler{DB: db}
+}
+
+// GetNotifications lists the user's latest notifications (?unread=true for unread ones only)
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, _ := c.Get("userID")
+
+	query := db.Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	var notifications []models.Notification
+	if err := query.Order("id DESC").Limit(50).Find(&notifications).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch notifications"))
+		return
+	}
+
+	var unread int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unread":        unread,
+	})
+}
+
+// MarkNotificationsRead marks one notification (:id) or, without an id, all of the user's notifications
+// as read, and tells the user's other devices
+func (h *NotificationHandler) MarkNotificationsRead(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
+	now := clock.Now()
+	query := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	change := gin.H{"read_at": now, "all": true}
+	if id := c.Param("id"); id != "" {
+		notificationID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("Invalid notification ID"))
+			return
+		}
+		query = query.Where("id = ?", notificationID)
+		change = gin.H{"read_at": now, "ids": []uint64{notificationID}}
+	}
+	result := query.Updates(map[string]interface{}{"read_at": now, "updated_at": now})
+	if result.Error != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update notifications"))
+		return
+	}
+	if result.RowsAffected > 0 {
+		publishStateChange(userID, eventNotificationState, change)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Notifications marked as read"),
+		"updated": result.RowsAffected,
+	})
+}
+
+// MarkNotificationUnread marks a notification as unread again on all of the user's devices
+func (h *NotificationHandler) MarkNotificationUnread(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
+	var notification models.Notification
+	if err := db.Where("user_id = ?", userID).First(&notification, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Notification not found"))
+		return
+	}
+	if notification.ReadAt != nil {
+		if err := db.Model(&notification).Updates(map[string]interface{}{"read_at": nil, "updated_at": clock.Now()}).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to update notification"))
+			return
+		}
+		publishStateChange(userID, eventNotificationState, gin.H{"read_at": nil, "ids": []uint{notification.ID}})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Notification marked as unread")})
+}
+
+// DeleteNotification deletes a notification, leaving a tombstone for devices that sync later
+func (h *NotificationHandler) DeleteNotification(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
+	var notification models.Notification
+	if err := db.Where("user_id = ?", userID).First(&notification, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Notification not found"))
+		return
+	}
+
+	now := clock.Now()
+	if err := db.Model(&notification).Updates(map[string]interface{}{"deleted_at": now, "updated_at": now}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete notification"))
+		return
+	}
+	publishStateChange(userID, eventNotificationDeleted, gin.H{"ids": []uint{notification.ID}})
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Notification deleted")})
+}
+
+// SyncNotifications returns the user's notifications created, read, unread or deleted since
+// ?since= (the synced_at of the previous sync, RFC 3339). Deleted ones come back with deleted_at set.
+// When since is older than the tombstones are kept, reset tells the device to reload its list.
+func (h *NotificationHandler) SyncNotifications(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+	now := clock.Now()
+
+	since, err := time.Parse(time.RFC3339Nano, c.Query("since"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("since must be an RFC 3339 timestamp, such as the synced_at of the last sync"))
+		return
+	}
+	if since.Before(now.Add(-notificationTombstoneRetention)) {
+		c.JSON(http.StatusOK, gin.H{
+			"reset":     true,
+			"synced_at": now,
+		})
+		return
+	}
+
+	// Changes are matched inclusively, so a change made in the same instant as the last sync is not missed
+	var changes []models.Notification
+	if err := db.Unscoped().Where("user_id = ? AND updated_at >= ?", userID, since).
+		Order("updated_at").Limit(500).Find(&changes).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to sync notifications"))
+		return
+	}
+
+	syncedAt := now
+	if len(changes) == 500 {
+		// More to fetch: continue from the last change returned
+		syncedAt = changes[len(changes)-1].UpdatedAt
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": changes,
+		"reset":         false,
+		"has_more":      len(changes) == 500,
+		"synced_at":     syncedAt,
+	})
+}
+
+// PurgeNotificationTombstones removes deleted notifications once devices had time to sync them.
+// Runs as a scheduled job.
+func (h *NotificationHandler) PurgeNotificationTombstones(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	return db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", clock.Now().Add(-notificationTombstoneRetention)).
+		Delete(&models.Notification{}).Error
+}
+
+// StreamNotifications pushes the user's notifications as server-sent events. Clients resuming with
+// Last-Event-ID (or ?last_event_id=) first receive what they missed.
+func (h *NotificationHandler) StreamNotifications(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
+	// Subscribe before replaying so nothing published in between is lost
+	events, cancel := realtime.Subscribe(userID)
+	defer cancel()
+
+	var lastID uint64
+	if value := c.GetHeader("Last-Event-ID"); value != "" {
+		lastID, _ = strconv.ParseUint(value, 10, 64)
+	} else if value := c.Query("last_event_id"); value != "" {
+		lastID, _ = strconv.ParseUint(value, 10, 64)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx would otherwise buffer the stream
+	c.Status(http.StatusOK)
+	// Streams stay open for hours, past the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to lift the write deadline of a stream", "error", err)
+	}
+
+	w := c.Writer
+	send := func(event realtime.Event) error {
+		var err error
+		if event.ID == 0 {
+			// State changes carry no ID so the client's Last-Event-ID keeps pointing at notifications
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+		} else {
+			if uint64(event.ID) <= lastID {
+				return nil // already sent by the replay
+			}
+			lastID = uint64(event.ID)
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+		}
+		if err != nil {
+			return err
+		}
+		w.Flush()
+		return nil
+	}
+
+	fmt.Fprintf(w, "retry: 5000\n\n")
+	w.Flush()
+
+	if lastID > 0 {
+		var missed []models.Notification
+		if err := db.Where("user_id = ? AND id > ?", userID, lastID).Order("id").Limit(100).Find(&missed).Error; err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to replay notifications", "user_id", userID, "error", err)
+		}
+		for _, notification := range missed {
+			data, _ := json.Marshal(notification)
+			if send(realtime.Event{ID: notification.ID, Type: notification.Type, Data: data}) != nil {
+				return
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return // fell behind or the server is shutting down, the client reconnects and catches up
+			}
+			if send(event) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		}
+	}
+}
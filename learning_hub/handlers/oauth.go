@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/oauth"
+	"learning_hub/pkg/settings"
+	"learning_hub/pkg/utils"
+	"learning_hub/pkg/validation"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateMaxAge = 600 // seconds the user has to log in at the provider
+)
+
+// oauthError is why a social login failed, passed to the web app as ?error= in the fragment
+type oauthError string
+
+const (
+	oauthCancelled        oauthError = "cancelled"
+	oauthInvalidState     oauthError = "invalid_state"
+	oauthProviderError    oauthError = "provider_error"
+	oauthNoEmail          oauthError = "no_email"
+	oauthEmailInUse       oauthError = "email_in_use"       // unverified provider email of an existing account
+	oauthEmailNotAllowed  oauthError = "email_not_allowed"  // outside the allowed registration domains
+	oauthEmailNotVerified oauthError = "email_not_verified" // verification email sent
+	oauthAccountLocked    oauthError = "account_locked"     // too many failed logins
+	oauthServerError      oauthError = "server_error"
+)
+
+func (h *UserHandler) oauthRedirectURI(provider string) string {
+	return h.AppBaseURL + "/api/auth/" + provider + "/callback"
+}
+
+// oauthFinish sends the browser back to the web app's /auth/callback with the outcome in the URL fragment,
+// which isn't sent to servers or kept in their logs
+func oauthFinish(c *gin.Context, values url.Values) {
+	c.Redirect(http.StatusFound, settings.FrontendURL()+"/auth/callback#"+values.Encode())
+}
+
+func oauthFail(c *gin.Context, reason oauthError) {
+	oauthFinish(c, url.Values{"error": {string(reason)}})
+}
+
+// StartOAuthLogin sends the browser to log in with Google or Microsoft; the provider then sends it to
+// the callback. A cookie ties the callback to this browser.
+func (h *UserHandler) StartOAuthLogin(c *gin.Context) {
+	provider, ok := oauth.Get(c.Param("provider"))
+	if !ok {
+		apierror.Abort(c, apierror.NotFound("This login provider is not available").With("providers", oauth.Enabled()))
+		return
+	}
+	state, err := idgen.Token("", 16)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to start login"))
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, oauthStateMaxAge, "/api/auth", "",
+		strings.HasPrefix(h.AppBaseURL, "https://"), true)
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(state, h.oauthRedirectURI(provider.Name)))
+}
+
+// OAuthCallback finishes a social login: it finds or creates the user of the provider's identity and
+// sends the browser to the web app with a JWT, or a two-factor challenge token, in the URL fragment
+func (h *UserHandler) OAuthCallback(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	provider, ok := oauth.Get(c.Param("provider"))
+	if !ok {
+		apierror.Abort(c, apierror.NotFound("This login provider is not available").With("providers", oauth.Enabled()))
+		return
+	}
+
+	state, _ := c.Cookie(oauthStateCookie)
+	c.SetCookie(oauthStateCookie, "", -1, "/api/auth", "", strings.HasPrefix(h.AppBaseURL, "https://"), true)
+	if c.Query("error") != "" {
+		oauthFail(c, oauthCancelled)
+		return
+	}
+	if state == "" || c.Query("state") != state || c.Query("code") == "" {
+		oauthFail(c, oauthInvalidState)
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), c.Query("code"), h.oauthRedirectURI(provider.Name))
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "Social login failed", "provider", provider.Name, "error", err)
+		oauthFail(c, oauthProviderError)
+		return
+	}
+
+	user, reason := oauthUser(db, identity)
+	if reason != "" {
+		oauthFail(c, reason)
+		return
+	}
+	if !user.EmailVerified {
+		recordLoginAttempt(db, c, &user.ID, user.Email, "unverified")
+		oauthFail(c, oauthEmailNotVerified)
+		return
+	}
+	if user.LockedUntil != nil && user.LockedUntil.After(clock.Now()) {
+		recordLoginAttempt(db, c, &user.ID, user.Email, "locked")
+		oauthFail(c, oauthAccountLocked)
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		token, _, err := createTwoFactorChallenge(db, user.ID)
+		if err != nil {
+			oauthFail(c, oauthServerError)
+			return
+		}
+		oauthFinish(c, url.Values{"two_factor_required": {"true"}, "challenge_token": {token}})
+		return
+	}
+	token, err := h.issueLoginToken(c, user)
+	if err != nil {
+		oauthFail(c, oauthServerError)
+		return
+	}
+	oauthFinish(c, url.Values{"token": {token}})
+}
+
+// oauthUser returns the user linked to the identity. An identity seen for the first time is linked to
+// the account with its email when the provider verified the address, or else gets a new student account.
+func oauthUser(db *gorm.DB, identity *oauth.Identity) (models.User, oauthError) {
+	var user models.User
+	now := clock.Now()
+
+	var link models.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&link).Error
+	if err == nil {
+		if err := db.First(&user, link.UserID).Error; err == nil {
+			db.Model(&link).Update("last_login_at", now)
+			return user, ""
+		}
+		// The user was deleted; the identity starts over
+		db.Delete(&link)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, oauthServerError
+	}
+
+	if identity.Email == "" {
+		return user, oauthNoEmail
+	}
+	link = models.UserIdentity{
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		CreatedAt:   now,
+		LastLoginAt: &now,
+	}
+
+	err = db.Where("LOWER(email) = ? AND is_test_student = ?", strings.ToLower(identity.Email), false).First(&user).Error
+	if err == nil {
+		// Anyone can put any address on a provider account, so only a verified one proves it is theirs
+		if !identity.EmailVerified {
+			return user, oauthEmailInUse
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			link.UserID = user.ID
+			if err := tx.Create(&link).Error; err != nil {
+				return err
+			}
+			if !user.EmailVerified {
+				user.EmailVerified = true
+				return tx.Model(&user).Updates(map[string]interface{}{"email_verified": true, "verification_token": nil}).Error
+			}
+			return nil
+		})
+		if err != nil {
+			return user, oauthServerError
+		}
+		slog.Info("Linked social login", "provider", identity.Provider, "user_id", user.ID)
+		return user, ""
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, oauthServerError
+	}
+
+	if valid, _ := validation.IsValidEmail(identity.Email); !valid {
+		return user, oauthEmailNotAllowed
+	}
+	user, err = createOAuthUser(db, identity, &link)
+	if err != nil {
+		slog.Error("Failed to create user for social login", "provider", identity.Provider, "error", err)
+		return user, oauthServerError
+	}
+	return user, ""
+}
+
+// createOAuthUser creates the student account of a new identity and links it. It has an unknown
+// password, which the user can set with a password reset. An address the provider didn't verify is
+// verified by email as on registration.
+func createOAuthUser(db *gorm.DB, identity *oauth.Identity, link *models.UserIdentity) (models.User, error) {
+	password, err := idgen.Token("", 32)
+	if err != nil {
+		return models.User{}, err
+	}
+	firstName := identity.FirstName
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(identity.Email, "@")
+	}
+	user := models.User{
+		FirstName:     truncate(firstName, 100),
+		LastName:      truncate(identity.LastName, 100),
+		Email:         identity.Email,
+		Password:      password,
+		Role:          "student",
+		EmailVerified: identity.EmailVerified,
+	}
+	var verificationToken string
+	if !identity.EmailVerified {
+		if verificationToken, err = utils.GenerateVerificationToken(); err != nil {
+			return user, err
+		}
+		sentAt := clock.Now()
+		user.VerificationToken, user.VerificationSentAt = &verificationToken, &sentAt
+	}
+	if err := user.HashPassword(); err != nil {
+		return user, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		link.UserID = user.ID
+		return tx.Create(link).Error
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if verificationToken != "" {
+		go func() {
+			if err := email.SendVerificationEmail(user.Email, user.FirstName+" "+user.LastName, verificationToken); err != nil {
+				slog.Error("Failed to send verification email", "user_id", user.ID, "error", err)
+			}
+		}()
+	}
+	return user, nil
+}
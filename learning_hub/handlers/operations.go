@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/chapa"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/receipt"
+	"learning_hub/pkg/utils"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Routine tasks of operators, run with the learning_hub CLI instead of through the API. They act as the
+// system: audit entries they record have no actor.
+
+// ErrUserExists is returned by CreateAdmin when the email is taken and promoting its user wasn't asked for
+var ErrUserExists = errors.New("a user with this email already exists")
+
+// CreateAdmin creates a verified admin account, or with promote makes the existing user with the email
+// an admin. It reports whether the account was created.
+func (h *AdminHandler) CreateAdmin(ctx context.Context, emailAddress, firstName, lastName, password string, promote bool) (*models.User, bool, error) {
+	db := h.DB.WithContext(ctx)
+	emailAddress = strings.ToLower(strings.TrimSpace(emailAddress))
+
+	var user models.User
+	err := db.Where("email = ?", emailAddress).First(&user).Error
+	if err == nil {
+		if !promote {
+			return nil, false, ErrUserExists
+		}
+		if user.Role == "admin" {
+			return &user, false, nil
+		}
+		previousRole := user.Role
+		if err := db.Model(&user).Update("role", "admin").Error; err != nil {
+			return nil, false, err
+		}
+		user.Role = "admin"
+		recordAudit(db, nil, models.AuditRoleChange, "user", user.ID, map[string]string{"role": previousRole}, map[string]string{"role": user.Role})
+		// Tokens carry the role, so the old one would keep working until they expire
+		if _, err := revokeSessions(db, user.ID, ""); err != nil {
+			return nil, false, fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		return &user, false, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	if err := checkPasswordLength(password); err != nil {
+		return nil, false, err
+	}
+	user = models.User{
+		FirstName:     firstName,
+		LastName:      lastName,
+		Email:         emailAddress,
+		Role:          "admin",
+		Password:      password,
+		EmailVerified: true,
+	}
+	if err := user.HashPassword(); err != nil {
+		return nil, false, err
+	}
+	if err := db.Create(&user).Error; err != nil {
+		return nil, false, err
+	}
+	recordAudit(db, nil, models.AuditAdminCreate, "user", user.ID, nil, map[string]string{"email": user.Email})
+	return &user, true, nil
+}
+
+// SetUserPassword replaces the password of the user with the email, as a reset would: pending reset codes
+// and the lockout are cleared, every session is revoked and the user is told by email
+func (h *AdminHandler) SetUserPassword(ctx context.Context, emailAddress, password string) (*models.User, error) {
+	if err := checkPasswordLength(password); err != nil {
+		return nil, err
+	}
+	db := h.DB.WithContext(ctx)
+	var user models.User
+	if err := db.Where("email = ?", strings.ToLower(strings.TrimSpace(emailAddress))).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	user.Password = password
+	if err := user.HashPassword(); err != nil {
+		return nil, err
+	}
+	user.ResetToken = nil
+	user.ResetSentAt = nil
+	user.ResetExpiresAt = nil
+	user.FailedLoginAttempts = 0
+	user.LockedUntil = nil
+	if err := db.Save(&user).Error; err != nil {
+		return nil, err
+	}
+	recordAudit(db, nil, models.AuditPasswordSet, "user", user.ID, nil, nil)
+	if _, err := revokeSessions(db, user.ID, ""); err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if err := email.SendPasswordResetSuccessEmail(user.Email, user.FirstName+" "+user.LastName); err != nil {
+		return &user, fmt.Errorf("password set, but the notification email failed: %w", err)
+	}
+	return &user, nil
+}
+
+// checkPasswordLength applies the length rule registration binds passwords with
+func checkPasswordLength(password string) error {
+	if len(password) < 6 || len(password) > 72 {
+		return errors.New("the password must be 6 to 72 characters long")
+	}
+	return nil
+}
+
+// ReissueCertificate issues the certificate with the ID or verification code again, e.g. after a grade
+// was corrected or it was revoked by mistake: the grade is recomputed, the verification code replaced,
+// the validity restarted and any revocation lifted. The holder is emailed the new certificate.
+func (h *CertificateHandler) ReissueCertificate(ctx context.Context, idOrCode string) (*models.Certificate, error) {
+	db := h.DB.WithContext(ctx)
+	var certificate models.Certificate
+	if err := db.Where("id = ? OR verification_code = ?", idOrCode, idOrCode).First(&certificate).Error; err != nil {
+		return nil, err
+	}
+	before := certificate
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := clock.Now()
+		expiryDate := now.AddDate(2, 0, 0)
+		certificate.IssueDate = now
+		certificate.ExpiryDate = &expiryDate
+		certificate.ExpiryNotifiedAt = nil
+		certificate.VerificationCode = generateVerificationCode()
+		certificate.RevokedAt = nil
+		certificate.RevokedBy = nil
+		certificate.RevocationReason = ""
+		certificate.Grade, certificate.GradePercent = "", nil
+		grades, err := courseGrades(tx, certificate.CourseID, []uint{certificate.UserID})
+		if err != nil {
+			return err
+		}
+		if percent, ok := grades[certificate.UserID]; ok {
+			scale, _ := effectiveScale(tx, certificate.CourseID)
+			certificate.Grade = scale.Letter(percent)
+			certificate.GradePercent = &percent
+		}
+		if err := tx.Save(&certificate).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Enrollment{}).Where("id = ?", certificate.EnrollmentID).
+			Update("certificate_issued_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordAudit(db, nil, models.AuditCertReissue, "enrollment", certificate.EnrollmentID,
+		map[string]interface{}{"verification_code": before.VerificationCode, "grade": before.Grade, "revoked_at": before.RevokedAt},
+		map[string]interface{}{"verification_code": certificate.VerificationCode, "grade": certificate.Grade})
+
+	if err := mailCertificate(db, certificate); err != nil {
+		return &certificate, fmt.Errorf("certificate reissued, but the email failed: %w", err)
+	}
+	return &certificate, nil
+}
+
+// ResendCertificateEmail emails the holder of the certificate with the ID or verification code again
+func (h *CertificateHandler) ResendCertificateEmail(ctx context.Context, idOrCode string) error {
+	db := h.DB.WithContext(ctx)
+	var certificate models.Certificate
+	if err := db.Where("id = ? OR verification_code = ?", idOrCode, idOrCode).First(&certificate).Error; err != nil {
+		return err
+	}
+	if certificate.RevokedAt != nil {
+		return errors.New("the certificate is revoked")
+	}
+	return mailCertificate(db, certificate)
+}
+
+// Reconcile runs payment reconciliation once and returns its report
+func (h *ReconciliationHandler) Reconcile(ctx context.Context) (*models.PaymentReconciliation, error) {
+	return h.reconcile(ctx)
+}
+
+// SendNewVerificationEmail sends the user with the email a new verification link, replacing the old one
+func (h *UserHandler) SendNewVerificationEmail(ctx context.Context, emailAddress string) error {
+	db := h.DB.WithContext(ctx)
+	var user models.User
+	if err := db.Where("email = ?", strings.ToLower(strings.TrimSpace(emailAddress))).First(&user).Error; err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return errors.New("the email is already verified")
+	}
+
+	token, err := utils.GenerateVerificationToken()
+	if err != nil {
+		return err
+	}
+	if err := db.Model(&user).Updates(map[string]interface{}{
+		"verification_token":   token,
+		"verification_sent_at": clock.Now(),
+	}).Error; err != nil {
+		return err
+	}
+	return email.SendVerificationEmail(user.Email, user.FirstName+" "+user.LastName, token)
+}
+
+// ResendPaymentReceipt emails the receipt of the successful payment with the ID or transaction reference
+// to its payer again
+func (h *PaymentHandler) ResendPaymentReceipt(ctx context.Context, idOrTxRef string) error {
+	db := h.db.WithContext(ctx)
+	query := db.Where("chapa_tx_ref = ?", idOrTxRef)
+	if id, err := strconv.ParseUint(idOrTxRef, 10, 64); err == nil {
+		query = db.Where("id = ? OR chapa_tx_ref = ?", id, idOrTxRef)
+	}
+	var payment models.Payment
+	if err := query.First(&payment).Error; err != nil {
+		return err
+	}
+	if payment.Status != models.PaymentStatusSuccess {
+		return fmt.Errorf("the payment is %s, receipts are only sent for successful payments", payment.Status)
+	}
+
+	var user models.User
+	var course models.Course
+	if err := db.First(&user, payment.UserID).Error; err != nil {
+		return err
+	}
+	if err := db.First(&course, payment.CourseID).Error; err != nil {
+		return err
+	}
+	pdf := receipt.RenderPDF(paymentReceipt(db, payment, email.ReceiptLocale()))
+	return email.SendPaymentSuccessEmail(ctx, user.Email, user.FirstName, course.Title, payment.Amount, payment.Currency,
+		payment.ChapaTxRef, chapa.PaymentMethodLabel(payment.PaymentMethod), pdf)
+}
@@ -0,0 +1,742 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/chapa"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/settings"
+	"learning_hub/pkg/validation"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Most seats one purchase may buy
+const maxSeatPurchase = 1000
+
+var errNoSeat = errors.New("no seat left")
+
+// OrganizationHandler lets companies buy course seats for their employees and follow their progress
+type OrganizationHandler struct {
+	DB *gorm.DB
+}
+
+func NewOrganizationHandler(db *gorm.DB) *OrganizationHandler {
+	return &OrganizationHandler{DB: db}
+}
+
+// managedOrganization loads the organization of the request (:id) if the caller is one of its admins or a
+// platform admin, and responds otherwise
+func (h *OrganizationHandler) managedOrganization(c *gin.Context) (models.Organization, bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	var org models.Organization
+	if err := db.First(&org, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Organization not found"))
+		return org, false
+	}
+	if role, _ := c.Get("userRole"); role == "admin" {
+		return org, true
+	}
+	var count int64
+	db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ? AND role = ?", org.ID, c.MustGet("userID"), models.OrgRoleAdmin).
+		Count(&count)
+	if count == 0 {
+		apierror.Abort(c, apierror.Forbidden("Only the organization's admins can do this"))
+		return org, false
+	}
+	return org, true
+}
+
+// CreateOrganization creates an organization ({"name"}) with the caller as its admin
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Name string `json:"name" binding:"required,max=200"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("A name of at most 200 characters is required"))
+		return
+	}
+	if rejectTestStudent(c, db, "create organizations") {
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+	org := models.Organization{Name: strings.TrimSpace(input.Name), CreatedByID: userID}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         userID,
+			Role:           models.OrgRoleAdmin,
+		}).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create organization"))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"organization": org})
+}
+
+// GetMyOrganizations lists the organizations the caller belongs to, with their role in each
+func (h *OrganizationHandler) GetMyOrganizations(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var rows []struct {
+		ID   uint   `json:"id"`
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	if err := db.Table("organizations").
+		Select("organizations.id, organizations.name, organization_members.role").
+		Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ? AND organizations.deleted_at IS NULL", c.MustGet("userID")).
+		Order("organizations.name").Scan(&rows).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch organizations"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organizations": rows})
+}
+
+// GetOrganization shows an organization with its members and seats
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	org, ok := h.managedOrganization(c)
+	if !ok {
+		return
+	}
+	var members []models.OrganizationMember
+	if err := db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, first_name, last_name, email, invitation_sent_at")
+	}).Where("organization_id = ?", org.ID).Order("created_at").Find(&members).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch members"))
+		return
+	}
+	var seats []models.OrganizationSeat
+	if err := db.Preload("Course", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, title")
+	}).Where("organization_id = ?", org.ID).Order("course_id").Find(&seats).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch seats"))
+		return
+	}
+
+	memberList := make([]gin.H, len(members))
+	for i, member := range members {
+		memberList[i] = gin.H{
+			"user_id":    member.UserID,
+			"first_name": member.User.FirstName,
+			"last_name":  member.User.LastName,
+			"email":      member.User.Email,
+			"role":       member.Role,
+			"invited":    member.User.InvitationSentAt != nil, // hasn't set a password yet
+			"joined_at":  member.CreatedAt,
+		}
+	}
+	seatList := make([]gin.H, len(seats))
+	for i, seat := range seats {
+		seatList[i] = gin.H{
+			"course_id":    seat.CourseID,
+			"course_title": seat.Course.Title,
+			"purchased":    seat.Purchased,
+			"used":         seat.Used,
+			"available":    seat.Purchased - seat.Used,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"organization": org, "members": memberList, "seats": seatList})
+}
+
+// creditSeats adds purchased seats of a course to an organization
+func creditSeats(tx *gorm.DB, orgID, courseID uint, seats int) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "organization_id"}, {Name: "course_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"purchased":  gorm.Expr("organization_seats.purchased + ?", seats),
+			"updated_at": clock.Now(),
+		}),
+	}).Create(&models.OrganizationSeat{OrganizationID: orgID, CourseID: courseID, Purchased: seats}).Error
+}
+
+// BuySeats buys seats of a course for the organization ({"course_id", "seats"}), at the course's price in
+// the buyer's country for each. Seats of free courses are credited right away.
+func (h *OrganizationHandler) BuySeats(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	org, ok := h.managedOrganization(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		CourseID uint `json:"course_id" binding:"required"`
+		Seats    int  `json:"seats" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("course_id and a number of seats are required"))
+		return
+	}
+	if input.Seats > maxSeatPurchase {
+		apierror.Abort(c, apierror.BadRequest(fmt.Sprintf("Buy at most %d seats at a time", maxSeatPurchase)))
+		return
+	}
+	var course models.Course
+	if err := db.First(&course, input.CourseID).Error; err != nil || !course.Published {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	price, available, err := coursePriceIn(db, course, requestCountry(c, db))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check course availability"))
+		return
+	}
+	if !available {
+		apierror.Abort(c, apierror.Forbidden("This course is not available in your country"))
+		return
+	}
+
+	if price == 0 {
+		if err := creditSeats(db, org.ID, course.ID, input.Seats); err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to add seats"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Added %d seats of this free course", input.Seats)})
+		return
+	}
+	if !deviceAllowed(c, db, "check out") {
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, c.MustGet("userID")).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch user details"))
+		return
+	}
+	key, ok := idempotencyKey(c)
+	if !ok {
+		return
+	}
+	amount := price * float64(input.Seats)
+	txRef := models.GenerateTxRef()
+	payment := models.Payment{
+		UserID:         user.ID,
+		CourseID:       course.ID,
+		OrganizationID: &org.ID,
+		Seats:          input.Seats,
+		Amount:         amount,
+		Currency:       courseCurrency(course),
+		ChapaTxRef:     txRef,
+		IdempotencyKey: key,
+		TaxRate:        settings.TaxRate(),
+		Status:         models.PaymentStatusPending,
+	}
+	if resumeCheckout(c, db, payment) {
+		return
+	}
+
+	// TEST MODE: If using test keys, simulate payment
+	if strings.Contains(chapa.GetSecretKey(), "test") {
+		payment.Status = models.PaymentStatusSuccess
+		payment.PaymentMethod = chapa.MethodTest
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&payment).Error; err != nil {
+				return err
+			}
+			return creditSeats(tx, org.ID, course.ID, input.Seats)
+		})
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to add seats"))
+			return
+		}
+		recordDevice(db, c, user.ID, models.DeviceEventCheckout)
+		c.JSON(http.StatusOK, gin.H{
+			"message":         i18n.T(i18n.Locale(c), "TEST MODE: Payment completed successfully"),
+			"transaction_ref": txRef,
+			"payment_id":      payment.ID,
+			"test_mode":       true,
+		})
+		return
+	}
+
+	checkoutURL, err := startCheckout(c.Request.Context(), checkout{
+		Payment:     &payment,
+		User:        user,
+		Title:       course.Title,
+		Description: fmt.Sprintf("%d seats of %s", input.Seats, course.Title),
+		Meta: map[string]interface{}{
+			"user_id":         user.ID,
+			"course_id":       course.ID,
+			"organization_id": org.ID,
+			"seats":           input.Seats,
+		},
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to initialize payment").Wrap(err))
+		return
+	}
+	if !createPayment(c, db, &payment) {
+		return
+	}
+	recordDevice(db, c, user.ID, models.DeviceEventCheckout)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         i18n.T(i18n.Locale(c), "Payment initialized successfully"),
+		"checkout_url":    checkoutURL,
+		"transaction_ref": txRef,
+		"payment_id":      payment.ID,
+	})
+}
+
+// AddMember adds a user to the organization ({"email", "role", "first_name", "last_name"}). Users with an
+// account join right away; for others a student account is created and invited by email, which needs
+// their names.
+func (h *OrganizationHandler) AddMember(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	org, ok := h.managedOrganization(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Email     string `json:"email" binding:"required"`
+		Role      string `json:"role"`
+		FirstName string `json:"first_name" binding:"max=100"`
+		LastName  string `json:"last_name" binding:"max=100"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	address := strings.TrimSpace(input.Email)
+	if !validation.IsEmailFormat(address) || len(address) > 100 {
+		apierror.Abort(c, apierror.BadRequest("Email is not a valid address"))
+		return
+	}
+	if input.Role == "" {
+		input.Role = models.OrgRoleMember
+	}
+	if input.Role != models.OrgRoleMember && input.Role != models.OrgRoleAdmin {
+		apierror.Abort(c, apierror.BadRequest("Role must be member or admin"))
+		return
+	}
+
+	var user models.User
+	err := db.Where("LOWER(email) = ?", strings.ToLower(address)).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Abort(c, apierror.Internal("Failed to look up the user"))
+		return
+	}
+	existing := err == nil
+	if existing {
+		var count int64
+		db.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", org.ID, user.ID).Count(&count)
+		if count > 0 {
+			apierror.Abort(c, apierror.Conflict("This user is already a member"))
+			return
+		}
+	} else if strings.TrimSpace(input.FirstName) == "" || strings.TrimSpace(input.LastName) == "" {
+		apierror.Abort(c, apierror.BadRequest("No account uses this email: first_name and last_name are needed to invite them"))
+		return
+	}
+
+	adminID := c.MustGet("userID").(uint)
+	var token string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if !existing {
+			password, err := idgen.Token("", 32)
+			if err != nil {
+				return err
+			}
+			user = models.User{
+				FirstName: strings.TrimSpace(input.FirstName),
+				LastName:  strings.TrimSpace(input.LastName),
+				Email:     address,
+				Password:  password,
+				Role:      "student",
+			}
+			if err := user.HashPassword(); err != nil {
+				return err
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if token, err = inviteUser(tx, &user, adminID); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&models.OrganizationMember{OrganizationID: org.ID, UserID: user.ID, Role: input.Role}).Error
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to add organization member", "email", address, "organization_id", org.ID, "error", err)
+		apierror.Abort(c, apierror.Internal("Failed to add member"))
+		return
+	}
+
+	if existing {
+		notifyUser(db, user.ID, models.NotificationOrganization, "You were added to "+org.Name, gin.H{
+			"organization_id": org.ID,
+			"role":            input.Role,
+		})
+	} else {
+		var admin models.User
+		db.Select("id, first_name, last_name").First(&admin, adminID)
+		invitedBy := strings.TrimSpace(admin.FirstName+" "+admin.LastName) + " of " + org.Name
+		go func() {
+			if err := email.SendInvitationEmail(user.Email, user.FirstName+" "+user.LastName, invitedBy, user.Role, token); err != nil {
+				slog.Error("Failed to send invitation", "user_id", user.ID, "error", err)
+			}
+		}()
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Member added"),
+		"user_id": user.ID,
+		"role":    input.Role,
+		"invited": !existing,
+	})
+}
+
+// releaseSeat takes back an organization's course from a member. The seat returns to the organization if
+// they haven't completed a lesson yet; otherwise it stays used and the enrollment is only deactivated.
+func releaseSeat(tx *gorm.DB, enrollment models.Enrollment) (bool, error) {
+	if enrollment.CompletedLessons > 0 {
+		return false, tx.Model(&enrollment).Update("is_active", false).Error
+	}
+	if err := tx.Delete(&enrollment).Error; err != nil {
+		return false, err
+	}
+	return true, tx.Model(&models.OrganizationSeat{}).
+		Where("organization_id = ? AND course_id = ? AND used > 0", *enrollment.OrganizationID, enrollment.CourseID).
+		UpdateColumn("used", gorm.Expr("used - 1")).Error
+}
+
+// RemoveMember removes a user from the organization and takes back the courses it assigned them
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	org, ok := h.managedOrganization(c)
+	if !ok {
+		return
+	}
+	var member models.OrganizationMember
+	if err := db.Where("organization_id = ? AND user_id = ?", org.ID, c.Param("userId")).First(&member).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Member not found"))
+		return
+	}
+	if member.Role == models.OrgRoleAdmin {
+		var admins int64
+		db.Model(&models.OrganizationMember{}).Where("organization_id = ? AND role = ?", org.ID, models.OrgRoleAdmin).Count(&admins)
+		if admins <= 1 {
+			apierror.Abort(c, apierror.Conflict("The organization needs another admin before its last one leaves"))
+			return
+		}
+	}
+
+	released := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var enrollments []models.Enrollment
+		if err := tx.Where("organization_id = ? AND user_id = ? AND is_active = ?", org.ID, member.UserID, true).
+			Find(&enrollments).Error; err != nil {
+			return err
+		}
+		for _, enrollment := range enrollments {
+			freed, err := releaseSeat(tx, enrollment)
+			if err != nil {
+				return err
+			}
+			if freed {
+				released++
+			}
+		}
+		return tx.Delete(&member).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to remove member"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Member removed"), "seats_released": released})
+}
+
+// assignmentResult reports what assigning a course did for one member
+type assignmentResult struct {
+	UserID       uint   `json:"user_id"`
+	Status       string `json:"status"` // assigned or error
+	EnrollmentID uint   `json:"enrollment_id,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// assignCourse enrolls a member in the course on one of the organization's seats. Taking the seat only
+// succeeds while some are left, so concurrent assignments can't oversell them.
+func assignCourse(tx *gorm.DB, orgID, courseID, userID uint) (*models.Enrollment, error) {
+	result := tx.Model(&models.OrganizationSeat{}).
+		Where("organization_id = ? AND course_id = ? AND used < purchased", orgID, courseID).
+		UpdateColumn("used", gorm.Expr("used + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errNoSeat
+	}
+
+	// A course taken back earlier is given back with its progress
+	var enrollment models.Enrollment
+	err := tx.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error
+	if err == nil {
+		return &enrollment, tx.Model(&enrollment).Updates(map[string]interface{}{"is_active": true, "organization_id": orgID}).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	enrollment = models.Enrollment{
+		UserID:         userID,
+		CourseID:       courseID,
+		OrganizationID: &orgID,
+		IsActive:       true,
+		EnrolledAt:     clock.Now(),
+	}
+	if err := tx.Create(&enrollment).Error; err != nil {
+		return nil, err
+	}
+	emitEnrollmentCreated(tx, enrollment)
+	return &enrollment, nil
+}
+
+// AssignCourse enrolls members in a course ({"course_id", "user_ids"}), using a seat for each. Members
+// already taking the course are skipped; the report says for each member what happened.
+func (h *OrganizationHandler) AssignCourse(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	org, ok := h.managedOrganization(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		CourseID uint   `json:"course_id" binding:"required"`
+		UserIDs  []uint `json:"user_ids" binding:"required,min=1,max=1000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("course_id and user_ids are required"))
+		return
+	}
+	var course models.Course
+	if err := db.Select("id, title").First(&course, input.CourseID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	var memberIDs []uint
+	db.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id IN ?", org.ID, input.UserIDs).
+		Pluck("user_id", &memberIDs)
+	members := make(map[uint]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		members[id] = true
+	}
+
+	results := make([]assignmentResult, len(input.UserIDs))
+	assigned := 0
+	for i, userID := range input.UserIDs {
+		results[i] = assignmentResult{UserID: userID, Status: "error"}
+		if !members[userID] {
+			results[i].Error = "not a member of the organization"
+			continue
+		}
+		var active int64
+		db.Model(&models.Enrollment{}).Where("user_id = ? AND course_id = ? AND is_active = ?", userID, course.ID, true).
+			Count(&active)
+		if active > 0 {
+			results[i].Error = "already enrolled in the course"
+			continue
+		}
+
+		var enrollment *models.Enrollment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			enrollment, err = assignCourse(tx, org.ID, course.ID, userID)
+			return err
+		})
+		if errors.Is(err, errNoSeat) {
+			results[i].Error = "no seat left for the course"
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to assign course", "course_id", course.ID, "user_id", userID, "organization_id", org.ID, "error", err)
+			results[i].Error = "failed to enroll"
+			continue
+		}
+		results[i].Status, results[i].EnrollmentID = "assigned", enrollment.ID
+		assigned++
+		notifyUser(db, userID, models.NotificationOrganization, org.Name+" enrolled you in "+course.Title, gin.H{
+			"organization_id": org.ID,
+			"course_id":       course.ID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Assigned the course to %d of %d members", assigned, len(input.UserIDs)),
+		"assigned": assigned,
+		"results":  results,
+	})
+}
+
+// UnassignCourse takes back a course the organization assigned to a member (see releaseSeat)
+func (h *OrganizationHandler) UnassignCourse(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	org, ok := h.managedOrganization(c)
+	if !ok {
+		return
+	}
+	var enrollment models.Enrollment
+	if err := db.Where("id = ? AND organization_id = ? AND is_active = ?", c.Param("enrollmentId"), org.ID, true).
+		First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Assignment not found"))
+		return
+	}
+	var released bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		released, err = releaseSeat(tx, enrollment)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to unassign the course"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Course unassigned"), "seat_released": released})
+}
+
+// teamProgressRow is a member's progress in a course the organization assigned them
+type teamProgressRow struct {
+	EnrollmentID     uint       `json:"enrollment_id"`
+	UserID           uint       `json:"user_id"`
+	FirstName        string     `json:"first_name"`
+	LastName         string     `json:"last_name"`
+	Email            string     `json:"email"`
+	CourseID         uint       `json:"course_id"`
+	CourseTitle      string     `json:"course_title"`
+	Progress         float64    `json:"progress"`
+	CompletedLessons int        `json:"completed_lessons"`
+	TotalLessons     int        `json:"total_lessons"`
+	EnrolledAt       time.Time  `json:"enrolled_at"`
+	LastActivityAt   time.Time  `json:"last_activity_at"`
+	CompletedAt      *time.Time `json:"completed_at"`
+}
+
+// GetTeamProgress is the organization's dashboard: each member's progress in the courses assigned to them,
+// and per course how many completed it. ?course_id= and ?user_id= narrow it down.
+func (h *OrganizationHandler) GetTeamProgress(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	org, ok := h.managedOrganization(c)
+	if !ok {
+		return
+	}
+	query := db.Table("enrollments").
+		Select(`enrollments.id AS enrollment_id, enrollments.user_id, users.first_name, users.last_name, users.email,
+			enrollments.course_id, courses.title AS course_title, enrollments.progress, enrollments.completed_lessons,
+			enrollments.total_lessons, enrollments.enrolled_at, enrollments.last_activity_at, enrollments.completed_at`).
+		Joins("JOIN users ON users.id = enrollments.user_id").
+		Joins("JOIN courses ON courses.id = enrollments.course_id").
+		Where("enrollments.organization_id = ? AND enrollments.is_active = ?", org.ID, true)
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("enrollments.course_id = ?", courseID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("enrollments.user_id = ?", userID)
+	}
+	var rows []teamProgressRow
+	if err := query.Order("users.last_name, users.first_name, courses.title").Scan(&rows).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch team progress"))
+		return
+	}
+
+	type courseSummary struct {
+		CourseID        uint    `json:"course_id"`
+		CourseTitle     string  `json:"course_title"`
+		Assigned        int     `json:"assigned"`
+		Completed       int     `json:"completed"`
+		AverageProgress float64 `json:"average_progress"`
+	}
+	var courses []*courseSummary
+	byCourse := map[uint]*courseSummary{}
+	for _, row := range rows {
+		summary, ok := byCourse[row.CourseID]
+		if !ok {
+			summary = &courseSummary{CourseID: row.CourseID, CourseTitle: row.CourseTitle}
+			byCourse[row.CourseID] = summary
+			courses = append(courses, summary)
+		}
+		summary.Assigned++
+		summary.AverageProgress += row.Progress
+		if row.CompletedAt != nil {
+			summary.Completed++
+		}
+	}
+	for _, summary := range courses {
+		summary.AverageProgress = float64(int(summary.AverageProgress/float64(summary.Assigned)*10)) / 10
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization_id": org.ID, "courses": courses, "members": rows})
+}
+
+// GetOrganizations lists every organization with its member count and seats, for platform admins
+func (h *AdminHandler) GetOrganizations(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var rows []struct {
+		ID            uint      `json:"id"`
+		Name          string    `json:"name"`
+		CreatedByID   uint      `json:"created_by_id"`
+		CreatedAt     time.Time `json:"created_at"`
+		Members       int64     `json:"members"`
+		SeatsBought   int64     `json:"seats_purchased"`
+		SeatsUsed     int64     `json:"seats_used"`
+		CoursesBought int64     `json:"courses"`
+	}
+	if err := db.Table("organizations").
+		Select(`organizations.id, organizations.name, organizations.created_by_id, organizations.created_at,
+			(SELECT COUNT(*) FROM organization_members WHERE organization_id = organizations.id) AS members,
+			(SELECT COALESCE(SUM(purchased), 0) FROM organization_seats WHERE organization_id = organizations.id) AS seats_bought,
+			(SELECT COALESCE(SUM(used), 0) FROM organization_seats WHERE organization_id = organizations.id) AS seats_used,
+			(SELECT COUNT(*) FROM organization_seats WHERE organization_id = organizations.id) AS courses_bought`).
+		Where("organizations.deleted_at IS NULL").Order("organizations.name").Scan(&rows).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch organizations"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organizations": rows})
+}
+
+// GrantSeats adds seats of a course to an organization without a payment ({"course_id", "seats",
+// "reason"}), e.g. for a contract invoiced outside the app
+func (h *AdminHandler) GrantSeats(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		CourseID uint   `json:"course_id" binding:"required"`
+		Seats    int    `json:"seats" binding:"required,min=1"`
+		Reason   string `json:"reason" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("course_id, seats and a reason are required"))
+		return
+	}
+	var org models.Organization
+	if err := db.First(&org, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Organization not found"))
+		return
+	}
+	var course models.Course
+	if err := db.Select("id").First(&course, input.CourseID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	if err := creditSeats(db, org.ID, course.ID, input.Seats); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to add seats"))
+		return
+	}
+	recordAudit(db, c, models.AuditSeatsGrant, "organization", org.ID, nil, gin.H{
+		"course_id": course.ID,
+		"seats":     input.Seats,
+		"reason":    input.Reason,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Granted %d seats", input.Seats)})
+}
@@ -0,0 +1,492 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/webhooks"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// webhookDeliveryBatch caps the deliveries attempted in one run of the job
+const webhookDeliveryBatch = 100
+
+// webhookDeliveryLease is how long a claimed delivery is left to the instance attempting it
+const webhookDeliveryLease = 5 * time.Minute
+
+// webhookEnvelope is the body POSTed to endpoints
+type webhookEnvelope struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// emitEvent queues an event for every active endpoint subscribed to it. Deliveries are created with db, so
+// an event emitted in a transaction is only sent if it commits. Failures are logged, never returned: an
+// integration must not break the action it reports.
+func emitEvent(db *gorm.DB, eventType string, data interface{}) {
+	var endpoints []models.WebhookEndpoint
+	if err := db.Select("id, events").Where("active = ?", true).Find(&endpoints).Error; err != nil {
+		slog.Error("Failed to load webhook endpoints", "event_type", eventType, "error", err)
+		return
+	}
+	var subscribed []uint
+	for _, endpoint := range endpoints {
+		if slices.Contains(strings.Split(endpoint.Events, ","), eventType) {
+			subscribed = append(subscribed, endpoint.ID)
+		}
+	}
+	if len(subscribed) > 0 {
+		queueEvent(db, subscribed, eventType, data)
+	}
+}
+
+// queueEvent creates the deliveries of an event to the given endpoints
+func queueEvent(db *gorm.DB, endpointIDs []uint, eventType string, data interface{}) []models.WebhookDelivery {
+	eventID, err := idgen.Token("evt_", 16)
+	if err != nil {
+		slog.Error("Failed to generate a webhook event ID", "error", err)
+		return nil
+	}
+	now := clock.Now()
+	payload, err := json.Marshal(webhookEnvelope{ID: eventID, Type: eventType, CreatedAt: now, Data: data})
+	if err != nil {
+		slog.Error("Failed to encode webhook event", "event_type", eventType, "error", err)
+		return nil
+	}
+	deliveries := make([]models.WebhookDelivery, len(endpointIDs))
+	for i, endpointID := range endpointIDs {
+		deliveries[i] = models.WebhookDelivery{
+			EndpointID:    endpointID,
+			EventID:       eventID,
+			EventType:     eventType,
+			Payload:       models.JSON(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+		}
+	}
+	if err := db.Create(&deliveries).Error; err != nil {
+		slog.Error("Failed to queue webhook event", "event_type", eventType, "error", err)
+		return nil
+	}
+	return deliveries
+}
+
+func emitEnrollmentCreated(db *gorm.DB, enrollment models.Enrollment) {
+	emitEvent(db, webhooks.EnrollmentCreated, gin.H{
+		"enrollment_id":   enrollment.ID,
+		"user_id":         enrollment.UserID,
+		"course_id":       enrollment.CourseID,
+		"payment_id":      enrollment.PaymentID,
+		"organization_id": enrollment.OrganizationID,
+		"enrolled_at":     enrollment.EnrolledAt,
+	})
+}
+
+func emitPaymentSucceeded(db *gorm.DB, payment models.Payment) {
+	emitEvent(db, webhooks.PaymentSucceeded, gin.H{
+		"payment_id":      payment.ID,
+		"tx_ref":          payment.ChapaTxRef,
+		"user_id":         payment.UserID,
+		"course_id":       payment.CourseID,
+		"track_id":        payment.TrackID,
+		"organization_id": payment.OrganizationID,
+		"seats":           payment.Seats,
+		"amount":          payment.Amount,
+		"currency":        payment.Currency,
+		"provider":        payment.Provider,
+		"payment_method":  payment.PaymentMethod,
+	})
+}
+
+func emitCertificateIssued(db *gorm.DB, certificate models.Certificate) {
+	emitEvent(db, webhooks.CertificateIssued, gin.H{
+		"certificate_id":    certificate.ID,
+		"enrollment_id":     certificate.EnrollmentID,
+		"user_id":           certificate.UserID,
+		"course_id":         certificate.CourseID,
+		"issue_date":        certificate.IssueDate,
+		"expiry_date":       certificate.ExpiryDate,
+		"grade":             certificate.Grade,
+		"verification_code": certificate.VerificationCode,
+	})
+}
+
+func emitQuizCompleted(db *gorm.DB, attempt models.QuizAttempt, courseID uint) {
+	emitEvent(db, webhooks.QuizCompleted, gin.H{
+		"attempt_id":   attempt.ID,
+		"quiz_id":      attempt.QuizID,
+		"course_id":    courseID,
+		"user_id":      attempt.UserID,
+		"score":        attempt.Score,
+		"is_passed":    attempt.IsPassed,
+		"completed_at": attempt.CompletedAt,
+	})
+}
+
+type OutboundWebhookHandler struct {
+	DB *gorm.DB
+}
+
+func NewOutboundWebhookHandler(db *gorm.DB) *OutboundWebhookHandler {
+	return &OutboundWebhookHandler{DB: db}
+}
+
+// DeliverWebhooks attempts the deliveries that are due. Failed ones are retried with growing delays and
+// given up after webhooks.MaxAttempts.
+func (h *OutboundWebhookHandler) DeliverWebhooks(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	now := clock.Now()
+	var due []models.WebhookDelivery
+	if err := db.Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+		Order("next_attempt_at, id").Limit(webhookDeliveryBatch).Find(&due).Error; err != nil {
+		return err
+	}
+
+	endpoints := map[uint]*models.WebhookEndpoint{}
+	for _, delivery := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Claim the delivery; another instance may have taken it first
+		lease := now.Add(webhookDeliveryLease)
+		result := db.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, models.DeliveryPending, delivery.NextAttemptAt).
+			Update("next_attempt_at", lease)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		endpoint, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			endpoint = &models.WebhookEndpoint{}
+			if err := db.First(endpoint, delivery.EndpointID).Error; err != nil {
+				endpoint = nil
+			}
+			endpoints[delivery.EndpointID] = endpoint
+		}
+		h.attemptDelivery(ctx, delivery, endpoint)
+	}
+	return nil
+}
+
+// attemptDelivery sends a delivery once and records the result
+func (h *OutboundWebhookHandler) attemptDelivery(ctx context.Context, delivery models.WebhookDelivery, endpoint *models.WebhookEndpoint) models.WebhookDelivery {
+	db := h.DB.WithContext(ctx)
+	attemptedAt := clock.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &attemptedAt
+
+	var result webhooks.Result
+	var err error
+	if endpoint == nil || !endpoint.Active {
+		err = errors.New("endpoint deleted or disabled")
+		delivery.Attempts = webhooks.MaxAttempts
+	} else {
+		result, err = webhooks.Deliver(ctx, endpoint.URL, endpoint.Secret, delivery.EventType, delivery.EventID, delivery.Payload)
+	}
+	delivery.ResponseCode, delivery.ResponseBody = result.StatusCode, result.Body
+
+	switch {
+	case err == nil:
+		delivery.Status, delivery.Error = models.DeliverySucceeded, ""
+		delivery.DeliveredAt, delivery.NextAttemptAt = &attemptedAt, nil
+	case delivery.Attempts >= webhooks.MaxAttempts:
+		delivery.Status, delivery.Error = models.DeliveryFailed, truncate(err.Error(), 2000)
+		delivery.NextAttemptAt = nil
+		slog.Error("Gave up webhook delivery", "delivery_id", delivery.ID, "event_type", delivery.EventType, "attempts", delivery.Attempts, "error", err)
+	default:
+		next := attemptedAt.Add(webhooks.RetryDelay(delivery.Attempts))
+		delivery.Status, delivery.Error = models.DeliveryPending, truncate(err.Error(), 2000)
+		delivery.NextAttemptAt = &next
+	}
+	if err := db.Model(&delivery).Select("status", "attempts", "next_attempt_at", "last_attempt_at",
+		"response_code", "response_body", "error", "delivered_at").Updates(&delivery).Error; err != nil {
+		slog.Error("Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+	return delivery
+}
+
+// parseWebhookEvents validates the events an endpoint subscribes to and joins them for storage
+func parseWebhookEvents(events []string) (string, error) {
+	if len(events) == 0 {
+		return "", errors.New("events must list at least one of " + strings.Join(webhooks.Events, ", "))
+	}
+	var valid []string
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if !slices.Contains(webhooks.Events, event) {
+			return "", errors.New("unknown event " + event + ", events are " + strings.Join(webhooks.Events, ", "))
+		}
+		if !slices.Contains(valid, event) {
+			valid = append(valid, event)
+		}
+	}
+	return strings.Join(valid, ","), nil
+}
+
+func newWebhookSecret() (string, error) {
+	return idgen.Token("whsec_", 24)
+}
+
+// CreateWebhookEndpoint registers an endpoint ({"url", "description", "events"}). Its signing secret is
+// only shown in this response.
+func (h *OutboundWebhookHandler) CreateWebhookEndpoint(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		URL         string   `json:"url" binding:"required"`
+		Description string   `json:"description"`
+		Events      []string `json:"events"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	input.URL = strings.TrimSpace(input.URL)
+	if err := webhooks.ValidateURL(input.URL); err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	events, err := parseWebhookEvents(input.Events)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to generate a secret"))
+		return
+	}
+
+	endpoint := models.WebhookEndpoint{
+		URL:         input.URL,
+		Description: truncate(strings.TrimSpace(input.Description), 255),
+		Secret:      secret,
+		Events:      events,
+		Active:      true,
+		CreatedByID: c.MustGet("userID").(uint),
+	}
+	if err := db.Create(&endpoint).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create webhook endpoint"))
+		return
+	}
+	recordAudit(db, c, models.AuditWebhookEndpoint, "webhook_endpoint", endpoint.ID, nil, endpoint)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"endpoint": endpoint,
+		"secret":   secret,
+	})
+}
+
+// GetWebhookEndpoints lists the registered endpoints
+func (h *OutboundWebhookHandler) GetWebhookEndpoints(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var endpoints []models.WebhookEndpoint
+	if err := db.Order("id").Find(&endpoints).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch webhook endpoints"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"endpoints": endpoints,
+		"events":    webhooks.Events,
+	})
+}
+
+// UpdateWebhookEndpoint changes an endpoint's url, description, events or active flag; "rotate_secret": true
+// replaces its secret and returns the new one
+func (h *OutboundWebhookHandler) UpdateWebhookEndpoint(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var endpoint models.WebhookEndpoint
+	if err := db.First(&endpoint, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Webhook endpoint not found"))
+		return
+	}
+	var input struct {
+		URL          *string  `json:"url"`
+		Description  *string  `json:"description"`
+		Events       []string `json:"events"`
+		Active       *bool    `json:"active"`
+		RotateSecret bool     `json:"rotate_secret"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	before := endpoint
+	if input.URL != nil {
+		url := strings.TrimSpace(*input.URL)
+		if err := webhooks.ValidateURL(url); err != nil {
+			apierror.Abort(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		endpoint.URL = url
+	}
+	if input.Description != nil {
+		endpoint.Description = truncate(strings.TrimSpace(*input.Description), 255)
+	}
+	if input.Events != nil {
+		events, err := parseWebhookEvents(input.Events)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		endpoint.Events = events
+	}
+	if input.Active != nil {
+		endpoint.Active = *input.Active
+	}
+	response := gin.H{}
+	if input.RotateSecret {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to generate a secret"))
+			return
+		}
+		endpoint.Secret = secret
+		response["secret"] = secret
+	}
+
+	if err := db.Save(&endpoint).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update webhook endpoint"))
+		return
+	}
+	after := gin.H{"endpoint": endpoint, "secret_rotated": input.RotateSecret}
+	recordAudit(db, c, models.AuditWebhookEndpoint, "webhook_endpoint", endpoint.ID, before, after)
+
+	response["endpoint"] = endpoint
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteWebhookEndpoint removes an endpoint with its delivery log
+func (h *OutboundWebhookHandler) DeleteWebhookEndpoint(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var endpoint models.WebhookEndpoint
+	if err := db.First(&endpoint, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Webhook endpoint not found"))
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint_id = ?", endpoint.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&endpoint).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete webhook endpoint"))
+		return
+	}
+	recordAudit(db, c, models.AuditWebhookEndpoint, "webhook_endpoint", endpoint.ID, endpoint, nil)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Webhook endpoint deleted")})
+}
+
+// TestWebhookEndpoint sends a ping event to an endpoint right away and returns the delivery
+func (h *OutboundWebhookHandler) TestWebhookEndpoint(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var endpoint models.WebhookEndpoint
+	if err := db.First(&endpoint, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Webhook endpoint not found"))
+		return
+	}
+	if !endpoint.Active {
+		apierror.Abort(c, apierror.Conflict("The endpoint is disabled"))
+		return
+	}
+	deliveries := queueEvent(db, []uint{endpoint.ID}, webhooks.Ping, gin.H{"endpoint_id": endpoint.ID})
+	if len(deliveries) == 0 {
+		apierror.Abort(c, apierror.Internal("Failed to queue the ping"))
+		return
+	}
+	// Leased so the delivery job leaves it alone while it is sent here
+	lease := clock.Now().Add(webhookDeliveryLease)
+	db.Model(&deliveries[0]).Update("next_attempt_at", lease)
+	delivery := h.attemptDelivery(c.Request.Context(), deliveries[0], &endpoint)
+	c.JSON(http.StatusOK, gin.H{"delivery": delivery})
+}
+
+// GetWebhookDeliveries lists deliveries, newest first, without their payloads (?endpoint_id=, ?status=,
+// ?event_type=, ?event_id=, ?page=)
+func (h *OutboundWebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	const pageSize = 50
+
+	query := db.Model(&models.WebhookDelivery{})
+	if endpointID := c.Query("endpoint_id"); endpointID != "" {
+		query = query.Where("endpoint_id = ?", endpointID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if eventType := c.Query("event_type"); eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+	if eventID := c.Query("event_id"); eventID != "" {
+		query = query.Where("event_id = ?", eventID)
+	}
+	var total int64
+	query.Count(&total)
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Omit("payload", "response_body").Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&deliveries).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch webhook deliveries"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"total":      total,
+		"page":       page,
+	})
+}
+
+// GetWebhookDelivery returns a delivery with its payload and the endpoint's last answer
+func (h *OutboundWebhookHandler) GetWebhookDelivery(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var delivery models.WebhookDelivery
+	if err := db.First(&delivery, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Webhook delivery not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"delivery": delivery})
+}
+
+// RedeliverWebhook queues a delivery to be sent again on the next run, with a fresh set of attempts
+func (h *OutboundWebhookHandler) RedeliverWebhook(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var delivery models.WebhookDelivery
+	if err := db.First(&delivery, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Webhook delivery not found"))
+		return
+	}
+	now := clock.Now()
+	if err := db.Model(&delivery).Updates(map[string]interface{}{
+		"status":          models.DeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+	}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to queue the delivery"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"delivery": delivery})
+}
@@ -1,16 +1,33 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"learning_hub/models"
+	"learning_hub/pkg/apierror"
 	"learning_hub/pkg/chapa"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/currency"
 	"learning_hub/pkg/email" // Add this import
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/receipt"
+	"learning_hub/pkg/settings"
+	"learning_hub/pkg/stripe"
+	"log/slog"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Where Chapa sends payment webhooks, and where payment providers return the customer after checkout
+const (
+	chapaCallbackURL = "https://webhook.site/f661bb23-ccc5-478f-8c9e-c835551834c6  "
+	paymentReturnURL = "http://localhost:8080/api/payment/success"
 )
 
 type PaymentHandler struct {
@@ -24,88 +41,146 @@ func NewPaymentHandler(db *gorm.DB) *PaymentHandler {
 // InitiatePayment handles course purchase and payment initiation
 // In the InitiatePayment function, add test mode handling:
 func (h *PaymentHandler) InitiatePayment(c *gin.Context) {
+	// Bound to the request, so its statements are traced with it
+	db := h.db.WithContext(c.Request.Context())
 	var request struct {
-		CourseID uint `json:"course_id" binding:"required"`
+		CourseID uint `json:"course_id" binding:"required,gt=0"`
 	}
 
 	// Bind and validate request
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	// Get user from context (set by auth middleware)
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Abort(c, apierror.Unauthorized("User not authenticated"))
+		return
+	}
+
+	if !deviceAllowed(c, db, "check out") {
+		return
+	}
+	if rejectTestStudent(c, db, "make payments") {
 		return
 	}
 
 	// Get course details
 	var course models.Course
-	if err := h.db.First(&course, request.CourseID).Error; err != nil {
+	if err := db.First(&course, request.CourseID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+			apierror.Abort(c, apierror.NotFound("Course not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch course"})
+		apierror.Abort(c, apierror.Internal("Failed to fetch course"))
 		return
 	}
 
-	// Check if user is already enrolled
-	var existingEnrollment models.Enrollment
-	err := h.db.Where("user_id = ? AND course_id = ?", userID, request.CourseID).First(&existingEnrollment).Error
-	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "You are already enrolled in this course"})
+	// Courses can be blocked or priced differently per country
+	price, available, err := coursePriceIn(db, course, requestCountry(c, db))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch course price"))
+		return
+	}
+	if !available {
+		apierror.Abort(c, apierror.Forbidden("This course is not available in your country"))
+		return
+	}
+
+	// Check if user is already enrolled; a deactivated enrollment is reactivated once paid for
+	enrolled, err := isActivelyEnrolled(db, userID.(uint), course.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check enrollment").Wrap(err))
+		return
+	}
+	if enrolled {
+		apierror.Abort(c, apierror.Conflict("You are already enrolled in this course"))
+		return
+	}
+
+	// Free courses need no payment, the student is enrolled right away
+	if price <= 0 {
+		enrollment, created, err := enrollForFree(db, userID.(uint), course.ID)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to create enrollment").Wrap(err))
+			return
+		}
+		if created {
+			emitEnrollmentCreated(db, enrollment)
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"message":    i18n.T(i18n.Locale(c), "Enrolled for free"),
+			"free":       true,
+			"enrollment": enrollment,
+		})
+		return
+	}
+
+	key, ok := idempotencyKey(c)
+	if !ok {
+		return
+	}
+	payment := models.Payment{
+		UserID:         userID.(uint),
+		CourseID:       course.ID,
+		Amount:         price,
+		Currency:       courseCurrency(course),
+		IdempotencyKey: key,
+		TaxRate:        settings.TaxRate(),
+		Status:         models.PaymentStatusPending,
+	}
+	// Repeated clicks on pay get the checkout already started back instead of a second payment
+	if resumeCheckout(c, db, payment) {
 		return
 	}
 
 	// Get user details for payment
 	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user details"})
+	if err := db.First(&user, userID).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch user details"))
 		return
 	}
 
 	// Generate unique transaction reference
-	txRef := fmt.Sprintf("learnhub-%d-%s", time.Now().Unix(), generateRandomString(8))
+	txRef := models.GenerateTxRef()
+	payment.ChapaTxRef = txRef
 
 	// TEST MODE: If using test keys, simulate payment
 	if strings.Contains(chapa.GetSecretKey(), "test") {
-		fmt.Println("🔧 TEST MODE: Simulating payment flow")
-
-		// Create payment record in database
-		payment := models.Payment{
-			UserID:     user.ID,
-			CourseID:   course.ID,
-			Amount:     course.Price,
-			Currency:   "ETB",
-			ChapaTxRef: txRef,
-			Status:     models.PaymentStatusSuccess, // Simulate success in test mode
-		}
+		slog.WarnContext(c.Request.Context(), "Chapa test key: simulating the payment", "tx_ref", txRef)
 
-		if err := h.db.Create(&payment).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment record"})
+		// Create the payment simulating success, and its enrollment with it
+		payment.Status = models.PaymentStatusSuccess
+		payment.PaymentMethod = chapa.MethodTest
+		paid := payment
+		var enrollment *models.Enrollment
+		err := inTransaction(db, func(tx *gorm.DB) error {
+			paid = payment
+			if err := tx.Create(&paid).Error; err != nil {
+				return err
+			}
+			var err error
+			enrollment, err = enrollPayer(tx, paid)
+			return err
+		})
+		if err != nil {
+			// A concurrent request with the same idempotency key may have won the race
+			if payment.IdempotencyKey != nil && resumeCheckout(c, db, payment) {
+				return
+			}
+			apierror.Abort(c, apierror.Internal("Failed to create enrollment").Wrap(err))
 			return
 		}
-
-		// Create enrollment
-		enrollment := models.Enrollment{
-			UserID:     user.ID,
-			CourseID:   course.ID,
-			PaymentID:  &payment.ID,
-			IsActive:   true,
-			Progress:   0,
-			EnrolledAt: time.Now(),
-		}
-
-		if err := h.db.Create(&enrollment).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create enrollment"})
-			return
+		payment = paid
+		recordDevice(db, c, user.ID, models.DeviceEventCheckout)
+		if enrollment != nil {
+			emitEnrollmentCreated(db, *enrollment)
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"message":         "TEST MODE: Payment completed successfully",
+			"message":         i18n.T(i18n.Locale(c), "TEST MODE: Payment completed successfully"),
 			"checkout_url":    "https://chapa.co/test-mode  ",
 			"transaction_ref": txRef,
 			"payment_id":      payment.ID,
@@ -114,221 +189,373 @@ func (h *PaymentHandler) InitiatePayment(c *gin.Context) {
 		return
 	}
 
-	// REAL MODE: Use actual Chapa API
-	paymentReq := &chapa.PaymentRequest{
-		Amount:      fmt.Sprintf("%.2f", course.Price),
-		Currency:    "ETB",
-		Email:       user.Email,
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
-		PhoneNumber: user.Phone,
-		TxRef:       txRef,
-		CallbackURL: "https://webhook.site/f661bb23-ccc5-478f-8c9e-c835551834c6  ",
-		ReturnURL:   "http://localhost:8080/api/payment/success",
-		Customization: chapa.Customization{
-			Title:       "LearnHub", // Shortened to meet 16 char limit
-			Description: fmt.Sprintf("Pay for %s", course.Title),
-		},
+	// REAL MODE: The provider of the course's currency takes the payment
+	checkoutURL, err := startCheckout(c.Request.Context(), checkout{
+		Payment:     &payment,
+		User:        user,
+		Title:       course.Title,
+		Description: fmt.Sprintf("Pay for %s", course.Title),
 		Meta: map[string]interface{}{
 			"user_id":   userID,
 			"course_id": course.ID,
 		},
-	}
-
-	// Initialize payment with Chapa
-	paymentResp, err := chapa.InitializePayment(paymentReq)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to initialize payment",
-			"details": err.Error(),
-		})
+		apierror.Abort(c, apierror.Internal("Failed to initialize payment").Wrap(err))
 		return
 	}
 
-	// Create payment record in database
-	payment := models.Payment{
-		UserID:     user.ID,
-		CourseID:   course.ID,
-		Amount:     course.Price,
-		Currency:   "ETB",
-		ChapaTxRef: txRef,
-		Status:     models.PaymentStatusPending,
-	}
-
-	if err := h.db.Create(&payment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment record"})
+	if !createPayment(c, db, &payment) {
 		return
 	}
+	recordDevice(db, c, user.ID, models.DeviceEventCheckout)
 
 	// Return payment URL to frontend
 	c.JSON(http.StatusOK, gin.H{
-		"message":         "Payment initialized successfully",
-		"checkout_url":    paymentResp.Data.CheckoutURL,
+		"message":         i18n.T(i18n.Locale(c), "Payment initialized successfully"),
+		"checkout_url":    checkoutURL,
 		"transaction_ref": txRef,
 		"payment_id":      payment.ID,
+		"provider":        payment.Provider,
 	})
 }
 
-// HandlePaymentCallback handles Chapa webhook callbacks
-// HandlePaymentCallback handles Chapa webhook callbacks
+// HandlePaymentCallback handles Chapa webhook callbacks. Each is logged as a webhook event and applied once.
 func (h *PaymentHandler) HandlePaymentCallback(c *gin.Context) {
+	body, err := readWebhookBody(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid webhook payload"))
+		return
+	}
 	var webhookPayload chapa.WebhookPayload
-
-	// Bind webhook payload
-	if err := c.ShouldBindJSON(&webhookPayload); err != nil {
-		fmt.Printf("❌ Webhook bind error: %v\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload"})
+	if err := json.Unmarshal(body, &webhookPayload); err != nil || webhookPayload.TxRef == "" {
+		slog.WarnContext(c.Request.Context(), "Invalid Chapa webhook payload", "error", err)
+		h.rejectWebhook(c, providerChapa, body, "Invalid webhook payload")
 		return
 	}
 
-	fmt.Printf("🔔 Webhook received: %+v\n", webhookPayload)
+	slog.InfoContext(c.Request.Context(), "Chapa webhook received", "tx_ref", webhookPayload.TxRef, "status", webhookPayload.Status)
 
-	// Find payment by transaction reference
-	var payment models.Payment
-	if err := h.db.Where("chapa_tx_ref = ?", webhookPayload.TxRef).First(&payment).Error; err != nil {
-		fmt.Printf("❌ Payment not found for tx_ref: %s\n", webhookPayload.TxRef)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+	// Chapa sends no event ID: a payment reaches each status once, so retries share the reference and status
+	event, done := h.receiveWebhook(c, providerChapa, webhookPayload.TxRef+":"+webhookPayload.Status,
+		"charge."+webhookPayload.Status, webhookPayload.TxRef, body)
+	if done {
 		return
 	}
+	h.finishWebhook(c, event, h.processChapaWebhook(c.Request.Context(), webhookPayload))
+}
 
-	fmt.Printf("🔍 Found payment: ID=%d, Status=%s\n", payment.ID, payment.Status)
+// processChapaWebhook applies what Chapa reported about a payment
+func (h *PaymentHandler) processChapaWebhook(ctx context.Context, webhookPayload chapa.WebhookPayload) webhookResult {
+	db := h.db.WithContext(ctx)
+	// Find payment by transaction reference
+	var payment models.Payment
+	if err := db.Where("chapa_tx_ref = ?", webhookPayload.TxRef).First(&payment).Error; err != nil {
+		slog.WarnContext(ctx, "Chapa webhook for an unknown payment", "tx_ref", webhookPayload.TxRef)
+		return webhookFailed(apierror.NotFound("Payment not found"))
+	}
 
-	// If webhook status is success, update payment and create enrollment
-	if webhookPayload.Status == "success" {
-		// Update payment status
-		payment.Status = models.PaymentStatusSuccess
+	previousStatus := payment.Status
+	succeeded := webhookPayload.Status == "success"
+	if succeeded {
 		payment.ChapaRefID = webhookPayload.RefID
 
-		if err := h.db.Save(&payment).Error; err != nil {
-			fmt.Printf("❌ Failed to update payment: %v\n", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
-			return
+		// Capture the channel the customer paid with (telebirr, cbebirr, card...) for receipts and reporting
+		if verifyResp, err := chapa.VerifyPayment(ctx, payment.ChapaTxRef); err == nil {
+			payment.PaymentMethod = chapa.NormalizePaymentMethod(verifyResp.Data.Method)
+		} else {
+			slog.WarnContext(ctx, "Could not verify the payment method", "payment_id", payment.ID, "error", err)
+		}
+	}
+	if err := h.settlePayment(ctx, payment, previousStatus, succeeded); err != nil {
+		return webhookFailed(apierror.Internal("Failed to update payment").Wrap(err))
+	}
+
+	// Always return success to Chapa
+	return webhookResult{Code: http.StatusOK, Body: gin.H{"status": "webhook processed successfully"}}
+}
+
+// settlePayment records the outcome the provider reported for a payment. A successful payment enrolls the
+// buyer, in every course of a track bundle, or credits an organization its seats.
+func (h *PaymentHandler) settlePayment(ctx context.Context, payment models.Payment, previousStatus models.PaymentStatus, succeeded bool) error {
+	db := h.db.WithContext(ctx)
+	if !succeeded {
+		payment.Status = models.PaymentStatusFailed
+		if err := db.Save(&payment).Error; err == nil {
+			auditPaymentStatus(db, nil, payment, previousStatus)
+			notifyPaymentStatus(db, payment)
 		}
+		slog.InfoContext(ctx, "Payment failed", "payment_id", payment.ID)
+		return nil
+	}
 
-		fmt.Printf("✅ Payment updated to success: ID=%d\n", payment.ID)
+	// The payment is recorded as paid together with what it buys, so a crash can't leave it paid for nothing
+	payment.Status = models.PaymentStatusSuccess
+	var enrollment *models.Enrollment
+	err := inTransaction(db, func(tx *gorm.DB) error {
+		enrollment = nil
+		// Concurrent deliveries of the success wait for each other on the payment's row, and the later ones
+		// find it paid already
+		var current models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, status").
+			First(&current, payment.ID).Error; err != nil {
+			return err
+		}
+		previousStatus = current.Status
+		if err := tx.Omit(clause.Associations).Save(&payment).Error; err != nil {
+			return err
+		}
+		// Seats bought by an organization are credited to it; a repeated webhook mustn't credit them twice
+		if payment.OrganizationID != nil {
+			if previousStatus == models.PaymentStatusSuccess {
+				return nil
+			}
+			return creditSeats(tx, *payment.OrganizationID, payment.CourseID, payment.Seats)
+		}
+		var err error
+		enrollment, err = enrollPayer(tx, payment)
+		return err
+	})
+	if err != nil {
+		// Left as it was: the provider retries the webhook, and the reconciliation job settles it otherwise
+		slog.ErrorContext(ctx, "Failed to settle payment", "payment_id", payment.ID, "user_id", payment.UserID, "error", err)
+		return err
+	}
 
-		// Check if enrollment already exists
-		var existingEnrollment models.Enrollment
-		err := h.db.Where("user_id = ? AND course_id = ?", payment.UserID, payment.CourseID).First(&existingEnrollment).Error
+	slog.InfoContext(ctx, "Payment succeeded", "payment_id", payment.ID)
+	auditPaymentStatus(db, nil, payment, previousStatus)
+	notifyPaymentStatus(db, payment)
+	if previousStatus != models.PaymentStatusSuccess {
+		emitPaymentSucceeded(db, payment)
+	}
+	if enrollment == nil {
+		return nil
+	}
+	emitEnrollmentCreated(db, *enrollment)
+
+	// Send email notifications, which outlive the webhook request: they keep its trace, not its cancellation
+	ctx = context.WithoutCancel(ctx)
+	db = db.WithContext(ctx)
+	go func() {
+		// Send payment success email to student
+		var user models.User
+		var course models.Course
+		db.First(&user, payment.UserID)
+		db.First(&course, payment.CourseID)
+
+		pdf := receipt.RenderPDF(paymentReceipt(db, payment, email.ReceiptLocale()))
+		email.SendPaymentSuccessEmail(ctx, user.Email, user.FirstName, course.Title, payment.Amount, payment.Currency,
+			payment.ChapaTxRef, chapa.PaymentMethodLabel(payment.PaymentMethod), pdf)
+
+		// Send enrollment notification to instructor
+		var instructor models.User
+		db.First(&instructor, course.InstructorID)
+		email.SendEnrollmentNotification(ctx, instructor.Email, instructor.FirstName, user.FirstName, course.Title)
+	}()
+	return nil
+}
 
+// enrollPayer enrolls the buyer of a successful payment in the course or track it paid for, reactivating an
+// existing course enrollment. It returns the course enrollment it created, nil when there was one already or
+// for a track, whose course enrollments are announced as they're created.
+func enrollPayer(tx *gorm.DB, payment models.Payment) (*models.Enrollment, error) {
+	if payment.TrackID != nil {
+		var existing int64
+		if err := tx.Model(&models.TrackEnrollment{}).Where("user_id = ? AND track_id = ?", payment.UserID, *payment.TrackID).
+			Count(&existing).Error; err != nil || existing > 0 {
+			return nil, err
+		}
+		track, err := loadTrack(tx.Unscoped(), *payment.TrackID)
 		if err != nil {
-			// Create enrollment if it doesn't exist
-			enrollment := models.Enrollment{
-				UserID:     payment.UserID,
-				CourseID:   payment.CourseID,
-				PaymentID:  &payment.ID,
-				IsActive:   true,
-				Progress:   0,
-				EnrolledAt: time.Now(),
-			}
+			return nil, err
+		}
+		_, err = enrollInTrack(tx, payment.UserID, track, &payment.ID)
+		return nil, err
+	}
 
-			if err := h.db.Create(&enrollment).Error; err != nil {
-				fmt.Printf("❌ Failed to create enrollment: %v\n", err)
-				// Don't return error - we still want to acknowledge the webhook
-			} else {
-				fmt.Printf("✅ Enrollment created: UserID=%d, CourseID=%d\n", payment.UserID, payment.CourseID)
-
-				// Send email notifications
-				go func() {
-					// Send payment success email to student
-					var user models.User
-					var course models.Course
-					h.db.First(&user, payment.UserID)
-					h.db.First(&course, payment.CourseID)
-
-					email.SendPaymentSuccessEmail(user.Email, user.FirstName, course.Title, payment.Amount, "ETB")
-
-					// Send enrollment notification to instructor
-					var instructor models.User
-					h.db.First(&instructor, course.InstructorID)
-					email.SendEnrollmentNotification(instructor.Email, instructor.FirstName, user.FirstName, course.Title)
-				}()
-			}
-		} else {
-			fmt.Printf("ℹ️ Enrollment already exists: ID=%d\n", existingEnrollment.ID)
+	var existing models.Enrollment
+	err := tx.Where("user_id = ? AND course_id = ?", payment.UserID, payment.CourseID).First(&existing).Error
+	if err == nil {
+		if existing.IsActive {
+			return nil, nil
 		}
-	} else {
-		// Payment failed
-		payment.Status = models.PaymentStatusFailed
-		h.db.Save(&payment)
-		fmt.Printf("❌ Payment failed: %s\n", webhookPayload.TxRef)
+		return nil, tx.Model(&existing).Updates(map[string]interface{}{"is_active": true, "payment_id": payment.ID}).Error
 	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	enrollment := models.Enrollment{
+		UserID:     payment.UserID,
+		CourseID:   payment.CourseID,
+		PaymentID:  &payment.ID,
+		IsActive:   true,
+		EnrolledAt: clock.Now(),
+	}
+	if err := tx.Create(&enrollment).Error; err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
 
-	// Always return success to Chapa
-	c.JSON(http.StatusOK, gin.H{"status": "webhook processed successfully"})
+// HandleStripeWebhook settles payments taken with Stripe Checkout from the events Stripe sends about their
+// sessions. Events are only accepted with a valid Stripe-Signature, and are logged and applied once.
+func (h *PaymentHandler) HandleStripeWebhook(c *gin.Context) {
+	body, err := readWebhookBody(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid webhook payload"))
+		return
+	}
+	event, err := stripe.ParseWebhook(body, c.GetHeader("Stripe-Signature"))
+	if err != nil {
+		h.rejectWebhook(c, providerStripe, body, err.Error())
+		return
+	}
+
+	var txRef string
+	if session, err := event.Session(); err == nil {
+		txRef = session.ClientReferenceID
+	}
+	logged, done := h.receiveWebhook(c, providerStripe, event.ID, event.Type, txRef, body)
+	if done {
+		return
+	}
+	h.finishWebhook(c, logged, h.processStripeEvent(c.Request.Context(), event))
+}
+
+// processStripeEvent applies a checkout session event
+func (h *PaymentHandler) processStripeEvent(ctx context.Context, event stripe.Event) webhookResult {
+	db := h.db.WithContext(ctx)
+	var succeeded bool
+	switch event.Type {
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded":
+		succeeded = true
+	case "checkout.session.expired", "checkout.session.async_payment_failed":
+	default:
+		return webhookResult{Code: http.StatusOK, Body: gin.H{"status": "event ignored"}, Ignored: true}
+	}
+	session, err := event.Session()
+	if err != nil {
+		return webhookFailed(apierror.BadRequest("Invalid checkout session").Wrap(err))
+	}
+	// Bank debits complete the session before the money arrives; async_payment_succeeded follows
+	if succeeded && !session.Paid() {
+		return webhookResult{Code: http.StatusOK, Body: gin.H{"status": "waiting for the payment"}}
+	}
+
+	var payment models.Payment
+	if err := db.Where("chapa_tx_ref = ? AND provider = ?", session.ClientReferenceID, providerStripe).
+		First(&payment).Error; err != nil {
+		return webhookFailed(apierror.NotFound("Payment not found"))
+	}
+	previousStatus := payment.Status
+	if succeeded {
+		payment.ProviderRef, payment.PaymentMethod = session.ID, chapa.MethodCard
+	}
+	if err := h.settlePayment(ctx, payment, previousStatus, succeeded); err != nil {
+		return webhookFailed(apierror.Internal("Failed to update payment").Wrap(err))
+	}
+	return webhookResult{Code: http.StatusOK, Body: gin.H{"status": "webhook processed successfully"}}
 }
 
 // GetPaymentStatus checks the status of a payment
 func (h *PaymentHandler) GetPaymentStatus(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	paymentID := c.Param("id")
 
 	var payment models.Payment
-	if err := h.db.Preload("User").Preload("Course").First(&payment, paymentID).Error; err != nil {
+	if err := db.Preload("User", contactUserFields).Preload("Course", courseBriefFields).First(&payment, paymentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			apierror.Abort(c, apierror.NotFound("Payment not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment"})
+		apierror.Abort(c, apierror.Internal("Failed to fetch payment"))
+		return
+	}
+	// Someone else's payment is answered as missing, so its ID says nothing
+	if role, _ := c.Get("userRole"); payment.UserID != c.MustGet("userID").(uint) && role != "admin" {
+		apierror.Abort(c, apierror.NotFound("Payment not found"))
 		return
 	}
 
-	// Verify with Chapa for latest status (optional)
-	verifyResp, err := chapa.VerifyPayment(payment.ChapaTxRef)
-	if err == nil {
-		// Update local status if different
-		if verifyResp.Data.Status == "success" && payment.Status != models.PaymentStatusSuccess {
-			payment.Status = models.PaymentStatusSuccess
-			h.db.Save(&payment)
+	// Ask the provider for the latest status (optional)
+	previousStatus := payment.Status
+	if payment.Provider == providerStripe {
+		session, err := stripe.GetSession(c.Request.Context(), payment.ProviderRef)
+		if err == nil && session.Paid() && payment.Status != models.PaymentStatusSuccess {
+			payment.Status, payment.PaymentMethod = models.PaymentStatusSuccess, chapa.MethodCard
+		}
+	} else if verifyResp, err := chapa.VerifyPayment(c.Request.Context(), payment.ChapaTxRef); err == nil && verifyResp.Data.Status == "success" {
+		// Update local status and payment method if different
+		method := chapa.NormalizePaymentMethod(verifyResp.Data.Method)
+		if payment.Status == models.PaymentStatusSuccess && payment.PaymentMethod != method {
+			db.Model(&payment).Update("payment_method", method)
+		}
+		payment.Status, payment.PaymentMethod = models.PaymentStatusSuccess, method
+	}
+	// Confirmed by the provider, not by the user who asked for the status: settled as its webhook would be
+	if payment.Status != previousStatus {
+		if err := h.settlePayment(c.Request.Context(), payment, previousStatus, true); err != nil {
+			payment.Status = previousStatus
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"payment": payment,
+		"payment": paymentView{Payment: payment, User: newUserBrief(payment.User), Course: newCourseBrief(payment.Course)},
 		"status":  payment.Status,
+		"receipt": buildReceipt(payment, receiptLocale(c)),
+	})
+}
+
+// notifyPaymentStatus tells the buyer their payment went through or failed
+func notifyPaymentStatus(db *gorm.DB, payment models.Payment) {
+	title := "Payment successful"
+	if payment.Status != models.PaymentStatusSuccess {
+		title = "Payment failed"
+	}
+	notifyUser(db, payment.UserID, models.NotificationPayment, title, gin.H{
+		"payment_id":      payment.ID,
+		"course_id":       payment.CourseID,
+		"status":          payment.Status,
+		"amount":          payment.Amount,
+		"currency":        payment.Currency,
+		"transaction_ref": payment.ChapaTxRef,
 	})
 }
 
 // GetUserPayments returns all payments made by the authenticated user
 func (h *PaymentHandler) GetUserPayments(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Abort(c, apierror.Unauthorized("User not authenticated"))
 		return
 	}
 
+	// The user is the caller, and needs no loading
 	var payments []models.Payment
-	if err := h.db.Preload("User").Preload("Course").Preload("Course.Instructor").
+	if err := db.Preload("Course", courseBriefFields).Preload("Course.Instructor", publicUserFields).
 		Where("user_id = ?", userID).Order("created_at DESC").Find(&payments).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+		apierror.Abort(c, apierror.Internal("Failed to fetch payments"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"payments": payments})
-}
-
-// generateRandomString generates a random string for transaction references
-func generateRandomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
-	}
-	return string(b)
+	c.JSON(http.StatusOK, gin.H{"payments": newPaymentViews(payments)})
 }
 
 // PaymentSuccess handles the return URL from Chapa
 func (h *PaymentHandler) PaymentSuccess(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
 	txRef := c.Query("tx_ref")
 	status := c.Query("status")
 
 	if status == "success" && txRef != "" {
 		// Find the payment
 		var payment models.Payment
-		if err := h.db.Preload("Course").Where("chapa_tx_ref = ?", txRef).First(&payment).Error; err == nil {
-			c.HTML(http.StatusOK, "payment_success.html", gin.H{
-				"course_title": payment.Course.Title,
-				"amount":       payment.Amount,
-				"currency":     payment.Currency,
+		if err := db.Preload("Course").Where("chapa_tx_ref = ?", txRef).First(&payment).Error; err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": i18n.T(i18n.Locale(c), "Payment completed successfully! You can now access your course."),
+				"status":  "success",
+				"receipt": buildReceipt(payment, receiptLocale(c)),
 			})
 			return
 		}
@@ -336,7 +563,35 @@ func (h *PaymentHandler) PaymentSuccess(c *gin.Context) {
 
 	// Generic success message
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Payment completed successfully! You can now access your course.",
+		"message": i18n.T(i18n.Locale(c), "Payment completed successfully! You can now access your course."),
 		"status":  "success",
 	})
 }
+
+// receiptLocale picks the receipt locale from the ?locale= query or the Accept-Language header
+func receiptLocale(c *gin.Context) string {
+	if locale := c.Query("locale"); locale != "" {
+		return currency.NormalizeLocale(locale)
+	}
+	return currency.NormalizeLocale(c.GetHeader("Accept-Language"))
+}
+
+// buildReceipt returns the receipt view of a payment with localized amount formatting
+func buildReceipt(payment models.Payment, locale string) gin.H {
+	subtotal, tax := receipt.SplitTax(payment.Amount, payment.TaxRate)
+	return gin.H{
+		"transaction_ref":  payment.ChapaTxRef,
+		"course_id":        payment.CourseID,
+		"course_title":     payment.Course.Title,
+		"amount":           payment.Amount,
+		"currency":         payment.Currency,
+		"formatted_amount": currency.FormatAmount(payment.Amount, payment.Currency, locale),
+		"subtotal":         subtotal,
+		"tax":              tax,
+		"tax_rate":         payment.TaxRate,
+		"payment_method":   chapa.PaymentMethodLabel(payment.PaymentMethod),
+		"status":           payment.Status,
+		"paid_at":          payment.UpdatedAt,
+		"locale":           locale,
+	}
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/accounting"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/fileupload"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Exports with more payments than this are generated in the background
+const paymentExportSyncLimit = 5000
+
+// exportablePaymentStatuses are the payment statuses that can be exported
+var exportablePaymentStatuses = []string{string(models.PaymentStatusSuccess), string(models.PaymentStatusRefunded)}
+
+// paymentExportRequest is what to export and how to lay it out
+type paymentExportRequest struct {
+	Format   accounting.Format
+	Statuses []string
+	From, To time.Time
+}
+
+// parseExportRequest reads ?from=&to= (YYYY-MM-DD), ?status=success,refunded, ?format=csv|quickbooks|peachtree
+// and, for csv, an optional ?columns= mapping such as "date:Posted On,amount,reference:Invoice"
+func parseExportRequest(c *gin.Context) (paymentExportRequest, bool) {
+	var req paymentExportRequest
+
+	from, to, ok := parseReportRange(c, time.Time{})
+	if !ok {
+		return req, false
+	}
+	req.From, req.To = from, to
+
+	req.Statuses = exportablePaymentStatuses
+	if value := c.Query("status"); value != "" {
+		req.Statuses = nil
+		for _, status := range strings.Split(value, ",") {
+			status = strings.TrimSpace(status)
+			if status != string(models.PaymentStatusSuccess) && status != string(models.PaymentStatusRefunded) {
+				apierror.Abort(c, apierror.BadRequest("status must be success and/or refunded"))
+				return req, false
+			}
+			req.Statuses = append(req.Statuses, status)
+		}
+	}
+
+	format, ok := accounting.Formats[c.DefaultQuery("format", "csv")]
+	if !ok {
+		apierror.Abort(c, apierror.BadRequest("format must be csv, quickbooks or peachtree"))
+		return req, false
+	}
+	if mapping := c.Query("columns"); mapping != "" {
+		if format.Name != "csv" {
+			apierror.Abort(c, apierror.BadRequest("Custom columns are only supported for the csv format"))
+			return req, false
+		}
+		columns, err := accounting.ParseColumns(mapping)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("Invalid columns: "+err.Error()))
+			return req, false
+		}
+		format.Columns = columns
+	}
+	req.Format = format
+
+	return req, true
+}
+
+// exportPaymentsQuery selects payments with a sale or refund in the period. Refunds are dated by
+// the payment's last update.
+func exportPaymentsQuery(db *gorm.DB, req paymentExportRequest) *gorm.DB {
+	query := db.Model(&models.Payment{}).Where("status IN ?", req.Statuses)
+	if containsString(req.Statuses, string(models.PaymentStatusRefunded)) {
+		return query.Where("((created_at >= ? AND created_at < ?) OR (status = ? AND updated_at >= ? AND updated_at < ?))",
+			req.From, req.To, models.PaymentStatusRefunded, req.From, req.To)
+	}
+	return query.Where("created_at >= ? AND created_at < ?", req.From, req.To)
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// exportRows loads the payments of an export as accounting rows in date order. A refunded payment is
+// exported as its original sale plus a refund, each only when its date falls in the period.
+func exportRows(db *gorm.DB, req paymentExportRequest) ([]accounting.Row, error) {
+	var payments []models.Payment
+	if err := exportPaymentsQuery(db, req).
+		Preload("User", func(db *gorm.DB) *gorm.DB { return db.Unscoped().Select("id, first_name, last_name, email") }).
+		Preload("Course", func(db *gorm.DB) *gorm.DB { return db.Unscoped().Select("id, title") }).
+		Order("created_at").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+
+	inPeriod := func(t time.Time) bool { return !t.Before(req.From) && t.Before(req.To) }
+	rows := make([]accounting.Row, 0, len(payments))
+	var refunds []accounting.Row
+	for _, p := range payments {
+		row := accounting.Row{
+			PaymentID:     p.ID,
+			Date:          p.CreatedAt,
+			Reference:     p.ChapaTxRef,
+			ProviderRef:   p.ChapaRefID,
+			CustomerName:  strings.TrimSpace(p.User.FirstName + " " + p.User.LastName),
+			CustomerEmail: p.User.Email,
+			CourseID:      p.CourseID,
+			CourseTitle:   p.Course.Title,
+			PaymentMethod: p.PaymentMethod,
+			Currency:      p.Currency,
+			Amount:        p.Amount,
+			Status:        string(p.Status),
+		}
+		if inPeriod(row.Date) {
+			rows = append(rows, row)
+		}
+		if p.Status == models.PaymentStatusRefunded && inPeriod(p.UpdatedAt) {
+			row.Date, row.Refund = p.UpdatedAt, true
+			refunds = append(refunds, row)
+		}
+	}
+
+	// Merge refunds into the date order of the sales
+	merged := make([]accounting.Row, 0, len(rows)+len(refunds))
+	i, j := 0, 0
+	for i < len(rows) || j < len(refunds) {
+		if j == len(refunds) || (i < len(rows) && !refunds[j].Date.Before(rows[i].Date)) {
+			merged = append(merged, rows[i])
+			i++
+		} else {
+			merged = append(merged, refunds[j])
+			j++
+		}
+	}
+	return merged, nil
+}
+
+// exportFilename names an export file after its format and period
+func exportFilename(format string, from, to time.Time) string {
+	start := "all"
+	if !from.IsZero() {
+		start = from.Format("2006-01-02")
+	}
+	return fmt.Sprintf("payments_%s_to_%s_%s.csv", start, to.AddDate(0, 0, -1).Format("2006-01-02"), format)
+}
+
+type PaymentExportHandler struct {
+	DB *gorm.DB
+}
+
+func NewPaymentExportHandler(db *gorm.DB) *PaymentExportHandler {
+	return &PaymentExportHandler{DB: db}
+}
+
+// ExportPayments downloads payments and refunds as CSV for accounting software. Large exports
+// (or ?async=true) are queued and answered with 202; fetch them from /admin/payments/exports.
+func (h *PaymentExportHandler) ExportPayments(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	req, ok := parseExportRequest(c)
+	if !ok {
+		return
+	}
+
+	var count int64
+	if err := exportPaymentsQuery(db, req).Count(&count).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to count payments"))
+		return
+	}
+
+	if count > paymentExportSyncLimit || c.Query("async") == "true" {
+		adminID, _ := c.Get("userID")
+		columns, _ := json.Marshal(req.Format.Columns)
+		export := models.PaymentExport{
+			RequestedByID: adminID.(uint),
+			Format:        req.Format.Name,
+			Columns:       models.JSON(columns),
+			Statuses:      strings.Join(req.Statuses, ","),
+			From:          req.From,
+			To:            req.To,
+			Status:        models.ExportStatusPending,
+			CreatedAt:     clock.Now(),
+		}
+		if err := db.Create(&export).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to queue export"))
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": i18n.T(i18n.Locale(c), "Export queued, download it from /api/admin/payments/exports once ready"),
+			"export":  export,
+		})
+		return
+	}
+
+	rows, err := exportRows(db, req)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to load payments"))
+		return
+	}
+	var buf bytes.Buffer
+	if err := accounting.Write(&buf, req.Format, rows); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to generate export"))
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+exportFilename(req.Format.Name, req.From, req.To)+`"`)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// GetPaymentExports lists background exports with the formats and fields available
+func (h *PaymentExportHandler) GetPaymentExports(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var exports []models.PaymentExport
+	if err := db.Order("created_at DESC").Limit(50).Find(&exports).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch exports"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"exports": exports,
+		"formats": accounting.Formats,
+		"fields":  accounting.FieldNames(),
+	})
+}
+
+// DownloadPaymentExport downloads a finished background export
+func (h *PaymentExportHandler) DownloadPaymentExport(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var export models.PaymentExport
+	if err := db.First(&export, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Export not found"))
+		return
+	}
+	if export.Status != models.ExportStatusReady {
+		apierror.Abort(c, apierror.Conflict("Export is not ready").With("status", export.Status))
+		return
+	}
+
+	reader, info, err := fileupload.Storage().Open(c.Request.Context(), export.StorageKey)
+	if err != nil {
+		apierror.Abort(c, apierror.NotFound("Export file not found"))
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, info.Size, "text/csv; charset=utf-8", reader, map[string]string{
+		"Content-Disposition": `attachment; filename="` + exportFilename(export.Format, export.From, export.To) + `"`,
+	})
+}
+
+// ProcessPaymentExports generates queued exports one at a time. Runs as a scheduled job.
+func (h *PaymentExportHandler) ProcessPaymentExports(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	for ctx.Err() == nil {
+		var export models.PaymentExport
+		err := db.Where("status = ?", models.ExportStatusPending).Order("id").First(&export).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// Claim the export; another instance may have taken it first
+		result := db.Model(&models.PaymentExport{}).
+			Where("id = ? AND status = ?", export.ID, models.ExportStatusPending).
+			Update("status", models.ExportStatusProcessing)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		rowCount, key, err := h.generateExport(ctx, export)
+		completedAt := clock.Now()
+		updates := map[string]interface{}{"completed_at": completedAt}
+		if err != nil {
+			slog.Error("Payment export failed", "export_id", export.ID, "error", err)
+			updates["status"], updates["error"] = models.ExportStatusFailed, truncate(err.Error(), 2000)
+		} else {
+			updates["status"], updates["storage_key"], updates["row_count"] = models.ExportStatusReady, key, rowCount
+		}
+		if err := db.Model(&export).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		title := "Your payment export is ready"
+		if err != nil {
+			title = "Your payment export failed"
+		}
+		notifyUser(db, export.RequestedByID, models.NotificationPaymentExport, title, gin.H{
+			"export_id": export.ID,
+			"status":    updates["status"],
+			"row_count": rowCount,
+		})
+	}
+	return ctx.Err()
+}
+
+// generateExport writes an export file to storage and returns its row count and storage key
+func (h *PaymentExportHandler) generateExport(ctx context.Context, export models.PaymentExport) (int, string, error) {
+	db := h.DB.WithContext(ctx)
+	format, ok := accounting.Formats[export.Format]
+	if !ok {
+		return 0, "", fmt.Errorf("unknown format %q", export.Format)
+	}
+	if len(export.Columns) > 0 {
+		if err := json.Unmarshal(export.Columns, &format.Columns); err != nil {
+			return 0, "", err
+		}
+	}
+
+	req := paymentExportRequest{
+		Format:   format,
+		Statuses: strings.Split(export.Statuses, ","),
+		From:     export.From,
+		To:       export.To,
+	}
+	rows, err := exportRows(db, req)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var buf bytes.Buffer
+	if err := accounting.Write(&buf, format, rows); err != nil {
+		return 0, "", err
+	}
+	// Kept outside the served upload directories
+	key := fmt.Sprintf("exports/payments-%d.csv", export.ID)
+	if err := fileupload.Storage().Save(ctx, key, &buf, int64(buf.Len()), "text/csv"); err != nil {
+		return 0, "", err
+	}
+	return len(rows), key, nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/chapa"
+	"learning_hub/pkg/receipt"
+	"learning_hub/pkg/settings"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// paymentReceipt lays out a successful payment as a receipt: what was bought, the tax included at the
+// payment's rate and the references to quote to support or the provider
+func paymentReceipt(db *gorm.DB, payment models.Payment, locale string) receipt.Receipt {
+	var user models.User
+	db.Select("id, first_name, last_name, email").First(&user, payment.UserID)
+	course := payment.Course
+	if course.ID == 0 {
+		db.Select("id, title").First(&course, payment.CourseID)
+	}
+
+	line := receipt.Line{Description: course.Title, Quantity: 1, UnitPrice: payment.Amount, Amount: payment.Amount}
+	billedTo := []string{strings.TrimSpace(user.FirstName + " " + user.LastName), user.Email}
+	switch {
+	case payment.TrackID != nil:
+		var track models.Track
+		db.Select("id, title").First(&track, *payment.TrackID)
+		line.Description = "Learning track: " + track.Title
+	case payment.OrganizationID != nil && payment.Seats > 0:
+		var org models.Organization
+		db.Select("id, name").First(&org, *payment.OrganizationID)
+		line.Description = "Seats of " + course.Title
+		line.Quantity = payment.Seats
+		line.UnitPrice = payment.Amount / float64(payment.Seats)
+		billedTo = append([]string{org.Name}, billedTo...)
+	default:
+		line.Description = "Course: " + course.Title
+	}
+
+	references := []receipt.Reference{
+		{Label: "Transaction", Value: payment.ChapaTxRef},
+		{Label: "Payment method", Value: chapa.PaymentMethodLabel(payment.PaymentMethod)},
+	}
+	if payment.Provider == providerStripe {
+		references = append(references, receipt.Reference{Label: "Stripe session", Value: payment.ProviderRef})
+	} else {
+		references = append(references, receipt.Reference{Label: "Chapa reference", Value: payment.ChapaRefID})
+	}
+	references = append(references, receipt.Reference{Label: "Status", Value: string(payment.Status)})
+
+	return receipt.Receipt{
+		Number:     payment.ChapaTxRef,
+		IssuedAt:   payment.UpdatedAt,
+		Issuer:     settings.ReceiptIssuer(),
+		BilledTo:   billedTo,
+		Lines:      []receipt.Line{line},
+		Total:      payment.Amount,
+		TaxLabel:   settings.TaxLabel(),
+		TaxRate:    payment.TaxRate,
+		Currency:   payment.Currency,
+		Locale:     locale,
+		References: references,
+		Note:       "Prices include tax. Keep this receipt for your records.",
+	}
+}
+
+// DownloadPaymentReceipt returns the PDF receipt of a successful payment, to the payer or an admin
+// (?locale= formats amounts, like the payment status)
+func (h *PaymentHandler) DownloadPaymentReceipt(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	var payment models.Payment
+	if err := db.Preload("Course").First(&payment, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Payment not found"))
+		return
+	}
+	if role, _ := c.Get("userRole"); payment.UserID != c.MustGet("userID").(uint) && role != "admin" {
+		apierror.Abort(c, apierror.NotFound("Payment not found"))
+		return
+	}
+	if payment.Status != models.PaymentStatusSuccess {
+		apierror.Abort(c, apierror.Conflict("Receipts are only issued for successful payments"))
+		return
+	}
+
+	pdf := receipt.RenderPDF(paymentReceipt(db, payment, receiptLocale(c)))
+	c.Header("Content-Disposition", `attachment; filename="receipt-`+payment.ChapaTxRef+`.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/sandbox"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxCodeTests caps the test cases of a playground lesson, each check runs all of them
+const maxCodeTests = 20
+
+// codeLessonInput holds the playground settings of a lesson being created or updated
+type codeLessonInput struct {
+	Type         string              `json:"type"`
+	CodeLanguage string              `json:"code_language"`
+	StarterCode  string              `json:"starter_code"`
+	CodeTests    *[]sandbox.TestCase `json:"code_tests"`
+}
+
+// applyCodeLesson validates the playground settings and applies them to the lesson.
+// Empty fields keep the lesson's current values.
+func applyCodeLesson(lesson *models.Lesson, input codeLessonInput) error {
+	if input.Type != "" {
+		if input.Type != models.LessonTypeStandard && input.Type != models.LessonTypeCode {
+			return fmt.Errorf("type must be %s or %s", models.LessonTypeStandard, models.LessonTypeCode)
+		}
+		lesson.Type = input.Type
+	}
+	if lesson.Type == "" {
+		lesson.Type = models.LessonTypeStandard
+	}
+	if input.CodeLanguage != "" {
+		lesson.CodeLanguage = strings.ToLower(strings.TrimSpace(input.CodeLanguage))
+	}
+	if input.StarterCode != "" {
+		if len(input.StarterCode) > sandbox.MaxCodeSize {
+			return fmt.Errorf("starter_code is larger than %d KB", sandbox.MaxCodeSize/1024)
+		}
+		lesson.StarterCode = input.StarterCode
+	}
+	if input.CodeTests != nil {
+		tests := *input.CodeTests
+		if len(tests) > maxCodeTests {
+			return fmt.Errorf("a lesson can have at most %d code tests", maxCodeTests)
+		}
+		for i, test := range tests {
+			if strings.TrimSpace(test.ExpectedOutput) == "" {
+				return fmt.Errorf("code test %d needs an expected_output", i+1)
+			}
+		}
+		lesson.CodeTests = nil
+		if len(tests) > 0 {
+			data, err := json.Marshal(tests)
+			if err != nil {
+				return err
+			}
+			lesson.CodeTests = models.JSON(data)
+		}
+	}
+
+	if lesson.Type == models.LessonTypeCode && lesson.CodeLanguage == "" {
+		return errors.New("code lessons need a code_language")
+	}
+	if lesson.Type != models.LessonTypeCode && len(codeTests(*lesson)) > 0 {
+		return errors.New("only code lessons can have code tests")
+	}
+	return nil
+}
+
+// codeTests returns the lesson's test cases
+func codeTests(lesson models.Lesson) []sandbox.TestCase {
+	var tests []sandbox.TestCase
+	if len(lesson.CodeTests) > 0 {
+		json.Unmarshal(lesson.CodeTests, &tests)
+	}
+	return tests
+}
+
+// hideCodeTests leaves only the test cases students may see on the lesson
+func hideCodeTests(lesson *models.Lesson) {
+	tests := codeTests(*lesson)
+	if len(tests) == 0 {
+		return
+	}
+	visible := []sandbox.TestCase{}
+	for _, test := range tests {
+		if !test.Hidden {
+			visible = append(visible, test)
+		}
+	}
+	data, _ := json.Marshal(visible)
+	lesson.CodeTests = models.JSON(data)
+}
+
+// hideCourseCodeTests hides the test cases of the course's loaded lessons from students
+func hideCourseCodeTests(course *models.Course) {
+	for i := range course.Modules {
+		for j := range course.Modules[i].Lessons {
+			hideCodeTests(&course.Modules[i].Lessons[j])
+		}
+	}
+}
+
+// codeTestsPassed reports whether the student may complete the lesson: lessons without code tests
+// always count, playground lessons with tests once the student's code passed them
+func codeTestsPassed(db *gorm.DB, userID, lessonID uint) (bool, error) {
+	var lesson models.Lesson
+	if err := db.Select("id, type, code_tests").First(&lesson, lessonID).Error; err != nil {
+		return false, err
+	}
+	if lesson.Type != models.LessonTypeCode || len(codeTests(lesson)) == 0 {
+		return true, nil
+	}
+	var passed int64
+	err := db.Model(&models.LessonCode{}).
+		Where("user_id = ? AND lesson_id = ? AND passed = ?", userID, lessonID, true).
+		Count(&passed).Error
+	return passed > 0, err
+}
+
+// loadCodeLesson loads the :id playground lesson for a student enrolled in its course or a course manager
+func (h *LessonHandler) loadCodeLesson(c *gin.Context) (models.Lesson, bool) {
+	db := h.db.WithContext(c.Request.Context())
+	var lesson models.Lesson
+	if err := db.Preload("Module.Course").First(&lesson, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Lesson not found"))
+		return lesson, false
+	}
+	if lesson.Type != models.LessonTypeCode {
+		apierror.Abort(c, apierror.BadRequest("This lesson has no code playground"))
+		return lesson, false
+	}
+	if !h.canAccessCourseContent(c, lesson.Module.Course) {
+		apierror.Abort(c, apierror.Forbidden("You must be enrolled in this course to access its lessons"))
+		return lesson, false
+	}
+	return lesson, true
+}
+
+// saveLessonCode stores the caller's code for the lesson
+func saveLessonCode(db *gorm.DB, userID, lessonID uint, code string) (models.LessonCode, error) {
+	var saved models.LessonCode
+	err := db.Where("user_id = ? AND lesson_id = ?", userID, lessonID).First(&saved).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return saved, err
+	}
+	saved.UserID, saved.LessonID, saved.Code = userID, lessonID, code
+	saved.UpdatedAt = clock.Now()
+	return saved, db.Save(&saved).Error
+}
+
+// bindCode reads {"code"} and, for runs, {"stdin"} from the request
+func bindCode(c *gin.Context) (code, stdin string, ok bool) {
+	var input struct {
+		Code  string `json:"code"`
+		Stdin string `json:"stdin"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return "", "", false
+	}
+	if len(input.Code) > sandbox.MaxCodeSize {
+		apierror.Abort(c, apierror.New(http.StatusRequestEntityTooLarge, fmt.Sprintf("Code is larger than %d KB", sandbox.MaxCodeSize/1024)))
+		return "", "", false
+	}
+	return input.Code, input.Stdin, true
+}
+
+// GetLessonCode returns the caller's saved code of a playground lesson (the starter code until they save)
+// with the visible test cases and the last check
+func (h *LessonHandler) GetLessonCode(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadCodeLesson(c)
+	if !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+
+	saved := models.LessonCode{UserID: userID, LessonID: lesson.ID, Code: lesson.StarterCode}
+	db.Where("user_id = ? AND lesson_id = ?", userID, lesson.ID).First(&saved)
+
+	tests := codeTests(lesson)
+	if !canTeachCourse(c, db, lesson.Module.Course) {
+		hideCodeTests(&lesson)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lesson_id":    lesson.ID,
+		"language":     lesson.CodeLanguage,
+		"starter_code": lesson.StarterCode,
+		"code":         saved,
+		"tests":        codeTests(lesson),
+		"tests_total":  len(tests),
+		"can_run":      sandbox.Enabled(),
+	})
+}
+
+// SaveLessonCode saves the caller's code of a playground lesson without running it
+func (h *LessonHandler) SaveLessonCode(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadCodeLesson(c)
+	if !ok {
+		return
+	}
+	code, _, ok := bindCode(c)
+	if !ok {
+		return
+	}
+
+	saved, err := saveLessonCode(db, c.MustGet("userID").(uint), lesson.ID, code)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save code"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Code saved"),
+		"code":    saved,
+	})
+}
+
+// RunLessonCode saves the caller's code and runs it in the sandbox with the given stdin
+func (h *LessonHandler) RunLessonCode(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadCodeLesson(c)
+	if !ok {
+		return
+	}
+	code, stdin, ok := bindCode(c)
+	if !ok {
+		return
+	}
+	if !sandbox.Enabled() {
+		apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, "Code execution is not available"))
+		return
+	}
+
+	if _, err := saveLessonCode(db, c.MustGet("userID").(uint), lesson.ID, code); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save code"))
+		return
+	}
+
+	result, err := sandbox.Run(c.Request.Context(), lesson.CodeLanguage, code, stdin)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to run lesson code", "lesson_id", lesson.ID, "error", err)
+		apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, "Your code could not be run right now, please try again later"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
+
+// CheckLessonCode saves the caller's code and runs it against the lesson's test cases. When they all
+// pass, the lesson is completed for enrolled students.
+func (h *LessonHandler) CheckLessonCode(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadCodeLesson(c)
+	if !ok {
+		return
+	}
+	code, _, ok := bindCode(c)
+	if !ok {
+		return
+	}
+	tests := codeTests(lesson)
+	if len(tests) == 0 {
+		apierror.Abort(c, apierror.BadRequest("This lesson has no code tests"))
+		return
+	}
+	if !sandbox.Enabled() {
+		apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, "Code execution is not available"))
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	saved, err := saveLessonCode(db, userID, lesson.ID, code)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save code"))
+		return
+	}
+
+	results, err := sandbox.Check(c.Request.Context(), lesson.CodeLanguage, code, tests)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to check lesson code", "lesson_id", lesson.ID, "error", err)
+		apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, "Your code could not be run right now, please try again later"))
+		return
+	}
+	passed := 0
+	for _, result := range results {
+		if result.Passed {
+			passed++
+		}
+	}
+
+	now := clock.Now()
+	allPassed := passed == len(results)
+	updates := map[string]interface{}{
+		"tests_passed": passed,
+		"tests_total":  len(results),
+		"checked_at":   now,
+	}
+	if allPassed {
+		updates["passed"] = true
+	}
+	if err := db.Model(&saved).UpdateColumns(updates).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save test results"))
+		return
+	}
+
+	response := gin.H{
+		"results":      results,
+		"tests_passed": passed,
+		"tests_total":  len(results),
+		"passed":       allPassed,
+	}
+
+	if allPassed {
+		certificate, err := completeLesson(db, userID, lesson.ID, lesson.Module.CourseID)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to update lesson progress"))
+			return
+		}
+		if certificate != nil {
+			go sendCertificateEmail(detached(db), *certificate)
+			response["certificate"] = certificate
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -5,8 +5,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"log/slog"
 	"net/http"
-	"time"
 )
 
 type ProgressHandler struct {
@@ -19,24 +24,26 @@ func NewProgressHandler(db *gorm.DB) *ProgressHandler {
 
 // GetCourseProgress returns detailed progress for a specific course
 func (h *ProgressHandler) GetCourseProgress(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Abort(c, apierror.Unauthorized("User not authenticated"))
 		return
 	}
 
 	courseID := c.Param("id")
 
 	var enrollment models.Enrollment
-	if err := h.DB.Preload("Course").Preload("Course.Modules").Preload("Course.Modules.Lessons").
+	if err := db.Preload("Course").Preload("Course.Modules").Preload("Course.Modules.Lessons").
 		Where("user_id = ? AND course_id = ?", userID, courseID).
 		First(&enrollment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Enrollment not found"})
+		apierror.Abort(c, apierror.NotFound("Enrollment not found"))
 		return
 	}
+	hideCourseCodeTests(&enrollment.Course)
 
 	// Calculate detailed progress
-	progress := h.calculateDetailedProgress(enrollment.CourseID, userID.(uint))
+	progress := calculateDetailedProgress(db, enrollment.CourseID, userID.(uint))
 
 	c.JSON(http.StatusOK, gin.H{
 		"enrollment": enrollment,
@@ -46,9 +53,10 @@ func (h *ProgressHandler) GetCourseProgress(c *gin.Context) {
 
 // UpdateLessonProgress marks a lesson as completed and updates overall progress
 func (h *ProgressHandler) UpdateLessonProgress(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Abort(c, apierror.Unauthorized("User not authenticated"))
 		return
 	}
 
@@ -60,12 +68,30 @@ func (h *ProgressHandler) UpdateLessonProgress(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
+	// The lesson's completion criterion decides, not the client's completed flag
+	if request.Completed {
+		var lesson models.Lesson
+		if err := db.First(&lesson, request.LessonID).Error; err != nil {
+			apierror.Abort(c, apierror.NotFound("Lesson not found"))
+			return
+		}
+		reason, err := lessonCompletionBlocked(db, userID.(uint), lesson)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to check lesson completion"))
+			return
+		}
+		if reason != "" {
+			apierror.Abort(c, apierror.Conflict(reason).With("completion_criterion", lesson.CompletionCriterion))
+			return
+		}
+	}
+
 	// Start transaction
-	tx := h.DB.Begin()
+	tx := db.Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -86,58 +112,68 @@ func (h *ProgressHandler) UpdateLessonProgress(c *gin.Context) {
 			TimeSpent: request.TimeSpent,
 		}
 		if request.Completed {
-			lessonProgress.CompletedAt = time.Now()
+			lessonProgress.CompletedAt = clock.Now()
 		}
 		if err := tx.Create(&lessonProgress).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create lesson progress"})
+			apierror.Abort(c, apierror.Internal("Failed to create lesson progress"))
 			return
 		}
 	} else if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update lesson progress"})
+		apierror.Abort(c, apierror.Internal("Failed to update lesson progress"))
 		return
 	} else {
 		// Update existing progress
 		if request.Completed && !lessonProgress.Completed {
-			lessonProgress.CompletedAt = time.Now()
+			lessonProgress.CompletedAt = clock.Now()
 		}
 		lessonProgress.Completed = request.Completed
 		lessonProgress.TimeSpent += request.TimeSpent
 		if err := tx.Save(&lessonProgress).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update lesson progress"})
+			apierror.Abort(c, apierror.Internal("Failed to update lesson progress"))
 			return
 		}
 	}
 
-	// Update enrollment progress
-	if err := h.updateEnrollmentProgress(tx, userID.(uint), request.CourseID); err != nil {
+	// Update enrollment progress (issues the certificate when the course is completed)
+	certificate, err := updateEnrollmentProgress(tx, userID.(uint), request.CourseID)
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update course progress"})
+		apierror.Abort(c, apierror.Internal("Failed to update course progress"))
 		return
 	}
 
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save progress"})
+		apierror.Abort(c, apierror.Internal("Failed to save progress"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Progress updated successfully",
+	response := gin.H{
+		"message": i18n.T(i18n.Locale(c), "Progress updated successfully"),
 		"progress": gin.H{
 			"lesson_completed": request.Completed,
 			"time_spent":       request.TimeSpent,
 		},
-	})
+	}
+
+	if certificate != nil {
+		go sendCertificateEmail(detached(db), *certificate)
+		response["certificate"] = certificate
+		response["message"] = "Course completed! Your certificate has been issued."
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // GetStudentDashboard returns comprehensive student learning analytics
 func (h *ProgressHandler) GetStudentDashboard(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Abort(c, apierror.Unauthorized("User not authenticated"))
 		return
 	}
 
@@ -152,23 +188,23 @@ func (h *ProgressHandler) GetStudentDashboard(c *gin.Context) {
 	}
 
 	// Get enrollment stats
-	h.DB.Model(&models.Enrollment{}).Where("user_id = ?", userID).Count(&dashboard.TotalEnrollments)
-	h.DB.Model(&models.Enrollment{}).Where("user_id = ? AND progress >= ?", userID, 100).Count(&dashboard.CompletedCourses)
-	h.DB.Model(&models.Enrollment{}).Where("user_id = ? AND progress > ? AND progress < ?", userID, 0, 100).Count(&dashboard.CoursesInProgress)
+	db.Model(&models.Enrollment{}).Where("user_id = ?", userID).Count(&dashboard.TotalEnrollments)
+	db.Model(&models.Enrollment{}).Where("user_id = ? AND progress >= ?", userID, 100).Count(&dashboard.CompletedCourses)
+	db.Model(&models.Enrollment{}).Where("user_id = ? AND progress > ? AND progress < ?", userID, 0, 100).Count(&dashboard.CoursesInProgress)
 
 	// Get total learning time
-	h.DB.Model(&models.LessonProgress{}).Where("user_id = ?", userID).
+	db.Model(&models.LessonProgress{}).Where("user_id = ?", userID).
 		Select("COALESCE(SUM(time_spent), 0)").Scan(&dashboard.TotalLearningTime)
 
 	// Get average progress
-	h.DB.Model(&models.Enrollment{}).Where("user_id = ?", userID).
+	db.Model(&models.Enrollment{}).Where("user_id = ?", userID).
 		Select("COALESCE(AVG(progress), 0)").Scan(&dashboard.AverageProgress)
 
 	// Get certificates count
-	h.DB.Model(&models.Certificate{}).Where("user_id = ?", userID).Count(&dashboard.CertificatesCount)
+	db.Model(&models.Certificate{}).Where("user_id = ?", userID).Count(&dashboard.CertificatesCount)
 
 	// Get recent activity
-	h.DB.Preload("Lesson").Preload("Course").
+	db.Preload("Lesson").Preload("Course").
 		Where("user_id = ?", userID).
 		Order("updated_at DESC").Limit(10).
 		Find(&dashboard.RecentActivity)
@@ -181,9 +217,10 @@ func (h *ProgressHandler) GetStudentDashboard(c *gin.Context) {
 
 // GenerateCertificate generates a completion certificate for a course
 func (h *ProgressHandler) GenerateCertificate(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Abort(c, apierror.Unauthorized("User not authenticated"))
 		return
 	}
 
@@ -191,121 +228,153 @@ func (h *ProgressHandler) GenerateCertificate(c *gin.Context) {
 
 	// Check if enrollment exists and course is completed
 	var enrollment models.Enrollment
-	if err := h.DB.Preload("Course").Preload("User").
+	if err := db.Preload("Course").Preload("User").
 		Where("user_id = ? AND course_id = ?", userID, courseID).
 		First(&enrollment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Enrollment not found"})
+		apierror.Abort(c, apierror.NotFound("Enrollment not found"))
 		return
 	}
 
 	if enrollment.Progress < 100 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Course not completed. Progress: " + fmt.Sprintf("%.1f%%", enrollment.Progress),
-		})
+		apierror.Abort(c, apierror.BadRequest("Course not completed. Progress: "+fmt.Sprintf("%.1f%%", enrollment.Progress)))
 		return
 	}
 
 	if enrollment.CertificateID != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Certificate already issued"})
+		apierror.Abort(c, apierror.BadRequest("Certificate already issued"))
 		return
 	}
 
-	// Generate certificate
-	certificate, err := h.createCertificate(enrollment)
+	passed, err := requiredQuizzesPassed(db, enrollment.UserID, enrollment.CourseID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate certificate: " + err.Error()})
+		apierror.Abort(c, apierror.Internal("Failed to check quiz results"))
+		return
+	}
+	if !passed {
+		apierror.Abort(c, apierror.BadRequest("All required quizzes must be passed before a certificate is issued"))
 		return
 	}
 
-	// Update enrollment with certificate info
-	now := time.Now()
-	enrollment.CertificateID = &certificate.ID
-	enrollment.CertificateIssuedAt = &now
-	if err := h.DB.Save(&enrollment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update enrollment"})
+	// Generate certificate
+	certificate, err := issueCertificate(db, &enrollment)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to generate certificate").Wrap(err))
 		return
 	}
 
+	go sendCertificateEmail(detached(db), *certificate)
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":     "Certificate generated successfully",
+		"message":     i18n.T(i18n.Locale(c), "Certificate generated successfully"),
 		"certificate": certificate,
 	})
 }
 
 // GetCertificate returns certificate details
 func (h *ProgressHandler) GetCertificate(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	certificateID := c.Param("id")
 
 	var certificate models.Certificate
-	if err := h.DB.Preload("Enrollment").Preload("Enrollment.User").
-		Preload("Enrollment.Course").
+	if err := db.Preload("Enrollment").Preload("Enrollment.User", publicUserFields).
+		Preload("Enrollment.Course", courseBriefFields).
 		Where("id = ?", certificateID).
 		First(&certificate).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Certificate not found"})
+		apierror.Abort(c, apierror.NotFound("Certificate not found"))
+		return
+	}
+	// Only its holder and the course's instructor or an admin see the certificate; anyone may verify it
+	if certificate.Enrollment.UserID != c.MustGet("userID").(uint) && !ownsCourse(c, certificate.Enrollment.Course) {
+		apierror.Abort(c, apierror.NotFound("Certificate not found"))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"certificate": certificate,
+		"certificate": newCertificateView(certificate),
 	})
 }
 
 // VerifyCertificate allows public verification of certificates
 func (h *ProgressHandler) VerifyCertificate(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	verificationCode := c.Query("code")
 	certificateID := c.Query("id")
 
 	var certificate models.Certificate
-	query := h.DB.Preload("Enrollment").Preload("Enrollment.User").
-		Preload("Enrollment.Course")
+	query := db.Preload("Enrollment").Preload("Enrollment.User", publicUserFields).
+		Preload("Enrollment.Course", courseBriefFields)
 
 	if verificationCode != "" {
 		query = query.Where("verification_code = ?", verificationCode)
 	} else if certificateID != "" {
 		query = query.Where("id = ?", certificateID)
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification code or certificate ID required"})
+		apierror.Abort(c, apierror.BadRequest("Verification code or certificate ID required"))
 		return
 	}
 
 	if err := query.First(&certificate).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"valid": false,
-			"error": "Certificate not found or invalid",
-		})
+		apierror.Abort(c, apierror.NotFound("Certificate not found or invalid").With("valid", false))
 		return
 	}
 
+	status := certificate.Status(clock.Now())
+	details := gin.H{
+		"id":                certificate.ID,
+		"student_name":      certificate.Enrollment.User.FirstName + " " + certificate.Enrollment.User.LastName,
+		"course_title":      certificate.Enrollment.Course.Title,
+		"issue_date":        certificate.IssueDate.Format("January 2, 2006"),
+		"verification_code": certificate.VerificationCode,
+		"status":            status,
+	}
+	if certificate.Grade != "" {
+		details["grade"] = certificate.Grade
+	}
+	if certificate.ExpiryDate != nil {
+		details["expiry_date"] = certificate.ExpiryDate.Format("January 2, 2006")
+	}
+	if certificate.RevokedAt != nil {
+		details["revoked_at"] = certificate.RevokedAt.Format("January 2, 2006")
+		details["revocation_reason"] = certificate.RevocationReason
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"valid": true,
-		"certificate": gin.H{
-			"id":                certificate.ID,
-			"student_name":      certificate.Enrollment.User.FirstName + " " + certificate.Enrollment.User.LastName,
-			"course_title":      certificate.Enrollment.Course.Title,
-			"issue_date":        certificate.IssueDate.Format("January 2, 2006"),
-			"verification_code": certificate.VerificationCode,
-		},
+		"valid":       status == models.CertificateStatusValid,
+		"status":      status,
+		"certificate": details,
 	})
 }
 
-// Helper function to calculate detailed progress
-func (h *ProgressHandler) calculateDetailedProgress(courseID, userID uint) gin.H {
+// Helper function to calculate detailed progress.
+// db should be the transaction when called mid-update so uncommitted progress is counted.
+func calculateDetailedProgress(db *gorm.DB, courseID, userID uint) gin.H {
 	var totalLessons int64
 	var completedLessons int64
 	var totalTimeSpent int
 
+	// Students following a learning path progress through the path's lessons only
+	var enrollment models.Enrollment
+	db.Select("learning_path_id").Where("user_id = ? AND course_id = ?", userID, courseID).Limit(1).Find(&enrollment)
+	inScope := func(q *gorm.DB) *gorm.DB {
+		if enrollment.LearningPathID == nil {
+			return q
+		}
+		return q.Where("lessons.id IN (?)", db.Model(&models.LearningPathItem{}).Select("lesson_id").
+			Where("learning_path_id = ?", *enrollment.LearningPathID))
+	}
+
 	// Count total lessons in course
-	h.DB.Model(&models.Lesson{}).Joins("JOIN modules ON modules.id = lessons.module_id").
+	db.Model(&models.Lesson{}).Joins("JOIN modules ON modules.id = lessons.module_id").Scopes(inScope).
 		Where("modules.course_id = ?", courseID).Count(&totalLessons)
 
 	// Count completed lessons
-	h.DB.Model(&models.LessonProgress{}).Joins("JOIN lessons ON lessons.id = lesson_progresses.lesson_id").
-		Joins("JOIN modules ON modules.id = lessons.module_id").
+	db.Model(&models.LessonProgress{}).Joins("JOIN lessons ON lessons.id = lesson_progresses.lesson_id").
+		Joins("JOIN modules ON modules.id = lessons.module_id").Scopes(inScope).
 		Where("lesson_progresses.user_id = ? AND modules.course_id = ? AND lesson_progresses.completed = ?",
 			userID, courseID, true).Count(&completedLessons)
 
 	// Calculate total time spent
-	h.DB.Model(&models.LessonProgress{}).Joins("JOIN lessons ON lessons.id = lesson_progresses.lesson_id").
+	db.Model(&models.LessonProgress{}).Joins("JOIN lessons ON lessons.id = lesson_progresses.lesson_id").
 		Joins("JOIN modules ON modules.id = lessons.module_id").
 		Where("lesson_progresses.user_id = ? AND modules.course_id = ?", userID, courseID).
 		Select("COALESCE(SUM(lesson_progresses.time_spent), 0)").Scan(&totalTimeSpent)
@@ -322,38 +391,108 @@ func (h *ProgressHandler) calculateDetailedProgress(courseID, userID uint) gin.H
 		"time_spent_minutes":  totalTimeSpent,
 		"time_spent_hours":    float64(totalTimeSpent) / 60,
 		"remaining_lessons":   totalLessons - completedLessons,
+		"learning_path_id":    enrollment.LearningPathID,
 	}
 }
 
-// Helper function to update enrollment progress
-func (h *ProgressHandler) updateEnrollmentProgress(tx *gorm.DB, userID, courseID uint) error {
+// Helper function to update enrollment progress.
+// Returns the newly issued certificate when this update completes the course.
+func updateEnrollmentProgress(tx *gorm.DB, userID, courseID uint) (*models.Certificate, error) {
 	var enrollment models.Enrollment
 	if err := tx.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error; err != nil {
-		return err
+		return nil, err
 	}
 
 	// Calculate new progress
-	progress := h.calculateDetailedProgress(courseID, userID)
+	progress := calculateDetailedProgress(tx, courseID, userID)
 	enrollment.Progress = progress["progress_percentage"].(float64)
 
 	// Update completed lessons count
 	enrollment.CompletedLessons = int(progress["completed_lessons"].(int64))
 	enrollment.TotalLessons = int(progress["total_lessons"].(int64))
 	enrollment.TimeSpent = progress["time_spent_minutes"].(int)
-	enrollment.LastActivityAt = time.Now()
+	enrollment.LastActivityAt = clock.Now()
+
+	// Remember where to continue, following the learning path order when one is chosen
+	if lessons, err := orderedLessons(tx, courseID, userID, enrollment.LearningPathID); err == nil {
+		if next := nextIncompleteLesson(lessons); next != nil {
+			enrollment.CurrentLesson, enrollment.CurrentModule = &next.ID, &next.ModuleID
+		}
+	}
 
 	// Check if course is completed
 	if enrollment.Progress >= 100 && enrollment.CompletedAt == nil {
-		now := time.Now()
+		now := clock.Now()
 		enrollment.CompletedAt = &now
 	}
 
-	return tx.Save(&enrollment).Error
+	if err := tx.Save(&enrollment).Error; err != nil {
+		return nil, err
+	}
+
+	if err := awardLessonPoints(tx, userID, courseID); err != nil {
+		return nil, err
+	}
+
+	// Completing the last course of a track completes the track
+	if enrollment.CompletedAt != nil {
+		if err := completeTracks(tx, userID, courseID); err != nil {
+			return nil, err
+		}
+	}
+
+	return issueCertificateIfEligible(tx, &enrollment)
+}
+
+// issueCertificateIfEligible issues a certificate once the course is fully completed and
+// every required quiz has been passed. Returns nil when nothing was issued.
+func issueCertificateIfEligible(tx *gorm.DB, enrollment *models.Enrollment) (*models.Certificate, error) {
+	if enrollment.Progress < 100 || enrollment.CertificateID != nil {
+		return nil, nil
+	}
+
+	passed, err := requiredQuizzesPassed(tx, enrollment.UserID, enrollment.CourseID)
+	if err != nil || !passed {
+		return nil, err
+	}
+
+	return issueCertificate(tx, enrollment)
+}
+
+// requiredQuizzesPassed reports whether the user has passed every published required quiz of the course
+func requiredQuizzesPassed(db *gorm.DB, userID, courseID uint) (bool, error) {
+	var pending int64
+	err := db.Model(&models.Quiz{}).
+		Where("course_id = ? AND is_published = ? AND is_required = ?", courseID, true, true).
+		Where(`NOT EXISTS (SELECT 1 FROM quiz_attempts WHERE quiz_attempts.quiz_id = quizzes.id
+			AND quiz_attempts.user_id = ? AND quiz_attempts.is_passed = ? AND quiz_attempts.deleted_at IS NULL)`, userID, true).
+		Count(&pending).Error
+	return pending == 0, err
+}
+
+// issueCertificate creates the certificate and links it to the enrollment
+func issueCertificate(tx *gorm.DB, enrollment *models.Enrollment) (*models.Certificate, error) {
+	certificate, err := createCertificate(tx, *enrollment)
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now()
+	if err := tx.Model(enrollment).Updates(map[string]interface{}{
+		"certificate_id":        certificate.ID,
+		"certificate_issued_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	enrollment.CertificateID = &certificate.ID
+	enrollment.CertificateIssuedAt = &now
+
+	return certificate, nil
 }
 
 // Helper function to create certificate
-func (h *ProgressHandler) createCertificate(enrollment models.Enrollment) (*models.Certificate, error) {
-	certificateID := fmt.Sprintf("LHC-%d-%s", enrollment.ID, time.Now().Format("20060102"))
+func createCertificate(tx *gorm.DB, enrollment models.Enrollment) (*models.Certificate, error) {
+	certificateID := fmt.Sprintf("LHC-%d-%s", enrollment.ID, clock.Now().Format("20060102"))
 	verificationCode := generateVerificationCode()
 
 	certificate := models.Certificate{
@@ -361,24 +500,65 @@ func (h *ProgressHandler) createCertificate(enrollment models.Enrollment) (*mode
 		EnrollmentID:     enrollment.ID,
 		UserID:           enrollment.UserID,
 		CourseID:         enrollment.CourseID,
-		IssueDate:        time.Now(),
+		IssueDate:        clock.Now(),
 		VerificationCode: verificationCode,
-		CreatedAt:        time.Now(),
-		UpdatedAt:        time.Now(),
+		CreatedAt:        clock.Now(),
+		UpdatedAt:        clock.Now(),
+	}
+
+	// Record the course grade on the course's grading scale
+	if grades, err := courseGrades(tx, enrollment.CourseID, []uint{enrollment.UserID}); err == nil {
+		if percent, ok := grades[enrollment.UserID]; ok {
+			scale, _ := effectiveScale(tx, enrollment.CourseID)
+			certificate.Grade = scale.Letter(percent)
+			certificate.GradePercent = &percent
+		}
 	}
 
 	// Set expiry date (2 years from issue)
-	expiryDate := time.Now().AddDate(2, 0, 0)
+	expiryDate := clock.Now().AddDate(2, 0, 0)
 	certificate.ExpiryDate = &expiryDate
 
-	if err := h.DB.Create(&certificate).Error; err != nil {
+	// Rendered on demand with the course's certificate template
+	certificateURL := fmt.Sprintf("/api/certificates/%s/download", certificateID)
+	certificate.CertificateURL = &certificateURL
+
+	if err := tx.Create(&certificate).Error; err != nil {
 		return nil, err
 	}
+	emitCertificateIssued(tx, certificate)
 
 	return &certificate, nil
 }
 
+// sendCertificateEmail sends the congratulatory certificate email; meant to run in a goroutine
+func sendCertificateEmail(db *gorm.DB, certificate models.Certificate) {
+	if err := mailCertificate(db, certificate); err != nil {
+		slog.Error("Failed to send certificate email", "certificate_id", certificate.ID, "error", err)
+	}
+}
+
+// mailCertificate emails the certificate to its holder
+func mailCertificate(db *gorm.DB, certificate models.Certificate) error {
+	var user models.User
+	var course models.Course
+	if err := db.First(&user, certificate.UserID).Error; err != nil {
+		return fmt.Errorf("failed to load the holder: %w", err)
+	}
+	if err := db.First(&course, certificate.CourseID).Error; err != nil {
+		return fmt.Errorf("failed to load the course: %w", err)
+	}
+
+	certificateURL := ""
+	if certificate.CertificateURL != nil {
+		certificateURL = *certificate.CertificateURL
+	}
+
+	fullName := user.FirstName + " " + user.LastName
+	return email.SendCertificateEmail(user.Email, fullName, course.Title, certificate.ID, certificateURL, certificate.VerificationCode)
+}
+
 // Helper function to generate verification code
 func generateVerificationCode() string {
-	return fmt.Sprintf("LHC-%d", time.Now().UnixNano()%1000000)
+	return "LHC-" + idgen.Code(8)
 }
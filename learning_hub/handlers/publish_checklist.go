@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/fileupload"
+	"learning_hub/pkg/i18n"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// publishRuleTypes are the checks admins can require before publishing, with what the threshold measures
+var publishRuleTypes = map[string]string{
+	"min_lessons":            "Number of lessons",
+	"min_video_minutes":      "Total minutes of video lessons",
+	"min_description_length": "Characters in the course description",
+	"min_image_width":        "Width of the course image in pixels",
+	"min_image_height":       "Height of the course image in pixels",
+
+	// Accessibility: set to 100 to require captions, transcripts or alt text everywhere
+	"min_accessibility_score":       "Accessibility score of the course (0-100)",
+	"min_captioned_video_percent":   "Percent of video lessons with captions",
+	"min_transcribed_video_percent": "Percent of video lessons with a transcript",
+	"min_alt_text_percent":          "Percent of images with alt text, including the course image",
+}
+
+// publishCheck is the outcome of one checklist rule for a course
+type publishCheck struct {
+	Rule        string  `json:"rule"`
+	Description string  `json:"description"`
+	Required    float64 `json:"required"`
+	Actual      float64 `json:"actual"`
+	Passed      bool    `json:"passed"`
+	Message     string  `json:"message,omitempty"`
+}
+
+// publishChecklist evaluates the enabled publish rules against a course and reports whether all pass
+func publishChecklist(ctx context.Context, db *gorm.DB, course models.Course) ([]publishCheck, bool, error) {
+	var rules []models.PublishRule
+	if err := db.Where("enabled = ?", true).Order("id").Find(&rules).Error; err != nil {
+		return nil, false, err
+	}
+
+	lessons := db.Model(&models.Lesson{}).
+		Joins("JOIN modules ON modules.id = lessons.module_id AND modules.deleted_at IS NULL").
+		Where("modules.course_id = ?", course.ID)
+
+	// The course image is only read when a rule needs it
+	var width, height int
+	var imageErr error
+	imageLoaded := false
+	imageSize := func() (int, int, error) {
+		if !imageLoaded {
+			imageLoaded = true
+			key, ok := fileupload.ImageKeyFromURL(course.ImageURL)
+			if !ok {
+				imageErr = fmt.Errorf("the course image must be an uploaded image")
+			} else {
+				width, height, imageErr = fileupload.ImageDimensions(ctx, key)
+				if imageErr != nil {
+					imageErr = fmt.Errorf("the course image could not be read")
+				}
+			}
+		}
+		return width, height, imageErr
+	}
+
+	var accessibility *accessibilityReport
+	loadAccessibility := func() (*accessibilityReport, error) {
+		if accessibility == nil {
+			report, err := courseAccessibility(db, course)
+			if err != nil {
+				return nil, err
+			}
+			accessibility = &report
+		}
+		return accessibility, nil
+	}
+
+	checks := make([]publishCheck, 0, len(rules))
+	allPassed := true
+	for _, rule := range rules {
+		check := publishCheck{Rule: rule.Rule, Description: publishRuleTypes[rule.Rule], Required: rule.Threshold}
+
+		switch rule.Rule {
+		case "min_lessons":
+			var count int64
+			if err := lessons.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+				return nil, false, err
+			}
+			check.Actual = float64(count)
+		case "min_video_minutes":
+			var minutes int64
+			if err := lessons.Session(&gorm.Session{}).Where("lessons.video_url <> ''").
+				Select("COALESCE(SUM(lessons.duration), 0)").Scan(&minutes).Error; err != nil {
+				return nil, false, err
+			}
+			check.Actual = float64(minutes)
+		case "min_description_length":
+			check.Actual = float64(len([]rune(strings.TrimSpace(course.Description))))
+		case "min_image_width", "min_image_height":
+			w, h, err := imageSize()
+			if err != nil {
+				check.Message = err.Error()
+			} else if rule.Rule == "min_image_width" {
+				check.Actual = float64(w)
+			} else {
+				check.Actual = float64(h)
+			}
+		case "min_accessibility_score", "min_captioned_video_percent", "min_transcribed_video_percent", "min_alt_text_percent":
+			report, err := loadAccessibility()
+			if err != nil {
+				return nil, false, err
+			}
+			switch rule.Rule {
+			case "min_accessibility_score":
+				check.Actual = float64(report.Score)
+			case "min_captioned_video_percent":
+				check.Actual = report.CaptionedPercent
+			case "min_transcribed_video_percent":
+				check.Actual = report.TranscribedPercent
+			default:
+				check.Actual = report.AltTextPercent
+			}
+		default:
+			continue
+		}
+
+		check.Passed = check.Actual >= check.Required
+		if !check.Passed {
+			allPassed = false
+			if check.Message == "" {
+				check.Message = fmt.Sprintf("%s must be at least %g (currently %g)", check.Description, check.Required, check.Actual)
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks, allPassed, nil
+}
+
+type PublishChecklistHandler struct {
+	DB *gorm.DB
+}
+
+func NewPublishChecklistHandler(db *gorm.DB) *PublishChecklistHandler {
+	return &PublishChecklistHandler{DB: db}
+}
+
+// GetPublishRules returns the publish checklist and the rule types that can be used in it
+func (h *PublishChecklistHandler) GetPublishRules(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var rules []models.PublishRule
+	if err := db.Order("id").Find(&rules).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch publish checklist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules":      rules,
+		"rule_types": publishRuleTypes,
+	})
+}
+
+// SavePublishRules replaces the publish checklist. An empty list lets every course be published.
+func (h *PublishChecklistHandler) SavePublishRules(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Rules []struct {
+			Rule      string  `json:"rule" binding:"required"`
+			Threshold float64 `json:"threshold" binding:"min=0"`
+			Enabled   *bool   `json:"enabled"`
+		} `json:"rules"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	now := clock.Now()
+	rules := make([]models.PublishRule, 0, len(input.Rules))
+	seen := make(map[string]bool)
+	for _, r := range input.Rules {
+		if _, ok := publishRuleTypes[r.Rule]; !ok {
+			apierror.Abort(c, apierror.BadRequest("Unknown rule: "+r.Rule))
+			return
+		}
+		if seen[r.Rule] {
+			apierror.Abort(c, apierror.BadRequest("Duplicate rule: "+r.Rule))
+			return
+		}
+		seen[r.Rule] = true
+
+		enabled := r.Enabled == nil || *r.Enabled
+		rules = append(rules, models.PublishRule{Rule: r.Rule, Threshold: r.Threshold, Enabled: enabled, CreatedAt: now, UpdatedAt: now})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.PublishRule{}).Error; err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		return tx.Create(&rules).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save publish checklist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Publish checklist saved"),
+		"rules":   rules,
+	})
+}
+
+// GetPublishReport shows how a course does against the publish checklist without publishing it
+func (h *PublishChecklistHandler) GetPublishReport(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	checks, passed, err := publishChecklist(c.Request.Context(), db, course)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to evaluate publish checklist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"course_id":   course.ID,
+		"published":   course.Published,
+		"can_publish": passed,
+		"checks":      checks,
+	})
+}
+
+// PublishCourse publishes a course once it passes every rule of the checklist
+func (h *PublishChecklistHandler) PublishCourse(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canManageCourse)
+	if !ok {
+		return
+	}
+
+	checks, passed, err := publishChecklist(c.Request.Context(), db, course)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to evaluate publish checklist"))
+		return
+	}
+	if !passed {
+		apierror.Abort(c, apierror.New(http.StatusUnprocessableEntity, "Course does not meet the publish checklist").With("checks", checks))
+		return
+	}
+
+	wasPublished := course.Published
+	if err := db.Model(&course).Update("published", true).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to publish course"))
+		return
+	}
+	courseChanged(db, course, wasPublished, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Course published successfully"),
+		"course":  course,
+		"checks":  checks,
+	})
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/fileupload"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Where a quarantined file was uploaded
+const (
+	quarantineSourceUpload     = "upload"
+	quarantineSourceSubmission = "assignment_submission"
+)
+
+// quarantineUpload stores a file the virus scanner flagged outside the served upload directories,
+// records it and notifies admins
+func quarantineUpload(c *gin.Context, db *gorm.DB, file *multipart.FileHeader, source, signature string) {
+	record := models.QuarantinedFile{
+		Source:       source,
+		OriginalName: file.Filename,
+		Size:         file.Size,
+		Signature:    signature,
+		CreatedAt:    clock.Now(),
+	}
+	if userID, exists := c.Get("userID"); exists {
+		uid := userID.(uint)
+		record.UserID = &uid
+	}
+
+	if filename, err := generateSecureFilename(file.Filename); err == nil {
+		if src, err := file.Open(); err == nil {
+			key := "quarantine/" + filename
+			if err := fileupload.Storage().Save(c.Request.Context(), key, src, file.Size, "application/octet-stream"); err == nil {
+				record.StorageKey = key
+			} else {
+				slog.ErrorContext(c.Request.Context(), "Failed to quarantine file", "filename", file.Filename, "error", err)
+			}
+			src.Close()
+		}
+	}
+	if err := db.Create(&record).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to record quarantined file", "filename", file.Filename, "error", err)
+	}
+
+	uploader := "anonymous user"
+	if record.UserID != nil {
+		uploader = fmt.Sprintf("user %d", *record.UserID)
+	}
+	details := fmt.Sprintf("%s (%d bytes) uploaded by %s via %s was flagged as %s and quarantined (ID %d).",
+		file.Filename, file.Size, uploader, source, signature, record.ID)
+	go func() {
+		if err := email.SendAdminNotification("Malware upload quarantined", details); err != nil {
+			slog.Error("Failed to notify admins about quarantined file", "error", err)
+		}
+	}()
+}
+
+// rejectScannedFile responds to an upload the virus scan did not accept and reports whether it did so.
+// Flagged files are quarantined.
+func rejectScannedFile(c *gin.Context, db *gorm.DB, file *multipart.FileHeader, source string, err error) bool {
+	var infected *fileupload.InfectedError
+	switch {
+	case errors.As(err, &infected):
+		quarantineUpload(c, db, file, source, infected.Signature)
+		apierror.Abort(c, apierror.New(http.StatusUnprocessableEntity, "File rejected: malware detected"))
+		return true
+	case errors.Is(err, fileupload.ErrScanUnavailable):
+		apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, err.Error()))
+		return true
+	}
+	return false
+}
+
+// GetQuarantinedFiles lists uploads flagged by the virus scanner, newest first
+func (h *AdminHandler) GetQuarantinedFiles(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var files []models.QuarantinedFile
+	if err := db.Order("created_at DESC").Limit(200).Find(&files).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch quarantined files"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"files":            files,
+		"count":            len(files),
+		"scanning_enabled": fileupload.ScanningEnabled(),
+	})
+}
+
+// DeleteQuarantinedFile permanently deletes a quarantined file
+func (h *AdminHandler) DeleteQuarantinedFile(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var file models.QuarantinedFile
+	if err := db.First(&file, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Quarantined file not found"))
+		return
+	}
+
+	if file.StorageKey != "" {
+		if err := fileupload.Storage().Delete(c.Request.Context(), file.StorageKey); err != nil &&
+			!errors.Is(err, fileupload.ErrObjectNotFound) {
+			apierror.Abort(c, apierror.Internal("Failed to delete file"))
+			return
+		}
+	}
+	if err := db.Delete(&file).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete quarantine record"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Quarantined file deleted")})
+}
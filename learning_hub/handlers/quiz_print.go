@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/quizsheet"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Most results accepted in one paper import
+const maxPaperResults = 1000
+
+// loadManagedQuiz loads the :quizId quiz with its questions in order and checks the caller may manage its course
+func (h *AssessmentHandler) loadManagedQuiz(c *gin.Context) (models.Quiz, bool) {
+	db := h.db.WithContext(c.Request.Context())
+	var quiz models.Quiz
+	if err := db.Preload("Course").Preload("Questions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("order_index ASC, id ASC")
+	}).First(&quiz, c.Param("quizId")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Quiz not found"))
+		return quiz, false
+	}
+	if !canTeachCourse(c, db, quiz.Course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to manage this quiz"))
+		return quiz, false
+	}
+	return quiz, true
+}
+
+// quizSheet lays out a quiz for printing
+func quizSheet(quiz models.Quiz, answerKey bool) quizsheet.Sheet {
+	sheet := quizsheet.Sheet{
+		Title:        quiz.Title,
+		CourseTitle:  quiz.Course.Title,
+		Instructions: quiz.Instructions,
+		TimeLimit:    quiz.TimeLimit,
+		AnswerKey:    answerKey,
+	}
+	for _, q := range quiz.Questions {
+		var options []string
+		if len(q.Options) > 0 {
+			if err := json.Unmarshal(q.Options, &options); err != nil {
+				slog.Warn("Quiz question has invalid options", "question_id", q.ID, "error", err)
+			}
+		}
+		sheet.Questions = append(sheet.Questions, quizsheet.Question{
+			Text:        q.Question,
+			Type:        string(q.QuestionType),
+			Options:     options,
+			Points:      q.Points,
+			Answer:      q.CorrectAnswer,
+			Explanation: q.Explanation,
+		})
+	}
+	return sheet
+}
+
+// ExportQuiz downloads a quiz as a printable document for offline exams
+// (?format=pdf|docx, ?answer_key=true for the marking copy)
+func (h *AssessmentHandler) ExportQuiz(c *gin.Context) {
+	quiz, ok := h.loadManagedQuiz(c)
+	if !ok {
+		return
+	}
+	if len(quiz.Questions) == 0 {
+		apierror.Abort(c, apierror.BadRequest("Quiz has no questions"))
+		return
+	}
+
+	answerKey := c.Query("answer_key") == "true"
+	sheet := quizSheet(quiz, answerKey)
+	filename := fmt.Sprintf("quiz-%d", quiz.ID)
+	if answerKey {
+		filename += "-answer-key"
+	}
+
+	switch c.DefaultQuery("format", "pdf") {
+	case "pdf":
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.pdf"`)
+		c.Data(http.StatusOK, "application/pdf", quizsheet.RenderPDF(sheet))
+	case "docx":
+		doc, err := quizsheet.RenderDocx(sheet)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to generate document"))
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.docx"`)
+		c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", doc)
+	default:
+		apierror.Abort(c, apierror.BadRequest("format must be pdf or docx"))
+	}
+}
+
+// paperResult is one student's marked paper
+type paperResult struct {
+	UserID       uint    `json:"user_id"`
+	Email        string  `json:"email"`
+	EarnedPoints float64 `json:"earned_points"`
+}
+
+// bindPaperResults reads results from a JSON body ({"taken_at", "results": [...]}) or a CSV body
+// with an email or user_id column and an earned_points column
+func bindPaperResults(c *gin.Context) ([]paperResult, time.Time, error) {
+	takenAt := clock.Now()
+	if c.ContentType() != "text/csv" {
+		var input struct {
+			TakenAt *time.Time    `json:"taken_at"`
+			Results []paperResult `json:"results" binding:"required,min=1"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			return nil, takenAt, err
+		}
+		if input.TakenAt != nil {
+			takenAt = *input.TakenAt
+		}
+		return input.Results, takenAt, nil
+	}
+
+	if value := c.Query("taken_at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, takenAt, errors.New("taken_at must be an RFC3339 time")
+		}
+		takenAt = parsed
+	}
+
+	reader := csv.NewReader(io.LimitReader(c.Request.Body, 1<<20))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, takenAt, errors.New("CSV is empty")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	pointsColumn, ok := columns["earned_points"]
+	if !ok {
+		return nil, takenAt, errors.New("CSV needs an earned_points column")
+	}
+	emailColumn, hasEmail := columns["email"]
+	userColumn, hasUser := columns["user_id"]
+	if !hasEmail && !hasUser {
+		return nil, takenAt, errors.New("CSV needs an email or user_id column")
+	}
+
+	var results []paperResult
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, takenAt, err
+		}
+
+		var result paperResult
+		field := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if result.EarnedPoints, err = strconv.ParseFloat(field(pointsColumn), 64); err != nil {
+			return nil, takenAt, fmt.Errorf("line %d: earned_points must be a number", line)
+		}
+		if hasEmail {
+			result.Email = field(emailColumn)
+		}
+		if hasUser && field(userColumn) != "" {
+			id, err := strconv.ParseUint(field(userColumn), 10, 64)
+			if err != nil {
+				return nil, takenAt, fmt.Errorf("line %d: user_id must be a number", line)
+			}
+			result.UserID = uint(id)
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return nil, takenAt, errors.New("CSV has no results")
+	}
+	return results, takenAt, nil
+}
+
+// ImportPaperResults records the marks of a quiz taken on paper as completed attempts, so they count in
+// the gradebook like online attempts. Importing a student again replaces their earlier paper result.
+// Nothing is saved when any row is invalid.
+func (h *AssessmentHandler) ImportPaperResults(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	quiz, ok := h.loadManagedQuiz(c)
+	if !ok {
+		return
+	}
+	recordedBy := c.MustGet("userID").(uint)
+
+	results, takenAt, err := bindPaperResults(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	if len(results) > maxPaperResults {
+		apierror.Abort(c, apierror.BadRequest(fmt.Sprintf("Import at most %d results at a time", maxPaperResults)))
+		return
+	}
+	if takenAt.After(clock.Now()) {
+		apierror.Abort(c, apierror.BadRequest("taken_at cannot be in the future"))
+		return
+	}
+
+	var totalPoints float64
+	for _, q := range quiz.Questions {
+		totalPoints += float64(q.Points)
+	}
+	if totalPoints <= 0 {
+		apierror.Abort(c, apierror.BadRequest("Quiz has no points to score"))
+		return
+	}
+
+	// Match rows to actively enrolled students by id or email
+	var enrollments []models.Enrollment
+	if err := db.Preload("User").Where("course_id = ? AND is_active = ?", quiz.CourseID, true).
+		Find(&enrollments).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch enrollments"))
+		return
+	}
+	byID := make(map[uint]models.Enrollment, len(enrollments))
+	byEmail := make(map[string]models.Enrollment, len(enrollments))
+	for _, e := range enrollments {
+		byID[e.UserID] = e
+		byEmail[strings.ToLower(e.User.Email)] = e
+	}
+
+	var rowErrors []gin.H
+	matched := make([]models.Enrollment, len(results))
+	seen := make(map[uint]int)
+	for i, result := range results {
+		enrollment, found := byID[result.UserID]
+		if result.UserID == 0 {
+			enrollment, found = byEmail[strings.ToLower(strings.TrimSpace(result.Email))]
+		}
+		switch {
+		case result.UserID == 0 && strings.TrimSpace(result.Email) == "":
+			rowErrors = append(rowErrors, gin.H{"row": i + 1, "error": "user_id or email is required"})
+		case !found:
+			rowErrors = append(rowErrors, gin.H{"row": i + 1, "error": "Student is not enrolled in this course"})
+		case result.EarnedPoints < 0 || result.EarnedPoints > totalPoints:
+			rowErrors = append(rowErrors, gin.H{"row": i + 1, "error": fmt.Sprintf("earned_points must be between 0 and %g", totalPoints)})
+		case seen[enrollment.UserID] != 0:
+			rowErrors = append(rowErrors, gin.H{"row": i + 1, "error": fmt.Sprintf("Student already listed in row %d", seen[enrollment.UserID])})
+		default:
+			seen[enrollment.UserID] = i + 1
+			matched[i] = enrollment
+		}
+	}
+	if len(rowErrors) > 0 {
+		apierror.Abort(c, apierror.New(http.StatusUnprocessableEntity, "No results were imported, fix these rows and try again").With("rows", rowErrors))
+		return
+	}
+
+	attempts := make([]models.QuizAttempt, len(results))
+	created := 0
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i, result := range results {
+			score := result.EarnedPoints / totalPoints * 100
+			completedAt := takenAt
+			attempt := models.QuizAttempt{}
+			err := tx.Where("user_id = ? AND quiz_id = ? AND is_paper = ?", matched[i].UserID, quiz.ID, true).
+				First(&attempt).Error
+			var before gin.H
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				created++
+			} else if err != nil {
+				return err
+			} else {
+				before = gin.H{"earned_points": attempt.EarnedPoints, "score": attempt.Score, "passed": attempt.IsPassed}
+			}
+
+			attempt.UserID = matched[i].UserID
+			attempt.QuizID = quiz.ID
+			attempt.Score = score
+			attempt.TotalPoints = totalPoints
+			attempt.EarnedPoints = result.EarnedPoints
+			attempt.IsCompleted = true
+			attempt.IsPassed = score >= float64(quiz.PassingScore)
+			attempt.StartedAt = takenAt
+			attempt.CompletedAt = &completedAt
+			attempt.IsPaper = true
+			attempt.RecordedByID = &recordedBy
+			if attempt.ID == 0 {
+				if _, err := numberQuizAttempt(tx, &attempt); err != nil {
+					return err
+				}
+			}
+			if err := tx.Save(&attempt).Error; err != nil {
+				return err
+			}
+			if before == nil || before["earned_points"] != attempt.EarnedPoints {
+				recordAudit(tx, c, models.AuditGradeChange, "quiz_attempt", attempt.ID, before, gin.H{
+					"earned_points": attempt.EarnedPoints,
+					"score":         attempt.Score,
+					"passed":        attempt.IsPassed,
+					"user_id":       attempt.UserID,
+					"quiz_id":       quiz.ID,
+				})
+			}
+			attempts[i] = attempt
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save results"))
+		return
+	}
+
+	for i, attempt := range attempts {
+		if attempt.IsPassed {
+			if err := awardQuizPoints(db, attempt.UserID, quiz); err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to award quiz points", "user_id", attempt.UserID, "error", err)
+			}
+			if certificate, err := completeQuizLesson(db, attempt.UserID, quiz); err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to complete the lesson of the quiz", "quiz_id", quiz.ID, "user_id", attempt.UserID, "error", err)
+			} else if certificate != nil {
+				go sendCertificateEmail(detached(db), *certificate)
+			}
+		}
+		// Passing the last required quiz of a completed course issues the certificate
+		if attempt.IsPassed && quiz.IsRequired {
+			enrollment := matched[i]
+			if certificate, err := issueCertificateIfEligible(db, &enrollment); err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to issue certificate", "enrollment_id", enrollment.ID, "error", err)
+			} else if certificate != nil {
+				go sendCertificateEmail(detached(db), *certificate)
+			}
+		}
+
+		notifyUser(db, attempt.UserID, models.NotificationGrading, "Your paper exam for \""+quiz.Title+"\" has been graded", gin.H{
+			"attempt_id":    attempt.ID,
+			"quiz_id":       quiz.ID,
+			"course_id":     quiz.CourseID,
+			"score":         attempt.Score,
+			"earned_points": attempt.EarnedPoints,
+			"total_points":  attempt.TotalPoints,
+			"passed":        attempt.IsPassed,
+		})
+	}
+
+	response := gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Imported %d results", len(attempts)),
+		"created":  created,
+		"updated":  len(attempts) - created,
+		"attempts": attempts,
+	}
+	if !quiz.IsPublished {
+		response["warning"] = "The quiz is not published, so these results do not count toward course grades until it is"
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Enrollments in this window make a course popular
+	recommendationPopularityWindow = 90 * 24 * time.Hour
+	// Courses need this many reviews before their rating counts
+	recommendationMinReviews = 3
+	// At most this many catalog courses are considered per request
+	recommendationMaxCandidates = 1000
+)
+
+// courseLevels orders course levels, see Course.Level
+var courseLevels = map[string]int{"beginner": 0, "intermediate": 1, "advanced": 2}
+
+// RecommendationProfile is what a student's enrollment history says about their interests
+type RecommendationProfile struct {
+	UserID uint
+	// Interest per category: each enrolled course counts 1, completed ones 2
+	Categories map[string]float64
+	// Highest level completed per category
+	CompletedLevels map[string]string
+	Enrollments     int
+}
+
+// RecommendationCandidate is a course the student could take, with its catalog statistics
+type RecommendationCandidate struct {
+	Course            models.Course
+	RecentEnrollments int64
+	AverageRating     float64
+	Reviews           int64
+}
+
+// ScoredCourse is a recommended course with why it was picked
+type ScoredCourse struct {
+	Course  models.Course `json:"course"`
+	Score   float64       `json:"score"`
+	Reasons []string      `json:"reasons"`
+}
+
+// RecommendationStrategy scores candidate courses for a student, higher is better. Strategies may
+// read more data through db, e.g. what similar students enrolled in for collaborative filtering.
+type RecommendationStrategy interface {
+	Name() string
+	Score(db *gorm.DB, profile RecommendationProfile, candidates []RecommendationCandidate) ([]ScoredCourse, error)
+}
+
+// HistoryStrategy favours the categories the student studies, the next level after what they completed,
+// and courses that are popular and well rated. Students without enrollments get the popular ones.
+type HistoryStrategy struct{}
+
+func (HistoryStrategy) Name() string { return "history" }
+
+func (HistoryStrategy) Score(db *gorm.DB, profile RecommendationProfile, candidates []RecommendationCandidate) ([]ScoredCourse, error) {
+	maxAffinity, maxRecent := 0.0, int64(0)
+	for _, affinity := range profile.Categories {
+		if affinity > maxAffinity {
+			maxAffinity = affinity
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate.RecentEnrollments > maxRecent {
+			maxRecent = candidate.RecentEnrollments
+		}
+	}
+
+	scored := make([]ScoredCourse, 0, len(candidates))
+	for _, candidate := range candidates {
+		course := candidate.Course
+		result := ScoredCourse{Course: course, Reasons: []string{}}
+
+		if affinity := profile.Categories[course.Category]; affinity > 0 && course.Category != "" {
+			result.Score += 3 * affinity / maxAffinity
+			result.Reasons = append(result.Reasons, "You study "+course.Category)
+		}
+		if completed, ok := profile.CompletedLevels[course.Category]; ok && course.Category != "" {
+			switch courseLevels[course.Level] - courseLevels[completed] {
+			case 1:
+				result.Score++
+				result.Reasons = append(result.Reasons, "Next level after the "+course.Category+" courses you completed")
+			case -1, -2:
+				result.Score-- // easier than what they already finished
+			}
+		}
+		if maxRecent > 0 && candidate.RecentEnrollments > 0 {
+			popularity := float64(candidate.RecentEnrollments) / float64(maxRecent)
+			result.Score += 1.5 * popularity
+			if popularity >= 0.5 {
+				result.Reasons = append(result.Reasons, "Popular with students right now")
+			}
+		}
+		if candidate.Reviews >= recommendationMinReviews && candidate.AverageRating > 3 {
+			result.Score += (candidate.AverageRating - 3) / 2
+			if candidate.AverageRating >= 4.5 {
+				result.Reasons = append(result.Reasons, fmt.Sprintf("Rated %.1f by %d students", candidate.AverageRating, candidate.Reviews))
+			}
+		}
+		scored = append(scored, result)
+	}
+	return scored, nil
+}
+
+type RecommendationHandler struct {
+	DB *gorm.DB
+	// Strategy ranks the courses; swap it to change how recommendations are made
+	Strategy RecommendationStrategy
+}
+
+func NewRecommendationHandler(db *gorm.DB) *RecommendationHandler {
+	return &RecommendationHandler{DB: db, Strategy: HistoryStrategy{}}
+}
+
+// recommendationProfile reads the student's enrollments
+func recommendationProfile(db *gorm.DB, userID uint) (RecommendationProfile, error) {
+	profile := RecommendationProfile{
+		UserID:          userID,
+		Categories:      map[string]float64{},
+		CompletedLevels: map[string]string{},
+	}
+
+	var history []struct {
+		Category string
+		Level    string
+		Progress float64
+	}
+	if err := db.Table("enrollments").
+		Joins("JOIN courses ON courses.id = enrollments.course_id").
+		Where("enrollments.user_id = ?", userID).
+		Select("courses.category, courses.level, enrollments.progress").
+		Scan(&history).Error; err != nil {
+		return profile, err
+	}
+
+	profile.Enrollments = len(history)
+	for _, enrollment := range history {
+		if enrollment.Category == "" {
+			continue
+		}
+		if enrollment.Progress < 100 {
+			profile.Categories[enrollment.Category]++
+			continue
+		}
+		profile.Categories[enrollment.Category] += 2
+		if best, ok := profile.CompletedLevels[enrollment.Category]; !ok || courseLevels[enrollment.Level] > courseLevels[best] {
+			profile.CompletedLevels[enrollment.Category] = enrollment.Level
+		}
+	}
+	return profile, nil
+}
+
+// recommendationCandidates lists the published courses offered in the country that the user neither
+// takes nor teaches, with their recent enrollments and ratings
+func recommendationCandidates(db *gorm.DB, userID uint, country string) ([]RecommendationCandidate, error) {
+	var courses []models.Course
+	query := db.Where("published = ? AND instructor_id <> ?", true, userID).
+		Where("id NOT IN (?)", db.Model(&models.Enrollment{}).Select("course_id").Where("user_id = ?", userID))
+	if err := availableInCountry(query, country).
+		Preload("Instructor", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, first_name, last_name")
+		}).
+		Order("id DESC").Limit(recommendationMaxCandidates).
+		Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	if len(courses) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, len(courses))
+	for i, course := range courses {
+		ids[i] = course.ID
+	}
+
+	var recent []struct {
+		CourseID uint
+		Count    int64
+	}
+	if err := db.Model(&models.Enrollment{}).Scopes(withoutTestStudents).
+		Where("course_id IN ? AND enrolled_at >= ?", ids, clock.Now().Add(-recommendationPopularityWindow)).
+		Group("course_id").Select("course_id, COUNT(*) AS count").
+		Scan(&recent).Error; err != nil {
+		return nil, err
+	}
+	var ratings []struct {
+		CourseID uint
+		Average  float64
+		Count    int64
+	}
+	if err := db.Model(&models.Review{}).
+		Where("course_id IN ? AND status = ?", ids, models.ContentPublished).
+		Group("course_id").Select("course_id, AVG(rating) AS average, COUNT(*) AS count").
+		Scan(&ratings).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := make([]RecommendationCandidate, len(courses))
+	index := make(map[uint]int, len(courses))
+	for i, course := range courses {
+		candidates[i].Course = course
+		index[course.ID] = i
+	}
+	for _, row := range recent {
+		candidates[index[row.CourseID]].RecentEnrollments = row.Count
+	}
+	for _, row := range ratings {
+		candidates[index[row.CourseID]].AverageRating = row.Average
+		candidates[index[row.CourseID]].Reviews = row.Count
+	}
+	return candidates, nil
+}
+
+// GetRecommendations suggests courses for the caller from their enrollment history and what is
+// popular, best first (?limit=, default 10, at most 50). Prices are those of the caller's country.
+func (h *RecommendationHandler) GetRecommendations(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 50 {
+		apierror.Abort(c, apierror.BadRequest("limit must be between 1 and 50"))
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+	country := requestCountry(c, db)
+
+	profile, err := recommendationProfile(db, userID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to read your enrollments"))
+		return
+	}
+	candidates, err := recommendationCandidates(db, userID, country)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch courses"))
+		return
+	}
+	scored, err := h.Strategy.Score(db, profile, candidates)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to rank courses"))
+		return
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].Score != scored[j].Score {
+			return scored[i].Score > scored[j].Score
+		}
+		return scored[i].Course.ID > scored[j].Course.ID
+	})
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+
+	courses := make([]models.Course, len(scored))
+	for i := range scored {
+		courses[i] = scored[i].Course
+	}
+	if err := applyCountryPrices(db, courses, country); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch course prices"))
+		return
+	}
+	for i := range scored {
+		scored[i].Course = courses[i]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recommendations": scored,
+		"count":           len(scored),
+		"strategy":        h.Strategy.Name(),
+		"personalized":    profile.Enrollments > 0,
+		"country":         country,
+	})
+}
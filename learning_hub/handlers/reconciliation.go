@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/chapa"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/config"
+	"learning_hub/pkg/stripe"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errReconciliationRunning = errors.New("payment reconciliation already running")
+
+// reconcileBatchSize caps the pending payments verified in one run, oldest first
+const reconcileBatchSize = 200
+
+type ReconciliationHandler struct {
+	DB *gorm.DB
+
+	// Pending payments younger than this are left to their webhook
+	After time.Duration
+
+	running sync.Mutex
+}
+
+func NewReconciliationHandler(db *gorm.DB, cfg *config.Config) *ReconciliationHandler {
+	return &ReconciliationHandler{DB: db, After: cfg.PaymentReconcileAfter}
+}
+
+// providerPayment is what a payment provider reports about one of our payments
+type providerPayment struct {
+	Status   models.PaymentStatus // success, failed, or pending while the buyer may still pay
+	Amount   float64
+	Currency string
+	Method   string
+	RefID    string
+}
+
+// verifyWithProvider asks the payment's provider where it stands
+func verifyWithProvider(ctx context.Context, payment models.Payment) (providerPayment, error) {
+	if payment.Provider == providerStripe {
+		session, err := stripe.GetSession(ctx, payment.ProviderRef)
+		if err != nil {
+			return providerPayment{}, err
+		}
+		result := providerPayment{
+			Status:   models.PaymentStatusPending,
+			Amount:   float64(session.AmountTotal) / 100,
+			Currency: strings.ToUpper(session.Currency),
+			Method:   chapa.MethodCard,
+			RefID:    session.ID,
+		}
+		switch {
+		case session.Paid():
+			result.Status = models.PaymentStatusSuccess
+		case session.Status == "expired":
+			result.Status = models.PaymentStatusFailed
+		}
+		return result, nil
+	}
+
+	resp, err := chapa.VerifyPayment(ctx, payment.ChapaTxRef)
+	if err != nil {
+		return providerPayment{}, err
+	}
+	result := providerPayment{
+		Status:   models.PaymentStatusPending,
+		Amount:   resp.Data.Amount,
+		Currency: strings.ToUpper(resp.Data.Currency),
+		Method:   chapa.NormalizePaymentMethod(resp.Data.Method),
+		RefID:    resp.Data.Reference,
+	}
+	switch strings.ToLower(resp.Data.Status) {
+	case "success":
+		result.Status = models.PaymentStatusSuccess
+	case "failed", "cancelled":
+		result.Status = models.PaymentStatusFailed
+	}
+	return result, nil
+}
+
+// reconcileFinding is a payment in a reconciliation report
+type reconcileFinding struct {
+	PaymentID        uint                 `json:"payment_id"`
+	TxRef            string               `json:"tx_ref"`
+	Provider         string               `json:"provider"`
+	UserID           uint                 `json:"user_id"`
+	CourseID         uint                 `json:"course_id"`
+	Kind             string               `json:"kind"`
+	Status           models.PaymentStatus `json:"status"` // ours after the run
+	Amount           float64              `json:"amount"`
+	Currency         string               `json:"currency"`
+	ProviderStatus   models.PaymentStatus `json:"provider_status,omitempty"`
+	ProviderAmount   float64              `json:"provider_amount,omitempty"`
+	ProviderCurrency string               `json:"provider_currency,omitempty"`
+	Error            string               `json:"error,omitempty"`
+}
+
+// ReconcilePayments re-verifies payments pending for longer than After with their provider: ones paid
+// there are settled as their webhook would have, failed ones are marked failed, and ones still unpaid past
+// pendingPaymentTTL are failed as abandoned. Payments paid for a different amount are left for an admin.
+// Successful payments whose buyer isn't enrolled are enrolled then, unless an admin dismissed that on the
+// integrity dashboard. Each run is recorded as a report; admins are notified when it changed or flagged
+// anything.
+func (h *ReconciliationHandler) ReconcilePayments(ctx context.Context) error {
+	_, err := h.reconcile(ctx)
+	return err
+}
+
+func (h *ReconciliationHandler) reconcile(ctx context.Context) (*models.PaymentReconciliation, error) {
+	if !h.running.TryLock() {
+		return nil, errReconciliationRunning
+	}
+	defer h.running.Unlock()
+
+	db := h.DB.WithContext(ctx)
+	now := clock.Now()
+	report := models.PaymentReconciliation{StartedAt: now}
+
+	var payments []models.Payment
+	if err := db.Where("status = ? AND created_at < ? AND mismatch_flagged_at IS NULL",
+		models.PaymentStatusPending, now.Add(-h.After)).
+		Order("created_at").Limit(reconcileBatchSize).Find(&payments).Error; err != nil {
+		return nil, err
+	}
+
+	settler := &PaymentHandler{db: h.DB}
+	findings := []reconcileFinding{}
+	for _, payment := range payments {
+		if ctx.Err() != nil {
+			break
+		}
+		report.Checked++
+		finding := reconcileFinding{
+			PaymentID: payment.ID,
+			TxRef:     payment.ChapaTxRef,
+			Provider:  payment.Provider,
+			UserID:    payment.UserID,
+			CourseID:  payment.CourseID,
+			Amount:    payment.Amount,
+			Currency:  payment.Currency,
+		}
+		abandoned := payment.CreatedAt.Before(now.Add(-pendingPaymentTTL))
+
+		remote, err := verifyWithProvider(ctx, payment)
+		switch {
+		case err != nil && !abandoned:
+			// Checkouts never opened may be unknown to the provider; they're failed once abandoned
+			finding.Kind, finding.Error = models.ReconcileVerifyError, err.Error()
+			report.Errors++
+
+		case err == nil && remote.Status == models.PaymentStatusSuccess:
+			finding.ProviderStatus, finding.ProviderAmount, finding.ProviderCurrency = remote.Status, remote.Amount, remote.Currency
+			if math.Abs(remote.Amount-payment.Amount) > 0.005 || (remote.Currency != "" && remote.Currency != payment.Currency) {
+				finding.Kind = models.ReconcileAmountMismatch
+				report.Mismatches++
+				if err := db.Model(&payment).Update("mismatch_flagged_at", now).Error; err != nil {
+					return nil, err
+				}
+				break
+			}
+			if payment.Provider == providerStripe {
+				payment.ProviderRef = remote.RefID
+			} else {
+				payment.ChapaRefID = remote.RefID
+			}
+			payment.PaymentMethod = remote.Method
+			if err := settler.settlePayment(ctx, payment, models.PaymentStatusPending, true); err != nil {
+				finding.Kind, finding.Error = models.ReconcileVerifyError, err.Error()
+				report.Errors++
+				break
+			}
+			finding.Kind = models.ReconcileMissedWebhook
+			report.Settled++
+
+		case err == nil && remote.Status == models.PaymentStatusFailed, abandoned:
+			finding.Kind = models.ReconcileProviderFailed
+			if err == nil {
+				finding.ProviderStatus = remote.Status
+			} else {
+				finding.Error = err.Error()
+			}
+			if remote.Status != models.PaymentStatusFailed {
+				finding.Kind = models.ReconcileAbandoned
+			}
+			// Only if still pending: a webhook may have settled it meanwhile
+			result := db.Model(&models.Payment{}).
+				Where("id = ? AND status = ?", payment.ID, models.PaymentStatusPending).
+				Update("status", models.PaymentStatusFailed)
+			if result.Error != nil {
+				return nil, result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			payment.Status = models.PaymentStatusFailed
+			auditPaymentStatus(db, nil, payment, models.PaymentStatusPending)
+			report.Failed++
+
+		default:
+			// Still payable at the provider
+			continue
+		}
+
+		var current models.Payment
+		if db.Select("id, status").First(&current, payment.ID).Error == nil {
+			finding.Status = current.Status
+		}
+		findings = append(findings, finding)
+	}
+
+	enrolled, err := h.enrollMissedBuyers(ctx, &report)
+	if err != nil {
+		return nil, err
+	}
+	findings = append(findings, enrolled...)
+
+	details, _ := json.Marshal(findings)
+	report.Details = models.JSON(details)
+	report.FinishedAt = clock.Now()
+	// Runs with nothing to check or enroll aren't kept
+	if report.Checked == 0 && len(enrolled) == 0 {
+		return &report, nil
+	}
+	if err := db.Create(&report).Error; err != nil {
+		return nil, err
+	}
+	if report.Settled > 0 || report.Mismatches > 0 || report.Enrolled > 0 {
+		notifyAdmins(db, models.NotificationReconcile,
+			fmt.Sprintf("Payment reconciliation: %d settled, %d mismatches, %d enrolled", report.Settled, report.Mismatches, report.Enrolled),
+			gin.H{"reconciliation_id": report.ID, "settled": report.Settled, "mismatches": report.Mismatches, "enrolled": report.Enrolled})
+	}
+	slog.InfoContext(ctx, "Reconciled pending payments", "checked", report.Checked, "settled", report.Settled,
+		"failed", report.Failed, "mismatches", report.Mismatches, "enrolled", report.Enrolled, "errors", report.Errors)
+	return &report, nil
+}
+
+// enrollMissedBuyers enrolls the buyers of successful payments that didn't enroll them, as when the API
+// stopped between taking a payment and enrolling. Payments whose integrity issue an admin dismissed are
+// left alone.
+func (h *ReconciliationHandler) enrollMissedBuyers(ctx context.Context, report *models.PaymentReconciliation) ([]reconcileFinding, error) {
+	db := h.DB.WithContext(ctx)
+	var payments []models.Payment
+	if err := paymentsWithoutEnrollment(db).
+		Where(`NOT EXISTS (SELECT 1 FROM integrity_issues WHERE integrity_issues.type = ?
+			AND integrity_issues.entity_id = CAST(payments.id AS TEXT) AND integrity_issues.resolution = ?)`,
+			models.IntegrityPaymentWithoutEnrollment, models.IntegrityDismissed).
+		Order("updated_at").Limit(reconcileBatchSize).Find(&payments).Error; err != nil {
+		return nil, err
+	}
+
+	findings := make([]reconcileFinding, 0, len(payments))
+	for _, payment := range payments {
+		if ctx.Err() != nil {
+			break
+		}
+		finding := reconcileFinding{
+			PaymentID: payment.ID,
+			TxRef:     payment.ChapaTxRef,
+			Provider:  payment.Provider,
+			UserID:    payment.UserID,
+			CourseID:  payment.CourseID,
+			Kind:      models.ReconcileNotEnrolled,
+			Status:    payment.Status,
+			Amount:    payment.Amount,
+			Currency:  payment.Currency,
+		}
+		var enrollment *models.Enrollment
+		err := inTransaction(db, func(tx *gorm.DB) error {
+			var err error
+			enrollment, err = enrollPayer(tx, payment)
+			return err
+		})
+		if err != nil {
+			finding.Kind, finding.Error = models.ReconcileEnrollError, err.Error()
+			report.Errors++
+		} else {
+			report.Enrolled++
+			if enrollment != nil {
+				emitEnrollmentCreated(db, *enrollment)
+			}
+			slog.WarnContext(ctx, "Enrolled the buyer of a payment that hadn't enrolled them", "payment_id", payment.ID,
+				"user_id", payment.UserID, "course_id", payment.CourseID, "track_id", payment.TrackID)
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// RunReconciliation reconciles pending payments now instead of waiting for the job and returns the report
+func (h *ReconciliationHandler) RunReconciliation(c *gin.Context) {
+	report, err := h.reconcile(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, errReconciliationRunning) {
+			apierror.Abort(c, apierror.Conflict("A reconciliation is already running"))
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Reconciliation failed").Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reconciliation": report})
+}
+
+// GetReconciliations lists reconciliation reports, newest first (?mismatches=true for runs that flagged
+// payments, ?page=)
+func (h *ReconciliationHandler) GetReconciliations(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	const pageSize = 50
+
+	query := db.Model(&models.PaymentReconciliation{})
+	if c.Query("mismatches") == "true" {
+		query = query.Where("mismatches > 0")
+	}
+	var total int64
+	query.Count(&total)
+
+	var reports []models.PaymentReconciliation
+	if err := query.Omit("details").Order("started_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&reports).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch reconciliations"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"reconciliations": reports,
+		"total":           total,
+		"page":            page,
+	})
+}
+
+// GetReconciliation returns a reconciliation report with the payments it settled, failed or flagged
+func (h *ReconciliationHandler) GetReconciliation(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var report models.PaymentReconciliation
+	if err := db.First(&report, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Reconciliation not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reconciliation": report})
+}
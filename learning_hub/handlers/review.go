@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	featuredReviewsCacheTTL = 10 * time.Minute
+	// The featured feed shows a different slice of the pool every rotation period
+	featuredReviewsRotation = time.Hour
+	featuredReviewsPoolSize = 50
+	// Reviews picked automatically when admins haven't featured enough
+	autoFeatureMinRating        = 4
+	autoFeatureMinCommentLength = 40
+)
+
+// ReviewHandler serves the public testimonials feed, its admin curation and moderation, and instructor replies
+type ReviewHandler struct {
+	DB *gorm.DB
+
+	mu             sync.Mutex
+	curated        []reviewSummary
+	auto           []reviewSummary
+	cacheExpiresAt time.Time
+}
+
+func NewReviewHandler(db *gorm.DB) *ReviewHandler {
+	return &ReviewHandler{DB: db}
+}
+
+// reviewSummary is a review as shown publicly: no email, reviewer last name shortened to an initial
+type reviewSummary struct {
+	ID               uint      `json:"id"`
+	Rating           int       `json:"rating"`
+	Comment          string    `json:"comment"`
+	Status           string    `json:"status"`
+	ReviewerName     string    `json:"reviewer_name"`
+	CourseID         uint      `json:"course_id"`
+	CourseTitle      string    `json:"course_title"`
+	Featured         bool      `json:"featured"`
+	VerifiedPurchase bool      `json:"verified_purchase"`
+	Reply            string    `json:"reply,omitempty"`
+	Flagged          bool      `json:"flagged"`
+	FlagReason       string    `json:"flag_reason,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	FirstName        string    `json:"-"`
+	LastName         string    `json:"-"`
+}
+
+func reviewQuery(db *gorm.DB) *gorm.DB {
+	return db.Table("reviews").
+		Joins("JOIN users ON users.id = reviews.user_id").
+		Joins("JOIN courses ON courses.id = reviews.course_id AND courses.deleted_at IS NULL").
+		Where("reviews.deleted_at IS NULL").
+		Select("reviews.id, reviews.rating, reviews.comment, reviews.status, reviews.featured, reviews.created_at, " +
+			"reviews.verified_purchase, reviews.reply, reviews.flagged, reviews.flag_reason, " +
+			"reviews.course_id, courses.title AS course_title, users.first_name, users.last_name")
+}
+
+func withReviewerNames(reviews []reviewSummary) []reviewSummary {
+	for i := range reviews {
+		reviews[i].ReviewerName = reviews[i].FirstName
+		if reviews[i].LastName != "" {
+			reviews[i].ReviewerName += " " + string([]rune(reviews[i].LastName)[0]) + "."
+		}
+	}
+	return reviews
+}
+
+// loadFeaturedPool returns the curated and automatically picked reviews, cached for featuredReviewsCacheTTL
+func (h *ReviewHandler) loadFeaturedPool(db *gorm.DB) ([]reviewSummary, []reviewSummary, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if clock.Now().Before(h.cacheExpiresAt) {
+		return h.curated, h.auto, nil
+	}
+
+	var curated, auto []reviewSummary
+	if err := reviewQuery(db).
+		Where("reviews.featured = ? AND courses.published = ?", true, true).
+		Where("reviews.status = ? AND reviews.flagged = ?", models.ContentPublished, false).
+		Order("reviews.featured_at DESC").
+		Limit(featuredReviewsPoolSize).
+		Scan(&curated).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := reviewQuery(db).
+		Where("reviews.featured = ? AND courses.published = ?", false, true).
+		Where("reviews.status = ? AND reviews.flagged = ?", models.ContentPublished, false).
+		Where("reviews.rating >= ? AND LENGTH(reviews.comment) >= ?", autoFeatureMinRating, autoFeatureMinCommentLength).
+		Order("reviews.rating DESC, reviews.created_at DESC").
+		Limit(featuredReviewsPoolSize).
+		Scan(&auto).Error; err != nil {
+		return nil, nil, err
+	}
+
+	h.curated, h.auto = withReviewerNames(curated), withReviewerNames(auto)
+	h.cacheExpiresAt = clock.Now().Add(featuredReviewsCacheTTL)
+	return h.curated, h.auto, nil
+}
+
+func (h *ReviewHandler) invalidateFeatured() {
+	h.mu.Lock()
+	h.cacheExpiresAt = time.Time{}
+	h.mu.Unlock()
+}
+
+// rotate starts the list at an offset that advances every featuredReviewsRotation
+func rotate(reviews []reviewSummary, now time.Time) []reviewSummary {
+	if len(reviews) == 0 {
+		return reviews
+	}
+	offset := int(now.Unix()/int64(featuredReviewsRotation.Seconds())) % len(reviews)
+	return append(append([]reviewSummary{}, reviews[offset:]...), reviews[:offset]...)
+}
+
+// GetFeaturedReviews returns testimonials for the homepage: admin-featured reviews first,
+// topped up with highly rated ones, rotating over time
+func (h *ReviewHandler) GetFeaturedReviews(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "6"))
+	if err != nil || limit < 1 || limit > 20 {
+		apierror.Abort(c, apierror.BadRequest("limit must be between 1 and 20"))
+		return
+	}
+
+	curated, auto, err := h.loadFeaturedPool(db)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch reviews"))
+		return
+	}
+
+	now := clock.Now()
+	reviews := append(rotate(curated, now), rotate(auto, now)...)
+	if len(reviews) > limit {
+		reviews = reviews[:limit]
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"reviews": reviews,
+		"count":   len(reviews),
+	})
+}
+
+// GetAdminReviews lists reviews for curation (?featured=true|false, ?flagged=true|false, ?min_rating=, ?course_id=, ?status=)
+func (h *ReviewHandler) GetAdminReviews(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	query := reviewQuery(db)
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("reviews.status = ?", status)
+	}
+	if featured := c.Query("featured"); featured != "" {
+		query = query.Where("reviews.featured = ?", featured == "true")
+	}
+	if flagged := c.Query("flagged"); flagged != "" {
+		query = query.Where("reviews.flagged = ?", flagged == "true")
+	}
+	if minRating := c.Query("min_rating"); minRating != "" {
+		query = query.Where("reviews.rating >= ?", minRating)
+	}
+	if courseID := c.Query("course_id"); courseID != "" {
+		query = query.Where("reviews.course_id = ?", courseID)
+	}
+
+	var reviews []reviewSummary
+	if err := query.Order("reviews.created_at DESC").Limit(200).Scan(&reviews).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch reviews"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews": withReviewerNames(reviews),
+		"count":   len(reviews),
+	})
+}
+
+// SetReviewFeatured adds a review to or removes it from the homepage testimonials
+func (h *ReviewHandler) SetReviewFeatured(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Featured *bool `json:"featured" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	var review models.Review
+	if err := db.First(&review, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Review not found"))
+		return
+	}
+	if *input.Featured && (review.Status != models.ContentPublished || review.Flagged) {
+		apierror.Abort(c, apierror.Conflict("Only published, unflagged reviews can be featured"))
+		return
+	}
+
+	updates := map[string]interface{}{"featured": *input.Featured, "featured_at": nil}
+	if *input.Featured {
+		updates["featured_at"] = clock.Now()
+	}
+	if err := db.Model(&review).Updates(updates).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update review"))
+		return
+	}
+	h.invalidateFeatured()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Review updated successfully"),
+		"id":       review.ID,
+		"featured": *input.Featured,
+	})
+}
+
+// ModerateReview hides a published review from students or shows it again, and flags or unflags it.
+// Flagged reviews stay visible but are kept off the homepage.
+func (h *ReviewHandler) ModerateReview(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	adminID, _ := c.Get("userID")
+
+	var input struct {
+		Action string `json:"action" binding:"required,oneof=hide unhide flag unflag"`
+		Reason string `json:"reason" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	var review models.Review
+	if err := db.First(&review, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Review not found"))
+		return
+	}
+
+	updates := map[string]interface{}{
+		"moderated_by_id": adminID.(uint),
+		"moderated_at":    clock.Now(),
+	}
+	switch input.Action {
+	case "hide":
+		if review.Status != models.ContentPublished {
+			apierror.Abort(c, apierror.Conflict("Only published reviews can be hidden"))
+			return
+		}
+		updates["status"], updates["featured"], updates["featured_at"] = models.ContentHidden, false, nil
+	case "unhide":
+		if review.Status != models.ContentHidden {
+			apierror.Abort(c, apierror.Conflict("Review is not hidden"))
+			return
+		}
+		updates["status"] = models.ContentPublished
+	case "flag":
+		updates["flagged"], updates["flag_reason"] = true, input.Reason
+		updates["featured"], updates["featured_at"] = false, nil
+	case "unflag":
+		updates["flagged"], updates["flag_reason"] = false, ""
+	}
+	if err := db.Model(&review).Updates(updates).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update review"))
+		return
+	}
+	h.invalidateFeatured()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Review updated successfully"),
+		"review":  review,
+	})
+}
+
+// loadCourseReview finds a review of the course in the URL, for its instructor or an admin
+func (h *ReviewHandler) loadCourseReview(c *gin.Context) (models.Review, bool) {
+	db := h.DB.WithContext(c.Request.Context())
+	var review models.Review
+	if err := db.Preload("Course").Where("course_id = ?", c.Param("id")).
+		First(&review, c.Param("reviewId")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Review not found"))
+		return review, false
+	}
+	if !canManageCourse(c, db, review.Course) {
+		apierror.Abort(c, apierror.Forbidden("Only the course instructor can reply to its reviews"))
+		return review, false
+	}
+	return review, true
+}
+
+// ReplyToReview sets the instructor's public reply to a review and lets the reviewer know
+func (h *ReviewHandler) ReplyToReview(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Reply string `json:"reply" binding:"required,max=2000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	input.Reply = strings.TrimSpace(input.Reply)
+	if input.Reply == "" {
+		apierror.Abort(c, apierror.BadRequest("Reply cannot be empty"))
+		return
+	}
+
+	review, ok := h.loadCourseReview(c)
+	if !ok {
+		return
+	}
+	if review.Status != models.ContentPublished {
+		apierror.Abort(c, apierror.Conflict("Only published reviews can be replied to"))
+		return
+	}
+
+	now := clock.Now()
+	if err := db.Model(&review).UpdateColumns(map[string]interface{}{
+		"reply":      input.Reply,
+		"replied_at": now,
+	}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save reply"))
+		return
+	}
+	review.Reply, review.RepliedAt = input.Reply, &now
+	h.invalidateFeatured()
+
+	notifyUser(db, review.UserID, models.NotificationReviewReply, "The instructor replied to your review of "+review.Course.Title, gin.H{
+		"review_id": review.ID,
+		"course_id": review.CourseID,
+		"reply":     review.Reply,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Reply saved"),
+		"review":  review,
+	})
+}
+
+// DeleteReviewReply removes the instructor's reply from a review
+func (h *ReviewHandler) DeleteReviewReply(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	review, ok := h.loadCourseReview(c)
+	if !ok {
+		return
+	}
+	if err := db.Model(&review).UpdateColumns(map[string]interface{}{
+		"reply":      "",
+		"replied_at": nil,
+	}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete reply"))
+		return
+	}
+	h.invalidateFeatured()
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Reply deleted")})
+}
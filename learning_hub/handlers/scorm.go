@@ -0,0 +1,729 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/fileupload"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/mediaurl"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxScormPackageSize  = 300 << 20 // the uploaded zip
+	maxScormUnpackedSize = 1 << 30
+	maxScormFiles        = 5000
+	maxScormDataSize     = 256 << 10 // a runtime commit
+	maxXAPIStatements    = 50        // per request
+)
+
+// scormManifest is the part of a package's imsmanifest.xml needed to launch it
+type scormManifest struct {
+	SchemaVersion string `xml:"metadata>schemaversion"`
+	Organizations struct {
+		Default string              `xml:"default,attr"`
+		List    []scormOrganization `xml:"organization"`
+	} `xml:"organizations"`
+	Resources []struct {
+		Identifier string `xml:"identifier,attr"`
+		Href       string `xml:"href,attr"`
+	} `xml:"resources>resource"`
+}
+
+type scormOrganization struct {
+	Identifier string      `xml:"identifier,attr"`
+	Title      string      `xml:"title"`
+	Items      []scormItem `xml:"item"`
+}
+
+type scormItem struct {
+	IdentifierRef string      `xml:"identifierref,attr"`
+	Parameters    string      `xml:"parameters,attr"`
+	Items         []scormItem `xml:"item"`
+}
+
+// launch returns the version, title and page to open of the package: the first item of its default
+// organization that points at a resource
+func (m scormManifest) launch() (version, title, launchPath string, err error) {
+	version = models.ScormVersion12
+	if strings.Contains(m.SchemaVersion, "2004") || strings.Contains(m.SchemaVersion, "1.3") {
+		version = models.ScormVersion2004
+	}
+	hrefs := map[string]string{}
+	for _, resource := range m.Resources {
+		if resource.Href != "" {
+			hrefs[resource.Identifier] = resource.Href
+		}
+	}
+
+	var organization *scormOrganization
+	for i := range m.Organizations.List {
+		if organization == nil || m.Organizations.List[i].Identifier == m.Organizations.Default {
+			organization = &m.Organizations.List[i]
+		}
+	}
+	var find func(items []scormItem) string
+	find = func(items []scormItem) string {
+		for _, item := range items {
+			if href, ok := hrefs[item.IdentifierRef]; ok {
+				if item.Parameters == "" {
+					return href
+				}
+				separator := "?"
+				if strings.Contains(href, "?") {
+					separator = "&"
+				}
+				return href + separator + strings.TrimLeft(item.Parameters, "?&")
+			}
+			if href := find(item.Items); href != "" {
+				return href
+			}
+		}
+		return ""
+	}
+	if organization != nil {
+		title = strings.TrimSpace(organization.Title)
+		launchPath = find(organization.Items)
+	}
+	if launchPath == "" {
+		for _, resource := range m.Resources {
+			if resource.Href != "" {
+				launchPath = resource.Href
+				break
+			}
+		}
+	}
+	if launchPath == "" {
+		return version, title, "", errors.New("imsmanifest.xml names no page to launch")
+	}
+	return version, title, launchPath, nil
+}
+
+// scormFilePath cleans a path inside a package, refusing those that would leave it
+func scormFilePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\x00") {
+		return "", false
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+	return cleaned, true
+}
+
+func scormDir(packageID uint) string {
+	return fmt.Sprintf("/uploads/scorm/%d/", packageID)
+}
+
+// scormLaunchURL returns the link opening the package for userID. The signature is part of the path, so
+// the pages and assets the package loads relatively are covered by it too.
+func scormLaunchURL(pkg models.ScormPackage, userID uint) string {
+	return scormDir(pkg.ID) + mediaurl.PathToken(scormDir(pkg.ID), userID) + "/" + pkg.LaunchPath
+}
+
+func scormContentType(name string) string {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// unpackScormPackage stores the archive's files under prefix and returns their paths and total size. What is
+// wrong with the package itself is returned as a 400 to answer with.
+func unpackScormPackage(ctx context.Context, archive *zip.Reader, prefix string) ([]string, int64, error) {
+	var paths []string
+	var total int64
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		name, ok := scormFilePath(file.Name)
+		if !ok {
+			return paths, total, apierror.BadRequest("The package contains an invalid path: " + file.Name)
+		}
+		if len(paths) >= maxScormFiles {
+			return paths, total, apierror.BadRequest(fmt.Sprintf("A package can have at most %d files", maxScormFiles))
+		}
+		if total+int64(file.UncompressedSize64) > maxScormUnpackedSize {
+			return paths, total, apierror.BadRequest(fmt.Sprintf("The unpacked package is larger than %d MB", maxScormUnpackedSize>>20))
+		}
+		r, err := file.Open()
+		if err != nil {
+			return paths, total, apierror.BadRequest("The package has a file that can't be read: " + file.Name).Wrap(err)
+		}
+		// The declared size is checked while reading too, archives can lie about it
+		size := int64(file.UncompressedSize64)
+		err = fileupload.Storage().Save(ctx, prefix+"/"+name, io.LimitReader(r, size), size, scormContentType(name))
+		r.Close()
+		if err != nil {
+			return paths, total, err
+		}
+		paths = append(paths, name)
+		total += size
+	}
+	return paths, total, nil
+}
+
+// deleteScormFiles deletes the files of a removed package unless another lesson (a copy) still plays them
+func deleteScormFiles(db *gorm.DB, prefix string, paths []string) {
+	var users int64
+	if err := db.Model(&models.ScormPackage{}).Where("storage_prefix = ?", prefix).Count(&users).Error; err != nil || users > 0 {
+		return
+	}
+	for _, name := range paths {
+		if err := fileupload.Storage().Delete(context.Background(), prefix+"/"+name); err != nil &&
+			!errors.Is(err, fileupload.ErrObjectNotFound) {
+			slog.Error("Failed to delete SCORM file", "path", prefix+"/"+name, "error", err)
+		}
+	}
+}
+
+func scormPackagePaths(pkg models.ScormPackage) []string {
+	var paths []string
+	if len(pkg.Paths) > 0 {
+		json.Unmarshal(pkg.Paths, &paths)
+	}
+	return paths
+}
+
+// UploadScormPackage makes the lesson play a SCORM 1.2 or 2004 package, uploaded as the file field of a
+// form. The zip is unpacked to storage and replaces the lesson's previous package; students' attempts stay.
+func (h *LessonHandler) UploadScormPackage(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Upload the package as the file field"))
+		return
+	}
+	if strings.ToLower(path.Ext(header.Filename)) != ".zip" {
+		apierror.Abort(c, apierror.BadRequest("A SCORM package is a .zip file"))
+		return
+	}
+	if header.Size > MaxScormPackageSize {
+		apierror.Abort(c, apierror.New(http.StatusRequestEntityTooLarge, fmt.Sprintf("Package is larger than %d MB", MaxScormPackageSize>>20)))
+		return
+	}
+	if err := fileupload.ScanFile(c.Request.Context(), header); err != nil {
+		if !rejectScannedFile(c, db, header, quarantineSourceUpload, err) {
+			apierror.Abort(c, apierror.BadRequest(err.Error()))
+		}
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to read the package"))
+		return
+	}
+	defer file.Close()
+	archive, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("Package is not a valid zip file"))
+		return
+	}
+	var manifest scormManifest
+	found := false
+	for _, entry := range archive.File {
+		if entry.Name != "imsmanifest.xml" {
+			continue
+		}
+		r, err := entry.Open()
+		if err == nil {
+			err = xml.NewDecoder(io.LimitReader(r, 5<<20)).Decode(&manifest)
+			r.Close()
+		}
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("imsmanifest.xml is not valid: "+err.Error()))
+			return
+		}
+		found = true
+		break
+	}
+	if !found {
+		apierror.Abort(c, apierror.BadRequest("The package has no imsmanifest.xml at its root"))
+		return
+	}
+	version, title, launchPath, err := manifest.launch()
+	if err == nil {
+		var valid bool
+		if launchPath, valid = scormFilePath(launchPath); !valid {
+			err = errors.New("imsmanifest.xml launches a page outside the package")
+		}
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	token, err := idgen.Token("", 16)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to store the package"))
+		return
+	}
+	prefix := "scorm/" + token
+	paths, size, err := unpackScormPackage(c.Request.Context(), archive, prefix)
+	if err != nil {
+		go deleteScormFiles(detached(db), prefix, paths)
+		var invalid *apierror.Error
+		if errors.As(err, &invalid) {
+			apierror.Abort(c, invalid)
+		} else {
+			apierror.Abort(c, apierror.Internal("Failed to unpack the package").Wrap(err))
+		}
+		return
+	}
+	pathsJSON, _ := json.Marshal(paths)
+
+	pkg := models.ScormPackage{
+		LessonID:      lesson.ID,
+		Version:       version,
+		Title:         truncateRunes(title, 200),
+		LaunchPath:    truncate(launchPath, 500),
+		StoragePrefix: prefix,
+		Paths:         models.JSON(pathsJSON),
+		Files:         len(paths),
+		Size:          size,
+		UploadedByID:  c.MustGet("userID").(uint),
+	}
+	var previous models.ScormPackage
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("lesson_id = ?", lesson.ID).Limit(1).Find(&previous).Error; err != nil {
+			return err
+		}
+		if previous.ID != 0 {
+			if err := tx.Delete(&previous).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&pkg).Error; err != nil {
+			return err
+		}
+		return tx.Model(&lesson).Update("type", models.LessonTypeScorm).Error
+	})
+	if err != nil {
+		go deleteScormFiles(detached(db), prefix, paths)
+		apierror.Abort(c, apierror.Internal("Failed to save the package"))
+		return
+	}
+	if previous.ID != 0 {
+		go deleteScormFiles(detached(db), previous.StoragePrefix, scormPackagePaths(previous))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(i18n.Locale(c), "SCORM package uploaded"),
+		"package": pkg,
+	})
+}
+
+// DeleteScormPackage removes the lesson's SCORM package; the lesson becomes a standard one
+func (h *LessonHandler) DeleteScormPackage(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
+		return
+	}
+	var pkg models.ScormPackage
+	if err := db.Where("lesson_id = ?", lesson.ID).First(&pkg).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("This lesson has no SCORM package"))
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&pkg).Error; err != nil {
+			return err
+		}
+		return tx.Model(&lesson).Update("type", models.LessonTypeStandard).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to remove the package"))
+		return
+	}
+	go deleteScormFiles(detached(db), pkg.StoragePrefix, scormPackagePaths(pkg))
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "SCORM package removed")})
+}
+
+// loadScormLesson loads the :id SCORM lesson and its package for a student enrolled in its course or its staff
+func (h *LessonHandler) loadScormLesson(c *gin.Context) (models.Lesson, models.ScormPackage, bool) {
+	db := h.db.WithContext(c.Request.Context())
+	var lesson models.Lesson
+	var pkg models.ScormPackage
+	if err := db.Preload("Module.Course").First(&lesson, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Lesson not found"))
+		return lesson, pkg, false
+	}
+	if lesson.Type != models.LessonTypeScorm {
+		apierror.Abort(c, apierror.BadRequest("This lesson has no SCORM package"))
+		return lesson, pkg, false
+	}
+	if !h.canAccessCourseContent(c, lesson.Module.Course) {
+		apierror.Abort(c, apierror.Forbidden("You must be enrolled in this course to access its lessons"))
+		return lesson, pkg, false
+	}
+	if err := db.Where("lesson_id = ?", lesson.ID).First(&pkg).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("This lesson has no SCORM package"))
+		return lesson, pkg, false
+	}
+	return lesson, pkg, true
+}
+
+// loadScormAttempt returns the caller's attempt of the lesson, new when they haven't started it
+func loadScormAttempt(db *gorm.DB, userID, lessonID uint) (models.ScormAttempt, error) {
+	attempt := models.ScormAttempt{
+		LessonID:         lessonID,
+		UserID:           userID,
+		CompletionStatus: "unknown",
+		SuccessStatus:    "unknown",
+	}
+	err := db.Where("user_id = ? AND lesson_id = ?", userID, lessonID).Limit(1).Find(&attempt).Error
+	return attempt, err
+}
+
+// GetScormLaunch returns what a SCORM player needs to run the lesson's package for the caller: the launch
+// link (signed for them), the SCORM version whose runtime API to provide, and the data model committed so
+// far to resume from
+func (h *LessonHandler) GetScormLaunch(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, pkg, ok := h.loadScormLesson(c)
+	if !ok {
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+	attempt, err := loadScormAttempt(db, userID, lesson.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch your attempt"))
+		return
+	}
+	if len(attempt.Data) == 0 {
+		attempt.Data = models.JSON("{}")
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"lesson_id":  lesson.ID,
+		"version":    pkg.Version,
+		"title":      pkg.Title,
+		"launch_url": scormLaunchURL(pkg, userID),
+		"expires_in": int(mediaurl.Expiry().Seconds()),
+		"attempt":    attempt,
+	})
+}
+
+// scormPercent converts a SCORM or xAPI score to a percentage: from the scaled score (-1 to 1) when there
+// is one, otherwise from the raw score between min (default 0) and max (default 100)
+func scormPercent(scaled, raw, min, max *float64) *float64 {
+	var percent float64
+	switch {
+	case scaled != nil:
+		percent = *scaled * 100
+	case raw != nil:
+		low, high := 0.0, 100.0
+		if min != nil {
+			low = *min
+		}
+		if max != nil {
+			high = *max
+		}
+		if high <= low {
+			return nil
+		}
+		percent = (*raw - low) / (high - low) * 100
+	default:
+		return nil
+	}
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	return &percent
+}
+
+// applyScormData reads completion, success and score from a committed SCORM 1.2 or 2004 data model
+func applyScormData(attempt *models.ScormAttempt, data map[string]string) {
+	number := func(key string) *float64 {
+		value, err := strconv.ParseFloat(strings.TrimSpace(data[key]), 64)
+		if err != nil {
+			return nil
+		}
+		return &value
+	}
+
+	// SCORM 1.2 has a single status; failing still finishes the lesson
+	switch data["cmi.core.lesson_status"] {
+	case "passed":
+		attempt.CompletionStatus, attempt.SuccessStatus = "completed", "passed"
+	case "failed":
+		attempt.CompletionStatus, attempt.SuccessStatus = "completed", "failed"
+	case "completed":
+		attempt.CompletionStatus = "completed"
+	case "incomplete", "browsed":
+		attempt.CompletionStatus = "incomplete"
+	}
+	if score := scormPercent(nil, number("cmi.core.score.raw"), number("cmi.core.score.min"), number("cmi.core.score.max")); score != nil {
+		attempt.Score = score
+	}
+
+	switch status := data["cmi.completion_status"]; status {
+	case "completed", "incomplete", "not attempted":
+		attempt.CompletionStatus = status
+	}
+	switch status := data["cmi.success_status"]; status {
+	case "passed", "failed":
+		attempt.SuccessStatus = status
+	}
+	if score := scormPercent(number("cmi.score.scaled"), number("cmi.score.raw"), number("cmi.score.min"), number("cmi.score.max")); score != nil {
+		attempt.Score = score
+	}
+}
+
+// saveScormAttempt stores the attempt, records its score in the student's lesson progress and completes
+// the lesson once the package reports it completed and not failed
+func (h *LessonHandler) saveScormAttempt(c *gin.Context, lesson models.Lesson, attempt *models.ScormAttempt) {
+	db := h.db.WithContext(c.Request.Context())
+	if err := db.Save(attempt).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save your attempt"))
+		return
+	}
+	response := gin.H{"attempt": attempt}
+
+	// Progress is only kept for enrolled students, staff trying the package out have none
+	courseID := lesson.Module.CourseID
+	var enrolled int64
+	db.Model(&models.Enrollment{}).Where("user_id = ? AND course_id = ? AND is_active = ?", attempt.UserID, courseID, true).
+		Count(&enrolled)
+	if enrolled == 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if attempt.Score != nil {
+		var progress models.LessonProgress
+		if err := db.Where("user_id = ? AND lesson_id = ?", attempt.UserID, lesson.ID).Limit(1).Find(&progress).Error; err == nil {
+			progress.UserID, progress.LessonID, progress.CourseID = attempt.UserID, lesson.ID, courseID
+			progress.Score = attempt.Score
+			db.Omit("User", "Lesson", "Course").Save(&progress)
+		}
+	}
+	if attempt.CompletionStatus == "completed" && attempt.SuccessStatus != "failed" {
+		if reason, err := lessonCompletionBlocked(db, attempt.UserID, lesson); err == nil && reason == "" {
+			certificate, err := completeLesson(db, attempt.UserID, lesson.ID, courseID)
+			if err != nil {
+				apierror.Abort(c, apierror.Internal("Failed to complete lesson"))
+				return
+			}
+			response["lesson_completed"] = true
+			if certificate != nil {
+				go sendCertificateEmail(detached(db), *certificate)
+				response["certificate"] = certificate
+			}
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CommitScormData saves the data model the SCORM runtime commits ({"data": {"cmi.…": "value"}}, merged
+// into what was committed before) and takes the lesson's completion and score from it
+func (h *LessonHandler) CommitScormData(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, _, ok := h.loadScormLesson(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Data map[string]string `json:"data" binding:"required"`
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxScormDataSize)
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	attempt, err := loadScormAttempt(db, c.MustGet("userID").(uint), lesson.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch your attempt"))
+		return
+	}
+
+	data := map[string]string{}
+	if len(attempt.Data) > 0 {
+		json.Unmarshal(attempt.Data, &data)
+	}
+	for key, value := range input.Data {
+		if !strings.HasPrefix(key, "cmi.") && !strings.HasPrefix(key, "adl.") {
+			apierror.Abort(c, apierror.BadRequest("Unknown data model element: "+key))
+			return
+		}
+		data[key] = value
+	}
+	encoded, _ := json.Marshal(data)
+	if len(encoded) > maxScormDataSize {
+		apierror.Abort(c, apierror.New(http.StatusRequestEntityTooLarge, "The attempt's data is too large"))
+		return
+	}
+	attempt.Data = models.JSON(encoded)
+	applyScormData(&attempt, data)
+	h.saveScormAttempt(c, lesson, &attempt)
+}
+
+// xapiStatement is the part of an xAPI statement that carries results
+type xapiStatement struct {
+	Verb struct {
+		ID string `json:"id"`
+	} `json:"verb"`
+	Result *struct {
+		Completion *bool `json:"completion"`
+		Success    *bool `json:"success"`
+		Score      *struct {
+			Scaled *float64 `json:"scaled"`
+			Raw    *float64 `json:"raw"`
+			Min    *float64 `json:"min"`
+			Max    *float64 `json:"max"`
+		} `json:"score"`
+	} `json:"result"`
+}
+
+// RecordXAPIStatements stores xAPI statements the lesson's content sends about the caller (one statement
+// or a list) and takes the lesson's completion and score from their verbs and results. The caller is the
+// actor, whatever the statements say.
+func (h *LessonHandler) RecordXAPIStatements(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, _, ok := h.loadScormLesson(c)
+	if !ok {
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxScormDataSize)
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		apierror.Abort(c, apierror.New(http.StatusRequestEntityTooLarge, "Statements are too large"))
+		return
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		var single json.RawMessage
+		if err := json.Unmarshal(body, &single); err != nil {
+			apierror.Abort(c, apierror.BadRequest("Send a statement or a list of statements"))
+			return
+		}
+		raw = []json.RawMessage{single}
+	}
+	if len(raw) == 0 || len(raw) > maxXAPIStatements {
+		apierror.Abort(c, apierror.BadRequest(fmt.Sprintf("Send between 1 and %d statements", maxXAPIStatements)))
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	attempt, err := loadScormAttempt(db, userID, lesson.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch your attempt"))
+		return
+	}
+	records := make([]models.XAPIStatement, 0, len(raw))
+	for i, document := range raw {
+		var statement xapiStatement
+		if err := json.Unmarshal(document, &statement); err != nil || statement.Verb.ID == "" {
+			apierror.Abort(c, apierror.BadRequest(fmt.Sprintf("Statement %d needs a verb", i+1)))
+			return
+		}
+		records = append(records, models.XAPIStatement{
+			LessonID:  lesson.ID,
+			UserID:    userID,
+			Verb:      truncate(statement.Verb.ID, 300),
+			Statement: models.JSON(document),
+		})
+
+		switch verb := statement.Verb.ID; {
+		case strings.HasSuffix(verb, "/passed"):
+			attempt.CompletionStatus, attempt.SuccessStatus = "completed", "passed"
+		case strings.HasSuffix(verb, "/failed"):
+			attempt.CompletionStatus, attempt.SuccessStatus = "completed", "failed"
+		case strings.HasSuffix(verb, "/completed"):
+			attempt.CompletionStatus = "completed"
+		}
+		if result := statement.Result; result != nil {
+			if result.Completion != nil && *result.Completion {
+				attempt.CompletionStatus = "completed"
+			}
+			if result.Success != nil {
+				attempt.SuccessStatus = "failed"
+				if *result.Success {
+					attempt.SuccessStatus = "passed"
+				}
+			}
+			if score := result.Score; score != nil {
+				if percent := scormPercent(score.Scaled, score.Raw, score.Min, score.Max); percent != nil {
+					attempt.Score = percent
+				}
+			}
+		}
+	}
+	if err := db.Create(&records).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to store the statements"))
+		return
+	}
+	attempt.Statements += len(records)
+	h.saveScormAttempt(c, lesson, &attempt)
+}
+
+// ServeScormFile serves a file of an unpacked SCORM package to the user its launch link was signed for,
+// while they are enrolled in the lesson's course or on its staff
+func (h *UploadHandler) ServeScormFile(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var pkg models.ScormPackage
+	if err := db.First(&pkg, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Package not found"))
+		return
+	}
+	name, ok := scormFilePath(strings.TrimPrefix(c.Param("path"), "/"))
+	if !ok {
+		apierror.Abort(c, apierror.BadRequest("Invalid path"))
+		return
+	}
+
+	userID, ok := mediaurl.VerifyPathToken(scormDir(pkg.ID), c.Param("token"))
+	if !ok {
+		apierror.Abort(c, apierror.Forbidden("Invalid or expired media link"))
+		return
+	}
+	var user models.User
+	if err := db.Select("id, role").First(&user, userID).Error; err != nil {
+		apierror.Abort(c, apierror.Forbidden("Invalid or expired media link"))
+		return
+	}
+	c.Set("userID", user.ID)
+	c.Set("userRole", user.Role)
+
+	var lesson models.Lesson
+	if err := db.Preload("Module.Course").First(&lesson, pkg.LessonID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Package not found"))
+		return
+	}
+	reader, info, err := fileupload.Storage().Open(c.Request.Context(), pkg.StoragePrefix+"/"+name)
+	if errors.Is(err, fileupload.ErrObjectNotFound) {
+		apierror.Abort(c, apierror.NotFound("File not found"))
+		return
+	} else if err != nil {
+		apierror.Abort(c, apierror.Internal("Error accessing file"))
+		return
+	}
+	defer reader.Close()
+	if !h.authorizeLessonFile(c, lesson, scormDir(pkg.ID)+name, info.Size, false) {
+		return
+	}
+	c.DataFromReader(http.StatusOK, info.Size, scormContentType(name), reader, nil)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/jwt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// How stale a session's last_seen_at may get before a request refreshes it, to not write on every request
+const sessionSeenInterval = time.Minute
+
+// SessionHandler manages the logins (sessions) of users and checks tokens against them
+type SessionHandler struct {
+	DB *gorm.DB
+}
+
+func NewSessionHandler(db *gorm.DB) *SessionHandler {
+	return &SessionHandler{DB: db}
+}
+
+// startSession records a login from the request's device and issues its token
+func startSession(db *gorm.DB, c *gin.Context, user models.User) (string, error) {
+	id, err := idgen.Token("ses_", 16)
+	if err != nil {
+		return "", err
+	}
+	now := clock.Now()
+	session := models.Session{
+		ID:         id,
+		UserID:     user.ID,
+		IP:         c.ClientIP(),
+		UserAgent:  truncate(c.Request.UserAgent(), 300),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		LastSeenIP: c.ClientIP(),
+		ExpiresAt:  now.Add(jwt.TokenTTL),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return "", err
+	}
+	return jwt.GenerateToken(user.ID, user.Email, user.Role, id)
+}
+
+// revokeSessions ends the user's active sessions but the one kept ("" for all) and returns how many
+func revokeSessions(db *gorm.DB, userID uint, keep string) (int64, error) {
+	query := db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, clock.Now())
+	if keep != "" {
+		query = query.Where("id <> ?", keep)
+	}
+	result := query.Update("revoked_at", clock.Now())
+	return result.RowsAffected, result.Error
+}
+
+// Active tells the auth middleware whether the session of a valid token is still on. Tokens issued
+// before sessions existed carry no session ID and are refused, so their users log in again.
+func (h *SessionHandler) Active(c *gin.Context, claims *jwt.Claims) bool {
+	db := h.DB.WithContext(c.Request.Context())
+	if claims.ID == "" {
+		return false
+	}
+	var session models.Session
+	if err := db.Select("id, user_id, last_seen_at, revoked_at, expires_at").
+		First(&session, "id = ?", claims.ID).Error; err != nil {
+		return false
+	}
+	now := clock.Now()
+	if session.UserID != claims.UserID || session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+		return false
+	}
+	if now.Sub(session.LastSeenAt) >= sessionSeenInterval {
+		db.Model(&session).Updates(map[string]interface{}{"last_seen_at": now, "last_seen_ip": c.ClientIP()})
+	}
+	return true
+}
+
+// GetSessions lists the caller's active sessions, newest first, leaving out those of admins acting as them.
+// The one making the request has current: true.
+func (h *SessionHandler) GetSessions(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var sessions []models.Session
+	if err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", c.MustGet("userID"), clock.Now()).
+		Where("impersonator_id IS NULL").Order("created_at DESC").Find(&sessions).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch sessions"))
+		return
+	}
+
+	current := c.GetString("sessionID")
+	result := make([]gin.H, len(sessions))
+	for i, session := range sessions {
+		result[i] = gin.H{
+			"id":           session.ID,
+			"ip":           session.IP,
+			"user_agent":   session.UserAgent,
+			"created_at":   session.CreatedAt,
+			"last_seen_at": session.LastSeenAt,
+			"last_seen_ip": session.LastSeenIP,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.ID == current,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": result})
+}
+
+// RevokeSession logs one of the caller's devices out
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	result := db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), c.MustGet("userID")).
+		Update("revoked_at", clock.Now())
+	if result.Error != nil {
+		apierror.Abort(c, apierror.Internal("Failed to revoke session"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Abort(c, apierror.NotFound("Session not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Session revoked")})
+}
+
+// Logout ends the session of the token making the request
+func (h *SessionHandler) Logout(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	if err := db.Model(&models.Session{}).Where("id = ?", c.GetString("sessionID")).
+		Update("revoked_at", clock.Now()).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to log out"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Logged out")})
+}
+
+// LogoutEverywhere ends all the caller's sessions, or all but this one with ?keep_current=true
+func (h *SessionHandler) LogoutEverywhere(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	keep := ""
+	if c.Query("keep_current") == "true" {
+		keep = c.GetString("sessionID")
+	}
+	revoked, err := revokeSessions(db, c.MustGet("userID").(uint), keep)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to revoke sessions"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Logged out everywhere"), "revoked": revoked})
+}
+
+// RevokeUserSessions logs a user out of all their devices, e.g. when their account was compromised
+func (h *AdminHandler) RevokeUserSessions(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	revoked, err := revokeSessions(db, user.ID, "")
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to revoke sessions"))
+		return
+	}
+	recordAudit(db, c, models.AuditSessionsRevoke, "user", user.ID, nil, gin.H{"revoked": revoked})
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "User logged out everywhere"), "revoked": revoked})
+}
+
+// CleanupSessions deletes sessions that expired or were revoked over a week ago
+func (h *SessionHandler) CleanupSessions(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	cutoff := clock.Now().AddDate(0, 0, -7)
+	result := db.Where("expires_at < ? OR revoked_at < ?", cutoff, cutoff).Delete(&models.Session{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		slog.Info("Deleted old sessions", "count", result.RowsAffected)
+	}
+	return nil
+}
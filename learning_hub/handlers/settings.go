@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/settings"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SettingsHandler struct {
+	DB *gorm.DB
+}
+
+func NewSettingsHandler(db *gorm.DB) *SettingsHandler {
+	return &SettingsHandler{DB: db}
+}
+
+// Load reads the stored settings; it is the settings.Loader of the platform settings cache
+func (h *SettingsHandler) Load() (map[string]string, error) {
+	var stored []models.Setting
+	if err := h.DB.Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(stored))
+	for _, setting := range stored {
+		values[setting.Key] = setting.Value
+	}
+	return values, nil
+}
+
+// settingsView lists every setting with its current value and whether an admin changed it
+func settingsView(db *gorm.DB) ([]gin.H, error) {
+	var stored []models.Setting
+	if err := db.Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]models.Setting, len(stored))
+	for _, setting := range stored {
+		byKey[setting.Key] = setting
+	}
+
+	definitions := settings.Definitions()
+	results := make([]gin.H, len(definitions))
+	for i, def := range definitions {
+		result := gin.H{
+			"key":         def.Key,
+			"type":        def.Type,
+			"description": def.Description,
+			"default":     def.Default,
+			"value":       def.Default,
+			"overridden":  false,
+		}
+		if setting, ok := byKey[def.Key]; ok {
+			result["value"], result["overridden"] = setting.Value, true
+			result["updated_by_id"], result["updated_at"] = setting.UpdatedByID, setting.UpdatedAt
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// GetSettings lists the platform settings with their defaults and current values
+func (h *SettingsHandler) GetSettings(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	results, err := settingsView(db)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch settings"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"settings": results})
+}
+
+// UpdateSettings changes settings given as {"key": "value"}; a null value restores the default.
+// Nothing is saved when any value is invalid.
+func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input map[string]*string
+	if err := c.ShouldBindJSON(&input); err != nil || len(input) == 0 {
+		apierror.Abort(c, apierror.BadRequest("Send the settings to change as {\"key\": \"value\"}"))
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	var upserts []models.Setting
+	var resets []string
+	fieldErrors := map[string]string{}
+	for key, value := range input {
+		if _, ok := settings.Lookup(key); !ok {
+			fieldErrors[key] = "unknown setting"
+			continue
+		}
+		if value == nil {
+			resets = append(resets, key)
+			continue
+		}
+		normalized, err := settings.Validate(key, *value)
+		if err != nil {
+			fieldErrors[key] = err.Error()
+			continue
+		}
+		upserts = append(upserts, models.Setting{Key: key, Value: normalized, UpdatedByID: &userID})
+	}
+	if len(fieldErrors) > 0 {
+		apierror.Abort(c, apierror.BadRequest("Invalid settings").WithFields(fieldErrors))
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if len(upserts) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by_id", "updated_at"}),
+			}).Create(&upserts).Error; err != nil {
+				return err
+			}
+		}
+		if len(resets) > 0 {
+			return tx.Where("key IN ?", resets).Delete(&models.Setting{}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save settings"))
+		return
+	}
+	settings.Invalidate()
+
+	results, err := settingsView(db)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch settings"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Settings updated"),
+		"settings": results,
+	})
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/slo"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Burn rate alerts: a fast burn spends 2% of the 30-day error budget in an hour, a slow burn 5% in six hours.
+// Both windows must burn, so alerts stop soon after the flow recovers.
+var sloBurnRules = []struct {
+	Severity string
+	Long     time.Duration
+	Short    time.Duration
+	BurnRate float64
+}{
+	{Severity: "fast", Long: time.Hour, Short: 5 * time.Minute, BurnRate: 14.4},
+	{Severity: "slow", Long: 6 * time.Hour, Short: 30 * time.Minute, BurnRate: 6},
+}
+
+// Flows with fewer requests than this in the long window don't alert
+const sloMinRequests = 20
+
+type SLOHandler struct {
+	DB *gorm.DB
+}
+
+func NewSLOHandler(db *gorm.DB) *SLOHandler {
+	return &SLOHandler{DB: db}
+}
+
+// FlushSLIs persists the requests recorded in memory, adding to the stored minutes; meant to run as a background job
+func (h *SLOHandler) FlushSLIs(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	samples := slo.Drain()
+	if len(samples) == 0 {
+		return nil
+	}
+	rows := make([]models.SLISample, len(samples))
+	for i, s := range samples {
+		rows[i] = models.SLISample{Flow: s.Flow, Minute: s.Minute, Total: s.Total, Errors: s.Errors,
+			Slow: s.Slow, LatencyMs: s.LatencyMs, MaxMs: s.MaxMs}
+	}
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "flow"}, {Name: "minute"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "total"}, Value: gorm.Expr("sli_samples.total + excluded.total")},
+			{Column: clause.Column{Name: "errors"}, Value: gorm.Expr("sli_samples.errors + excluded.errors")},
+			{Column: clause.Column{Name: "slow"}, Value: gorm.Expr("sli_samples.slow + excluded.slow")},
+			{Column: clause.Column{Name: "latency_ms"}, Value: gorm.Expr("sli_samples.latency_ms + excluded.latency_ms")},
+			{Column: clause.Column{Name: "max_ms"}, Value: gorm.Expr("GREATEST(sli_samples.max_ms, excluded.max_ms)")},
+		},
+	}).Create(&rows).Error
+	if err != nil {
+		slo.Restore(samples)
+	}
+	return err
+}
+
+// sliTotals are a flow's requests over a period
+type sliTotals struct {
+	Total     int64
+	Errors    int64
+	Slow      int64
+	LatencyMs int64
+	MaxMs     int64
+}
+
+func (t sliTotals) errorRate() float64 {
+	if t.Total == 0 {
+		return 0
+	}
+	return float64(t.Errors) / float64(t.Total)
+}
+
+// burnRate is how many times faster than allowed the flow spends its error budget
+func (t sliTotals) burnRate(objective slo.Objective) float64 {
+	return t.errorRate() / objective.ErrorBudget()
+}
+
+func (h *SLOHandler) sliTotals(db *gorm.DB, flow string, since time.Time) (sliTotals, error) {
+	var totals sliTotals
+	err := db.Model(&models.SLISample{}).Where("flow = ? AND minute >= ?", flow, since).
+		Select("COALESCE(SUM(total), 0) AS total, COALESCE(SUM(errors), 0) AS errors, COALESCE(SUM(slow), 0) AS slow, " +
+			"COALESCE(SUM(latency_ms), 0) AS latency_ms, COALESCE(MAX(max_ms), 0) AS max_ms").
+		Scan(&totals).Error
+	return totals, err
+}
+
+// CheckErrorBudgets notifies admins of flows burning their error budget too fast, at most once per
+// rule's long window per flow; meant to run as a background job
+func (h *SLOHandler) CheckErrorBudgets(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	now := clock.Now()
+	for _, objective := range slo.Objectives {
+		for _, rule := range sloBurnRules {
+			long, err := h.sliTotals(db, objective.Flow, now.Add(-rule.Long))
+			if err != nil {
+				return err
+			}
+			if long.Total < sloMinRequests || long.burnRate(objective) < rule.BurnRate {
+				continue
+			}
+			short, err := h.sliTotals(db, objective.Flow, now.Add(-rule.Short))
+			if err != nil {
+				return err
+			}
+			if short.burnRate(objective) < rule.BurnRate {
+				continue
+			}
+
+			var recent int64
+			db.Model(&models.SLOAlert{}).Where("flow = ? AND severity = ? AND fired_at >= ?",
+				objective.Flow, rule.Severity, now.Add(-rule.Long)).Count(&recent)
+			if recent > 0 {
+				continue
+			}
+
+			alert := models.SLOAlert{
+				Flow:      objective.Flow,
+				Severity:  rule.Severity,
+				BurnRate:  long.burnRate(objective),
+				ErrorRate: long.errorRate(),
+				FiredAt:   now,
+			}
+			if err := db.Create(&alert).Error; err != nil {
+				return err
+			}
+			slog.Warn("SLO error budget burning", "severity", rule.Severity, "flow", objective.Flow, "burn_rate", alert.BurnRate, "window", rule.Long)
+			notifyAdmins(db, models.NotificationSLOBurn,
+				fmt.Sprintf("The %s flow is burning its error budget (%.0f%% errors over %s)", alert.Flow, alert.ErrorRate*100, rule.Long),
+				gin.H{
+					"flow":       alert.Flow,
+					"severity":   alert.Severity,
+					"burn_rate":  alert.BurnRate,
+					"error_rate": alert.ErrorRate,
+					"alert_id":   alert.ID,
+				})
+		}
+	}
+	return nil
+}
+
+// GetSLODashboard reports each critical flow against its objectives over the last ?days= (default 30,
+// at most 90): availability, latency, error budget left and current burn rates, with recent alerts
+func (h *SLOHandler) GetSLODashboard(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(int(slo.Window.Hours()/24))))
+	if err != nil || days < 1 || days > 90 {
+		apierror.Abort(c, apierror.BadRequest("days must be between 1 and 90"))
+		return
+	}
+	now := clock.Now()
+
+	flows := make([]gin.H, 0, len(slo.Objectives))
+	for _, objective := range slo.Objectives {
+		totals, err := h.sliTotals(db, objective.Flow, now.AddDate(0, 0, -days))
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to read SLIs"))
+			return
+		}
+		burnRates := gin.H{}
+		for _, window := range []time.Duration{time.Hour, 6 * time.Hour, 24 * time.Hour} {
+			recent, err := h.sliTotals(db, objective.Flow, now.Add(-window))
+			if err != nil {
+				apierror.Abort(c, apierror.Internal("Failed to read SLIs"))
+				return
+			}
+			burnRates[fmt.Sprintf("%dh", int(window.Hours()))] = recent.burnRate(objective)
+		}
+
+		availability, latency, averageMs := 1.0, 1.0, 0.0
+		if totals.Total > 0 {
+			availability = 1 - totals.errorRate()
+			latency = 1 - float64(totals.Slow)/float64(totals.Total)
+			averageMs = float64(totals.LatencyMs) / float64(totals.Total)
+		}
+		budgetLeft := 1 - totals.errorRate()/objective.ErrorBudget()
+		status := "ok"
+		switch {
+		case budgetLeft <= 0:
+			status = "exhausted"
+		case budgetLeft < 0.25:
+			status = "at_risk"
+		}
+
+		flows = append(flows, gin.H{
+			"flow":                   objective.Flow,
+			"objective":              objective,
+			"latency_threshold_ms":   objective.LatencyThreshold.Milliseconds(),
+			"requests":               totals.Total,
+			"errors":                 totals.Errors,
+			"availability":           availability,
+			"availability_met":       availability >= objective.Availability,
+			"latency_sli":            latency,
+			"latency_met":            latency >= objective.LatencyTarget,
+			"average_latency_ms":     averageMs,
+			"max_latency_ms":         totals.MaxMs,
+			"error_budget_remaining": budgetLeft,
+			"burn_rates":             burnRates,
+			"status":                 status,
+		})
+	}
+
+	var alerts []models.SLOAlert
+	db.Where("fired_at >= ?", now.AddDate(0, 0, -days)).Order("fired_at DESC").Limit(50).Find(&alerts)
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":   days,
+		"flows":  flows,
+		"alerts": alerts,
+	})
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/fileupload"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Submission files are stored under submissionStoragePrefix and served from submissionFilePrefix
+const (
+	submissionStoragePrefix = "assignments/"
+	submissionFilePrefix    = "/uploads/" + submissionStoragePrefix
+)
+
+// hashUploadedFile returns the hex SHA-256 of an uploaded file's content
+func hashUploadedFile(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	return hashReader(src)
+}
+
+func hashReader(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashText returns the hex SHA-256 of a text submission, exactly as received
+func hashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashStoredSubmissionFile recomputes the SHA-256 of a submission file as it is stored now
+func hashStoredSubmissionFile(ctx context.Context, fileURL string) (string, error) {
+	filename := strings.TrimPrefix(fileURL, submissionFilePrefix)
+	if filename == fileURL || filename != path.Base(filename) {
+		return "", fileupload.ErrObjectNotFound
+	}
+
+	f, _, err := fileupload.Storage().Open(ctx, submissionStoragePrefix+filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return hashReader(f)
+}
+
+// submissionReceipt describes what was submitted and when, identified by content hashes
+func submissionReceipt(submission models.AssignmentSubmission) gin.H {
+	receipt := gin.H{
+		"submission_id": submission.ID,
+		"assignment_id": submission.AssignmentID,
+		"user_id":       submission.UserID,
+		"submitted_at":  submission.SubmittedAt,
+		"algorithm":     "SHA-256",
+		"file_name":     strings.TrimPrefix(submission.FileURL, submissionFilePrefix),
+		"file_hash":     submission.FileHash,
+		"text_hash":     submission.TextHash,
+	}
+	if submission.FileHash == "" && submission.TextHash == "" {
+		receipt["note"] = "This submission predates integrity hashes"
+	}
+	return receipt
+}
+
+// sendSubmissionReceipt emails the student their submission receipt; meant to run in a goroutine
+func sendSubmissionReceipt(db *gorm.DB, submission models.AssignmentSubmission, assignment models.Assignment) {
+	var user models.User
+	var course models.Course
+	if err := db.First(&user, submission.UserID).Error; err != nil {
+		slog.Error("Failed to load user for submission receipt", "submission_id", submission.ID, "error", err)
+		return
+	}
+	if err := db.First(&course, assignment.CourseID).Error; err != nil {
+		slog.Error("Failed to load course for submission receipt", "submission_id", submission.ID, "error", err)
+		return
+	}
+
+	if err := email.SendSubmissionReceiptEmail(user.Email, user.FirstName+" "+user.LastName, course.Title, assignment.Title,
+		submission.ID, submission.SubmittedAt, strings.TrimPrefix(submission.FileURL, submissionFilePrefix),
+		submission.FileHash, submission.TextHash); err != nil {
+		slog.Error("Failed to send submission receipt", "submission_id", submission.ID, "error", err)
+	}
+}
+
+// loadReviewableSubmission loads the :submissionId submission for its student, the course instructor or an admin
+func (h *AssessmentHandler) loadReviewableSubmission(c *gin.Context) (models.AssignmentSubmission, bool) {
+	db := h.db.WithContext(c.Request.Context())
+	var submission models.AssignmentSubmission
+	if err := db.Preload("Assignment.Course").First(&submission, c.Param("submissionId")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Submission not found"))
+		return submission, false
+	}
+
+	userID, _ := c.Get("userID")
+	if submission.UserID != userID.(uint) && !canTeachCourse(c, db, submission.Assignment.Course) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to view this submission"))
+		return submission, false
+	}
+	return submission, true
+}
+
+// GetSubmissionReceipt returns the receipt of an assignment submission
+func (h *AssessmentHandler) GetSubmissionReceipt(c *gin.Context) {
+	submission, ok := h.loadReviewableSubmission(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"receipt": submissionReceipt(submission)})
+}
+
+// VerifySubmission checks content against what was recorded at submission time.
+// Accepts a "file" and/or "submission_text" form field, or a "hash" to compare with the receipt.
+// The stored file is also re-hashed to show whether it changed since submission.
+func (h *AssessmentHandler) VerifySubmission(c *gin.Context) {
+	submission, ok := h.loadReviewableSubmission(c)
+	if !ok {
+		return
+	}
+
+	file, _ := c.FormFile("file")
+	text, hasText := c.GetPostForm("submission_text")
+	hash := strings.ToLower(strings.TrimSpace(c.PostForm("hash")))
+	if file == nil && !hasText && hash == "" {
+		apierror.Abort(c, apierror.BadRequest("Provide a file, submission_text or hash to verify"))
+		return
+	}
+
+	result := gin.H{"receipt": submissionReceipt(submission)}
+
+	if file != nil {
+		fileHash, err := hashUploadedFile(file)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("Failed to read file"))
+			return
+		}
+		result["file_hash"] = fileHash
+		result["file_matches"] = submission.FileHash != "" && fileHash == submission.FileHash
+	}
+	if hasText {
+		textHash := hashText(text)
+		result["text_hash"] = textHash
+		result["text_matches"] = submission.TextHash != "" && textHash == submission.TextHash
+	}
+	if hash != "" {
+		result["hash_matches"] = hash == submission.FileHash || hash == submission.TextHash
+	}
+
+	if submission.FileHash != "" {
+		if stored, err := hashStoredSubmissionFile(c.Request.Context(), submission.FileURL); err == nil {
+			result["stored_file_intact"] = stored == submission.FileHash
+		} else {
+			result["stored_file_intact"] = false
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// FixtureClockStart is where the test clock starts after every reset
+var FixtureClockStart = time.Date(2025, time.January, 6, 9, 0, 0, 0, time.UTC)
+
+// Password of every fixture user
+const fixturePassword = "Password123!"
+
+// TestFixturesHandler serves the /api/test routes, only registered when APP_TEST_MODE=true
+type TestFixturesHandler struct {
+	DB    *gorm.DB
+	Clock *clock.Manual
+}
+
+func NewTestFixturesHandler(db *gorm.DB, clk *clock.Manual) *TestFixturesHandler {
+	return &TestFixturesHandler{DB: db, Clock: clk}
+}
+
+// ResetDatabase empties every table and rewinds the clock and ID generator so each run starts identical
+func (h *TestFixturesHandler) ResetDatabase(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var tables []string
+	for _, model := range models.All() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to resolve tables"))
+			return
+		}
+		tables = append(tables, stmt.Schema.Table)
+	}
+
+	if err := db.Exec("TRUNCATE TABLE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to reset database"))
+		return
+	}
+
+	h.Clock.SetTime(FixtureClockStart)
+	idgen.Set(idgen.NewSequential())
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Test database reset"),
+		"now":     clock.Now(),
+	})
+}
+
+// CreateFixtures seeds an admin, an instructor, a student and a published course with
+// two lessons and a required quiz, and returns their IDs along with ready-to-use tokens
+func (h *TestFixturesHandler) CreateFixtures(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var req struct {
+		CoursePrice   float64 `json:"course_price"`
+		EnrollStudent bool    `json:"enroll_student"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	users := map[string]*models.User{
+		"admin":      {FirstName: "Ada", LastName: "Admin", Email: "admin@test.learnhub", Role: "admin"},
+		"instructor": {FirstName: "Ivan", LastName: "Instructor", Email: "instructor@test.learnhub", Role: "instructor"},
+		"student":    {FirstName: "Sara", LastName: "Student", Email: "student@test.learnhub", Role: "student"},
+	}
+	var course models.Course
+	var lessons []models.Lesson
+	var quiz models.Quiz
+	var enrollment *models.Enrollment
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, role := range []string{"admin", "instructor", "student"} {
+			user := users[role]
+			user.Password = fixturePassword
+			user.EmailVerified = true
+			if err := user.HashPassword(); err != nil {
+				return err
+			}
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+		}
+
+		course = models.Course{
+			Title:        "Fixture Course",
+			Description:  "Course created by the test fixtures",
+			Price:        req.CoursePrice,
+			Category:     "Testing",
+			Level:        "beginner",
+			Published:    true,
+			InstructorID: users["instructor"].ID,
+		}
+		if err := tx.Create(&course).Error; err != nil {
+			return err
+		}
+
+		module := models.Module{Title: "Module 1", CourseID: course.ID, OrderIndex: 1}
+		if err := tx.Create(&module).Error; err != nil {
+			return err
+		}
+
+		lessons = []models.Lesson{
+			{Title: "Lesson 1", Content: "First lesson", Duration: 10, OrderIndex: 1, ModuleID: module.ID},
+			{Title: "Lesson 2", Content: "Second lesson", Duration: 15, OrderIndex: 2, ModuleID: module.ID},
+		}
+		if err := tx.Create(&lessons).Error; err != nil {
+			return err
+		}
+
+		quiz = models.Quiz{
+			Title:        "Final Quiz",
+			CourseID:     course.ID,
+			ModuleID:     &module.ID,
+			MaxAttempts:  3,
+			PassingScore: 70,
+			IsPublished:  true,
+			IsRequired:   true,
+		}
+		if err := tx.Create(&quiz).Error; err != nil {
+			return err
+		}
+		question := models.QuizQuestion{
+			QuizID:        quiz.ID,
+			Question:      "Is this a fixture?",
+			QuestionType:  models.QuestionTypeTrueFalse,
+			CorrectAnswer: "true",
+			Points:        1,
+			OrderIndex:    1,
+		}
+		if err := tx.Create(&question).Error; err != nil {
+			return err
+		}
+
+		if req.EnrollStudent {
+			now := clock.Now()
+			enrollment = &models.Enrollment{
+				UserID:         users["student"].ID,
+				CourseID:       course.ID,
+				IsActive:       true,
+				TotalLessons:   len(lessons),
+				EnrolledAt:     now,
+				LastActivityAt: now,
+			}
+			if err := tx.Create(enrollment).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(fmt.Sprintf("Failed to create fixtures: %v", err)))
+		return
+	}
+
+	userData := gin.H{}
+	for role, user := range users {
+		token, err := startSession(db, c, *user)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to generate token"))
+			return
+		}
+		userData[role] = gin.H{"id": user.ID, "email": user.Email, "token": token}
+	}
+
+	lessonIDs := make([]uint, len(lessons))
+	for i, lesson := range lessons {
+		lessonIDs[i] = lesson.ID
+	}
+
+	response := gin.H{
+		"password":   fixturePassword,
+		"users":      userData,
+		"course_id":  course.ID,
+		"module_id":  quiz.ModuleID,
+		"lesson_ids": lessonIDs,
+		"quiz_id":    quiz.ID,
+	}
+	if enrollment != nil {
+		response["enrollment_id"] = enrollment.ID
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetClock returns the current test time
+func (h *TestFixturesHandler) GetClock(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"now": clock.Now()})
+}
+
+// SetClock moves the test clock to an absolute time ("now", RFC3339) or forward ("advance", e.g. "720h")
+func (h *TestFixturesHandler) SetClock(c *gin.Context) {
+	var req struct {
+		Now     string `json:"now"`
+		Advance string `json:"advance"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	switch {
+	case req.Now != "":
+		t, err := time.Parse(time.RFC3339, req.Now)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("now must be an RFC3339 timestamp"))
+			return
+		}
+		h.Clock.SetTime(t)
+	case req.Advance != "":
+		d, err := time.ParseDuration(req.Advance)
+		if err != nil || d < 0 {
+			apierror.Abort(c, apierror.BadRequest("advance must be a positive duration such as 24h"))
+			return
+		}
+		h.Clock.Advance(d)
+	default:
+		apierror.Abort(c, apierror.BadRequest("Provide now or advance"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"now": clock.Now()})
+}
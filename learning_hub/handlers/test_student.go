@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// withoutTestStudents scopes a query of enrollments, progress or attempts to real students
+func withoutTestStudents(db *gorm.DB) *gorm.DB {
+	return db.Where("user_id NOT IN (SELECT id FROM users WHERE is_test_student)")
+}
+
+// rejectTestStudent writes a 403 response and returns true when the caller is a test student,
+// who may only take the course they were made for
+func rejectTestStudent(c *gin.Context, db *gorm.DB, action string) bool {
+	userID, _ := c.Get("userID")
+	var count int64
+	db.Model(&models.User{}).Where("id = ? AND is_test_student = ?", userID, true).Count(&count)
+	if count == 0 {
+		return false
+	}
+	apierror.Abort(c, apierror.Forbidden("Test students can't "+action))
+	return true
+}
+
+type TestStudentHandler struct {
+	DB *gorm.DB
+}
+
+func NewTestStudentHandler(db *gorm.DB) *TestStudentHandler {
+	return &TestStudentHandler{DB: db}
+}
+
+// findTestStudent returns the caller's test student of the course, if they made one
+func findTestStudent(db *gorm.DB, ownerID, courseID uint) (models.User, error) {
+	var user models.User
+	err := db.Where("is_test_student = ? AND test_owner_id = ? AND test_course_id = ?", true, ownerID, courseID).
+		First(&user).Error
+	return user, err
+}
+
+// StartTestStudent signs the caller in as their test student of the course, creating it and its enrollment
+// the first time. The returned token acts as that student; their activity stays out of analytics.
+func (h *TestStudentHandler) StartTestStudent(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canTeachCourse)
+	if !ok {
+		return
+	}
+	ownerID := c.MustGet("userID").(uint)
+
+	user, err := findTestStudent(db, ownerID, course.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var owner models.User
+			if err := tx.Select("id, first_name").First(&owner, ownerID).Error; err != nil {
+				return err
+			}
+			// Nobody knows the password and the address can't receive mail, so the account is only
+			// reachable through this endpoint
+			password, err := idgen.Token("", 32)
+			if err != nil {
+				return err
+			}
+			user = models.User{
+				FirstName:     owner.FirstName,
+				LastName:      "(test student)",
+				Email:         fmt.Sprintf("test-student-%d-%d@learninghub.invalid", ownerID, course.ID),
+				Password:      password,
+				Role:          "student",
+				EmailVerified: true,
+				IsTestStudent: true,
+				TestOwnerID:   &ownerID,
+				TestCourseID:  &course.ID,
+			}
+			if err := user.HashPassword(); err != nil {
+				return err
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.Enrollment{
+				UserID:     user.ID,
+				CourseID:   course.ID,
+				EnrolledAt: clock.Now(),
+			}).Error
+		})
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to set up the test student"))
+		return
+	}
+
+	token, err := startSession(db, c, user)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to generate token"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":   i18n.T(i18n.Locale(c), "Use this token to take the course as a student"),
+		"token":     token,
+		"user":      user,
+		"course_id": course.ID,
+	})
+}
+
+// ResetTestStudent clears the progress, attempts, submissions and certificate of the caller's test
+// student of the course, so they can take it again from the start
+func (h *TestStudentHandler) ResetTestStudent(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	course, ok := loadManagedCourse(c, db, canTeachCourse)
+	if !ok {
+		return
+	}
+	user, err := findTestStudent(db, c.MustGet("userID").(uint), course.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.NotFound("You have no test student for this course"))
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		attempts := tx.Model(&models.QuizAttempt{}).Select("id").Where("user_id = ?", user.ID)
+		if err := tx.Where("attempt_id IN (?)", attempts).Delete(&models.QuizAnswer{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{
+			&models.QuizAttempt{}, &models.AssignmentSubmission{}, &models.LessonProgress{}, &models.LessonCode{},
+			&models.LiveAttendance{}, &models.PointEntry{}, &models.UserBadge{}, &models.LearningStreak{},
+			&models.Certificate{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Enrollment{}).Where("user_id = ?", user.ID).Updates(map[string]interface{}{
+			"progress":              0,
+			"completed_lessons":     0,
+			"time_spent":            0,
+			"current_module":        nil,
+			"current_lesson":        nil,
+			"completed_at":          nil,
+			"certificate_id":        nil,
+			"certificate_issued_at": nil,
+			"learning_path_id":      nil,
+		}).Error
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to reset the test student"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Test student reset")})
+}
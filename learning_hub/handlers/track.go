@@ -0,0 +1,680 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/chapa"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/settings"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TrackHandler struct {
+	DB *gorm.DB
+}
+
+func NewTrackHandler(db *gorm.DB) *TrackHandler {
+	return &TrackHandler{DB: db}
+}
+
+type trackInput struct {
+	Title       string  `json:"title" binding:"required,max=200"`
+	Description string  `json:"description"`
+	ImageURL    string  `json:"image_url"`
+	Price       float64 `json:"price" binding:"min=0"`
+	Published   bool    `json:"published"`
+	// Course IDs in study order
+	CourseIDs []uint `json:"course_ids" binding:"required,min=2,max=30"`
+}
+
+// trackCourses orders a track's courses by position
+func trackCourses(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+// loadTrack loads a track with its courses in order
+func loadTrack(db *gorm.DB, id interface{}) (models.Track, error) {
+	var track models.Track
+	err := db.Scopes(withTrackDetails).First(&track, id).Error
+	return track, err
+}
+
+// withTrackDetails preloads what loadTrack returns with a track
+func withTrackDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Courses", trackCourses).Preload("Courses.Course").Preload("Instructor", publicUserFields)
+}
+
+// canManageTrack reports whether the caller is an admin or the track's instructor
+func canManageTrack(c *gin.Context, track models.Track) bool {
+	return ownsCourse(c, models.Course{InstructorID: track.InstructorID})
+}
+
+// checkTrackCourses checks the courses exist without duplicates; instructors may only bundle their own courses
+func (h *TrackHandler) checkTrackCourses(c *gin.Context, ids []uint) bool {
+	db := h.DB.WithContext(c.Request.Context())
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			apierror.Abort(c, apierror.BadRequest(fmt.Sprintf("Course %d is listed twice", id)))
+			return false
+		}
+		seen[id] = true
+	}
+
+	var courses []models.Course
+	if err := db.Select("id, instructor_id").Where("id IN ?", ids).Find(&courses).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch courses"))
+		return false
+	}
+	if len(courses) != len(ids) {
+		apierror.Abort(c, apierror.BadRequest("Some courses do not exist"))
+		return false
+	}
+	for _, course := range courses {
+		if !canManageCourse(c, db, course) {
+			apierror.Abort(c, apierror.Forbidden(fmt.Sprintf("Course %d is not yours to add to a track", course.ID)))
+			return false
+		}
+	}
+	return true
+}
+
+// saveTrackCourses replaces the courses of a track
+func saveTrackCourses(tx *gorm.DB, trackID uint, ids []uint) error {
+	if err := tx.Where("track_id = ?", trackID).Delete(&models.TrackCourse{}).Error; err != nil {
+		return err
+	}
+	items := make([]models.TrackCourse, len(ids))
+	for i, id := range ids {
+		items[i] = models.TrackCourse{TrackID: trackID, CourseID: id, Position: i}
+	}
+	return tx.Create(&items).Error
+}
+
+// CreateTrack bundles courses into a new track
+func (h *TrackHandler) CreateTrack(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input trackInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if !h.checkTrackCourses(c, input.CourseIDs) {
+		return
+	}
+
+	track := models.Track{
+		Title:        strings.TrimSpace(input.Title),
+		Description:  input.Description,
+		ImageURL:     input.ImageURL,
+		Price:        input.Price,
+		Published:    input.Published,
+		InstructorID: c.MustGet("userID").(uint),
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&track).Error; err != nil {
+			return err
+		}
+		return saveTrackCourses(tx, track.ID, input.CourseIDs)
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create track"))
+		return
+	}
+
+	track, _ = loadTrack(db, track.ID)
+	c.JSON(http.StatusCreated, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Track created successfully"),
+		"track":   track,
+	})
+}
+
+// UpdateTrack replaces a track's details and courses. Students already enrolled are not
+// enrolled in added courses; they still need them for the track certificate.
+func (h *TrackHandler) UpdateTrack(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	track, err := loadTrack(db, c.Param("id"))
+	if err != nil {
+		apierror.Abort(c, apierror.NotFound("Track not found"))
+		return
+	}
+	if !canManageTrack(c, track) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to manage this track"))
+		return
+	}
+	var input trackInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if !h.checkTrackCourses(c, input.CourseIDs) {
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&track).Updates(map[string]interface{}{
+			"title":       strings.TrimSpace(input.Title),
+			"description": input.Description,
+			"image_url":   input.ImageURL,
+			"price":       input.Price,
+			"published":   input.Published,
+		}).Error; err != nil {
+			return err
+		}
+		return saveTrackCourses(tx, track.ID, input.CourseIDs)
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update track"))
+		return
+	}
+
+	track, _ = loadTrack(db, track.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Track updated successfully"),
+		"track":   track,
+	})
+}
+
+// DeleteTrack removes a track. Course enrollments made through it are kept.
+func (h *TrackHandler) DeleteTrack(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var track models.Track
+	if err := db.First(&track, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Track not found"))
+		return
+	}
+	if !canManageTrack(c, track) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to manage this track"))
+		return
+	}
+	if err := db.Delete(&track).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete track"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Track deleted successfully")})
+}
+
+// trackSummary adds what the courses cost when bought one by one
+func trackSummary(track models.Track) gin.H {
+	separate := 0.0
+	for _, item := range track.Courses {
+		separate += item.Course.Price
+	}
+	return gin.H{
+		"track":          track,
+		"course_count":   len(track.Courses),
+		"separate_price": separate,
+		"savings":        separate - track.Price,
+	}
+}
+
+// GetTracks lists the published tracks with their courses
+func (h *TrackHandler) GetTracks(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var tracks []models.Track
+	if err := db.Where("published = ?", true).
+		Preload("Courses", trackCourses).Preload("Courses.Course").
+		Preload("Instructor", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, first_name, last_name")
+		}).
+		Order("id DESC").Find(&tracks).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch tracks"))
+		return
+	}
+
+	summaries := make([]gin.H, len(tracks))
+	for i, track := range tracks {
+		summaries[i] = trackSummary(track)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tracks": summaries,
+		"count":  len(summaries),
+	})
+}
+
+// GetTrack shows a published track with its courses in order
+func (h *TrackHandler) GetTrack(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	track, err := loadTrack(db, c.Param("id"))
+	if err != nil || !track.Published {
+		apierror.Abort(c, apierror.NotFound("Track not found"))
+		return
+	}
+	c.JSON(http.StatusOK, trackSummary(track))
+}
+
+// enrollInTrack enrolls the user in the track and each of its courses they don't take yet.
+// Courses already completed count right away, so the track may complete on enrollment.
+func enrollInTrack(tx *gorm.DB, userID uint, track models.Track, paymentID *uint) (*models.TrackEnrollment, error) {
+	enrollment := models.TrackEnrollment{
+		UserID:     userID,
+		TrackID:    track.ID,
+		PaymentID:  paymentID,
+		EnrolledAt: clock.Now(),
+	}
+	if err := tx.Create(&enrollment).Error; err != nil {
+		return nil, err
+	}
+
+	for _, item := range track.Courses {
+		var existing int64
+		if err := tx.Model(&models.Enrollment{}).Where("user_id = ? AND course_id = ?", userID, item.CourseID).
+			Count(&existing).Error; err != nil {
+			return nil, err
+		}
+		if existing > 0 {
+			continue
+		}
+		courseEnrollment := models.Enrollment{
+			UserID:     userID,
+			CourseID:   item.CourseID,
+			PaymentID:  paymentID,
+			IsActive:   true,
+			EnrolledAt: clock.Now(),
+		}
+		if err := tx.Create(&courseEnrollment).Error; err != nil {
+			return nil, err
+		}
+		emitEnrollmentCreated(tx, courseEnrollment)
+	}
+
+	if err := completeTrackIfDone(tx, &enrollment); err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// completeTrackIfDone completes the track enrollment and issues its certificate once every course is completed
+func completeTrackIfDone(tx *gorm.DB, enrollment *models.TrackEnrollment) error {
+	if enrollment.CompletedAt != nil {
+		return nil
+	}
+	var pending int64
+	if err := tx.Model(&models.TrackCourse{}).Where("track_id = ?", enrollment.TrackID).
+		Where(`NOT EXISTS (SELECT 1 FROM enrollments WHERE enrollments.course_id = track_courses.course_id
+			AND enrollments.user_id = ? AND enrollments.completed_at IS NOT NULL)`, enrollment.UserID).
+		Count(&pending).Error; err != nil {
+		return err
+	}
+	if pending > 0 {
+		return nil
+	}
+
+	now := clock.Now()
+	code := generateVerificationCode()
+	if err := tx.Model(enrollment).Updates(map[string]interface{}{
+		"completed_at":          now,
+		"certificate_code":      code,
+		"certificate_issued_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	enrollment.CompletedAt, enrollment.CertificateCode, enrollment.CertificateIssuedAt = &now, &code, &now
+
+	var track models.Track
+	tx.Select("id, title").First(&track, enrollment.TrackID)
+	notifyUser(tx, enrollment.UserID, models.NotificationTrackComplete, "You completed the track "+track.Title, gin.H{
+		"track_id":          track.ID,
+		"verification_code": code,
+	})
+	return nil
+}
+
+// completeTracks completes the user's unfinished tracks that include the course; called when a course is completed
+func completeTracks(tx *gorm.DB, userID, courseID uint) error {
+	var enrollments []models.TrackEnrollment
+	if err := tx.Where("user_id = ? AND completed_at IS NULL", userID).
+		Where("track_id IN (?)", tx.Model(&models.TrackCourse{}).Select("track_id").Where("course_id = ?", courseID)).
+		Find(&enrollments).Error; err != nil {
+		return err
+	}
+	for i := range enrollments {
+		if err := completeTrackIfDone(tx, &enrollments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnrollTrack enrolls the caller in a track and all its courses. Free tracks enroll right away;
+// paid tracks start a Chapa payment for the bundle price and enroll once it succeeds.
+func (h *TrackHandler) EnrollTrack(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	track, err := loadTrack(db, c.Param("id"))
+	if err != nil || !track.Published {
+		apierror.Abort(c, apierror.NotFound("Track not found"))
+		return
+	}
+	if len(track.Courses) == 0 {
+		apierror.Abort(c, apierror.New(http.StatusUnprocessableEntity, "This track has no courses"))
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+	if rejectTestStudent(c, db, "enroll in tracks") {
+		return
+	}
+
+	var existing int64
+	db.Model(&models.TrackEnrollment{}).Where("user_id = ? AND track_id = ?", userID, track.ID).Count(&existing)
+	if existing > 0 {
+		apierror.Abort(c, apierror.Conflict("You are already enrolled in this track"))
+		return
+	}
+
+	// Every course must be offered in the student's country
+	country := requestCountry(c, db)
+	for _, item := range track.Courses {
+		_, available, err := coursePriceIn(db, item.Course, country)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to check course availability"))
+			return
+		}
+		if !available {
+			apierror.Abort(c, apierror.Forbidden("\""+item.Course.Title+"\" is not available in your country"))
+			return
+		}
+	}
+
+	if track.Price == 0 {
+		var enrollment *models.TrackEnrollment
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			enrollment, err = enrollInTrack(tx, userID, track, nil)
+			return err
+		})
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to enroll in track").Wrap(err))
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"message":    i18n.T(i18n.Locale(c), "Enrolled successfully"),
+			"enrollment": enrollment,
+		})
+		return
+	}
+
+	h.initiateTrackPayment(c, track, userID)
+}
+
+// initiateTrackPayment starts the bundle payment, like PaymentHandler.InitiatePayment does for a course
+func (h *TrackHandler) initiateTrackPayment(c *gin.Context, track models.Track, userID uint) {
+	db := h.DB.WithContext(c.Request.Context())
+	if !deviceAllowed(c, db, "check out") {
+		return
+	}
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch user details"))
+		return
+	}
+	key, ok := idempotencyKey(c)
+	if !ok {
+		return
+	}
+	txRef := models.GenerateTxRef()
+	payment := models.Payment{
+		UserID:         user.ID,
+		CourseID:       track.Courses[0].CourseID,
+		TrackID:        &track.ID,
+		Amount:         track.Price,
+		Currency:       settings.DefaultCurrency(),
+		ChapaTxRef:     txRef,
+		IdempotencyKey: key,
+		TaxRate:        settings.TaxRate(),
+		Status:         models.PaymentStatusPending,
+	}
+	if resumeCheckout(c, db, payment) {
+		return
+	}
+
+	// TEST MODE: If using test keys, simulate payment
+	if strings.Contains(chapa.GetSecretKey(), "test") {
+		payment.Status = models.PaymentStatusSuccess
+		payment.PaymentMethod = chapa.MethodTest
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&payment).Error; err != nil {
+				return err
+			}
+			_, err := enrollInTrack(tx, user.ID, track, &payment.ID)
+			return err
+		})
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to enroll in track").Wrap(err))
+			return
+		}
+		recordDevice(db, c, user.ID, models.DeviceEventCheckout)
+		c.JSON(http.StatusOK, gin.H{
+			"message":         i18n.T(i18n.Locale(c), "TEST MODE: Payment completed successfully"),
+			"transaction_ref": txRef,
+			"payment_id":      payment.ID,
+			"test_mode":       true,
+		})
+		return
+	}
+
+	checkoutURL, err := startCheckout(c.Request.Context(), checkout{
+		Payment:     &payment,
+		User:        user,
+		Title:       track.Title,
+		Description: fmt.Sprintf("Pay for %s", track.Title),
+		Meta: map[string]interface{}{
+			"user_id":  user.ID,
+			"track_id": track.ID,
+		},
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to initialize payment").Wrap(err))
+		return
+	}
+	if !createPayment(c, db, &payment) {
+		return
+	}
+	recordDevice(db, c, user.ID, models.DeviceEventCheckout)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         i18n.T(i18n.Locale(c), "Payment initialized successfully"),
+		"checkout_url":    checkoutURL,
+		"transaction_ref": txRef,
+		"payment_id":      payment.ID,
+	})
+}
+
+// trackCourseProgress is the caller's progress in one course of a track
+type trackCourseProgress struct {
+	CourseID    uint    `json:"course_id"`
+	Title       string  `json:"title"`
+	Position    int     `json:"position"`
+	Enrolled    bool    `json:"enrolled"`
+	Progress    float64 `json:"progress"`
+	Completed   bool    `json:"completed"`
+	Certificate *string `json:"certificate_id"`
+}
+
+// trackProgress aggregates the user's progress over the track's courses; each course weighs the same
+func trackProgress(db *gorm.DB, track models.Track, userID uint) ([]trackCourseProgress, float64, error) {
+	byCourse, err := enrollmentsByCourse(db, userID, trackCourseIDs(track))
+	if err != nil {
+		return nil, 0, err
+	}
+	courses, overall := progressThrough(track, byCourse)
+	return courses, overall, nil
+}
+
+func trackCourseIDs(track models.Track) []uint {
+	ids := make([]uint, len(track.Courses))
+	for i, item := range track.Courses {
+		ids[i] = item.CourseID
+	}
+	return ids
+}
+
+// enrollmentsByCourse returns the user's enrollments in the courses by course
+func enrollmentsByCourse(db *gorm.DB, userID uint, courseIDs []uint) (map[uint]models.Enrollment, error) {
+	var enrollments []models.Enrollment
+	if err := db.Where("user_id = ? AND course_id IN ?", userID, courseIDs).Find(&enrollments).Error; err != nil {
+		return nil, err
+	}
+	byCourse := make(map[uint]models.Enrollment, len(enrollments))
+	for _, enrollment := range enrollments {
+		byCourse[enrollment.CourseID] = enrollment
+	}
+	return byCourse, nil
+}
+
+// progressThrough is trackProgress over enrollments already loaded
+func progressThrough(track models.Track, byCourse map[uint]models.Enrollment) ([]trackCourseProgress, float64) {
+	courses := make([]trackCourseProgress, len(track.Courses))
+	total := 0.0
+	for i, item := range track.Courses {
+		courses[i] = trackCourseProgress{CourseID: item.CourseID, Title: item.Course.Title, Position: item.Position}
+		if enrollment, ok := byCourse[item.CourseID]; ok {
+			courses[i].Enrolled = true
+			courses[i].Progress = enrollment.Progress
+			courses[i].Completed = enrollment.CompletedAt != nil
+			courses[i].Certificate = enrollment.CertificateID
+			if courses[i].Completed {
+				courses[i].Progress = 100
+			}
+		}
+		total += courses[i].Progress
+	}
+	overall := 0.0
+	if len(courses) > 0 {
+		overall = total / float64(len(courses))
+	}
+	return courses, overall
+}
+
+// GetTrackProgress shows the caller's progress through a track they are enrolled in
+func (h *TrackHandler) GetTrackProgress(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+	var enrollment models.TrackEnrollment
+	if err := db.Where("user_id = ? AND track_id = ?", userID, c.Param("id")).First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("You are not enrolled in this track"))
+		return
+	}
+	track, err := loadTrack(db.Unscoped(), enrollment.TrackID)
+	if err != nil {
+		apierror.Abort(c, apierror.NotFound("Track not found"))
+		return
+	}
+
+	courses, overall, err := trackProgress(db, track, userID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch progress"))
+		return
+	}
+	completed := 0
+	var next *trackCourseProgress
+	for i := range courses {
+		if courses[i].Completed {
+			completed++
+		} else if next == nil {
+			next = &courses[i]
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"track_id":          track.ID,
+		"title":             track.Title,
+		"progress":          overall,
+		"completed_courses": completed,
+		"total_courses":     len(courses),
+		"courses":           courses,
+		"next_course":       next,
+		"enrollment":        enrollment,
+	})
+}
+
+// GetMyTracks lists the tracks the caller is enrolled in with their overall progress
+func (h *TrackHandler) GetMyTracks(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+	var enrollments []models.TrackEnrollment
+	if err := db.Where("user_id = ?", userID).Order("enrolled_at DESC").Find(&enrollments).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch tracks"))
+		return
+	}
+
+	// The tracks and the caller's enrollments in all their courses are loaded at once
+	trackIDs := make([]uint, len(enrollments))
+	for i, enrollment := range enrollments {
+		trackIDs[i] = enrollment.TrackID
+	}
+	var loaded []models.Track
+	if err := db.Unscoped().Scopes(withTrackDetails).Where("id IN ?", trackIDs).Find(&loaded).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch tracks"))
+		return
+	}
+	trackByID := make(map[uint]models.Track, len(loaded))
+	var courseIDs []uint
+	for _, track := range loaded {
+		trackByID[track.ID] = track
+		courseIDs = append(courseIDs, trackCourseIDs(track)...)
+	}
+	byCourse, err := enrollmentsByCourse(db, userID, courseIDs)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch progress"))
+		return
+	}
+
+	tracks := make([]gin.H, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		track, ok := trackByID[enrollment.TrackID]
+		if !ok {
+			continue
+		}
+		_, overall := progressThrough(track, byCourse)
+		tracks = append(tracks, gin.H{
+			"track":      track,
+			"progress":   overall,
+			"enrollment": enrollment,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tracks": tracks,
+		"count":  len(tracks),
+	})
+}
+
+// VerifyTrackCertificate allows public verification of track certificates by code
+func (h *TrackHandler) VerifyTrackCertificate(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	code := c.Query("code")
+	if code == "" {
+		apierror.Abort(c, apierror.BadRequest("Verification code required"))
+		return
+	}
+
+	var enrollment models.TrackEnrollment
+	err := db.Preload("Track", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("certificate_code = ?", code).First(&enrollment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Abort(c, apierror.NotFound("Certificate not found or invalid").With("valid", false))
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to verify certificate"))
+		return
+	}
+	var user models.User
+	db.Select("id, first_name, last_name").First(&user, enrollment.UserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid": true,
+		"certificate": gin.H{
+			"student_name":      user.FirstName + " " + user.LastName,
+			"track_title":       enrollment.Track.Title,
+			"issue_date":        enrollment.CertificateIssuedAt.Format("January 2, 2006"),
+			"verification_code": code,
+		},
+	})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// transactionAttempts bounds how often a transaction that lost a race is run
+const transactionAttempts = 3
+
+// inTransaction runs fn in a transaction, and runs it again after a short pause when it lost a race with
+// a concurrent one, on a serialization failure or deadlock. Other errors, unique violations included, are
+// returned at once: running fn again would only repeat them. fn may run more than once, so it must only
+// write through tx and keep its results in variables it sets on every run.
+func inTransaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for attempt := 1; ; attempt++ {
+		err := db.Transaction(fn)
+		if err == nil || attempt == transactionAttempts || !retryableTransaction(err) {
+			return err
+		}
+		slog.WarnContext(ctx, "Transaction conflicted with another, retrying", "attempt", attempt, "error", err)
+		select {
+		case <-time.After(time.Duration(attempt) * 50 * time.Millisecond):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func retryableTransaction(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
+
+// detached is db for work that outlives the request, such as emails sent once it is answered: its
+// statements keep the request's trace but are not cancelled with it
+func detached(db *gorm.DB) *gorm.DB {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		return db
+	}
+	return db.WithContext(context.WithoutCancel(ctx))
+}
@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/config"
+	"learning_hub/pkg/fileupload"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/mediaurl"
+	"learning_hub/pkg/transcode"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// Longest a single video may take to transcode
+	transcodeTimeout = 2 * time.Hour
+	// Jobs still processing after this long were interrupted (e.g. by a restart) and are retried
+	transcodeStaleAfter = 3 * time.Hour
+
+	uploadedVideoPrefix = "/uploads/videos/"
+)
+
+// TranscodeHandler runs the background worker converting lesson videos to HLS
+type TranscodeHandler struct {
+	DB          *gorm.DB
+	Transcoder  *transcode.Transcoder // nil when ffmpeg isn't installed
+	MaxAttempts int
+}
+
+func NewTranscodeHandler(db *gorm.DB, cfg *config.Config) *TranscodeHandler {
+	h := &TranscodeHandler{DB: db, MaxAttempts: cfg.TranscodeMaxAttempts}
+
+	renditions, err := transcode.ParseRenditions(cfg.TranscodeRenditions)
+	if err != nil {
+		slog.Warn("Invalid TRANSCODE_RENDITIONS, using all renditions", "error", err)
+		renditions = transcode.DefaultRenditions
+	}
+	if h.Transcoder, err = transcode.New(cfg.FFmpegPath, cfg.FFprobePath, renditions); err != nil {
+		slog.Warn("Video transcoding disabled, lesson videos stay queued", "error", err)
+	}
+	return h
+}
+
+// uploadedVideoKey returns the storage key of a video uploaded through the API, or false for external URLs
+func uploadedVideoKey(videoURL string) (string, bool) {
+	idx := strings.Index(videoURL, uploadedVideoPrefix)
+	if idx < 0 {
+		return "", false
+	}
+	filename := videoURL[idx+len(uploadedVideoPrefix):]
+	if !isValidFilename(filename) {
+		return "", false
+	}
+	return fileupload.ObjectKey(filename, fileupload.FileTypeVideo), true
+}
+
+func hlsKey(transcodeID uint, name string) string {
+	return fmt.Sprintf("hls/%d/%s", transcodeID, name)
+}
+
+func hlsURL(transcodeID uint, name string) string {
+	return fmt.Sprintf("/uploads/hls/%d/%s", transcodeID, name)
+}
+
+// queueTranscode schedules HLS conversion of a lesson video uploaded through the API.
+// Returns nil without queueing for external video URLs or when the video is already queued.
+func queueTranscode(db *gorm.DB, lessonID uint, videoURL string) (*models.VideoTranscode, error) {
+	if _, ok := uploadedVideoKey(videoURL); !ok {
+		return nil, nil
+	}
+
+	var existing int64
+	db.Model(&models.VideoTranscode{}).
+		Where("lesson_id = ? AND source_url = ? AND status IN ?", lessonID, videoURL,
+			[]string{models.TranscodeStatusPending, models.TranscodeStatusProcessing}).
+		Count(&existing)
+	if existing > 0 {
+		return nil, nil
+	}
+
+	job := &models.VideoTranscode{
+		LessonID:  lessonID,
+		SourceURL: videoURL,
+		Status:    models.TranscodeStatusPending,
+	}
+	if err := db.Create(job).Error; err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ProcessTranscodes works through queued videos one at a time. Runs as a scheduled job.
+func (h *TranscodeHandler) ProcessTranscodes(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	h.requeueStale(db)
+
+	for ctx.Err() == nil {
+		var job models.VideoTranscode
+		err := db.Where("status = ?", models.TranscodeStatusPending).Order("id").First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// Claim the job; another instance may have taken it first
+		startedAt := clock.Now()
+		result := db.Model(&models.VideoTranscode{}).
+			Where("id = ? AND status = ?", job.ID, models.TranscodeStatusPending).
+			Updates(map[string]interface{}{
+				"status":     models.TranscodeStatusProcessing,
+				"started_at": startedAt,
+				"attempts":   gorm.Expr("attempts + 1"),
+				"error":      "",
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		job.Attempts++
+
+		if err := h.processTranscode(ctx, &job); err != nil {
+			status := models.TranscodeStatusPending
+			if job.Attempts >= h.MaxAttempts {
+				status = models.TranscodeStatusFailed
+			}
+			slog.Error("Transcoding lesson video failed", "lesson_id", job.LessonID, "attempt", job.Attempts, "error", err)
+			db.Model(&job).Updates(map[string]interface{}{
+				"status": status,
+				"error":  truncate(err.Error(), 2000),
+			})
+			// Leave the remaining queue for the next run instead of retrying immediately
+			return nil
+		}
+		slog.Info("Transcoded lesson video", "lesson_id", job.LessonID, "renditions", job.Renditions, "duration", clock.Since(startedAt).Round(time.Second))
+	}
+	return nil
+}
+
+// requeueStale puts back jobs left in processing by an interrupted worker
+func (h *TranscodeHandler) requeueStale(db *gorm.DB) {
+	stale := db.Model(&models.VideoTranscode{}).
+		Where("status = ? AND started_at < ?", models.TranscodeStatusProcessing, clock.Now().Add(-transcodeStaleAfter))
+	stale.Session(&gorm.Session{}).Where("attempts >= ?", h.MaxAttempts).
+		Updates(map[string]interface{}{"status": models.TranscodeStatusFailed, "error": "transcoding was interrupted"})
+	stale.Session(&gorm.Session{}).Where("attempts < ?", h.MaxAttempts).
+		Update("status", models.TranscodeStatusPending)
+}
+
+// processTranscode converts one video, uploads the renditions and points the lesson at the manifest
+func (h *TranscodeHandler) processTranscode(ctx context.Context, job *models.VideoTranscode) error {
+	db := h.DB.WithContext(ctx)
+	if h.Transcoder == nil {
+		return errors.New("ffmpeg is not available")
+	}
+	key, ok := uploadedVideoKey(job.SourceURL)
+	if !ok {
+		return fmt.Errorf("not an uploaded video: %s", job.SourceURL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, transcodeTimeout)
+	defer cancel()
+
+	workDir, err := os.MkdirTemp("", "lesson-video-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	// Work from a local copy, the source may live in object storage
+	source := filepath.Join(workDir, "source"+filepath.Ext(key))
+	if err := downloadObject(ctx, key, source); err != nil {
+		return fmt.Errorf("failed to fetch source video: %v", err)
+	}
+
+	outDir := filepath.Join(workDir, "hls")
+	if err := os.Mkdir(outDir, 0755); err != nil {
+		return err
+	}
+	ladder, err := h.Transcoder.HLS(ctx, source, outDir)
+	if err != nil {
+		return err
+	}
+
+	files, err := os.ReadDir(outDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := uploadObject(ctx, filepath.Join(outDir, file.Name()), hlsKey(job.ID, file.Name())); err != nil {
+			return fmt.Errorf("failed to store %s: %v", file.Name(), err)
+		}
+	}
+
+	names := make([]string, len(ladder))
+	for i, r := range ladder {
+		names[i] = r.Name
+	}
+	job.Renditions = strings.Join(names, ",")
+	job.ManifestURL = hlsURL(job.ID, transcode.MasterPlaylist)
+	completedAt := clock.Now()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(job).Updates(map[string]interface{}{
+			"status":       models.TranscodeStatusReady,
+			"renditions":   job.Renditions,
+			"manifest_url": job.ManifestURL,
+			"completed_at": completedAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Only switch the lesson over if the instructor hasn't replaced the video meanwhile
+		return tx.Model(&models.Lesson{}).
+			Where("id = ? AND video_url = ?", job.LessonID, job.SourceURL).
+			Update("video_url", job.ManifestURL).Error
+	})
+}
+
+func downloadObject(ctx context.Context, key, dst string) error {
+	reader, _, err := fileupload.Storage().Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, reader)
+	return err
+}
+
+func uploadObject(ctx context.Context, src, key string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return fileupload.Storage().Save(ctx, key, f, info.Size(), hlsContentType(src))
+}
+
+func hlsContentType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// ServeHLS serves HLS playlists and segments of a transcoded lesson video to enrolled students.
+// Playlists reference their segments relatively, so files are always proxied rather than redirected.
+func (h *UploadHandler) ServeHLS(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	filename := c.Param("filename")
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !isValidFilename(filename) || (ext != ".m3u8" && ext != ".ts") {
+		apierror.Abort(c, apierror.BadRequest("Invalid filename"))
+		return
+	}
+
+	var job models.VideoTranscode
+	if err := db.Where("status = ?", models.TranscodeStatusReady).First(&job, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Video not found"))
+		return
+	}
+
+	var lesson models.Lesson
+	if err := db.Preload("Module.Course").First(&lesson, job.LessonID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Video not found"))
+		return
+	}
+
+	reader, info, err := fileupload.Storage().Open(c.Request.Context(), hlsKey(job.ID, filename))
+	if err == fileupload.ErrObjectNotFound {
+		apierror.Abort(c, apierror.NotFound("File not found"))
+		return
+	} else if err != nil {
+		apierror.Abort(c, apierror.Internal("Error accessing file"))
+		return
+	}
+	defer reader.Close()
+
+	// Only the master playlist counts as a download, segments are fetched continuously while playing
+	if !h.authorizeLessonFile(c, lesson, hlsURL(job.ID, filename), info.Size, filename == transcode.MasterPlaylist) {
+		return
+	}
+
+	// Relative playlist entries don't inherit the query string, so signed links are carried over explicitly
+	query := c.Request.URL.Query()
+	if ext == ".m3u8" && mediaurl.Signed(query) {
+		playlist, err := io.ReadAll(reader)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Error accessing file"))
+			return
+		}
+		c.Data(http.StatusOK, hlsContentType(filename), signPlaylist(playlist, query))
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, info.Size, hlsContentType(filename), reader, nil)
+}
+
+// signPlaylist appends the media signature of the request to every URI in an HLS playlist
+func signPlaylist(playlist []byte, query url.Values) []byte {
+	signed := url.Values{}
+	for _, param := range []string{mediaurl.ParamExpires, mediaurl.ParamUser, mediaurl.ParamSignature} {
+		signed.Set(param, query.Get(param))
+	}
+	suffix := "?" + signed.Encode()
+
+	lines := strings.Split(string(playlist), "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.Contains(line, "?") {
+			lines[i] = line + suffix
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// GetLessonVideo returns the lesson's video and the state of its transcoding jobs
+func (h *LessonHandler) GetLessonVideo(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
+		return
+	}
+
+	var jobs []models.VideoTranscode
+	db.Where("lesson_id = ?", lesson.ID).Order("id DESC").Limit(10).Find(&jobs)
+
+	status := "none"
+	if len(jobs) > 0 {
+		status = jobs[0].Status
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lesson_id":  lesson.ID,
+		"video_url":  lesson.VideoURL,
+		"status":     status,
+		"streaming":  strings.HasSuffix(lesson.VideoURL, transcode.MasterPlaylist),
+		"transcodes": jobs,
+	})
+}
+
+// RetranscodeLessonVideo queues the lesson's uploaded video for transcoding again, e.g. after a failure
+func (h *LessonHandler) RetranscodeLessonVideo(c *gin.Context) {
+	db := h.db.WithContext(c.Request.Context())
+	lesson, ok := h.loadManagedLesson(c)
+	if !ok {
+		return
+	}
+
+	// After a successful run the lesson points at the manifest, so fall back to the last source
+	source := lesson.VideoURL
+	if _, uploaded := uploadedVideoKey(source); !uploaded {
+		var last models.VideoTranscode
+		if err := db.Where("lesson_id = ?", lesson.ID).Order("id DESC").First(&last).Error; err != nil {
+			apierror.Abort(c, apierror.BadRequest("Only videos uploaded to LearnHub can be transcoded"))
+			return
+		}
+		source = last.SourceURL
+	}
+
+	job, err := queueTranscode(db, lesson.ID, source)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to queue transcoding"))
+		return
+	}
+	if job == nil {
+		apierror.Abort(c, apierror.Conflict("This video is already being transcoded"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":   i18n.T(i18n.Locale(c), "Video queued for transcoding"),
+		"transcode": job,
+	})
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// How long deleted curriculum items stay restorable before the purge job removes them
+const trashRetention = 30 * 24 * time.Hour
+
+type TrashHandler struct {
+	DB *gorm.DB
+}
+
+func NewTrashHandler(db *gorm.DB) *TrashHandler {
+	return &TrashHandler{DB: db}
+}
+
+type trashItem struct {
+	Type        string    `json:"type"`
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	CourseID    uint      `json:"course_id"`
+	CourseTitle string    `json:"course_title"`
+	ModuleID    *uint     `json:"module_id,omitempty"`
+	DeletedAt   time.Time `json:"deleted_at"`
+	PurgeAt     time.Time `json:"purge_at"`
+}
+
+// GetTrash lists deleted modules, lessons and quizzes of the courses the caller manages (all courses for admins).
+// Lessons deleted together with their module are only listed under the module.
+func (h *TrashHandler) GetTrash(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	scope := func(db *gorm.DB) *gorm.DB {
+		if role, _ := c.Get("userRole"); role != "admin" {
+			userID, _ := c.Get("userID")
+			db = db.Where("courses.instructor_id = ? OR courses.id IN (SELECT course_id FROM course_staffs WHERE user_id = ? AND role = ?)",
+				userID, userID, models.StaffCoInstructor)
+		}
+		if courseID := c.Query("course_id"); courseID != "" {
+			db = db.Where("courses.id = ?", courseID)
+		}
+		return db
+	}
+
+	var modules, lessons, quizzes []trashItem
+	if err := db.Table("modules").Scopes(scope).
+		Joins("JOIN courses ON courses.id = modules.course_id").
+		Where("modules.deleted_at IS NOT NULL").
+		Select("'module' AS type, modules.id, modules.title, modules.course_id, courses.title AS course_title, modules.deleted_at").
+		Scan(&modules).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch trash"))
+		return
+	}
+	if err := db.Table("lessons").Scopes(scope).
+		Joins("JOIN modules ON modules.id = lessons.module_id").
+		Joins("JOIN courses ON courses.id = modules.course_id").
+		Where("lessons.deleted_at IS NOT NULL").
+		Where("modules.deleted_at IS NULL OR modules.deleted_at <> lessons.deleted_at").
+		Select("'lesson' AS type, lessons.id, lessons.title, modules.course_id, courses.title AS course_title, lessons.module_id, lessons.deleted_at").
+		Scan(&lessons).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch trash"))
+		return
+	}
+	if err := db.Table("quizzes").Scopes(scope).
+		Joins("JOIN courses ON courses.id = quizzes.course_id").
+		Where("quizzes.deleted_at IS NOT NULL").
+		Select("'quiz' AS type, quizzes.id, quizzes.title, quizzes.course_id, courses.title AS course_title, quizzes.module_id, quizzes.deleted_at").
+		Scan(&quizzes).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch trash"))
+		return
+	}
+
+	items := append(append(modules, lessons...), quizzes...)
+	for i := range items {
+		items[i].PurgeAt = items[i].DeletedAt.Add(trashRetention)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":          items,
+		"count":          len(items),
+		"retention_days": int(trashRetention.Hours() / 24),
+	})
+}
+
+// RestoreTrashItem restores a deleted module (with the lessons deleted alongside it), lesson or quiz
+func (h *TrashHandler) RestoreTrashItem(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	id := c.Param("id")
+
+	switch c.Param("type") {
+	case "modules":
+		var module models.Module
+		if err := db.Unscoped().Preload("Course").Where("deleted_at IS NOT NULL").First(&module, id).Error; err != nil {
+			apierror.Abort(c, apierror.NotFound("Module not found in trash"))
+			return
+		}
+		if !canManageCourse(c, db, module.Course) {
+			apierror.Abort(c, apierror.Forbidden("Not authorized to restore this module"))
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Model(&models.Lesson{}).
+				Where("module_id = ? AND deleted_at = ?", module.ID, module.DeletedAt.Time).
+				Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Model(&module).Update("deleted_at", nil).Error
+		})
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to restore module"))
+			return
+		}
+		refreshAccessibilityScore(db, module.CourseID)
+		refreshCourseWorkload(db, module.CourseID)
+
+	case "lessons":
+		var lesson models.Lesson
+		if err := db.Unscoped().Where("deleted_at IS NOT NULL").First(&lesson, id).Error; err != nil {
+			apierror.Abort(c, apierror.NotFound("Lesson not found in trash"))
+			return
+		}
+		var module models.Module
+		if err := db.Unscoped().Preload("Course").First(&module, lesson.ModuleID).Error; err != nil {
+			apierror.Abort(c, apierror.NotFound("Module not found"))
+			return
+		}
+		if !canManageCourse(c, db, module.Course) {
+			apierror.Abort(c, apierror.Forbidden("Not authorized to restore this lesson"))
+			return
+		}
+		if module.DeletedAt.Valid {
+			apierror.Abort(c, apierror.Conflict("The lesson's module is in the trash, restore the module first"))
+			return
+		}
+
+		if err := db.Unscoped().Model(&lesson).Update("deleted_at", nil).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to restore lesson"))
+			return
+		}
+		refreshAccessibilityScore(db, module.CourseID)
+		refreshCourseWorkload(db, module.CourseID)
+
+	case "quizzes":
+		var quiz models.Quiz
+		if err := db.Unscoped().Preload("Course").Where("deleted_at IS NOT NULL").First(&quiz, id).Error; err != nil {
+			apierror.Abort(c, apierror.NotFound("Quiz not found in trash"))
+			return
+		}
+		if !canManageCourse(c, db, quiz.Course) {
+			apierror.Abort(c, apierror.Forbidden("Not authorized to restore this quiz"))
+			return
+		}
+
+		if err := db.Unscoped().Model(&quiz).Update("deleted_at", nil).Error; err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to restore quiz"))
+			return
+		}
+		refreshCourseWorkload(db, quiz.CourseID)
+
+	default:
+		apierror.Abort(c, apierror.BadRequest("Invalid item type, expected modules, lessons or quizzes"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Item restored successfully")})
+}
+
+// PurgeTrash permanently removes curriculum items deleted more than trashRetention ago.
+// Quizzes with student attempts are kept so grade history is never lost. Runs as a scheduled job.
+func (h *TrashHandler) PurgeTrash(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	cutoff := clock.Now().Add(-trashRetention)
+
+	var purged [3]int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		expiredQuizzes := tx.Unscoped().Model(&models.Quiz{}).Select("id").
+			Where("deleted_at < ?", cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM quiz_attempts WHERE quiz_attempts.quiz_id = quizzes.id)")
+		if err := tx.Unscoped().Where("quiz_id IN (?)", expiredQuizzes).Delete(&models.QuizQuestion{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id IN (?)", expiredQuizzes).Delete(&models.Quiz{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged[2] = result.RowsAffected
+
+		expiredLessons := tx.Unscoped().Model(&models.Lesson{}).Select("id").
+			Where("deleted_at < ?", cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM lesson_progresses WHERE lesson_progresses.lesson_id = lessons.id)")
+		if err := tx.Unscoped().Model(&models.Quiz{}).Where("lesson_id IN (?)", expiredLessons).
+			Update("lesson_id", nil).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.LessonVariant{}, &models.VideoTranscode{}} {
+			if err := tx.Unscoped().Where("lesson_id IN (?)", expiredLessons).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		result = tx.Unscoped().Where("id IN (?)", expiredLessons).Delete(&models.Lesson{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged[1] = result.RowsAffected
+
+		// Modules go only once none of their lessons remain
+		expiredModules := tx.Unscoped().Model(&models.Module{}).Select("id").
+			Where("deleted_at < ?", cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM lessons WHERE lessons.module_id = modules.id)")
+		for _, model := range []interface{}{&models.Quiz{}, &models.Assignment{}} {
+			if err := tx.Unscoped().Model(model).Where("module_id IN (?)", expiredModules).
+				Update("module_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		result = tx.Unscoped().Where("id IN (?)", expiredModules).Delete(&models.Module{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged[0] = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if purged[0]+purged[1]+purged[2] > 0 {
+		slog.Info("Purged trash", "modules", purged[0], "lessons", purged[1], "quizzes", purged[2])
+	}
+	return nil
+}
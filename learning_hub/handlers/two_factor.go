@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/totp"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorIssuer = "LearnHub"
+
+	// A login waits this long for its code, and is dropped after this many wrong codes
+	twoFactorChallengeTTL      = 5 * time.Minute
+	twoFactorChallengeAttempts = 5
+
+	backupCodeCount = 10
+)
+
+// hashTwoFactorValue hashes a login challenge token or backup code for storage
+func hashTwoFactorValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeBackupCode lets backup codes be typed without the dash, with spaces or in upper case
+func normalizeBackupCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
+
+// replaceBackupCodes swaps the user's backup codes for new ones and returns them; they are only shown once
+func replaceBackupCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactorBackupCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, backupCodeCount)
+	rows := make([]models.TwoFactorBackupCode, backupCodeCount)
+	for i := range codes {
+		raw, err := idgen.Token("", 5)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = raw[:5] + "-" + raw[5:]
+		rows[i] = models.TwoFactorBackupCode{UserID: userID, CodeHash: hashTwoFactorValue(raw), CreatedAt: clock.Now()}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// checkTwoFactorCode accepts an authenticator code not used before, or else an unused backup code, which
+// is used up. usedBackup reports which one it was.
+func checkTwoFactorCode(db *gorm.DB, user models.User, code string) (ok, usedBackup bool, err error) {
+	if step, valid := totp.Validate(user.TwoFactorSecret, code, clock.Now(), user.TwoFactorLastStep); valid {
+		// Conditional, so the same code sent twice at once only works once
+		result := db.Model(&models.User{}).Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+			Update("two_factor_last_step", step)
+		return result.RowsAffected == 1, false, result.Error
+	}
+
+	code = normalizeBackupCode(code)
+	if code == "" {
+		return false, false, nil
+	}
+	result := db.Model(&models.TwoFactorBackupCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashTwoFactorValue(code)).
+		Update("used_at", clock.Now())
+	return result.RowsAffected == 1, result.RowsAffected == 1, result.Error
+}
+
+// disableTwoFactor turns two-factor login off and forgets the secret, backup codes and pending logins
+func disableTwoFactor(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_enabled":   false,
+		"two_factor_secret":    "",
+		"two_factor_last_step": 0,
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactorBackupCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.TwoFactorChallenge{}).Error
+}
+
+func sendTwoFactorChangedEmail(user models.User, change string, byAdmin bool) {
+	go func() {
+		if err := email.SendTwoFactorChangedEmail(user.Email, user.FirstName, change, byAdmin); err != nil {
+			slog.Error("Failed to send two-factor email", "user_id", user.ID, "error", err)
+		}
+	}()
+}
+
+// createTwoFactorChallenge starts the second step of a login and returns the token to send back with the code
+func createTwoFactorChallenge(db *gorm.DB, userID uint) (string, time.Time, error) {
+	token, err := idgen.Token("2fa_", 32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := clock.Now()
+	challenge := models.TwoFactorChallenge{
+		UserID:    userID,
+		TokenHash: hashTwoFactorValue(token),
+		ExpiresAt: now.Add(twoFactorChallengeTTL),
+		CreatedAt: now,
+	}
+	// Expired challenges of the user are cleared as new ones are made
+	db.Where("user_id = ? AND expires_at < ?", userID, now).Delete(&models.TwoFactorChallenge{})
+	if err := db.Create(&challenge).Error; err != nil {
+		return "", time.Time{}, err
+	}
+	return token, challenge.ExpiresAt, nil
+}
+
+// startTwoFactorLogin answers a right password of a two-factor user with a challenge token to send back
+// with the code to POST /login/2fa
+func (h *UserHandler) startTwoFactorLogin(c *gin.Context, user models.User) {
+	db := h.DB.WithContext(c.Request.Context())
+	token, expiresAt, err := createTwoFactorChallenge(db, user.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to start two-factor login"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":             i18n.T(i18n.Locale(c), "Enter the code from your authenticator app or a backup code"),
+		"two_factor_required": true,
+		"challenge_token":     token,
+		"expires_at":          expiresAt,
+	})
+}
+
+// VerifyTwoFactorLogin completes a two-factor login with the challenge token from POST /login and an
+// authenticator or backup code ({"challenge_token", "code"}), responding like a login
+func (h *UserHandler) VerifyTwoFactorLogin(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+
+	var challenge models.TwoFactorChallenge
+	if err := db.Where("token_hash = ? AND expires_at > ?", hashTwoFactorValue(input.ChallengeToken), clock.Now()).
+		First(&challenge).Error; err != nil {
+		apierror.Abort(c, apierror.Unauthorized("This login has expired, log in again"))
+		return
+	}
+	var user models.User
+	if err := db.First(&user, challenge.UserID).Error; err != nil {
+		apierror.Abort(c, apierror.Unauthorized("This login has expired, log in again"))
+		return
+	}
+	if user.LockedUntil != nil && user.LockedUntil.After(clock.Now()) {
+		recordLoginAttempt(db, c, &user.ID, user.Email, "locked")
+		apierror.Abort(c, apierror.New(http.StatusLocked, "Too many failed logins: this account is locked. Try again later or reset your password").
+			WithCode(apierror.CodeAccountLocked).With("locked_until", user.LockedUntil))
+		return
+	}
+
+	// An admin may have reset two-factor login meanwhile; the password was right, so the login goes on
+	if user.TwoFactorEnabled {
+		ok, usedBackup, err := checkTwoFactorCode(db, user, input.Code)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to check the code"))
+			return
+		}
+		if !ok {
+			db.Model(&challenge).Update("attempts", gorm.Expr("attempts + 1"))
+			if challenge.Attempts+1 >= twoFactorChallengeAttempts {
+				db.Delete(&challenge)
+			}
+			h.loginFailed(c, user, "wrong_2fa_code", "Invalid two-factor code")
+			return
+		}
+		if usedBackup {
+			slog.InfoContext(c.Request.Context(), "User logged in with a backup code", "user_id", user.ID)
+		}
+	}
+
+	db.Delete(&challenge)
+	h.completeLogin(c, user)
+}
+
+// GetTwoFactorStatus reports whether the caller has two-factor login on and how many backup codes are left
+func (h *UserHandler) GetTwoFactorStatus(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.Select("id, two_factor_enabled").First(&user, c.MustGet("userID")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	var left int64
+	db.Model(&models.TwoFactorBackupCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&left)
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":           user.TwoFactorEnabled,
+		"backup_codes_left": left,
+	})
+}
+
+// SetupTwoFactor creates a new authenticator secret for the caller ({"password"}) and returns it with the
+// otpauth:// URI to show as a QR code. It is only used once confirmed with POST /2fa/enable.
+func (h *UserHandler) SetupTwoFactor(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("Your password is required"))
+		return
+	}
+	var user models.User
+	if err := db.First(&user, c.MustGet("userID")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if user.TwoFactorEnabled {
+		apierror.Abort(c, apierror.Conflict("Two-factor login is already on"))
+		return
+	}
+	if err := user.CheckPassword(input.Password); err != nil {
+		apierror.Abort(c, apierror.Unauthorized("Incorrect password"))
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to set up two-factor login"))
+		return
+	}
+	if err := db.Model(&user).Updates(map[string]interface{}{
+		"two_factor_secret":    secret,
+		"two_factor_last_step": 0,
+	}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to set up two-factor login"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          i18n.T(i18n.Locale(c), "Scan the QR code with your authenticator app, then confirm a code to turn two-factor login on"),
+		"secret":           secret,
+		"provisioning_uri": totp.URI(secret, twoFactorIssuer, user.Email),
+	})
+}
+
+// EnableTwoFactor turns two-factor login on once the caller confirms a code of the secret from setup
+// ({"code"}), and returns the backup codes
+func (h *UserHandler) EnableTwoFactor(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("A code from your authenticator app is required"))
+		return
+	}
+	var user models.User
+	if err := db.First(&user, c.MustGet("userID")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if user.TwoFactorEnabled {
+		apierror.Abort(c, apierror.Conflict("Two-factor login is already on"))
+		return
+	}
+	if user.TwoFactorSecret == "" {
+		apierror.Abort(c, apierror.BadRequest("Set up two-factor login first"))
+		return
+	}
+	step, ok := totp.Validate(user.TwoFactorSecret, input.Code, clock.Now(), user.TwoFactorLastStep)
+	if !ok {
+		apierror.Abort(c, apierror.BadRequest("Invalid code: check your phone's time is correct and try again"))
+		return
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"two_factor_enabled":   true,
+			"two_factor_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceBackupCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to turn on two-factor login"))
+		return
+	}
+	sendTwoFactorChangedEmail(user, "turned on", false)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      i18n.T(i18n.Locale(c), "Two-factor login is on. Keep these backup codes somewhere safe: each works once, and they won't be shown again"),
+		"backup_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns two-factor login off ({"password", "code"}, an authenticator or backup code)
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("Your password and a code are required"))
+		return
+	}
+	var user models.User
+	if err := db.First(&user, c.MustGet("userID")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if !user.TwoFactorEnabled {
+		apierror.Abort(c, apierror.BadRequest("Two-factor login is not on"))
+		return
+	}
+	if err := user.CheckPassword(input.Password); err != nil {
+		apierror.Abort(c, apierror.Unauthorized("Incorrect password"))
+		return
+	}
+	if ok, _, err := checkTwoFactorCode(db, user, input.Code); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check the code"))
+		return
+	} else if !ok {
+		apierror.Abort(c, apierror.Unauthorized("Invalid two-factor code"))
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return disableTwoFactor(tx, user.ID)
+	}); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to turn off two-factor login"))
+		return
+	}
+	sendTwoFactorChangedEmail(user, "turned off", false)
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Two-factor login is off")})
+}
+
+// RegenerateBackupCodes replaces the caller's backup codes ({"code"}, an authenticator or backup code)
+func (h *UserHandler) RegenerateBackupCodes(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("A code from your authenticator app is required"))
+		return
+	}
+	var user models.User
+	if err := db.First(&user, c.MustGet("userID")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if !user.TwoFactorEnabled {
+		apierror.Abort(c, apierror.BadRequest("Two-factor login is not on"))
+		return
+	}
+	if ok, _, err := checkTwoFactorCode(db, user, input.Code); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check the code"))
+		return
+	} else if !ok {
+		apierror.Abort(c, apierror.Unauthorized("Invalid two-factor code"))
+		return
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceBackupCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create backup codes"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":      i18n.T(i18n.Locale(c), "New backup codes created; the old ones no longer work"),
+		"backup_codes": codes,
+	})
+}
+
+// ResetTwoFactor turns off two-factor login of a user who lost their authenticator and backup codes,
+// so they can log in with their password and set it up again
+func (h *AdminHandler) ResetTwoFactor(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if !user.TwoFactorEnabled && user.TwoFactorSecret == "" {
+		apierror.Abort(c, apierror.BadRequest("This user has no two-factor login set up"))
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := disableTwoFactor(tx, user.ID); err != nil {
+			return err
+		}
+		recordAudit(tx, c, models.AuditTwoFactorReset, "user", user.ID,
+			gin.H{"two_factor_enabled": user.TwoFactorEnabled}, gin.H{"two_factor_enabled": false})
+		return nil
+	}); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to reset two-factor login"))
+		return
+	}
+	if user.TwoFactorEnabled {
+		sendTwoFactorChangedEmail(user, "reset", true)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Two-factor login reset; the user can log in with their password")})
+}
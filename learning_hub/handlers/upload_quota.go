@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/i18n"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// uploadQuota is a user's upload allowance and how much of it is used
+type uploadQuota struct {
+	Usage  int64  `json:"usage"`
+	Limit  int64  `json:"limit"`  // 0 means unlimited
+	Source string `json:"source"` // user, role or unlimited
+}
+
+// exceededBy reports whether uploading size more bytes would go over the limit
+func (q uploadQuota) exceededBy(size int64) bool {
+	return q.Limit > 0 && q.Usage+size > q.Limit
+}
+
+// formatBytes renders a byte count for error messages, e.g. "12.5 MB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// uploadUsage sums the sizes of the user's registered uploads. Deduplicated content counts
+// for every uploader that has it.
+func uploadUsage(db *gorm.DB, userID uint) (int64, error) {
+	var usage int64
+	err := db.Model(&models.UploadedFile{}).Where("owner_id = ?", userID).
+		Select("COALESCE(SUM(size), 0)").Scan(&usage).Error
+	return usage, err
+}
+
+// quotaFor returns the user's quota: their override if an admin set one, else their role's default.
+// Admins are unlimited.
+func (h *UploadHandler) quotaFor(db *gorm.DB, userID uint) (uploadQuota, error) {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return uploadQuota{}, err
+	}
+	usage, err := uploadUsage(db, userID)
+	if err != nil {
+		return uploadQuota{}, err
+	}
+
+	quota := uploadQuota{Usage: usage, Source: "role"}
+	var override models.UploadQuota
+	switch {
+	case db.Where("user_id = ?", userID).First(&override).Error == nil:
+		quota.Limit, quota.Source = override.QuotaBytes, "user"
+	case user.Role == "admin":
+		quota.Source = "unlimited"
+	case user.Role == "instructor":
+		quota.Limit = h.InstructorQuota
+	default:
+		quota.Limit = h.StudentQuota
+	}
+	if quota.Limit == 0 {
+		quota.Source = "unlimited"
+	}
+	return quota, nil
+}
+
+// GetMyUploadQuota returns the user's upload usage and limit
+func (h *UploadHandler) GetMyUploadQuota(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, _ := c.Get("userID")
+
+	quota, err := h.quotaFor(db, userID.(uint))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check upload quota"))
+		return
+	}
+	c.JSON(http.StatusOK, quota)
+}
+
+// GetUserUploadQuota returns a user's upload usage and limit
+func (h *UploadHandler) GetUserUploadQuota(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+
+	quota, err := h.quotaFor(db, user.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check upload quota"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": user.ID, "role": user.Role, "quota": quota})
+}
+
+// SetUserUploadQuota overrides a user's upload quota; 0 makes it unlimited.
+// Existing files are kept even if they exceed the new limit.
+func (h *UploadHandler) SetUserUploadQuota(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	adminID, _ := c.Get("userID")
+
+	var user models.User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+
+	var input struct {
+		QuotaBytes *int64 `json:"quota_bytes" binding:"required"`
+		Note       string `json:"note" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.Bind(err))
+		return
+	}
+	if *input.QuotaBytes < 0 {
+		apierror.Abort(c, apierror.BadRequest("quota_bytes must not be negative"))
+		return
+	}
+
+	now := clock.Now()
+	var override models.UploadQuota
+	db.Where("user_id = ?", user.ID).First(&override)
+	override.UserID = user.ID
+	override.QuotaBytes = *input.QuotaBytes
+	override.Note = input.Note
+	override.SetByID = adminID.(uint)
+	override.UpdatedAt = now
+	if override.ID == 0 {
+		override.CreatedAt = now
+	}
+	if err := db.Save(&override).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to save upload quota"))
+		return
+	}
+
+	quota, err := h.quotaFor(db, user.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check upload quota"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Upload quota updated"), "user_id": user.ID, "quota": quota})
+}
+
+// ResetUserUploadQuota removes a user's override so their role's default applies again
+func (h *UploadHandler) ResetUserUploadQuota(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+
+	if err := db.Where("user_id = ?", user.ID).Delete(&models.UploadQuota{}).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to reset upload quota"))
+		return
+	}
+
+	quota, err := h.quotaFor(db, user.ID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check upload quota"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Upload quota reset to role default"), "user_id": user.ID, "quota": quota})
+}
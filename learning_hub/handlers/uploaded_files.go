@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/fileupload"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// orphanCleanupBatchSize is how many uploads the cleanup job checks per query
+const orphanCleanupBatchSize = 100
+
+// fileReference is an entity field using an uploaded file
+type fileReference struct {
+	Type  string `json:"type"`
+	ID    uint   `json:"id"`
+	Field string `json:"field"`
+}
+
+// fileReferenceColumns are the columns that can hold an upload URL. Text columns are searched for
+// embedded links. Soft-deleted rows still count so trashed items can be restored with their files.
+var fileReferenceColumns = []struct {
+	Table, Type, Column string
+	Embedded            bool
+}{
+	{"courses", "course", "image_url", false},
+	{"courses", "course", "thumbnail_url", false},
+	{"lessons", "lesson", "video_url", false},
+	{"lessons", "lesson", "document_url", false},
+	{"lessons", "lesson", "content", true},
+	{"lesson_variants", "lesson_variant", "video_url", false},
+	{"lesson_variants", "lesson_variant", "captions_url", false},
+	{"lesson_variants", "lesson_variant", "content", true},
+	{"assignment_submissions", "assignment_submission", "file_url", false},
+	{"certificate_templates", "certificate_template", "background_image_url", false},
+	{"certificate_templates", "certificate_template", "signature_image_url", false},
+}
+
+// uploadURLs returns every URL an upload can be referenced by: the file itself and, for images,
+// its generated size variants
+func uploadURLs(file models.UploadedFile) []string {
+	urls := []string{file.FileURL}
+	if file.FileType == fileupload.FileTypeImage {
+		for _, size := range fileupload.ImageSizes {
+			urls = append(urls, "/uploads/"+fileupload.VariantKey(file.StorageKey, size))
+		}
+	}
+	return urls
+}
+
+// uploadReferences finds the entities currently using an uploaded file.
+// Stored URLs may carry a host, so columns are matched on the URL suffix.
+func uploadReferences(db *gorm.DB, file models.UploadedFile) ([]fileReference, error) {
+	urls := uploadURLs(file)
+	references := []fileReference{}
+
+	for _, col := range fileReferenceColumns {
+		conditions := make([]string, len(urls))
+		args := make([]interface{}, len(urls))
+		for i, u := range urls {
+			conditions[i] = col.Column + " LIKE ?"
+			if col.Embedded {
+				args[i] = "%" + u + "%"
+			} else {
+				args[i] = "%" + u
+			}
+		}
+
+		var ids []uint
+		if err := db.Table(col.Table).Where(strings.Join(conditions, " OR "), args...).
+			Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			references = append(references, fileReference{Type: col.Type, ID: id, Field: col.Column})
+		}
+	}
+
+	// Transcoded videos keep their source while the lesson exists, even after video_url moves to the HLS manifest
+	var transcodeIDs []uint
+	if err := db.Table("video_transcodes").
+		Where("source_url LIKE ?", "%"+file.FileURL).
+		Where("EXISTS (SELECT 1 FROM lessons WHERE lessons.id = video_transcodes.lesson_id)").
+		Pluck("id", &transcodeIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range transcodeIDs {
+		references = append(references, fileReference{Type: "video_transcode", ID: id, Field: "source_url"})
+	}
+
+	return references, nil
+}
+
+// refreshReferences looks up and stores the entities using an uploaded file
+func refreshReferences(db *gorm.DB, file *models.UploadedFile) ([]fileReference, error) {
+	references, err := uploadReferences(db, *file)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(references)
+	if err != nil {
+		return nil, err
+	}
+	now := clock.Now()
+	file.ReferencedBy = models.JSON(encoded)
+	file.ReferencesCheckedAt = &now
+	if err := db.Model(file).Updates(map[string]interface{}{
+		"referenced_by":         file.ReferencedBy,
+		"references_checked_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return references, nil
+}
+
+// findDuplicateUpload returns a stored upload with the same content, or nil
+func findDuplicateUpload(ctx context.Context, db *gorm.DB, fileType, checksum string, size int64) *models.UploadedFile {
+	// Assignment submissions are private to their student and course, so their content isn't shared
+	var existing models.UploadedFile
+	if err := db.Where("file_type = ? AND checksum = ? AND size = ?", fileType, checksum, size).
+		Where("storage_key NOT LIKE ?", submissionStoragePrefix+"%").
+		Order("id").First(&existing).Error; err != nil {
+		return nil
+	}
+	// The registry could be ahead of storage, e.g. after a failed cleanup
+	if _, err := fileupload.Storage().Stat(ctx, existing.StorageKey); err != nil {
+		return nil
+	}
+	return &existing
+}
+
+// deleteUpload removes an upload's registry entry and, once no other upload shares the stored
+// content, the file and its image variants
+func deleteUpload(ctx context.Context, db *gorm.DB, file models.UploadedFile) error {
+	var sharing int64
+	if err := db.Model(&models.UploadedFile{}).Where("storage_key = ? AND id <> ?", file.StorageKey, file.ID).
+		Count(&sharing).Error; err != nil {
+		return err
+	}
+	if sharing > 0 {
+		return db.Delete(&file).Error
+	}
+
+	storage := fileupload.Storage()
+	if err := storage.Delete(ctx, file.StorageKey); err != nil && !errors.Is(err, fileupload.ErrObjectNotFound) {
+		return err
+	}
+	if file.FileType == fileupload.FileTypeImage {
+		for _, size := range fileupload.ImageSizes {
+			storage.Delete(ctx, fileupload.VariantKey(file.StorageKey, size))
+		}
+	}
+	return db.Delete(&file).Error
+}
+
+// GetMyFiles lists the files the user has uploaded and where each is used (?type=image|video|document)
+func (h *UploadHandler) GetMyFiles(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, _ := c.Get("userID")
+
+	query := db.Where("owner_id = ?", userID)
+	if fileType := c.Query("type"); fileType != "" {
+		if !isValidFileType(fileType) {
+			apierror.Abort(c, apierror.BadRequest("Invalid file type"))
+			return
+		}
+		query = query.Where("file_type = ?", fileType)
+	}
+
+	var files []models.UploadedFile
+	if err := query.Order("created_at DESC").Limit(200).Find(&files).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch files"))
+		return
+	}
+
+	var totalSize int64
+	for i := range files {
+		if _, err := refreshReferences(db, &files[i]); err != nil {
+			apierror.Abort(c, apierror.Internal("Failed to check file usage"))
+			return
+		}
+		totalSize += files[i].Size
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"files":      files,
+		"count":      len(files),
+		"total_size": totalSize,
+	})
+}
+
+// DeleteMyFile deletes one of the user's uploaded files. Files still in use are refused.
+// Admins may delete any file.
+func (h *UploadHandler) DeleteMyFile(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("userRole")
+
+	var file models.UploadedFile
+	if err := db.First(&file, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("File not found"))
+		return
+	}
+	if role != "admin" && (file.OwnerID == nil || *file.OwnerID != userID.(uint)) {
+		apierror.Abort(c, apierror.Forbidden("Not authorized to delete this file"))
+		return
+	}
+
+	references, err := refreshReferences(db, &file)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to check file usage"))
+		return
+	}
+	if len(references) > 0 {
+		apierror.Abort(c, apierror.Conflict("File is still in use").With("references", references))
+		return
+	}
+
+	if err := deleteUpload(c.Request.Context(), db, file); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to delete file"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "File deleted successfully")})
+}
+
+// CleanupOrphanedUploads deletes uploads older than the grace period that nothing references.
+// Runs as a scheduled job.
+func (h *UploadHandler) CleanupOrphanedUploads(ctx context.Context) error {
+	cutoff := clock.Now().Add(-h.OrphanGracePeriod)
+	db := h.DB.WithContext(ctx)
+
+	var lastID uint
+	deleted := 0
+	for ctx.Err() == nil {
+		var files []models.UploadedFile
+		if err := db.Where("created_at < ? AND id > ?", cutoff, lastID).
+			Order("id").Limit(orphanCleanupBatchSize).Find(&files).Error; err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			break
+		}
+		lastID = files[len(files)-1].ID
+
+		for i := range files {
+			references, err := refreshReferences(db, &files[i])
+			if err != nil {
+				return err
+			}
+			if len(references) > 0 {
+				continue
+			}
+			if err := deleteUpload(ctx, db, files[i]); err != nil {
+				slog.Error("Failed to delete orphaned upload", "storage_key", files[i].StorageKey, "error", err)
+				continue
+			}
+			deleted++
+		}
+	}
+
+	if deleted > 0 {
+		slog.Info("Deleted orphaned uploads", "count", deleted)
+	}
+	return ctx.Err()
+}
@@ -1,14 +1,21 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/config"
 	"learning_hub/pkg/email"
-	"learning_hub/pkg/jwt"
+	"learning_hub/pkg/fx"
+	"learning_hub/pkg/geo"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/settings"
 	"learning_hub/pkg/utils"
 	"learning_hub/pkg/validation"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,61 +23,77 @@ import (
 )
 
 type UserHandler struct {
-	DB *gorm.DB
+	DB               *gorm.DB
+	MaxLoginFailures int           // failed logins in a row that lock the account, 0 disables lockout
+	LockoutDuration  time.Duration // how long a locked account stays locked
+	AppBaseURL       string        // social login providers send users back here
 }
 
-func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{DB: db}
+func NewUserHandler(db *gorm.DB, cfg *config.Config) *UserHandler {
+	return &UserHandler{
+		DB:               db,
+		MaxLoginFailures: cfg.LoginMaxFailures,
+		LockoutDuration:  cfg.LoginLockoutDuration,
+		AppBaseURL:       strings.TrimRight(cfg.AppBaseURL, "/"),
+	}
 }
 
 // Update RegisterUser function to send verification email
 // Update RegisterUser function in user_handler.go
 func (h *UserHandler) RegisterUser(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	var request struct {
-		FirstName string `json:"first_name" binding:"required"`
-		LastName  string `json:"last_name" binding:"required"`
-		Email     string `json:"email" binding:"required,email"`
-		Password  string `json:"password" binding:"required,min=6"`
-		Phone     string `json:"phone" binding:"omitempty"`
+		FirstName string `json:"first_name" binding:"required,max=100"`
+		LastName  string `json:"last_name" binding:"required,max=100"`
+		Email     string `json:"email" binding:"required,email,max=100"`
+		Password  string `json:"password" binding:"required,min=6,max=72"` // bcrypt ignores what follows 72 bytes
+		Phone     string `json:"phone" binding:"omitempty,max=20"`
 		Role      string `json:"role" binding:"omitempty"` // Remove role validation for public registration
+		Language  string `json:"preferred_language"`       // defaults to the language of Accept-Language
 	}
 
 	// Bind JSON input
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid user data: " + err.Error(),
-		})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
+	// Kept so emails, sent outside of requests, are written in the language the user signed up in
+	language := i18n.Match(c.GetHeader("Accept-Language"))
+	if request.Language != "" {
+		locale, ok := i18n.Normalize(request.Language)
+		if !ok {
+			apierror.Abort(c, apierror.Invalid(languageProblem("preferred_language")))
+			return
+		}
+		language = locale
+	}
 
 	// Validate email domain
 	if isValid, errMsg := validation.IsValidEmail(request.Email); !isValid {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":           errMsg,
-			"allowed_domains": validation.GetAllowedDomains(),
-		})
+		apierror.Abort(c, apierror.BadRequest(errMsg).WithCode(apierror.CodeEmailDomainNotAllowed).
+			With("allowed_domains", validation.GetAllowedDomains()))
+		return
+	}
+
+	if !deviceAllowed(c, db, "register") {
 		return
 	}
 
 	// Check if user already exists
 	var existingUser models.User
-	if err := h.DB.Where("email = ?", request.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"error": "User with this email already exists",
-		})
+	if err := db.Where("email = ?", request.Email).First(&existingUser).Error; err == nil {
+		apierror.Abort(c, apierror.Conflict("User with this email already exists").WithCode(apierror.CodeEmailTaken))
 		return
 	}
 
 	// Generate verification token
 	verificationToken, err := utils.GenerateVerificationToken()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to generate verification token",
-		})
+		apierror.Abort(c, apierror.Internal("Failed to generate verification token"))
 		return
 	}
 
-	verificationSentAt := time.Now()
+	verificationSentAt := clock.Now()
 
 	newUser := models.User{
 		FirstName:          request.FirstName,
@@ -78,6 +101,7 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 		Email:              request.Email,
 		Password:           request.Password,
 		Phone:              request.Phone,
+		PreferredLanguage:  language,
 		Role:               "student", // Force student role for public registration
 		EmailVerified:      false,
 		VerificationToken:  &verificationToken,
@@ -85,30 +109,28 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 	}
 	// Hash password
 	if err := newUser.HashPassword(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to secure password: " + err.Error(),
-		})
+		apierror.Abort(c, apierror.Internal("Failed to secure password").Wrap(err))
 		return
 	}
 
 	// Create user in database
-	if err := h.DB.Create(&newUser).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to register user: " + err.Error(),
-		})
+	if err := db.Create(&newUser).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to register user").Wrap(err))
 		return
 	}
 
+	recordDevice(db, c, newUser.ID, models.DeviceEventRegister)
+
 	// Send verification email in background
 	go func() {
 		fullName := newUser.FirstName + " " + newUser.LastName
 		if err := email.SendVerificationEmail(newUser.Email, fullName, verificationToken); err != nil {
-			log.Printf("Failed to send verification email: %v", err)
+			slog.Error("Failed to send verification email", "user_id", newUser.ID, "error", err)
 		}
 	}()
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Student account created successfully. Please check your email for verification link.",
+		"message": i18n.T(i18n.Locale(c), "Student account created successfully. Please check your email for verification link."),
 		"user": gin.H{
 			"id":             newUser.ID,
 			"first_name":     newUser.FirstName,
@@ -125,51 +147,30 @@ func (h *UserHandler) RegisterUser(c *gin.Context) {
 
 // VerifyEmail handles email verification
 func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	token := c.Query("token")
-	fmt.Printf("🔍 VerifyEmail called with token: %s\n", token)
-
 	if token == "" {
-		fmt.Printf("❌ No token provided\n")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Verification token is required",
-		})
+		apierror.Abort(c, apierror.BadRequest("Verification token is required"))
 		return
 	}
 
 	// Find user by verification token
 	var user models.User
-	if err := h.DB.Where("verification_token = ?", token).First(&user).Error; err != nil {
-		fmt.Printf("❌ User not found for token: %s, error: %v\n", token, err)
-
-		// Check if token might be expired or already used
-		var existingUser models.User
-		if err := h.DB.Where("email = ?", "jerbawjerbex@gmail.com").First(&existingUser).Error; err == nil {
-			fmt.Printf("ℹ️ User exists but token doesn't match. User token: '%s'\n", existingUser.VerificationToken)
-			fmt.Printf("ℹ️ User verification status: %t\n", existingUser.EmailVerified)
-		}
-
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid or expired verification token",
-		})
+	if err := db.Where("verification_token = ?", token).First(&user).Error; err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid or expired verification token"))
 		return
 	}
 
-	fmt.Printf("✅ User found: ID=%d, Email=%s, Verified=%t\n", user.ID, user.Email, user.EmailVerified)
-
 	// Check if token is expired
 	if utils.IsTokenExpired(user.VerificationSentAt) {
-		fmt.Printf("❌ Token expired. Sent at: %v\n", user.VerificationSentAt)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Verification token has expired. Please request a new one.",
-		})
+		apierror.Abort(c, apierror.BadRequest("Verification token has expired. Please request a new one."))
 		return
 	}
 
 	// Check if already verified
 	if user.EmailVerified {
-		fmt.Printf("ℹ️ Email already verified\n")
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Email is already verified",
+			"message": i18n.T(i18n.Locale(c), "Email is already verified"),
 			"user": gin.H{
 				"id":    user.ID,
 				"email": user.Email,
@@ -178,44 +179,35 @@ func (h *UserHandler) VerifyEmail(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("📝 Updating user verification status...\n")
-
 	// Update user as verified using direct SQL to be safe
-	result := h.DB.Exec(`
+	result := db.Exec(`
 		UPDATE users 
 		SET email_verified = true, verification_token = NULL 
 		WHERE id = ? AND verification_token = ?
 	`, user.ID, token)
 
 	if result.Error != nil {
-		fmt.Printf("❌ Failed to update user verification: %v\n", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to verify email: " + result.Error.Error(),
-		})
+		apierror.Abort(c, apierror.Internal("Failed to verify email").Wrap(result.Error))
 		return
 	}
 
 	if result.RowsAffected == 0 {
-		fmt.Printf("❌ No rows updated - possible race condition\n")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to verify email - no changes made",
-		})
+		apierror.Abort(c, apierror.Internal("Failed to verify email - no changes made"))
 		return
 	}
 
-	fmt.Printf("✅ Email verified successfully for user: %s\n", user.Email)
+	slog.InfoContext(c.Request.Context(), "Email verified", "user_id", user.ID)
 
 	// Send verification success email
 	go func() {
 		fullName := user.FirstName + " " + user.LastName
-		fmt.Printf("📧 Sending verification success email to: %s\n", user.Email)
 		if err := email.SendVerificationSuccessEmail(user.Email, fullName); err != nil {
-			log.Printf("Failed to send verification success email: %v", err)
+			slog.Error("Failed to send verification success email", "user_id", user.ID, "error", err)
 		}
 	}()
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Email verified successfully! Your account is now active.",
+		"message": i18n.T(i18n.Locale(c), "Email verified successfully! Your account is now active."),
 		"user": gin.H{
 			"id":             user.ID,
 			"first_name":     user.FirstName,
@@ -228,99 +220,76 @@ func (h *UserHandler) VerifyEmail(c *gin.Context) {
 
 // ResendVerificationEmail allows users to request a new verification email
 func (h *UserHandler) ResendVerificationEmail(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	var request struct {
 		Email string `json:"email" binding:"required,email"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request: " + err.Error(),
-		})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
-	fmt.Printf("🔍 Resend verification request for email: %s\n", request.Email)
-
 	// Find user by email
 	var user models.User
-	if err := h.DB.Where("email = ?", request.Email).First(&user).Error; err != nil {
+	if err := db.Where("email = ?", request.Email).First(&user).Error; err != nil {
 		// Don't reveal if user exists or not for security
 		c.JSON(http.StatusOK, gin.H{
-			"message": "If the email exists, a verification link has been sent.",
+			"message": i18n.T(i18n.Locale(c), "If the email exists, a verification link has been sent."),
 		})
 		return
 	}
 
-	fmt.Printf("✅ User found: ID=%d, Email=%s, Verified=%t, VerificationToken='%s'\n",
-		user.ID, user.Email, user.EmailVerified, user.VerificationToken)
-
 	// Check if already verified
 	if user.EmailVerified {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Email is already verified",
-		})
+		apierror.Abort(c, apierror.BadRequest("Email is already verified"))
 		return
 	}
 
 	// Generate new verification token
 	newToken, err := utils.GenerateVerificationToken()
 	if err != nil {
-		fmt.Printf("❌ Failed to generate verification token: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to generate verification token",
-		})
+		apierror.Abort(c, apierror.Internal("Failed to generate verification token").Wrap(err))
 		return
 	}
 
-	fmt.Printf("✅ New verification token generated: %s\n", newToken)
-
 	// Update user with new token using direct SQL to handle empty string case
-	verificationSentAt := time.Now()
+	verificationSentAt := clock.Now()
 
 	// Use raw SQL update to avoid any ORM issues with empty strings
-	result := h.DB.Exec(`
+	result := db.Exec(`
 		UPDATE users 
 		SET verification_token = ?, verification_sent_at = ? 
 		WHERE id = ? AND email = ?
 	`, newToken, verificationSentAt, user.ID, user.Email)
 
 	if result.Error != nil {
-		fmt.Printf("❌ Failed to update user with new token: %v\n", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to resend verification email: " + result.Error.Error(),
-		})
+		apierror.Abort(c, apierror.Internal("Failed to resend verification email").Wrap(result.Error))
 		return
 	}
 
 	if result.RowsAffected == 0 {
-		fmt.Printf("❌ No rows affected during update\n")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update user record",
-		})
+		apierror.Abort(c, apierror.Internal("Failed to update user record"))
 		return
 	}
 
-	fmt.Printf("✅ User updated with new verification token\n")
-
 	// Send new verification email
 	go func() {
 		fullName := user.FirstName + " " + user.LastName
-		fmt.Printf("📧 Sending verification email to: %s\n", user.Email)
 		if err := email.SendVerificationEmail(user.Email, fullName, newToken); err != nil {
-			log.Printf("❌ Failed to resend verification email: %v", err)
-		} else {
-			fmt.Printf("✅ Verification email sent successfully to: %s\n", user.Email)
+			slog.Error("Failed to resend verification email", "user_id", user.ID, "error", err)
 		}
 	}()
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Verification email sent successfully. Please check your email.",
+		"message": i18n.T(i18n.Locale(c), "Verification email sent successfully. Please check your email."),
 		"email":   user.Email,
 	})
 }
 
 // LoginUser handles user login
 func (h *UserHandler) LoginUser(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	var loginData struct {
 		Email    string `json:"email" binding:"required,email"`
 		Password string `json:"password" binding:"required"`
@@ -328,51 +297,72 @@ func (h *UserHandler) LoginUser(c *gin.Context) {
 
 	// Bind JSON input
 	if err := c.ShouldBindJSON(&loginData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid login data: " + err.Error(),
-		})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	// Find user by email
 	var user models.User
-	if err := h.DB.Where("email = ?", loginData.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Invalid email or password",
-		})
+	if err := db.Where("email = ? AND is_test_student = ?", loginData.Email, false).First(&user).Error; err != nil {
+		recordLoginAttempt(db, c, nil, loginData.Email, "unknown_email")
+		apierror.Abort(c, apierror.Unauthorized("Invalid email or password").WithCode(apierror.CodeInvalidCredentials))
+		return
+	}
+
+	// Locked accounts are refused whatever the password, so guessing can't go on during the lock
+	if user.LockedUntil != nil && user.LockedUntil.After(clock.Now()) {
+		recordLoginAttempt(db, c, &user.ID, user.Email, "locked")
+		apierror.Abort(c, apierror.New(http.StatusLocked, "Too many failed logins: this account is locked. Try again later or reset your password").
+			WithCode(apierror.CodeAccountLocked).With("locked_until", user.LockedUntil))
 		return
 	}
 
 	// Check if email is verified
 	if !user.EmailVerified {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":               "Please verify your email address before logging in",
-			"email_verified":      false,
-			"resend_verification": true,
-		})
+		recordLoginAttempt(db, c, &user.ID, user.Email, "unverified")
+		apierror.Abort(c, apierror.Unauthorized("Please verify your email address before logging in").WithCode(apierror.CodeEmailNotVerified).
+			With("email_verified", false).With("resend_verification", true))
 		return
 	}
 
 	// Check password
 	if err := user.CheckPassword(loginData.Password); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Invalid email or password",
-		})
+		h.loginFailed(c, user, "wrong_password", "Invalid email or password")
+		return
+	}
+
+	// With two-factor login the token is only issued once the code is checked as well, and failed
+	// counts are kept until then so wrong codes add up towards the lockout
+	if user.TwoFactorEnabled {
+		h.startTwoFactorLogin(c, user)
 		return
 	}
+	h.completeLogin(c, user)
+}
+
+// issueLoginToken clears the failed login count, records the login, keeps an account scheduled for deletion
+// and starts a session for the device, returning its JWT
+func (h *UserHandler) issueLoginToken(c *gin.Context, user models.User) (string, error) {
+	db := h.DB.WithContext(c.Request.Context())
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		db.Model(&user).Updates(map[string]interface{}{"failed_login_attempts": 0, "locked_until": nil})
+	}
+	recordLoginAttempt(db, c, &user.ID, user.Email, "")
+	cancelAccountDeletion(db, &user)
+	return startSession(db, c, user)
+}
 
-	// Generate JWT token
-	token, err := jwt.GenerateToken(user.ID, user.Email, user.Role)
+// completeLogin responds to a successful login with the JWT
+func (h *UserHandler) completeLogin(c *gin.Context, user models.User) {
+	token, err := h.issueLoginToken(c, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to generate token: " + err.Error(),
-		})
+		apierror.Abort(c, apierror.Internal("Failed to generate token").Wrap(err))
 		return
 	}
 
 	// Login successful
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
+		"message": i18n.T(i18n.Locale(c), "Login successful"),
 		"token":   token,
 		"user": gin.H{
 			"id":             user.ID,
@@ -388,15 +378,16 @@ func (h *UserHandler) LoginUser(c *gin.Context) {
 
 // GetProfile returns the authenticated user's profile
 func (h *UserHandler) GetProfile(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	var user models.User
-	if err := h.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := db.First(&user, userID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
 		return
 	}
 
@@ -406,6 +397,7 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 		"last_name":  user.LastName,
 		"email":      user.Email,
 		"phone":      user.Phone, // Include phone in response
+		"country":    user.Country,
 		"role":       user.Role,
 		"created_at": user.CreatedAt,
 	})
@@ -413,30 +405,23 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 
 // ValidateResetCode checks if a reset code is valid
 func (h *UserHandler) ValidateResetCode(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	code := c.Query("code")
 	if code == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Reset code is required",
-		})
+		apierror.Abort(c, apierror.BadRequest("Reset code is required"))
 		return
 	}
 
 	// Find user by reset code (stored in reset_token field)
 	var user models.User
-	if err := h.DB.Where("reset_token = ?", code).First(&user).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"valid": false,
-			"error": "Invalid reset code",
-		})
+	if err := db.Where("reset_token = ?", code).First(&user).Error; err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid reset code").With("valid", false))
 		return
 	}
 
 	// Check if code is expired
 	if utils.IsResetTokenExpired(user.ResetExpiresAt) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"valid": false,
-			"error": "Reset code has expired",
-		})
+		apierror.Abort(c, apierror.BadRequest("Reset code has expired").With("valid", false))
 		return
 	}
 
@@ -452,51 +437,51 @@ func (h *UserHandler) ValidateResetCode(c *gin.Context) {
 
 // UpdateProfile updates the authenticated user's profile
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	var updateData struct {
-		FirstName       string `json:"first_name" binding:"omitempty"`
-		LastName        string `json:"last_name" binding:"omitempty"`
-		Phone           string `json:"phone" binding:"omitempty"` // Added phone field
-		Password        string `json:"password" binding:"omitempty,min=6"`
-		CurrentPassword string `json:"current_password" binding:"omitempty"` // Add current password field
+		FirstName       string  `json:"first_name" binding:"omitempty"`
+		LastName        string  `json:"last_name" binding:"omitempty"`
+		Phone           string  `json:"phone" binding:"omitempty"` // Added phone field
+		Country         *string `json:"country"`                   // ISO country code, "" to use the IP country
+		Currency        *string `json:"preferred_currency"`        // ISO currency code prices are also shown in, "" for none
+		Language        *string `json:"preferred_language"`        // locale of messages and emails, "" to follow Accept-Language
+		Password        string  `json:"password" binding:"omitempty,min=6"`
+		CurrentPassword string  `json:"current_password" binding:"omitempty"` // Add current password field
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid update data: " + err.Error()})
+		apierror.Abort(c, apierror.Bind(err))
 		return
 	}
 
 	var user models.User
-	if err := h.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := db.First(&user, userID).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
 		return
 	}
 
 	// If user is changing password, require current password verification
 	if updateData.Password != "" {
 		if updateData.CurrentPassword == "" {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Current password is required to change password",
-			})
+			apierror.Abort(c, apierror.BadRequest("Current password is required to change password"))
 			return
 		}
 
 		// Verify current password
 		if err := user.CheckPassword(updateData.CurrentPassword); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Current password is incorrect",
-			})
+			apierror.Abort(c, apierror.Unauthorized("Current password is incorrect"))
 			return
 		}
 
 		user.Password = updateData.Password
 		if err := user.HashPassword(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to secure password: " + err.Error()})
+			apierror.Abort(c, apierror.Internal("Failed to secure password").Wrap(err))
 			return
 		}
 	}
@@ -511,38 +496,84 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	if updateData.Phone != "" {
 		user.Phone = updateData.Phone // Update phone field
 	}
+	if updateData.Country != nil {
+		user.Country = ""
+		if *updateData.Country != "" {
+			country, ok := geo.Normalize(*updateData.Country)
+			if !ok {
+				apierror.Abort(c, apierror.BadRequest("country must be an ISO 3166-1 alpha-2 code"))
+				return
+			}
+			user.Country = country
+		}
+	}
+	if updateData.Currency != nil {
+		code := strings.ToUpper(strings.TrimSpace(*updateData.Currency))
+		if code != "" {
+			if !currencyCodePattern.MatchString(code) {
+				apierror.Abort(c, apierror.BadRequest("preferred_currency must be an ISO 4217 code"))
+				return
+			}
+			if _, err := fx.Rate(c.Request.Context(), settings.DefaultCurrency(), code); errors.Is(err, fx.ErrUnknownCurrency) {
+				apierror.Abort(c, apierror.BadRequest("Prices can't be shown in "+code))
+				return
+			}
+		}
+		user.PreferredCurrency = code
+	}
+	if updateData.Language != nil {
+		user.PreferredLanguage = ""
+		if *updateData.Language != "" {
+			locale, ok := i18n.Normalize(*updateData.Language)
+			if !ok {
+				apierror.Abort(c, apierror.Invalid(languageProblem("preferred_language")))
+				return
+			}
+			user.PreferredLanguage = locale
+		}
+		c.Set("locale", "") // answer in the new language
+	}
 
-	if err := h.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile: " + err.Error()})
+	if err := db.Save(&user).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update profile").Wrap(err))
 		return
 	}
+	if updateData.Password != "" {
+		if _, err := revokeSessions(db, user.ID, c.GetString("sessionID")); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to revoke sessions", "user_id", user.ID, "error", err)
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Profile updated successfully",
+		"message": i18n.T(i18n.Locale(c), "Profile updated successfully"),
 		"user": gin.H{
-			"id":         user.ID,
-			"first_name": user.FirstName,
-			"last_name":  user.LastName,
-			"email":      user.Email,
-			"phone":      user.Phone, // Include updated phone in response
-			"role":       user.Role,
+			"id":                 user.ID,
+			"first_name":         user.FirstName,
+			"last_name":          user.LastName,
+			"email":              user.Email,
+			"phone":              user.Phone, // Include updated phone in response
+			"country":            user.Country,
+			"preferred_currency": user.PreferredCurrency,
+			"preferred_language": user.PreferredLanguage,
+			"role":               user.Role,
 		},
 	})
 }
 
 // GetUserEnrollments returns all courses the user is enrolled in
 func (h *UserHandler) GetUserEnrollments(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+		apierror.Abort(c, apierror.Unauthorized("User ID not found in context"))
 		return
 	}
 
 	var enrollments []models.Enrollment
-	if err := h.DB.Preload("Course").Preload("Course.Instructor").
+	if err := db.Preload("Course").Preload("Course.Instructor", publicUserFields).
 		Where("user_id = ? AND is_active = ?", userID, true).
 		Find(&enrollments).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch enrollments"})
+		apierror.Abort(c, apierror.Internal("Failed to fetch enrollments"))
 		return
 	}
 
@@ -554,69 +585,58 @@ func (h *UserHandler) GetUserEnrollments(c *gin.Context) {
 
 // ForgotPassword handles password reset requests
 func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	var request struct {
 		Email string `json:"email" binding:"required,email"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Valid email address is required",
-		})
+		apierror.Abort(c, apierror.BadRequest("Valid email address is required"))
 		return
 	}
 
 	// Find user by email
 	var user models.User
-	if err := h.DB.Where("email = ?", request.Email).First(&user).Error; err != nil {
+	if err := db.Where("email = ?", request.Email).First(&user).Error; err != nil {
 		// Don't reveal if user exists for security
 		c.JSON(http.StatusOK, gin.H{
-			"message": "If the email exists, a password reset code has been sent.",
+			"message": i18n.T(i18n.Locale(c), "If the email exists, a password reset code has been sent."),
 		})
 		return
 	}
 
 	// Check if email is verified
 	if !user.EmailVerified {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Please verify your email address before resetting password",
-		})
+		apierror.Abort(c, apierror.BadRequest("Please verify your email address before resetting password"))
 		return
 	}
 
 	// Generate reset code (6-digit verification code)
 	resetCode, err := utils.GenerateVerificationCode()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to generate reset code",
-		})
+		apierror.Abort(c, apierror.Internal("Failed to generate reset code"))
 		return
 	}
 
 	// Set reset code and expiry
-	resetSentAt := time.Now()
+	resetSentAt := clock.Now()
 	resetExpiresAt := utils.CalculateResetExpiry()
 
 	// Store the verification code in reset_token field
 	// Use direct SQL update to avoid unique constraint issues with empty verification_token
-	result := h.DB.Exec(`
+	result := db.Exec(`
 		UPDATE users 
 		SET reset_token = ?, reset_sent_at = ?, reset_expires_at = ? 
 		WHERE id = ? AND email = ?
 	`, resetCode, resetSentAt, resetExpiresAt, user.ID, user.Email)
 
 	if result.Error != nil {
-		log.Printf("❌ Failed to update user reset token: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to process reset request",
-		})
+		apierror.Abort(c, apierror.Internal("Failed to process reset request").Wrap(result.Error))
 		return
 	}
 
 	if result.RowsAffected == 0 {
-		log.Printf("❌ No rows affected during reset token update")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update user record",
-		})
+		apierror.Abort(c, apierror.Internal("Failed to update user record"))
 		return
 	}
 
@@ -624,53 +644,46 @@ func (h *UserHandler) ForgotPassword(c *gin.Context) {
 	go func() {
 		fullName := user.FirstName + " " + user.LastName
 		if err := email.SendPasswordResetEmail(user.Email, fullName, resetCode); err != nil {
-			log.Printf("Failed to send password reset email: %v", err)
+			slog.Error("Failed to send password reset email", "user_id", user.ID, "error", err)
 		}
 	}()
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":    "Password reset code sent to your email",
+		"message":    i18n.T(i18n.Locale(c), "Password reset code sent to your email"),
 		"expires_in": "1 hour",
 	})
 }
 
 // ResetPassword handles password reset with verification code
 func (h *UserHandler) ResetPassword(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	var request struct {
 		Code     string `json:"code" binding:"required"`
 		Password string `json:"password" binding:"required,min=6"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Code and new password are required",
-		})
+		apierror.Abort(c, apierror.BadRequest("Code and new password are required"))
 		return
 	}
 
 	// Find user by reset code
 	var user models.User
-	if err := h.DB.Where("reset_token = ?", request.Code).First(&user).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid or expired reset code",
-		})
+	if err := db.Where("reset_token = ?", request.Code).First(&user).Error; err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid or expired reset code"))
 		return
 	}
 
 	// Check if code is expired
 	if utils.IsResetTokenExpired(user.ResetExpiresAt) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Reset code has expired. Please request a new one.",
-		})
+		apierror.Abort(c, apierror.BadRequest("Reset code has expired. Please request a new one."))
 		return
 	}
 
 	// Update password
 	user.Password = request.Password
 	if err := user.HashPassword(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to secure new password",
-		})
+		apierror.Abort(c, apierror.Internal("Failed to secure new password"))
 		return
 	}
 
@@ -679,23 +692,29 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 	user.ResetSentAt = nil
 	user.ResetExpiresAt = nil
 
-	if err := h.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to reset password",
-		})
+	// Whoever can reset the password owns the account, so a lockout no longer protects it
+	user.FailedLoginAttempts = 0
+	user.LockedUntil = nil
+
+	if err := db.Save(&user).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to reset password"))
 		return
 	}
+	// Whoever knew the old password may still be logged in
+	if _, err := revokeSessions(db, user.ID, ""); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to revoke sessions", "user_id", user.ID, "error", err)
+	}
 
 	// Send success notification email
 	go func() {
 		fullName := user.FirstName + " " + user.LastName
 		if err := email.SendPasswordResetSuccessEmail(user.Email, fullName); err != nil {
-			log.Printf("Failed to send password reset success email: %v", err)
+			slog.Error("Failed to send password reset success email", "user_id", user.ID, "error", err)
 		}
 	}()
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Password reset successfully! You can now login with your new password.",
+		"message": i18n.T(i18n.Locale(c), "Password reset successfully! You can now login with your new password."),
 		"user": gin.H{
 			"id":    user.ID,
 			"email": user.Email,
@@ -705,30 +724,23 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 
 // ValidateResetToken checks if a reset token is valid (kept for backward compatibility)
 func (h *UserHandler) ValidateResetToken(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
 	token := c.Query("token")
 	if token == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Reset token is required",
-		})
+		apierror.Abort(c, apierror.BadRequest("Reset token is required"))
 		return
 	}
 
 	// Find user by reset token
 	var user models.User
-	if err := h.DB.Where("reset_token = ?", token).First(&user).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"valid": false,
-			"error": "Invalid reset token",
-		})
+	if err := db.Where("reset_token = ?", token).First(&user).Error; err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid reset token").With("valid", false))
 		return
 	}
 
 	// Check if token is expired
 	if utils.IsResetTokenExpired(user.ResetExpiresAt) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"valid": false,
-			"error": "Reset token has expired",
-		})
+		apierror.Abort(c, apierror.BadRequest("Reset token has expired").With("valid", false))
 		return
 	}
 
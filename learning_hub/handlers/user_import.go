@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/validation"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxImportedUsers  = 1000
+	MaxUserImportSize = 2 << 20 // of the CSV
+	invitationTTL     = 7 * 24 * time.Hour
+)
+
+// importedUser is a row of a user import
+type importedUser struct {
+	Line      int
+	FirstName string
+	LastName  string
+	Email     string
+	Role      string
+	Phone     string
+}
+
+// importRowResult reports what happened to one row of a user import
+type importRowResult struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email"`
+	Status string `json:"status"` // created or error
+	UserID uint   `json:"user_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// readUserImport reads the CSV of a user import, sent as the body (text/csv) or as the file field of a
+// form. It needs first_name, last_name and email columns; role (student or instructor, default student)
+// and phone are optional.
+func readUserImport(c *gin.Context) ([]importedUser, error) {
+	var body io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, errors.New("upload the CSV as the file field")
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		body = file
+	} else if c.ContentType() != "text/csv" {
+		return nil, errors.New("send the CSV as text/csv or as the file field of a form")
+	}
+
+	reader := csv.NewReader(io.LimitReader(body, MaxUserImportSize))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV is empty")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"first_name", "last_name", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV needs a %s column", required)
+		}
+	}
+
+	var users []importedUser
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		user := importedUser{
+			Line:      line,
+			FirstName: field("first_name"),
+			LastName:  field("last_name"),
+			Email:     field("email"),
+			Role:      strings.ToLower(field("role")),
+			Phone:     field("phone"),
+		}
+		if user == (importedUser{Line: line}) {
+			continue // blank line
+		}
+		if user.Role == "" {
+			user.Role = "student"
+		}
+		users = append(users, user)
+	}
+	if len(users) == 0 {
+		return nil, errors.New("CSV has no users")
+	}
+	return users, nil
+}
+
+// checkImportedUser returns why a row can't be imported, or ""
+func checkImportedUser(user importedUser) string {
+	switch {
+	case user.FirstName == "" || user.LastName == "":
+		return "first_name and last_name are required"
+	case len(user.FirstName) > 100 || len(user.LastName) > 100:
+		return "names are at most 100 characters"
+	case !validation.IsEmailFormat(user.Email) || len(user.Email) > 100:
+		return "email is not a valid address"
+	case user.Role != "student" && user.Role != "instructor":
+		return "role must be student or instructor"
+	case len(user.Phone) > 20:
+		return "phone is at most 20 characters"
+	}
+	return ""
+}
+
+// inviteUser gives a user a new invitation link, valid for 7 days, and returns its token
+func inviteUser(db *gorm.DB, user *models.User, invitedBy uint) (string, error) {
+	token, err := idgen.Token("invite_", 32)
+	if err != nil {
+		return "", err
+	}
+	now := clock.Now()
+	user.InvitationToken, user.InvitationSentAt, user.InvitedByID = &token, &now, &invitedBy
+	return token, db.Model(user).Updates(map[string]interface{}{
+		"invitation_token":   token,
+		"invitation_sent_at": now,
+		"invited_by_id":      invitedBy,
+	}).Error
+}
+
+// ImportUsers creates accounts from a CSV (see readUserImport) and emails each user an invitation to set
+// their password. Rows are imported independently: the report says for each whether it was created or
+// why not, e.g. because the email already has an account.
+func (h *AdminHandler) ImportUsers(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	rows, err := readUserImport(c)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	if len(rows) > maxImportedUsers {
+		apierror.Abort(c, apierror.BadRequest(fmt.Sprintf("Import at most %d users at a time", maxImportedUsers)))
+		return
+	}
+
+	adminID := c.MustGet("userID").(uint)
+	var admin models.User
+	db.Select("id, first_name, last_name").First(&admin, adminID)
+	invitedBy := strings.TrimSpace(admin.FirstName + " " + admin.LastName)
+
+	type invitation struct {
+		user  models.User
+		token string
+	}
+	var invitations []invitation
+	results := make([]importRowResult, len(rows))
+	seen := map[string]bool{}
+	created := 0
+	for i, row := range rows {
+		results[i] = importRowResult{Line: row.Line, Email: row.Email, Status: "error"}
+		if problem := checkImportedUser(row); problem != "" {
+			results[i].Error = problem
+			continue
+		}
+		key := strings.ToLower(row.Email)
+		if seen[key] {
+			results[i].Error = "email appears earlier in the CSV"
+			continue
+		}
+		seen[key] = true
+		taken, err := emailTaken(db, row.Email, 0)
+		if err != nil {
+			results[i].Error = "failed to check the email"
+			continue
+		}
+		if taken {
+			results[i].Error = "email already has an account"
+			continue
+		}
+
+		// The password is never told; the user sets one with the invitation
+		password, err := idgen.Token("", 32)
+		if err != nil {
+			results[i].Error = "failed to create the account"
+			continue
+		}
+		user := models.User{
+			FirstName: row.FirstName,
+			LastName:  row.LastName,
+			Email:     row.Email,
+			Phone:     row.Phone,
+			Password:  password,
+			Role:      row.Role,
+		}
+		var token string
+		err = user.HashPassword()
+		if err == nil {
+			err = db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+				token, err = inviteUser(tx, &user, adminID)
+				return err
+			})
+		}
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to import user", "email", row.Email, "error", err)
+			results[i].Error = "failed to create the account"
+			continue
+		}
+		results[i].Status, results[i].UserID = "created", user.ID
+		invitations = append(invitations, invitation{user, token})
+		created++
+	}
+
+	// One after another, so a large cohort doesn't open hundreds of SMTP connections at once
+	go func() {
+		for _, invite := range invitations {
+			name := invite.user.FirstName + " " + invite.user.LastName
+			if err := email.SendInvitationEmail(invite.user.Email, name, invitedBy, invite.user.Role, invite.token); err != nil {
+				slog.Error("Failed to send invitation", "user_id", invite.user.ID, "error", err)
+			}
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": i18n.T(i18n.Locale(c), "Created %d of %d users; invitations are being sent", created, len(rows)),
+		"created": created,
+		"failed":  len(rows) - created,
+		"results": results,
+	})
+}
+
+// ResendInvitation sends an imported user who hasn't set their password yet a new invitation link
+func (h *AdminHandler) ResendInvitation(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var user models.User
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("User not found"))
+		return
+	}
+	if user.InvitationSentAt == nil {
+		apierror.Abort(c, apierror.BadRequest("This user wasn't invited or already accepted the invitation"))
+		return
+	}
+	adminID := c.MustGet("userID").(uint)
+	token, err := inviteUser(db, &user, adminID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to create the invitation"))
+		return
+	}
+
+	var admin models.User
+	db.Select("id, first_name, last_name").First(&admin, adminID)
+	go func() {
+		name := user.FirstName + " " + user.LastName
+		if err := email.SendInvitationEmail(user.Email, name, admin.FirstName+" "+admin.LastName, user.Role, token); err != nil {
+			slog.Error("Failed to send invitation", "user_id", user.ID, "error", err)
+		}
+	}()
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Invitation sent to %s", user.Email)})
+}
+
+// AcceptInvitation sets the password of an invited user ({"token", "password"}) and logs them in. The
+// link reached them by email, so their address is verified too.
+func (h *UserHandler) AcceptInvitation(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, apierror.BadRequest("Token and a password of at least 6 characters are required"))
+		return
+	}
+
+	var user models.User
+	if err := db.Where("invitation_token = ?", input.Token).First(&user).Error; err != nil {
+		apierror.Abort(c, apierror.BadRequest("Invalid or expired invitation"))
+		return
+	}
+	if user.InvitationSentAt == nil || clock.Since(*user.InvitationSentAt) > invitationTTL {
+		apierror.Abort(c, apierror.BadRequest("This invitation has expired. Ask your administrator for a new one."))
+		return
+	}
+
+	user.Password = input.Password
+	if err := user.HashPassword(); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to secure password"))
+		return
+	}
+	result := db.Model(&user).Where("invitation_token = ?", input.Token).Updates(map[string]interface{}{
+		"password":           user.Password,
+		"email_verified":     true,
+		"invitation_token":   nil,
+		"invitation_sent_at": nil,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		apierror.Abort(c, apierror.Internal("Failed to accept the invitation"))
+		return
+	}
+	user.EmailVerified = true
+	h.completeLogin(c, user)
+}
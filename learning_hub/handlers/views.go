@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"learning_hub/models"
+
+	"gorm.io/gorm"
+)
+
+// Listings embed users and courses as these briefs, loaded with only their columns, rather than whole
+// models: a preloaded models.User carries tokens and contact details, and its zero-valued fields would
+// be answered as if they were the user's.
+
+type userBrief struct {
+	ID        uint   `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email,omitempty"`
+}
+
+type courseBrief struct {
+	ID           uint       `json:"id"`
+	Title        string     `json:"title"`
+	ThumbnailURL string     `json:"thumbnail_url,omitempty"`
+	InstructorID uint       `json:"instructor_id"`
+	Instructor   *userBrief `json:"instructor,omitempty"`
+}
+
+// publicUserFields loads the fields of a user anyone may see, deleted users included
+func publicUserFields(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Select("id, first_name, last_name")
+}
+
+// contactUserFields also loads the email, for admins and the course pages of instructors
+func contactUserFields(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Select("id, first_name, last_name, email")
+}
+
+// courseBriefFields loads the fields of a courseBrief, deleted courses included
+func courseBriefFields(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Select("id, title, thumbnail_url, instructor_id")
+}
+
+// newUserBrief returns nil for a user that wasn't loaded
+func newUserBrief(user models.User) *userBrief {
+	if user.ID == 0 {
+		return nil
+	}
+	return &userBrief{ID: user.ID, FirstName: user.FirstName, LastName: user.LastName, Email: user.Email}
+}
+
+// newCourseBrief returns nil for a course that wasn't loaded
+func newCourseBrief(course models.Course) *courseBrief {
+	if course.ID == 0 {
+		return nil
+	}
+	return &courseBrief{
+		ID:           course.ID,
+		Title:        course.Title,
+		ThumbnailURL: course.ThumbnailURL,
+		InstructorID: course.InstructorID,
+		Instructor:   newUserBrief(course.Instructor),
+	}
+}
+
+// paymentView is a payment with its user and course as briefs
+type paymentView struct {
+	models.Payment
+	User   *userBrief   `json:"user,omitempty"`
+	Course *courseBrief `json:"course,omitempty"`
+}
+
+func newPaymentViews(payments []models.Payment) []paymentView {
+	views := make([]paymentView, len(payments))
+	for i, payment := range payments {
+		views[i] = paymentView{Payment: payment, User: newUserBrief(payment.User), Course: newCourseBrief(payment.Course)}
+	}
+	return views
+}
+
+// enrollmentView is an enrollment with its user and course as briefs
+type enrollmentView struct {
+	models.Enrollment
+	User   *userBrief   `json:"user,omitempty"`
+	Course *courseBrief `json:"course,omitempty"`
+}
+
+func newEnrollmentViews(enrollments []models.Enrollment) []enrollmentView {
+	views := make([]enrollmentView, len(enrollments))
+	for i, enrollment := range enrollments {
+		views[i] = enrollmentView{Enrollment: enrollment, User: newUserBrief(enrollment.User), Course: newCourseBrief(enrollment.Course)}
+	}
+	return views
+}
+
+// certificateView is a certificate with the user and course of its enrollment as briefs
+type certificateView struct {
+	models.Certificate
+	Enrollment enrollmentView `json:"enrollment"`
+}
+
+func newCertificateView(certificate models.Certificate) certificateView {
+	enrollment := certificate.Enrollment
+	return certificateView{
+		Certificate: certificate,
+		Enrollment:  enrollmentView{Enrollment: enrollment, User: newUserBrief(enrollment.User), Course: newCourseBrief(enrollment.Course)},
+	}
+}
+
+// fileAccessView is a download of a protected file with its user as a brief
+type fileAccessView struct {
+	models.FileAccessLog
+	User *userBrief `json:"user,omitempty"`
+}
+
+// quizAttemptView is an attempt at a quiz with its student as a brief
+type quizAttemptView struct {
+	models.QuizAttempt
+	User *userBrief `json:"user,omitempty"`
+}
+
+// submissionView is a submission of an assignment with its student as a brief
+type submissionView struct {
+	models.AssignmentSubmission
+	User *userBrief `json:"user,omitempty"`
+}
+
+// moderationItemView is held content with its author as a brief
+type moderationItemView struct {
+	models.ModerationItem
+	User *userBrief `json:"user,omitempty"`
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/chapa"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/stripe"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxWebhookBodySize caps the webhook bodies read and stored
+const maxWebhookBodySize = 1 << 20
+
+// webhookSecretHeaders are request headers not stored with webhook events
+var webhookSecretHeaders = map[string]bool{"Authorization": true, "Cookie": true}
+
+// webhookResult is how processing a webhook went, and the answer to the provider
+type webhookResult struct {
+	Code    int
+	Body    gin.H
+	Err     *apierror.Error // why processing failed, answered instead of Body
+	Ignored bool            // an event we don't act on
+}
+
+// webhookFailed is the result of a webhook that could not be processed
+func webhookFailed(err *apierror.Error) webhookResult {
+	return webhookResult{Code: err.Status, Err: err}
+}
+
+func readWebhookBody(c *gin.Context) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+}
+
+// webhookHeaders returns the request headers to keep with its webhook event
+func webhookHeaders(c *gin.Context) models.JSON {
+	headers := make(map[string]string, len(c.Request.Header))
+	for name, values := range c.Request.Header {
+		if !webhookSecretHeaders[name] {
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+	data, _ := json.Marshal(headers)
+	return models.JSON(data)
+}
+
+// receiveWebhook logs a webhook before it is processed. An event already processed is acknowledged right
+// away and true returned; one that failed before is processed again.
+func (h *PaymentHandler) receiveWebhook(c *gin.Context, provider, eventID, eventType, txRef string, body []byte) (*models.WebhookEvent, bool) {
+	db := h.db.WithContext(c.Request.Context())
+	event := models.WebhookEvent{
+		Provider:   provider,
+		EventID:    truncate(eventID, 255),
+		EventType:  truncate(eventType, 100),
+		TxRef:      truncate(txRef, 100),
+		Headers:    webhookHeaders(c),
+		Body:       string(body),
+		Status:     models.WebhookReceived,
+		ReceivedAt: clock.Now(),
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+	if result.Error != nil {
+		// Processing goes on unlogged rather than losing the payment
+		slog.ErrorContext(c.Request.Context(), "Failed to log webhook", "provider", provider, "event_id", eventID, "error", result.Error)
+		return nil, false
+	}
+	if result.RowsAffected == 0 {
+		if err := db.Where("provider = ? AND event_id = ?", provider, event.EventID).First(&event).Error; err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to load webhook", "provider", provider, "event_id", eventID, "error", err)
+			return nil, false
+		}
+		if event.Status == models.WebhookProcessed || event.Status == models.WebhookIgnored {
+			c.JSON(http.StatusOK, gin.H{"status": "event already processed"})
+			return nil, true
+		}
+	}
+	return &event, false
+}
+
+// recordWebhookResult stores how processing a logged webhook went
+func recordWebhookResult(db *gorm.DB, event *models.WebhookEvent, result webhookResult) {
+	if event == nil {
+		return
+	}
+	now := clock.Now()
+	event.Attempts++
+	event.ResponseCode = result.Code
+	event.ProcessedAt = &now
+	event.Error = ""
+	switch {
+	case result.Err != nil:
+		event.Status = models.WebhookFailed
+		event.Error = result.Err.Message
+	case result.Ignored:
+		event.Status = models.WebhookIgnored
+	default:
+		event.Status = models.WebhookProcessed
+	}
+	if err := db.Model(event).Select("attempts", "response_code", "processed_at", "error", "status").
+		Updates(event).Error; err != nil {
+		slog.Error("Failed to record webhook result", "webhook_event_id", event.ID, "error", err)
+	}
+}
+
+// finishWebhook records the result of processing a webhook and answers the provider with it
+func (h *PaymentHandler) finishWebhook(c *gin.Context, event *models.WebhookEvent, result webhookResult) {
+	db := h.db.WithContext(c.Request.Context())
+	recordWebhookResult(db, event, result)
+	if result.Err != nil {
+		apierror.Abort(c, result.Err)
+		return
+	}
+	c.JSON(result.Code, result.Body)
+}
+
+// rejectWebhook logs a webhook that won't be processed, as its signature or payload is invalid, and answers 400
+func (h *PaymentHandler) rejectWebhook(c *gin.Context, provider string, body []byte, reason string) {
+	db := h.db.WithContext(c.Request.Context())
+	sum := sha256.Sum256(body)
+	now := clock.Now()
+	event := models.WebhookEvent{
+		Provider:     provider,
+		EventID:      "rejected:" + hex.EncodeToString(sum[:]),
+		Headers:      webhookHeaders(c),
+		Body:         string(body),
+		Status:       models.WebhookRejected,
+		ResponseCode: http.StatusBadRequest,
+		Error:        reason,
+		ReceivedAt:   now,
+		ProcessedAt:  &now,
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&event).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to log rejected webhook", "provider", provider, "error", err)
+	}
+	apierror.Abort(c, apierror.BadRequest(reason))
+}
+
+type WebhookEventHandler struct {
+	DB *gorm.DB
+}
+
+func NewWebhookEventHandler(db *gorm.DB) *WebhookEventHandler {
+	return &WebhookEventHandler{DB: db}
+}
+
+// GetWebhookEvents lists received payment webhooks, newest first, without their bodies (?provider=,
+// ?status=, ?tx_ref=, ?page=)
+func (h *WebhookEventHandler) GetWebhookEvents(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	const pageSize = 50
+
+	query := db.Model(&models.WebhookEvent{})
+	if provider := c.Query("provider"); provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if txRef := c.Query("tx_ref"); txRef != "" {
+		query = query.Where("tx_ref = ?", txRef)
+	}
+	var total int64
+	query.Count(&total)
+
+	var events []models.WebhookEvent
+	if err := query.Omit("headers", "body").Order("received_at DESC, id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&events).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch webhook events"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"page":   page,
+	})
+}
+
+// GetWebhookEvent returns a webhook event with its raw body and headers
+func (h *WebhookEventHandler) GetWebhookEvent(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var event models.WebhookEvent
+	if err := db.First(&event, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Webhook event not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"event": event})
+}
+
+// ReplayWebhookEvent processes a failed webhook event again from its stored body, e.g. once the payment it
+// was about exists or the database is back. Rejected events can't be replayed.
+func (h *WebhookEventHandler) ReplayWebhookEvent(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var event models.WebhookEvent
+	if err := db.First(&event, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Webhook event not found"))
+		return
+	}
+	if event.Status != models.WebhookFailed && event.Status != models.WebhookReceived {
+		apierror.Abort(c, apierror.Conflict("Only failed webhook events can be replayed, this one is "+event.Status))
+		return
+	}
+
+	payments := &PaymentHandler{db: db}
+	result, err := replayWebhook(c, payments, event)
+	if err != nil {
+		apierror.Abort(c, apierror.New(http.StatusUnprocessableEntity, err.Error()))
+		return
+	}
+	previous := event.Status
+	recordWebhookResult(db, &event, result)
+	recordAudit(db, c, models.AuditWebhookReplay, "webhook_event", event.ID,
+		gin.H{"status": previous},
+		gin.H{"status": event.Status, "response_code": event.ResponseCode, "error": event.Error})
+
+	body := result.Body
+	if result.Err != nil {
+		body = result.Err.Body("")
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"event":  event,
+		"result": body,
+	})
+}
+
+// replayWebhook decodes a stored webhook body and processes it like its provider's handler would
+func replayWebhook(c *gin.Context, payments *PaymentHandler, event models.WebhookEvent) (webhookResult, error) {
+	switch event.Provider {
+	case providerChapa:
+		var payload chapa.WebhookPayload
+		if err := json.Unmarshal([]byte(event.Body), &payload); err != nil {
+			return webhookResult{}, errors.New("the stored body is not a Chapa webhook")
+		}
+		return payments.processChapaWebhook(c.Request.Context(), payload), nil
+	case providerStripe:
+		// The signature was checked when the event arrived
+		var stripeEvent stripe.Event
+		if err := json.Unmarshal([]byte(event.Body), &stripeEvent); err != nil {
+			return webhookResult{}, errors.New("the stored body is not a Stripe event")
+		}
+		return payments.processStripeEvent(c.Request.Context(), stripeEvent), nil
+	}
+	return webhookResult{}, errors.New("unknown webhook provider " + event.Provider)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/currency"
+	"learning_hub/pkg/email"
+	"learning_hub/pkg/i18n"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WishlistHandler struct {
+	DB *gorm.DB
+}
+
+func NewWishlistHandler(db *gorm.DB) *WishlistHandler {
+	return &WishlistHandler{DB: db}
+}
+
+// notifyWishlisters tells everyone who wishlisted a course and has not enrolled yet about news of it,
+// in the app and by email, in each user's language; meant to run in a goroutine
+func notifyWishlisters(db *gorm.DB, course models.Course, news func(locale string) (headline, message string)) {
+	var users []models.User
+	if err := db.Model(&models.User{}).Select("users.id, users.first_name, users.email, users.preferred_language").
+		Joins("JOIN wishlists ON wishlists.user_id = users.id").
+		Where("wishlists.course_id = ?", course.ID).
+		Where("NOT EXISTS (SELECT 1 FROM enrollments WHERE enrollments.user_id = users.id AND enrollments.course_id = ? AND enrollments.is_active = ?)", course.ID, true).
+		Find(&users).Error; err != nil {
+		slog.Error("Failed to load wishlists", "course_id", course.ID, "error", err)
+		return
+	}
+
+	for _, user := range users {
+		headline, message := news(userLocale(user))
+		notifyUser(db, user.ID, models.NotificationWishlist, headline, gin.H{
+			"course_id": course.ID,
+			"title":     course.Title,
+			"price":     course.Price,
+			"message":   message,
+		})
+		if err := email.SendWishlistEmail(user.Email, user.FirstName, course.Title, course.ID, headline, message); err != nil {
+			slog.Error("Failed to email wishlist news", "course_id", course.ID, "user_id", user.ID, "error", err)
+		}
+	}
+}
+
+// courseChanged notifies wishlisters when a course was published or its price went down. oldPrice is
+// the price before the update when the update set one, nil otherwise.
+func courseChanged(db *gorm.DB, course models.Course, wasPublished bool, oldPrice *float64) {
+	switch {
+	case !course.Published:
+		// Nothing to announce until students can enroll
+	case !wasPublished:
+		go notifyWishlisters(detached(db), course, func(locale string) (string, string) {
+			return i18n.T(locale, "%s is now available", course.Title),
+				i18n.T(locale, "A course on your wishlist has been published and is open for enrollment.")
+		})
+	case oldPrice != nil && course.Price < *oldPrice:
+		code, previous := courseCurrency(course), *oldPrice
+		go notifyWishlisters(detached(db), course, func(locale string) (string, string) {
+			price := currency.FormatAmount(course.Price, code, "en")
+			if course.Price == 0 {
+				price = i18n.T(locale, "free")
+			}
+			return i18n.T(locale, "Price drop: %s is now %s", course.Title, price),
+				i18n.T(locale, "The price went down from %s to %s.", currency.FormatAmount(previous, code, "en"), price)
+		})
+	}
+}
+
+// AddToWishlist saves a course to the caller's wishlist
+func (h *WishlistHandler) AddToWishlist(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	var course models.Course
+	if err := db.First(&course, c.Param("id")).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Course not found"))
+		return
+	}
+	userID := c.MustGet("userID").(uint)
+
+	var enrolled int64
+	db.Model(&models.Enrollment{}).
+		Where("user_id = ? AND course_id = ? AND is_active = ?", userID, course.ID, true).
+		Count(&enrolled)
+	if enrolled > 0 {
+		apierror.Abort(c, apierror.Conflict("You are already enrolled in this course"))
+		return
+	}
+
+	item := models.Wishlist{}
+	result := db.Where(models.Wishlist{UserID: userID, CourseID: course.ID}).
+		Attrs(models.Wishlist{PriceWhenAdded: course.Price, CreatedAt: clock.Now()}).
+		FirstOrCreate(&item)
+	if result.Error != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update wishlist"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  i18n.T(i18n.Locale(c), "Course added to your wishlist"),
+		"wishlist": item,
+	})
+}
+
+// RemoveFromWishlist takes a course off the caller's wishlist
+func (h *WishlistHandler) RemoveFromWishlist(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	result := db.Where("user_id = ? AND course_id = ?", c.MustGet("userID"), c.Param("id")).
+		Delete(&models.Wishlist{})
+	if result.Error != nil {
+		apierror.Abort(c, apierror.Internal("Failed to update wishlist"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Abort(c, apierror.NotFound("Course is not on your wishlist"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(i18n.Locale(c), "Course removed from your wishlist")})
+}
+
+// GetMyWishlist lists the caller's wishlisted courses, newest first, with their price in the caller's
+// country and whether it dropped since they were added
+func (h *WishlistHandler) GetMyWishlist(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
+	var items []models.Wishlist
+	if err := db.Preload("Course.Instructor", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, first_name, last_name")
+	}).Joins("JOIN courses ON courses.id = wishlists.course_id AND courses.deleted_at IS NULL").
+		Where("wishlists.user_id = ?", userID).
+		Order("wishlists.created_at DESC").
+		Find(&items).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch wishlist"))
+		return
+	}
+
+	country := requestCountry(c, db)
+	courses := make([]models.Course, len(items))
+	for i, item := range items {
+		courses[i] = item.Course
+	}
+	if err := applyCountryPrices(db, courses, country); err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to fetch course prices"))
+		return
+	}
+
+	var enrolled []uint
+	db.Model(&models.Enrollment{}).Where("user_id = ? AND is_active = ?", userID, true).Pluck("course_id", &enrolled)
+	enrolledIn := make(map[uint]bool, len(enrolled))
+	for _, id := range enrolled {
+		enrolledIn[id] = true
+	}
+
+	wishlist := make([]gin.H, 0, len(items))
+	for i, item := range items {
+		wishlist = append(wishlist, gin.H{
+			"course":           courses[i],
+			"added_at":         item.CreatedAt,
+			"price_when_added": item.PriceWhenAdded,
+			"price_dropped":    item.Course.Price < item.PriceWhenAdded,
+			"enrolled":         enrolledIn[item.CourseID],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"wishlist": wishlist,
+		"count":    len(wishlist),
+		"country":  country,
+	})
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"learning_hub/models"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Workload estimates
+const (
+	readingWordsPerMinute  = 230 // typical adult reading speed of instructional text
+	minutesPerQuizQuestion = 1   // for quizzes without a time limit
+)
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// readingMinutes estimates how long reading lesson content (HTML, Markdown or plain text) takes
+func readingMinutes(content string) int {
+	words := len(strings.Fields(htmlTagPattern.ReplaceAllString(content, " ")))
+	if words == 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(words) / readingWordsPerMinute))
+}
+
+// lessonEffort is the estimated minutes of a lesson: its videos plus reading its text, with its loaded blocks
+func lessonEffort(lesson models.Lesson) int {
+	effort := lesson.Duration + readingMinutes(lesson.Content)
+	for _, block := range lesson.Blocks {
+		effort += block.Duration + readingMinutes(block.Text)
+	}
+	return effort
+}
+
+// quizEffort is the time limit of a quiz, or an estimate from its questions
+func quizEffort(timeLimit, questions int) int {
+	if timeLimit > 0 {
+		return timeLimit
+	}
+	return questions * minutesPerQuizQuestion
+}
+
+// minutesToHours converts minutes to hours rounded to a tenth
+func minutesToHours(minutes int) float64 {
+	return math.Round(float64(minutes)/6) / 10
+}
+
+// quizEfforts returns the estimated minutes of each published quiz of a course
+func quizEfforts(db *gorm.DB, courseID uint) (map[uint]int, error) {
+	var quizzes []struct {
+		ID        uint
+		TimeLimit int
+		Questions int
+	}
+	if err := db.Model(&models.Quiz{}).
+		Select("quizzes.id, quizzes.time_limit, COUNT(quiz_questions.id) AS questions").
+		Joins("LEFT JOIN quiz_questions ON quiz_questions.quiz_id = quizzes.id AND quiz_questions.deleted_at IS NULL").
+		Where("quizzes.course_id = ? AND quizzes.is_published = ?", courseID, true).
+		Group("quizzes.id, quizzes.time_limit").
+		Scan(&quizzes).Error; err != nil {
+		return nil, err
+	}
+	efforts := make(map[uint]int, len(quizzes))
+	for _, quiz := range quizzes {
+		efforts[quiz.ID] = quizEffort(quiz.TimeLimit, quiz.Questions)
+	}
+	return efforts, nil
+}
+
+func refreshCourseWorkload(db *gorm.DB, courseID uint) {
+	if err := updateCourseWorkload(db, courseID); err != nil {
+		slog.Error("Failed to update course workload", "course_id", courseID, "error", err)
+	}
+}
+
+// updateCourseWorkload recomputes the estimated minutes of a course's lessons and its total workload
+func updateCourseWorkload(db *gorm.DB, courseID uint) error {
+	var lessons []models.Lesson
+	if err := db.Preload("Blocks", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, lesson_id, text, duration")
+	}).Select("lessons.id, lessons.content, lessons.duration, lessons.estimated_minutes").
+		Joins("JOIN modules ON modules.id = lessons.module_id AND modules.deleted_at IS NULL").
+		Where("modules.course_id = ?", courseID).
+		Find(&lessons).Error; err != nil {
+		return err
+	}
+
+	total := 0
+	for _, lesson := range lessons {
+		effort := lessonEffort(lesson)
+		total += effort
+		if effort != lesson.EstimatedMinutes {
+			if err := db.Model(&lesson).UpdateColumn("estimated_minutes", effort).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	quizzes, err := quizEfforts(db, courseID)
+	if err != nil {
+		return err
+	}
+	for _, effort := range quizzes {
+		total += effort
+	}
+	return db.Model(&models.Course{}).Where("id = ?", courseID).UpdateColumn("workload_hours", minutesToHours(total)).Error
+}
+
+type WorkloadHandler struct {
+	DB *gorm.DB
+}
+
+func NewWorkloadHandler(db *gorm.DB) *WorkloadHandler {
+	return &WorkloadHandler{DB: db}
+}
+
+// RefreshCourseWorkloads recomputes the workload of every course, so courses created before workloads
+// were tracked get one. Runs as a scheduled job.
+func (h *WorkloadHandler) RefreshCourseWorkloads(ctx context.Context) error {
+	db := h.DB.WithContext(ctx)
+	var ids []uint
+	if err := db.Model(&models.Course{}).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := updateCourseWorkload(db, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCoursePace compares the student's remaining workload with their goal: ?hours_per_week= gives the
+// expected finish date, ?target_date=YYYY-MM-DD the weekly hours needed to finish by then
+func (h *WorkloadHandler) GetCoursePace(c *gin.Context) {
+	db := h.DB.WithContext(c.Request.Context())
+	userID := c.MustGet("userID").(uint)
+
+	var enrollment models.Enrollment
+	if err := db.Preload("Course").
+		Where("user_id = ? AND course_id = ? AND is_active = ?", userID, c.Param("id"), true).
+		First(&enrollment).Error; err != nil {
+		apierror.Abort(c, apierror.NotFound("Enrollment not found"))
+		return
+	}
+
+	var hoursPerWeek float64
+	if value := c.Query("hours_per_week"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 168 {
+			apierror.Abort(c, apierror.BadRequest("hours_per_week must be a number of hours from 0 to 168"))
+			return
+		}
+		hoursPerWeek = parsed
+	}
+	var targetDate *time.Time
+	if value := c.Query("target_date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("target_date must be a date like 2026-12-31"))
+			return
+		}
+		targetDate = &parsed
+	}
+
+	// Remaining: lessons not completed yet and published quizzes without a passing attempt
+	var remainingLessons int
+	if err := db.Model(&models.Lesson{}).Select("COALESCE(SUM(lessons.estimated_minutes), 0)").
+		Joins("JOIN modules ON modules.id = lessons.module_id AND modules.deleted_at IS NULL").
+		Where("modules.course_id = ?", enrollment.CourseID).
+		Where("NOT EXISTS (SELECT 1 FROM lesson_progresses WHERE lesson_progresses.lesson_id = lessons.id AND lesson_progresses.user_id = ? AND lesson_progresses.completed AND lesson_progresses.deleted_at IS NULL)", userID).
+		Scan(&remainingLessons).Error; err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to calculate remaining workload"))
+		return
+	}
+	quizzes, err := quizEfforts(db, enrollment.CourseID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("Failed to calculate remaining workload"))
+		return
+	}
+	var passed []uint
+	db.Model(&models.QuizAttempt{}).Where("user_id = ? AND is_passed = ?", userID, true).Distinct().Pluck("quiz_id", &passed)
+	for _, id := range passed {
+		delete(quizzes, id)
+	}
+	remaining := remainingLessons
+	for _, effort := range quizzes {
+		remaining += effort
+	}
+
+	remainingHours := minutesToHours(remaining)
+	response := gin.H{
+		"course_id":       enrollment.CourseID,
+		"workload_hours":  enrollment.Course.WorkloadHours,
+		"remaining_hours": remainingHours,
+		"progress":        enrollment.Progress,
+	}
+
+	today := clock.Now().Truncate(24 * time.Hour)
+	if hoursPerWeek > 0 {
+		weeks := float64(remaining) / 60 / hoursPerWeek
+		response["hours_per_week"] = hoursPerWeek
+		response["weeks_to_finish"] = math.Round(weeks*10) / 10
+		response["expected_finish_date"] = today.AddDate(0, 0, int(math.Ceil(weeks*7))).Format("2006-01-02")
+	}
+	if targetDate != nil {
+		days := targetDate.Sub(today).Hours() / 24
+		if days < 1 {
+			apierror.Abort(c, apierror.BadRequest("target_date must be in the future"))
+			return
+		}
+		response["target_date"] = targetDate.Format("2006-01-02")
+		response["required_hours_per_week"] = math.Round(float64(remaining)/60/(days/7)*10) / 10
+	}
+
+	c.JSON(http.StatusOK, response)
+}
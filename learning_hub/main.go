@@ -1,121 +1,293 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"learning_hub/handlers"
 	"learning_hub/middleware"
 	"learning_hub/models"
+	"learning_hub/pkg/cache"
 	"learning_hub/pkg/chapa"
+	"learning_hub/pkg/clock"
 	"learning_hub/pkg/config"
+	"learning_hub/pkg/dbcache"
+	"learning_hub/pkg/dbmetrics"
+	"learning_hub/pkg/dbtimeout"
+	"learning_hub/pkg/dbtracing"
 	"learning_hub/pkg/email"
 	"learning_hub/pkg/fileupload"
+	"learning_hub/pkg/fx"
+	"learning_hub/pkg/geo"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/idgen"
+	"learning_hub/pkg/jobs"
+	"learning_hub/pkg/logger"
+	"learning_hub/pkg/mediaurl"
+	"learning_hub/pkg/meeting"
+	"learning_hub/pkg/oauth"
+	"learning_hub/pkg/realtime"
+	"learning_hub/pkg/sandbox"
+	"learning_hub/pkg/settings"
+	"learning_hub/pkg/slo"
+	"learning_hub/pkg/spam"
+	"learning_hub/pkg/stripe"
+	"learning_hub/pkg/tracing"
 	"learning_hub/pkg/validation"
-	"log"
+	"learning_hub/pkg/youtube"
+	"learning_hub/seed"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// setup loads the configuration, initializes the packages and connects to the database, for the
+// server and every command
+func setup() (*config.Config, *gorm.DB) {
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatal("Failed to load configuration:", err)
+		logger.Fatal("Failed to load configuration", "error", err)
+	}
+	logger.Init(cfg)
+	if err := tracing.Init(cfg); err != nil {
+		logger.Fatal("Failed to set up tracing", "error", err)
 	}
 	email.Init(cfg)
-
-	fmt.Printf("🚀 Starting LearnHub API in %s mode...\n", cfg.ServerEnv)
+	mediaurl.Init(cfg)
+	spam.Init(cfg)
+	geo.Init(cfg)
+	sandbox.Init(cfg)
+	youtube.Init(cfg)
+	meeting.Init(cfg)
+	oauth.Init(cfg)
+	stripe.Init(cfg)
+	fx.Init(cfg)
 
 	// Initialize file upload with config
-	fileupload.Init(cfg)
+	if err := fileupload.Init(cfg); err != nil {
+		logger.Fatal("Failed to initialize file storage", "error", err)
+	}
 
 	// Initialize Chapa
 	if err := chapa.Init(cfg); err != nil {
-		log.Fatal("Failed to initialize Chapa:", err)
+		logger.Fatal("Failed to initialize Chapa", "error", err)
 	}
-
-	// Test Chapa connection
-	if err := chapa.TestConnection(); err != nil {
-		log.Printf("Warning: Chapa connection test failed: %v", err)
-	} else {
-		fmt.Println("✅ Chapa connected successfully")
+	if err := cache.Init(cfg); err != nil {
+		logger.Fatal("Failed to initialize the cache", "error", err)
 	}
 
 	// Database connection using config
 	db, err := gorm.Open(postgres.Open(cfg.GetDBDSN()), &gorm.Config{})
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logger.Fatal("Failed to connect to database", "error", err)
+	}
+	// Statements that run too long are cancelled instead of holding a pooled connection
+	if err := db.Use(dbtimeout.New(cfg.DBQueryTimeout)); err != nil {
+		logger.Fatal("Failed to set up database timeouts", "error", err)
+	}
+	if err := db.Use(dbmetrics.New()); err != nil {
+		logger.Fatal("Failed to set up database metrics", "error", err)
+	}
+	if err := db.Use(dbtracing.New()); err != nil {
+		logger.Fatal("Failed to set up database tracing", "error", err)
+	}
+	// Cached reads are dropped when the tables they come from are written
+	if err := db.Use(dbcache.New(handlers.CacheInvalidations)); err != nil {
+		logger.Fatal("Failed to set up cache invalidation", "error", err)
+	}
+
+	// Settings, and the preferences emails follow, are read from the database
+	settings.Init(cfg, handlers.NewSettingsHandler(db).Load)
+	userHandler := handlers.NewUserHandler(db, cfg)
+	email.SetRecipientFilter(handlers.NewNotificationPreferenceHandler(db).AllowEmail)
+	email.SetRecipientLanguage(userHandler.RecipientLanguage)
+	i18n.SetUserLanguage(userHandler.PreferredLanguage)
+	return cfg, db
+}
+
+// migrate creates and updates the tables of every model
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		return err
+	}
+	// Storage keys of uploaded files used to be unique; deduplicated uploads share them
+	if err := db.Exec("DROP INDEX IF EXISTS idx_uploaded_files_storage_key").Error; err != nil {
+		return err
+	}
+	// Quiz attempts are numbered per student and quiz, and no number is taken twice: attempts from before
+	// the numbering are numbered in the order they were started, then the index is created
+	if err := db.Exec(`UPDATE quiz_attempts SET attempt_number = numbered.n
+		FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, quiz_id ORDER BY started_at, id) AS n
+			FROM quiz_attempts WHERE deleted_at IS NULL) AS numbered
+		WHERE quiz_attempts.id = numbered.id AND quiz_attempts.attempt_number = 0`).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_quiz_attempt_number
+		ON quiz_attempts (user_id, quiz_id, attempt_number) WHERE deleted_at IS NULL`).Error; err != nil {
+		return err
+	}
+	slog.Info("Database migrations completed")
+	return nil
+}
+
+// serve migrates the database and serves the API until SIGTERM or SIGINT
+func serve(cfg *config.Config, db *gorm.DB) {
+	slog.Info("Starting LearnHub API", "env", cfg.ServerEnv, "cors_origins", cfg.CORSAllowedOrigins)
+
+	// Test Chapa connection
+	if err := chapa.TestConnection(context.Background()); err != nil {
+		slog.Warn("Chapa connection test failed", "error", err)
+	} else {
+		slog.Info("Chapa connected")
 	}
 
-	// Auto migrate models
-	if err := db.AutoMigrate(
-		&models.User{},
-		&models.Course{},
-		&models.Module{},
-		&models.Lesson{},
-		&models.Enrollment{},
-		&models.Payment{},
-		&models.LessonProgress{},
-		&models.Certificate{},
-		&models.Review{},
-		&models.Quiz{},
-		&models.QuizQuestion{},
-		&models.QuizAttempt{},
-		&models.QuizAnswer{},
-		&models.Assignment{},
-		&models.AssignmentSubmission{},
-	); err != nil {
-		log.Fatal("Migration failed:", err)
+	if err := migrate(db); err != nil {
+		logger.Fatal("Migration failed", "error", err)
 	}
-	fmt.Println("✅ Database migrations completed successfully")
 
 	// Initialize handlers
-	userHandler := handlers.NewUserHandler(db)
-	courseHandler := handlers.NewCourseHandler(db)
-	uploadHandler := handlers.NewUploadHandler(db)
+	userHandler := handlers.NewUserHandler(db, cfg)
+	courseHandler := handlers.NewCourseHandler(db, cfg)
+	courseImportHandler := handlers.NewCourseImportHandler(db)
+	uploadHandler := handlers.NewUploadHandler(db, cfg)
 	paymentHandler := handlers.NewPaymentHandler(db)
 	adminHandler := handlers.NewAdminHandler(db)
+	sessionHandler := handlers.NewSessionHandler(db)
+	middleware.CheckSessions(sessionHandler.Active)
+	middleware.RecordImpersonation(adminHandler.RecordImpersonatedRequest)
 	progressHandler := handlers.NewProgressHandler(db)
 	lessonHandler := handlers.NewLessonHandler(db)
-	assessmentHandler := handlers.NewAssessmentHandler(db)
+	assessmentHandler := handlers.NewAssessmentHandler(db, uploadHandler)
+	certificateHandler := handlers.NewCertificateHandler(db)
+	analyticsHandler := handlers.NewAnalyticsHandler(db)
+	cohortHandler := handlers.NewCohortHandler(db)
+	workloadHandler := handlers.NewWorkloadHandler(db)
+	trashHandler := handlers.NewTrashHandler(db)
+	transcodeHandler := handlers.NewTranscodeHandler(db, cfg)
+	reviewHandler := handlers.NewReviewHandler(db)
+	learningPathHandler := handlers.NewLearningPathHandler(db)
+	gradingHandler := handlers.NewGradingHandler(db)
+	availabilityHandler := handlers.NewAvailabilityHandler(db)
+	moderationHandler := handlers.NewModerationHandler(db)
+	publishChecklistHandler := handlers.NewPublishChecklistHandler(db)
+	paymentExportHandler := handlers.NewPaymentExportHandler(db)
+	reconciliationHandler := handlers.NewReconciliationHandler(db, cfg)
+	webhookEventHandler := handlers.NewWebhookEventHandler(db)
+	outboundWebhookHandler := handlers.NewOutboundWebhookHandler(db)
+	countryRuleHandler := handlers.NewCountryRuleHandler(db)
+	accessibilityHandler := handlers.NewAccessibilityHandler(db)
+	notificationHandler := handlers.NewNotificationHandler(db)
+	notificationPreferenceHandler := handlers.NewNotificationPreferenceHandler(db)
+	announcementHandler := handlers.NewAnnouncementHandler(db)
+	forumHandler := handlers.NewForumHandler(db)
+	messageHandler := handlers.NewMessageHandler(db)
+	wishlistHandler := handlers.NewWishlistHandler(db)
+	recommendationHandler := handlers.NewRecommendationHandler(db)
+	trackHandler := handlers.NewTrackHandler(db)
+	sloHandler := handlers.NewSLOHandler(db)
+	deviceHandler := handlers.NewDeviceHandler(db)
+	achievementHandler := handlers.NewAchievementHandler(db)
+	liveSessionHandler := handlers.NewLiveSessionHandler(db)
+	certificateVerificationHandler := handlers.NewCertificateVerificationHandler(db)
+	calendarHandler := handlers.NewCalendarHandler(db, cfg)
+	settingsHandler := handlers.NewSettingsHandler(db)
+	auditHandler := handlers.NewAuditHandler(db)
+	testStudentHandler := handlers.NewTestStudentHandler(db)
+	courseScheduleHandler := handlers.NewCourseScheduleHandler(db)
+	integrityHandler := handlers.NewIntegrityHandler(db)
+	organizationHandler := handlers.NewOrganizationHandler(db)
+	courseStaffHandler := handlers.NewCourseStaffHandler(db)
+	achievementHandler.SeedBadges()
+	capabilitiesHandler := handlers.NewCapabilitiesHandler(cfg, transcodeHandler.Transcoder != nil)
+	apiDocsHandler := handlers.NewAPIDocsHandler()
+	metricsHandler := handlers.NewMetricsHandler(cfg)
+	healthHandler := handlers.NewHealthHandler(db)
+	jobHandler := handlers.NewJobHandler(db)
+
+	// Test mode: frozen clock and sequential IDs so end-to-end runs are reproducible
+	var testClock *clock.Manual
+	if cfg.TestMode {
+		testClock = clock.NewManual(handlers.FixtureClockStart)
+		clock.Set(testClock)
+		idgen.Set(idgen.NewSequential())
+		slog.Warn("Test mode enabled: deterministic clock and IDs, /api/test routes registered")
+	}
 
-	r := gin.Default()
+	r := gin.New()
+	// Errors sits outside the recovery so a panic is answered like any other server error
+	r.Use(middleware.RequestID(), otelgin.Middleware(cfg.OTelServiceName), middleware.Tracing(), middleware.AccessLog(), middleware.Metrics(), middleware.Errors(), gin.CustomRecovery(middleware.Recovered))
+	r.NoRoute(middleware.NoRoute)
 
-	// Add CORS middleware - ADD THIS SECTION
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	r.Use(middleware.CORS(cfg), middleware.SecurityHeaders(cfg), middleware.DefaultBodyLimit(cfg.MaxRequestBodySize))
+
+	// Upload routes take their file plus the rest of the multipart form
+	const formOverhead = 1 << 20
+	uploadLimit := middleware.BodyLimit(max(cfg.MaxImageSize, cfg.MaxVideoSize, cfg.MaxDocumentSize) + formOverhead)
+	documentLimit := middleware.BodyLimit(cfg.MaxDocumentSize + formOverhead)
 
 	// API routes group
 	api := r.Group("/api")
 	{
 		// Public routes
-		api.GET("/courses", courseHandler.GetCourses)
-		api.GET("/courses/:id", courseHandler.GetCourseByID)
+		api.GET("/courses", middleware.OptionalAuth(), courseHandler.GetCourses)
+		api.GET("/courses/:id", middleware.OptionalAuth(), courseHandler.GetCourseByID)
+		api.GET("/courses/:id/preview", middleware.OptionalAuth(), courseHandler.GetCoursePreview)
+		api.POST("/courses/:id/view", middleware.OptionalAuth(), analyticsHandler.RecordCourseView)
+		api.GET("/capabilities", capabilitiesHandler.GetCapabilities)
+		api.GET("/docs", apiDocsHandler.GetSwaggerUI)
+		api.GET("/docs/openapi.json", apiDocsHandler.GetOpenAPISpec)
 		api.POST("/register", userHandler.RegisterUser)
-		api.POST("/login", userHandler.LoginUser)
-		api.POST("/upload", uploadHandler.UploadFile)
+		api.POST("/login", middleware.SLI(slo.FlowLogin), userHandler.LoginUser)
+		api.POST("/login/2fa", middleware.SLI(slo.FlowLogin), middleware.RateLimit(20, time.Minute), userHandler.VerifyTwoFactorLogin)
+		api.GET("/auth/:provider", middleware.RateLimit(30, time.Minute), userHandler.StartOAuthLogin)
+		api.GET("/auth/:provider/callback", middleware.SLI(slo.FlowLogin), middleware.RateLimit(30, time.Minute), userHandler.OAuthCallback)
+		api.POST("/upload", uploadLimit, middleware.AuthMiddleware(), uploadHandler.UploadFile)
 
 		// Verification & Password routes
 		// Verification & Password routes
 		api.GET("/verify-email", userHandler.VerifyEmail)
+		api.GET("/confirm-email-change", userHandler.ConfirmEmailChange)
+		api.POST("/accept-invitation", middleware.RateLimit(20, time.Minute), userHandler.AcceptInvitation)
 		api.POST("/resend-verification", userHandler.ResendVerificationEmail)
 		api.POST("/forgot-password", userHandler.ForgotPassword)
 		api.POST("/reset-password", userHandler.ResetPassword)
 		api.GET("/validate-reset-token", userHandler.ValidateResetToken)
 		api.GET("/validate-reset-code", userHandler.ValidateResetCode)
 
+		// Public testimonials feed for the homepage
+		api.GET("/reviews/featured", middleware.RateLimit(60, time.Minute), reviewHandler.GetFeaturedReviews)
+
 		// Public certificate verification
 		api.GET("/verify-certificate", progressHandler.VerifyCertificate)
+		api.GET("/verify-track-certificate", trackHandler.VerifyTrackCertificate)
+		api.POST("/verify-certificates", certificateVerificationHandler.APIKeyAuth(),
+			middleware.RateLimitBy(time.Minute, handlers.VerificationRateLimitKey), certificateVerificationHandler.VerifyCertificates)
+
+		// Calendar feed, authenticated by the token in its URL so calendar apps can subscribe
+		api.GET("/calendar.ics", middleware.RateLimit(60, time.Minute), calendarHandler.GetCalendarICS)
+
+		// Tracks bundling several courses
+		api.GET("/tracks", trackHandler.GetTracks)
+		api.GET("/tracks/:id", trackHandler.GetTrack)
+		api.GET("/badges", achievementHandler.GetBadges)
+
+		// Instructor away status and auto-reply
+		api.GET("/instructors/:id/availability", availabilityHandler.GetInstructorAvailability)
 
 		// Public domain list
 		api.GET("/allowed-email-domains", func(c *gin.Context) {
@@ -123,35 +295,120 @@ func main() {
 			c.JSON(http.StatusOK, gin.H{
 				"allowed_domains": domains,
 				"count":           len(domains),
-				"message":         "These are the supported email providers for registration",
+				"message":         i18n.T(i18n.Locale(c), "These are the supported email providers for registration"),
 			})
 		})
 
 		// Payment webhooks (public)
-		api.POST("/webhooks/chapa", paymentHandler.HandlePaymentCallback)
+		api.POST("/webhooks/chapa", middleware.SLI(slo.FlowWebhook), paymentHandler.HandlePaymentCallback)
+		api.POST("/webhooks/stripe", middleware.SLI(slo.FlowWebhook), paymentHandler.HandleStripeWebhook)
 		api.GET("/payment/success", paymentHandler.PaymentSuccess)
 
+		// Unsubscribe links in emails
+		api.GET("/unsubscribe", notificationPreferenceHandler.Unsubscribe)
+		api.POST("/unsubscribe", notificationPreferenceHandler.Unsubscribe)
+
+		// Real-time notifications as server-sent events (EventSource can pass the JWT as ?token=)
+		api.GET("/notifications/stream", middleware.TokenFromQuery(), middleware.AuthMiddleware(), notificationHandler.StreamNotifications)
+
 		// Protected routes (require authentication)
 		protected := api.Group("/")
 		protected.Use(middleware.AuthMiddleware())
 		{
 			protected.GET("/profile", userHandler.GetProfile)
-			protected.PUT("/profile", userHandler.UpdateProfile)
+			protected.PUT("/profile", middleware.NotImpersonated(), userHandler.UpdateProfile)
+			protected.GET("/profile/notifications", notificationPreferenceHandler.GetNotificationPreferences)
+			protected.PUT("/profile/notifications", notificationPreferenceHandler.UpdateNotificationPreferences)
+			protected.GET("/profile/2fa", userHandler.GetTwoFactorStatus)
+			protected.POST("/profile/2fa/setup", middleware.NotImpersonated(), userHandler.SetupTwoFactor)
+			protected.POST("/profile/2fa/enable", middleware.NotImpersonated(), userHandler.EnableTwoFactor)
+			protected.POST("/profile/2fa/disable", middleware.NotImpersonated(), userHandler.DisableTwoFactor)
+			protected.POST("/profile/2fa/backup-codes", middleware.NotImpersonated(), userHandler.RegenerateBackupCodes)
+			protected.POST("/profile/email", middleware.NotImpersonated(), middleware.RateLimit(5, time.Hour), userHandler.RequestEmailChange)
+			protected.DELETE("/profile/email", middleware.NotImpersonated(), userHandler.CancelEmailChange)
+			protected.DELETE("/profile", middleware.NotImpersonated(), userHandler.DeleteAccount)
+			protected.GET("/profile/export", middleware.NotImpersonated(), middleware.RateLimit(5, time.Hour), userHandler.ExportAccountData)
+			protected.GET("/profile/sessions", sessionHandler.GetSessions)
+			protected.DELETE("/profile/sessions/:id", middleware.NotImpersonated(), sessionHandler.RevokeSession)
+			protected.POST("/logout", sessionHandler.Logout)
+			protected.POST("/logout/everywhere", middleware.NotImpersonated(), sessionHandler.LogoutEverywhere)
 			protected.GET("/dashboard", progressHandler.GetStudentDashboard)
 			protected.GET("/my-payments", paymentHandler.GetUserPayments)
 			protected.GET("/my-enrollments", userHandler.GetUserEnrollments)
-			protected.POST("/payments/initiate", paymentHandler.InitiatePayment)
+			protected.POST("/payments/initiate", middleware.SLI(slo.FlowCheckout), middleware.NotImpersonated(), paymentHandler.InitiatePayment)
 			protected.GET("/payments/status/:id", paymentHandler.GetPaymentStatus)
+			protected.GET("/payments/:id/receipt", paymentHandler.DownloadPaymentReceipt)
+			protected.GET("/certificates/:id/download", certificateHandler.DownloadCertificate)
+			protected.GET("/courses/:id/paths", learningPathHandler.GetLearningPaths)
+			protected.GET("/courses/:id/grading-scale", gradingHandler.GetCourseGradingScale)
+			protected.GET("/courses/:id/announcements", announcementHandler.GetAnnouncements)
+			protected.POST("/courses/:id/announcements", announcementHandler.CreateAnnouncement)
+			protected.PUT("/courses/:id/announcements/:announcementId/pin", announcementHandler.PinAnnouncement)
+			protected.DELETE("/courses/:id/announcements/:announcementId", announcementHandler.DeleteAnnouncement)
+			protected.GET("/courses/:id/threads", forumHandler.GetThreads)
+			protected.POST("/courses/:id/threads", forumHandler.CreateThread)
+			protected.GET("/threads/:id", forumHandler.GetThread)
+			protected.PUT("/threads/:id", forumHandler.ModerateThread)
+			protected.DELETE("/threads/:id", forumHandler.DeleteThread)
+			protected.POST("/threads/:id/posts", forumHandler.CreatePost)
+			protected.POST("/threads/:id/vote", forumHandler.VoteThread)
+			protected.DELETE("/threads/:id/vote", forumHandler.VoteThread)
+			protected.PUT("/threads/:id/answer", forumHandler.MarkAnswer)
+			protected.DELETE("/posts/:id", forumHandler.DeletePost)
+			protected.POST("/posts/:id/vote", forumHandler.VotePost)
+			protected.DELETE("/posts/:id/vote", forumHandler.VotePost)
+			protected.POST("/courses/:id/conversations", messageHandler.StartConversation)
+			protected.GET("/conversations", messageHandler.GetConversations)
+			protected.GET("/conversations/unread", messageHandler.GetUnreadMessageCount)
+			protected.GET("/conversations/:id/messages", messageHandler.GetMessages)
+			protected.POST("/conversations/:id/messages", messageHandler.SendMessage)
+			protected.GET("/courses/:id/live-sessions", liveSessionHandler.GetLiveSessions)
+			protected.POST("/live-sessions/:id/join", liveSessionHandler.JoinLiveSession)
+			protected.POST("/courses/:id/wishlist", wishlistHandler.AddToWishlist)
+			protected.DELETE("/courses/:id/wishlist", wishlistHandler.RemoveFromWishlist)
+			protected.GET("/my-wishlist", wishlistHandler.GetMyWishlist)
+			protected.GET("/recommendations", recommendationHandler.GetRecommendations)
+			protected.GET("/my-tracks", trackHandler.GetMyTracks)
+			protected.GET("/my-achievements", achievementHandler.GetMyAchievements)
+			protected.GET("/calendar", calendarHandler.GetCalendarFeed)
+			protected.POST("/calendar/reset", calendarHandler.ResetCalendarFeed)
+			protected.GET("/leaderboard", achievementHandler.GetLeaderboard)
+			protected.GET("/courses/:id/leaderboard", achievementHandler.GetCourseLeaderboard)
+			protected.GET("/tracks/:id/progress", trackHandler.GetTrackProgress)
+			protected.GET("/my-transcript", gradingHandler.GetTranscript)
+			protected.GET("/my-organizations", organizationHandler.GetMyOrganizations)
+			protected.POST("/organizations", organizationHandler.CreateOrganization)
+			protected.GET("/organizations/:id", organizationHandler.GetOrganization)
+			protected.POST("/organizations/:id/seats", middleware.NotImpersonated(), organizationHandler.BuySeats)
+			protected.POST("/organizations/:id/members", organizationHandler.AddMember)
+			protected.DELETE("/organizations/:id/members/:userId", organizationHandler.RemoveMember)
+			protected.POST("/organizations/:id/assignments", organizationHandler.AssignCourse)
+			protected.DELETE("/organizations/:id/assignments/:enrollmentId", organizationHandler.UnassignCourse)
+			protected.GET("/organizations/:id/progress", organizationHandler.GetTeamProgress)
+			protected.GET("/my-files", uploadHandler.GetMyFiles)
+			protected.DELETE("/my-files/:id", uploadHandler.DeleteMyFile)
+			protected.GET("/my-files/quota", uploadHandler.GetMyUploadQuota)
+			protected.GET("/notifications", notificationHandler.GetNotifications)
+			protected.PUT("/notifications/read", notificationHandler.MarkNotificationsRead)
+			protected.PUT("/notifications/:id/read", notificationHandler.MarkNotificationsRead)
+			protected.PUT("/notifications/:id/unread", notificationHandler.MarkNotificationUnread)
+			protected.DELETE("/notifications/:id", notificationHandler.DeleteNotification)
+			protected.GET("/notifications/sync", notificationHandler.SyncNotifications)
 		}
 
 		// Student-only routes
 		student := api.Group("/")
 		student.Use(middleware.AuthMiddleware(), middleware.StudentOnly())
 		{
-			student.POST("/courses/:id/enroll", courseHandler.EnrollCourse)
+			student.POST("/courses/:id/enroll", middleware.SLI(slo.FlowEnrollment), courseHandler.EnrollCourse)
+			student.POST("/tracks/:id/enroll", middleware.SLI(slo.FlowEnrollment), middleware.NotImpersonated(), trackHandler.EnrollTrack)
 			student.GET("/my-courses", courseHandler.GetStudentCourses)
 			student.PUT("/progress/lesson", progressHandler.UpdateLessonProgress)
 			student.GET("/courses/:id/progress", progressHandler.GetCourseProgress)
+			student.GET("/courses/:id/pace", workloadHandler.GetCoursePace)
+			student.PUT("/courses/:id/path", learningPathHandler.ChooseLearningPath)
+			student.GET("/courses/:id/continue", learningPathHandler.ContinueCourse)
+			student.PUT("/courses/:id/language", lessonHandler.SetCourseLanguage)
 			student.POST("/courses/:id/review", courseHandler.SubmitCourseReview)
 			student.GET("/courses/:id/reviews", courseHandler.GetCourseReviews)
 			student.POST("/courses/:id/certificate", progressHandler.GenerateCertificate)
@@ -163,10 +420,57 @@ func main() {
 		instructor.Use(middleware.AuthMiddleware(), middleware.InstructorOnly())
 		{
 			instructor.POST("/courses", courseHandler.CreateCourse)
+			instructor.POST("/courses/import/youtube", courseImportHandler.ImportYouTubePlaylist)
+			instructor.POST("/courses/import", middleware.BodyLimit(handlers.MaxCoursePackageSize+formOverhead), courseImportHandler.ImportCourse)
+			instructor.GET("/courses/:id/export", courseImportHandler.ExportCourse)
 			instructor.PUT("/courses/:id", courseHandler.UpdateCourse)
+			instructor.POST("/courses/:id/publish", publishChecklistHandler.PublishCourse)
+			instructor.PUT("/courses/:id/unpublish-at", courseScheduleHandler.SetUnpublishDate)
 			instructor.DELETE("/courses/:id", courseHandler.DeleteCourse)
+			instructor.POST("/courses/:id/clone", courseHandler.CloneCourse)
+			instructor.GET("/courses/:id/staff", courseStaffHandler.GetCourseStaff)
+			instructor.POST("/courses/:id/staff", courseStaffHandler.AddCourseStaff)
+			instructor.PUT("/courses/:id/staff/:userId", courseStaffHandler.UpdateCourseStaff)
+			instructor.DELETE("/courses/:id/staff/:userId", courseStaffHandler.RemoveCourseStaff)
 			instructor.GET("/instructor/courses", courseHandler.GetInstructorCourses)
+			instructor.GET("/instructor/courses/:id/analytics", analyticsHandler.GetInstructorCourseAnalytics)
+			instructor.POST("/instructor/courses/:id/test-student", testStudentHandler.StartTestStudent)
+			instructor.POST("/instructor/courses/:id/test-student/reset", testStudentHandler.ResetTestStudent)
 			instructor.POST("/courses/:id/modules", courseHandler.CreateModule)
+			instructor.DELETE("/courses/:id/modules/:moduleId", courseHandler.DeleteModule)
+			instructor.GET("/instructor/availability", availabilityHandler.GetMyAvailability)
+			instructor.POST("/instructor/availability", availabilityHandler.AddAwayPeriod)
+			instructor.DELETE("/instructor/availability/:id", availabilityHandler.EndAwayPeriod)
+			instructor.POST("/tracks", trackHandler.CreateTrack)
+			instructor.POST("/courses/:id/live-sessions", liveSessionHandler.CreateLiveSession)
+			instructor.PUT("/courses/:id/live-sessions/:sessionId", liveSessionHandler.UpdateLiveSession)
+			instructor.DELETE("/courses/:id/live-sessions/:sessionId", liveSessionHandler.CancelLiveSession)
+			instructor.GET("/courses/:id/live-sessions/:sessionId/attendance", liveSessionHandler.GetLiveSessionAttendance)
+			instructor.PUT("/tracks/:id", trackHandler.UpdateTrack)
+			instructor.DELETE("/tracks/:id", trackHandler.DeleteTrack)
+			instructor.GET("/instructor/trash", trashHandler.GetTrash)
+			instructor.POST("/instructor/trash/:type/:id/restore", trashHandler.RestoreTrashItem)
+		}
+
+		// Course management routes (course staff or admin, checked by the handlers)
+		courseManagers := api.Group("/")
+		courseManagers.Use(middleware.AuthMiddleware(), middleware.InstructorOrAdmin())
+		{
+			courseManagers.PUT("/courses/:id/grading-scale", gradingHandler.SaveCourseGradingScale)
+			courseManagers.DELETE("/courses/:id/grading-scale", gradingHandler.DeleteCourseGradingScale)
+			courseManagers.GET("/courses/:id/gradebook", gradingHandler.GetGradebook)
+			courseManagers.PUT("/courses/:id/reviews/:reviewId/reply", reviewHandler.ReplyToReview)
+			courseManagers.DELETE("/courses/:id/reviews/:reviewId/reply", reviewHandler.DeleteReviewReply)
+			courseManagers.POST("/courses/:id/paths", learningPathHandler.CreateLearningPath)
+			courseManagers.PUT("/courses/:id/paths/:pathId", learningPathHandler.UpdateLearningPath)
+			courseManagers.DELETE("/courses/:id/paths/:pathId", learningPathHandler.DeleteLearningPath)
+			courseManagers.GET("/instructor/courses/:id/cohorts", cohortHandler.GetCohorts)
+			courseManagers.POST("/instructor/courses/:id/cohorts", cohortHandler.CreateCohort)
+			courseManagers.GET("/instructor/courses/:id/cohorts/compare", cohortHandler.CompareCohorts)
+			courseManagers.PUT("/instructor/courses/:id/cohorts/:cohortId", cohortHandler.UpdateCohort)
+			courseManagers.DELETE("/instructor/courses/:id/cohorts/:cohortId", cohortHandler.DeleteCohort)
+			courseManagers.GET("/courses/:id/accessibility", accessibilityHandler.GetCourseAccessibility)
+			courseManagers.GET("/courses/:id/publish-check", publishChecklistHandler.GetPublishReport)
 		}
 
 		// Admin-only routes
@@ -174,32 +478,132 @@ func main() {
 		admin.Use(middleware.AuthMiddleware(), middleware.AdminOnly())
 		{
 			admin.GET("/admin/stats", adminHandler.AdminStats)
+			admin.GET("/admin/slo", sloHandler.GetSLODashboard)
 			admin.GET("/admin/payments/recent", adminHandler.GetRecentPayments)
+			admin.GET("/admin/payments/methods", adminHandler.GetPaymentMethodReport)
+			admin.GET("/admin/payments/export", paymentExportHandler.ExportPayments)
+			admin.GET("/admin/payments/exports", paymentExportHandler.GetPaymentExports)
+			admin.GET("/admin/payments/exports/:id/download", paymentExportHandler.DownloadPaymentExport)
+			admin.POST("/admin/payments/reconcile", reconciliationHandler.RunReconciliation)
+			admin.GET("/admin/payments/reconciliations", reconciliationHandler.GetReconciliations)
+			admin.GET("/admin/payments/reconciliations/:id", reconciliationHandler.GetReconciliation)
+			admin.GET("/admin/webhooks", webhookEventHandler.GetWebhookEvents)
+			admin.GET("/admin/webhooks/:id", webhookEventHandler.GetWebhookEvent)
+			admin.POST("/admin/webhooks/:id/replay", webhookEventHandler.ReplayWebhookEvent)
+			admin.GET("/admin/webhook-endpoints", outboundWebhookHandler.GetWebhookEndpoints)
+			admin.POST("/admin/webhook-endpoints", outboundWebhookHandler.CreateWebhookEndpoint)
+			admin.PUT("/admin/webhook-endpoints/:id", outboundWebhookHandler.UpdateWebhookEndpoint)
+			admin.DELETE("/admin/webhook-endpoints/:id", outboundWebhookHandler.DeleteWebhookEndpoint)
+			admin.POST("/admin/webhook-endpoints/:id/test", outboundWebhookHandler.TestWebhookEndpoint)
+			admin.GET("/admin/webhook-deliveries", outboundWebhookHandler.GetWebhookDeliveries)
+			admin.GET("/admin/webhook-deliveries/:id", outboundWebhookHandler.GetWebhookDelivery)
+			admin.POST("/admin/webhook-deliveries/:id/redeliver", outboundWebhookHandler.RedeliverWebhook)
 			admin.GET("/admin/enrollments/recent", adminHandler.GetRecentEnrollments)
 			admin.GET("/admin/courses/:id/analytics", adminHandler.GetCourseAnalytics)
+			admin.GET("/admin/country-rules", countryRuleHandler.GetCountryRules)
+			admin.PUT("/admin/courses/:id/country-rules/:country", countryRuleHandler.SaveCountryRule)
+			admin.DELETE("/admin/courses/:id/country-rules/:country", countryRuleHandler.DeleteCountryRule)
+			admin.POST("/admin/certificates/:id/revoke", certificateHandler.RevokeCertificate)
+			admin.GET("/admin/reviews", reviewHandler.GetAdminReviews)
+			admin.PUT("/admin/reviews/:id/featured", reviewHandler.SetReviewFeatured)
+			admin.PUT("/admin/reviews/:id/moderation", reviewHandler.ModerateReview)
+			admin.GET("/admin/publish-checklist", publishChecklistHandler.GetPublishRules)
+			admin.PUT("/admin/publish-checklist", publishChecklistHandler.SavePublishRules)
+			admin.GET("/admin/audit-logs", auditHandler.GetAuditLogs)
+			admin.GET("/admin/jobs", jobHandler.GetJobs)
+			admin.GET("/admin/jobs/runs", jobHandler.GetJobRuns)
+			admin.GET("/admin/integrity", integrityHandler.GetIntegritySummary)
+			admin.POST("/admin/integrity/check", integrityHandler.RunIntegrityCheck)
+			admin.GET("/admin/integrity/issues", integrityHandler.GetIntegrityIssues)
+			admin.POST("/admin/integrity/issues/:id/repair", integrityHandler.RepairIntegrityIssue)
+			admin.POST("/admin/integrity/issues/:id/dismiss", integrityHandler.DismissIntegrityIssue)
+			admin.GET("/admin/settings", settingsHandler.GetSettings)
+			admin.PUT("/admin/settings", settingsHandler.UpdateSettings)
+			admin.GET("/admin/verification-keys", certificateVerificationHandler.GetVerificationKeys)
+			admin.POST("/admin/verification-keys", certificateVerificationHandler.CreateVerificationKey)
+			admin.PUT("/admin/verification-keys/:id", certificateVerificationHandler.UpdateVerificationKey)
+			admin.DELETE("/admin/verification-keys/:id", certificateVerificationHandler.RevokeVerificationKey)
+			admin.GET("/admin/moderation", moderationHandler.GetModerationQueue)
+			admin.PUT("/admin/moderation/:id", moderationHandler.ModerateItem)
+			admin.GET("/admin/grading-scale", gradingHandler.GetDefaultGradingScale)
+			admin.PUT("/admin/grading-scale", gradingHandler.SaveDefaultGradingScale)
+			admin.GET("/admin/file-access/logs", adminHandler.GetFileAccessLogs)
+			admin.GET("/admin/file-access/suspicious", adminHandler.GetSuspiciousFileAccess)
+			admin.GET("/admin/quarantine", adminHandler.GetQuarantinedFiles)
+			admin.DELETE("/admin/quarantine/:id", adminHandler.DeleteQuarantinedFile)
+			admin.POST("/admin/badges", achievementHandler.CreateBadge)
+			admin.PUT("/admin/badges/:id", achievementHandler.UpdateBadge)
+			admin.DELETE("/admin/badges/:id", achievementHandler.DeleteBadge)
+			admin.GET("/admin/devices", deviceHandler.GetDevices)
+			admin.GET("/admin/devices/:id", deviceHandler.GetDevice)
+			admin.PUT("/admin/devices/:id", deviceHandler.ReviewDevice)
 			admin.GET("/admin/users", adminHandler.GetUserManagement)
 			admin.PUT("/admin/users/:id/role", adminHandler.UpdateUserRole)
+			admin.GET("/admin/users/:id/login-attempts", adminHandler.GetLoginAttempts)
+			admin.POST("/admin/users/:id/unlock", adminHandler.UnlockUser)
+			admin.POST("/admin/users/:id/2fa/reset", adminHandler.ResetTwoFactor)
+			admin.POST("/admin/users/:id/sessions/revoke", adminHandler.RevokeUserSessions)
+			admin.POST("/admin/users/:id/impersonate", adminHandler.ImpersonateUser)
+			admin.POST("/admin/users/import", middleware.BodyLimit(handlers.MaxUserImportSize+formOverhead), adminHandler.ImportUsers)
+			admin.POST("/admin/users/:id/invitation", adminHandler.ResendInvitation)
 			admin.DELETE("/admin/users/:id", adminHandler.DeleteUser)
+			admin.GET("/admin/users/:id/upload-quota", uploadHandler.GetUserUploadQuota)
+			admin.PUT("/admin/users/:id/upload-quota", uploadHandler.SetUserUploadQuota)
+			admin.DELETE("/admin/users/:id/upload-quota", uploadHandler.ResetUserUploadQuota)
+			admin.GET("/admin/organizations", adminHandler.GetOrganizations)
+			admin.POST("/admin/organizations/:id/seats", adminHandler.GrantSeats)
 			admin.GET("/admin/email-domains", adminHandler.GetEmailDomains)
 			admin.POST("/admin/email-domains", adminHandler.AddEmailDomain)
 			admin.DELETE("/admin/email-domains/:domain", adminHandler.RemoveEmailDomain)
 		}
 
+		// Certificate template routes (course instructor or admin)
+		certificateTemplates := api.Group("/courses/:id/certificate-template")
+		certificateTemplates.Use(middleware.AuthMiddleware(), middleware.InstructorOrAdmin())
+		{
+			certificateTemplates.GET("", certificateHandler.GetCertificateTemplate)
+			certificateTemplates.PUT("", certificateHandler.SaveCertificateTemplate)
+			certificateTemplates.POST("/preview", certificateHandler.PreviewCertificateTemplate)
+		}
+
 		// Lesson routes
 		lessonRoutes := api.Group("/lessons")
 		{
 			lessonRoutes.POST("", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.CreateLesson)
-			lessonRoutes.GET("/:id", middleware.AuthMiddleware(), lessonHandler.GetLesson)
+			lessonRoutes.POST("/preview", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.PreviewLessonContent)
+			lessonRoutes.GET("/:id", middleware.SLI(slo.FlowLessonGet), middleware.AuthMiddleware(), lessonHandler.GetLesson)
 			lessonRoutes.PUT("/:id", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.UpdateLesson)
 			lessonRoutes.DELETE("/:id", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.DeleteLesson)
 			lessonRoutes.PUT("/:id/progress", middleware.AuthMiddleware(), lessonHandler.UpdateLessonProgress)
 			lessonRoutes.GET("/module/:moduleId", middleware.AuthMiddleware(), lessonHandler.GetModuleLessons)
 			lessonRoutes.GET("/:id/analytics", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.GetLessonAnalytics)
+			lessonRoutes.GET("/:id/variants", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.GetLessonVariants)
+			lessonRoutes.PUT("/:id/variants/:lang", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.SaveLessonVariant)
+			lessonRoutes.DELETE("/:id/variants/:lang", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.DeleteLessonVariant)
+			lessonRoutes.GET("/:id/video", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.GetLessonVideo)
+			lessonRoutes.POST("/:id/video/transcode", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.RetranscodeLessonVideo)
+			lessonRoutes.GET("/:id/code", middleware.AuthMiddleware(), lessonHandler.GetLessonCode)
+			lessonRoutes.PUT("/:id/code", middleware.AuthMiddleware(), lessonHandler.SaveLessonCode)
+			lessonRoutes.POST("/:id/code/run", middleware.AuthMiddleware(), middleware.RateLimit(30, time.Minute), lessonHandler.RunLessonCode)
+			lessonRoutes.POST("/:id/code/check", middleware.AuthMiddleware(), middleware.RateLimit(10, time.Minute), lessonHandler.CheckLessonCode)
+			lessonRoutes.GET("/:id/blocks", middleware.AuthMiddleware(), lessonHandler.GetLessonBlocks)
+			lessonRoutes.POST("/:id/blocks", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.CreateLessonBlock)
+			lessonRoutes.PUT("/:id/blocks/order", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.ReorderLessonBlocks)
+			lessonRoutes.PUT("/:id/blocks/:blockId", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.UpdateLessonBlock)
+			lessonRoutes.DELETE("/:id/blocks/:blockId", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.DeleteLessonBlock)
+			lessonRoutes.POST("/:id/scorm", middleware.BodyLimit(handlers.MaxScormPackageSize+formOverhead), middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.UploadScormPackage)
+			lessonRoutes.DELETE("/:id/scorm", middleware.AuthMiddleware(), middleware.InstructorOnly(), lessonHandler.DeleteScormPackage)
+			lessonRoutes.GET("/:id/scorm", middleware.AuthMiddleware(), lessonHandler.GetScormLaunch)
+			lessonRoutes.PUT("/:id/scorm/runtime", middleware.AuthMiddleware(), lessonHandler.CommitScormData)
+			lessonRoutes.POST("/:id/xapi/statements", middleware.AuthMiddleware(), middleware.RateLimit(120, time.Minute), lessonHandler.RecordXAPIStatements)
 		}
 		assessmentRoutes := api.Group("/assessments")
 		{
 			// Quiz routes
 			assessmentRoutes.POST("/quizzes", middleware.AuthMiddleware(), middleware.InstructorOnly(), assessmentHandler.CreateQuiz)
+			assessmentRoutes.DELETE("/quizzes/:quizId", middleware.AuthMiddleware(), middleware.InstructorOnly(), assessmentHandler.DeleteQuiz)
+			assessmentRoutes.GET("/quizzes/:quizId/export", middleware.AuthMiddleware(), middleware.InstructorOrAdmin(), assessmentHandler.ExportQuiz)
+			assessmentRoutes.POST("/quizzes/:quizId/paper-results", middleware.AuthMiddleware(), middleware.InstructorOrAdmin(), assessmentHandler.ImportPaperResults)
 			assessmentRoutes.POST("/quizzes/:quizId/attempt", middleware.AuthMiddleware(), assessmentHandler.StartQuizAttempt)
 			assessmentRoutes.POST("/attempts/:attemptId/answer", middleware.AuthMiddleware(), assessmentHandler.SubmitQuizAnswer)
 			assessmentRoutes.POST("/attempts/:attemptId/complete", middleware.AuthMiddleware(), assessmentHandler.CompleteQuizAttempt)
@@ -207,8 +611,10 @@ func main() {
 
 			// Assignment routes
 			assessmentRoutes.POST("/assignments", middleware.AuthMiddleware(), middleware.InstructorOnly(), assessmentHandler.CreateAssignment)
-			assessmentRoutes.POST("/assignments/:assignmentId/submit", middleware.AuthMiddleware(), assessmentHandler.SubmitAssignment)
+			assessmentRoutes.POST("/assignments/:assignmentId/submit", documentLimit, middleware.AuthMiddleware(), assessmentHandler.SubmitAssignment)
 			assessmentRoutes.POST("/submissions/:submissionId/grade", middleware.AuthMiddleware(), middleware.InstructorOnly(), assessmentHandler.GradeAssignment)
+			assessmentRoutes.GET("/submissions/:submissionId/receipt", middleware.AuthMiddleware(), assessmentHandler.GetSubmissionReceipt)
+			assessmentRoutes.POST("/submissions/:submissionId/verify", documentLimit, middleware.AuthMiddleware(), assessmentHandler.VerifySubmission)
 			assessmentRoutes.GET("/assignments/:assignmentId/submissions", middleware.AuthMiddleware(), assessmentHandler.GetStudentAssignmentSubmissions)
 
 			// Instructor analytics routes
@@ -218,12 +624,23 @@ func main() {
 	}
 
 	// File serving route for uploaded files
-	r.GET("/uploads/:type/:filename", uploadHandler.ServeFile)
+	filePolicy := middleware.ServedFiles(cfg, cfg.FilesContentCSP)
+	r.GET("/uploads/:type/:filename", filePolicy, middleware.OptionalAuth(), uploadHandler.ServeFile)
+	r.GET("/uploads/hls/:id/:filename", filePolicy, middleware.OptionalAuth(), uploadHandler.ServeHLS)
+	// SCORM packages are HTML courseware running their own scripts and styles
+	r.GET("/uploads/scorm/:id/:token/*path", middleware.ServedFiles(cfg, ""), uploadHandler.ServeScormFile)
+
+	// Prometheus scrapes the metrics here
+	r.GET("/metrics", metricsHandler.GetMetrics)
 
-	// Health check route
+	// Kubernetes probes: liveness checks the process answers, readiness its dependencies
+	r.GET("/healthz", healthHandler.Liveness)
+	r.GET("/readyz", healthHandler.Readiness)
+
+	// Health check route, kept for existing monitors; it reports Chapa and the payment provider
 	r.GET("/health", func(c *gin.Context) {
 		chapaStatus := "connected"
-		if err := chapa.TestConnection(); err != nil {
+		if err := chapa.TestConnection(c.Request.Context()); err != nil {
 			chapaStatus = "disconnected"
 		}
 
@@ -232,26 +649,176 @@ func main() {
 			"environment":      cfg.ServerEnv,
 			"chapa":            chapaStatus,
 			"payment_provider": cfg.GetPaymentProvider(),
+			"realtime_clients": realtime.Connections(),
 		})
 	})
 
+	// Test fixture routes (APP_TEST_MODE=true only, never in production)
+	if cfg.TestMode {
+		testFixturesHandler := handlers.NewTestFixturesHandler(db, testClock)
+		testRoutes := r.Group("/api/test")
+		{
+			testRoutes.POST("/reset", testFixturesHandler.ResetDatabase)
+			testRoutes.POST("/fixtures", testFixturesHandler.CreateFixtures)
+			testRoutes.GET("/clock", testFixturesHandler.GetClock)
+			testRoutes.POST("/clock", testFixturesHandler.SetClock)
+		}
+	}
+
+	// API docs describe every route registered above
+	if err := apiDocsHandler.Load(r.Routes()); err != nil {
+		slog.Error("Failed to build the API docs", "error", err)
+	}
+
 	// Start server
 	serverAddr := fmt.Sprintf(":%s", cfg.ServerPort)
-	fmt.Printf("📚 LearnHub API running on port %s...\n", cfg.ServerPort)
-	fmt.Printf("💳 Chapa payment integration: ENABLED\n")
-	fmt.Printf("🌐 CORS enabled for: http://localhost:5173, http://localhost:3000\n")
+	slog.Info("LearnHub API running", "port", cfg.ServerPort, "payments", cfg.GetPaymentProvider())
 
-	// Create some sample data on startup
-	createSampleData(db)
-
-	if err := r.Run(serverAddr); err != nil {
-		log.Fatal("Failed to start server:", err)
+	// Development servers can start with the demo data, seeded once
+	if cfg.SeedOnStartup {
+		if _, err := seed.Run(db, cfg, seed.Options{}); err != nil {
+			slog.Error("Failed to seed the database", "error", err)
+		}
 	}
-}
 
-// createSampleData creates initial sample data for testing
-func createSampleData(db *gorm.DB) {
-	fmt.Println("📝 Creating sample data...")
+	// Background jobs; nightly maintenance runs at fixed times in the server's time zone
+	jobs.Register(jobs.Job{
+		Name:     "certificate-expiry-notices",
+		Schedule: "0 9 * * *",
+		Run:      certificateHandler.NotifyExpiringCertificates,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "trash-purge",
+		Schedule: "0 3 * * *",
+		Run:      trashHandler.PurgeTrash,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "orphaned-upload-cleanup",
+		Schedule: "15 3 * * *",
+		Run:      uploadHandler.CleanupOrphanedUploads,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "accessibility-scores",
+		Interval: 24 * time.Hour,
+		Run:      accessibilityHandler.RefreshAccessibilityScores,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "course-workloads",
+		Interval: 24 * time.Hour,
+		Run:      workloadHandler.RefreshCourseWorkloads,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "account-deletion",
+		Interval: time.Hour,
+		Run:      userHandler.DeleteScheduledAccounts,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "session-cleanup",
+		Schedule: "30 3 * * *",
+		Run:      sessionHandler.CleanupSessions,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "notification-tombstone-purge",
+		Schedule: "45 3 * * *",
+		Run:      notificationHandler.PurgeNotificationTombstones,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "sli-flush",
+		Interval: time.Minute,
+		Run:      sloHandler.FlushSLIs,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "slo-burn-alerts",
+		Interval: 5 * time.Minute,
+		Run:      sloHandler.CheckErrorBudgets,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "live-session-reminders",
+		Interval: 5 * time.Minute,
+		Run:      liveSessionHandler.SendLiveSessionReminders,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "scheduled-unpublish",
+		Interval: 15 * time.Minute,
+		Run:      courseScheduleHandler.UnpublishScheduledCourses,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "integrity-checks",
+		Interval: time.Hour,
+		Run:      integrityHandler.RunIntegrityChecks,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "payment-reconciliation",
+		Interval: 10 * time.Minute,
+		Run:      reconciliationHandler.ReconcilePayments,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "webhook-deliveries",
+		Interval: time.Minute,
+		Run:      outboundWebhookHandler.DeliverWebhooks,
+	})
+	jobs.Register(jobs.Job{
+		Name:     "payment-exports",
+		Interval: time.Minute,
+		Run:      paymentExportHandler.ProcessPaymentExports,
+	})
+	if transcodeHandler.Transcoder != nil {
+		jobs.Register(jobs.Job{
+			Name:     "video-transcoding",
+			Interval: time.Minute,
+			Run:      transcodeHandler.ProcessTranscodes,
+		})
+	}
+	jobs.Register(jobs.Job{
+		Name:     "job-run-purge",
+		Schedule: "0 4 * * *",
+		Run:      jobHandler.PurgeJobRuns,
+	})
+	// Instances of the API take turns running each job, and their runs are recorded
+	jobs.SetStore(jobHandler)
+
+	// SIGTERM (sent by Kubernetes and docker stop) and Ctrl+C start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	jobs.Start(ctx)
+
+	srv := &http.Server{
+		Addr:              serverAddr,
+		Handler:           r.Handler(),
+		ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+		ReadTimeout:       cfg.ServerReadTimeout,
+		WriteTimeout:      cfg.ServerWriteTimeout,
+		IdleTimeout:       cfg.ServerIdleTimeout,
+		MaxHeaderBytes:    cfg.ServerMaxHeaderBytes,
+	}
+	// Notification streams never end on their own, Shutdown would wait for them until its timeout
+	srv.RegisterOnShutdown(realtime.CloseAll)
 
-	fmt.Println("✅ Sample data ready for testing")
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("Failed to start server", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // a second signal kills the process right away
+	slog.Info("Shutting down", "timeout", cfg.ShutdownTimeout)
+	healthHandler.ShuttingDown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	// Stops accepting connections and waits for the requests in progress
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Requests were still running at shutdown", "error", err)
+	}
+	if err := jobs.Stop(shutdownCtx); err != nil {
+		slog.Error("Background jobs were still running at shutdown", "error", err)
+	}
+	if err := tracing.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Failed to export the last spans", "error", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	slog.Info("Server stopped")
 }
@@ -1,42 +1,112 @@
 package middleware
 
 import (
-	"fmt"
+	"learning_hub/pkg/apierror"
 	"learning_hub/pkg/jwt"
-	"net/http"
+	"log/slog"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// SessionCheck reports whether the session a valid token belongs to is still active
+type SessionCheck func(c *gin.Context, claims *jwt.Claims) bool
+
+var sessionActive SessionCheck
+
+// CheckSessions makes the auth middleware refuse tokens whose session was revoked or is unknown
+func CheckSessions(check SessionCheck) {
+	sessionActive = check
+}
+
+var recordImpersonated func(c *gin.Context)
+
+// RecordImpersonation has every request made with an impersonation token passed to record once handled
+func RecordImpersonation(record func(c *gin.Context)) {
+	recordImpersonated = record
+}
+
+// setClaims identifies the user of a request; with an impersonation token, also the admin acting as them
+func setClaims(c *gin.Context, claims *jwt.Claims) {
+	c.Set("userID", claims.UserID)
+	c.Set("sessionID", claims.ID)
+	c.Set("userEmail", claims.Email)
+	c.Set("userRole", claims.Role)
+	if claims.ImpersonatorID != 0 {
+		c.Set("impersonatorID", claims.ImpersonatorID)
+		c.Header("X-Impersonated-By", strconv.FormatUint(uint64(claims.ImpersonatorID), 10))
+	}
+}
+
+// next runs the rest of the chain, recording the request when an admin made it as the user
+func next(c *gin.Context, claims *jwt.Claims) {
+	c.Next()
+	if claims.ImpersonatorID != 0 && recordImpersonated != nil {
+		recordImpersonated(c)
+	}
+}
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		fmt.Printf("🔐 Auth Middleware - Path: %s, Header: %s\n", c.Request.URL.Path, authHeader)
-
 		if authHeader == "" {
-			fmt.Println("❌ No Authorization header")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
-			c.Abort()
+			apierror.Abort(c, apierror.Unauthorized("Authorization header is missing"))
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		fmt.Printf("🔐 Token: %s\n", tokenString)
-
-		claims, err := jwt.ValidateToken(tokenString)
+		claims, err := jwt.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "))
 		if err != nil {
-			fmt.Printf("❌ Token validation failed: %v\n", err)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			slog.DebugContext(c.Request.Context(), "Token validation failed", "path", c.Request.URL.Path, "error", err)
+			apierror.Abort(c, apierror.Unauthorized("Invalid or expired token"))
 			return
 		}
 
-		fmt.Printf("✅ Token validated - UserID: %v, Email: %s\n", claims.UserID, claims.Email)
+		if sessionActive != nil && !sessionActive(c, claims) {
+			slog.DebugContext(c.Request.Context(), "Session is not active", "session_id", claims.ID, "user_id", claims.UserID)
+			apierror.Abort(c, apierror.Unauthorized("This session has ended, please log in again"))
+			return
+		}
 
-		c.Set("userID", claims.UserID)
-		c.Set("userEmail", claims.Email)
-		c.Set("userRole", claims.Role)
+		setClaims(c, claims)
+		next(c, claims)
+	}
+}
+
+// TokenFromQuery lets clients that cannot set headers, such as the browser's EventSource, pass the
+// JWT as ?token= to the auth middleware that follows
+func TokenFromQuery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("token"); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		c.Next()
+	}
+}
+
+// OptionalAuth identifies the user when a valid token is sent but lets anonymous requests through
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString != "" {
+			if claims, err := jwt.ValidateToken(tokenString); err == nil && (sessionActive == nil || sessionActive(c, claims)) {
+				setClaims(c, claims)
+				next(c, claims)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// NotImpersonated refuses requests an admin makes while acting as a user, for what only the user may
+// do such as changing their password
+func NotImpersonated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, impersonating := c.Get("impersonatorID"); impersonating {
+			apierror.Abort(c, apierror.Forbidden("Not allowed while acting as another user"))
+			return
+		}
 		c.Next()
 	}
 }
@@ -46,14 +116,12 @@ func AdminOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("userRole")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
-			c.Abort()
+			apierror.Abort(c, apierror.Unauthorized("User role not found"))
 			return
 		}
 
 		if userRole != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-			c.Abort()
+			apierror.Abort(c, apierror.Forbidden("Admin access required"))
 			return
 		}
 
@@ -1,20 +1,52 @@
-// middleware/cors.go
 package middleware
 
-import "github.com/gin-gonic/gin"
+import (
+	"learning_hub/pkg/config"
+	"log/slog"
+	"net/http"
 
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+// corsHeaders are the request headers the API reads, allowed from every origin
+var corsHeaders = []string{
+	"Origin", "Content-Type", "Content-Length", "Authorization", "Accept-Language", "Cache-Control",
+	RequestIDHeader, "X-Device-Fingerprint", "Idempotency-Key", "Last-Event-ID", "traceparent", "tracestate",
+}
+
+// corsExposedHeaders are the response headers scripts of other origins may read: file names of
+// downloads, request IDs for support and the rate limits
+var corsExposedHeaders = []string{
+	"Content-Length", "Content-Disposition", RequestIDHeader,
+	"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-Impersonated-By",
+}
 
-		c.Next()
+// CORS lets browser apps on the origins of CORS_ALLOWED_ORIGINS call the API. Preflight requests are
+// answered here, and cross-origin requests from other origins are refused with 403; requests without an
+// Origin, such as those of mobile apps and servers, are not affected.
+func CORS(cfg *config.Config) gin.HandlerFunc {
+	corsConfig := cors.Config{
+		AllowMethods: []string{
+			http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+			http.MethodHead, http.MethodOptions,
+		},
+		AllowHeaders:     append(append([]string{}, corsHeaders...), cfg.CORSAllowedHeaders...),
+		ExposeHeaders:    corsExposedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		AllowWildcard:    true,
+		MaxAge:           cfg.CORSMaxAge,
+	}
+	if len(cfg.CORSAllowedOrigins) == 1 && cfg.CORSAllowedOrigins[0] == "*" {
+		corsConfig.AllowAllOrigins = true
+	} else {
+		corsConfig.AllowOrigins = cfg.CORSAllowedOrigins
+		// Asked only for origins that aren't allowed, to tell why a browser app is refused
+		corsConfig.AllowOriginWithContextFunc = func(c *gin.Context, origin string) bool {
+			slog.WarnContext(c.Request.Context(), "Refused a cross-origin request, the origin is not in CORS_ALLOWED_ORIGINS",
+				"origin", origin, "method", c.Request.Method, "path", c.Request.URL.Path)
+			return false
+		}
 	}
+	return cors.New(corsConfig)
 }
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/logger"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request, in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// Request IDs accepted from callers, such as a proxy in front of the API
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives each request an ID, the caller's X-Request-ID when it sent a usable one, returned in
+// the X-Request-ID header and in error answers so a report can be matched with the logs. The ID is kept
+// in the request's context too, for the records logged with it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 12)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set("requestID", id)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// Errors writes the error a request was stopped with (see apierror.Abort) as the error envelope, unless
+// an answer was written already. Server errors are logged with their cause, and messages are translated
+// into the language of the request (see i18n.Locale).
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+		e := apierror.From(last.Err)
+		if last.IsType(gin.ErrorTypeBind) {
+			e = apierror.Bind(last.Err)
+		}
+		if e.Status >= http.StatusInternalServerError {
+			slog.ErrorContext(c.Request.Context(), "Request failed", "method", c.Request.Method, "path", c.Request.URL.Path,
+				"status", e.Status, "error", e)
+		}
+		e.Localize(i18n.Locale(c))
+		c.JSON(e.Status, e.Body(c.GetString("requestID")))
+	}
+}
+
+// Recovered answers a request whose handler panicked like any other server error, for gin.CustomRecovery
+func Recovered(c *gin.Context, recovered interface{}) {
+	apierror.Abort(c, apierror.Internal("Internal server error").Wrap(fmt.Errorf("panic: %v", recovered)))
+}
+
+// NoRoute answers requests to unknown routes with the error envelope
+func NoRoute(c *gin.Context) {
+	apierror.Abort(c, apierror.NotFound("No such endpoint: "+c.Request.Method+" "+c.Request.URL.Path))
+}
@@ -2,15 +2,26 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
-	"net/http"
+	"learning_hub/pkg/apierror"
 )
 
 func InstructorOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("userRole")
 		if !exists || userRole != "instructor" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Instructor access required"})
-			c.Abort()
+			apierror.Abort(c, apierror.Forbidden("Instructor access required"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// InstructorOrAdmin allows instructors and admins through
+func InstructorOrAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRole, exists := c.Get("userRole")
+		if !exists || (userRole != "instructor" && userRole != "admin") {
+			apierror.Abort(c, apierror.Forbidden("Instructor or admin access required"))
 			return
 		}
 		c.Next()
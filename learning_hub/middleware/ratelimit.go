@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/clock"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit allows each client IP at most limit requests per window (fixed window, in memory)
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	return RateLimitBy(window, func(c *gin.Context) (string, int) {
+		return c.ClientIP(), limit
+	})
+}
+
+// RateLimitBy is RateLimit with the client and its limit chosen per request, e.g. by API key
+func RateLimitBy(window time.Duration, client func(c *gin.Context) (key string, limit int)) gin.HandlerFunc {
+	type counter struct {
+		count   int
+		resetAt time.Time
+	}
+
+	var (
+		mu        sync.Mutex
+		clients   = make(map[string]*counter)
+		lastSweep time.Time
+	)
+
+	return func(c *gin.Context) {
+		now := clock.Now()
+		key, limit := client(c)
+
+		mu.Lock()
+		// Drop expired counters now and then so the map doesn't grow forever
+		if now.Sub(lastSweep) > window {
+			for key, entry := range clients {
+				if now.After(entry.resetAt) {
+					delete(clients, key)
+				}
+			}
+			lastSweep = now
+		}
+
+		entry, ok := clients[key]
+		if !ok || now.After(entry.resetAt) {
+			entry = &counter{resetAt: now.Add(window)}
+			clients[key] = entry
+		}
+		entry.count++
+		count, resetAt := entry.count, entry.resetAt
+		mu.Unlock()
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		if count > limit {
+			c.Header("X-RateLimit-Remaining", "0")
+			c.Header("Retry-After", strconv.Itoa(int(resetAt.Sub(now).Seconds())+1))
+			apierror.Abort(c, apierror.New(http.StatusTooManyRequests, "Too many requests, please try again later"))
+			return
+		}
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(limit-count))
+
+		c.Next()
+	}
+}
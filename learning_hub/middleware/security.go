@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"learning_hub/pkg/apierror"
+	"learning_hub/pkg/config"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiPolicy lets API answers load and run nothing and be framed by no page; routes serving pages or
+// files set their own
+const apiPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// SecurityHeaders sets the headers browsers enforce on every answer: no guessing content types, no
+// framing, no referrer sent on, the API's content policy, and Strict-Transport-Security when
+// HSTS_MAX_AGE is set
+func SecurityHeaders(cfg *config.Config) gin.HandlerFunc {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d; includeSubDomains", int64(cfg.HSTSMaxAge.Seconds()))
+	}
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "no-referrer")
+		header.Set("Content-Security-Policy", apiPolicy)
+		if hsts != "" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+// ServedFiles replaces the content policy for the files under /uploads with policy, and lets the web
+// app on the CORS origins embed them, as it does lesson videos, documents and SCORM packages. An empty
+// policy restricts only the framing, for courseware running its own scripts.
+func ServedFiles(cfg *config.Config, policy string) gin.HandlerFunc {
+	ancestors := "'self'"
+	for _, origin := range cfg.CORSAllowedOrigins {
+		ancestors += " " + origin
+	}
+	if policy != "" {
+		policy = strings.TrimSuffix(strings.TrimSpace(policy), ";") + "; "
+	}
+	policy += "frame-ancestors " + ancestors
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		// frame-ancestors says who may frame them; X-Frame-Options can't name other origins
+		header.Del("X-Frame-Options")
+		header.Set("Content-Security-Policy", policy)
+		c.Next()
+	}
+}
+
+// rawBodyKey keeps the body as the client sent it, for a route's BodyLimit to replace the router's
+const rawBodyKey = "rawBody"
+
+// DefaultBodyLimit answers 413 to requests whose handlers read more than limit bytes of the body. It
+// doesn't go by the Content-Length, which a route's BodyLimit may allow.
+func DefaultBodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limitBody(c, limit)
+		c.Next()
+	}
+}
+
+// BodyLimit replaces the router's limit for a route, such as an upload taking more than the default. It
+// answers 413 at once when the Content-Length is over limit, else when the handler reads past it.
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			apierror.Abort(c, apierror.TooLarge(limit))
+			return
+		}
+		limitBody(c, limit)
+		c.Next()
+	}
+}
+
+func limitBody(c *gin.Context, limit int64) {
+	raw, ok := c.Get(rawBodyKey)
+	if !ok {
+		raw = c.Request.Body
+		c.Set(rawBodyKey, raw)
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, raw.(io.ReadCloser), limit)
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"learning_hub/pkg/slo"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SLI records the outcome and latency of each request as part of a critical flow (see pkg/slo).
+// Put it first so requests rejected by later middleware count too.
+func SLI(flow string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		slo.Record(flow, c.Writer.Status(), time.Since(start))
+	}
+}
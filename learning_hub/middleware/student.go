@@ -2,15 +2,14 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
-	"net/http"
+	"learning_hub/pkg/apierror"
 )
 
 func StudentOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("userRole")
 		if !exists || userRole != "student" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Student access required"})
-			c.Abort()
+			apierror.Abort(c, apierror.Forbidden("Student access required"))
 			return
 		}
 		c.Next()
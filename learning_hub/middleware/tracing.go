@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing adds the request ID, and the user once authenticated, to the span otelgin.Middleware started
+// for the request, so a trace can be found from an error answer or a user's report. It runs after
+// otelgin.Middleware, which keeps the span in the request's context: handlers passing
+// c.Request.Context() to the database, Chapa or the email sender get those calls traced under it.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := trace.SpanFromContext(c.Request.Context())
+		if !span.IsRecording() {
+			c.Next()
+			return
+		}
+		span.SetAttributes(attribute.String("request.id", c.GetString("requestID")))
+		c.Next()
+
+		if userID, ok := c.Get("userID"); ok {
+			span.SetAttributes(attribute.String("enduser.id", fmt.Sprint(userID)))
+		}
+	}
+}
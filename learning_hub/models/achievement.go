@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Why points were awarded
+const (
+	PointsLessonCompleted = "lesson_completed" // SourceID is the lesson
+	PointsQuizPassed      = "quiz_passed"      // SourceID is the quiz, rewarded once however many attempts pass
+	PointsStreakDay       = "streak_day"       // SourceID is the day (days since 1970-01-01, UTC)
+)
+
+// What a badge counts, compared against its threshold
+const (
+	BadgeLessonsCompleted = "lessons_completed"
+	BadgeQuizzesPassed    = "quizzes_passed"
+	BadgeCoursesCompleted = "courses_completed"
+	BadgeStreakDays       = "streak_days" // longest streak
+	BadgePoints           = "points"
+)
+
+// PointEntry is points a user earned for one achievement. Each source is rewarded once.
+type PointEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_point_source;index" json:"user_id"`
+	CourseID  *uint     `gorm:"index" json:"course_id"` // nil for streak days
+	Reason    string    `gorm:"type:varchar(30);not null;uniqueIndex:idx_point_source" json:"reason"`
+	SourceID  uint      `gorm:"not null;uniqueIndex:idx_point_source" json:"source_id"`
+	Points    int       `gorm:"not null" json:"points"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// Badge is an achievement awarded once a user's count for Criterion reaches Threshold
+type Badge struct {
+	gorm.Model
+	Code        string `gorm:"type:varchar(50);not null;uniqueIndex" json:"code"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Description string `gorm:"type:text" json:"description"`
+	IconURL     string `json:"icon_url"`
+	Criterion   string `gorm:"type:varchar(30);not null" json:"criterion"`
+	Threshold   int    `gorm:"not null" json:"threshold"`
+}
+
+// UserBadge is a badge a user earned
+type UserBadge struct {
+	ID       uint      `gorm:"primaryKey" json:"id"`
+	UserID   uint      `gorm:"not null;uniqueIndex:idx_user_badge" json:"user_id"`
+	BadgeID  uint      `gorm:"not null;uniqueIndex:idx_user_badge" json:"badge_id"`
+	Badge    Badge     `gorm:"foreignKey:BadgeID" json:"badge,omitempty"`
+	EarnedAt time.Time `json:"earned_at"`
+}
+
+// LearningStreak counts the consecutive days (UTC) a user completed a lesson or passed a quiz
+type LearningStreak struct {
+	UserID       uint      `gorm:"primaryKey" json:"user_id"`
+	Current      int       `gorm:"not null;default:0" json:"current"`
+	Longest      int       `gorm:"not null;default:0" json:"longest"`
+	LastActiveOn time.Time `gorm:"type:date" json:"last_active_on"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
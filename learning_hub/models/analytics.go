@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// CourseView is a single (deduplicated, non-bot) view of a course detail page
+type CourseView struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CourseID  uint      `gorm:"not null;index:idx_course_view_visitor" json:"course_id"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	VisitorID string    `gorm:"type:varchar(64);not null;index:idx_course_view_visitor" json:"visitor_id"` // user id or hash of IP + user agent
+	Referrer  string    `gorm:"type:varchar(500)" json:"referrer"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for CourseView
+func (CourseView) TableName() string {
+	return "course_views"
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Announcement is a message an instructor broadcasts to everyone enrolled in a course
+type Announcement struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CourseID  uint           `gorm:"not null;index" json:"course_id"`
+	AuthorID  uint           `gorm:"not null" json:"author_id"`
+	Author    User           `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	Title     string         `gorm:"type:varchar(200);not null" json:"title"`
+	Body      string         `gorm:"type:text;not null" json:"body"`
+	Pinned    bool           `gorm:"default:false" json:"pinned"` // pinned announcements are listed first
+	PinnedAt  *time.Time     `json:"pinned_at"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -20,20 +20,24 @@ const (
 
 type Quiz struct {
 	gorm.Model
-	Title        string         `gorm:"type:varchar(200)" json:"title" binding:"required"`
-	Description  string         `gorm:"type:text" json:"description"`
-	Instructions string         `gorm:"type:text" json:"instructions"`
-	CourseID     uint           `gorm:"not null" json:"course_id"`
-	Course       Course         `gorm:"foreignKey:CourseID" json:"course,omitempty"`
-	ModuleID     *uint          `json:"module_id"`
-	Module       *Module        `gorm:"foreignKey:ModuleID" json:"module,omitempty"`
-	LessonID     *uint          `json:"lesson_id"`
-	Lesson       *Lesson        `gorm:"foreignKey:LessonID" json:"lesson,omitempty"`
-	TimeLimit    int            `json:"time_limit"` // in minutes
-	MaxAttempts  int            `gorm:"default:1" json:"max_attempts"`
-	PassingScore int            `gorm:"default:70" json:"passing_score"` // percentage
-	IsPublished  bool           `gorm:"default:false" json:"is_published"`
-	Questions    []QuizQuestion `gorm:"foreignKey:QuizID" json:"questions,omitempty"`
+	Title        string  `gorm:"type:varchar(200)" json:"title" binding:"required"`
+	Description  string  `gorm:"type:text" json:"description"`
+	Instructions string  `gorm:"type:text" json:"instructions"`
+	CourseID     uint    `gorm:"not null" json:"course_id"`
+	Course       Course  `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	ModuleID     *uint   `json:"module_id"`
+	Module       *Module `gorm:"foreignKey:ModuleID" json:"module,omitempty"`
+	LessonID     *uint   `json:"lesson_id"`
+	Lesson       *Lesson `gorm:"foreignKey:LessonID" json:"lesson,omitempty"`
+	TimeLimit    int     `json:"time_limit"` // in minutes
+	MaxAttempts  int     `gorm:"default:1" json:"max_attempts"`
+	PassingScore int     `gorm:"default:70" json:"passing_score"` // percentage
+	IsPublished  bool    `gorm:"default:false" json:"is_published"`
+	IsRequired   bool    `gorm:"default:false" json:"is_required"` // must be passed before a certificate is issued
+	// Optional window in which attempts can be started
+	OpensAt   *time.Time     `json:"opens_at"`
+	ClosesAt  *time.Time     `json:"closes_at"`
+	Questions []QuizQuestion `gorm:"foreignKey:QuizID" json:"questions,omitempty"`
 }
 
 type QuizQuestion struct {
@@ -46,23 +50,29 @@ type QuizQuestion struct {
 	Points        int          `gorm:"default:1" json:"points"`
 	Explanation   string       `gorm:"type:text" json:"explanation"`
 	OrderIndex    int          `gorm:"default:0" json:"order_index"`
+	// Coding questions with a language are run in the sandbox; CorrectAnswer is then the expected output
+	Language string `gorm:"type:varchar(30)" json:"language,omitempty"`
 }
 
 type QuizAttempt struct {
 	gorm.Model
-	UserID       uint         `gorm:"not null" json:"user_id"`
-	QuizID       uint         `gorm:"not null" json:"quiz_id"`
-	User         User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Quiz         Quiz         `gorm:"foreignKey:QuizID" json:"quiz,omitempty"`
-	Score        float64      `json:"score"` // percentage
-	TotalPoints  float64      `json:"total_points"`
-	EarnedPoints float64      `json:"earned_points"`
-	IsCompleted  bool         `gorm:"default:false" json:"is_completed"`
-	IsPassed     bool         `gorm:"default:false" json:"is_passed"`
-	StartedAt    time.Time    `json:"started_at"`
-	CompletedAt  *time.Time   `json:"completed_at"`
-	TimeSpent    int          `json:"time_spent"` // in seconds
-	Answers      []QuizAnswer `gorm:"foreignKey:AttemptID" json:"answers,omitempty"`
+	UserID uint `gorm:"not null" json:"user_id"`
+	QuizID uint `gorm:"not null" json:"quiz_id"`
+	User   User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Quiz   Quiz `gorm:"foreignKey:QuizID" json:"quiz,omitempty"`
+	// 1 for the student's first attempt at the quiz; unique among their attempts (idx_quiz_attempt_number)
+	AttemptNumber int          `gorm:"not null;default:0" json:"attempt_number"`
+	Score         float64      `json:"score"` // percentage
+	TotalPoints   float64      `json:"total_points"`
+	EarnedPoints  float64      `json:"earned_points"`
+	IsCompleted   bool         `gorm:"default:false" json:"is_completed"`
+	IsPassed      bool         `gorm:"default:false" json:"is_passed"`
+	StartedAt     time.Time    `json:"started_at"`
+	CompletedAt   *time.Time   `json:"completed_at"`
+	TimeSpent     int          `json:"time_spent"` // in seconds
+	Answers       []QuizAnswer `gorm:"foreignKey:AttemptID" json:"answers,omitempty"`
+	IsPaper       bool         `gorm:"default:false" json:"is_paper"` // taken on paper and entered by an instructor
+	RecordedByID  *uint        `json:"recorded_by_id,omitempty"`
 }
 
 type QuizAnswer struct {
@@ -99,6 +109,8 @@ type AssignmentSubmission struct {
 	FileURL        string     `gorm:"type:varchar(500)" json:"file_url"`
 	SubmissionText string     `gorm:"type:text" json:"submission_text"`
 	SubmittedAt    time.Time  `json:"submitted_at"`
+	FileHash       string     `gorm:"type:varchar(64)" json:"file_hash,omitempty"` // SHA-256 of the submitted file, hex
+	TextHash       string     `gorm:"type:varchar(64)" json:"text_hash,omitempty"` // SHA-256 of the submission text, hex
 	Grade          *float64   `json:"grade"`
 	GradedAt       *time.Time `json:"graded_at"`
 	Feedback       string     `gorm:"type:text" json:"feedback"`
@@ -127,3 +139,17 @@ func (j JSON) Value() (driver.Value, error) {
 	}
 	return []byte(j), nil
 }
+
+// MarshalJSON emits the stored document as-is instead of base64-encoded bytes
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+// UnmarshalJSON stores the raw JSON document
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[0:0], data...)
+	return nil
+}
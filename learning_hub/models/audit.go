@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Audited actions
+const (
+	AuditRoleChange      = "user.role_change"
+	AuditUserUnlock      = "user.unlock"
+	AuditTwoFactorReset  = "user.2fa_reset"
+	AuditSessionsRevoke  = "user.sessions_revoke"
+	AuditImpersonate     = "user.impersonate"
+	AuditImpersonation   = "impersonation.request" // a request an admin made while acting as a user
+	AuditUserDelete      = "user.delete"
+	AuditCourseDelete    = "course.delete"
+	AuditGradeChange     = "grade.change"
+	AuditPaymentStatus   = "payment.status_change"
+	AuditRefund          = "payment.refund"
+	AuditIntegrityRepair = "integrity.repair"
+	AuditSeatsGrant      = "organization.seats_grant"
+	AuditWebhookReplay   = "webhook.replay"
+	AuditWebhookEndpoint = "webhook_endpoint.change"
+	AuditAdminCreate     = "user.admin_create"
+	AuditPasswordSet     = "user.password_set"
+	AuditCertReissue     = "certificate.reissue"
+)
+
+// AuditLog records a sensitive action: who did what to which record, with the record before and after.
+// Entries are only ever added.
+type AuditLog struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// Empty for actions of the system, e.g. a payment webhook
+	ActorID    *uint     `gorm:"index" json:"actor_id"`
+	Actor      *User     `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	ActorRole  string    `gorm:"type:varchar(20)" json:"actor_role,omitempty"`
+	Action     string    `gorm:"type:varchar(50);not null;index" json:"action"`
+	EntityType string    `gorm:"type:varchar(50);not null;index:idx_audit_entity" json:"entity_type"`
+	EntityID   uint      `gorm:"not null;index:idx_audit_entity" json:"entity_id"`
+	Before     JSON      `gorm:"type:json" json:"before"`
+	After      JSON      `gorm:"type:json" json:"after"`
+	IP         string    `gorm:"type:varchar(64)" json:"ip,omitempty"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+
+	// The user an admin was acting as, when the action was made with an impersonation token
+	OnBehalfOfID *uint `gorm:"index" json:"on_behalf_of_id,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// InstructorAwayPeriod is a span during which an instructor is away. Students see the auto-reply,
+// and response-time metrics leave the period out.
+type InstructorAwayPeriod struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	InstructorID uint      `gorm:"not null;index" json:"instructor_id"`
+	StartsAt     time.Time `gorm:"not null;index" json:"starts_at"`
+	EndsAt       time.Time `gorm:"not null;index" json:"ends_at"`
+	AutoReply    string    `gorm:"type:text" json:"auto_reply"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// CertificateTemplate is the per-course certificate design used when issuing certificates
+type CertificateTemplate struct {
+	gorm.Model
+	CourseID uint   `gorm:"not null;uniqueIndex" json:"course_id"`
+	Course   Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+
+	// Artwork
+	BackgroundImageURL string `gorm:"type:varchar(500)" json:"background_image_url"`
+	SignatureImageURL  string `gorm:"type:varchar(500)" json:"signature_image_url"`
+
+	// Wording - supports {{student_name}}, {{course_title}}, {{instructor_name}}, {{issue_date}}, {{grade}} placeholders
+	Title          string `gorm:"type:varchar(200)" json:"title"`
+	Wording        string `gorm:"type:text" json:"wording"`
+	SignatoryName  string `gorm:"type:varchar(200)" json:"signatory_name"`
+	SignatoryTitle string `gorm:"type:varchar(200)" json:"signatory_title"`
+
+	// Text placement per element: {"student_name": {"x": 50, "y": 42, "font_size": 40}}
+	Placements JSON `gorm:"type:json" json:"placements"`
+
+	IsPublished bool `gorm:"default:false" json:"is_published"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// CourseCohort is a run of a course. Students belong to the cohort whose window their enrollment falls in,
+// so cohorts can be added after the fact to compare past runs.
+type CourseCohort struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CourseID    uint      `gorm:"not null;index" json:"course_id"`
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
+	StartsAt    time.Time `gorm:"not null" json:"starts_at"`
+	EndsAt      time.Time `gorm:"not null" json:"ends_at"`  // exclusive
+	Changes     string    `gorm:"type:text" json:"changes"` // what was changed in the content for this run
+	CreatedByID uint      `gorm:"not null" json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
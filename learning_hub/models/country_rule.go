@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Country rule actions
+const (
+	CountryRuleBlock = "block" // the course is hidden and cannot be bought in the country
+	CountryRuleAllow = "allow" // once a course has allow rules it is only offered in those countries
+	CountryRulePrice = "price" // the course costs Price in the country
+)
+
+// CourseCountryRule restricts or reprices a course for visitors from one country
+type CourseCountryRule struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CourseID    uint      `gorm:"not null;uniqueIndex:idx_course_country" json:"course_id"`
+	Country     string    `gorm:"type:varchar(2);not null;uniqueIndex:idx_course_country" json:"country"` // ISO 3166-1 alpha-2
+	Action      string    `gorm:"type:varchar(10);not null" json:"action"`
+	Price       *float64  `json:"price,omitempty"` // price override for allow and price rules
+	Note        string    `gorm:"type:varchar(255)" json:"note"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Course Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+}
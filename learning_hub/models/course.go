@@ -11,11 +11,28 @@ type Course struct {
 	Title        string  `gorm:"type:varchar(200)" json:"title" binding:"required"`
 	Description  string  `gorm:"type:text" json:"description" binding:"required"`
 	Price        float64 `gorm:"type:decimal(10,2)" json:"price"`
+	Currency     string  `gorm:"type:varchar(3)" json:"currency"` // ISO 4217 currency of the price, empty for the default
 	Category     string  `gorm:"type:varchar(100)" json:"category"`
 	Level        string  `gorm:"type:varchar(50)" json:"level" binding:"required,oneof=beginner intermediate advanced"`
 	ImageURL     string  `gorm:"type:varchar(500)" json:"image_url"`     // Updated to 500
 	ThumbnailURL string  `gorm:"type:varchar(500)" json:"thumbnail_url"` // Added thumbnail field
 	Published    bool    `gorm:"default:false" json:"published"`
+	ViewCount    int64   `gorm:"default:0" json:"view_count"`        // detail page views, see CourseView
+	ImageAlt     string  `gorm:"type:varchar(300)" json:"image_alt"` // alt text of the course image
+
+	// Percentage of accessibility items provided (captions, transcripts, alt text), kept up to date by the handlers
+	AccessibilityScore int `gorm:"default:0;index" json:"accessibility_score"`
+
+	// Estimated hours to complete the lessons and published quizzes, kept up to date by the handlers
+	WorkloadHours float64 `gorm:"default:0;index" json:"workload_hours"`
+
+	// Seasonal courses leave the catalog at this time; enrolled students keep their access
+	UnpublishAt           *time.Time `gorm:"index" json:"unpublish_at"`
+	UnpublishNoticeSentAt *time.Time `json:"-"` // the instructor was told the course is about to be unpublished
+
+	// The price converted to the visitor's preferred currency, for display only
+	DisplayPrice    *float64 `gorm:"-" json:"display_price,omitempty"`
+	DisplayCurrency string   `gorm:"-" json:"display_currency,omitempty"`
 
 	// Relationships
 	InstructorID uint         `json:"instructor_id"`
@@ -44,22 +61,110 @@ type Lesson struct {
 	DocumentURL string `gorm:"type:varchar(500)" json:"document_url"` // Added document field
 	Duration    int    `gorm:"default:0" json:"duration"`             // in minutes
 	OrderIndex  int    `gorm:"default:0" json:"order_index"`
+	IsPreview   bool   `gorm:"default:false" json:"is_preview"` // open to everyone to sample the course
+
+	// Content is Markdown (which may include HTML) or HTML, and students get it as sanitized HTML
+	ContentFormat string `gorm:"type:varchar(10);not null;default:'markdown'" json:"content_format"`
+	ContentHTML   string `gorm:"-" json:"content_html,omitempty"`
+
+	// Video duration plus reading time of the content, kept up to date by the handlers
+	EstimatedMinutes int `gorm:"default:0" json:"estimated_minutes"`
+
+	// Accessibility alternatives for the video. Alt text of images is read from the content.
+	CaptionsURL string `gorm:"type:varchar(500)" json:"captions_url"` // WebVTT captions
+	Transcript  string `gorm:"type:text" json:"transcript"`
+
+	// Code playground lessons: students edit the starter code and run it in the sandbox. With test
+	// cases, the lesson is completed once the student's code passes them all.
+	Type         string `gorm:"type:varchar(20);not null;default:'standard'" json:"type"` // standard or code
+	CodeLanguage string `gorm:"type:varchar(30)" json:"code_language,omitempty"`
+	StarterCode  string `gorm:"type:text" json:"starter_code,omitempty"`
+	CodeTests    JSON   `gorm:"type:json" json:"code_tests,omitempty"` // [{"name", "stdin", "expected_output", "hidden"}]
+
+	// How students complete the lesson, checked by the server whatever the client reports
+	CompletionCriterion    string `gorm:"type:varchar(20);not null;default:'manual'" json:"completion_criterion"` // manual, video or quiz
+	CompletionVideoPercent int    `gorm:"default:0" json:"completion_video_percent,omitempty"`                    // share of the video to watch
 
 	// Relationships
-	ModuleID uint   `json:"module_id"`
-	Module   Module `gorm:"foreignKey:ModuleID" json:"module,omitempty"`
+	ModuleID uint            `json:"module_id"`
+	Module   Module          `gorm:"foreignKey:ModuleID" json:"module,omitempty"`
+	Variants []LessonVariant `gorm:"foreignKey:LessonID" json:"variants,omitempty"`
+	Blocks   []LessonBlock   `gorm:"foreignKey:LessonID" json:"blocks,omitempty"`
+}
+
+// LessonVariant is an alternate-language version of a lesson's content.
+// Empty fields fall back to the lesson's default content.
+type LessonVariant struct {
+	gorm.Model
+	LessonID    uint   `gorm:"not null;uniqueIndex:idx_lesson_language" json:"lesson_id"`
+	Language    string `gorm:"type:varchar(16);not null;uniqueIndex:idx_lesson_language" json:"language"`
+	Title       string `gorm:"type:varchar(200)" json:"title"`
+	Content     string `gorm:"type:text" json:"content"`
+	VideoURL    string `gorm:"type:varchar(500)" json:"video_url"`
+	CaptionsURL string `gorm:"type:varchar(500)" json:"captions_url"` // WebVTT captions
+}
+
+// Localized returns a copy of the lesson with the variant's non-empty fields applied
+func (l Lesson) Localized(variant LessonVariant) Lesson {
+	if variant.Title != "" {
+		l.Title = variant.Title
+	}
+	if variant.Content != "" {
+		l.Content = variant.Content
+	}
+	if variant.VideoURL != "" {
+		l.VideoURL = variant.VideoURL
+	}
+	if variant.CaptionsURL != "" {
+		l.CaptionsURL = variant.CaptionsURL
+	}
+	l.Variants = nil
+	return l
+}
+
+// Lesson content formats
+const (
+	ContentFormatMarkdown = "markdown"
+	ContentFormatHTML     = "html"
+)
+
+// Lesson block types
+const (
+	BlockText  = "text" // Markdown
+	BlockVideo = "video"
+	BlockFile  = "file"
+	BlockEmbed = "embed" // an external page shown in a frame, e.g. slides or a simulation
+	BlockQuiz  = "quiz"  // one of the course's quizzes, taken in place
+)
+
+// LessonBlock is a piece of a lesson's page. Blocks are shown in order after the lesson's own content.
+type LessonBlock struct {
+	gorm.Model
+	LessonID    uint   `gorm:"not null;index" json:"lesson_id"`
+	Type        string `gorm:"type:varchar(20);not null" json:"type"`
+	OrderIndex  int    `gorm:"default:0" json:"order_index"`
+	Title       string `gorm:"type:varchar(200)" json:"title"`
+	Text        string `gorm:"type:text" json:"text"`                 // the Markdown of text blocks, a caption for others
+	URL         string `gorm:"type:varchar(500)" json:"url"`          // video, file and embed blocks
+	Duration    int    `gorm:"default:0" json:"duration"`             // video blocks, in minutes
+	CaptionsURL string `gorm:"type:varchar(500)" json:"captions_url"` // video blocks, WebVTT
+	QuizID      *uint  `gorm:"index" json:"quiz_id"`                  // quiz blocks
+	Quiz        *Quiz  `gorm:"foreignKey:QuizID" json:"quiz,omitempty"`
+	HTML        string `gorm:"-" json:"html,omitempty"` // Text rendered for students
 }
 
 // UpdateCourseInput is used for partial updates
 type UpdateCourseInput struct {
-	Title        string  `json:"title"`
-	Description  string  `json:"description"`
-	Price        float64 `json:"price"`
-	Category     string  `json:"category"`
-	Level        string  `json:"level"`
-	ImageURL     string  `json:"image_url"`
-	ThumbnailURL string  `json:"thumbnail_url"` // Added thumbnail field
-	Published    bool    `json:"published"`
+	Title        string   `json:"title" binding:"max=200"`
+	Description  string   `json:"description"`
+	Price        *float64 `json:"price" binding:"omitempty,gte=0"`    // omitted keeps the current one
+	Currency     string   `json:"currency" binding:"omitempty,len=3"` // empty keeps the current one
+	Category     string   `json:"category" binding:"max=100"`
+	Level        string   `json:"level" binding:"omitempty,oneof=beginner intermediate advanced"`
+	ImageURL     string   `json:"image_url" binding:"max=500"`
+	ThumbnailURL string   `json:"thumbnail_url" binding:"max=500"` // Added thumbnail field
+	ImageAlt     string   `json:"image_alt" binding:"max=300"`
+	Published    *bool    `json:"published"`
 }
 
 type LessonProgress struct {
@@ -71,6 +176,13 @@ type LessonProgress struct {
 	CompletedAt time.Time `json:"completed_at"`
 	TimeSpent   int       `gorm:"default:0" json:"time_spent"` // in minutes
 
+	// Seconds of the lesson video credited as watched, and when the player last reported
+	VideoWatchedSeconds int        `gorm:"default:0" json:"video_watched_seconds"`
+	VideoReportedAt     *time.Time `json:"video_reported_at"`
+
+	// Percentage score reported by the lesson's SCORM package or xAPI statements
+	Score *float64 `json:"score,omitempty"`
+
 	User   User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	Lesson Lesson `gorm:"foreignKey:LessonID" json:"lesson,omitempty"`
 	Course Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
@@ -78,14 +190,31 @@ type LessonProgress struct {
 
 type Review struct {
 	gorm.Model
-	UserID    uint      `json:"user_id"`
-	CourseID  uint      `json:"course_id"`
-	Rating    int       `gorm:"type:int;check:rating>=1 AND rating<=5" json:"rating" binding:"required,min=1,max=5"`
-	Comment   string    `gorm:"type:text" json:"comment"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	User      User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Course    Course    `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	UserID     uint       `json:"user_id"`
+	CourseID   uint       `json:"course_id"`
+	Rating     int        `gorm:"type:int;check:rating>=1 AND rating<=5" json:"rating" binding:"required,min=1,max=5"`
+	Comment    string     `gorm:"type:text" json:"comment"`
+	Status     string     `gorm:"type:varchar(20);not null;default:'published';index" json:"status"` // published, pending (held for moderation), rejected or hidden
+	Featured   bool       `gorm:"default:false;index" json:"featured"`                               // shown as a homepage testimonial, chosen by admins
+	FeaturedAt *time.Time `json:"featured_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	User       User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Course     Course     `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+
+	// One review per enrollment (nil on reviews written before this was enforced)
+	EnrollmentID     *uint `gorm:"uniqueIndex" json:"enrollment_id"`
+	VerifiedPurchase bool  `gorm:"default:false" json:"verified_purchase"` // the reviewer paid for the course
+
+	// The course instructor's public answer
+	Reply     string     `gorm:"type:text" json:"reply,omitempty"`
+	RepliedAt *time.Time `json:"replied_at,omitempty"`
+
+	// Admin moderation of published reviews: flagged reviews stay visible but are never featured
+	Flagged       bool       `gorm:"default:false;index" json:"flagged"`
+	FlagReason    string     `gorm:"type:varchar(255)" json:"flag_reason,omitempty"`
+	ModeratedByID *uint      `json:"moderated_by_id,omitempty"`
+	ModeratedAt   *time.Time `json:"moderated_at,omitempty"`
 }
 
 func (lp *LessonProgress) CalculateProgressPercentage(totalDuration int) float64 {
@@ -99,3 +228,10 @@ func (lp *LessonProgress) CalculateProgressPercentage(totalDuration int) float64
 	}
 	return progress
 }
+
+// Lesson completion criteria
+const (
+	CompletionManual = "manual" // the student marks the lesson complete
+	CompletionVideo  = "video"  // completed once CompletionVideoPercent of the video was watched
+	CompletionQuiz   = "quiz"   // completed by passing a quiz embedded in the lesson
+)
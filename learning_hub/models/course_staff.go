@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Roles of course staff besides the course's instructor (its owner)
+const (
+	StaffCoInstructor = "co_instructor" // manages the course like its instructor, but can't delete it or change its staff
+	StaffTA           = "ta"            // grades, moderates and sees analytics, but can't change the content
+)
+
+// CourseStaff lets another instructor help run a course
+type CourseStaff struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CourseID  uint      `gorm:"not null;uniqueIndex:idx_course_staff" json:"course_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_course_staff;index" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Role      string    `gorm:"type:varchar(20);not null" json:"role"`
+	AddedByID uint      `gorm:"not null" json:"added_by_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Device events recorded with a fingerprint
+const (
+	DeviceEventRegister = "register"
+	DeviceEventCheckout = "checkout"
+)
+
+// DeviceFingerprint is a browser or device identified by the fingerprint its client sends on registration
+// and checkout. Only a hash of the fingerprint is kept. Blocked devices can neither register nor check out.
+type DeviceFingerprint struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Hash        string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"hash"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `gorm:"index" json:"last_seen_at"`
+	// Raised by the correlation rules, e.g. ["5 accounts registered in 30 days"]
+	Flagged     bool       `gorm:"not null;default:false;index" json:"flagged"`
+	FlagReasons JSON       `gorm:"type:json" json:"flag_reasons"`
+	FlaggedAt   *time.Time `json:"flagged_at"`
+	// Set by admins
+	Blocked      bool       `gorm:"not null;default:false;index" json:"blocked"`
+	BlockReason  string     `gorm:"type:text" json:"block_reason,omitempty"`
+	ReviewedByID *uint      `json:"reviewed_by_id"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+}
+
+// DeviceEvent is a registration or checkout made from a device
+type DeviceEvent struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	FingerprintID uint      `gorm:"not null;index" json:"fingerprint_id"`
+	UserID        uint      `gorm:"not null;index" json:"user_id"`
+	User          User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Event         string    `gorm:"type:varchar(20);not null" json:"event"`
+	IP            string    `gorm:"type:varchar(45)" json:"ip"`
+	UserAgent     string    `gorm:"type:text" json:"user_agent"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// FileAccessLog records a download of a protected course file
+type FileAccessLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index:idx_file_access_user_time" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	LessonID  *uint     `gorm:"index" json:"lesson_id"`
+	CourseID  uint      `gorm:"index" json:"course_id"`
+	FilePath  string    `gorm:"type:varchar(500);not null" json:"file_path"`
+	Bytes     int64     `json:"bytes"`
+	IPAddress string    `gorm:"type:varchar(64)" json:"ip_address"`
+	UserAgent string    `gorm:"type:varchar(500)" json:"user_agent"`
+	Throttled bool      `gorm:"default:false;index" json:"throttled"` // request was refused by the download limit
+	CreatedAt time.Time `gorm:"index:idx_file_access_user_time" json:"created_at"`
+}
+
+// TableName specifies the table name for FileAccessLog
+func (FileAccessLog) TableName() string {
+	return "file_access_logs"
+}
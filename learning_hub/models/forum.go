@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ForumThread is a question or discussion in a course, optionally about one lesson
+type ForumThread struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CourseID       uint      `gorm:"not null;index" json:"course_id"`
+	LessonID       *uint     `gorm:"index" json:"lesson_id"`
+	UserID         uint      `gorm:"not null;index" json:"user_id"` // author
+	User           User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Title          string    `gorm:"type:varchar(200);not null" json:"title"`
+	Body           string    `gorm:"type:text;not null" json:"body"`
+	Status         string    `gorm:"type:varchar(20);not null;default:'published';index" json:"status"` // see ContentPublished
+	Pinned         bool      `gorm:"default:false" json:"pinned"`
+	Locked         bool      `gorm:"default:false" json:"locked"` // only course managers can reply
+	AnsweredPostID *uint     `json:"answered_post_id"`
+	Upvotes        int       `gorm:"default:0" json:"upvotes"`
+	LastActivityAt time.Time `gorm:"index" json:"last_activity_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// First reply of the course instructor and how long it took, not counting time they were away
+	InstructorRepliedAt *time.Time `json:"instructor_replied_at"`
+	ResponseSeconds     *int64     `json:"response_seconds"`
+
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ReplyCount int64          `gorm:"-" json:"reply_count"`
+	Posts      []ForumPost    `gorm:"foreignKey:ThreadID" json:"posts,omitempty"`
+}
+
+// ForumPost is a reply in a thread
+type ForumPost struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	ThreadID  uint           `gorm:"not null;index" json:"thread_id"`
+	UserID    uint           `gorm:"not null;index" json:"user_id"` // author
+	User      User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Body      string         `gorm:"type:text;not null" json:"body"`
+	Status    string         `gorm:"type:varchar(20);not null;default:'published';index" json:"status"`
+	IsAnswer  bool           `gorm:"default:false" json:"is_answer"`
+	Upvotes   int            `gorm:"default:0" json:"upvotes"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// ForumVote is a user's upvote of a thread or post
+type ForumVote struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_forum_vote" json:"user_id"`
+	ContentType string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_forum_vote" json:"content_type"` // thread or post
+	ContentID   uint      `gorm:"not null;uniqueIndex:idx_forum_vote" json:"content_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// GradingScale maps course grade percentages to letter grades.
+// A scale without a course is the platform default used by courses that have none.
+type GradingScale struct {
+	gorm.Model
+	CourseID      *uint   `gorm:"uniqueIndex" json:"course_id"`
+	Name          string  `gorm:"type:varchar(100)" json:"name"`
+	PassThreshold float64 `gorm:"not null;default:60" json:"pass_threshold"` // percentage
+
+	// Grade bands: [{"letter": "A", "min_percent": 90}, ...]
+	Bands JSON `gorm:"type:json" json:"bands"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Anomalies looked for by the consistency checker
+const (
+	IntegrityEnrollmentWithoutPayment = "enrollment_without_payment" // active enrollment in a paid course nobody paid for
+	IntegrityPaymentWithoutEnrollment = "payment_without_enrollment" // successful payment that didn't enroll
+	IntegrityOrphanedProgress         = "orphaned_progress"          // progress of a lesson deleted for good
+	IntegrityUnearnedCertificate      = "unearned_certificate"       // certificate of an enrollment never completed
+)
+
+// How an integrity issue was resolved
+const (
+	IntegrityRepaired  = "repaired"
+	IntegrityDismissed = "dismissed" // accepted as intended; the checker won't raise it again
+	IntegrityCleared   = "cleared"   // no longer found by the checker
+)
+
+// IntegrityIssue is an anomaly found by the consistency checker. An issue found again after it was
+// repaired or cleared is reopened; a dismissed one stays dismissed.
+type IntegrityIssue struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Type       string     `gorm:"type:varchar(50);not null;uniqueIndex:idx_integrity_type_entity" json:"type"`
+	EntityType string     `gorm:"type:varchar(50);not null" json:"entity_type"`
+	EntityID   string     `gorm:"type:varchar(100);not null;uniqueIndex:idx_integrity_type_entity" json:"entity_id"`
+	UserID     uint       `gorm:"index" json:"user_id"`
+	CourseID   uint       `gorm:"index" json:"course_id"`
+	Details    JSON       `gorm:"type:json" json:"details"`
+	DetectedAt time.Time  `gorm:"not null" json:"detected_at"`
+	LastSeenAt time.Time  `gorm:"not null" json:"last_seen_at"`
+	ResolvedAt *time.Time `gorm:"index" json:"resolved_at"`
+	ResolvedBy *uint      `json:"resolved_by,omitempty"`
+	Resolution string     `gorm:"type:varchar(20);not null;default:''" json:"resolution,omitempty"`
+	Note       string     `gorm:"type:text" json:"note,omitempty"` // why it was dismissed
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// JobRun is a finished run of a background job on one instance of the API. Status is one of the
+// results of pkg/jobs: success, failure, panic or interrupted.
+type JobRun struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Job        string    `gorm:"type:varchar(100);not null;index:idx_job_run_job_time" json:"job"`
+	Instance   string    `gorm:"type:varchar(255)" json:"instance"`
+	Status     string    `gorm:"type:varchar(20);not null;index" json:"status"`
+	Error      string    `gorm:"type:text" json:"error,omitempty"`
+	StartedAt  time.Time `gorm:"not null;index:idx_job_run_job_time" json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// LearningPath is an instructor-defined ordering of a subset of a course's lessons,
+// e.g. a "fast track" cutting across modules. Students may follow one instead of the full course.
+type LearningPath struct {
+	gorm.Model
+	CourseID    uint               `gorm:"not null;index" json:"course_id"`
+	Course      Course             `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	Title       string             `gorm:"type:varchar(200);not null" json:"title"`
+	Description string             `gorm:"type:text" json:"description"`
+	Items       []LearningPathItem `gorm:"foreignKey:LearningPathID" json:"items,omitempty"`
+}
+
+// LearningPathItem places a lesson at a position in a learning path
+type LearningPathItem struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	LearningPathID uint   `gorm:"not null;uniqueIndex:idx_path_lesson" json:"learning_path_id"`
+	LessonID       uint   `gorm:"not null;uniqueIndex:idx_path_lesson" json:"lesson_id"`
+	Lesson         Lesson `gorm:"foreignKey:LessonID" json:"lesson,omitempty"`
+	Position       int    `gorm:"not null" json:"position"`
+}
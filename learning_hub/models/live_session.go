@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Live session states
+const (
+	LiveSessionScheduled = "scheduled"
+	LiveSessionCancelled = "cancelled"
+)
+
+// LiveSession is a live class of a course held in a video meeting created with the configured provider
+type LiveSession struct {
+	gorm.Model
+	CourseID        uint      `gorm:"not null;index" json:"course_id"`
+	Course          Course    `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	Title           string    `gorm:"type:varchar(200);not null" json:"title"`
+	Description     string    `gorm:"type:text" json:"description"`
+	StartsAt        time.Time `gorm:"not null;index" json:"starts_at"`
+	DurationMinutes int       `gorm:"not null" json:"duration_minutes"`
+	Status          string    `gorm:"type:varchar(20);not null;default:'scheduled';index" json:"status"`
+	CreatedByID     uint      `gorm:"not null" json:"created_by_id"`
+
+	// Meeting, see pkg/meeting. Students get the join URL when they join, so attendance is recorded.
+	Provider  string `gorm:"type:varchar(20)" json:"provider"`
+	MeetingID string `gorm:"type:varchar(100)" json:"-"`
+	JoinURL   string `gorm:"type:text" json:"-"`
+	HostURL   string `gorm:"type:text" json:"-"`
+
+	ReminderSentAt *time.Time `json:"reminder_sent_at"`
+}
+
+// EndsAt is when the session is scheduled to end
+func (s LiveSession) EndsAt() time.Time {
+	return s.StartsAt.Add(time.Duration(s.DurationMinutes) * time.Minute)
+}
+
+// LiveAttendance records a user joining a live session
+type LiveAttendance struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	SessionID     uint      `gorm:"not null;uniqueIndex:idx_live_attendance" json:"session_id"`
+	UserID        uint      `gorm:"not null;uniqueIndex:idx_live_attendance;index" json:"user_id"`
+	FirstJoinedAt time.Time `json:"first_joined_at"`
+	LastJoinedAt  time.Time `json:"last_joined_at"`
+	Joins         int       `gorm:"not null;default:1" json:"joins"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// LoginAttempt records a password login, successful or not. UserID is empty when no account has the email.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    *uint     `gorm:"index:idx_login_attempt_user_time" json:"user_id"`
+	Email     string    `gorm:"type:varchar(100);not null" json:"email"`
+	IP        string    `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent string    `gorm:"type:varchar(300)" json:"user_agent"`
+	Success   bool      `gorm:"not null" json:"success"`
+	Reason    string    `gorm:"type:varchar(30)" json:"reason,omitempty"` // why a login failed: unknown_email, wrong_password, locked or unverified
+	CreatedAt time.Time `gorm:"index:idx_login_attempt_user_time" json:"created_at"`
+}
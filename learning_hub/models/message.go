@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Conversation is a private thread between a student and the instructor of a course
+type Conversation struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CourseID      uint      `gorm:"not null;uniqueIndex:idx_conversation_course_student" json:"course_id"`
+	Course        Course    `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	StudentID     uint      `gorm:"not null;uniqueIndex:idx_conversation_course_student" json:"student_id"`
+	Student       User      `gorm:"foreignKey:StudentID" json:"student,omitempty"`
+	InstructorID  uint      `gorm:"not null;index" json:"instructor_id"`
+	Instructor    User      `gorm:"foreignKey:InstructorID" json:"instructor,omitempty"`
+	LastMessageAt time.Time `gorm:"index" json:"last_message_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UnreadCount   int64     `gorm:"-" json:"unread_count"`
+	LastMessage   *Message  `gorm:"-" json:"last_message,omitempty"`
+}
+
+// Message is one message of a conversation. ReadAt is set once the recipient has seen it.
+type Message struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ConversationID uint       `gorm:"not null;index" json:"conversation_id"`
+	SenderID       uint       `gorm:"not null" json:"sender_id"`
+	Body           string     `gorm:"type:text;not null" json:"body"`
+	ReadAt         *time.Time `json:"read_at"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,84 @@
+package models
+
+// All returns every persisted model, in migration order
+func All() []interface{} {
+	return []interface{}{
+		&User{},
+		&Course{},
+		&Module{},
+		&Lesson{},
+		&LessonVariant{},
+		&Enrollment{},
+		&Payment{},
+		&LessonProgress{},
+		&Certificate{},
+		&Review{},
+		&Quiz{},
+		&QuizQuestion{},
+		&QuizAttempt{},
+		&QuizAnswer{},
+		&Assignment{},
+		&AssignmentSubmission{},
+		&CertificateTemplate{},
+		&CourseView{},
+		&FileAccessLog{},
+		&VideoTranscode{},
+		&LearningPath{},
+		&LearningPathItem{},
+		&GradingScale{},
+		&UploadedFile{},
+		&QuarantinedFile{},
+		&UploadQuota{},
+		&InstructorAwayPeriod{},
+		&ModerationItem{},
+		&PublishRule{},
+		&PaymentExport{},
+		&CourseCountryRule{},
+		&Notification{},
+		&NotificationPreference{},
+		&Announcement{},
+		&ForumThread{},
+		&ForumPost{},
+		&ForumVote{},
+		&Conversation{},
+		&Message{},
+		&CourseCohort{},
+		&Wishlist{},
+		&LessonCode{},
+		&Track{},
+		&TrackCourse{},
+		&TrackEnrollment{},
+		&SLISample{},
+		&SLOAlert{},
+		&DeviceFingerprint{},
+		&DeviceEvent{},
+		&PointEntry{},
+		&Badge{},
+		&UserBadge{},
+		&LearningStreak{},
+		&LiveSession{},
+		&LiveAttendance{},
+		&VerificationAPIKey{},
+		&Setting{},
+		&AuditLog{},
+		&LoginAttempt{},
+		&TwoFactorBackupCode{},
+		&TwoFactorChallenge{},
+		&UserIdentity{},
+		&Session{},
+		&IntegrityIssue{},
+		&PaymentReconciliation{},
+		&WebhookEvent{},
+		&WebhookEndpoint{},
+		&WebhookDelivery{},
+		&Organization{},
+		&OrganizationMember{},
+		&OrganizationSeat{},
+		&CourseStaff{},
+		&ScormPackage{},
+		&ScormAttempt{},
+		&XAPIStatement{},
+		&LessonBlock{},
+		&JobRun{},
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// Publication states of user-posted content such as reviews
+const (
+	ContentPublished = "published"
+	ContentPending   = "pending" // held for moderation
+	ContentRejected  = "rejected"
+	ContentHidden    = "hidden" // taken down by an admin after it was published
+)
+
+// ModerationItem is user-posted content the spam check held back, waiting for an admin decision
+type ModerationItem struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	ContentType  string     `gorm:"type:varchar(30);not null;index:idx_moderation_content" json:"content_type"` // e.g. review
+	ContentID    uint       `gorm:"not null;index:idx_moderation_content" json:"content_id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	User         User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Excerpt      string     `gorm:"type:text" json:"excerpt"`
+	Score        int        `json:"score"`
+	Reasons      JSON       `gorm:"type:json" json:"reasons"` // ["contains 3 links", ...]
+	Status       string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	ReviewedByID *uint      `json:"reviewed_by_id"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification types
+const (
+	NotificationGrading       = "grading"        // an assignment submission or paper exam was graded
+	NotificationPayment       = "payment"        // a payment succeeded or failed
+	NotificationPaymentExport = "payment_export" // a background payment export finished
+	NotificationAnnouncement  = "announcement"   // an instructor posted a course announcement
+	NotificationForumReply    = "forum_reply"    // a reply in the user's thread, or their reply was accepted
+	NotificationMessage       = "message"        // a direct message from a student or instructor
+	NotificationWishlist      = "wishlist"       // a wishlisted course got cheaper or was published
+	NotificationReviewReply   = "review_reply"   // the instructor replied to the user's course review
+	NotificationTrackComplete = "track_complete" // the user completed every course of a track
+	NotificationSLOBurn       = "slo_burn"       // a critical flow burns its error budget too fast (admins)
+	NotificationDeviceFlagged = "device_flagged" // a device fingerprint matched an abuse rule (admins)
+	NotificationBadgeEarned   = "badge_earned"   // the user earned a badge
+	NotificationLiveSession   = "live_session"   // a live class was scheduled, rescheduled, cancelled or starts soon
+	NotificationUnpublish     = "unpublish"      // the instructor's course is about to leave, or left, the catalog
+	NotificationOrganization  = "organization"   // the user joined an organization or it assigned them a course
+	NotificationCourseStaff   = "course_staff"   // the user was added to a course's staff
+	NotificationReconcile     = "reconciliation" // payment reconciliation settled payments or found mismatches (admins)
+)
+
+// Notification categories users can turn on or off per channel
+const (
+	CategoryMarketing     = "marketing"
+	CategoryCourseUpdates = "course_updates" // announcements, enrollments, certificates
+	CategoryGrading       = "grading"        // submission receipts and grades
+	CategoryPayments      = "payments"       // payment receipts and results
+	CategoryMessages      = "messages"       // direct messages
+)
+
+// NotificationCategories lists the categories with whether they are on by default. Marketing is opt-in.
+var NotificationCategories = map[string]bool{
+	CategoryMarketing:     false,
+	CategoryCourseUpdates: true,
+	CategoryGrading:       true,
+	CategoryPayments:      true,
+	CategoryMessages:      true,
+}
+
+// NotificationTypeCategories maps notification types to the category that controls them.
+// Types not listed are always delivered.
+var NotificationTypeCategories = map[string]string{
+	NotificationGrading:       CategoryGrading,
+	NotificationPayment:       CategoryPayments,
+	NotificationAnnouncement:  CategoryCourseUpdates,
+	NotificationForumReply:    CategoryCourseUpdates,
+	NotificationMessage:       CategoryMessages,
+	NotificationWishlist:      CategoryCourseUpdates,
+	NotificationReviewReply:   CategoryCourseUpdates,
+	NotificationTrackComplete: CategoryCourseUpdates,
+	NotificationBadgeEarned:   CategoryCourseUpdates,
+	NotificationLiveSession:   CategoryCourseUpdates,
+	NotificationOrganization:  CategoryCourseUpdates,
+}
+
+// NotificationPreference is a user's choice for one category. Without a row the category's default applies.
+type NotificationPreference struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_notification_category" json:"-"`
+	Category  string    `gorm:"type:varchar(30);not null;uniqueIndex:idx_user_notification_category" json:"category"`
+	Email     bool      `json:"email"`
+	InApp     bool      `json:"in_app"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Notification is a message for a user, pushed to open connections and kept for later reading.
+// Deleted notifications stay as tombstones for a while so other devices can sync the deletion.
+type Notification struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	UserID    uint           `gorm:"not null;index" json:"user_id"`
+	Type      string         `gorm:"type:varchar(30);not null" json:"type"`
+	Title     string         `gorm:"type:varchar(200);not null" json:"title"`
+	Data      JSON           `gorm:"type:json" json:"data"`
+	ReadAt    *time.Time     `json:"read_at"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `gorm:"index" json:"updated_at"` // last change of read state or deletion
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
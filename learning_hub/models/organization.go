@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Roles of organization members
+const (
+	OrgRoleAdmin  = "admin"  // buys seats, invites members and assigns courses
+	OrgRoleMember = "member" // takes the courses assigned to them
+)
+
+// Organization is a company that buys course seats for its employees
+type Organization struct {
+	gorm.Model
+	Name        string `gorm:"type:varchar(200);not null" json:"name"`
+	CreatedByID uint   `gorm:"not null;index" json:"created_by_id"`
+}
+
+// OrganizationMember puts a user in an organization
+type OrganizationMember struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_org_member" json:"organization_id"`
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_org_member;index" json:"user_id"`
+	User           User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Role           string    `gorm:"type:varchar(20);not null;default:'member'" json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// OrganizationSeat counts the seats an organization bought for a course; assigning the course to a
+// member uses one
+type OrganizationSeat struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_org_course" json:"organization_id"`
+	CourseID       uint      `gorm:"not null;uniqueIndex:idx_org_course" json:"course_id"`
+	Course         Course    `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	Purchased      int       `gorm:"not null;default:0" json:"purchased"`
+	Used           int       `gorm:"not null;default:0" json:"used"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"   // waiting for its next attempt
+	DeliverySucceeded = "succeeded" // the endpoint answered 2xx
+	DeliveryFailed    = "failed"    // given up after the last attempt
+)
+
+// WebhookEndpoint is a URL registered by an admin to receive platform events, signed with its secret
+type WebhookEndpoint struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	URL         string    `gorm:"type:varchar(500);not null" json:"url"`
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	Secret      string    `gorm:"type:varchar(100);not null" json:"-"`
+	Events      string    `gorm:"type:varchar(500);not null" json:"events"` // comma-separated event types
+	Active      bool      `gorm:"not null;default:true" json:"active"`
+	CreatedByID uint      `gorm:"not null" json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is an event sent, or to be sent, to an endpoint, with the result of its last attempt
+type WebhookDelivery struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	EndpointID    uint       `gorm:"not null;index" json:"endpoint_id"`
+	EventID       string     `gorm:"type:varchar(50);not null;index" json:"event_id"` // shared by the deliveries of an event
+	EventType     string     `gorm:"type:varchar(50);not null;index" json:"event_type"`
+	Payload       JSON       `gorm:"type:json" json:"payload,omitempty"`
+	Status        string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at"`
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+	ResponseCode  int        `json:"response_code,omitempty"`
+	ResponseBody  string     `gorm:"type:text" json:"response_body,omitempty"`
+	Error         string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt     time.Time  `gorm:"index" json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+}
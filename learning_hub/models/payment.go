@@ -2,6 +2,8 @@ package models
 
 import (
 	"fmt"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/idgen"
 	"time"
 
 	"gorm.io/gorm"
@@ -15,25 +17,51 @@ const (
 	PaymentStatusSuccess   PaymentStatus = "success"
 	PaymentStatusFailed    PaymentStatus = "failed"
 	PaymentStatusCancelled PaymentStatus = "cancelled"
+	PaymentStatusRefunded  PaymentStatus = "refunded"
 )
 
 // Payment represents a payment transaction
 type Payment struct {
 	ID       uint   `gorm:"primaryKey" json:"id"`
-	UserID   uint   `gorm:"not null" json:"user_id"`
+	UserID   uint   `gorm:"not null;uniqueIndex:idx_payment_idempotency" json:"user_id"`
 	User     User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	CourseID uint   `gorm:"not null" json:"course_id"`
 	Course   Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
 
+	// The Idempotency-Key the buyer started the payment with; retries with the same key get this payment back
+	IdempotencyKey *string `gorm:"size:100;uniqueIndex:idx_payment_idempotency" json:"-"`
+
+	// Set for a track bundle purchase, which enrolls in every course of the track; CourseID is then its first course
+	TrackID *uint `gorm:"index" json:"track_id,omitempty"`
+
+	// Set for seats bought by an organization, which are credited to it instead of enrolling the payer
+	OrganizationID *uint `gorm:"index" json:"organization_id,omitempty"`
+	Seats          int   `gorm:"not null;default:0" json:"seats,omitempty"`
+
 	// Payment Details
 	Amount     float64 `gorm:"not null" json:"amount"`
 	Currency   string  `gorm:"size:10;not null;default:'ETB'" json:"currency"`
 	ChapaTxRef string  `gorm:"size:100;not null;uniqueIndex" json:"chapa_tx_ref"`
 	ChapaRefID string  `gorm:"size:100" json:"chapa_ref_id"` // Chapa's internal reference
 
+	// Tax included in Amount, in percent, at the rate in force when the payment was started
+	TaxRate float64 `gorm:"not null;default:0" json:"tax_rate"`
+
+	// Who takes the payment: chapa, or stripe for currencies Chapa doesn't handle. ChapaTxRef is our
+	// reference with either; ProviderRef is Stripe's checkout session.
+	Provider    string `gorm:"size:20;not null;default:'chapa'" json:"provider"`
+	ProviderRef string `gorm:"size:255;index" json:"provider_ref,omitempty"`
+
+	// The provider's page the buyer pays on, offered again when they retry while the payment is pending
+	CheckoutURL string `gorm:"size:1000" json:"checkout_url,omitempty"`
+
+	// When reconciliation found it paid at the provider for another amount or currency; it is left pending
+	// for an admin and not checked again
+	MismatchFlaggedAt *time.Time `json:"mismatch_flagged_at,omitempty"`
+
 	// Status
 	Status        PaymentStatus `gorm:"size:20;not null;default:'pending'" json:"status"`
-	PaymentMethod string        `gorm:"size:50" json:"payment_method"`
+	PaymentMethod string        `gorm:"size:50;index" json:"payment_method"` // telebirr, cbebirr, card... from Chapa verification
 
 	// Timestamps
 	CreatedAt time.Time `json:"created_at"`
@@ -60,6 +88,15 @@ type Enrollment struct {
 	Payment   *Payment `gorm:"foreignKey:PaymentID" json:"payment,omitempty"`
 	IsActive  bool     `gorm:"not null;default:true" json:"is_active"`
 
+	// Learning path the student follows instead of the full course order (nil = whole course)
+	LearningPathID *uint `gorm:"index" json:"learning_path_id"`
+
+	// Organization whose seat the enrollment uses (nil = the student enrolled themselves)
+	OrganizationID *uint `gorm:"index" json:"organization_id"`
+
+	// Preferred content language for lessons with language variants (empty = course default)
+	PreferredLanguage string `gorm:"type:varchar(16)" json:"preferred_language"`
+
 	// Progress tracking
 	Progress      float64 `gorm:"not null;default:0" json:"progress"` // Percentage completed
 	CurrentModule *uint   `gorm:"index" json:"current_module"`
@@ -93,19 +130,49 @@ type Certificate struct {
 	ExpiryDate     *time.Time `json:"expiry_date"`
 	CertificateURL *string    `gorm:"type:text" json:"certificate_url"`
 
+	// Course grade at the time of issue (empty when nothing was graded)
+	Grade        string   `gorm:"type:varchar(8)" json:"grade,omitempty"`
+	GradePercent *float64 `json:"grade_percent,omitempty"`
+
 	// Verification
 	VerificationCode string `gorm:"type:varchar(50);uniqueIndex" json:"verification_code"`
 
+	// Revocation
+	RevokedAt        *time.Time `gorm:"index" json:"revoked_at"`
+	RevokedBy        *uint      `json:"revoked_by"`
+	RevocationReason string     `gorm:"type:text" json:"revocation_reason,omitempty"`
+
+	// Set once the holder has been told the certificate is about to expire
+	ExpiryNotifiedAt *time.Time `json:"expiry_notified_at"`
+
 	// Timestamps
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Certificate statuses reported by verification
+const (
+	CertificateStatusValid   = "valid"
+	CertificateStatusExpired = "expired"
+	CertificateStatusRevoked = "revoked"
+)
+
 // TableName specifies the table name for Certificate
 func (Certificate) TableName() string {
 	return "certificates"
 }
 
+// Status returns whether the certificate is valid, expired or revoked at the given time
+func (c *Certificate) Status(now time.Time) string {
+	if c.RevokedAt != nil {
+		return CertificateStatusRevoked
+	}
+	if c.ExpiryDate != nil && !now.Before(*c.ExpiryDate) {
+		return CertificateStatusExpired
+	}
+	return CertificateStatusValid
+}
+
 // BeforeCreate generates a unique transaction reference
 func (p *Payment) BeforeCreate(tx *gorm.DB) error {
 	if p.ChapaTxRef == "" {
@@ -116,15 +183,10 @@ func (p *Payment) BeforeCreate(tx *gorm.DB) error {
 
 // GenerateTxRef generates a unique transaction reference
 func GenerateTxRef() string {
-	return fmt.Sprintf("learnhub-%d-%s", time.Now().Unix(), GenerateRandomString(8))
+	return fmt.Sprintf("learnhub-%d-%s", clock.Now().Unix(), GenerateRandomString(8))
 }
 
 // GenerateRandomString generates a random string
 func GenerateRandomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
-	}
-	return string(b)
+	return idgen.String(length)
 }
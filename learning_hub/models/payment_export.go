@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// Payment export job states
+const (
+	ExportStatusPending    = "pending"
+	ExportStatusProcessing = "processing"
+	ExportStatusReady      = "ready"
+	ExportStatusFailed     = "failed"
+)
+
+// PaymentExport is an accounting export of payments and refunds generated in the background
+type PaymentExport struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	RequestedByID uint       `gorm:"not null;index" json:"requested_by_id"`
+	Format        string     `gorm:"type:varchar(20);not null" json:"format"`
+	Columns       JSON       `gorm:"type:json" json:"columns"`                   // accounting.Column mapping used for the file
+	Statuses      string     `gorm:"type:varchar(100);not null" json:"statuses"` // comma-separated payment statuses
+	From          time.Time  `json:"from"`
+	To            time.Time  `json:"to"` // exclusive
+	Status        string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	StorageKey    string     `gorm:"type:varchar(500)" json:"-"`
+	RowCount      int        `json:"row_count"`
+	Error         string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CompletedAt   *time.Time `json:"completed_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Lesson types
+const (
+	LessonTypeStandard = "standard"
+	LessonTypeCode     = "code"  // a code playground students run in the sandbox
+	LessonTypeScorm    = "scorm" // plays an uploaded SCORM package, see ScormPackage
+)
+
+// LessonCode is a student's code in a playground lesson and the result of its last test run
+type LessonCode struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;uniqueIndex:idx_lesson_code_user" json:"user_id"`
+	LessonID    uint       `gorm:"not null;uniqueIndex:idx_lesson_code_user;index" json:"lesson_id"`
+	Code        string     `gorm:"type:text" json:"code"`
+	TestsPassed int        `json:"tests_passed"`
+	TestsTotal  int        `json:"tests_total"`
+	Passed      bool       `gorm:"default:false" json:"passed"` // all of the lesson's tests passed once
+	CheckedAt   *time.Time `json:"checked_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// PublishRule is an admin-defined requirement a course must meet before it can be published
+type PublishRule struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Rule      string    `gorm:"type:varchar(50);not null;uniqueIndex" json:"rule"` // e.g. min_lessons, see handlers.publishRuleTypes
+	Threshold float64   `gorm:"not null" json:"threshold"`
+	Enabled   bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
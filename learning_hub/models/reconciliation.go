@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// What the reconciliation job found about a pending payment
+const (
+	ReconcileMissedWebhook  = "missed_webhook"  // paid at the provider but never confirmed to us; now settled
+	ReconcileProviderFailed = "provider_failed" // failed at the provider; now marked failed
+	ReconcileAbandoned      = "abandoned"       // still unpaid long after checkout started; now marked failed
+	ReconcileAmountMismatch = "amount_mismatch" // paid at the provider for another amount or currency; left for an admin
+	ReconcileVerifyError    = "verify_error"    // the provider couldn't be asked; retried next run
+	ReconcileNotEnrolled    = "not_enrolled"    // paid but its buyer never enrolled, e.g. after a crash; now enrolled
+	ReconcileEnrollError    = "enroll_error"    // paid but its buyer couldn't be enrolled; retried next run
+)
+
+// PaymentReconciliation is the report of one run of the job that re-verifies pending payments with their
+// provider and enrolls the buyers of successful ones left without. Details lists every payment it changed
+// or that needs an admin.
+type PaymentReconciliation struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	StartedAt  time.Time `gorm:"not null;index" json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Checked    int       `gorm:"not null;default:0" json:"checked"`
+	Settled    int       `gorm:"not null;default:0" json:"settled"`
+	Failed     int       `gorm:"not null;default:0" json:"failed"`
+	Mismatches int       `gorm:"not null;default:0" json:"mismatches"`
+	Enrolled   int       `gorm:"not null;default:0" json:"enrolled"`
+	Errors     int       `gorm:"not null;default:0" json:"errors"`
+	Details    JSON      `gorm:"type:json" json:"details"`
+}
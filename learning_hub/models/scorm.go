@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// SCORM versions of packages
+const (
+	ScormVersion12   = "1.2"
+	ScormVersion2004 = "2004"
+)
+
+// ScormPackage is the SCORM package a lesson of type scorm plays. Its files are unpacked to storage
+// under StoragePrefix, which copies of the lesson share.
+type ScormPackage struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	LessonID      uint      `gorm:"not null;uniqueIndex" json:"lesson_id"`
+	Version       string    `gorm:"type:varchar(10);not null" json:"version"` // 1.2 or 2004
+	Title         string    `gorm:"type:varchar(200)" json:"title"`
+	LaunchPath    string    `gorm:"type:varchar(500);not null" json:"launch_path"` // the page to open, relative to the package
+	StoragePrefix string    `gorm:"type:varchar(100);not null;index" json:"-"`
+	Paths         JSON      `gorm:"type:json" json:"-"` // unpacked files, relative to StoragePrefix
+	Files         int       `json:"files"`
+	Size          int64     `json:"size"` // unpacked, in bytes
+	UploadedByID  uint      `json:"uploaded_by_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ScormAttempt is a student's state in a lesson's SCORM package: the data model its runtime committed and
+// the results it or xAPI statements reported
+type ScormAttempt struct {
+	ID               uint     `gorm:"primaryKey" json:"id"`
+	LessonID         uint     `gorm:"not null;uniqueIndex:idx_scorm_attempt;index" json:"lesson_id"`
+	UserID           uint     `gorm:"not null;uniqueIndex:idx_scorm_attempt" json:"user_id"`
+	Data             JSON     `gorm:"type:json" json:"data"`                                                // {"cmi.core.lesson_location": "3", ...}
+	CompletionStatus string   `gorm:"type:varchar(20);not null;default:'unknown'" json:"completion_status"` // completed, incomplete, not attempted or unknown
+	SuccessStatus    string   `gorm:"type:varchar(20);not null;default:'unknown'" json:"success_status"`    // passed, failed or unknown
+	Score            *float64 `json:"score"`                                                                // percentage
+	Statements       int      `gorm:"default:0" json:"statements"`                                          // xAPI statements received
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// XAPIStatement is an xAPI statement a lesson's content sent about a student, kept as sent
+type XAPIStatement struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	LessonID  uint      `gorm:"not null;index" json:"lesson_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Verb      string    `gorm:"type:varchar(300)" json:"verb"`
+	Statement JSON      `gorm:"type:json" json:"statement"`
+	CreatedAt time.Time `json:"created_at"`
+}
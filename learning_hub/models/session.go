@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Session is a login on a device. Its ID is the jti of the JWT issued for it, and revoking it makes the
+// auth middleware refuse that token.
+type Session struct {
+	ID         string     `gorm:"primaryKey;type:varchar(40)" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"-"`
+	IP         string     `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent  string     `gorm:"type:varchar(300)" json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	LastSeenIP string     `gorm:"type:varchar(64)" json:"last_seen_ip"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// Set when an admin is using the session to act as the user
+	ImpersonatorID *uint `gorm:"index" json:"impersonator_id,omitempty"`
+}
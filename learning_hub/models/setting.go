@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Setting is a platform setting changed by an admin, overriding its default from the configuration.
+// See pkg/settings for the keys and their types.
+type Setting struct {
+	Key         string    `gorm:"primaryKey;type:varchar(100)" json:"key"`
+	Value       string    `gorm:"type:text;not null" json:"value"`
+	UpdatedByID *uint     `json:"updated_by_id"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// SLISample aggregates one minute of requests of a critical flow, see pkg/slo
+type SLISample struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	Flow      string    `gorm:"type:varchar(30);not null;uniqueIndex:idx_sli_flow_minute" json:"flow"`
+	Minute    time.Time `gorm:"not null;uniqueIndex:idx_sli_flow_minute;index" json:"minute"`
+	Total     int       `gorm:"not null;default:0" json:"total"`
+	Errors    int       `gorm:"not null;default:0" json:"errors"` // 5xx responses
+	Slow      int       `gorm:"not null;default:0" json:"slow"`   // slower than the flow's latency threshold
+	LatencyMs int64     `gorm:"not null;default:0" json:"latency_ms"`
+	MaxMs     int64     `gorm:"not null;default:0" json:"max_ms"`
+}
+
+// SLOAlert records that admins were told a flow burns its error budget too fast
+type SLOAlert struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Flow      string    `gorm:"type:varchar(30);not null;index" json:"flow"`
+	Severity  string    `gorm:"type:varchar(10);not null" json:"severity"` // fast or slow burn
+	BurnRate  float64   `json:"burn_rate"`
+	ErrorRate float64   `json:"error_rate"`
+	FiredAt   time.Time `gorm:"not null;index" json:"fired_at"`
+}
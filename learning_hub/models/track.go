@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Track orders several courses into a program students enroll in as a whole, e.g. "Backend developer".
+// Not to be confused with LearningPath, which orders lessons within a single course.
+type Track struct {
+	gorm.Model
+	Title        string        `gorm:"type:varchar(200);not null" json:"title"`
+	Description  string        `gorm:"type:text" json:"description"`
+	ImageURL     string        `json:"image_url"`
+	InstructorID uint          `gorm:"not null;index" json:"instructor_id"`
+	Instructor   User          `gorm:"foreignKey:InstructorID" json:"instructor,omitempty"`
+	Price        float64       `gorm:"not null;default:0" json:"price"` // bundle price of all its courses, 0 = free
+	Published    bool          `gorm:"not null;default:false" json:"published"`
+	Courses      []TrackCourse `gorm:"foreignKey:TrackID" json:"courses,omitempty"`
+}
+
+// TrackCourse places a course at a position in a track
+type TrackCourse struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	TrackID  uint   `gorm:"not null;uniqueIndex:idx_track_course" json:"track_id"`
+	CourseID uint   `gorm:"not null;uniqueIndex:idx_track_course" json:"course_id"`
+	Course   Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	Position int    `gorm:"not null" json:"position"`
+}
+
+// TrackEnrollment is a student's enrollment in a track; they are enrolled in each of its courses as well.
+// Completing every course issues the track certificate.
+type TrackEnrollment struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_user_track" json:"user_id"`
+	TrackID    uint      `gorm:"not null;uniqueIndex:idx_user_track;index" json:"track_id"`
+	Track      Track     `gorm:"foreignKey:TrackID" json:"track,omitempty"`
+	PaymentID  *uint     `gorm:"index" json:"payment_id"` // nil for free tracks
+	EnrolledAt time.Time `json:"enrolled_at"`
+	// Set once every course of the track is completed
+	CompletedAt *time.Time `json:"completed_at"`
+
+	// Track certificate, verifiable by its code
+	CertificateCode     *string    `gorm:"type:varchar(50);uniqueIndex" json:"certificate_code"`
+	CertificateIssuedAt *time.Time `json:"certificate_issued_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
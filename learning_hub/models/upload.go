@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// UploadedFile records a file uploaded through the API and where it is used
+type UploadedFile struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	OwnerID      *uint  `gorm:"index" json:"owner_id"` // nil for anonymous uploads, made before uploading required signing in
+	Owner        *User  `gorm:"foreignKey:OwnerID" json:"-"`
+	FileType     string `gorm:"type:varchar(20);not null;index" json:"file_type"`
+	StorageKey   string `gorm:"type:varchar(500);not null;index:idx_uploaded_file_storage" json:"storage_key"` // shared by duplicate uploads
+	FileURL      string `gorm:"type:varchar(500);not null;index" json:"file_url"`
+	OriginalName string `gorm:"type:varchar(255)" json:"original_name"`
+	ContentType  string `gorm:"type:varchar(100)" json:"content_type"`
+	Size         int64  `gorm:"not null" json:"size"`
+	Checksum     string `gorm:"type:varchar(64);index" json:"checksum"` // SHA-256, hex
+
+	// Entities using the file as of the last check: [{"type": "lesson", "id": 3, "field": "video_url"}]
+	ReferencedBy        JSON       `gorm:"type:json" json:"referenced_by"`
+	ReferencesCheckedAt *time.Time `json:"references_checked_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UploadQuota overrides the role's default upload quota for one user
+type UploadQuota struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null;uniqueIndex" json:"user_id"`
+	User       User      `gorm:"foreignKey:UserID" json:"-"`
+	QuotaBytes int64     `gorm:"not null" json:"quota_bytes"` // 0 means unlimited
+	Note       string    `gorm:"type:varchar(255)" json:"note"`
+	SetByID    uint      `json:"set_by_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// QuarantinedFile is an upload the virus scanner flagged. It is kept out of the public upload
+// directories for admins to review and delete.
+type QuarantinedFile struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       *uint     `gorm:"index" json:"user_id"`                    // nil for anonymous uploads
+	Source       string    `gorm:"type:varchar(30);not null" json:"source"` // upload or assignment_submission
+	OriginalName string    `gorm:"type:varchar(255)" json:"original_name"`
+	StorageKey   string    `gorm:"type:varchar(500);not null" json:"storage_key"`
+	Size         int64     `json:"size"`
+	Signature    string    `gorm:"type:varchar(255)" json:"signature"`
+	CreatedAt    time.Time `json:"created_at"`
+}
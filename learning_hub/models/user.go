@@ -15,15 +15,53 @@ type User struct {
 	Password  string `gorm:"type:varchar(255);not null" json:"-"`
 	Phone     string `gorm:"type:varchar(20)" json:"phone"`
 	Role      string `gorm:"type:varchar(20);default:'student'" json:"role"`
+	Country   string `gorm:"type:varchar(2)" json:"country"` // ISO 3166-1 alpha-2, overrides the IP country
+
+	// Currency prices are also shown in (ISO 4217, empty shows them in their own currency only)
+	PreferredCurrency string `gorm:"type:varchar(3)" json:"preferred_currency"`
+	// Language the API and emails speak to the user in (see i18n.Supported), empty follows Accept-Language
+	PreferredLanguage string `gorm:"type:varchar(10)" json:"preferred_language"`
 
 	// Email Verification Fields
 	EmailVerified      bool       `gorm:"default:false" json:"email_verified"`
-	VerificationToken  *string    `gorm:"uniqueIndex:idx_users_verification_token;null" json:"verification_token"`
+	VerificationToken  *string    `gorm:"uniqueIndex:idx_users_verification_token;null" json:"-"`
 	VerificationSentAt *time.Time `json:"verification_sent_at"`
 
-	ResetToken     *string    `gorm:"null" json:"reset_token"`
-	ResetSentAt    *time.Time `json:"reset_sent_at"`
-	ResetExpiresAt *time.Time `json:"reset_expires_at"`
+	// A new address waiting for its owner to confirm it; Email only changes once they do
+	PendingEmail      *string    `gorm:"type:varchar(100)" json:"pending_email,omitempty"`
+	EmailChangeToken  *string    `gorm:"uniqueIndex:idx_users_email_change_token;null" json:"-"`
+	EmailChangeSentAt *time.Time `json:"-"`
+
+	ResetToken     *string    `gorm:"null" json:"-"`
+	ResetSentAt    *time.Time `json:"-"`
+	ResetExpiresAt *time.Time `json:"-"`
+
+	// Failed password logins since the last successful one; reaching the limit locks the account until LockedUntil
+	FailedLoginAttempts int        `gorm:"default:0" json:"-"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"`
+
+	// Two-factor login with an authenticator app. The secret is stored at setup and only asked for once
+	// the user confirms a code; codes of steps up to TwoFactorLastStep were used and are refused.
+	TwoFactorEnabled  bool   `gorm:"default:false" json:"two_factor_enabled"`
+	TwoFactorSecret   string `gorm:"type:varchar(64)" json:"-"`
+	TwoFactorLastStep int64  `gorm:"default:0" json:"-"`
+
+	// Accounts created by an admin import wait for the user to set a password with the invitation link
+	InvitationToken  *string    `gorm:"uniqueIndex:idx_users_invitation_token;null" json:"-"`
+	InvitationSentAt *time.Time `json:"invitation_sent_at,omitempty"`
+	InvitedByID      *uint      `json:"invited_by_id,omitempty"`
+
+	// Set when the user asked to delete their account: it is anonymized then, unless they log in before
+	DeletionScheduledAt *time.Time `gorm:"index" json:"deletion_scheduled_at,omitempty"`
+
+	// Authenticates the user's calendar feed, which calendar apps fetch without logging in
+	CalendarToken *string `gorm:"uniqueIndex;null" json:"-"`
+
+	// Test students let an instructor take one of their courses as a student would. They can't log in,
+	// pay, enroll elsewhere or review, and are left out of analytics and leaderboards.
+	IsTestStudent bool  `gorm:"default:false;index" json:"is_test_student,omitempty"`
+	TestOwnerID   *uint `gorm:"uniqueIndex:idx_test_student" json:"test_owner_id,omitempty"`
+	TestCourseID  *uint `gorm:"uniqueIndex:idx_test_student" json:"test_course_id,omitempty"`
 
 	// Relationships
 	Courses     []Course     `gorm:"foreignKey:InstructorID" json:"courses,omitempty"`
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// VerificationAPIKey lets an employer's HR system verify certificates in bulk with higher limits.
+// Only a hash of the key is stored; the key itself is shown once when it is created.
+type VerificationAPIKey struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Name         string `gorm:"type:varchar(200);not null" json:"name"`
+	ContactEmail string `gorm:"type:varchar(255)" json:"contact_email"`
+	// The first characters of the key, so admins and key holders can tell keys apart
+	Prefix  string `gorm:"type:varchar(20);not null" json:"prefix"`
+	KeyHash string `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	// Requests allowed per minute
+	RateLimit    int        `gorm:"not null" json:"rate_limit"`
+	CreatedByID  uint       `gorm:"not null" json:"created_by_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `gorm:"index" json:"revoked_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	Requests     int64      `gorm:"not null;default:0" json:"requests"`
+	Certificates int64      `gorm:"not null;default:0" json:"certificates"`
+}
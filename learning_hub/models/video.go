@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Video transcode job states
+const (
+	TranscodeStatusPending    = "pending"
+	TranscodeStatusProcessing = "processing"
+	TranscodeStatusReady      = "ready"
+	TranscodeStatusFailed     = "failed"
+)
+
+// VideoTranscode is a job converting an uploaded lesson video into HLS renditions
+type VideoTranscode struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	LessonID    uint       `gorm:"not null;index" json:"lesson_id"`
+	SourceURL   string     `gorm:"type:varchar(500);not null;index" json:"source_url"`
+	Status      string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	Renditions  string     `gorm:"type:varchar(100)" json:"renditions"` // comma-separated, e.g. "360p,720p"
+	ManifestURL string     `gorm:"type:varchar(500)" json:"manifest_url"`
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for VideoTranscode
+func (VideoTranscode) TableName() string {
+	return "video_transcodes"
+}
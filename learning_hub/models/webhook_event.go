@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// How a received webhook was handled
+const (
+	WebhookReceived  = "received"  // being processed, or processing never finished
+	WebhookProcessed = "processed" // applied; repeats of the event are acknowledged without processing
+	WebhookIgnored   = "ignored"   // an event type we don't act on
+	WebhookFailed    = "failed"    // processing failed; the provider retries and admins can replay it
+	WebhookRejected  = "rejected"  // bad signature or unreadable payload; never processed
+)
+
+// WebhookEvent is a webhook received from a payment provider, kept with its raw body and headers and how it
+// was handled. An event is processed at most once: EventID is the provider's event ID where it has one.
+type WebhookEvent struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Provider     string     `gorm:"type:varchar(20);not null;uniqueIndex:idx_webhook_provider_event" json:"provider"`
+	EventID      string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_webhook_provider_event" json:"event_id"`
+	EventType    string     `gorm:"type:varchar(100)" json:"event_type"`
+	TxRef        string     `gorm:"type:varchar(100);index" json:"tx_ref,omitempty"` // our payment reference
+	Headers      JSON       `gorm:"type:json" json:"headers,omitempty"`
+	Body         string     `gorm:"type:text" json:"body,omitempty"`
+	Status       string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	ResponseCode int        `json:"response_code"` // what processing answered
+	Error        string     `gorm:"type:text" json:"error,omitempty"`
+	Attempts     int        `gorm:"not null;default:0" json:"attempts"` // deliveries and replays processed
+	ReceivedAt   time.Time  `gorm:"not null;index" json:"received_at"`
+	ProcessedAt  *time.Time `json:"processed_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// Wishlist is a course a user saved to buy later. They hear about price drops and when it is published.
+type Wishlist struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_wishlist_user_course" json:"user_id"`
+	CourseID       uint      `gorm:"not null;uniqueIndex:idx_wishlist_user_course;index" json:"course_id"`
+	Course         Course    `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	PriceWhenAdded float64   `gorm:"type:decimal(10,2)" json:"price_when_added"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,192 @@
+package accounting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Row is one payment or refund to export
+type Row struct {
+	PaymentID     uint
+	Date          time.Time
+	Reference     string // transaction reference
+	ProviderRef   string // payment provider's own reference
+	CustomerName  string
+	CustomerEmail string
+	CourseID      uint
+	CourseTitle   string
+	PaymentMethod string
+	Currency      string
+	Amount        float64
+	Status        string
+	Refund        bool
+}
+
+// field renders one value of a row; dateLayout applies to dates
+type field func(r Row, dateLayout string) string
+
+// Fields are the values a column mapping can use
+var Fields = map[string]field{
+	"payment_id":     func(r Row, _ string) string { return strconv.FormatUint(uint64(r.PaymentID), 10) },
+	"date":           func(r Row, layout string) string { return r.Date.Format(layout) },
+	"reference":      func(r Row, _ string) string { return r.Reference },
+	"provider_ref":   func(r Row, _ string) string { return r.ProviderRef },
+	"customer_name":  func(r Row, _ string) string { return r.CustomerName },
+	"customer_email": func(r Row, _ string) string { return r.CustomerEmail },
+	"course_id":      func(r Row, _ string) string { return strconv.FormatUint(uint64(r.CourseID), 10) },
+	"course":         func(r Row, _ string) string { return r.CourseTitle },
+	"description":    func(r Row, _ string) string { return description(r) },
+	"payment_method": func(r Row, _ string) string { return r.PaymentMethod },
+	"currency":       func(r Row, _ string) string { return r.Currency },
+	"amount":         func(r Row, _ string) string { return formatAmount(r.Amount) },
+	// Refunds as negative amounts, for tools that expect a single signed column
+	"signed_amount": func(r Row, _ string) string { return formatAmount(signed(r)) },
+	"debit": func(r Row, _ string) string {
+		if r.Refund {
+			return formatAmount(r.Amount)
+		}
+		return ""
+	},
+	"credit": func(r Row, _ string) string {
+		if r.Refund {
+			return ""
+		}
+		return formatAmount(r.Amount)
+	},
+	"status": func(r Row, _ string) string { return r.Status },
+	"type": func(r Row, _ string) string {
+		if r.Refund {
+			return "Refund"
+		}
+		return "Payment"
+	},
+}
+
+// Column maps a field to a header in the exported file
+type Column struct {
+	Field  string `json:"field"`
+	Header string `json:"header"`
+}
+
+// Format describes the layout of an export file
+type Format struct {
+	Name       string   `json:"name"`
+	DateLayout string   `json:"date_layout"`
+	Columns    []Column `json:"columns"`
+}
+
+// Formats are the built-in export layouts
+var Formats = map[string]Format{
+	"csv": {
+		Name:       "csv",
+		DateLayout: "2006-01-02",
+		Columns: []Column{
+			{"date", "Date"}, {"payment_id", "Payment ID"}, {"reference", "Reference"},
+			{"type", "Type"}, {"status", "Status"}, {"customer_name", "Customer"},
+			{"customer_email", "Email"}, {"course", "Course"}, {"payment_method", "Payment Method"},
+			{"currency", "Currency"}, {"amount", "Amount"},
+		},
+	},
+	// QuickBooks Online bank transaction import (3-column layout)
+	"quickbooks": {
+		Name:       "quickbooks",
+		DateLayout: "01/02/2006",
+		Columns:    []Column{{"date", "Date"}, {"description", "Description"}, {"signed_amount", "Amount"}},
+	},
+	// Sage 50 / Peachtree cash receipts import
+	"peachtree": {
+		Name:       "peachtree",
+		DateLayout: "01/02/06",
+		Columns: []Column{
+			{"customer_email", "Customer ID"}, {"reference", "Reference"}, {"date", "Date"},
+			{"payment_method", "Payment Method"}, {"description", "Description"},
+			{"signed_amount", "Receipt Amount"},
+		},
+	},
+}
+
+// FieldNames lists the fields available to column mappings
+func FieldNames() []string {
+	names := make([]string, 0, len(Fields))
+	for name := range Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseColumns parses a column mapping such as "date:Posted On,amount,reference:Invoice".
+// A field without a header uses the field name as header.
+func ParseColumns(mapping string) ([]Column, error) {
+	var columns []Column
+	for _, part := range strings.Split(mapping, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, header, found := strings.Cut(part, ":")
+		name = strings.TrimSpace(name)
+		if _, ok := Fields[name]; !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		header = strings.TrimSpace(header)
+		if !found || header == "" {
+			header = name
+		}
+		columns = append(columns, Column{Field: name, Header: header})
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("column mapping is empty")
+	}
+	return columns, nil
+}
+
+// Write writes rows as CSV in the given format
+func Write(w io.Writer, format Format, rows []Row) error {
+	out := csv.NewWriter(w)
+
+	headers := make([]string, len(format.Columns))
+	for i, col := range format.Columns {
+		headers[i] = col.Header
+	}
+	if err := out.Write(headers); err != nil {
+		return err
+	}
+
+	record := make([]string, len(format.Columns))
+	for _, row := range rows {
+		for i, col := range format.Columns {
+			record[i] = Fields[col.Field](row, format.DateLayout)
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+func description(r Row) string {
+	kind := "Course sale"
+	if r.Refund {
+		kind = "Refund"
+	}
+	return fmt.Sprintf("%s: %s (%s)", kind, r.CourseTitle, r.Reference)
+}
+
+func signed(r Row) float64 {
+	if r.Refund {
+		return -r.Amount
+	}
+	return r.Amount
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
@@ -0,0 +1,430 @@
+// Package apidocs builds the OpenAPI 3 description of the API from the routes the server registers, the
+// operations documented in operations.go and the JSON shape of the models
+package apidocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Access is who may call an operation
+type Access int
+
+const (
+	Public Access = iota
+	OptionalAuth
+	Authenticated
+	Student
+	Instructor
+	InstructorOrAdmin
+	Admin
+	APIKey
+
+	undocumented Access = -1 // routes missing from Operations
+)
+
+// Operation documents a route. Query, Body and Response list fields as "name[:type][!]", comma separated:
+// types are string (the default), integer, number, boolean, date-time, binary, object, any, a model name
+// such as Course, or [type] for arrays; "!" marks required fields. A Response of "=Type" is the whole body.
+type Operation struct {
+	Method   string
+	Path     string // gin syntax, e.g. /api/courses/:id
+	Access   Access
+	Summary  string
+	Query    string
+	Body     string // sent as multipart/form-data when a field is binary
+	Response string
+	Produces string // content type of answers other than JSON, e.g. application/pdf
+	Status   int    // of a successful answer, 200 when zero
+}
+
+// Spec is a built OpenAPI document
+type Spec struct {
+	JSON []byte
+
+	// Undocumented are the registered routes missing from Operations, described from their route only
+	Undocumented []string
+}
+
+var (
+	pathParam   = regexp.MustCompile(`[:*](\w+)`)
+	handlerName = regexp.MustCompile(`\.(\w+?)(-fm)?$`)
+)
+
+// Build describes the registered routes. Schemas are the values whose types model names resolve to.
+func Build(routes gin.RoutesInfo, schemas ...interface{}) (Spec, error) {
+	documented := make(map[string]Operation, len(Operations))
+	for _, op := range Operations {
+		documented[op.Method+" "+op.Path] = op
+	}
+	b := &builder{types: map[string]reflect.Type{}, components: map[string]interface{}{}}
+	for _, value := range schemas {
+		t := reflect.TypeOf(value)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		b.types[t.Name()] = t
+	}
+
+	var spec Spec
+	paths := map[string]map[string]interface{}{}
+	for _, route := range routes {
+		op, ok := documented[route.Method+" "+route.Path]
+		if !ok {
+			spec.Undocumented = append(spec.Undocumented, route.Method+" "+route.Path)
+			op = Operation{Method: route.Method, Path: route.Path, Access: undocumented, Summary: summaryFromHandler(route.Handler)}
+		}
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		operation, err := b.operation(op)
+		if err != nil {
+			return spec, fmt.Errorf("%s %s: %v", op.Method, op.Path, err)
+		}
+		paths[path][strings.ToLower(route.Method)] = operation
+	}
+	sort.Strings(spec.Undocumented)
+
+	b.components["Error"] = map[string]interface{}{
+		"type":     "object",
+		"required": []string{"error", "code"},
+		"properties": map[string]interface{}{
+			"error":      map[string]interface{}{"type": "string", "description": "What went wrong, for people"},
+			"code":       map[string]interface{}{"type": "string", "description": "What went wrong, for programs, e.g. not_found or validation_failed"},
+			"request_id": map[string]interface{}{"type": "string"},
+			"fields": map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"},
+				"description": "Problem of each invalid field"},
+		},
+		"additionalProperties": true,
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "LearnHub API",
+			"version": "1.0",
+			"description": "Courses, enrollment, payments, assessments and administration of LearnHub. Authenticated " +
+				"operations take the JWT returned by POST /api/login as a Bearer token.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.components,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey":     map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+	var err error
+	spec.JSON, err = json.MarshalIndent(doc, "", "  ")
+	return spec, err
+}
+
+// summaryFromHandler turns a handler name like handlers.(*CourseHandler).GetCourses-fm into "Get courses"
+func summaryFromHandler(name string) string {
+	m := handlerName.FindStringSubmatch(name)
+	if m == nil || strings.HasPrefix(m[1], "func") {
+		return ""
+	}
+	var words []string
+	start := 0
+	runes := []rune(m[1])
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || (unicode.IsUpper(runes[i]) && !unicode.IsUpper(runes[i-1])) {
+			words = append(words, strings.ToLower(string(runes[start:i])))
+			start = i
+		}
+	}
+	summary := strings.Join(words, " ")
+	return strings.ToUpper(summary[:1]) + summary[1:]
+}
+
+// tag groups operations by the resource in their path: /api/courses/:id → courses, /api/admin/users → admin users
+func tag(path string) string {
+	segments := strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, "/api"), "/"), "/")
+	first := strings.TrimPrefix(segments[0], "my-")
+	if (first == "admin" || first == "instructor") && len(segments) > 1 {
+		return first + " " + segments[1]
+	}
+	return first
+}
+
+type builder struct {
+	types      map[string]reflect.Type
+	components map[string]interface{}
+}
+
+func (b *builder) operation(op Operation) (map[string]interface{}, error) {
+	operation := map[string]interface{}{
+		"summary":     op.Summary,
+		"operationId": op.Method + strings.NewReplacer("/", "_", ":", "", "*", "", "-", "_", ".", "_").Replace(op.Path),
+		"tags":        []string{tag(op.Path)},
+	}
+
+	var params []interface{}
+	for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		params = append(params, map[string]interface{}{
+			"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	query, err := b.fields(op.Query)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range query {
+		params = append(params, map[string]interface{}{"name": f.name, "in": "query", "required": f.required, "schema": f.schema})
+	}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+
+	if op.Body != "" {
+		body, err := b.fields(op.Body)
+		if err != nil {
+			return nil, err
+		}
+		contentType := "application/json"
+		for _, f := range body {
+			if f.schema["format"] == "binary" {
+				contentType = "multipart/form-data"
+			}
+		}
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{contentType: map[string]interface{}{"schema": object(body)}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	switch {
+	case op.Produces != "":
+		success["content"] = map[string]interface{}{op.Produces: map[string]interface{}{
+			"schema": map[string]interface{}{"type": "string", "format": "binary"},
+		}}
+	case strings.HasPrefix(op.Response, "="):
+		schema, err := b.schema(strings.TrimPrefix(op.Response, "="))
+		if err != nil {
+			return nil, err
+		}
+		success["content"] = jsonContent(schema)
+	case op.Response != "":
+		fields, err := b.fields(op.Response)
+		if err != nil {
+			return nil, err
+		}
+		success["content"] = jsonContent(object(fields))
+	}
+	errorResponse := func(status int) map[string]interface{} {
+		return map[string]interface{}{
+			"description": http.StatusText(status),
+			"content":     jsonContent(map[string]interface{}{"$ref": "#/components/schemas/Error"}),
+		}
+	}
+	responses := map[string]interface{}{fmt.Sprint(status): success}
+	if op.Body != "" || op.Query != "" {
+		responses["400"] = errorResponse(http.StatusBadRequest)
+	}
+
+	switch op.Access {
+	case undocumented:
+		operation["description"] = "Not documented yet: described from its route only."
+	case Public:
+		operation["security"] = []interface{}{}
+	case OptionalAuth:
+		operation["security"] = []interface{}{map[string]interface{}{}, map[string]interface{}{"bearerAuth": []string{}}}
+		operation["description"] = "Signed-in callers may get more, e.g. their enrollment."
+	case APIKey:
+		operation["security"] = []interface{}{map[string]interface{}{"apiKey": []string{}}}
+		responses["401"] = errorResponse(http.StatusUnauthorized)
+	default:
+		operation["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+		responses["401"] = errorResponse(http.StatusUnauthorized)
+		if role := map[Access]string{Student: "students", Instructor: "instructors", InstructorOrAdmin: "instructors and admins",
+			Admin: "admins"}[op.Access]; role != "" {
+			operation["description"] = "Only for " + role + "."
+			responses["403"] = errorResponse(http.StatusForbidden)
+		}
+	}
+	if strings.Contains(op.Path, ":") {
+		responses["404"] = errorResponse(http.StatusNotFound)
+	}
+	operation["responses"] = responses
+	return operation, nil
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+type field struct {
+	name     string
+	required bool
+	schema   map[string]interface{}
+}
+
+// fields parses a field list such as "courses:[Course], total:integer, search"
+func (b *builder) fields(list string) ([]field, error) {
+	var fields []field
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		f := field{}
+		if strings.HasSuffix(item, "!") {
+			f.required, item = true, strings.TrimSuffix(item, "!")
+		}
+		name, typ, _ := strings.Cut(item, ":")
+		if typ == "" {
+			typ = "string"
+		}
+		schema, err := b.schema(typ)
+		if err != nil {
+			return nil, err
+		}
+		f.name, f.schema = name, schema
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func object(fields []field) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for _, f := range fields {
+		properties[f.name] = f.schema
+		if f.required {
+			required = append(required, f.name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schema returns the schema of a field type, adding the models it names to the components
+func (b *builder) schema(typ string) (map[string]interface{}, error) {
+	switch typ {
+	case "string", "integer", "number", "boolean", "object":
+		return map[string]interface{}{"type": typ}, nil
+	case "date-time":
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case "binary":
+		return map[string]interface{}{"type": "string", "format": "binary"}, nil
+	case "any":
+		return map[string]interface{}{}, nil
+	}
+	if strings.HasPrefix(typ, "[") && strings.HasSuffix(typ, "]") {
+		items, err := b.schema(typ[1 : len(typ)-1])
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	}
+	t, ok := b.types[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+	return b.ref(t), nil
+}
+
+// ref returns a reference to a struct type's component, describing it on first use
+func (b *builder) ref(t reflect.Type) map[string]interface{} {
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	if _, done := b.components[t.Name()]; !done {
+		b.components[t.Name()] = nil // a placeholder, so self references end here
+		b.components[t.Name()] = b.structSchema(t)
+	}
+	return ref
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// typeSchema describes a Go type as encoding/json marshals it
+func (b *builder) typeSchema(t reflect.Type) map[string]interface{} {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+	var schema map[string]interface{}
+	switch {
+	case t == timeType || t.Name() == "DeletedAt":
+		schema = map[string]interface{}{"type": "string", "format": "date-time"}
+		nullable = nullable || t.Name() == "DeletedAt"
+	case t.ConvertibleTo(rawJSONType) && t.Kind() == reflect.Slice:
+		return map[string]interface{}{} // any JSON
+	case t.Kind() == reflect.Struct:
+		if _, ok := b.types[t.Name()]; ok {
+			if !nullable {
+				return b.ref(t)
+			}
+			return map[string]interface{}{"allOf": []interface{}{b.ref(t)}, "nullable": true}
+		}
+		schema = b.structSchema(t)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			schema = map[string]interface{}{"type": "string", "format": "byte"}
+		} else {
+			schema = map[string]interface{}{"type": "array", "items": b.typeSchema(t.Elem())}
+		}
+	case t.Kind() == reflect.Map:
+		schema = map[string]interface{}{"type": "object", "additionalProperties": b.typeSchema(t.Elem())}
+	case t.Kind() == reflect.Bool:
+		schema = map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		schema = map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema = map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.String:
+		schema = map[string]interface{}{"type": "string"}
+	default:
+		schema = map[string]interface{}{}
+	}
+	if nullable {
+		schema["nullable"] = true
+	}
+	return schema
+}
+
+// structSchema describes the JSON object of a struct, with the fields of embedded structs inlined
+func (b *builder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || (!f.IsExported() && !f.Anonymous) {
+				continue
+			}
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				collect(f.Type)
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = b.typeSchema(f.Type)
+		}
+	}
+	collect(t)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
@@ -0,0 +1,593 @@
+package apidocs
+
+import "net/http"
+
+// Operations documents the API's routes; keep it in step with the routes registered in main.go. Routes
+// missing here are still described, from their path and handler name, and logged when the spec is built.
+var Operations = []Operation{
+	// Catalog
+	{Method: "GET", Path: "/api/courses", Access: OptionalAuth, Summary: "List published courses",
+		Query: "currency, max_hours:integer, min_accessibility:integer", Response: "courses:[Course], country"},
+	{Method: "GET", Path: "/api/courses/:id", Access: OptionalAuth, Summary: "Get a course with its modules and lessons",
+		Query: "currency", Response: "course:Course, country, instructor_availability:object"},
+	{Method: "GET", Path: "/api/courses/:id/preview", Access: OptionalAuth, Summary: "List the preview lessons of a course",
+		Response: "course_id:integer, title, lessons:[Lesson], count:integer"},
+	{Method: "POST", Path: "/api/courses/:id/view", Access: OptionalAuth, Summary: "Record a view of a course page",
+		Response: "recorded:boolean"},
+	{Method: "GET", Path: "/api/capabilities", Access: Public, Summary: "Features enabled on this server", Response: "=object"},
+	{Method: "GET", Path: "/api/reviews/featured", Access: Public, Summary: "Featured reviews for the homepage",
+		Query: "limit:integer", Response: "reviews:[Review], count:integer"},
+	{Method: "GET", Path: "/api/tracks", Access: Public, Summary: "List published tracks", Response: "tracks:[object], count:integer"},
+	{Method: "GET", Path: "/api/tracks/:id", Access: Public, Summary: "Get a published track with its courses", Response: "=object"},
+	{Method: "GET", Path: "/api/badges", Access: Public, Summary: "List the badges that can be earned", Response: "badges:[Badge]"},
+	{Method: "GET", Path: "/api/instructors/:id/availability", Access: Public, Summary: "Whether an instructor is away", Response: "=object"},
+	{Method: "GET", Path: "/api/allowed-email-domains", Access: Public, Summary: "Email domains accepted at registration",
+		Response: "allowed_domains:[string], count:integer, message"},
+
+	// Accounts
+	{Method: "POST", Path: "/api/register", Access: Public, Summary: "Register an account", Status: http.StatusCreated,
+		Body:     "first_name!, last_name!, email!, password!, phone, role, preferred_language",
+		Response: "message, user:object, verification_required:boolean, allowed_domains:[string]"},
+	{Method: "POST", Path: "/api/login", Access: Public, Summary: "Log in with email and password",
+		Body: "email!, password!", Response: "message, token, user:object, two_factor_required:boolean, challenge_token"},
+	{Method: "POST", Path: "/api/login/2fa", Access: Public, Summary: "Complete a login with a two-factor code",
+		Body: "challenge_token!, code!", Response: "message, token, user:object"},
+	{Method: "GET", Path: "/api/auth/:provider", Access: Public, Summary: "Start a login with an OAuth provider (redirects)"},
+	{Method: "GET", Path: "/api/auth/:provider/callback", Access: Public, Summary: "OAuth provider callback (redirects to the frontend)",
+		Query: "code, state, error"},
+	{Method: "GET", Path: "/api/verify-email", Access: Public, Summary: "Verify an email address", Query: "token!",
+		Response: "message, user:object"},
+	{Method: "GET", Path: "/api/confirm-email-change", Access: Public, Summary: "Confirm a new email address", Query: "token!",
+		Response: "message, user:object"},
+	{Method: "POST", Path: "/api/accept-invitation", Access: Public, Summary: "Set the password of an invited account",
+		Body: "token!, password!", Response: "message, token, user:object"},
+	{Method: "POST", Path: "/api/resend-verification", Access: Public, Summary: "Send the verification email again",
+		Body: "email!", Response: "message, email"},
+	{Method: "POST", Path: "/api/forgot-password", Access: Public, Summary: "Email a password reset code",
+		Body: "email!", Response: "message, expires_in"},
+	{Method: "POST", Path: "/api/reset-password", Access: Public, Summary: "Reset a password with a reset code",
+		Body: "code!, password!", Response: "message, user:object"},
+	{Method: "GET", Path: "/api/validate-reset-token", Access: Public, Summary: "Check a password reset token", Query: "token!",
+		Response: "valid:boolean, user:object"},
+	{Method: "GET", Path: "/api/validate-reset-code", Access: Public, Summary: "Check a password reset code", Query: "code!",
+		Response: "valid:boolean, user:object"},
+	{Method: "GET", Path: "/api/profile", Access: Authenticated, Summary: "Get the caller's profile",
+		Response: "id:integer, first_name, last_name, email, phone, country, role, created_at:date-time"},
+	{Method: "PUT", Path: "/api/profile", Access: Authenticated, Summary: "Update the caller's profile or password",
+		Body: "first_name, last_name, phone, country, preferred_currency, preferred_language, password, current_password", Response: "message, user:object"},
+	{Method: "GET", Path: "/api/profile/notifications", Access: Authenticated, Summary: "Notification preferences",
+		Response: "preferences:[object]"},
+	{Method: "PUT", Path: "/api/profile/notifications", Access: Authenticated, Summary: "Change notification preferences",
+		Body: "preferences:object!", Response: "message, preferences:[object]"},
+	{Method: "GET", Path: "/api/profile/2fa", Access: Authenticated, Summary: "Two-factor login status",
+		Response: "enabled:boolean, backup_codes_left:integer"},
+	{Method: "POST", Path: "/api/profile/2fa/setup", Access: Authenticated, Summary: "Start setting up two-factor login",
+		Body: "password!", Response: "message, secret, provisioning_uri"},
+	{Method: "POST", Path: "/api/profile/2fa/enable", Access: Authenticated, Summary: "Turn on two-factor login",
+		Body: "code!", Response: "message, backup_codes:[string]"},
+	{Method: "POST", Path: "/api/profile/2fa/disable", Access: Authenticated, Summary: "Turn off two-factor login",
+		Body: "password!, code!", Response: "message"},
+	{Method: "POST", Path: "/api/profile/2fa/backup-codes", Access: Authenticated, Summary: "Replace the two-factor backup codes",
+		Body: "code!", Response: "message, backup_codes:[string]"},
+	{Method: "POST", Path: "/api/profile/email", Access: Authenticated, Summary: "Request an email address change",
+		Body: "new_email!, password!", Response: "message, pending_email"},
+	{Method: "DELETE", Path: "/api/profile/email", Access: Authenticated, Summary: "Cancel a pending email change", Response: "message"},
+	{Method: "DELETE", Path: "/api/profile", Access: Authenticated, Summary: "Schedule the deletion of the caller's account",
+		Body: "password!", Response: "message, deletion_scheduled_at:date-time"},
+	{Method: "GET", Path: "/api/profile/export", Access: Authenticated, Summary: "Export the caller's data", Query: "format",
+		Response: "=object"},
+	{Method: "GET", Path: "/api/profile/sessions", Access: Authenticated, Summary: "List the caller's sessions", Response: "sessions:[Session]"},
+	{Method: "DELETE", Path: "/api/profile/sessions/:id", Access: Authenticated, Summary: "Revoke a session", Response: "message"},
+	{Method: "POST", Path: "/api/logout", Access: Authenticated, Summary: "Log out of this session", Response: "message"},
+	{Method: "POST", Path: "/api/logout/everywhere", Access: Authenticated, Summary: "Log out of all sessions",
+		Query: "keep_current:boolean", Response: "message, revoked:integer"},
+
+	// Certificates
+	{Method: "GET", Path: "/api/verify-certificate", Access: Public, Summary: "Verify a certificate", Query: "code, id",
+		Response: "valid:boolean, status, certificate:object"},
+	{Method: "GET", Path: "/api/verify-track-certificate", Access: Public, Summary: "Verify a track certificate", Query: "code!",
+		Response: "valid:boolean, certificate:object"},
+	{Method: "POST", Path: "/api/verify-certificates", Access: APIKey, Summary: "Verify certificates in bulk",
+		Body: "codes:[string]!", Response: "results:[object]"},
+	{Method: "POST", Path: "/api/courses/:id/certificate", Access: Student, Summary: "Issue the caller's certificate of a completed course",
+		Response: "message, certificate:Certificate"},
+	{Method: "GET", Path: "/api/certificates/:id", Access: Student, Summary: "Get one of the caller's certificates", Response: "certificate:Certificate"},
+	{Method: "GET", Path: "/api/certificates/:id/download", Access: Authenticated, Summary: "Download a certificate", Produces: "application/pdf"},
+	{Method: "GET", Path: "/api/courses/:id/certificate-template", Access: InstructorOrAdmin, Summary: "Get a course's certificate template",
+		Response: "template:CertificateTemplate, default:object, is_default:boolean"},
+	{Method: "PUT", Path: "/api/courses/:id/certificate-template", Access: InstructorOrAdmin, Summary: "Save a course's certificate template",
+		Body:     "background_image_url, signature_image_url, title, wording, signatory_name, signatory_title, placements:object, is_published:boolean",
+		Response: "message, template:CertificateTemplate"},
+	{Method: "POST", Path: "/api/courses/:id/certificate-template/preview", Access: InstructorOrAdmin, Summary: "Render a sample certificate",
+		Body:     "background_image_url, signature_image_url, title, wording, signatory_name, signatory_title, placements:object",
+		Produces: "application/pdf"},
+
+	// Calendar
+	{Method: "GET", Path: "/api/calendar.ics", Access: Public, Summary: "iCalendar feed of deadlines and live sessions", Query: "token!",
+		Produces: "text/calendar"},
+	{Method: "GET", Path: "/api/calendar", Access: Authenticated, Summary: "URL of the caller's calendar feed", Response: "url"},
+	{Method: "POST", Path: "/api/calendar/reset", Access: Authenticated, Summary: "Replace the calendar feed URL", Response: "message, url"},
+
+	// Payments
+	{Method: "POST", Path: "/api/webhooks/chapa", Access: Public, Summary: "Chapa payment webhook", Body: "tx_ref!, status!, ref_id",
+		Response: "status"},
+	{Method: "POST", Path: "/api/webhooks/stripe", Access: Public, Summary: "Stripe Checkout webhook, checked against its Stripe-Signature",
+		Body: "id!, type!, data:object!", Response: "status"},
+	{Method: "GET", Path: "/api/payment/success", Access: Public, Summary: "Landing page after a checkout", Query: "tx_ref, status",
+		Response: "message, status, receipt:object"},
+	{Method: "GET", Path: "/api/my-payments", Access: Authenticated, Summary: "List the caller's payments", Response: "payments:[Payment]"},
+	{Method: "POST", Path: "/api/payments/initiate", Access: Authenticated, Summary: "Start paying for a course (honours Idempotency-Key)",
+		Body: "course_id:integer!", Response: "message, checkout_url, transaction_ref, payment_id:integer, provider, resumed:boolean, free:boolean, enrollment:Enrollment"},
+	{Method: "GET", Path: "/api/payments/status/:id", Access: Authenticated, Summary: "Get a payment's status, checked with its provider",
+		Query: "locale", Response: "payment:Payment, status, receipt:object"},
+	{Method: "GET", Path: "/api/payments/:id/receipt", Access: Authenticated, Summary: "Download the receipt of a successful payment",
+		Query: "locale", Produces: "application/pdf"},
+
+	// Enrollment and progress
+	{Method: "GET", Path: "/api/dashboard", Access: Authenticated, Summary: "The caller's learning dashboard", Response: "dashboard:object, user_id:integer"},
+	{Method: "GET", Path: "/api/my-enrollments", Access: Authenticated, Summary: "List the caller's enrollments",
+		Response: "enrollments:[Enrollment], count:integer"},
+	{Method: "POST", Path: "/api/courses/:id/enroll", Access: Student, Summary: "Enroll in a free course",
+		Response: "message, enrollment:Enrollment"},
+	{Method: "GET", Path: "/api/my-courses", Access: Student, Summary: "List the courses the caller is enrolled in", Response: "enrollments:[Enrollment]"},
+	{Method: "PUT", Path: "/api/progress/lesson", Access: Student, Summary: "Record progress on a lesson",
+		Body: "lesson_id:integer!, course_id:integer!, time_spent:integer!, completed:boolean", Response: "=object"},
+	{Method: "GET", Path: "/api/courses/:id/progress", Access: Student, Summary: "The caller's progress in a course",
+		Response: "enrollment:Enrollment, progress:[LessonProgress]"},
+	{Method: "GET", Path: "/api/courses/:id/pace", Access: Student, Summary: "Weekly plan to finish a course",
+		Query: "hours_per_week:number, target_date", Response: "=object"},
+	{Method: "GET", Path: "/api/courses/:id/paths", Access: Authenticated, Summary: "List a course's learning paths",
+		Response: "learning_paths:[LearningPath], count:integer"},
+	{Method: "PUT", Path: "/api/courses/:id/path", Access: Student, Summary: "Choose a learning path", Body: "learning_path_id:integer",
+		Response: "=object"},
+	{Method: "GET", Path: "/api/courses/:id/continue", Access: Student, Summary: "The next lesson to take",
+		Response: "next_lesson:Lesson, learning_path_id:integer, completed_lessons:integer, total_lessons:integer, lessons:[object]"},
+	{Method: "PUT", Path: "/api/courses/:id/language", Access: Student, Summary: "Choose the language of a course's lessons",
+		Body: "language", Response: "message, course_id:integer, preferred_language"},
+	{Method: "POST", Path: "/api/courses/:id/review", Access: Student, Summary: "Review a course",
+		Body: "rating:integer!, comment", Response: "message, review:Review"},
+	{Method: "GET", Path: "/api/courses/:id/reviews", Access: Student, Summary: "List a course's reviews", Response: "=[Review]"},
+	{Method: "GET", Path: "/api/my-transcript", Access: Authenticated, Summary: "The caller's grades in every course",
+		Response: "courses:[object], count:integer"},
+	{Method: "GET", Path: "/api/courses/:id/grading-scale", Access: Authenticated, Summary: "A course's grading scale",
+		Response: "grading_scale:object, source"},
+
+	// Tracks
+	{Method: "POST", Path: "/api/tracks/:id/enroll", Access: Student, Summary: "Enroll in a track, paying for it when priced",
+		Response: "message, enrollment:TrackEnrollment, checkout_url, payment_id:integer"},
+	{Method: "GET", Path: "/api/my-tracks", Access: Authenticated, Summary: "List the caller's tracks", Response: "tracks:[object], count:integer"},
+	{Method: "GET", Path: "/api/tracks/:id/progress", Access: Authenticated, Summary: "The caller's progress in a track",
+		Response: "track_id:integer, title, progress:number, completed_courses:integer, total_courses:integer, courses:[object], next_course:object, enrollment:TrackEnrollment"},
+	{Method: "POST", Path: "/api/tracks", Access: Instructor, Summary: "Create a track", Status: http.StatusCreated,
+		Body: "title!, description, image_url, price:number, published:boolean, course_ids:[integer]!", Response: "message, track:Track"},
+	{Method: "PUT", Path: "/api/tracks/:id", Access: Instructor, Summary: "Update a track",
+		Body: "title!, description, image_url, price:number, published:boolean, course_ids:[integer]!", Response: "message, track:Track"},
+	{Method: "DELETE", Path: "/api/tracks/:id", Access: Instructor, Summary: "Delete a track", Response: "message"},
+
+	// Announcements, forum and messages
+	{Method: "GET", Path: "/api/courses/:id/announcements", Access: Authenticated, Summary: "List a course's announcements",
+		Response: "announcements:[Announcement], count:integer"},
+	{Method: "POST", Path: "/api/courses/:id/announcements", Access: Authenticated, Summary: "Post an announcement to a course's students",
+		Status: http.StatusCreated, Body: "title!, body!, pinned:boolean", Response: "message, announcement:Announcement, recipients:integer"},
+	{Method: "PUT", Path: "/api/courses/:id/announcements/:announcementId/pin", Access: Authenticated, Summary: "Pin or unpin an announcement",
+		Body: "pinned:boolean!", Response: "message, announcement:Announcement"},
+	{Method: "DELETE", Path: "/api/courses/:id/announcements/:announcementId", Access: Authenticated, Summary: "Delete an announcement",
+		Response: "message"},
+	{Method: "GET", Path: "/api/courses/:id/threads", Access: Authenticated, Summary: "List a course's forum threads",
+		Query: "lesson_id:integer, sort, unanswered:boolean, page:integer", Response: "threads:[ForumThread], total:integer, page:integer"},
+	{Method: "POST", Path: "/api/courses/:id/threads", Access: Authenticated, Summary: "Start a forum thread", Status: http.StatusCreated,
+		Body: "title!, body!, lesson_id:integer", Response: "message, thread:ForumThread"},
+	{Method: "GET", Path: "/api/threads/:id", Access: Authenticated, Summary: "Get a thread with its posts",
+		Response: "thread:ForumThread, upvoted:boolean, upvoted_posts:[integer]"},
+	{Method: "PUT", Path: "/api/threads/:id", Access: Authenticated, Summary: "Pin or lock a thread",
+		Body: "pinned:boolean, locked:boolean", Response: "message, thread:ForumThread"},
+	{Method: "DELETE", Path: "/api/threads/:id", Access: Authenticated, Summary: "Delete a thread", Response: "message"},
+	{Method: "POST", Path: "/api/threads/:id/posts", Access: Authenticated, Summary: "Reply to a thread", Status: http.StatusCreated,
+		Body: "body!", Response: "message, post:ForumPost"},
+	{Method: "POST", Path: "/api/threads/:id/vote", Access: Authenticated, Summary: "Upvote a thread", Response: "upvotes:integer, upvoted:boolean"},
+	{Method: "DELETE", Path: "/api/threads/:id/vote", Access: Authenticated, Summary: "Remove a thread upvote", Response: "upvotes:integer, upvoted:boolean"},
+	{Method: "PUT", Path: "/api/threads/:id/answer", Access: Authenticated, Summary: "Mark the post answering a question",
+		Body: "post_id:integer", Response: "message, answered_post_id:integer"},
+	{Method: "DELETE", Path: "/api/posts/:id", Access: Authenticated, Summary: "Delete a post", Response: "message"},
+	{Method: "POST", Path: "/api/posts/:id/vote", Access: Authenticated, Summary: "Upvote a post", Response: "upvotes:integer, upvoted:boolean"},
+	{Method: "DELETE", Path: "/api/posts/:id/vote", Access: Authenticated, Summary: "Remove a post upvote", Response: "upvotes:integer, upvoted:boolean"},
+	{Method: "POST", Path: "/api/courses/:id/conversations", Access: Authenticated, Summary: "Message a course's instructor",
+		Status: http.StatusCreated, Body: "body!", Response: "conversation:Conversation, message:Message"},
+	{Method: "GET", Path: "/api/conversations", Access: Authenticated, Summary: "List the caller's conversations", Query: "page:integer",
+		Response: "conversations:[Conversation], total:integer, page:integer"},
+	{Method: "GET", Path: "/api/conversations/unread", Access: Authenticated, Summary: "Count unread messages", Response: "unread:integer"},
+	{Method: "GET", Path: "/api/conversations/:id/messages", Access: Authenticated, Summary: "List a conversation's messages",
+		Query: "before:integer", Response: "conversation_id:integer, messages:[Message], has_more:boolean"},
+	{Method: "POST", Path: "/api/conversations/:id/messages", Access: Authenticated, Summary: "Send a message", Status: http.StatusCreated,
+		Body: "body!", Response: "=Message"},
+
+	// Live sessions
+	{Method: "GET", Path: "/api/courses/:id/live-sessions", Access: Authenticated, Summary: "List a course's live sessions",
+		Query: "upcoming:boolean", Response: "sessions:[LiveSession]"},
+	{Method: "POST", Path: "/api/live-sessions/:id/join", Access: Authenticated, Summary: "Join a live session", Response: "join_url, host:boolean"},
+	{Method: "POST", Path: "/api/courses/:id/live-sessions", Access: Instructor, Summary: "Schedule a live session", Status: http.StatusCreated,
+		Body: "title!, description, starts_at:date-time!, duration_minutes:integer!", Response: "message, session:LiveSession, join_url, host_url"},
+	{Method: "PUT", Path: "/api/courses/:id/live-sessions/:sessionId", Access: Instructor, Summary: "Reschedule a live session",
+		Body: "title!, description, starts_at:date-time!, duration_minutes:integer!", Response: "message, session:LiveSession, join_url, host_url"},
+	{Method: "DELETE", Path: "/api/courses/:id/live-sessions/:sessionId", Access: Instructor, Summary: "Cancel a live session", Response: "message"},
+	{Method: "GET", Path: "/api/courses/:id/live-sessions/:sessionId/attendance", Access: Instructor, Summary: "Attendance of a live session",
+		Response: "session:LiveSession, students:[object], enrolled:integer, attended:integer, attendance_rate:number"},
+
+	// Wishlist, recommendations and achievements
+	{Method: "POST", Path: "/api/courses/:id/wishlist", Access: Authenticated, Summary: "Add a course to the wishlist",
+		Response: "message, wishlist:Wishlist"},
+	{Method: "DELETE", Path: "/api/courses/:id/wishlist", Access: Authenticated, Summary: "Remove a course from the wishlist", Response: "message"},
+	{Method: "GET", Path: "/api/my-wishlist", Access: Authenticated, Summary: "List the caller's wishlist",
+		Response: "wishlist:[object], count:integer, country"},
+	{Method: "GET", Path: "/api/recommendations", Access: Authenticated, Summary: "Courses recommended to the caller", Query: "limit:integer",
+		Response: "recommendations:[object], count:integer, strategy, personalized:boolean, country"},
+	{Method: "GET", Path: "/api/my-achievements", Access: Authenticated, Summary: "The caller's points, streak and badges",
+		Response: "points:integer, points_by_reason:object, counts:object, streak:LearningStreak, badges:[UserBadge], badges_available:[Badge]"},
+	{Method: "GET", Path: "/api/leaderboard", Access: Authenticated, Summary: "Platform leaderboard", Response: "=object"},
+	{Method: "GET", Path: "/api/courses/:id/leaderboard", Access: Authenticated, Summary: "Leaderboard of a course", Response: "=object"},
+
+	// Organizations
+	{Method: "GET", Path: "/api/my-organizations", Access: Authenticated, Summary: "List the caller's organizations",
+		Response: "organizations:[Organization]"},
+	{Method: "POST", Path: "/api/organizations", Access: Authenticated, Summary: "Create an organization", Status: http.StatusCreated,
+		Body: "name!", Response: "organization:Organization"},
+	{Method: "GET", Path: "/api/organizations/:id", Access: Authenticated, Summary: "Get an organization with its members and seats",
+		Response: "organization:Organization, members:[OrganizationMember], seats:[OrganizationSeat]"},
+	{Method: "POST", Path: "/api/organizations/:id/seats", Access: Authenticated, Summary: "Buy seats of a course (honours Idempotency-Key)",
+		Body: "course_id:integer!, seats:integer!", Response: "message, checkout_url, transaction_ref, payment_id:integer, resumed:boolean"},
+	{Method: "POST", Path: "/api/organizations/:id/members", Access: Authenticated, Summary: "Add or invite a member",
+		Body: "email!, role, first_name, last_name", Response: "message, user_id:integer, role, invited:boolean"},
+	{Method: "DELETE", Path: "/api/organizations/:id/members/:userId", Access: Authenticated, Summary: "Remove a member",
+		Response: "message, seats_released:integer"},
+	{Method: "POST", Path: "/api/organizations/:id/assignments", Access: Authenticated, Summary: "Assign a course to members",
+		Body: "course_id:integer!, user_ids:[integer]!", Response: "message, assigned:integer, results:[object]"},
+	{Method: "DELETE", Path: "/api/organizations/:id/assignments/:enrollmentId", Access: Authenticated, Summary: "Take back an assigned course",
+		Response: "message, seat_released:boolean"},
+	{Method: "GET", Path: "/api/organizations/:id/progress", Access: Authenticated, Summary: "Progress of the organization's members",
+		Query: "course_id:integer, user_id:integer", Response: "organization_id:integer, courses:[object], members:[object]"},
+
+	// Files
+	{Method: "POST", Path: "/api/upload", Access: Authenticated, Summary: "Upload a file (videos by instructors and admins)", Body: "file:binary!, type", Response: "=object"},
+	{Method: "GET", Path: "/api/my-files", Access: Authenticated, Summary: "List the caller's uploads", Query: "type",
+		Response: "files:[UploadedFile], count:integer, total_size:integer"},
+	{Method: "DELETE", Path: "/api/my-files/:id", Access: Authenticated, Summary: "Delete an upload", Response: "message"},
+	{Method: "GET", Path: "/api/my-files/quota", Access: Authenticated, Summary: "The caller's upload quota", Response: "=object"},
+	{Method: "GET", Path: "/uploads/:type/:filename", Access: OptionalAuth, Summary: "Download an uploaded file", Produces: "application/octet-stream"},
+	{Method: "GET", Path: "/uploads/hls/:id/:filename", Access: OptionalAuth, Summary: "HLS playlist or segment of a transcoded video",
+		Produces: "application/vnd.apple.mpegurl"},
+	{Method: "GET", Path: "/uploads/scorm/:id/:token/*path", Access: Public, Summary: "File of a SCORM package, with a launch token",
+		Produces: "application/octet-stream"},
+
+	// Notifications
+	{Method: "GET", Path: "/api/notifications", Access: Authenticated, Summary: "List the caller's notifications", Query: "unread:boolean",
+		Response: "notifications:[Notification], unread:integer"},
+	{Method: "GET", Path: "/api/notifications/stream", Access: Authenticated, Summary: "Notifications as server-sent events (?token= accepted)",
+		Query: "token, last_event_id", Produces: "text/event-stream"},
+	{Method: "GET", Path: "/api/notifications/sync", Access: Authenticated, Summary: "Notifications changed since a sync", Query: "since",
+		Response: "notifications:[Notification], reset:boolean, has_more:boolean, synced_at:date-time"},
+	{Method: "PUT", Path: "/api/notifications/read", Access: Authenticated, Summary: "Mark all notifications read", Response: "message, updated:integer"},
+	{Method: "PUT", Path: "/api/notifications/:id/read", Access: Authenticated, Summary: "Mark a notification read", Response: "message, updated:integer"},
+	{Method: "PUT", Path: "/api/notifications/:id/unread", Access: Authenticated, Summary: "Mark a notification unread", Response: "message"},
+	{Method: "DELETE", Path: "/api/notifications/:id", Access: Authenticated, Summary: "Delete a notification", Response: "message"},
+	{Method: "GET", Path: "/api/unsubscribe", Access: Public, Summary: "Unsubscribe from emails with a link token",
+		Query: "user, category, token!", Response: "message, category"},
+	{Method: "POST", Path: "/api/unsubscribe", Access: Public, Summary: "One-click unsubscribe from emails",
+		Query: "user, category, token!", Response: "message, category"},
+
+	// Course authoring
+	{Method: "POST", Path: "/api/courses", Access: Instructor, Summary: "Create a course", Status: http.StatusCreated,
+		Body:     "title!, description!, price:number, currency, category, level!, image_url, thumbnail_url, image_alt, published:boolean",
+		Response: "message, course:Course, publish_checks:[object]"},
+	{Method: "PUT", Path: "/api/courses/:id", Access: Instructor, Summary: "Update a course",
+		Body:     "title, description, price:number, currency, category, level, image_url, thumbnail_url, image_alt, published:boolean",
+		Response: "message, course:Course"},
+	{Method: "DELETE", Path: "/api/courses/:id", Access: Instructor, Summary: "Move a course to the trash", Response: "message, purge_at:date-time"},
+	{Method: "POST", Path: "/api/courses/:id/clone", Access: Instructor, Summary: "Copy a course", Status: http.StatusCreated,
+		Body: "title, shift_days:integer", Response: "message, course:Course, copied:object"},
+	{Method: "POST", Path: "/api/courses/import/youtube", Access: Instructor, Summary: "Create a course from a YouTube playlist",
+		Status: http.StatusCreated, Body: "playlist_url!, title, category, level, price:number, lessons_per_module:integer",
+		Response: "message, course:Course, lessons:integer, skipped_videos:[object]"},
+	{Method: "POST", Path: "/api/courses/import", Access: Instructor, Summary: "Import a course package (JSON, zip or form file)",
+		Status: http.StatusCreated, Body: "file:binary", Response: "message, course:Course, imported:object"},
+	{Method: "GET", Path: "/api/courses/:id/export", Access: Instructor, Summary: "Export a course package", Query: "format",
+		Produces: "application/zip"},
+	{Method: "GET", Path: "/api/courses/:id/publish-check", Access: Instructor, Summary: "Check a course against the publish checklist",
+		Response: "course_id:integer, published:boolean, can_publish:boolean, checks:[object]"},
+	{Method: "POST", Path: "/api/courses/:id/publish", Access: Instructor, Summary: "Publish a course passing the checklist",
+		Response: "message, course:Course, checks:[object]"},
+	{Method: "PUT", Path: "/api/courses/:id/unpublish-at", Access: Instructor, Summary: "Schedule a course to be unpublished",
+		Body: "unpublish_at:date-time", Response: "message, course:Course"},
+	{Method: "GET", Path: "/api/courses/:id/accessibility", Access: Instructor, Summary: "Accessibility report of a course",
+		Response: "course_id:integer, accessibility:object"},
+	{Method: "PUT", Path: "/api/courses/:id/reviews/:reviewId/reply", Access: Instructor, Summary: "Reply to a review",
+		Body: "reply!", Response: "message, review:Review"},
+	{Method: "DELETE", Path: "/api/courses/:id/reviews/:reviewId/reply", Access: Instructor, Summary: "Delete a review reply", Response: "message"},
+	{Method: "GET", Path: "/api/courses/:id/staff", Access: Instructor, Summary: "List a course's staff", Response: "staff:[CourseStaff]"},
+	{Method: "POST", Path: "/api/courses/:id/staff", Access: Instructor, Summary: "Add a co-instructor or TA", Status: http.StatusCreated,
+		Body: "email!, role!", Response: "staff:CourseStaff"},
+	{Method: "PUT", Path: "/api/courses/:id/staff/:userId", Access: Instructor, Summary: "Change a staff member's role",
+		Body: "role!", Response: "staff:CourseStaff"},
+	{Method: "DELETE", Path: "/api/courses/:id/staff/:userId", Access: Instructor, Summary: "Remove a staff member", Response: "message"},
+	{Method: "POST", Path: "/api/courses/:id/modules", Access: Instructor, Summary: "Add a module", Status: http.StatusCreated,
+		Body: "title!, description, order_index:integer", Response: "=Module"},
+	{Method: "DELETE", Path: "/api/courses/:id/modules/:moduleId", Access: Instructor, Summary: "Move a module to the trash",
+		Response: "message, purge_at:date-time"},
+	{Method: "POST", Path: "/api/courses/:id/paths", Access: Instructor, Summary: "Create a learning path", Status: http.StatusCreated,
+		Body: "title!, description, lesson_ids:[integer]!", Response: "=LearningPath"},
+	{Method: "PUT", Path: "/api/courses/:id/paths/:pathId", Access: Instructor, Summary: "Update a learning path",
+		Body: "title!, description, lesson_ids:[integer]!", Response: "=LearningPath"},
+	{Method: "DELETE", Path: "/api/courses/:id/paths/:pathId", Access: Instructor, Summary: "Delete a learning path", Response: "message"},
+	{Method: "PUT", Path: "/api/courses/:id/grading-scale", Access: Instructor, Summary: "Set a course's grading scale",
+		Body: "name, pass_threshold:number!, bands:[object]!", Response: "message, grading_scale:object, source"},
+	{Method: "DELETE", Path: "/api/courses/:id/grading-scale", Access: Instructor, Summary: "Use the default grading scale again",
+		Response: "message, grading_scale:object, source"},
+	{Method: "GET", Path: "/api/courses/:id/gradebook", Access: Instructor, Summary: "Gradebook of a course",
+		Response: "course_id:integer, grading_scale:object, scale_source, students:[object], count:integer, graded_count:integer, passed_count:integer"},
+	{Method: "GET", Path: "/api/instructor/courses", Access: Instructor, Summary: "List the caller's courses", Response: "=[Course]"},
+	{Method: "GET", Path: "/api/instructor/courses/:id/analytics", Access: Instructor, Summary: "Enrollment, revenue and rating analytics of a course",
+		Query:    "from, to, interval",
+		Response: "course_id:integer, from, to, interval, platform_share_percent:number, enrollments:[object], revenue:[object], rating_trend:[object], funnel:object, totals:object"},
+	{Method: "GET", Path: "/api/instructor/courses/:id/cohorts", Access: Instructor, Summary: "List a course's cohorts", Response: "=[CourseCohort]"},
+	{Method: "POST", Path: "/api/instructor/courses/:id/cohorts", Access: Instructor, Summary: "Define a cohort", Status: http.StatusCreated,
+		Body: "name!, starts_at:date-time!, ends_at:date-time!, changes", Response: "=CourseCohort"},
+	{Method: "GET", Path: "/api/instructor/courses/:id/cohorts/compare", Access: Instructor, Summary: "Compare the outcomes of cohorts",
+		Response: "course_id:integer, cohorts:[object], unassigned_students:integer"},
+	{Method: "PUT", Path: "/api/instructor/courses/:id/cohorts/:cohortId", Access: Instructor, Summary: "Update a cohort",
+		Body: "name!, starts_at:date-time!, ends_at:date-time!, changes", Response: "=CourseCohort"},
+	{Method: "DELETE", Path: "/api/instructor/courses/:id/cohorts/:cohortId", Access: Instructor, Summary: "Delete a cohort", Response: "message"},
+	{Method: "POST", Path: "/api/instructor/courses/:id/test-student", Access: Instructor, Summary: "Get a token to view a course as a student",
+		Response: "message, token, user:object, course_id:integer"},
+	{Method: "POST", Path: "/api/instructor/courses/:id/test-student/reset", Access: Instructor, Summary: "Reset the test student's progress",
+		Response: "message"},
+	{Method: "GET", Path: "/api/instructor/availability", Access: Instructor, Summary: "The caller's away periods",
+		Response: "status:object, away_periods:[InstructorAwayPeriod]"},
+	{Method: "POST", Path: "/api/instructor/availability", Access: Instructor, Summary: "Add an away period with an auto-reply",
+		Status: http.StatusCreated, Body: "starts_at:date-time, ends_at:date-time!, auto_reply", Response: "=InstructorAwayPeriod"},
+	{Method: "DELETE", Path: "/api/instructor/availability/:id", Access: Instructor, Summary: "End an away period", Response: "message, status:object"},
+	{Method: "GET", Path: "/api/instructor/trash", Access: Instructor, Summary: "List deleted course content", Query: "course_id:integer",
+		Response: "items:[object], count:integer, retention_days:integer"},
+	{Method: "POST", Path: "/api/instructor/trash/:type/:id/restore", Access: Instructor, Summary: "Restore deleted content", Response: "message"},
+
+	// Lessons
+	{Method: "POST", Path: "/api/lessons", Access: Instructor, Summary: "Create a lesson", Status: http.StatusCreated,
+		Body:     "title!, content, video_url, document_url, duration:integer, order_index:integer, module_id:integer!, captions_url, transcript, content_format, is_preview:boolean",
+		Response: "=Lesson"},
+	{Method: "POST", Path: "/api/lessons/preview", Access: Instructor, Summary: "Render lesson content as HTML", Body: "content, format",
+		Response: "html"},
+	{Method: "GET", Path: "/api/lessons/:id", Access: Authenticated, Summary: "Get a lesson, in the caller's language", Query: "lang",
+		Response: "=object"},
+	{Method: "PUT", Path: "/api/lessons/:id", Access: Instructor, Summary: "Update a lesson",
+		Body:     "title, content, video_url, document_url, duration:integer, order_index:integer, captions_url, transcript, content_format, is_preview:boolean",
+		Response: "=Lesson"},
+	{Method: "DELETE", Path: "/api/lessons/:id", Access: Instructor, Summary: "Move a lesson to the trash", Response: "message, purge_at:date-time"},
+	{Method: "PUT", Path: "/api/lessons/:id/progress", Access: Authenticated, Summary: "Record progress on a lesson",
+		Body: "time_spent:integer!, video_seconds:integer", Response: "=object"},
+	{Method: "GET", Path: "/api/lessons/module/:moduleId", Access: Authenticated, Summary: "List a module's lessons", Response: "=object"},
+	{Method: "GET", Path: "/api/lessons/:id/analytics", Access: Instructor, Summary: "Engagement analytics of a lesson", Response: "=object"},
+	{Method: "GET", Path: "/api/lessons/:id/variants", Access: Instructor, Summary: "List a lesson's translations",
+		Response: "variants:[LessonVariant], count:integer"},
+	{Method: "PUT", Path: "/api/lessons/:id/variants/:lang", Access: Instructor, Summary: "Save a lesson translation",
+		Body: "title, content, video_url, captions_url", Response: "=LessonVariant"},
+	{Method: "DELETE", Path: "/api/lessons/:id/variants/:lang", Access: Instructor, Summary: "Delete a lesson translation", Response: "message"},
+	{Method: "GET", Path: "/api/lessons/:id/video", Access: Instructor, Summary: "Transcoding status of a lesson video",
+		Response: "lesson_id:integer, video_url, status, streaming:object, transcodes:[VideoTranscode]"},
+	{Method: "POST", Path: "/api/lessons/:id/video/transcode", Access: Instructor, Summary: "Transcode a lesson video again",
+		Status: http.StatusAccepted, Response: "message, transcode:VideoTranscode"},
+	{Method: "GET", Path: "/api/lessons/:id/code", Access: Authenticated, Summary: "Starter and saved code of a playground lesson",
+		Response: "lesson_id:integer, language, starter_code, code, tests:[object], tests_total:integer, can_run:boolean"},
+	{Method: "PUT", Path: "/api/lessons/:id/code", Access: Authenticated, Summary: "Save code of a playground lesson",
+		Body: "code, stdin", Response: "message, code:LessonCode"},
+	{Method: "POST", Path: "/api/lessons/:id/code/run", Access: Authenticated, Summary: "Run code in the sandbox",
+		Body: "code, stdin", Response: "result:object"},
+	{Method: "POST", Path: "/api/lessons/:id/code/check", Access: Authenticated, Summary: "Check code against the lesson's tests",
+		Body: "code", Response: "=object"},
+	{Method: "GET", Path: "/api/lessons/:id/blocks", Access: Authenticated, Summary: "List a lesson's content blocks",
+		Response: "blocks:[LessonBlock], count:integer"},
+	{Method: "POST", Path: "/api/lessons/:id/blocks", Access: Instructor, Summary: "Add a content block", Status: http.StatusCreated,
+		Body: "type!, title, text, url, duration:integer, captions_url, quiz_id:integer, position:integer", Response: "=LessonBlock"},
+	{Method: "PUT", Path: "/api/lessons/:id/blocks/order", Access: Instructor, Summary: "Reorder content blocks",
+		Body: "block_ids:[integer]!", Response: "message"},
+	{Method: "PUT", Path: "/api/lessons/:id/blocks/:blockId", Access: Instructor, Summary: "Update a content block",
+		Body: "type, title, text, url, duration:integer, captions_url, quiz_id:integer", Response: "=LessonBlock"},
+	{Method: "DELETE", Path: "/api/lessons/:id/blocks/:blockId", Access: Instructor, Summary: "Delete a content block", Response: "message"},
+	{Method: "POST", Path: "/api/lessons/:id/scorm", Access: Instructor, Summary: "Upload a SCORM package", Status: http.StatusCreated,
+		Body: "file:binary!", Response: "message, package:ScormPackage"},
+	{Method: "DELETE", Path: "/api/lessons/:id/scorm", Access: Instructor, Summary: "Remove a lesson's SCORM package", Response: "message"},
+	{Method: "GET", Path: "/api/lessons/:id/scorm", Access: Authenticated, Summary: "Launch a SCORM lesson",
+		Response: "lesson_id:integer, version, title, launch_url, expires_in:integer, attempt:ScormAttempt"},
+	{Method: "PUT", Path: "/api/lessons/:id/scorm/runtime", Access: Authenticated, Summary: "Commit SCORM runtime data",
+		Body: "data:object!", Response: "=object"},
+	{Method: "POST", Path: "/api/lessons/:id/xapi/statements", Access: Authenticated, Summary: "Record xAPI statements (one or an array)",
+		Body: "verb:object, object:object, result:object", Response: "=object"},
+
+	// Assessments
+	{Method: "POST", Path: "/api/assessments/quizzes", Access: Instructor, Summary: "Create a quiz", Status: http.StatusCreated,
+		Body:     "title!, description, instructions, course_id:integer!, module_id:integer, lesson_id:integer, time_limit:integer, max_attempts:integer, passing_score:integer, is_required:boolean, opens_at:date-time, closes_at:date-time, questions:[object]",
+		Response: "=Quiz"},
+	{Method: "DELETE", Path: "/api/assessments/quizzes/:quizId", Access: Instructor, Summary: "Move a quiz to the trash",
+		Response: "message, purge_at:date-time"},
+	{Method: "GET", Path: "/api/assessments/quizzes/:quizId/export", Access: Instructor, Summary: "Printable quiz sheet",
+		Query: "format, answer_key:boolean", Produces: "application/pdf"},
+	{Method: "POST", Path: "/api/assessments/quizzes/:quizId/paper-results", Access: Instructor, Summary: "Import results of a paper quiz (JSON or CSV)",
+		Body: "taken_at:date-time, results:[object]!", Response: "=object"},
+	{Method: "POST", Path: "/api/assessments/quizzes/:quizId/attempt", Access: Authenticated, Summary: "Start a quiz attempt",
+		Response: "attempt:QuizAttempt, quiz:Quiz"},
+	{Method: "POST", Path: "/api/assessments/attempts/:attemptId/answer", Access: Authenticated, Summary: "Answer a question of an attempt",
+		Body: "question_id:integer!, answer!", Response: "=QuizAnswer"},
+	{Method: "POST", Path: "/api/assessments/attempts/:attemptId/complete", Access: Authenticated, Summary: "Complete an attempt and score it",
+		Response: "=QuizAttempt"},
+	{Method: "GET", Path: "/api/assessments/quizzes/:quizId/attempts", Access: Authenticated, Summary: "The caller's attempts of a quiz",
+		Response: "=[QuizAttempt]"},
+	{Method: "GET", Path: "/api/assessments/quizzes/:quizId/all-attempts", Access: Instructor, Summary: "All attempts of a quiz",
+		Response: "=[QuizAttempt]"},
+	{Method: "POST", Path: "/api/assessments/assignments", Access: Instructor, Summary: "Create an assignment", Status: http.StatusCreated,
+		Body:     "title!, description, instructions, course_id:integer!, module_id:integer, due_date:date-time!, max_points:integer",
+		Response: "=Assignment"},
+	{Method: "POST", Path: "/api/assessments/assignments/:assignmentId/submit", Access: Authenticated, Summary: "Submit an assignment",
+		Status: http.StatusCreated, Body: "file:binary, submission_text", Response: "=AssignmentSubmission"},
+	{Method: "GET", Path: "/api/assessments/assignments/:assignmentId/submissions", Access: Authenticated, Summary: "The caller's submissions",
+		Response: "=[AssignmentSubmission]"},
+	{Method: "GET", Path: "/api/assessments/assignments/:assignmentId/all-submissions", Access: Instructor, Summary: "All submissions of an assignment",
+		Response: "=[AssignmentSubmission]"},
+	{Method: "POST", Path: "/api/assessments/submissions/:submissionId/grade", Access: Instructor, Summary: "Grade a submission",
+		Body: "grade:number!, feedback", Response: "=AssignmentSubmission"},
+	{Method: "GET", Path: "/api/assessments/submissions/:submissionId/receipt", Access: Authenticated, Summary: "Signed receipt of a submission",
+		Response: "receipt:object"},
+	{Method: "POST", Path: "/api/assessments/submissions/:submissionId/verify", Access: Authenticated, Summary: "Check a file or text against a submission",
+		Body: "file:binary, submission_text", Response: "=object"},
+
+	// Administration
+	{Method: "GET", Path: "/api/admin/stats", Access: Admin, Summary: "Platform statistics", Response: "stats:object"},
+	{Method: "GET", Path: "/api/admin/slo", Access: Admin, Summary: "Service levels of the critical flows", Query: "days:integer",
+		Response: "days:integer, flows:[object], alerts:[SLOAlert]"},
+	{Method: "GET", Path: "/api/admin/payments/recent", Access: Admin, Summary: "Recent payments", Response: "payments:[Payment], count:integer"},
+	{Method: "GET", Path: "/api/admin/payments/methods", Access: Admin, Summary: "Payments by payment method",
+		Query: "from, to", Response: "methods:[object], count:integer"},
+	{Method: "GET", Path: "/api/admin/payments/export", Access: Admin, Summary: "Export payments for accounting",
+		Query: "from, to, status, format, columns, async:boolean", Produces: "text/csv"},
+	{Method: "GET", Path: "/api/admin/payments/exports", Access: Admin, Summary: "List background payment exports",
+		Response: "exports:[PaymentExport], formats:[string], fields:[string]"},
+	{Method: "GET", Path: "/api/admin/payments/exports/:id/download", Access: Admin, Summary: "Download a payment export", Produces: "text/csv"},
+	{Method: "POST", Path: "/api/admin/payments/reconcile", Access: Admin, Summary: "Reconcile pending payments now",
+		Response: "reconciliation:PaymentReconciliation"},
+	{Method: "GET", Path: "/api/admin/payments/reconciliations", Access: Admin, Summary: "List reconciliation reports",
+		Query: "mismatches:boolean, page:integer", Response: "reconciliations:[PaymentReconciliation], total:integer, page:integer"},
+	{Method: "GET", Path: "/api/admin/payments/reconciliations/:id", Access: Admin, Summary: "Get a reconciliation report",
+		Response: "reconciliation:PaymentReconciliation"},
+	{Method: "GET", Path: "/api/admin/webhooks", Access: Admin, Summary: "List received payment webhooks",
+		Query: "provider, status, tx_ref, page:integer", Response: "events:[WebhookEvent], total:integer, page:integer"},
+	{Method: "GET", Path: "/api/admin/webhooks/:id", Access: Admin, Summary: "Get a webhook event with its body", Response: "event:WebhookEvent"},
+	{Method: "POST", Path: "/api/admin/webhooks/:id/replay", Access: Admin, Summary: "Process a failed webhook event again",
+		Response: "event:WebhookEvent, result:object"},
+	{Method: "GET", Path: "/api/admin/webhook-endpoints", Access: Admin, Summary: "List outbound webhook endpoints",
+		Response: "endpoints:[WebhookEndpoint], events:[string]"},
+	{Method: "POST", Path: "/api/admin/webhook-endpoints", Access: Admin, Summary: "Register an outbound webhook endpoint",
+		Status: http.StatusCreated, Body: "url!, description, events:[string]!", Response: "endpoint:WebhookEndpoint, secret"},
+	{Method: "PUT", Path: "/api/admin/webhook-endpoints/:id", Access: Admin, Summary: "Update an outbound webhook endpoint",
+		Body: "url, description, events:[string], active:boolean, rotate_secret:boolean", Response: "endpoint:WebhookEndpoint, secret"},
+	{Method: "DELETE", Path: "/api/admin/webhook-endpoints/:id", Access: Admin, Summary: "Delete an outbound webhook endpoint", Response: "message"},
+	{Method: "POST", Path: "/api/admin/webhook-endpoints/:id/test", Access: Admin, Summary: "Send a ping event to an endpoint",
+		Response: "delivery:WebhookDelivery"},
+	{Method: "GET", Path: "/api/admin/webhook-deliveries", Access: Admin, Summary: "List outbound webhook deliveries",
+		Query: "endpoint_id:integer, status, event_type, event_id, page:integer", Response: "deliveries:[WebhookDelivery], total:integer, page:integer"},
+	{Method: "GET", Path: "/api/admin/webhook-deliveries/:id", Access: Admin, Summary: "Get a webhook delivery with its payload",
+		Response: "delivery:WebhookDelivery"},
+	{Method: "POST", Path: "/api/admin/webhook-deliveries/:id/redeliver", Access: Admin, Summary: "Send a webhook delivery again",
+		Response: "delivery:WebhookDelivery"},
+	{Method: "GET", Path: "/api/admin/enrollments/recent", Access: Admin, Summary: "Recent enrollments",
+		Response: "enrollments:[Enrollment], count:integer"},
+	{Method: "GET", Path: "/api/admin/courses/:id/analytics", Access: Admin, Summary: "Analytics of a course",
+		Response: "course_id:integer, analytics:object, funnel:object"},
+	{Method: "GET", Path: "/api/admin/country-rules", Access: Admin, Summary: "List per-country course rules",
+		Query: "country, course_id:integer", Response: "rules:[CourseCountryRule]"},
+	{Method: "PUT", Path: "/api/admin/courses/:id/country-rules/:country", Access: Admin, Summary: "Hide or reprice a course in a country",
+		Body: "action!, price:number, note", Response: "message, rule:CourseCountryRule"},
+	{Method: "DELETE", Path: "/api/admin/courses/:id/country-rules/:country", Access: Admin, Summary: "Remove a country rule", Response: "message"},
+	{Method: "POST", Path: "/api/admin/certificates/:id/revoke", Access: Admin, Summary: "Revoke a certificate",
+		Body: "reason!", Response: "message, certificate:Certificate"},
+	{Method: "GET", Path: "/api/admin/reviews", Access: Admin, Summary: "List reviews for moderation",
+		Query: "course_id:integer, featured:boolean, flagged:boolean, min_rating:integer, status", Response: "reviews:[Review], count:integer"},
+	{Method: "PUT", Path: "/api/admin/reviews/:id/featured", Access: Admin, Summary: "Feature a review on the homepage",
+		Body: "featured:boolean!", Response: "message, id:integer, featured:boolean"},
+	{Method: "PUT", Path: "/api/admin/reviews/:id/moderation", Access: Admin, Summary: "Approve or hide a review",
+		Body: "action!, reason", Response: "message, review:Review"},
+	{Method: "GET", Path: "/api/admin/publish-checklist", Access: Admin, Summary: "Rules courses must pass to be published",
+		Response: "rules:[PublishRule], rule_types:[object]"},
+	{Method: "PUT", Path: "/api/admin/publish-checklist", Access: Admin, Summary: "Change a publish rule",
+		Body: "rule!, threshold:number, enabled:boolean", Response: "message, rules:[PublishRule]"},
+	{Method: "GET", Path: "/api/admin/audit-logs", Access: Admin, Summary: "Audit log of sensitive actions",
+		Query: "actor_id:integer, action, entity_type, entity_id:integer, from, to, page:integer", Response: "entries:[AuditLog], total:integer, page:integer"},
+	{Method: "GET", Path: "/api/admin/jobs", Access: Admin, Summary: "Background jobs with their schedule, state and last run",
+		Response: "jobs:[object]"},
+	{Method: "GET", Path: "/api/admin/jobs/runs", Access: Admin, Summary: "List runs of background jobs",
+		Query: "job, status, page:integer", Response: "runs:[JobRun], total:integer, page:integer"},
+	{Method: "GET", Path: "/api/admin/integrity", Access: Admin, Summary: "Summary of the data integrity checks",
+		Response: "checks:[object], open_issues:integer, last_checked_at:date-time"},
+	{Method: "POST", Path: "/api/admin/integrity/check", Access: Admin, Summary: "Run the integrity checks now", Response: "=object"},
+	{Method: "GET", Path: "/api/admin/integrity/issues", Access: Admin, Summary: "List integrity issues",
+		Query: "type, status, resolution, page:integer", Response: "issues:[IntegrityIssue], total:integer, page:integer"},
+	{Method: "POST", Path: "/api/admin/integrity/issues/:id/repair", Access: Admin, Summary: "Repair an integrity issue",
+		Response: "message, repair, issue:IntegrityIssue"},
+	{Method: "POST", Path: "/api/admin/integrity/issues/:id/dismiss", Access: Admin, Summary: "Dismiss an integrity issue",
+		Body: "note", Response: "message, issue:IntegrityIssue"},
+	{Method: "GET", Path: "/api/admin/settings", Access: Admin, Summary: "Platform settings", Response: "settings:[object]"},
+	{Method: "PUT", Path: "/api/admin/settings", Access: Admin, Summary: "Change platform settings (a map of key to value)",
+		Body: "tax_rate_percent, tax_label, receipt_issuer", Response: "message, settings:[object]"},
+	{Method: "GET", Path: "/api/admin/verification-keys", Access: Admin, Summary: "List certificate verification API keys",
+		Response: "keys:[VerificationAPIKey]"},
+	{Method: "POST", Path: "/api/admin/verification-keys", Access: Admin, Summary: "Create a verification API key", Status: http.StatusCreated,
+		Body: "name!, contact_email, rate_limit:integer", Response: "message, key, api_key:VerificationAPIKey"},
+	{Method: "PUT", Path: "/api/admin/verification-keys/:id", Access: Admin, Summary: "Update a verification API key",
+		Body: "name, contact_email, rate_limit:integer", Response: "message, api_key:VerificationAPIKey"},
+	{Method: "DELETE", Path: "/api/admin/verification-keys/:id", Access: Admin, Summary: "Revoke a verification API key", Response: "message"},
+	{Method: "GET", Path: "/api/admin/moderation", Access: Admin, Summary: "Moderation queue", Query: "status, type",
+		Response: "items:[ModerationItem], count:integer"},
+	{Method: "PUT", Path: "/api/admin/moderation/:id", Access: Admin, Summary: "Approve or remove a moderated item",
+		Body: "action!", Response: "message, item:ModerationItem"},
+	{Method: "GET", Path: "/api/admin/grading-scale", Access: Admin, Summary: "Default grading scale", Response: "grading_scale:object, source"},
+	{Method: "PUT", Path: "/api/admin/grading-scale", Access: Admin, Summary: "Set the default grading scale",
+		Body: "name, pass_threshold:number!, bands:[object]!", Response: "message, grading_scale:object, source"},
+	{Method: "GET", Path: "/api/admin/file-access/logs", Access: Admin, Summary: "File access log",
+		Query: "user_id:integer, course_id:integer, throttled:boolean", Response: "logs:[FileAccessLog], count:integer"},
+	{Method: "GET", Path: "/api/admin/file-access/suspicious", Access: Admin, Summary: "Users downloading unusually many files",
+		Query: "hours:integer, threshold:integer", Response: "users:[object], count:integer, hours:integer, threshold:integer"},
+	{Method: "GET", Path: "/api/admin/quarantine", Access: Admin, Summary: "List quarantined uploads",
+		Response: "files:[QuarantinedFile], count:integer, scanning_enabled:boolean"},
+	{Method: "DELETE", Path: "/api/admin/quarantine/:id", Access: Admin, Summary: "Delete a quarantined upload", Response: "message"},
+	{Method: "POST", Path: "/api/admin/badges", Access: Admin, Summary: "Create a badge", Status: http.StatusCreated,
+		Body: "code!, name!, description, icon_url, criterion!, threshold:integer!", Response: "badge:Badge"},
+	{Method: "PUT", Path: "/api/admin/badges/:id", Access: Admin, Summary: "Update a badge",
+		Body: "code!, name!, description, icon_url, criterion!, threshold:integer!", Response: "badge:Badge"},
+	{Method: "DELETE", Path: "/api/admin/badges/:id", Access: Admin, Summary: "Delete a badge", Response: "message"},
+	{Method: "GET", Path: "/api/admin/devices", Access: Admin, Summary: "List device fingerprints",
+		Query: "flagged:boolean, blocked:boolean, page:integer", Response: "devices:[DeviceFingerprint], total:integer, page:integer"},
+	{Method: "GET", Path: "/api/admin/devices/:id", Access: Admin, Summary: "Get a device with its events",
+		Response: "device:DeviceFingerprint, events:[DeviceEvent]"},
+	{Method: "PUT", Path: "/api/admin/devices/:id", Access: Admin, Summary: "Block, unblock or clear a device",
+		Body: "action!, reason", Response: "message, device:DeviceFingerprint"},
+	{Method: "GET", Path: "/api/admin/users", Access: Admin, Summary: "List users", Response: "users:[object], count:integer"},
+	{Method: "PUT", Path: "/api/admin/users/:id/role", Access: Admin, Summary: "Change a user's role", Body: "role!",
+		Response: "message, user:object"},
+	{Method: "GET", Path: "/api/admin/users/:id/login-attempts", Access: Admin, Summary: "A user's login attempts",
+		Query:    "failed:boolean, page:integer",
+		Response: "user_id:integer, locked:boolean, locked_until:date-time, failed_login_attempts:integer, attempts:[LoginAttempt], total:integer, page:integer"},
+	{Method: "POST", Path: "/api/admin/users/:id/unlock", Access: Admin, Summary: "Lift a login lockout", Response: "message"},
+	{Method: "POST", Path: "/api/admin/users/:id/2fa/reset", Access: Admin, Summary: "Turn off a user's two-factor login", Response: "message"},
+	{Method: "POST", Path: "/api/admin/users/:id/sessions/revoke", Access: Admin, Summary: "Log a user out everywhere",
+		Response: "message, revoked:integer"},
+	{Method: "POST", Path: "/api/admin/users/:id/impersonate", Access: Admin, Summary: "Get a token acting as a user",
+		Body: "reason!", Response: "message, token, impersonating:object, expires_at:date-time, user:object"},
+	{Method: "POST", Path: "/api/admin/users/import", Access: Admin, Summary: "Invite users from a CSV (body or form file)",
+		Body: "file:binary", Response: "message, created:integer, failed:integer, results:[object]"},
+	{Method: "POST", Path: "/api/admin/users/:id/invitation", Access: Admin, Summary: "Send an invitation again", Response: "message"},
+	{Method: "DELETE", Path: "/api/admin/users/:id", Access: Admin, Summary: "Delete a user", Response: "message"},
+	{Method: "GET", Path: "/api/admin/users/:id/upload-quota", Access: Admin, Summary: "A user's upload quota",
+		Response: "user_id:integer, role, quota:object"},
+	{Method: "PUT", Path: "/api/admin/users/:id/upload-quota", Access: Admin, Summary: "Override a user's upload quota",
+		Body: "quota_bytes:integer!, note", Response: "message, user_id:integer, quota:object"},
+	{Method: "DELETE", Path: "/api/admin/users/:id/upload-quota", Access: Admin, Summary: "Reset a user's upload quota to their role's",
+		Response: "message, user_id:integer, quota:object"},
+	{Method: "GET", Path: "/api/admin/organizations", Access: Admin, Summary: "List organizations", Response: "organizations:[object]"},
+	{Method: "POST", Path: "/api/admin/organizations/:id/seats", Access: Admin, Summary: "Grant seats without a payment",
+		Body: "course_id:integer!, seats:integer!, reason!", Response: "message"},
+	{Method: "GET", Path: "/api/admin/email-domains", Access: Admin, Summary: "Email domains accepted at registration",
+		Response: "allowed_domains:[string], count:integer"},
+	{Method: "POST", Path: "/api/admin/email-domains", Access: Admin, Summary: "Accept an email domain",
+		Body: "domain!", Response: "message, domain, allowed_domains:[string]"},
+	{Method: "DELETE", Path: "/api/admin/email-domains/:domain", Access: Admin, Summary: "Stop accepting an email domain",
+		Response: "message, domain, allowed_domains:[string]"},
+
+	// Operations and testing
+	{Method: "GET", Path: "/health", Access: Public, Summary: "Health of the server and its dependencies", Response: "status, environment, chapa, payment_provider, realtime_clients:integer"},
+	{Method: "GET", Path: "/healthz", Access: Public, Summary: "Liveness probe: the process answers", Response: "status"},
+	{Method: "GET", Path: "/readyz", Access: Public, Summary: "Readiness probe: status and latency of the database, migrations, storage, SMTP and background workers (503 when a critical one is down)", Response: "status, checks:object"},
+	{Method: "GET", Path: "/metrics", Access: Public, Summary: "Prometheus metrics (bearer METRICS_TOKEN when set)", Produces: "text/plain"},
+	{Method: "GET", Path: "/api/docs", Access: Public, Summary: "Swagger UI of this API", Produces: "text/html"},
+	{Method: "GET", Path: "/api/docs/openapi.json", Access: Public, Summary: "This OpenAPI document", Response: "=object"},
+	{Method: "POST", Path: "/api/test/reset", Access: Public, Summary: "Empty the database (test mode only)", Response: "message, now:date-time"},
+	{Method: "POST", Path: "/api/test/fixtures", Access: Public, Summary: "Create fixtures (test mode only)",
+		Body: "course_price:number, enroll_student:boolean", Response: "=object"},
+	{Method: "GET", Path: "/api/test/clock", Access: Public, Summary: "The test clock (test mode only)", Response: "now:date-time"},
+	{Method: "POST", Path: "/api/test/clock", Access: Public, Summary: "Set or advance the test clock (test mode only)",
+		Body: "now, advance", Response: "now:date-time"},
+}
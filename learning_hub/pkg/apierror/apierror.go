@@ -0,0 +1,218 @@
+// Package apierror is the error envelope of the API. Every error answer has the shape
+//
+//	{"error": "Course not found", "code": "not_found", "request_id": "..."}
+//
+// with "fields" added to invalid requests ({"email": "is required"}) and, for some errors, keys telling
+// the client how to recover, such as the allowed values. Handlers and middleware stop a request with Abort;
+// middleware.Errors writes the envelope once the chain returns, so errors are logged and mapped in one place.
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/validation"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Codes other than the ones derived from the status
+const (
+	CodeValidation  = "validation_failed" // fields has the problem of each field
+	CodeInvalidBody = "invalid_body"      // the body is missing or is not valid JSON
+	CodeDuplicate   = "duplicate"         // a unique value is already taken
+	CodeTimeout     = "timeout"           // the database took too long
+
+	CodeInvalidCredentials    = "invalid_credentials"
+	CodeAccountLocked         = "account_locked"
+	CodeEmailNotVerified      = "email_not_verified"
+	CodeEmailTaken            = "email_taken"
+	CodeEmailDomainNotAllowed = "email_domain_not_allowed"
+)
+
+// Error is an error answer. Its code defaults to the status text in snake case, e.g. not_found.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  map[string]string      // problems of an invalid request, by JSON field name
+	Details map[string]interface{} // extra keys of the answer
+	Cause   error                  // logged for server errors, never shown
+
+	problems []validation.Problem // of an invalid request, worded in the client's language by Localize
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Cause }
+
+// New returns an error answering status with message
+func New(status int, message string) *Error {
+	return &Error{Status: status, Code: strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_"), Message: message}
+}
+
+func BadRequest(message string) *Error   { return New(http.StatusBadRequest, message) }
+func Unauthorized(message string) *Error { return New(http.StatusUnauthorized, message) }
+func Forbidden(message string) *Error    { return New(http.StatusForbidden, message) }
+func NotFound(message string) *Error     { return New(http.StatusNotFound, message) }
+func Conflict(message string) *Error     { return New(http.StatusConflict, message) }
+func Internal(message string) *Error     { return New(http.StatusInternalServerError, message) }
+
+// TooLarge is the answer to a request whose body is over limit bytes, which the answer carries as max_bytes
+func TooLarge(limit int64) *Error {
+	return New(http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body is larger than %s", byteSize(limit))).
+		With("max_bytes", limit)
+}
+
+// byteSize writes a size in the largest whole unit, e.g. 2 MB
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%d GB", n>>30)
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// WithCode replaces the code, for errors clients handle specifically
+func (e *Error) WithCode(code string) *Error {
+	e.Code = code
+	return e
+}
+
+// With adds a key to the answer
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = map[string]interface{}{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// WithFields adds the problems of an invalid request
+func (e *Error) WithFields(fields map[string]string) *Error {
+	e.Fields = fields
+	if e.Status == http.StatusBadRequest {
+		e.Code = CodeValidation
+	}
+	return e
+}
+
+// Wrap records the error that caused this one, for the logs
+func (e *Error) Wrap(cause error) *Error {
+	e.Cause = cause
+	return e
+}
+
+// Body is the JSON answer of the error
+func (e *Error) Body(requestID string) gin.H {
+	body := gin.H{}
+	for key, value := range e.Details {
+		body[key] = value
+	}
+	body["error"] = e.Message
+	body["code"] = e.Code
+	if len(e.Fields) > 0 {
+		body["fields"] = e.Fields
+	}
+	if requestID != "" {
+		body["request_id"] = requestID
+	}
+	return body
+}
+
+// Abort stops the request with err. Its status is set right away, for middleware looking at it once the
+// handler returns, and its body is written by middleware.Errors.
+func Abort(c *gin.Context, err error) {
+	e := From(err)
+	c.Status(e.Status)
+	_ = c.Error(e)
+	c.Abort()
+}
+
+// From maps an error to its answer: missing records are 404, unique violations 409, database timeouts
+// 503, unreadable or invalid request bodies 400, bodies over their limit 413 and anything else 500, with
+// err kept as the cause
+func From(err error) *Error {
+	var e *Error
+	var pgErr *pgconn.PgError
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.As(err, &tooLarge):
+		return TooLarge(tooLarge.Limit).Wrap(err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NotFound("Not found").Wrap(err)
+	case errors.Is(err, gorm.ErrDuplicatedKey), errors.As(err, &pgErr) && pgErr.Code == "23505":
+		return Conflict("This already exists").WithCode(CodeDuplicate).Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return New(http.StatusServiceUnavailable, "The request took too long, please try again").WithCode(CodeTimeout).Wrap(err)
+	case errors.As(err, &validationErrs), errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return Bind(err)
+	}
+	return Internal("Internal server error").Wrap(err)
+}
+
+// Bind maps an error binding the request (ShouldBindJSON and the like) to a 400, naming the invalid fields,
+// or to a 413 when the body is over its limit
+func Bind(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return TooLarge(tooLarge.Limit).Wrap(err)
+	case errors.As(err, &validationErrs):
+		return Invalid(validation.Problems(validationErrs)...).Wrap(err)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return Invalid(validation.TypeProblem(typeErr.Field, typeErr.Type)).Wrap(err)
+	case errors.Is(err, io.EOF):
+		return BadRequest("The request body is empty").WithCode(CodeInvalidBody).Wrap(err)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return BadRequest("The request body is not valid JSON").WithCode(CodeInvalidBody).Wrap(err)
+	}
+	return BadRequest("The request could not be read").WithCode(CodeInvalidBody).Wrap(err)
+}
+
+// Invalid is the answer to a request whose fields are invalid, its message listing them
+func Invalid(problems ...validation.Problem) *Error {
+	e := BadRequest("").WithCode(CodeValidation)
+	e.problems = problems
+	return e.Localize(i18n.DefaultLocale)
+}
+
+// Localize translates the message and field problems into locale. Messages built with dynamic parts
+// have no translation and stay in English.
+func (e *Error) Localize(locale string) *Error {
+	if len(e.problems) > 0 {
+		e.Message = validation.Summary(e.problems, locale)
+		e.Fields = validation.Messages(e.problems, locale)
+		return e
+	}
+	e.Message = i18n.T(locale, e.Message)
+	for field, problem := range e.Fields {
+		e.Fields[field] = i18n.T(locale, problem)
+	}
+	return e
+}
@@ -0,0 +1,278 @@
+// Package cache keeps the results of hot reads, such as the course catalogue, in Redis shared by the
+// instances of the API, or in the memory of the process when REDIS_URL is unset. Reads are cached by
+// group and invalidated a whole group at a time, on every instance, by bumping the group's version:
+//
+//	course, err := cache.Get(ctx, "course", id, 5*time.Minute, func() (models.Course, error) { ... })
+//
+//	cache.Invalidate(ctx, "course")
+//
+// The dbcache plugin invalidates groups when the tables they are read from are written. While Redis
+// fails, reads go to the database and nothing is cached, rather than falling back to entries of this
+// instance that a write elsewhere may have made stale.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"learning_hub/pkg/config"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Cached reads, by group and result (hit, miss, or error when the cache failed)",
+	}, []string{"group", "result"})
+	cacheInvalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_invalidations_total",
+		Help: "Invalidations of cache groups",
+	}, []string{"group"})
+)
+
+// keyPrefix keeps the keys of the cache apart from other users of the Redis database
+const keyPrefix = "learning_hub:cache:"
+
+// backendTimeout bounds each call to Redis, so a slow cache can't hold requests up for long
+const backendTimeout = 500 * time.Millisecond
+
+// backend stores the entries and the versions of the groups
+type backend interface {
+	// get returns the values of the keys, nil for the missing ones
+	get(ctx context.Context, keys ...string) ([][]byte, error)
+	set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	incr(ctx context.Context, key string) error
+	ping(ctx context.Context) error
+}
+
+var (
+	mu      sync.RWMutex
+	current backend = newMemory(10000)
+
+	failing atomic.Bool // whether the last call to the backend failed, to log only the changes
+)
+
+// Init connects to Redis at REDIS_URL, or keeps the cache in memory without it. An unreachable Redis
+// doesn't stop the API from starting: reads skip the cache until it answers.
+func Init(cfg *config.Config) error {
+	if cfg.RedisURL == "" {
+		use(newMemory(cfg.CacheMemoryEntries))
+		slog.Info("Cache kept in memory", "max_entries", cfg.CacheMemoryEntries)
+		return nil
+	}
+	options, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	use(&redisBackend{client: redis.NewClient(options)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := Ping(ctx); err != nil {
+		slog.Warn("Redis is unreachable, reads skip the cache until it answers", "addr", options.Addr, "error", err)
+	} else {
+		slog.Info("Cache kept in Redis", "addr", options.Addr)
+	}
+	return nil
+}
+
+func use(b backend) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = b
+}
+
+func active() backend {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Ping checks the cache answers, for the readiness probe
+func Ping(ctx context.Context) error {
+	return active().ping(ctx)
+}
+
+// Get returns the cached value of key in group, or calls load and caches its result for ttl. Errors of
+// load are returned and not cached. A ttl of 0 skips the cache.
+func Get[T any](ctx context.Context, group, key string, ttl time.Duration, load func() (T, error)) (T, error) {
+	if ttl <= 0 {
+		return load()
+	}
+	b := active()
+	versionKey, entryKey := keyPrefix+group+":version", keyPrefix+group+":"+key
+
+	callCtx, cancel := context.WithTimeout(ctx, backendTimeout)
+	values, err := b.get(callCtx, versionKey, entryKey)
+	cancel()
+	if reportFailure(err) {
+		cacheRequests.WithLabelValues(group, "error").Inc()
+		return load()
+	}
+
+	// An entry is only valid for the version of its group it was loaded at
+	version := values[0]
+	if versionOf, data, ok := bytes.Cut(values[1], []byte("\n")); ok && bytes.Equal(versionOf, version) {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			cacheRequests.WithLabelValues(group, "hit").Inc()
+			return value, nil
+		}
+	}
+	cacheRequests.WithLabelValues(group, "miss").Inc()
+
+	// The version was read before loading: a write during the load bumps it, and this entry is never served
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to encode a cached value", "group", group, "error", err)
+		return value, nil
+	}
+	entry := make([]byte, 0, len(version)+1+len(data))
+	entry = append(append(append(entry, version...), '\n'), data...)
+	callCtx, cancel = context.WithTimeout(ctx, backendTimeout)
+	defer cancel()
+	reportFailure(b.set(callCtx, entryKey, entry, ttl))
+	return value, nil
+}
+
+// Invalidate drops every cached value of the group, on every instance. It outlives the cancellation of
+// ctx, so a write answered before its invalidation still gets it.
+func Invalidate(ctx context.Context, group string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), backendTimeout)
+	defer cancel()
+	if err := active().incr(ctx, keyPrefix+group+":version"); err != nil {
+		// Entries of the group live until their TTL then
+		slog.ErrorContext(ctx, "Failed to invalidate cache group", "group", group, "error", err)
+		reportFailure(err)
+		return
+	}
+	cacheInvalidations.WithLabelValues(group).Inc()
+}
+
+// reportFailure logs when the backend starts failing or recovers, and reports whether err is a failure
+func reportFailure(err error) bool {
+	if err != nil {
+		if !failing.Swap(true) {
+			slog.Warn("Cache failed, reads go to the database until it recovers", "error", err)
+		}
+		return true
+	}
+	if failing.Swap(false) {
+		slog.Info("Cache recovered")
+	}
+	return false
+}
+
+// redisBackend keeps the cache in Redis
+type redisBackend struct {
+	client *redis.Client
+}
+
+func (r *redisBackend) get(ctx context.Context, keys ...string) ([][]byte, error) {
+	results, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(results))
+	for i, result := range results {
+		if s, ok := result.(string); ok {
+			values[i] = []byte(s)
+		}
+	}
+	return values, nil
+}
+
+func (r *redisBackend) set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *redisBackend) incr(ctx context.Context, key string) error {
+	return r.client.Incr(ctx, key).Err()
+}
+
+func (r *redisBackend) ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// memory keeps the cache in the process, for a single instance. When full, expired entries are dropped
+// first, then arbitrary ones.
+type memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]memoryEntry
+	versions   map[string][]byte
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func newMemory(maxEntries int) *memory {
+	return &memory{maxEntries: maxEntries, entries: map[string]memoryEntry{}, versions: map[string][]byte{}}
+}
+
+func (m *memory) get(ctx context.Context, keys ...string) ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		if version, ok := m.versions[key]; ok {
+			values[i] = version
+		} else if entry, ok := m.entries[key]; ok && now.Before(entry.expiresAt) {
+			values[i] = entry.value
+		}
+	}
+	return values, nil
+}
+
+func (m *memory) set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.entries[key]; !exists && len(m.entries) >= m.maxEntries {
+		m.evict()
+	}
+	m.entries[key] = memoryEntry{value: value, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *memory) evict() {
+	now := time.Now()
+	for key, entry := range m.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+	for key := range m.entries {
+		if len(m.entries) < m.maxEntries {
+			return
+		}
+		delete(m.entries, key)
+	}
+}
+
+func (m *memory) incr(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	version, _ := strconv.ParseInt(string(m.versions[key]), 10, 64)
+	m.versions[key] = []byte(strconv.FormatInt(version+1, 10))
+	return nil
+}
+
+func (m *memory) ping(ctx context.Context) error {
+	return nil
+}
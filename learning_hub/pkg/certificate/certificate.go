@@ -0,0 +1,219 @@
+package certificate
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// Canvas size of a rendered certificate (A4 landscape at 96 DPI)
+const (
+	CanvasWidth  = 1123
+	CanvasHeight = 794
+)
+
+// Element names that can be positioned on a certificate
+const (
+	ElementTitle            = "title"
+	ElementStudentName      = "student_name"
+	ElementBody             = "body"
+	ElementIssueDate        = "issue_date"
+	ElementSignature        = "signature"
+	ElementVerificationCode = "verification_code"
+)
+
+// Placement describes where and how an element is drawn.
+// X and Y are percentages of the canvas size so templates scale with the background.
+type Placement struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	FontSize int     `json:"font_size"`
+	Color    string  `json:"color"`
+	Align    string  `json:"align"` // start, middle or end
+}
+
+// Template holds everything needed to render a certificate
+type Template struct {
+	BackgroundImageURL string               `json:"background_image_url"`
+	SignatureImageURL  string               `json:"signature_image_url"`
+	Title              string               `json:"title"`
+	Wording            string               `json:"wording"`
+	SignatoryName      string               `json:"signatory_name"`
+	SignatoryTitle     string               `json:"signatory_title"`
+	Placements         map[string]Placement `json:"placements"`
+}
+
+// Data is the per-student information merged into a template
+type Data struct {
+	CertificateID    string
+	StudentName      string
+	CourseTitle      string
+	InstructorName   string
+	IssueDate        time.Time
+	VerificationCode string
+	Grade            string // letter grade, empty when the course had nothing graded
+}
+
+// DefaultTemplate returns the platform certificate layout used when a course has no custom template
+func DefaultTemplate() Template {
+	return Template{
+		Title:          "Certificate of Completion",
+		Wording:        "This certifies that {{student_name}} has successfully completed the course {{course_title}}.",
+		SignatoryName:  "{{instructor_name}}",
+		SignatoryTitle: "Course Instructor",
+		Placements:     DefaultPlacements(),
+	}
+}
+
+// DefaultPlacements returns the default position of every element
+func DefaultPlacements() map[string]Placement {
+	return map[string]Placement{
+		ElementTitle:            {X: 50, Y: 22, FontSize: 48, Color: "#1e293b", Align: "middle"},
+		ElementStudentName:      {X: 50, Y: 42, FontSize: 40, Color: "#7c3aed", Align: "middle"},
+		ElementBody:             {X: 50, Y: 55, FontSize: 20, Color: "#334155", Align: "middle"},
+		ElementIssueDate:        {X: 25, Y: 82, FontSize: 16, Color: "#334155", Align: "middle"},
+		ElementSignature:        {X: 75, Y: 82, FontSize: 16, Color: "#334155", Align: "middle"},
+		ElementVerificationCode: {X: 50, Y: 94, FontSize: 12, Color: "#64748b", Align: "middle"},
+	}
+}
+
+// WithDefaults fills in any empty fields of t from the default template
+func (t Template) WithDefaults() Template {
+	def := DefaultTemplate()
+	if t.Title == "" {
+		t.Title = def.Title
+	}
+	if t.Wording == "" {
+		t.Wording = def.Wording
+	}
+	if t.SignatoryName == "" {
+		t.SignatoryName = def.SignatoryName
+	}
+	if t.SignatoryTitle == "" {
+		t.SignatoryTitle = def.SignatoryTitle
+	}
+
+	placements := make(map[string]Placement, len(def.Placements))
+	for name, p := range def.Placements {
+		if custom, ok := t.Placements[name]; ok {
+			if custom.FontSize <= 0 {
+				custom.FontSize = p.FontSize
+			}
+			if custom.Color == "" {
+				custom.Color = p.Color
+			}
+			if custom.Align == "" {
+				custom.Align = p.Align
+			}
+			p = custom
+		}
+		placements[name] = p
+	}
+	t.Placements = placements
+	return t
+}
+
+// Validate checks that placements are within the canvas and use known elements
+func (t Template) Validate() error {
+	for name, p := range t.Placements {
+		if _, ok := DefaultPlacements()[name]; !ok {
+			return fmt.Errorf("unknown certificate element: %s", name)
+		}
+		if p.X < 0 || p.X > 100 || p.Y < 0 || p.Y > 100 {
+			return fmt.Errorf("placement for %s must be between 0 and 100 percent", name)
+		}
+		if p.Align != "" && p.Align != "start" && p.Align != "middle" && p.Align != "end" {
+			return fmt.Errorf("align for %s must be start, middle or end", name)
+		}
+	}
+	return nil
+}
+
+// FillWording replaces the supported placeholders in text with the certificate data
+func FillWording(text string, d Data) string {
+	return strings.NewReplacer(
+		"{{student_name}}", d.StudentName,
+		"{{course_title}}", d.CourseTitle,
+		"{{instructor_name}}", d.InstructorName,
+		"{{issue_date}}", d.IssueDate.Format("January 2, 2006"),
+		"{{certificate_id}}", d.CertificateID,
+		"{{verification_code}}", d.VerificationCode,
+		"{{grade}}", d.Grade,
+	).Replace(text)
+}
+
+// SampleData returns placeholder data used when previewing a template
+func SampleData(courseTitle, instructorName string) Data {
+	return Data{
+		CertificateID:    "LHC-PREVIEW",
+		StudentName:      "Jane Student",
+		CourseTitle:      courseTitle,
+		InstructorName:   instructorName,
+		IssueDate:        time.Now(),
+		VerificationCode: "LHC-000000",
+		Grade:            "A",
+	}
+}
+
+// RenderSVG renders the certificate as a standalone SVG document
+func RenderSVG(t Template, d Data) []byte {
+	t = t.WithDefaults()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		CanvasWidth, CanvasHeight, CanvasWidth, CanvasHeight)
+
+	if t.BackgroundImageURL != "" {
+		fmt.Fprintf(&buf, `<image href="%s" x="0" y="0" width="%d" height="%d" preserveAspectRatio="xMidYMid slice"/>`,
+			html.EscapeString(t.BackgroundImageURL), CanvasWidth, CanvasHeight)
+	} else {
+		fmt.Fprintf(&buf, `<rect x="0" y="0" width="%d" height="%d" fill="#ffffff"/>`, CanvasWidth, CanvasHeight)
+		fmt.Fprintf(&buf, `<rect x="20" y="20" width="%d" height="%d" fill="none" stroke="#7c3aed" stroke-width="6"/>`,
+			CanvasWidth-40, CanvasHeight-40)
+	}
+
+	writeText(&buf, t.Placements[ElementTitle], FillWording(t.Title, d), true)
+	writeText(&buf, t.Placements[ElementStudentName], d.StudentName, true)
+	writeText(&buf, t.Placements[ElementBody], FillWording(t.Wording, d), false)
+	writeText(&buf, t.Placements[ElementIssueDate], "Issued "+d.IssueDate.Format("January 2, 2006"), false)
+
+	signature := t.Placements[ElementSignature]
+	if t.SignatureImageURL != "" {
+		x, y := position(signature)
+		fmt.Fprintf(&buf, `<image href="%s" x="%.0f" y="%.0f" width="200" height="70" preserveAspectRatio="xMidYMid meet"/>`,
+			html.EscapeString(t.SignatureImageURL), x-100, y-90)
+	}
+	writeText(&buf, signature, FillWording(t.SignatoryName, d), true)
+	titleLine := signature
+	titleLine.Y += float64(signature.FontSize) * 2 * 100 / CanvasHeight
+	writeText(&buf, titleLine, FillWording(t.SignatoryTitle, d), false)
+
+	if d.VerificationCode != "" {
+		writeText(&buf, t.Placements[ElementVerificationCode],
+			fmt.Sprintf("Certificate ID: %s | Verification code: %s", d.CertificateID, d.VerificationCode), false)
+	}
+
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+// position converts a percentage placement into canvas coordinates
+func position(p Placement) (float64, float64) {
+	return p.X / 100 * CanvasWidth, p.Y / 100 * CanvasHeight
+}
+
+// writeText writes a single positioned text element
+func writeText(buf *bytes.Buffer, p Placement, text string, bold bool) {
+	if text == "" {
+		return
+	}
+	x, y := position(p)
+	weight := "normal"
+	if bold {
+		weight = "bold"
+	}
+	fmt.Fprintf(buf, `<text x="%.0f" y="%.0f" font-family="Georgia, serif" font-size="%d" font-weight="%s" fill="%s" text-anchor="%s">%s</text>`,
+		x, y, p.FontSize, weight, html.EscapeString(p.Color), p.Align, html.EscapeString(text))
+}
@@ -2,13 +2,17 @@ package chapa
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"learning_hub/pkg/config"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // Chapa API constants
@@ -19,6 +23,17 @@ const (
 	BanksPath      = "/banks"
 )
 
+// Payment methods (channels) reported by Chapa verification
+const (
+	MethodTelebirr = "telebirr"
+	MethodCBEBirr  = "cbebirr"
+	MethodMpesa    = "mpesa"
+	MethodEbirr    = "ebirr"
+	MethodCard     = "card"
+	MethodTest     = "test"
+	MethodUnknown  = "unknown"
+)
+
 // PaymentRequest represents the request to initialize a payment
 type PaymentRequest struct {
 	Amount        string                 `json:"amount"`
@@ -98,6 +113,11 @@ var (
 	ChapaClient *Client
 )
 
+// spanName names the client spans of the calls to Chapa, e.g. "chapa POST"
+func spanName(_ string, r *http.Request) string {
+	return "chapa " + r.Method
+}
+
 // Init initializes the Chapa client with configuration
 func Init(cfg *config.Config) error {
 	if cfg.ChapaSecretKey == "" {
@@ -107,23 +127,26 @@ func Init(cfg *config.Config) error {
 	ChapaClient = &Client{
 		secretKey: cfg.ChapaSecretKey,
 		baseURL:   BaseURL,
+		// Each call is also bound to its caller's context, so requests whose client went away stop waiting,
+		// and traced under the caller's span
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   cfg.ChapaTimeout,
+			Transport: otelhttp.NewTransport(nil, otelhttp.WithSpanNameFormatter(spanName)),
 		},
 	}
 
-	log.Println("✅ Chapa client initialized successfully")
+	slog.Info("Chapa client initialized")
 	return nil
 }
 
 // TestConnection tests the Chapa API connection
-func TestConnection() error {
+func TestConnection(ctx context.Context) error {
 	if ChapaClient == nil {
 		return fmt.Errorf("Chapa client not initialized")
 	}
 
 	// Make a simple API call to test connection
-	req, err := http.NewRequest("GET", ChapaClient.baseURL+BanksPath, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", ChapaClient.baseURL+BanksPath, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
@@ -140,7 +163,7 @@ func TestConnection() error {
 		return fmt.Errorf("Chapa API returned status: %d", resp.StatusCode)
 	}
 
-	log.Println("✅ Chapa connection test successful")
+	slog.Debug("Chapa connection test succeeded")
 	return nil
 }
 
@@ -148,7 +171,7 @@ func TestConnection() error {
 // Update the InitializePayment function in pkg/chapa/chapa.go:
 
 // InitializePayment creates a new payment transaction
-func InitializePayment(paymentReq *PaymentRequest) (*PaymentResponse, error) {
+func InitializePayment(ctx context.Context, paymentReq *PaymentRequest) (*PaymentResponse, error) {
 	if ChapaClient == nil {
 		return nil, fmt.Errorf("Chapa client not initialized")
 	}
@@ -165,7 +188,7 @@ func InitializePayment(paymentReq *PaymentRequest) (*PaymentResponse, error) {
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", ChapaClient.baseURL+InitializePath, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", ChapaClient.baseURL+InitializePath, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
@@ -186,8 +209,7 @@ func InitializePayment(paymentReq *PaymentRequest) (*PaymentResponse, error) {
 		return nil, fmt.Errorf("failed to read response: %v", err)
 	}
 
-	// Debug: Print the raw response
-	fmt.Printf("🔍 Chapa Raw Response (Status: %d): %s\n", resp.StatusCode, string(body))
+	slog.DebugContext(ctx, "Chapa payment initialized", "tx_ref", paymentReq.TxRef, "status", resp.StatusCode)
 
 	// Parse response
 	var paymentResp PaymentResponse
@@ -215,13 +237,13 @@ func InitializePayment(paymentReq *PaymentRequest) (*PaymentResponse, error) {
 }
 
 // VerifyPayment verifies a payment transaction
-func VerifyPayment(txRef string) (*VerifyResponse, error) {
+func VerifyPayment(ctx context.Context, txRef string) (*VerifyResponse, error) {
 	if ChapaClient == nil {
 		return nil, fmt.Errorf("Chapa client not initialized")
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("GET", ChapaClient.baseURL+VerifyPath+txRef, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", ChapaClient.baseURL+VerifyPath+txRef, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
@@ -295,6 +317,53 @@ func validatePaymentRequest(req *PaymentRequest) error {
 	return nil
 }
 
+// NormalizePaymentMethod maps the method string returned by Chapa to one of the Method constants
+func NormalizePaymentMethod(method string) string {
+	m := strings.ToLower(strings.TrimSpace(method))
+	m = strings.NewReplacer(" ", "", "_", "", "-", "").Replace(m)
+
+	switch m {
+	case "":
+		return MethodUnknown
+	case "telebirr":
+		return MethodTelebirr
+	case "cbe", "cbebirr":
+		return MethodCBEBirr
+	case "mpesa":
+		return MethodMpesa
+	case "ebirr":
+		return MethodEbirr
+	case "card", "visa", "mastercard", "amex":
+		return MethodCard
+	case "test":
+		return MethodTest
+	default:
+		return m
+	}
+}
+
+// PaymentMethodLabel returns a human readable name for a payment method
+func PaymentMethodLabel(method string) string {
+	switch method {
+	case MethodTelebirr:
+		return "telebirr"
+	case MethodCBEBirr:
+		return "CBE Birr"
+	case MethodMpesa:
+		return "M-Pesa"
+	case MethodEbirr:
+		return "ebirr"
+	case MethodCard:
+		return "Card"
+	case MethodTest:
+		return "Test Mode"
+	case "", MethodUnknown:
+		return "Unknown"
+	default:
+		return method
+	}
+}
+
 // GetSecretKey returns the secret key (for webhook verification)
 func GetSecretKey() string {
 	if ChapaClient == nil {
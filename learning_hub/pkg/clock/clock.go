@@ -0,0 +1,75 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. Handlers use the package-level Now so tests can swap the clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+var (
+	mu      sync.RWMutex
+	current Clock = systemClock{}
+)
+
+// Now returns the current time of the active clock
+func Now() time.Time {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current.Now()
+}
+
+// Since returns the time elapsed since t according to the active clock
+func Since(t time.Time) time.Duration {
+	return Now().Sub(t)
+}
+
+// Set replaces the active clock
+func Set(c Clock) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = c
+}
+
+// Reset restores the system clock
+func Reset() {
+	Set(systemClock{})
+}
+
+// Manual is a clock that only moves when told to, for deterministic tests and fixtures
+type Manual struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManual creates a manual clock starting at t
+func NewManual(t time.Time) *Manual {
+	return &Manual{now: t}
+}
+
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// SetTime moves the clock to t
+func (m *Manual) SetTime(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = t
+}
+
+// Advance moves the clock forward by d
+func (m *Manual) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
@@ -2,9 +2,11 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -19,10 +21,66 @@ type Config struct {
 	DBName     string
 	DBSSLMode  string
 
+	// Longest a database statement may run before it is cancelled (0 disables the limit)
+	DBQueryTimeout time.Duration
+
 	// Server
 	ServerPort string
 	ServerEnv  string
 
+	// HTTP server limits: reading the headers, reading a whole request (uploads included), writing a
+	// response, keeping an idle connection open, and the size of the headers. Streams of server-sent
+	// events are exempt from the write timeout.
+	ServerReadHeaderTimeout time.Duration
+	ServerReadTimeout       time.Duration
+	ServerWriteTimeout      time.Duration
+	ServerIdleTimeout       time.Duration
+	ServerMaxHeaderBytes    int
+	// How long requests and background jobs in progress get to finish once SIGTERM or SIGINT arrives
+	ShutdownTimeout time.Duration
+
+	// Logging: LOG_LEVEL is debug, info, warn or error; LOG_FORMAT is json or text (json in production)
+	LogLevel  string
+	LogFormat string
+
+	// Metrics: GET /metrics asks for this bearer token when set
+	MetricsToken string
+
+	// Tracing: spans are sent to the OTLP/HTTP collector at OTEL_EXPORTER_OTLP_ENDPOINT (e.g.
+	// http://localhost:4318), keeping OTEL_TRACES_SAMPLER_ARG of the traces; no endpoint turns it off
+	OTelEndpoint    string
+	OTelServiceName string
+	OTelSampleRatio float64
+
+	// Cache of hot reads such as the course catalogue: Redis at REDIS_URL (redis://host:6379/0), shared
+	// by the instances, or the memory of the process, up to CACHE_MEMORY_ENTRIES, without it. The TTLs
+	// bound how long a cached read lives; writes invalidate it earlier. A TTL of 0 turns its cache off.
+	RedisURL           string
+	CacheMemoryEntries int
+	CacheCourseListTTL time.Duration
+	CacheCourseTTL     time.Duration
+
+	// CORS: the browser origins that may call the API ("https://app.example.com", "https://*.example.com",
+	// or "*" for any, which can't send credentials), request headers allowed besides those the API
+	// reads, and how long browsers may cache a preflight. Without CORS_ALLOWED_ORIGINS, FRONTEND_URL is
+	// allowed, and in development the usual local dev servers too.
+	CORSAllowedOrigins   []string
+	CORSAllowedHeaders   []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+
+	// Largest request body most routes accept; upload and import routes take their file's limit instead
+	MaxRequestBodySize int64
+
+	// Security headers: how long browsers keep to HTTPS once they saw the API over it (0 sends no
+	// Strict-Transport-Security, for APIs also reached over plain HTTP), and the Content-Security-Policy
+	// of the uploaded files served under /uploads
+	HSTSMaxAge      time.Duration
+	FilesContentCSP string
+
+	// Test mode: deterministic clock/IDs and the /api/test fixture routes, never in production
+	TestMode bool
+
 	// JWT
 	JWTSecret string
 	JWTExpiry time.Duration
@@ -32,16 +90,110 @@ type Config struct {
 	MaxVideoSize    int64
 	MaxDocumentSize int64
 
+	// Uploads unused for this long are deleted by the orphan cleanup job
+	UploadOrphanGracePeriod time.Duration
+
+	// Total bytes each role may keep uploaded (0 means unlimited); admins can override per user
+	StudentUploadQuota    int64
+	InstructorUploadQuota int64
+
+	// Virus scanning of documents and assignment submissions with a clamd daemon (empty address disables)
+	ClamAVAddress     string
+	ClamAVTimeout     time.Duration
+	VirusScanFailOpen bool // accept files when the scanner is unreachable
+
+	// Signed lesson media links (secret defaults to JWT_SECRET)
+	MediaURLSecret string
+	MediaURLExpiry time.Duration
+
+	// Lesson material downloads allowed per user per hour (0 disables throttling)
+	DocumentDownloadLimit int
+
+	// Reviews and other posts allowed per user per hour (0 disables the limit)
+	PostingLimitPerHour int
+
+	// Failed password logins in a row that lock an account, and for how long (0 failures disables lockout)
+	LoginMaxFailures     int
+	LoginLockoutDuration time.Duration
+
+	// Request header holding the visitor's country, set by the CDN or proxy (empty disables IP lookup)
+	CountryHeader string
+
+	// File storage: "local" (uploads/ directory) or "s3" (any S3-compatible service such as MinIO)
+	StorageBackend  string
+	S3Endpoint      string
+	S3Region        string
+	S3Bucket        string
+	S3AccessKey     string
+	S3SecretKey     string
+	S3PathStyle     bool
+	S3PresignExpiry time.Duration
+	S3ServeMode     string // "redirect" to presigned URLs or "proxy" through the API
+
+	// Video transcoding to HLS
+	FFmpegPath           string
+	FFprobePath          string
+	TranscodeRenditions  string // comma-separated subset of 360p,480p,720p,1080p
+	TranscodeMaxAttempts int
+
+	// Sandboxed code execution for playground lessons and coding questions (empty URL disables)
+	SandboxURL        string
+	SandboxRunTimeout time.Duration
+
+	// YouTube Data API key for importing playlists as courses (empty disables)
+	YouTubeAPIKey string
+
+	// Live class meetings: "jitsi" (self-hosted or meet.jit.si), "zoom" (Server-to-Server OAuth app), or empty to disable
+	MeetingProvider  string
+	JitsiURL         string
+	ZoomAccountID    string
+	ZoomClientID     string
+	ZoomClientSecret string
+
 	// Stripe
 	StripeSecretKey      string
 	StripeWebhookSecret  string
 	StripePublishableKey string
 
+	// Exchange rates for showing prices in other currencies: the rates API ({base} is replaced by the
+	// currency converted from) and how long fetched rates are used
+	FXRatesURL string
+	FXCacheTTL time.Duration
+
 	// Chapa Payment Integration
 	ChapaSecretKey     string
 	ChapaWebhookSecret string
+	ChapaTimeout       time.Duration // per call to the Chapa API
 	AppBaseURL         string
 
+	// Pending payments older than this are re-verified with their provider by the reconciliation job
+	PaymentReconcileAfter time.Duration
+
+	// Web app links in emails point here (login, password reset)
+	FrontendURL string
+
+	// Social login over OpenID Connect; a provider is offered when its client ID is set
+	GoogleClientID        string
+	GoogleClientSecret    string
+	MicrosoftClientID     string
+	MicrosoftClientSecret string
+	MicrosoftTenant       string // "common" for work, school and personal accounts, or a tenant ID
+
+	// Admin notification emails go here
+	AdminEmail string
+
+	// Seeding: the admin account created by `seed` (the email defaults to ADMIN_EMAIL), and whether a
+	// development server seeds demo data on every start
+	SeedAdminEmail    string
+	SeedAdminPassword string
+	SeedOnStartup     bool
+
+	// Receipts
+	ReceiptLocale string
+
+	// Revenue share kept by the platform, in percent of each sale
+	PlatformSharePercent float64
+
 	// Firebase
 	FirebaseCredentialsPath string
 	FirebaseBucketName      string
@@ -51,13 +203,14 @@ type Config struct {
 	SMTPPort     int
 	SMTPUsername string
 	SMTPPassword string
+	SMTPTimeout  time.Duration // to connect and send one email
 }
 
 func LoadConfig() (*Config, error) {
 	// Load .env file
 	err := godotenv.Load()
 	if err != nil {
-		log.Println("Warning: .env file not found, using environment variables")
+		slog.Warn(".env file not found, using environment variables")
 	}
 
 	config := &Config{
@@ -69,9 +222,42 @@ func LoadConfig() (*Config, error) {
 		DBName:     getEnv("DB_NAME", "learning_hub"),
 		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
 
+		DBQueryTimeout: parseDuration(getEnv("DB_QUERY_TIMEOUT", "15s")),
+
 		// Server Configuration
 		ServerPort: getEnv("SERVER_PORT", "8080"),
 		ServerEnv:  getEnv("SERVER_ENV", "development"),
+		TestMode:   getEnv("APP_TEST_MODE", "false") == "true",
+		LogLevel:   getEnv("LOG_LEVEL", "info"),
+		LogFormat:  os.Getenv("LOG_FORMAT"),
+
+		ServerReadHeaderTimeout: parseDuration(getEnv("SERVER_READ_HEADER_TIMEOUT", "10s")),
+		ServerReadTimeout:       parseDuration(getEnv("SERVER_READ_TIMEOUT", "10m")),
+		ServerWriteTimeout:      parseDuration(getEnv("SERVER_WRITE_TIMEOUT", "10m")),
+		ServerIdleTimeout:       parseDuration(getEnv("SERVER_IDLE_TIMEOUT", "2m")),
+		ServerMaxHeaderBytes:    parseInt(getEnv("SERVER_MAX_HEADER_BYTES", "65536")),
+		ShutdownTimeout:         parseDuration(getEnv("SHUTDOWN_TIMEOUT", "30s")),
+
+		MetricsToken: os.Getenv("METRICS_TOKEN"),
+
+		OTelEndpoint:    os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		OTelServiceName: getEnv("OTEL_SERVICE_NAME", "learninghub"),
+		OTelSampleRatio: parseFloat(getEnv("OTEL_TRACES_SAMPLER_ARG", "1")),
+
+		RedisURL:           os.Getenv("REDIS_URL"),
+		CacheMemoryEntries: parseInt(getEnv("CACHE_MEMORY_ENTRIES", "10000")),
+		CacheCourseListTTL: parseDuration(getEnv("CACHE_COURSE_LIST_TTL", "1m")),
+		CacheCourseTTL:     parseDuration(getEnv("CACHE_COURSE_TTL", "5m")),
+
+		CORSAllowedOrigins:   parseList(os.Getenv("CORS_ALLOWED_ORIGINS")),
+		CORSAllowedHeaders:   parseList(os.Getenv("CORS_ALLOWED_HEADERS")),
+		CORSAllowCredentials: getEnv("CORS_ALLOW_CREDENTIALS", "true") == "true",
+		CORSMaxAge:           parseDuration(getEnv("CORS_MAX_AGE", "12h")),
+
+		MaxRequestBodySize: parseInt64(getEnv("MAX_REQUEST_BODY_SIZE", "2097152")), // 2MB
+		HSTSMaxAge:         parseDuration(getEnv("HSTS_MAX_AGE", "0")),
+		FilesContentCSP: getEnv("FILES_CONTENT_SECURITY_POLICY",
+			"default-src 'none'; img-src 'self' data:; media-src 'self' blob:; style-src 'unsafe-inline'"),
 
 		// JWT Configuration
 		JWTSecret: getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production"),
@@ -82,16 +268,89 @@ func LoadConfig() (*Config, error) {
 		MaxVideoSize:    parseInt64(getEnv("MAX_VIDEO_SIZE", "104857600")),
 		MaxDocumentSize: parseInt64(getEnv("MAX_DOCUMENT_SIZE", "5242880")),
 
+		UploadOrphanGracePeriod: parseDuration(getEnv("UPLOAD_ORPHAN_GRACE_PERIOD", "24h")),
+
+		StudentUploadQuota:    parseInt64(getEnv("STUDENT_UPLOAD_QUOTA", "104857600")),     // 100MB
+		InstructorUploadQuota: parseInt64(getEnv("INSTRUCTOR_UPLOAD_QUOTA", "5368709120")), // 5GB
+
+		ClamAVAddress:     getEnv("CLAMAV_ADDRESS", ""),
+		ClamAVTimeout:     parseDuration(getEnv("CLAMAV_TIMEOUT", "30s")),
+		VirusScanFailOpen: getEnv("VIRUS_SCAN_FAIL_OPEN", "false") == "true",
+
+		MediaURLSecret: getEnv("MEDIA_URL_SECRET", ""),
+		MediaURLExpiry: parseDuration(getEnv("MEDIA_URL_EXPIRY", "2h")),
+
+		DocumentDownloadLimit: parseInt(getEnv("DOCUMENT_DOWNLOAD_LIMIT", "50")),
+		PostingLimitPerHour:   parseInt(getEnv("POSTING_LIMIT_PER_HOUR", "10")),
+		LoginMaxFailures:      parseInt(getEnv("LOGIN_MAX_FAILURES", "5")),
+		LoginLockoutDuration:  parseDuration(getEnv("LOGIN_LOCKOUT_DURATION", "15m")),
+		CountryHeader:         getEnv("COUNTRY_HEADER", "CF-IPCountry"),
+
+		// Storage Configuration
+		StorageBackend:  getEnv("STORAGE_BACKEND", "local"),
+		S3Endpoint:      getEnv("S3_ENDPOINT", ""),
+		S3Region:        getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:        getEnv("S3_BUCKET", ""),
+		S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:     getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:     getEnv("S3_PATH_STYLE", "true") == "true",
+		S3PresignExpiry: parseDuration(getEnv("S3_PRESIGN_EXPIRY", "15m")),
+		S3ServeMode:     getEnv("S3_SERVE_MODE", "redirect"),
+
+		// Transcoding Configuration
+		FFmpegPath:           getEnv("FFMPEG_PATH", "ffmpeg"),
+		FFprobePath:          getEnv("FFPROBE_PATH", "ffprobe"),
+		TranscodeRenditions:  getEnv("TRANSCODE_RENDITIONS", "360p,480p,720p,1080p"),
+		TranscodeMaxAttempts: parseInt(getEnv("TRANSCODE_MAX_ATTEMPTS", "3")),
+
+		// Code Execution Configuration
+		SandboxURL:        getEnv("SANDBOX_URL", ""),
+		SandboxRunTimeout: parseDuration(getEnv("SANDBOX_RUN_TIMEOUT", "3s")),
+
+		YouTubeAPIKey: getEnv("YOUTUBE_API_KEY", ""),
+
+		// Live Class Configuration
+		MeetingProvider:  getEnv("MEETING_PROVIDER", ""),
+		JitsiURL:         getEnv("JITSI_URL", "https://meet.jit.si"),
+		ZoomAccountID:    getEnv("ZOOM_ACCOUNT_ID", ""),
+		ZoomClientID:     getEnv("ZOOM_CLIENT_ID", ""),
+		ZoomClientSecret: getEnv("ZOOM_CLIENT_SECRET", ""),
+
 		// Stripe Configuration
 		StripeSecretKey:      getEnv("STRIPE_SECRET_KEY", ""),
 		StripeWebhookSecret:  getEnv("STRIPE_WEBHOOK_SECRET", ""),
 		StripePublishableKey: getEnv("STRIPE_PUBLISHABLE_KEY", ""),
 
+		FXRatesURL: getEnv("FX_RATES_URL", "https://open.er-api.com/v6/latest/{base}"),
+		FXCacheTTL: parseDuration(getEnv("FX_CACHE_TTL", "6h")),
+
 		// Chapa Configuration
 		ChapaSecretKey:     getEnv("CHAPA_SECRET_KEY", ""),
 		ChapaWebhookSecret: getEnv("CHAPA_WEBHOOK_SECRET", ""),
+		ChapaTimeout:       parseDuration(getEnv("CHAPA_TIMEOUT", "15s")),
 		AppBaseURL:         getEnv("APP_BASE_URL", "http://localhost:8080"),
 
+		PaymentReconcileAfter: parseDuration(getEnv("PAYMENT_RECONCILE_AFTER", "15m")),
+
+		FrontendURL: getEnv("FRONTEND_URL", "http://localhost:5173"),
+		AdminEmail:  getEnv("ADMIN_EMAIL", "admin@learnhub.com"),
+
+		SeedAdminPassword: os.Getenv("SEED_ADMIN_PASSWORD"),
+		SeedOnStartup:     getEnv("SEED_ON_STARTUP", "false") == "true",
+
+		// Social Login Configuration
+		GoogleClientID:        getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:    getEnv("GOOGLE_CLIENT_SECRET", ""),
+		MicrosoftClientID:     getEnv("MICROSOFT_CLIENT_ID", ""),
+		MicrosoftClientSecret: getEnv("MICROSOFT_CLIENT_SECRET", ""),
+		MicrosoftTenant:       getEnv("MICROSOFT_TENANT", "common"),
+
+		// Receipt Configuration
+		ReceiptLocale: getEnv("RECEIPT_LOCALE", "en"),
+
+		// Revenue Configuration
+		PlatformSharePercent: parseFloat(getEnv("PLATFORM_SHARE_PERCENT", "20")),
+
 		// Firebase Configuration
 		FirebaseCredentialsPath: getEnv("FIREBASE_CREDENTIALS_PATH", ""),
 		FirebaseBucketName:      getEnv("FIREBASE_BUCKET_NAME", ""),
@@ -101,6 +360,14 @@ func LoadConfig() (*Config, error) {
 		SMTPPort:     parseInt(getEnv("SMTP_PORT", "587")),
 		SMTPUsername: getEnv("SMTP_USERNAME", ""),
 		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPTimeout:  parseDuration(getEnv("SMTP_TIMEOUT", "30s")),
+	}
+	config.SeedAdminEmail = getEnv("SEED_ADMIN_EMAIL", config.AdminEmail)
+	if len(config.CORSAllowedOrigins) == 0 {
+		config.CORSAllowedOrigins = []string{originOf(config.FrontendURL)}
+		if config.ServerEnv != "production" {
+			config.CORSAllowedOrigins = append(config.CORSAllowedOrigins, devServerOrigins...)
+		}
 	}
 
 	// Validate required fields
@@ -137,6 +404,14 @@ func (c *Config) GetPaymentProvider() string {
 	return "none"
 }
 
+// devServerOrigins are allowed by CORS outside production unless CORS_ALLOWED_ORIGINS is set: Vite,
+// Create React App and Next.js, and Vite's preview
+var devServerOrigins = []string{
+	"http://localhost:5173", "http://127.0.0.1:5173",
+	"http://localhost:3000", "http://127.0.0.1:3000",
+	"http://localhost:4173",
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -147,7 +422,7 @@ func getEnv(key, defaultValue string) string {
 func parseInt(s string) int {
 	value, err := strconv.Atoi(s)
 	if err != nil {
-		log.Printf("Warning: Invalid integer value for %s, using default: %v", s, err)
+		slog.Warn("Invalid integer setting, using the default", "value", s, "error", err)
 		// Return default value based on context
 		defaultValue := 0
 		if s == "587" {
@@ -163,7 +438,7 @@ func parseInt(s string) int {
 func parseInt64(s string) int64 {
 	value, err := strconv.ParseInt(s, 10, 64)
 	if err != nil {
-		log.Printf("Warning: Invalid int64 value for %s, using default: %v", s, err)
+		slog.Warn("Invalid int64 setting, using the default", "value", s, "error", err)
 		defaultValue := int64(0)
 		if s == "10485760" {
 			defaultValue = 10485760
@@ -177,10 +452,52 @@ func parseInt64(s string) int64 {
 	return value
 }
 
+func parseFloat(s string) float64 {
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		slog.Warn("Invalid float setting, using 0", "value", s, "error", err)
+		return 0
+	}
+	return value
+}
+
+// validateOrigin checks an allowed origin is a scheme and host, with at most one wildcard for subdomains
+func validateOrigin(origin string) error {
+	if strings.Count(origin, "*") > 1 {
+		return fmt.Errorf("%q: only one * is allowed", origin)
+	}
+	u, err := url.Parse(strings.Replace(origin, "*", "wildcard", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("%q is not an origin such as https://app.example.com", origin)
+	}
+	return nil
+}
+
+// originOf returns the scheme and host of a URL, which is what browsers send as the origin
+func originOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// parseList splits a comma-separated setting, dropping blank items
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func parseDuration(s string) time.Duration {
 	duration, err := time.ParseDuration(s)
 	if err != nil {
-		log.Printf("Warning: Invalid duration value for %s, using default 24h: %v", s, err)
+		slog.Warn("Invalid duration setting, using 24h", "value", s, "error", err)
 		return 24 * time.Hour
 	}
 	return duration
@@ -202,11 +519,70 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("DB_NAME is required")
 	}
 
+	if config.DBQueryTimeout < 0 {
+		return fmt.Errorf("DB_QUERY_TIMEOUT must not be negative")
+	}
+
 	// Validate server configuration
 	if config.ServerPort == "" {
 		return fmt.Errorf("SERVER_PORT is required")
 	}
 
+	if config.ServerReadHeaderTimeout < 0 || config.ServerReadTimeout < 0 || config.ServerWriteTimeout < 0 || config.ServerIdleTimeout < 0 {
+		return fmt.Errorf("SERVER_READ_HEADER_TIMEOUT, SERVER_READ_TIMEOUT, SERVER_WRITE_TIMEOUT and SERVER_IDLE_TIMEOUT must not be negative")
+	}
+	if config.ServerMaxHeaderBytes <= 0 {
+		return fmt.Errorf("SERVER_MAX_HEADER_BYTES must be greater than 0")
+	}
+	if config.ShutdownTimeout <= 0 {
+		return fmt.Errorf("SHUTDOWN_TIMEOUT must be greater than 0")
+	}
+
+	if config.CacheMemoryEntries <= 0 {
+		return fmt.Errorf("CACHE_MEMORY_ENTRIES must be greater than 0")
+	}
+	if config.CacheCourseListTTL < 0 || config.CacheCourseTTL < 0 {
+		return fmt.Errorf("CACHE_COURSE_LIST_TTL and CACHE_COURSE_TTL must not be negative")
+	}
+
+	for i, origin := range config.CORSAllowedOrigins {
+		// A trailing slash never matches the Origin header browsers send
+		origin = strings.TrimSuffix(origin, "/")
+		config.CORSAllowedOrigins[i] = origin
+		if origin == "*" {
+			if config.CORSAllowCredentials {
+				return fmt.Errorf("CORS_ALLOWED_ORIGINS=* needs CORS_ALLOW_CREDENTIALS=false, browsers refuse credentials for any origin")
+			}
+			if len(config.CORSAllowedOrigins) > 1 {
+				return fmt.Errorf("CORS_ALLOWED_ORIGINS=* can't be combined with other origins")
+			}
+			continue
+		}
+		if err := validateOrigin(origin); err != nil {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS: %w", err)
+		}
+	}
+	if config.CORSMaxAge < 0 {
+		return fmt.Errorf("CORS_MAX_AGE must not be negative")
+	}
+
+	if config.MaxRequestBodySize <= 0 {
+		return fmt.Errorf("MAX_REQUEST_BODY_SIZE must be greater than 0")
+	}
+	if config.HSTSMaxAge < 0 {
+		return fmt.Errorf("HSTS_MAX_AGE must not be negative")
+	}
+	if strings.Contains(config.FilesContentCSP, "frame-ancestors") {
+		return fmt.Errorf("FILES_CONTENT_SECURITY_POLICY must not set frame-ancestors, the allowed CORS origins are added")
+	}
+
+	if config.SeedOnStartup && config.ServerEnv == "production" {
+		return fmt.Errorf("SEED_ON_STARTUP cannot be enabled when SERVER_ENV=production")
+	}
+	if config.TestMode && config.ServerEnv == "production" {
+		return fmt.Errorf("APP_TEST_MODE cannot be enabled when SERVER_ENV=production")
+	}
+
 	// Validate JWT configuration
 	if config.JWTSecret == "" {
 		return fmt.Errorf("JWT_SECRET is required")
@@ -223,10 +599,76 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("MAX_DOCUMENT_SIZE must be greater than 0")
 	}
 
+	if config.UploadOrphanGracePeriod < time.Hour {
+		return fmt.Errorf("UPLOAD_ORPHAN_GRACE_PERIOD must be at least 1h")
+	}
+	if config.StudentUploadQuota < 0 || config.InstructorUploadQuota < 0 {
+		return fmt.Errorf("STUDENT_UPLOAD_QUOTA and INSTRUCTOR_UPLOAD_QUOTA must not be negative")
+	}
+	if config.PostingLimitPerHour < 0 {
+		return fmt.Errorf("POSTING_LIMIT_PER_HOUR must not be negative")
+	}
+	if config.LoginMaxFailures < 0 {
+		return fmt.Errorf("LOGIN_MAX_FAILURES must not be negative")
+	}
+	if config.LoginMaxFailures > 0 && config.LoginLockoutDuration < time.Minute {
+		return fmt.Errorf("LOGIN_LOCKOUT_DURATION must be at least 1m")
+	}
+
+	// Validate storage configuration
+	if config.StorageBackend == "s3" {
+		if config.S3Bucket == "" || config.S3AccessKey == "" || config.S3SecretKey == "" {
+			return fmt.Errorf("S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are required when STORAGE_BACKEND=s3")
+		}
+		if config.S3ServeMode != "redirect" && config.S3ServeMode != "proxy" {
+			return fmt.Errorf("S3_SERVE_MODE must be redirect or proxy")
+		}
+	}
+
+	if config.TranscodeMaxAttempts < 1 {
+		return fmt.Errorf("TRANSCODE_MAX_ATTEMPTS must be at least 1")
+	}
+
+	switch config.MeetingProvider {
+	case "", "jitsi":
+	case "zoom":
+		if config.ZoomAccountID == "" || config.ZoomClientID == "" || config.ZoomClientSecret == "" {
+			return fmt.Errorf("ZOOM_ACCOUNT_ID, ZOOM_CLIENT_ID and ZOOM_CLIENT_SECRET are required when MEETING_PROVIDER=zoom")
+		}
+	default:
+		return fmt.Errorf("MEETING_PROVIDER must be jitsi or zoom")
+	}
+
 	// Validate payment configuration
+	if config.PlatformSharePercent < 0 || config.PlatformSharePercent > 100 {
+		return fmt.Errorf("PLATFORM_SHARE_PERCENT must be between 0 and 100")
+	}
+	if config.ChapaTimeout <= 0 {
+		return fmt.Errorf("CHAPA_TIMEOUT must be greater than 0")
+	}
+	if config.PaymentReconcileAfter <= 0 {
+		return fmt.Errorf("PAYMENT_RECONCILE_AFTER must be greater than 0")
+	}
 	if config.IsChapaEnabled() && config.AppBaseURL == "" {
 		return fmt.Errorf("APP_BASE_URL is required when using Chapa payments")
 	}
+	if config.IsStripeEnabled() && config.StripeWebhookSecret == "" {
+		return fmt.Errorf("STRIPE_WEBHOOK_SECRET is required when STRIPE_SECRET_KEY is provided")
+	}
+	if config.FXCacheTTL <= 0 {
+		return fmt.Errorf("FX_CACHE_TTL must be greater than 0")
+	}
+
+	// Validate social login configuration
+	if config.GoogleClientID != "" && config.GoogleClientSecret == "" {
+		return fmt.Errorf("GOOGLE_CLIENT_SECRET is required when GOOGLE_CLIENT_ID is provided")
+	}
+	if config.MicrosoftClientID != "" && config.MicrosoftClientSecret == "" {
+		return fmt.Errorf("MICROSOFT_CLIENT_SECRET is required when MICROSOFT_CLIENT_ID is provided")
+	}
+	if (config.GoogleClientID != "" || config.MicrosoftClientID != "") && config.AppBaseURL == "" {
+		return fmt.Errorf("APP_BASE_URL is required for social login")
+	}
 
 	// Validate SMTP configuration if credentials are provided
 	if config.SMTPUsername != "" && config.SMTPPassword == "" {
@@ -245,6 +687,9 @@ func validateConfig(config *Config) error {
 		if config.SMTPPort == 0 {
 			return fmt.Errorf("SMTP_PORT is required when SMTP_HOST is provided")
 		}
+		if config.SMTPTimeout <= 0 {
+			return fmt.Errorf("SMTP_TIMEOUT must be greater than 0")
+		}
 	}
 
 	return nil
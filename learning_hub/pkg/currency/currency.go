@@ -0,0 +1,102 @@
+package currency
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultLocale is used when no supported locale can be determined
+const DefaultLocale = "en"
+
+// numberFormat describes how a locale writes amounts
+type numberFormat struct {
+	Thousands   string
+	Decimal     string
+	SymbolAfter bool
+}
+
+// Supported receipt locales
+var locales = map[string]numberFormat{
+	"en": {Thousands: ",", Decimal: ".", SymbolAfter: false},
+	"am": {Thousands: ",", Decimal: ".", SymbolAfter: true},
+	"fr": {Thousands: " ", Decimal: ",", SymbolAfter: true},
+	"de": {Thousands: ".", Decimal: ",", SymbolAfter: true},
+}
+
+// Currency symbols per locale, falling back to the ISO code
+var symbols = map[string]map[string]string{
+	"ETB": {"en": "ETB", "am": "ብር"},
+	"USD": {"en": "$"},
+	"EUR": {"en": "€"},
+}
+
+// NormalizeLocale reduces an Accept-Language header or locale tag (e.g. "am-ET,en;q=0.8")
+// to a supported base language, falling back to DefaultLocale
+func NormalizeLocale(tag string) string {
+	for _, part := range strings.Split(tag, ",") {
+		lang := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		lang = strings.ToLower(strings.SplitN(strings.ReplaceAll(lang, "_", "-"), "-", 2)[0])
+		if _, ok := locales[lang]; ok {
+			return lang
+		}
+	}
+	return DefaultLocale
+}
+
+// Symbol returns the display symbol of a currency for a locale
+func Symbol(currencyCode, locale string) string {
+	code := strings.ToUpper(currencyCode)
+	if bySymbol, ok := symbols[code]; ok {
+		if s, ok := bySymbol[locale]; ok {
+			return s
+		}
+		if s, ok := bySymbol[DefaultLocale]; ok {
+			return s
+		}
+	}
+	return code
+}
+
+// FormatNumber formats a number with two decimals using the locale's separators
+func FormatNumber(amount float64, locale string) string {
+	format, ok := locales[locale]
+	if !ok {
+		format = locales[DefaultLocale]
+	}
+
+	negative := amount < 0
+	cents := int64(math.Round(math.Abs(amount) * 100))
+	whole := fmt.Sprintf("%d", cents/100)
+
+	// Group the integer part in threes
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(format.Thousands)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	result := fmt.Sprintf("%s%s%02d", grouped.String(), format.Decimal, cents%100)
+	if negative {
+		result = "-" + result
+	}
+	return result
+}
+
+// FormatAmount formats an amount with its currency symbol for the given locale,
+// e.g. "ETB 1,250.00" (en) or "1,250.00 ብር" (am)
+func FormatAmount(amount float64, currencyCode, locale string) string {
+	locale = NormalizeLocale(locale)
+	number := FormatNumber(amount, locale)
+	symbol := Symbol(currencyCode, locale)
+
+	if locales[locale].SymbolAfter {
+		return number + " " + symbol
+	}
+	if len([]rune(symbol)) == 1 {
+		return symbol + number
+	}
+	return symbol + " " + number
+}
@@ -0,0 +1,77 @@
+// Package dbcache is a GORM plugin that invalidates cached reads when a table they are read from is
+// written, whichever handler writes it.
+package dbcache
+
+import (
+	"context"
+	"learning_hub/pkg/cache"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// transactionGrace is how long after a write in a transaction its groups are invalidated again. The
+// first invalidation comes before the commit, and a read in between may cache the rows being replaced.
+const transactionGrace = 2 * time.Second
+
+// rawWrite finds the table written by a raw statement
+var rawWrite = regexp.MustCompile(`(?i)^\s*(?:update|insert\s+into|delete\s+from)\s+"?(\w+)"?`)
+
+// Plugin invalidates the cache groups read from each table
+type Plugin struct {
+	groups map[string][]string
+}
+
+// New returns the plugin for the cache groups by the table they are read from
+func New(groups map[string][]string) *Plugin {
+	return &Plugin{groups: groups}
+}
+
+func (p *Plugin) Name() string { return "dbcache" }
+
+// Initialize registers the callbacks after every kind of write
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().After("*").Register("dbcache:after_create", p.invalidate),
+		callbacks.Update().After("*").Register("dbcache:after_update", p.invalidate),
+		callbacks.Delete().After("*").Register("dbcache:after_delete", p.invalidate),
+		callbacks.Raw().After("*").Register("dbcache:after_raw", p.invalidate),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Plugin) invalidate(db *gorm.DB) {
+	if db.Error != nil || db.RowsAffected == 0 {
+		return
+	}
+	table := db.Statement.Table
+	if table == "" {
+		if match := rawWrite.FindStringSubmatch(db.Statement.SQL.String()); match != nil {
+			table = strings.ToLower(match[1])
+		}
+	}
+	groups := p.groups[table]
+	if len(groups) == 0 {
+		return
+	}
+
+	ctx := db.Statement.Context
+	for _, group := range groups {
+		cache.Invalidate(ctx, group)
+	}
+	if _, inTransaction := db.Statement.ConnPool.(gorm.TxCommitter); inTransaction {
+		ctx = context.WithoutCancel(ctx)
+		time.AfterFunc(transactionGrace, func() {
+			for _, group := range groups {
+				cache.Invalidate(ctx, group)
+			}
+		})
+	}
+}
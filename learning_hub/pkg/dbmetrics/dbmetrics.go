@@ -0,0 +1,103 @@
+// Package dbmetrics is a GORM plugin that times every database statement for the /metrics endpoint,
+// by kind of statement and table, counts the statements that failed and exposes the connections of
+// the pool.
+package dbmetrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time database statements took, by operation and table",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "table"})
+	queryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Database statements that failed, by operation and table (missing records are not failures)",
+	}, []string{"operation", "table"})
+)
+
+const startKey = "dbmetrics:start"
+
+// Plugin times the statements
+type Plugin struct{}
+
+func New() *Plugin { return &Plugin{} }
+
+func (p *Plugin) Name() string { return "dbmetrics" }
+
+// Initialize registers the callbacks around every kind of statement and the gauges of the pool
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("*").Register("dbmetrics:before_create", before),
+		callbacks.Create().After("*").Register("dbmetrics:after_create", after("create")),
+		callbacks.Query().Before("*").Register("dbmetrics:before_query", before),
+		callbacks.Query().After("*").Register("dbmetrics:after_query", after("select")),
+		callbacks.Update().Before("*").Register("dbmetrics:before_update", before),
+		callbacks.Update().After("*").Register("dbmetrics:after_update", after("update")),
+		callbacks.Delete().Before("*").Register("dbmetrics:before_delete", before),
+		callbacks.Delete().After("*").Register("dbmetrics:after_delete", after("delete")),
+		callbacks.Raw().Before("*").Register("dbmetrics:before_raw", before),
+		callbacks.Raw().After("*").Register("dbmetrics:after_raw", after("raw")),
+		// Row, Rows and Scan are timed until the statement is sent, not while their rows are read
+		callbacks.Row().Before("*").Register("dbmetrics:before_row", before),
+		callbacks.Row().After("*").Register("dbmetrics:after_row", after("row")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "db_connections_open",
+		Help: "Open connections of the database pool",
+	}, func() float64 {
+		return float64(sqlDB.Stats().OpenConnections)
+	})
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "db_connections_in_use",
+		Help: "Connections of the database pool running a statement",
+	}, func() float64 {
+		return float64(sqlDB.Stats().InUse)
+	})
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "db_connections_wait_seconds",
+		Help: "Total time requests waited for a connection of the pool",
+	}, func() float64 {
+		return sqlDB.Stats().WaitDuration.Seconds()
+	})
+	return nil
+}
+
+func before(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func after(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		start, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "none"
+		}
+		queryDuration.WithLabelValues(operation, table).Observe(time.Since(start.(time.Time)).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			queryErrors.WithLabelValues(operation, table).Inc()
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package dbtimeout is a GORM plugin that bounds how long a database statement may run, so slow
+// queries give up instead of holding a pooled connection. Statements whose context already has a
+// deadline keep it; others get the plugin's timeout on top of their context, which is the request's
+// context when handlers use db.WithContext, so they are also cancelled when the client goes away.
+package dbtimeout
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const cancelKey = "dbtimeout:cancel"
+
+// Plugin applies the timeout to every statement
+type Plugin struct {
+	Timeout time.Duration
+}
+
+// New returns the plugin; a zero timeout disables it
+func New(timeout time.Duration) *Plugin {
+	return &Plugin{Timeout: timeout}
+}
+
+func (p *Plugin) Name() string { return "dbtimeout" }
+
+// Initialize registers the callbacks around every kind of statement
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	if p.Timeout <= 0 {
+		return nil
+	}
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("*").Register("dbtimeout:before_create", p.before),
+		callbacks.Create().After("*").Register("dbtimeout:after_create", p.after),
+		callbacks.Query().Before("*").Register("dbtimeout:before_query", p.before),
+		callbacks.Query().After("*").Register("dbtimeout:after_query", p.after),
+		callbacks.Update().Before("*").Register("dbtimeout:before_update", p.before),
+		callbacks.Update().After("*").Register("dbtimeout:after_update", p.after),
+		callbacks.Delete().Before("*").Register("dbtimeout:before_delete", p.before),
+		callbacks.Delete().After("*").Register("dbtimeout:after_delete", p.after),
+		callbacks.Raw().Before("*").Register("dbtimeout:before_raw", p.before),
+		callbacks.Raw().After("*").Register("dbtimeout:after_raw", p.after),
+		// Rows are read after the callbacks return, so the context of Row, Rows and Scan is only
+		// released when it expires
+		callbacks.Row().Before("*").Register("dbtimeout:before_row", p.beforeRow),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withTimeout returns the statement's context bounded by the timeout, or nil when it already has a deadline
+func (p *Plugin) withTimeout(db *gorm.DB) (context.Context, context.CancelFunc) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return nil, nil
+	}
+	return context.WithTimeout(ctx, p.Timeout)
+}
+
+func (p *Plugin) before(db *gorm.DB) {
+	if ctx, cancel := p.withTimeout(db); ctx != nil {
+		db.Statement.Context = ctx
+		db.InstanceSet(cancelKey, cancel)
+	}
+}
+
+func (p *Plugin) after(db *gorm.DB) {
+	if cancel, ok := db.InstanceGet(cancelKey); ok {
+		cancel.(context.CancelFunc)()
+	}
+}
+
+func (p *Plugin) beforeRow(db *gorm.DB) {
+	if ctx, cancel := p.withTimeout(db); ctx != nil {
+		db.Statement.Context = ctx
+		time.AfterFunc(p.Timeout, cancel)
+	}
+}
@@ -0,0 +1,85 @@
+// Package dbtracing is a GORM plugin that records an OpenTelemetry span for each database statement run
+// with the context of a traced request, e.g. h.DB.WithContext(c.Request.Context()). Statements run
+// without one, such as those of background jobs, are not traced, so they don't each start a trace of
+// their own.
+package dbtracing
+
+import (
+	"errors"
+	"learning_hub/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "dbtracing:span"
+
+// Plugin traces the statements
+type Plugin struct{}
+
+func New() *Plugin { return &Plugin{} }
+
+func (p *Plugin) Name() string { return "dbtracing" }
+
+// Initialize registers the callbacks around every kind of statement
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("*").Register("dbtracing:before_create", before("INSERT")),
+		callbacks.Create().After("*").Register("dbtracing:after_create", after),
+		callbacks.Query().Before("*").Register("dbtracing:before_query", before("SELECT")),
+		callbacks.Query().After("*").Register("dbtracing:after_query", after),
+		callbacks.Update().Before("*").Register("dbtracing:before_update", before("UPDATE")),
+		callbacks.Update().After("*").Register("dbtracing:after_update", after),
+		callbacks.Delete().Before("*").Register("dbtracing:before_delete", before("DELETE")),
+		callbacks.Delete().After("*").Register("dbtracing:after_delete", after),
+		callbacks.Raw().Before("*").Register("dbtracing:before_raw", before("RAW")),
+		callbacks.Raw().After("*").Register("dbtracing:after_raw", after),
+		callbacks.Row().Before("*").Register("dbtracing:before_row", before("ROW")),
+		callbacks.Row().After("*").Register("dbtracing:after_row", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanFromContext(ctx).IsRecording() {
+			return
+		}
+		name := operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		_, span := tracing.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemNamePostgreSQL,
+				semconv.DBOperationName(operation),
+				semconv.DBCollectionName(db.Statement.Table),
+			))
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// after ends the span with the statement, whose values are placeholders so no data is recorded
+func after(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.response.returned_rows", db.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		tracing.Fail(span, db.Error)
+	}
+	span.End()
+}
@@ -1,13 +1,38 @@
 package email
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/tls"
+	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"io/fs"
+	"learning_hub/pkg/clock"
 	"learning_hub/pkg/config"
-	"log"
+	"learning_hub/pkg/currency"
+	"learning_hub/pkg/i18n"
+	"learning_hub/pkg/settings"
+	"learning_hub/pkg/tracing"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/gomail.v2"
 )
 
@@ -19,11 +44,74 @@ var (
 	emailService *EmailService
 )
 
+var (
+	emailQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "email_queue_depth",
+		Help: "Emails being handed to the SMTP server",
+	})
+	emailsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "emails_total",
+		Help: "Emails handled, by result: sent, failed, timeout, unconfigured (SMTP not set up) or skipped (turned off by the recipient)",
+	}, []string{"result"})
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// templates holds every email, parsed together with the shared layout, by locale and file name without
+// extension
+var templates = mustParseTemplates()
+
+// Data is the template data of an email. Send adds AppBaseURL, FrontendURL, Year and Locale.
+type Data map[string]interface{}
+
 type EmailData struct {
-	To      string
-	Subject string
-	Body    string
-	Name    string
+	To             string
+	Subject        string
+	Body           string
+	Name           string
+	UnsubscribeURL string // sent as List-Unsubscribe when set
+	Attachments    []Attachment
+}
+
+// Attachment is a file sent along with an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// templateCategories are the notification categories of emails users can opt out of, see
+// models.NotificationCategories. Other emails (verification, password reset...) are always sent.
+var templateCategories = map[string]string{
+	"payment_success":         "payments",
+	"enrollment_notification": "course_updates",
+	"certificate":             "course_updates",
+	"certificate_expiring":    "course_updates",
+	"submission_receipt":      "grading",
+	"announcement":            "course_updates",
+	"message":                 "messages",
+	"wishlist":                "course_updates",
+	"live_session_reminder":   "course_updates",
+}
+
+// RecipientFilter reports whether the owner of an email address wants emails of a category, and
+// their user ID (0 when the address belongs to no user)
+type RecipientFilter func(ctx context.Context, to, category string) (userID uint, allowed bool)
+
+var recipientFilter RecipientFilter
+
+// SetRecipientFilter installs the check of users' notification preferences
+func SetRecipientFilter(filter RecipientFilter) {
+	recipientFilter = filter
+}
+
+var recipientLanguage func(ctx context.Context, to string) string
+
+// SetRecipientLanguage installs the lookup of the language the owner of an address reads emails in, ""
+// when unknown
+func SetRecipientLanguage(lookup func(ctx context.Context, to string) string) {
+	recipientLanguage = lookup
 }
 
 func Init(cfg *config.Config) {
@@ -31,27 +119,71 @@ func Init(cfg *config.Config) {
 
 	// Test email configuration
 	if cfg.SMTPHost != "" && cfg.SMTPUsername != "" {
-		log.Println("✅ Email service initialized with SMTP")
+		slog.Info("Email service initialized with SMTP")
 	} else {
-		log.Println("📧 Email service initialized in simulation mode")
+		slog.Info("Email service initialized in simulation mode")
+	}
+}
+
+// ErrNotConfigured is returned by Ping when no SMTP server is set up and emails are only logged
+var ErrNotConfigured = errors.New("SMTP is not configured")
+
+// Ping checks the SMTP server answers with its greeting, without logging in or sending anything
+func Ping(ctx context.Context) error {
+	if emailService == nil {
+		return fmt.Errorf("email service not initialized")
+	}
+	cfg := emailService.config
+	if cfg.SMTPHost == "" || cfg.SMTPUsername == "" || cfg.SMTPPassword == "" {
+		return ErrNotConfigured
+	}
+
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Like gomail, port 465 speaks TLS from the start; other ports greet in plain text
+	if cfg.SMTPPort == 465 {
+		conn = tls.Client(conn, &tls.Config{ServerName: cfg.SMTPHost, InsecureSkipVerify: cfg.ServerEnv == "development"})
+	}
+	client, err := smtp.NewClient(conn, cfg.SMTPHost)
+	if err != nil {
+		return err
 	}
+	return client.Quit()
 }
 
 // SendEmail sends an email using SMTP or simulates in development
 func SendEmail(data EmailData) error {
+	return sendEmail(context.Background(), data)
+}
+
+// sendEmail is SendEmail, traced under the span of ctx
+func sendEmail(ctx context.Context, data EmailData) (err error) {
 	if emailService == nil {
 		return fmt.Errorf("email service not initialized")
 	}
 
 	cfg := emailService.config
+	_, span := tracing.Tracer.Start(ctx, "smtp send", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("server.address", cfg.SMTPHost), attribute.Int("email.attachments", len(data.Attachments))))
+	defer func() {
+		tracing.Fail(span, err)
+		span.End()
+	}()
 
-	// Log email attempt
-	log.Printf("📧 Attempting to send email to: %s", data.To)
-	log.Printf("📧 Subject: %s", data.Subject)
+	slog.DebugContext(ctx, "Sending email", "to", data.To, "subject", data.Subject)
 
 	// Check if SMTP is configured
 	if cfg.SMTPHost == "" || cfg.SMTPUsername == "" || cfg.SMTPPassword == "" {
-		log.Printf("❌ SMTP not configured properly. Host: %s, Username: %s", cfg.SMTPHost, cfg.SMTPUsername)
+		slog.Warn("SMTP is not configured, email not sent", "to", data.To, "subject", data.Subject)
+		emailsTotal.WithLabelValues("unconfigured").Inc()
 		return fmt.Errorf("SMTP configuration is incomplete")
 	}
 
@@ -60,7 +192,21 @@ func SendEmail(data EmailData) error {
 	m.SetHeader("From", fmt.Sprintf("LearnHub <%s>", cfg.SMTPUsername))
 	m.SetHeader("To", data.To)
 	m.SetHeader("Subject", data.Subject)
+	if data.UnsubscribeURL != "" {
+		m.SetHeader("List-Unsubscribe", "<"+data.UnsubscribeURL+">")
+		m.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
 	m.SetBody("text/html", data.Body)
+	for _, attachment := range data.Attachments {
+		content := attachment.Content
+		m.Attach(attachment.Filename,
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(content)
+				return err
+			}),
+			gomail.SetHeader(map[string][]string{"Content-Type": {attachment.ContentType}}),
+		)
+	}
 
 	// Create dialer with proper configuration
 	d := gomail.NewDialer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword)
@@ -75,15 +221,29 @@ func SendEmail(data EmailData) error {
 		d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
 	}
 
-	log.Printf("🔧 Connecting to SMTP server: %s:%d", cfg.SMTPHost, cfg.SMTPPort)
-
-	// Send email
-	if err := d.DialAndSend(m); err != nil {
-		log.Printf("❌ Failed to send email to %s: %v", data.To, err)
-		return fmt.Errorf("failed to send email: %v", err)
+	// Send email; gomail only bounds the dial, so a stalled SMTP conversation is given up on here
+	// (the buffered channel lets the send finish in the background without leaking)
+	done := make(chan error, 1)
+	emailQueueDepth.Inc()
+	go func() {
+		done <- d.DialAndSend(m)
+		emailQueueDepth.Dec()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			slog.Error("Failed to send email", "to", data.To, "error", err)
+			emailsTotal.WithLabelValues("failed").Inc()
+			return fmt.Errorf("failed to send email: %v", err)
+		}
+	case <-time.After(cfg.SMTPTimeout):
+		slog.Error("Timed out sending email", "to", data.To, "timeout", cfg.SMTPTimeout)
+		emailsTotal.WithLabelValues("timeout").Inc()
+		return fmt.Errorf("failed to send email: timed out after %s", cfg.SMTPTimeout)
 	}
 
-	log.Printf("✅ Email sent successfully to: %s", data.To)
+	slog.Info("Email sent", "to", data.To, "subject", data.Subject)
+	emailsTotal.WithLabelValues("sent").Inc()
 	return nil
 }
 
@@ -115,600 +275,407 @@ func stripHTML(html string) string {
 	return strings.TrimSpace(result.String())
 }
 
+// mustParseTemplates parses each email template with templates/layout.html, once per locale.
+// Every email defines "subject", "style" (extra CSS) and "content"; a translation in templates/<locale>/
+// redefines "subject" and "content", and the layout translates its own text with the "t" function.
+func mustParseTemplates() map[string]map[string]*template.Template {
+	files, err := fs.Glob(templateFiles, "templates/*.html")
+	if err != nil {
+		panic(err)
+	}
+	parsed := map[string]map[string]*template.Template{}
+	for _, locale := range i18n.Supported() {
+		funcs := template.FuncMap{"t": func(message string, args ...interface{}) string {
+			return i18n.T(locale, message, args...)
+		}}
+		layout := template.Must(template.New("layout.html").Funcs(funcs).ParseFS(templateFiles, "templates/layout.html"))
+
+		parsed[locale] = make(map[string]*template.Template, len(files))
+		for _, file := range files {
+			name := strings.TrimSuffix(path.Base(file), ".html")
+			if name == "layout" {
+				continue
+			}
+			tmpl := template.Must(template.Must(layout.Clone()).ParseFS(templateFiles, file))
+			translation := path.Join("templates", locale, name+".html")
+			if _, err := fs.Stat(templateFiles, translation); err == nil {
+				tmpl = template.Must(tmpl.ParseFS(templateFiles, translation))
+			}
+			parsed[locale][name] = tmpl
+		}
+	}
+	return parsed
+}
+
+// baseURLs returns the API and frontend base URLs emails link to, without trailing slashes
+func baseURLs() (appBaseURL, frontendURL string) {
+	appBaseURL, frontendURL = "http://localhost:8080", "http://localhost:5173"
+	if emailService != nil {
+		if emailService.config.AppBaseURL != "" {
+			appBaseURL = emailService.config.AppBaseURL
+		}
+	}
+	if url := settings.FrontendURL(); url != "" {
+		frontendURL = url
+	}
+	return strings.TrimRight(appBaseURL, "/"), strings.TrimRight(frontendURL, "/")
+}
+
+// Render executes an email template in a locale (see i18n.Supported) and returns its subject and HTML body
+func Render(templateName, locale string, data Data) (string, string, error) {
+	if _, ok := templates[locale]; !ok {
+		locale = i18n.DefaultLocale
+	}
+	tmpl, ok := templates[locale][templateName]
+	if !ok {
+		return "", "", fmt.Errorf("unknown email template %q", templateName)
+	}
+
+	values := Data{}
+	for k, v := range data {
+		values[k] = v
+	}
+	values["AppBaseURL"], values["FrontendURL"] = baseURLs()
+	values["Year"] = clock.Now().Year()
+	values["Locale"] = locale
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.ExecuteTemplate(&body, "layout", values); err != nil {
+		return "", "", err
+	}
+	// The subject is plain text, so undo the HTML escaping of the template
+	return strings.TrimSpace(html.UnescapeString(subject.String())), body.String(), nil
+}
+
+// Send renders an email template (a file in templates/, without extension) in the recipient's language
+// and sends it, unless they turned off emails of its category. Those emails carry an unsubscribe link.
+func Send(templateName, to string, data Data) error {
+	return SendContext(context.Background(), templateName, to, data)
+}
+
+// SendWithAttachments is Send with files attached
+func SendWithAttachments(templateName, to string, data Data, attachments ...Attachment) error {
+	return SendContext(context.Background(), templateName, to, data, attachments...)
+}
+
+// SendContext is SendWithAttachments traced under the span of ctx. Emails are usually sent once the
+// request is answered, so pass context.WithoutCancel(c.Request.Context()).
+func SendContext(ctx context.Context, templateName, to string, data Data, attachments ...Attachment) error {
+	ctx, span := tracing.Tracer.Start(ctx, "email "+templateName, trace.WithAttributes(attribute.String("email.template", templateName)))
+	defer span.End()
+
+	var unsubscribeURL string
+	if category, ok := templateCategories[templateName]; ok && recipientFilter != nil {
+		userID, allowed := recipientFilter(ctx, to, category)
+		if !allowed {
+			slog.Debug("Email skipped, its category is turned off", "template", templateName, "to", to, "category", category)
+			emailsTotal.WithLabelValues("skipped").Inc()
+			return nil
+		}
+		if userID != 0 {
+			unsubscribeURL = UnsubscribeURL(userID, category)
+			withLink := Data{"UnsubscribeURL": unsubscribeURL}
+			for k, v := range data {
+				withLink[k] = v
+			}
+			data = withLink
+		}
+	}
+
+	locale := i18n.DefaultLocale
+	if recipientLanguage != nil {
+		if preferred, ok := i18n.Normalize(recipientLanguage(ctx, to)); ok {
+			locale = preferred
+		}
+	}
+	subject, body, err := Render(templateName, locale, data)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("failed to render %s email: %v", templateName, err)
+	}
+
+	name, _ := data["Name"].(string)
+	return sendEmail(ctx, EmailData{
+		To:             to,
+		Subject:        subject,
+		Body:           body,
+		Name:           name,
+		UnsubscribeURL: unsubscribeURL,
+		Attachments:    attachments,
+	})
+}
+
+// UnsubscribeToken signs an unsubscribe link of a user for a category
+func UnsubscribeToken(userID uint, category string) string {
+	secret := ""
+	if emailService != nil {
+		secret = emailService.config.JWTSecret
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "unsubscribe:%d:%s", userID, category)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidUnsubscribeToken checks the signature of an unsubscribe link
+func ValidUnsubscribeToken(userID uint, category, token string) bool {
+	return hmac.Equal([]byte(token), []byte(UnsubscribeToken(userID, category)))
+}
+
+// UnsubscribeURL returns the link that turns off a user's emails of a category without signing in
+func UnsubscribeURL(userID uint, category string) string {
+	appBaseURL, _ := baseURLs()
+	query := url.Values{
+		"user":     {strconv.FormatUint(uint64(userID), 10)},
+		"category": {category},
+		"token":    {UnsubscribeToken(userID, category)},
+	}
+	return appBaseURL + "/api/unsubscribe?" + query.Encode()
+}
+
 // SendWelcomeEmail sends welcome email to new users
 func SendWelcomeEmail(to, name string) error {
-	subject := "🎉 Welcome to LearnHub!"
-	body := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
-				.container { max-width: 600px; margin: 0 auto; background: #ffffff; }
-				.header { background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%); color: white; padding: 40px 20px; text-align: center; }
-				.content { padding: 30px; background: #f8fafc; }
-				.footer { padding: 20px; text-align: center; color: #64748b; font-size: 14px; background: #1e293b; color: white; }
-				.button { display: inline-block; padding: 12px 30px; background: #10b981; color: white; text-decoration: none; border-radius: 5px; margin: 20px 0; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<div class="header">
-					<h1>Welcome to LearnHub! 🎓</h1>
-					<p>Your learning journey begins now</p>
-				</div>
-				<div class="content">
-					<h2>Hello %s,</h2>
-					<p>We're thrilled to welcome you to LearnHub - your gateway to knowledge and skill development!</p>
-					
-					<p><strong>What you can do now:</strong></p>
-					<ul>
-						<li>📚 Browse our extensive course catalog</li>
-						<li>🎯 Enroll in courses that match your interests</li>
-						<li>📈 Track your learning progress</li>
-						<li>🏆 Earn certificates upon completion</li>
-					</ul>
-					
-					<p>Ready to start learning?</p>
-					<center>
-						<a href="http://localhost:8080/api/courses" class="button">Explore Courses</a>
-					</center>
-					
-					<p>If you have any questions, feel free to reply to this email.</p>
-					
-					<p>Happy learning!<br><strong>The LearnHub Team</strong></p>
-				</div>
-				<div class="footer">
-					<p>&copy; 2024 LearnHub. All rights reserved.</p>
-					<p>This is an automated message, please do not reply directly to this email.</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, name)
-
-	return SendEmail(EmailData{
-		To:      to,
-		Subject: subject,
-		Body:    body,
-		Name:    name,
-	})
+	return Send("welcome", to, Data{"Name": name})
 }
 
-// SendPaymentSuccessEmail sends payment confirmation email
-func SendPaymentSuccessEmail(to, name, courseTitle string, amount float64, transactionRef string) error {
-	subject := "✅ Payment Successful - Course Enrollment Confirmed"
-	body := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
-				.container { max-width: 600px; margin: 0 auto; background: #ffffff; }
-				.header { background: linear-gradient(135deg, #10b981 0%%, #059669 100%%); color: white; padding: 40px 20px; text-align: center; }
-				.content { padding: 30px; background: #f8fafc; }
-				.receipt { background: white; padding: 20px; border-radius: 10px; border-left: 4px solid #10b981; margin: 20px 0; }
-				.footer { padding: 20px; text-align: center; color: #64748b; font-size: 14px; background: #1e293b; color: white; }
-				.button { display: inline-block; padding: 12px 30px; background: #3b82f6; color: white; text-decoration: none; border-radius: 5px; margin: 20px 0; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<div class="header">
-					<h1>Payment Successful! 🎉</h1>
-					<p>You're now enrolled in your course</p>
-				</div>
-				<div class="content">
-					<h2>Hello %s,</h2>
-					<p>Your payment has been processed successfully and you now have full access to your course.</p>
-					
-					<div class="receipt">
-						<h3>📋 Payment Receipt</h3>
-						<p><strong>Course:</strong> %s</p>
-						<p><strong>Amount Paid:</strong> ETB %.2f</p>
-						<p><strong>Transaction ID:</strong> %s</p>
-						<p><strong>Status:</strong> <span style="color: #10b981;">Confirmed ✅</span></p>
-						<p><strong>Access:</strong> Immediate</p>
-					</div>
-					
-					<p>You can start learning right away! All course materials are now available to you.</p>
-					
-					<center>
-						<a href="http://localhost:8080/api/my-courses" class="button">Start Learning Now</a>
-					</center>
-					
-					<p>If you encounter any issues accessing your course, please contact our support team.</p>
-					
-					<p>Happy learning!<br><strong>The LearnHub Team</strong></p>
-				</div>
-				<div class="footer">
-					<p>&copy; 2024 LearnHub. All rights reserved.</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, name, courseTitle, amount, transactionRef)
-
-	return SendEmail(EmailData{
-		To:      to,
-		Subject: subject,
-		Body:    body,
-		Name:    name,
-	})
+// ReceiptLocale is the locale amounts in payment emails and their receipts are formatted for
+func ReceiptLocale() string {
+	if emailService != nil {
+		return currency.NormalizeLocale(emailService.config.ReceiptLocale)
+	}
+	return currency.DefaultLocale
+}
+
+// SendPaymentSuccessEmail sends payment confirmation email, with the PDF receipt attached when given
+func SendPaymentSuccessEmail(ctx context.Context, to, name, courseTitle string, amount float64, currencyCode, transactionRef, paymentMethod string, receiptPDF []byte) error {
+	locale := ReceiptLocale()
+
+	var attachments []Attachment
+	if receiptPDF != nil {
+		attachments = append(attachments, Attachment{
+			Filename:    "receipt-" + transactionRef + ".pdf",
+			ContentType: "application/pdf",
+			Content:     receiptPDF,
+		})
+	}
+	return SendContext(ctx, "payment_success", to, Data{
+		"Name":           name,
+		"CourseTitle":    courseTitle,
+		"Amount":         currency.FormatAmount(amount, currencyCode, locale),
+		"PaymentMethod":  paymentMethod,
+		"TransactionRef": transactionRef,
+		"HasReceipt":     receiptPDF != nil,
+	}, attachments...)
 }
 
 // SendEnrollmentNotification sends notification to instructor about new enrollment
-func SendEnrollmentNotification(to, instructorName, studentName, courseTitle string) error {
-	subject := "🎓 New Student Enrollment - " + courseTitle
-	body := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
-				.container { max-width: 600px; margin: 0 auto; background: #ffffff; }
-				.header { background: linear-gradient(135deg, #f59e0b 0%%, #d97706 100%%); color: white; padding: 40px 20px; text-align: center; }
-				.content { padding: 30px; background: #f8fafc; }
-				.enrollment-info { background: white; padding: 20px; border-radius: 10px; border-left: 4px solid #f59e0b; margin: 20px 0; }
-				.footer { padding: 20px; text-align: center; color: #64748b; font-size: 14px; background: #1e293b; color: white; }
-				.button { display: inline-block; padding: 12px 30px; background: #3b82f6; color: white; text-decoration: none; border-radius: 5px; margin: 20px 0; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<div class="header">
-					<h1>New Student Enrollment! 🎉</h1>
-					<p>Your course is making an impact</p>
-				</div>
-				<div class="content">
-					<h2>Hello %s,</h2>
-					<p>Great news! Another student has enrolled in your course and is excited to learn from you.</p>
-					
-					<div class="enrollment-info">
-						<h3>📈 Enrollment Details</h3>
-						<p><strong>Student:</strong> %s</p>
-						<p><strong>Course:</strong> %s</p>
-						<p><strong>Enrollment Date:</strong> %s</p>
-						<p><strong>Total Students:</strong> Growing! 📊</p>
-					</div>
-					
-					<p>Your expertise is helping shape the future of education. Keep up the amazing work!</p>
-					
-					<center>
-						<a href="http://localhost:8080/api/dashboard" class="button">View Course Dashboard</a>
-					</center>
-					
-					<p>Thank you for being an invaluable part of the LearnHub community.</p>
-					
-					<p>Best regards,<br><strong>The LearnHub Team</strong></p>
-				</div>
-				<div class="footer">
-					<p>&copy; 2024 LearnHub. All rights reserved.</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, instructorName, studentName, courseTitle, getCurrentDate())
-
-	return SendEmail(EmailData{
-		To:      to,
-		Subject: subject,
-		Body:    body,
-		Name:    instructorName,
+func SendEnrollmentNotification(ctx context.Context, to, instructorName, studentName, courseTitle string) error {
+	return SendContext(ctx, "enrollment_notification", to, Data{
+		"Name":        instructorName,
+		"StudentName": studentName,
+		"CourseTitle": courseTitle,
+		"Date":        getCurrentDate(),
 	})
 }
 
 // SendAdminNotification sends notification to admin for important events
 func SendAdminNotification(event, details string) error {
-	// This would send to admin email - you can configure this
-	adminEmail := "admin@learnhub.com" // You can make this configurable
-
-	subject := "🔔 Admin Notification: " + event
-	body := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
-				.container { max-width: 600px; margin: 0 auto; padding: 20px; }
-				.header { background: #ef4444; color: white; padding: 20px; text-align: center; }
-				.content { padding: 20px; background: #fef2f2; }
-				.info-box { background: white; padding: 15px; border-radius: 5px; margin: 15px 0; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<div class="header">
-					<h1>Admin Notification</h1>
-				</div>
-				<div class="content">
-					<h2>Event: %s</h2>
-					<div class="info-box">
-						<p><strong>Details:</strong> %s</p>
-						<p><strong>Time:</strong> %s</p>
-					</div>
-					<p>This is an automated notification from the LearnHub system.</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, event, details, getCurrentDate())
-
-	return SendEmail(EmailData{
-		To:      adminEmail,
-		Subject: subject,
-		Body:    body,
-		Name:    "Admin",
+	return Send("admin_notification", settings.AdminEmail(), Data{
+		"Name":    "Admin",
+		"Event":   event,
+		"Details": details,
+		"Date":    getCurrentDate(),
 	})
 }
 
-// Helper function to get current date
+// getCurrentDate returns the current time as shown in emails
 func getCurrentDate() string {
-	// This would be implemented to return current date
-	return "Just now"
+	return clock.Now().Format("January 2, 2006 at 3:04 PM")
 }
 
 // SendVerificationEmail sends email verification link
 func SendVerificationEmail(to, name, verificationToken string) error {
-	subject := "🔐 Verify Your LearnHub Account"
-	verificationURL := fmt.Sprintf("http://localhost:8080/api/verify-email?token=%s", verificationToken)
-
-	body := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
-				.container { max-width: 600px; margin: 0 auto; background: #ffffff; }
-				.header { background: linear-gradient(135deg, #6366f1 0%%, #4f46e5 100%%); color: white; padding: 40px 20px; text-align: center; }
-				.content { padding: 30px; background: #f8fafc; }
-				.verification-box { background: white; padding: 25px; border-radius: 10px; border: 2px dashed #e2e8f0; margin: 20px 0; text-align: center; }
-				.verification-button { display: inline-block; padding: 15px 30px; background: #10b981; color: white; text-decoration: none; border-radius: 8px; font-size: 16px; font-weight: bold; margin: 15px 0; }
-				.verification-code { background: #f1f5f9; padding: 15px; border-radius: 8px; font-family: monospace; font-size: 18px; color: #1e293b; margin: 15px 0; }
-				.footer { padding: 20px; text-align: center; color: #64748b; font-size: 14px; background: #1e293b; color: white; }
-				.note { background: #fffbeb; border-left: 4px solid #f59e0b; padding: 15px; margin: 15px 0; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<div class="header">
-					<h1>Verify Your Email Address</h1>
-					<p>One last step to activate your LearnHub account</p>
-				</div>
-				<div class="content">
-					<h2>Hello %s,</h2>
-					<p>Thank you for registering with LearnHub! To complete your registration and activate your account, please verify your email address.</p>
-					
-					<div class="verification-box">
-						<h3>📧 Email Verification Required</h3>
-						<p>Click the button below to verify your email address:</p>
-						
-						<center>
-							<a href="%s" class="verification-button">Verify Email Address</a>
-						</center>
-						
-						<p style="margin-top: 20px; color: #64748b; font-size: 14px;">
-							Or copy and paste this link in your browser:<br>
-							<span class="verification-code">%s</span>
-						</p>
-					</div>
-					
-					<div class="note">
-						<p><strong>⚠️ Important:</strong> This verification link will expire in 24 hours.</p>
-						<p>If you didn't create an account with LearnHub, please ignore this email.</p>
-					</div>
-					
-					<p>Once verified, you'll have full access to:</p>
-					<ul>
-						<li>📚 Browse and enroll in courses</li>
-						<li>🎯 Track your learning progress</li>
-						<li>🏆 Earn completion certificates</li>
-						<li>👥 Join our learning community</li>
-					</ul>
-					
-					<p>Happy learning!<br><strong>The LearnHub Team</strong></p>
-				</div>
-				<div class="footer">
-					<p>&copy; 2024 LearnHub. All rights reserved.</p>
-					<p>This is an automated message, please do not reply directly to this email.</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, name, verificationURL, verificationURL)
-
-	return SendEmail(EmailData{
-		To:      to,
-		Subject: subject,
-		Body:    body,
-		Name:    name,
+	appBaseURL, _ := baseURLs()
+	return Send("verification", to, Data{
+		"Name":            name,
+		"VerificationURL": appBaseURL + "/api/verify-email?token=" + url.QueryEscape(verificationToken),
+	})
+}
+
+// SendInvitationEmail invites the user of an account an admin created to set its password
+func SendInvitationEmail(to, name, invitedBy, role, token string) error {
+	_, frontendURL := baseURLs()
+	return Send("invitation", to, Data{
+		"Name":          name,
+		"InvitedBy":     invitedBy,
+		"Role":          role,
+		"InvitationURL": frontendURL + "/accept-invitation?token=" + url.QueryEscape(token),
+	})
+}
+
+// SendAccountDeletionEmail confirms to a user that their account will be deleted, unless they log in first
+func SendAccountDeletionEmail(to, name string, deletionDate time.Time) error {
+	return Send("account_deletion", to, Data{
+		"Name":         name,
+		"DeletionDate": deletionDate.UTC().Format("January 2, 2006"),
+	})
+}
+
+// SendEmailChangeConfirmEmail sends the link that confirms a new account address to that address
+func SendEmailChangeConfirmEmail(to, name, token string) error {
+	appBaseURL, _ := baseURLs()
+	return Send("email_change_confirm", to, Data{
+		"Name":       name,
+		"NewEmail":   to,
+		"ConfirmURL": appBaseURL + "/api/confirm-email-change?token=" + url.QueryEscape(token),
+	})
+}
+
+// SendEmailChangeNoticeEmail tells the old address of an account that a change of address was requested,
+// or made once changed is true
+func SendEmailChangeNoticeEmail(to, name, newEmail string, changed bool) error {
+	return Send("email_change_notice", to, Data{
+		"Name":     name,
+		"NewEmail": newEmail,
+		"Changed":  changed,
+		"Date":     getCurrentDate(),
 	})
 }
 
 // SendVerificationSuccessEmail sends confirmation after successful verification
 func SendVerificationSuccessEmail(to, name string) error {
-	subject := "✅ Email Verified Successfully!"
-	body := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
-				.container { max-width: 600px; margin: 0 auto; background: #ffffff; }
-				.header { background: linear-gradient(135deg, #10b981 0%%, #059669 100%%); color: white; padding: 40px 20px; text-align: center; }
-				.content { padding: 30px; background: #f8fafc; }
-				.success-box { background: white; padding: 25px; border-radius: 10px; border: 2px solid #10b981; margin: 20px 0; text-align: center; }
-				.button { display: inline-block; padding: 12px 30px; background: #3b82f6; color: white; text-decoration: none; border-radius: 5px; margin: 20px 0; }
-				.footer { padding: 20px; text-align: center; color: #64748b; font-size: 14px; background: #1e293b; color: white; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<div class="header">
-					<h1>Email Verified Successfully! 🎉</h1>
-					<p>Your LearnHub account is now active</p>
-				</div>
-				<div class="content">
-					<h2>Hello %s,</h2>
-					
-					<div class="success-box">
-						<h3 style="color: #10b981;">✅ Verification Complete</h3>
-						<p>Your email address has been successfully verified and your LearnHub account is now fully activated!</p>
-					</div>
-					
-					<p>You now have full access to all LearnHub features:</p>
-					<ul>
-						<li>🔐 Secure account access</li>
-						<li>📚 Full course catalog</li>
-						<li>💳 Course enrollment and payments</li>
-						<li>📊 Progress tracking</li>
-						<li>🏆 Achievement system</li>
-					</ul>
-					
-					<center>
-						<a href="http://localhost:8080/api/courses" class="button">Start Exploring Courses</a>
-					</center>
-					
-					<p style="margin-top: 30px;">If you have any questions or need assistance, don't hesitate to contact our support team.</p>
-					
-					<p>Welcome aboard!<br><strong>The LearnHub Team</strong></p>
-				</div>
-				<div class="footer">
-					<p>&copy; 2024 LearnHub. All rights reserved.</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, name)
-
-	return SendEmail(EmailData{
-		To:      to,
-		Subject: subject,
-		Body:    body,
-		Name:    name,
-	})
+	return Send("verification_success", to, Data{"Name": name})
 }
 
-// SendPasswordResetEmail sends password reset link
 // SendPasswordResetEmail sends password reset with verification code
 func SendPasswordResetEmail(to, name, verificationCode string) error {
-	subject := "🔐 Reset Your LearnHub Password - Verification Code"
-	// Remove the reset URL since we're using a code now
-	// resetURL := fmt.Sprintf("http://localhost:5173/reset-password?token=%s", resetToken)
-
-	body := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
-				.container { max-width: 600px; margin: 0 auto; background: #ffffff; }
-				.header { background: linear-gradient(135deg, #f59e0b 0%%, #d97706 100%%); color: white; padding: 40px 20px; text-align: center; }
-				.content { padding: 30px; background: #f8fafc; }
-				.code-box { background: white; padding: 25px; border-radius: 10px; border: 2px dashed #e2e8f0; margin: 20px 0; text-align: center; }
-				.verification-code { background: #1e293b; color: white; padding: 20px; border-radius: 10px; font-family: monospace; font-size: 32px; font-weight: bold; letter-spacing: 8px; margin: 20px 0; }
-				.footer { padding: 20px; text-align: center; color: #64748b; font-size: 14px; background: #1e293b; color: white; }
-				.note { background: #fffbeb; border-left: 4px solid #f59e0b; padding: 15px; margin: 15px 0; }
-				.warning { background: #fef2f2; border-left: 4px solid #ef4444; padding: 15px; margin: 15px 0; }
-				.instructions { background: #f0f9ff; border-left: 4px solid #3b82f6; padding: 15px; margin: 15px 0; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<div class="header">
-					<h1>Password Reset Request</h1>
-					<p>Secure your account with a new password</p>
-				</div>
-				<div class="content">
-					<h2>Hello %s,</h2>
-					<p>We received a request to reset your LearnHub account password.</p>
-					
-					<div class="instructions">
-						<h3>📝 How to Reset Your Password</h3>
-						<p>1. Go to the password reset page: <strong>http://localhost:5173/reset-password</strong></p>
-						<p>2. Enter the verification code below</p>
-						<p>3. Create your new password</p>
-					</div>
-					
-					<div class="code-box">
-						<h3>🔑 Your Verification Code</h3>
-						<p>Enter this code on the reset password page:</p>
-						
-						<div class="verification-code">%s</div>
-						
-						<p style="margin-top: 20px; color: #64748b; font-size: 14px;">
-							This code will expire in 1 hour for security reasons.
-						</p>
-					</div>
-					
-					<div class="note">
-						<p><strong>⏰ Important:</strong> This verification code will expire in 1 hour.</p>
-						<p>If you don't reset your password within this time, you'll need to request a new code.</p>
-					</div>
-					
-					<div class="warning">
-						<p><strong>⚠️ Security Notice:</strong> If you didn't request this password reset, please ignore this email and ensure your account is secure.</p>
-						<p>Your password will not be changed unless you enter this code and create a new password.</p>
-					</div>
-					
-					<p>Need help? Contact our support team for assistance.</p>
-					
-					<p>Stay secure,<br><strong>The LearnHub Team</strong></p>
-				</div>
-				<div class="footer">
-					<p>&copy; 2024 LearnHub. All rights reserved.</p>
-					<p>This is an automated message, please do not reply directly to this email.</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, name, verificationCode)
-
-	return SendEmail(EmailData{
-		To:      to,
-		Subject: subject,
-		Body:    body,
-		Name:    name,
-	})
+	return Send("password_reset", to, Data{"Name": name, "Code": verificationCode})
 }
 
 // SendPasswordResetSuccessEmail sends confirmation after successful password reset
 func SendPasswordResetSuccessEmail(to, name string) error {
-	subject := "✅ Password Reset Successful"
-	body := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
-				.container { max-width: 600px; margin: 0 auto; background: #ffffff; }
-				.header { background: linear-gradient(135deg, #10b981 0%%, #059669 100%%); color: white; padding: 40px 20px; text-align: center; }
-				.content { padding: 30px; background: #f8fafc; }
-				.success-box { background: white; padding: 25px; border-radius: 10px; border: 2px solid #10b981; margin: 20px 0; text-align: center; }
-				.security-tips { background: #f0f9ff; padding: 20px; border-radius: 8px; margin: 20px 0; }
-				.footer { padding: 20px; text-align: center; color: #64748b; font-size: 14px; background: #1e293b; color: white; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<div class="header">
-					<h1>Password Updated Successfully! 🔒</h1>
-					<p>Your account security has been enhanced</p>
-				</div>
-				<div class="content">
-					<h2>Hello %s,</h2>
-					
-					<div class="success-box">
-						<h3 style="color: #10b981;">✅ Password Reset Complete</h3>
-						<p>Your LearnHub password has been successfully updated.</p>
-						<p><strong>Time:</strong> %s</p>
-					</div>
-					
-					<div class="security-tips">
-						<h3>🔐 Security Tips</h3>
-						<ul>
-							<li>Use a strong, unique password</li>
-							<li>Don't reuse passwords across different sites</li>
-							<li>Enable two-factor authentication if available</li>
-							<li>Regularly update your passwords</li>
-						</ul>
-					</div>
-					
-					<p>If you made this change, you're all set! If you didn't, please contact our support team immediately.</p>
-					
-					<p>You can now login with your new password:</p>
-					<center>
-						<a href="http://localhost:5173/login" style="display: inline-block; padding: 12px 30px; background: #3b82f6; color: white; text-decoration: none; border-radius: 5px; margin: 20px 0;">Login to LearnHub</a>
-					</center>
-					
-					<p>Stay secure,<br><strong>The LearnHub Team</strong></p>
-				</div>
-				<div class="footer">
-					<p>&copy; 2024 LearnHub. All rights reserved.</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, name, time.Now().Format("January 2, 2006 at 3:04 PM"))
-
-	return SendEmail(EmailData{
-		To:      to,
-		Subject: subject,
-		Body:    body,
-		Name:    name,
+	return Send("password_reset_success", to, Data{"Name": name, "Date": getCurrentDate()})
+}
+
+// SendAccountLockedEmail warns a user that failed logins locked their account
+func SendAccountLockedEmail(to, name string, attempts int, ip string, lockedUntil time.Time) error {
+	return Send("account_locked", to, Data{
+		"Name":        name,
+		"Attempts":    attempts,
+		"IP":          ip,
+		"LockedUntil": lockedUntil.UTC().Format("January 2, 2006 at 15:04 UTC"),
+	})
+}
+
+// SendTwoFactorChangedEmail tells the user two-factor login was turned on, turned off or reset by an admin
+// (change is "turned on", "turned off" or "reset")
+func SendTwoFactorChangedEmail(to, name, change string, byAdmin bool) error {
+	title := map[string]string{"turned on": "Enabled", "turned off": "Disabled", "reset": "Reset"}[change]
+	return Send("two_factor_changed", to, Data{
+		"Name":      name,
+		"Change":    change,
+		"Title":     title,
+		"ByAdmin":   byAdmin,
+		"ChangedAt": clock.Now().UTC().Format("January 2, 2006 at 15:04 UTC"),
 	})
 }
 
 // SendCertificateEmail sends course completion certificate
-func SendCertificateEmail(to, name, courseTitle, certificateURL, verificationCode string) error {
-	subject := "🎓 Course Completed! Your LearnHub Certificate"
-
-	body := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<style>
-				body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; }
-				.container { max-width: 600px; margin: 0 auto; background: #ffffff; }
-				.header { background: linear-gradient(135deg, #8b5cf6 0%%, #7c3aed 100%%); color: white; padding: 40px 20px; text-align: center; }
-				.content { padding: 30px; background: #f8fafc; }
-				.certificate-box { background: white; padding: 25px; border-radius: 10px; border: 3px solid #e2e8f0; margin: 20px 0; text-align: center; }
-				.download-button { display: inline-block; padding: 15px 30px; background: #10b981; color: white; text-decoration: none; border-radius: 8px; font-size: 16px; font-weight: bold; margin: 15px 0; }
-				.verification { background: #f0f9ff; padding: 15px; border-radius: 8px; margin: 15px 0; }
-				.footer { padding: 20px; text-align: center; color: #64748b; font-size: 14px; background: #1e293b; color: white; }
-			</style>
-		</head>
-		<body>
-			<div class="container">
-				<div class="header">
-					<h1>Congratulations! 🎉</h1>
-					<p>You've successfully completed your course</p>
-				</div>
-				<div class="content">
-					<h2>Hello %s,</h2>
-					<p>We're thrilled to inform you that you've successfully completed the course:</p>
-					
-					<div class="certificate-box">
-						<h3 style="color: #8b5cf6;">🏆 Course Completion Certificate</h3>
-						<p><strong>Course:</strong> %s</p>
-						<p><strong>Completed on:</strong> %s</p>
-						<p><strong>Certificate ID:</strong> %s</p>
-						
-						<center>
-							<a href="%s" class="download-button">Download Certificate</a>
-						</center>
-					</div>
-					
-					<div class="verification">
-						<h4>🔍 Certificate Verification</h4>
-						<p>Share your achievement! Others can verify your certificate using:</p>
-						<p><strong>Verification Code:</strong> %s</p>
-						<p>Or visit: http://localhost:8080/api/verify-certificate?code=%s</p>
-					</div>
-					
-					<p>Your dedication and hard work have paid off. This certificate represents your commitment to learning and skill development.</p>
-					
-					<p>Share your achievement on LinkedIn and other professional networks!</p>
-					
-					<p>Ready for your next learning adventure?</p>
-					<center>
-						<a href="http://localhost:8080/api/courses" style="display: inline-block; padding: 12px 30px; background: #3b82f6; color: white; text-decoration: none; border-radius: 5px; margin: 20px 0;">Explore More Courses</a>
-					</center>
-					
-					<p>Congratulations once again!<br><strong>The LearnHub Team</strong></p>
-				</div>
-				<div class="footer">
-					<p>&copy; 2024 LearnHub. All rights reserved.</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, name, courseTitle, time.Now().Format("January 2, 2006"), "CERT-ID", certificateURL, verificationCode, verificationCode)
-
-	return SendEmail(EmailData{
-		To:      to,
-		Subject: subject,
-		Body:    body,
-		Name:    name,
+func SendCertificateEmail(to, name, courseTitle, certificateID, certificateURL, verificationCode string) error {
+	if strings.HasPrefix(certificateURL, "/") {
+		appBaseURL, _ := baseURLs()
+		certificateURL = appBaseURL + certificateURL
+	}
+
+	return Send("certificate", to, Data{
+		"Name":             name,
+		"CourseTitle":      courseTitle,
+		"Date":             clock.Now().Format("January 2, 2006"),
+		"CertificateID":    certificateID,
+		"CertificateURL":   certificateURL,
+		"VerificationCode": verificationCode,
+	})
+}
+
+// SendCertificateExpiringEmail tells a holder their certificate expires soon
+func SendCertificateExpiringEmail(to, name, courseTitle, certificateID string, expiryDate time.Time) error {
+	return Send("certificate_expiring", to, Data{
+		"Name":          name,
+		"CourseTitle":   courseTitle,
+		"CertificateID": certificateID,
+		"ExpiryDate":    expiryDate.Format("January 2, 2006"),
+	})
+}
+
+// SendCourseUnpublishEmail warns an instructor that their course leaves the catalog soon
+func SendCourseUnpublishEmail(to, name, courseTitle string, courseID uint, unpublishAt time.Time) error {
+	return Send("course_unpublish", to, Data{
+		"Name":        name,
+		"CourseTitle": courseTitle,
+		"CourseID":    courseID,
+		"UnpublishAt": unpublishAt.UTC().Format("January 2, 2006 at 15:04 UTC"),
+	})
+}
+
+// SendSubmissionReceiptEmail confirms an assignment submission with the integrity hashes of what was received
+func SendSubmissionReceiptEmail(to, name, courseTitle, assignmentTitle string, submissionID uint, submittedAt time.Time, fileName, fileHash, textHash string) error {
+	return Send("submission_receipt", to, Data{
+		"Name":            name,
+		"CourseTitle":     courseTitle,
+		"AssignmentTitle": assignmentTitle,
+		"SubmissionID":    submissionID,
+		"SubmittedAt":     submittedAt.UTC().Format("January 2, 2006 at 15:04:05 UTC"),
+		"FileName":        fileName,
+		"FileHash":        fileHash,
+		"TextHash":        textHash,
+	})
+}
+
+// SendAnnouncementEmail forwards a course announcement to an enrolled student
+func SendAnnouncementEmail(to, name, courseTitle string, courseID uint, authorName, title, message string) error {
+	return Send("announcement", to, Data{
+		"Name":        name,
+		"CourseTitle": courseTitle,
+		"CourseID":    courseID,
+		"AuthorName":  authorName,
+		"Title":       title,
+		"Message":     message,
+	})
+}
+
+// SendMessageEmail lets a user who is not online know about a new direct message
+func SendMessageEmail(to, name, senderName, courseTitle string, conversationID uint, message string) error {
+	return Send("message", to, Data{
+		"Name":           name,
+		"SenderName":     senderName,
+		"CourseTitle":    courseTitle,
+		"ConversationID": conversationID,
+		"Message":        message,
+	})
+}
+
+// SendWishlistEmail tells a user about news of a course on their wishlist
+func SendWishlistEmail(to, name, courseTitle string, courseID uint, headline, message string) error {
+	return Send("wishlist", to, Data{
+		"Name":        name,
+		"CourseTitle": courseTitle,
+		"CourseID":    courseID,
+		"Headline":    headline,
+		"Message":     message,
+	})
+}
+
+// SendLiveSessionReminderEmail reminds an enrolled student of a live class starting soon
+func SendLiveSessionReminderEmail(to, name, courseTitle string, courseID, sessionID uint, title string, startsAt time.Time, durationMinutes int) error {
+	return Send("live_session_reminder", to, Data{
+		"Name":        name,
+		"CourseTitle": courseTitle,
+		"CourseID":    courseID,
+		"SessionID":   sessionID,
+		"Title":       title,
+		"StartsAt":    startsAt.UTC().Format("Monday, January 2, 2006 at 15:04 UTC"),
+		"Duration":    durationMinutes,
 	})
 }
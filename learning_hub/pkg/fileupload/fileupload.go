@@ -1,10 +1,13 @@
 package fileupload
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"learning_hub/pkg/config"
+	"log/slog"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -53,7 +56,7 @@ type FileValidationResult struct {
 type UploadResult struct {
 	Filename string
 	FileURL  string
-	FilePath string
+	FilePath string // storage key, e.g. "documents/123_abc.pdf"
 	FileType string
 	Size     int64
 	Success  bool
@@ -61,7 +64,8 @@ type UploadResult struct {
 }
 
 type FileUpload struct {
-	cfg *config.Config
+	cfg     *config.Config
+	storage StorageBackend
 }
 
 var (
@@ -69,8 +73,30 @@ var (
 )
 
 // Init initializes the file upload package with configuration
-func Init(cfg *config.Config) {
-	fileUpload = &FileUpload{cfg: cfg}
+func Init(cfg *config.Config) error {
+	storage, err := newStorageBackend(cfg.StorageBackend, S3Config{
+		Endpoint:  cfg.S3Endpoint,
+		Region:    cfg.S3Region,
+		Bucket:    cfg.S3Bucket,
+		AccessKey: cfg.S3AccessKey,
+		SecretKey: cfg.S3SecretKey,
+		PathStyle: cfg.S3PathStyle,
+	})
+	if err != nil {
+		return err
+	}
+	fileUpload = &FileUpload{cfg: cfg, storage: storage}
+
+	if cfg.ClamAVAddress != "" {
+		SetScanner(NewClamAVScanner(cfg.ClamAVAddress, cfg.ClamAVTimeout))
+		failOpen = cfg.VirusScanFailOpen
+		slog.Info("Virus scanning enabled", "clamd", cfg.ClamAVAddress)
+	}
+
+	if storage.Name() != StorageLocal {
+		slog.Info("File storage ready", "storage", storage.Name(), "bucket", cfg.S3Bucket)
+		return nil
+	}
 
 	// Create upload directories if they don't exist
 	dirs := []string{
@@ -81,13 +107,27 @@ func Init(cfg *config.Config) {
 
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			fmt.Printf("Warning: Failed to create directory %s: %v\n", dir, err)
+			slog.Warn("Failed to create upload directory", "dir", dir, "error", err)
 		}
 	}
+	return nil
+}
+
+// PresignExpiry returns how long presigned download URLs stay valid
+func PresignExpiry() time.Duration {
+	if fileUpload == nil || fileUpload.cfg.S3PresignExpiry <= 0 {
+		return 15 * time.Minute
+	}
+	return fileUpload.cfg.S3PresignExpiry
+}
+
+// RedirectDownloads reports whether downloads should redirect to presigned URLs instead of being proxied
+func RedirectDownloads() bool {
+	return fileUpload != nil && fileUpload.cfg.S3ServeMode != "proxy"
 }
 
 // UploadFile handles file upload with comprehensive validation
-func UploadFile(file *multipart.FileHeader, fileType string) (*UploadResult, error) {
+func UploadFile(ctx context.Context, file *multipart.FileHeader, fileType string) (*UploadResult, error) {
 	if fileUpload == nil {
 		return nil, errors.New("file upload not initialized - call fileupload.Init() first")
 	}
@@ -102,7 +142,7 @@ func UploadFile(file *multipart.FileHeader, fileType string) (*UploadResult, err
 	}
 
 	// Perform comprehensive validation
-	validation := ValidateFile(file, fileType)
+	validation := ValidateFile(ctx, file, fileType)
 	if !validation.IsValid {
 		return nil, validation.Error
 	}
@@ -110,8 +150,6 @@ func UploadFile(file *multipart.FileHeader, fileType string) (*UploadResult, err
 	// Generate unique filename
 	ext := filepath.Ext(file.Filename)
 	filename := generateUniqueFilename(ext)
-	uploadPath := GetUploadPath(fileType)
-	fullPath := filepath.Join("uploads", uploadPath, filename)
 
 	// Save the file
 	src, err := file.Open()
@@ -120,22 +158,16 @@ func UploadFile(file *multipart.FileHeader, fileType string) (*UploadResult, err
 	}
 	defer src.Close()
 
-	dst, err := os.Create(fullPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create file: %v", err)
-	}
-	defer dst.Close()
-
-	// Copy file content
-	if _, err := io.Copy(dst, src); err != nil {
-		return nil, fmt.Errorf("failed to save file: %v", err)
+	key := ObjectKey(filename, fileType)
+	if err := Storage().Save(ctx, key, src, file.Size, mime.TypeByExtension(ext)); err != nil {
+		return nil, err
 	}
 
 	// Return upload result
 	result := &UploadResult{
 		Filename: filename,
 		FileURL:  GetFileURL(filename, fileType),
-		FilePath: fullPath,
+		FilePath: key,
 		FileType: fileType,
 		Size:     file.Size,
 		Success:  true,
@@ -145,12 +177,12 @@ func UploadFile(file *multipart.FileHeader, fileType string) (*UploadResult, err
 }
 
 // UploadFileWithAutoDetect handles file upload with automatic type detection
-func UploadFileWithAutoDetect(file *multipart.FileHeader) (*UploadResult, error) {
+func UploadFileWithAutoDetect(ctx context.Context, file *multipart.FileHeader) (*UploadResult, error) {
 	fileType, err := DetectFileType(file.Filename)
 	if err != nil {
 		return nil, err
 	}
-	return UploadFile(file, fileType)
+	return UploadFile(ctx, file, fileType)
 }
 
 // GetMaxSize returns the maximum allowed file size for a given file type
@@ -221,7 +253,7 @@ func DetectFileType(filename string) (string, error) {
 }
 
 // ValidateFile performs comprehensive validation of a file
-func ValidateFile(fileHeader *multipart.FileHeader, expectedType string) *FileValidationResult {
+func ValidateFile(ctx context.Context, fileHeader *multipart.FileHeader, expectedType string) *FileValidationResult {
 	result := &FileValidationResult{IsValid: false}
 
 	// Validate file extension
@@ -242,6 +274,14 @@ func ValidateFile(fileHeader *multipart.FileHeader, expectedType string) *FileVa
 		return result
 	}
 
+	// Documents can carry macros and exploits, so they are virus scanned when a scanner is configured
+	if expectedType == FileTypeDocument {
+		if err := ScanFile(ctx, fileHeader); err != nil {
+			result.Error = err
+			return result
+		}
+	}
+
 	result.IsValid = true
 	result.Type = expectedType
 	return result
@@ -269,7 +309,7 @@ func GetFileURL(filename, fileType string) string {
 	return fmt.Sprintf("/uploads/%s/%s", GetUploadPath(fileType), filename)
 }
 
-// GetFilePath returns the full filesystem path for a file
+// GetFilePath returns the full filesystem path for a file (local storage only)
 func GetFilePath(filename, fileType string) string {
 	if filename == "" {
 		return ""
@@ -278,28 +318,22 @@ func GetFilePath(filename, fileType string) string {
 }
 
 // DeleteFile removes an uploaded file
-func DeleteFile(filename, fileType string) error {
+func DeleteFile(ctx context.Context, filename, fileType string) error {
 	if filename == "" {
 		return errors.New("filename cannot be empty")
 	}
 
-	filePath := GetFilePath(filename, fileType)
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return errors.New("file does not exist")
-	}
-
-	return os.Remove(filePath)
+	return Storage().Delete(ctx, ObjectKey(filename, fileType))
 }
 
 // FileExists checks if a file exists
-func FileExists(filename, fileType string) bool {
+func FileExists(ctx context.Context, filename, fileType string) bool {
 	if filename == "" {
 		return false
 	}
 
-	filePath := GetFilePath(filename, fileType)
-	_, err := os.Stat(filePath)
-	return !os.IsNotExist(err)
+	_, err := Storage().Stat(ctx, ObjectKey(filename, fileType))
+	return err == nil
 }
 
 // validateMIMEType performs basic MIME type validation
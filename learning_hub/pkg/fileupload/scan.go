@@ -0,0 +1,172 @@
+package fileupload
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrScanUnavailable is returned when a file can't be scanned and VIRUS_SCAN_FAIL_OPEN is off
+var ErrScanUnavailable = errors.New("virus scanning is unavailable, please try again later")
+
+// InfectedError is returned for files the virus scanner flags
+type InfectedError struct {
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	return fmt.Sprintf("file rejected: malware detected (%s)", e.Signature)
+}
+
+// ScanResult is the verdict of a virus scan
+type ScanResult struct {
+	Infected  bool
+	Signature string // name of the detected malware
+}
+
+// Scanner checks file content for malware
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (*ScanResult, error)
+}
+
+var (
+	scanner  Scanner
+	failOpen bool
+)
+
+// SetScanner replaces the virus scanner; nil disables scanning
+func SetScanner(s Scanner) {
+	scanner = s
+}
+
+// ScanningEnabled reports whether uploads are virus scanned
+func ScanningEnabled() bool {
+	return scanner != nil
+}
+
+// ScanFile scans an uploaded file. It returns an *InfectedError for flagged files, and
+// ErrScanUnavailable when the scanner fails unless failing open is configured. The scan is
+// abandoned when ctx is cancelled, e.g. because the uploading client went away.
+func ScanFile(ctx context.Context, fileHeader *multipart.FileHeader) error {
+	if scanner == nil {
+		return nil
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open file for virus scan: %v", err)
+	}
+	defer file.Close()
+
+	result, err := scanner.Scan(ctx, file)
+	if err != nil {
+		slog.WarnContext(ctx, "Virus scan failed", "filename", fileHeader.Filename, "fail_open", failOpen, "error", err)
+		if failOpen {
+			return nil
+		}
+		return ErrScanUnavailable
+	}
+	if result.Infected {
+		return &InfectedError{Signature: result.Signature}
+	}
+	return nil
+}
+
+// ClamAVScanner scans files with a clamd daemon using the INSTREAM command
+type ClamAVScanner struct {
+	Network string // "tcp" or "unix"
+	Address string
+	Timeout time.Duration
+}
+
+// clamAVChunkSize is the size of each INSTREAM chunk sent to clamd
+const clamAVChunkSize = 64 * 1024
+
+// NewClamAVScanner returns a scanner for a clamd address: "host:port", "tcp://host:port" or "unix:///path/clamd.sock"
+func NewClamAVScanner(address string, timeout time.Duration) *ClamAVScanner {
+	s := &ClamAVScanner{Network: "tcp", Address: address, Timeout: timeout}
+	if rest, ok := strings.CutPrefix(address, "unix://"); ok {
+		s.Network, s.Address = "unix", rest
+	} else if rest, ok := strings.CutPrefix(address, "tcp://"); ok {
+		s.Address = rest
+	}
+	return s
+}
+
+// Scan streams r to clamd and parses its verdict
+func (s *ClamAVScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.Network, s.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+
+	// Each chunk is prefixed with its length as a 4-byte big-endian integer; a zero length ends the stream
+	buf := make([]byte, clamAVChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return nil, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return nil, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return nil, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return nil, err
+	}
+	return parseClamAVReply(reply)
+}
+
+// parseClamAVReply parses "stream: OK", "stream: <signature> FOUND" or "<message> ERROR"
+func parseClamAVReply(reply string) (*ScanResult, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+
+	switch {
+	case verdict == "OK":
+		return &ScanResult{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &ScanResult{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
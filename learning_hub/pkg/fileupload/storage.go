@@ -0,0 +1,179 @@
+package fileupload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage backends selectable with STORAGE_BACKEND
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+var (
+	// ErrObjectNotFound is returned when a stored file does not exist
+	ErrObjectNotFound = errors.New("file does not exist")
+	// ErrPresignNotSupported is returned by backends that can't issue direct download URLs
+	ErrPresignNotSupported = errors.New("storage backend does not support presigned URLs")
+)
+
+// ObjectInfo describes a stored file
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// StorageBackend stores uploaded files under slash-separated keys such as "documents/123_abc.pdf"
+type StorageBackend interface {
+	Name() string
+	Save(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a time-limited direct download URL; downloadName forces an attachment when set
+	PresignGet(key string, expiry time.Duration, downloadName string) (string, error)
+}
+
+// Storage returns the configured storage backend
+func Storage() StorageBackend {
+	if fileUpload == nil || fileUpload.storage == nil {
+		return NewLocalStorage("uploads")
+	}
+	return fileUpload.storage
+}
+
+// CheckWritable saves and deletes a small file in the storage, to tell whether uploads can be stored
+func CheckWritable(ctx context.Context) error {
+	storage := Storage()
+	key := "healthcheck/" + generateUniqueFilename(".txt")
+	if err := storage.Save(ctx, key, strings.NewReader("ok"), 2, "text/plain"); err != nil {
+		return err
+	}
+	return storage.Delete(ctx, key)
+}
+
+// ObjectKey returns the storage key of an uploaded file
+func ObjectKey(filename, fileType string) string {
+	return GetUploadPath(fileType) + "/" + filename
+}
+
+// newStorageBackend builds the backend selected in the configuration
+func newStorageBackend(backend string, s3cfg S3Config) (StorageBackend, error) {
+	switch strings.ToLower(backend) {
+	case "", StorageLocal:
+		return NewLocalStorage("uploads"), nil
+	case StorageS3:
+		return NewS3Storage(s3cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// LocalStorage keeps files on the local disk
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a local storage rooted at dir
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{root: dir}
+}
+
+func (s *LocalStorage) Name() string { return StorageLocal }
+
+// path resolves a key to a file path, refusing anything outside the storage root
+func (s *LocalStorage) path(key string) (string, error) {
+	root, err := filepath.Abs(s.root)
+	if err != nil {
+		return "", err
+	}
+	full, err := filepath.Abs(filepath.Join(root, filepath.FromSlash(key)))
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(full, root+string(filepath.Separator)) {
+		return "", errors.New("access denied - file outside allowed directory")
+	}
+	return full, nil
+}
+
+func (s *LocalStorage) Save(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	full, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return fmt.Errorf("failed to create upload directory: %v", err)
+	}
+
+	dst, err := os.Create(full)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("failed to save file: %v", err)
+	}
+
+	// Readable by owner/group, not executable
+	if err := os.Chmod(full, 0644); err != nil {
+		slog.Warn("Failed to set file permissions", "path", full, "error", err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	full, _ := s.path(key)
+	f, err := os.Open(full)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	full, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(full)
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, ErrObjectNotFound
+	}
+	return &ObjectInfo{Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	full, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(full)
+	if os.IsNotExist(err) {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+func (s *LocalStorage) PresignGet(key string, expiry time.Duration, downloadName string) (string, error) {
+	return "", ErrPresignNotSupported
+}
@@ -0,0 +1,175 @@
+package fileupload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"path"
+	"strings"
+
+	// Register decoders for the formats image.Decode understands
+	_ "image/gif"
+	_ "image/png"
+)
+
+// ImageSize is a generated variant of an uploaded image, cropped to fill Width x Height
+type ImageSize struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Image variants generated for every uploaded image
+var (
+	ImageSizeCard   = ImageSize{Name: "card", Width: 400, Height: 225}
+	ImageSizeBanner = ImageSize{Name: "banner", Width: 1200, Height: 400}
+	ImageSizeAvatar = ImageSize{Name: "avatar", Width: 128, Height: 128}
+
+	ImageSizes = []ImageSize{ImageSizeCard, ImageSizeBanner, ImageSizeAvatar}
+)
+
+const (
+	variantJPEGQuality = 85
+	// Images larger than this many pixels are not decoded, to bound memory use
+	maxVariantSourcePixels = 40 * 1000 * 1000
+)
+
+// ErrUnsupportedImage is returned for image formats that can't be resized (e.g. SVG, WebP)
+var ErrUnsupportedImage = errors.New("image format not supported for resizing")
+
+// VariantKey returns the storage key of a size variant, e.g. images/1_ab.png -> images/1_ab_card.jpg
+func VariantKey(key string, size ImageSize) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "_" + size.Name + ".jpg"
+}
+
+// ImageKeyFromURL returns the storage key of an uploaded image URL
+func ImageKeyFromURL(fileURL string) (string, bool) {
+	prefix := "/uploads/" + GetUploadPath(FileTypeImage) + "/"
+	idx := strings.Index(fileURL, prefix)
+	if idx < 0 || strings.ContainsAny(fileURL, "?#") {
+		return "", false
+	}
+	return fileURL[idx+len("/uploads/"):], true
+}
+
+// ImageDimensions returns the width and height of a stored image
+func ImageDimensions(ctx context.Context, key string) (int, int, error) {
+	reader, _, err := Storage().Open(ctx, key)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer reader.Close()
+
+	cfg, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		return 0, 0, ErrUnsupportedImage
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// GenerateImageVariants creates every ImageSizes variant of a stored image and returns their URLs by size name
+func GenerateImageVariants(ctx context.Context, key string) (map[string]string, error) {
+	storage := Storage()
+
+	reader, _, err := storage.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(reader); err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width*cfg.Height > maxVariantSourcePixels {
+		return nil, fmt.Errorf("image too large to resize (%dx%d)", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(&buf)
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	rgba := toRGBA(src)
+
+	urls := make(map[string]string, len(ImageSizes))
+	for _, size := range ImageSizes {
+		var out bytes.Buffer
+		if err := jpeg.Encode(&out, resizeToFill(rgba, size.Width, size.Height), &jpeg.Options{Quality: variantJPEGQuality}); err != nil {
+			return nil, err
+		}
+
+		variantKey := VariantKey(key, size)
+		if err := storage.Save(ctx, variantKey, &out, int64(out.Len()), "image/jpeg"); err != nil {
+			return nil, err
+		}
+		urls[size.Name] = "/uploads/" + variantKey
+	}
+	return urls, nil
+}
+
+// toRGBA flattens an image onto a white background (JPEG has no transparency)
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+	return dst
+}
+
+// resizeToFill center-crops src to the target aspect ratio and scales it with a box filter
+func resizeToFill(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	// Crop rectangle with the target aspect ratio
+	cw, ch := sw, sw*height/width
+	if ch > sh {
+		cw, ch = sh*width/height, sh
+	}
+	x0, y0 := (sw-cw)/2, (sh-ch)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy0 := y0 + y*ch/height
+		sy1 := y0 + (y+1)*ch/height
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < width; x++ {
+			sx0 := x0 + x*cw/width
+			sx1 := x0 + (x+1)*cw/width
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, b, a, n int
+			for sy := sy0; sy < sy1; sy++ {
+				i := src.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			o := dst.PixOffset(x, y)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(b / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,121 @@
+// Package fx converts amounts between currencies at exchange rates fetched from a rates API. Rates are
+// cached per base currency; when the API can't be reached, the last rates fetched are used.
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learning_hub/pkg/config"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownCurrency is returned when the rates don't include a currency
+var ErrUnknownCurrency = errors.New("no exchange rate for this currency")
+
+var (
+	ratesURL = "https://open.er-api.com/v6/latest/{base}"
+	ttl      = 6 * time.Hour
+	client   = &http.Client{Timeout: 10 * time.Second}
+
+	mu     sync.Mutex
+	tables = map[string]table{}
+)
+
+// table holds the rates from one base currency: 1 base = rates[code] code
+type table struct {
+	rates     map[string]float64
+	fetchedAt time.Time
+}
+
+// Init sets the rates API and cache lifetime from the configuration
+func Init(cfg *config.Config) {
+	if cfg.FXRatesURL != "" {
+		ratesURL = cfg.FXRatesURL
+	}
+	if cfg.FXCacheTTL > 0 {
+		ttl = cfg.FXCacheTTL
+	}
+}
+
+// Rate returns how many units of to one unit of from buys
+func Rate(ctx context.Context, from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, nil
+	}
+	rates, err := ratesFrom(ctx, from)
+	if err != nil {
+		return 0, err
+	}
+	rate, ok := rates[to]
+	if !ok || rate <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownCurrency, to)
+	}
+	return rate, nil
+}
+
+// Convert converts an amount between currencies, rounded to cents
+func Convert(ctx context.Context, amount float64, from, to string) (float64, error) {
+	rate, err := Rate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return math.Round(amount*rate*100) / 100, nil
+}
+
+// ratesFrom returns the rates from base, fetching them when the cached ones are older than the TTL
+func ratesFrom(ctx context.Context, base string) (map[string]float64, error) {
+	mu.Lock()
+	cached, ok := tables[base]
+	mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < ttl {
+		return cached.rates, nil
+	}
+
+	rates, err := fetch(ctx, base)
+	if err != nil {
+		if ok {
+			slog.Warn("Failed to refresh exchange rates, using cached ones", "base", base, "fetched_at", cached.fetchedAt, "error", err)
+			return cached.rates, nil
+		}
+		return nil, err
+	}
+	mu.Lock()
+	tables[base] = table{rates: rates, fetchedAt: time.Now()}
+	mu.Unlock()
+	return rates, nil
+}
+
+func fetch(ctx context.Context, base string) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(ratesURL, "{base}", base), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exchange rates unavailable: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange rates unavailable: status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Result string             `json:"result"`
+		Rates  map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid exchange rates: %v", err)
+	}
+	if body.Result == "error" || len(body.Rates) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCurrency, base)
+	}
+	return body.Rates, nil
+}
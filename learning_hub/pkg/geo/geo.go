@@ -0,0 +1,37 @@
+package geo
+
+import (
+	"learning_hub/pkg/config"
+	"net/http"
+	"strings"
+)
+
+// header carries the visitor's country as set by the CDN or reverse proxy in front of the API
+// (e.g. CF-IPCountry on Cloudflare, or an nginx geoip2 variable)
+var header = "CF-IPCountry"
+
+// Country codes proxies use when the location is unknown
+var unknown = map[string]bool{"XX": true, "T1": true, "A1": true, "A2": true, "EU": true, "AP": true}
+
+// Init sets the country header from the configuration
+func Init(cfg *config.Config) {
+	header = cfg.CountryHeader
+}
+
+// Normalize returns an ISO 3166-1 alpha-2 code in upper case, reporting false for anything else
+func Normalize(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' || unknown[code] {
+		return "", false
+	}
+	return code, true
+}
+
+// FromRequest returns the country of the request's IP address, or "" when it is unknown
+func FromRequest(r *http.Request) string {
+	if header == "" {
+		return ""
+	}
+	code, _ := Normalize(r.Header.Get(header))
+	return code
+}
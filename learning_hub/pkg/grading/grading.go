@@ -0,0 +1,97 @@
+package grading
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Band maps every percentage at or above MinPercent (and below the next band) to a letter
+type Band struct {
+	Letter     string  `json:"letter"`
+	MinPercent float64 `json:"min_percent"`
+}
+
+// Scale converts percentages into letter grades and decides whether a grade passes
+type Scale struct {
+	Name          string  `json:"name"`
+	PassThreshold float64 `json:"pass_threshold"` // minimum percentage to pass
+	Bands         []Band  `json:"bands"`
+}
+
+// Default returns the built-in scale used until an admin configures a platform default
+func Default() Scale {
+	return Scale{
+		Name:          "Standard",
+		PassThreshold: 60,
+		Bands: []Band{
+			{Letter: "A", MinPercent: 90},
+			{Letter: "B", MinPercent: 80},
+			{Letter: "C", MinPercent: 70},
+			{Letter: "D", MinPercent: 60},
+			{Letter: "F", MinPercent: 0},
+		},
+	}
+}
+
+// Normalize trims letters and orders bands from the highest threshold down
+func (s Scale) Normalize() Scale {
+	bands := make([]Band, len(s.Bands))
+	for i, b := range s.Bands {
+		bands[i] = Band{Letter: strings.TrimSpace(b.Letter), MinPercent: b.MinPercent}
+	}
+	sort.SliceStable(bands, func(i, j int) bool { return bands[i].MinPercent > bands[j].MinPercent })
+	s.Name = strings.TrimSpace(s.Name)
+	s.Bands = bands
+	return s
+}
+
+// Validate checks the scale covers 0-100% with distinct thresholds and letters
+func (s Scale) Validate() error {
+	if s.PassThreshold < 0 || s.PassThreshold > 100 {
+		return errors.New("pass_threshold must be between 0 and 100")
+	}
+	if len(s.Bands) == 0 {
+		return errors.New("at least one grade band is required")
+	}
+
+	letters := make(map[string]bool, len(s.Bands))
+	thresholds := make(map[float64]bool, len(s.Bands))
+	hasZero := false
+	for _, b := range s.Bands {
+		if b.Letter == "" || len(b.Letter) > 8 {
+			return errors.New("each band needs a letter of at most 8 characters")
+		}
+		if b.MinPercent < 0 || b.MinPercent > 100 {
+			return fmt.Errorf("min_percent for %s must be between 0 and 100", b.Letter)
+		}
+		if letters[b.Letter] {
+			return fmt.Errorf("letter %s is used more than once", b.Letter)
+		}
+		if thresholds[b.MinPercent] {
+			return fmt.Errorf("min_percent %g is used more than once", b.MinPercent)
+		}
+		letters[b.Letter], thresholds[b.MinPercent] = true, true
+		hasZero = hasZero || b.MinPercent == 0
+	}
+	if !hasZero {
+		return errors.New("the lowest band must start at 0 so every percentage gets a letter")
+	}
+	return nil
+}
+
+// Letter returns the letter grade for a percentage; bands must be normalized
+func (s Scale) Letter(percent float64) string {
+	for _, b := range s.Bands {
+		if percent >= b.MinPercent {
+			return b.Letter
+		}
+	}
+	return ""
+}
+
+// Passed reports whether a percentage meets the pass threshold
+func (s Scale) Passed(percent float64) bool {
+	return percent >= s.PassThreshold
+}
@@ -0,0 +1,141 @@
+// Package i18n translates what the API says to people. Messages are written in English in the code and
+// translated by the locale bundles in locales/, one JSON file per language mapping English messages to
+// their translation:
+//
+//	{"Course not found": "ኮርሱ አልተገኘም"}
+//
+// A message missing from a bundle stays in English. A request is answered in the preferred language of
+// its signed-in user, or else in the best language of its Accept-Language header.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultLocale is the language of the code, used when the client asks for none we have
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// bundles holds the translations of each language but English, by locale
+var bundles = mustLoadBundles()
+
+func mustLoadBundles() map[string]map[string]string {
+	files, err := fs.Glob(localeFiles, "locales/*.json")
+	if err != nil {
+		panic(err)
+	}
+	loaded := make(map[string]map[string]string, len(files))
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(file)
+		if err != nil {
+			panic(err)
+		}
+		bundle := map[string]string{}
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			panic(fmt.Sprintf("i18n: invalid bundle %s: %v", file, err))
+		}
+		loaded[strings.TrimSuffix(path.Base(file), ".json")] = bundle
+	}
+	return loaded
+}
+
+// Supported lists the locales messages are available in
+func Supported() []string {
+	locales := []string{DefaultLocale}
+	for locale := range bundles {
+		if locale != DefaultLocale {
+			locales = append(locales, locale)
+		}
+	}
+	sort.Strings(locales[1:])
+	return locales
+}
+
+// Normalize returns the supported locale of a language tag such as "am-ET" or "EN_us", and false for
+// languages we have no bundle for
+func Normalize(tag string) (string, bool) {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	base, _, _ = strings.Cut(base, "_")
+	if base == DefaultLocale {
+		return base, true
+	}
+	_, ok := bundles[base]
+	return base, ok && base != ""
+}
+
+// T translates message into locale, formatting it with args like fmt.Sprintf when there are any
+func T(locale, message string, args ...interface{}) string {
+	if translated, ok := bundles[locale][message]; ok && translated != "" {
+		message = translated
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Match picks the supported locale of an Accept-Language header, such as "am-ET,am;q=0.9,en;q=0.8"
+func Match(acceptLanguage string) string {
+	type preference struct {
+		tag    string
+		weight float64
+	}
+	var preferences []preference
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				weight = parsed
+			}
+		}
+		if tag != "" && weight > 0 {
+			preferences = append(preferences, preference{tag, weight})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].weight > preferences[j].weight })
+	for _, p := range preferences {
+		if locale, ok := Normalize(p.tag); ok {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+var userLanguage func(ctx context.Context, userID uint) string
+
+// SetUserLanguage installs the lookup of a user's preferred language, "" when they have none
+func SetUserLanguage(lookup func(ctx context.Context, userID uint) string) {
+	userLanguage = lookup
+}
+
+// Locale is the language to answer a request in: its user's preferred one once they are signed in,
+// else the one asked for by Accept-Language
+func Locale(c *gin.Context) string {
+	if locale := c.GetString("locale"); locale != "" {
+		return locale
+	}
+	userID, signedIn := c.Get("userID")
+	if !signedIn || userLanguage == nil {
+		return Match(c.GetHeader("Accept-Language"))
+	}
+	// Looked up once the user is known, then kept for the rest of the request
+	locale, ok := Normalize(userLanguage(c.Request.Context(), userID.(uint)))
+	if !ok {
+		locale = Match(c.GetHeader("Accept-Language"))
+	}
+	c.Set("locale", locale)
+	return locale
+}
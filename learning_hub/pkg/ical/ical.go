@@ -0,0 +1,94 @@
+// Package ical writes iCalendar (RFC 5545) feeds that calendar apps can subscribe to
+package ical
+
+import (
+	"strings"
+	"time"
+)
+
+// Event is a VEVENT. End defaults to Start; a zero LastModified is left out.
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	URL          string
+	Start        time.Time
+	End          time.Time
+	LastModified time.Time
+	Cancelled    bool
+}
+
+// Calendar is a VCALENDAR with its events
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+const stamp = "20060102T150405Z"
+
+// Encode renders the calendar. Times are written in UTC.
+func (cal Calendar) Encode(now time.Time) []byte {
+	var b strings.Builder
+	line := func(name, value string) { writeLine(&b, name+":"+value) }
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//LearnHub//Calendar//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if cal.Name != "" {
+		line("X-WR-CALNAME", escape(cal.Name))
+	}
+	for _, event := range cal.Events {
+		end := event.End
+		if end.Before(event.Start) {
+			end = event.Start
+		}
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", now.UTC().Format(stamp))
+		line("DTSTART", event.Start.UTC().Format(stamp))
+		line("DTEND", end.UTC().Format(stamp))
+		line("SUMMARY", escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", escape(event.Description))
+		}
+		if event.URL != "" {
+			line("URL", event.URL)
+		}
+		if !event.LastModified.IsZero() {
+			line("LAST-MODIFIED", event.LastModified.UTC().Format(stamp))
+		}
+		if event.Cancelled {
+			line("STATUS", "CANCELLED")
+		} else {
+			line("STATUS", "CONFIRMED")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return []byte(b.String())
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+func escape(text string) string {
+	return escaper.Replace(text)
+}
+
+// writeLine folds content lines longer than 75 octets without splitting UTF-8 characters
+func writeLine(b *strings.Builder, content string) {
+	limit := 75
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && content[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(content[:cut])
+		b.WriteString("\r\n ")
+		content = content[cut:]
+		limit = 74 // continuation lines start with the folding space
+	}
+	b.WriteString(content)
+	b.WriteString("\r\n")
+}
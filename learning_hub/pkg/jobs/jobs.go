@@ -0,0 +1,344 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Job is a task that runs periodically in the background: every Interval, starting when the jobs are
+// started, or at the times of Schedule when it is set
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Schedule string // cron expression, see Schedule
+	Run      func(ctx context.Context) error
+}
+
+// Results of a run
+const (
+	ResultSuccess     = "success"
+	ResultFailure     = "failure"
+	ResultPanic       = "panic"
+	ResultInterrupted = "interrupted" // cancelled by shutdown
+	ResultSkipped     = "skipped"     // left to another instance; only counted in metrics
+)
+
+// Run is a finished run of a job
+type Run struct {
+	Job        string
+	Instance   string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Result     string
+	Error      string
+}
+
+// Store coordinates the instances of the API running the same jobs and keeps the history of their runs.
+// Without one, every instance runs every job.
+type Store interface {
+	// Lock takes the lock of the job, held by one instance at a time; ok is false when another holds it
+	Lock(ctx context.Context, job string) (unlock func(), ok bool, err error)
+	// LastStarted returns when a recorded run of the job last started on any instance, zero if never
+	LastStarted(ctx context.Context, job string) (time.Time, error)
+	// Record saves a finished run
+	Record(ctx context.Context, run Run) error
+}
+
+// State is how a job has been running on this instance, for the readiness probe and admins
+type State struct {
+	Name      string
+	Interval  time.Duration
+	Schedule  string
+	Running   bool
+	LastRun   time.Time // when the last run ended
+	LastError string
+	NextRun   time.Time // when the job is next due, zero until started
+}
+
+// Stalled reports whether the job missed its schedule: it isn't running and is overdue by more than its
+// interval and a minute. Long runs, such as transcoding a video, are not stalls.
+func (s State) Stalled(started, now time.Time) bool {
+	if s.Running {
+		return false
+	}
+	due := s.NextRun
+	if due.IsZero() {
+		due = started
+	}
+	return now.Sub(due) > s.Interval+time.Minute
+}
+
+var (
+	jobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_runs_total",
+		Help: "Runs of background jobs, by job and result",
+	}, []string{"job", "result"})
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "job_run_duration_seconds",
+		Help:    "Duration of background job runs",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"job"})
+	jobsRunning = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "jobs_running",
+		Help: "Background jobs running on this instance",
+	}, []string{"job"})
+	jobLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "job_last_success_timestamp_seconds",
+		Help: "When each background job last succeeded, as a Unix time",
+	}, []string{"job"})
+)
+
+// instance names this process in the recorded runs
+var instance = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}()
+
+type entry struct {
+	job      Job
+	schedule *Schedule
+}
+
+var (
+	mu       sync.Mutex
+	registry []*entry
+	states   = map[string]*State{}
+	started  time.Time
+	store    Store
+
+	cancel  context.CancelFunc
+	running sync.WaitGroup // loops of the started jobs
+)
+
+// Register adds a job to be run by Start. It panics on an invalid schedule, like a duplicate metric.
+func Register(job Job) {
+	e := &entry{job: job}
+	if job.Schedule != "" {
+		schedule, err := ParseSchedule(job.Schedule)
+		if err != nil {
+			panic("jobs: " + job.Name + ": " + err.Error())
+		}
+		e.schedule = schedule
+	} else if job.Interval <= 0 {
+		panic("jobs: " + job.Name + " has neither an interval nor a schedule")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	registry = append(registry, e)
+	states[job.Name] = &State{Name: job.Name, Interval: job.Interval, Schedule: job.Schedule}
+}
+
+// SetStore makes the jobs run on one instance at a time and records their runs
+func SetStore(s Store) {
+	mu.Lock()
+	defer mu.Unlock()
+	store = s
+}
+
+// Start runs the registered jobs until ctx is cancelled: those with an interval right away and then on
+// their interval, scheduled ones at their next time
+func Start(ctx context.Context) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	started = time.Now()
+	ctx, cancel = context.WithCancel(ctx)
+	for _, e := range registry {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			loop(ctx, e)
+		}()
+	}
+	slog.Info("Started background jobs", "count", len(registry), "instance", instance)
+}
+
+// Stop cancels the context of the jobs and waits for the runs in progress to return, at most until ctx
+// is done. Jobs are expected to stop early when their context is cancelled; those that don't are
+// abandoned when the process exits.
+func Stop(ctx context.Context) error {
+	mu.Lock()
+	if cancel != nil {
+		cancel()
+	}
+	mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		slog.Info("Stopped background jobs")
+		return nil
+	case <-ctx.Done():
+		var busy []string
+		states, _ := States()
+		for _, state := range states {
+			if state.Running {
+				busy = append(busy, state.Name)
+			}
+		}
+		return fmt.Errorf("jobs still running: %s", strings.Join(busy, ", "))
+	}
+}
+
+// States returns the state of every registered job and when the jobs were started, zero until Start
+func States() ([]State, time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]State, len(registry))
+	for i, e := range registry {
+		list[i] = *states[e.job.Name]
+	}
+	return list, started
+}
+
+func loop(ctx context.Context, e *entry) {
+	next := time.Now()
+	if e.schedule != nil {
+		next = e.schedule.Next(next)
+		if next.IsZero() {
+			slog.Error("Job schedule never matches", "job", e.job.Name, "schedule", e.job.Schedule)
+			return
+		}
+	}
+
+	for {
+		setState(e.job.Name, func(s *State) { s.NextRun = next })
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		runOnce(ctx, e, next)
+
+		// Like a ticker, an interval job that overran its interval runs again right away, once
+		if e.schedule != nil {
+			next = e.schedule.Next(time.Now())
+		} else if next = next.Add(e.job.Interval); next.Before(time.Now()) {
+			next = time.Now()
+		}
+	}
+}
+
+// runOnce runs a job due at the time, unless another instance has it or already ran it. Panics are
+// recovered so one bad run doesn't stop the schedule.
+func runOnce(ctx context.Context, e *entry, due time.Time) {
+	name := e.job.Name
+	mu.Lock()
+	s := store
+	mu.Unlock()
+	if s != nil {
+		unlock, ok, err := s.Lock(ctx, name)
+		if err != nil {
+			slog.Error("Failed to lock job", "job", name, "error", err)
+			jobRuns.WithLabelValues(name, ResultFailure).Inc()
+			setState(name, func(s *State) { s.LastRun, s.LastError = time.Now(), "lock: "+err.Error() })
+			return
+		}
+		if !ok {
+			skip(name, "running on another instance")
+			return
+		}
+		defer unlock()
+		if last, err := s.LastStarted(ctx, name); err == nil && last.After(due.Add(-e.dedupWindow())) {
+			skip(name, "already run by another instance")
+			return
+		}
+	}
+
+	setState(name, func(s *State) { s.Running = true })
+	jobsRunning.WithLabelValues(name).Inc()
+	run := Run{Job: name, Instance: instance, StartedAt: time.Now()}
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Job panicked", "job", name, "panic", r)
+			err = errPanicked
+			run.Result = ResultPanic
+		}
+		run.FinishedAt = time.Now()
+		if err != nil {
+			run.Error = err.Error()
+		}
+		finish(ctx, s, run)
+	}()
+
+	if err = e.job.Run(ctx); err != nil {
+		if ctx.Err() != nil {
+			slog.Info("Job interrupted by shutdown", "job", name, "error", err)
+			run.Result = ResultInterrupted
+			return
+		}
+		slog.Error("Job failed", "job", name, "error", err)
+		run.Result = ResultFailure
+		return
+	}
+	run.Result = ResultSuccess
+	slog.Debug("Job completed", "job", name, "duration", time.Since(run.StartedAt).Round(time.Millisecond))
+}
+
+// dedupWindow is how long before a due time a run elsewhere counts as this one: half the interval, or
+// less than the minute between two cron times
+func (e *entry) dedupWindow() time.Duration {
+	if e.schedule != nil {
+		return 30 * time.Second
+	}
+	return e.job.Interval / 2
+}
+
+func skip(name, reason string) {
+	jobRuns.WithLabelValues(name, ResultSkipped).Inc()
+	slog.Debug("Job skipped", "job", name, "reason", reason)
+}
+
+// finish records the end of a run in the state, the metrics and the store
+func finish(ctx context.Context, s Store, run Run) {
+	setState(run.Job, func(s *State) {
+		s.Running, s.LastRun, s.LastError = false, run.FinishedAt, run.Error
+	})
+	jobsRunning.WithLabelValues(run.Job).Dec()
+	jobRuns.WithLabelValues(run.Job, run.Result).Inc()
+	jobDuration.WithLabelValues(run.Job).Observe(run.FinishedAt.Sub(run.StartedAt).Seconds())
+	if run.Result == ResultSuccess {
+		jobLastSuccess.WithLabelValues(run.Job).Set(float64(run.FinishedAt.Unix()))
+	}
+
+	if s == nil {
+		return
+	}
+	// Runs interrupted by shutdown are still recorded
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := s.Record(ctx, run); err != nil {
+		slog.Error("Failed to record job run", "job", run.Job, "error", err)
+	}
+}
+
+var errPanicked = errors.New("the job panicked")
+
+func setState(name string, update func(s *State)) {
+	mu.Lock()
+	defer mu.Unlock()
+	if s, ok := states[name]; ok {
+		update(s)
+	}
+}
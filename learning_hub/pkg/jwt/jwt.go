@@ -1,30 +1,47 @@
 package jwt
 
 import (
+	"learning_hub/pkg/clock"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+func init() {
+	// Validate expiry against the injectable clock so test fixtures can move time
+	jwt.TimeFunc = clock.Now
+}
+
 var jwtSecret = []byte("ermias1808")
 
+// TokenTTL is how long a token is valid
+const TokenTTL = 24 * time.Hour
+
 type Claims struct {
 	UserID uint   `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// Set on tokens an admin got to act as the user, to the admin's ID
+	ImpersonatorID uint `json:"impersonator_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, email, role string) (string, error) {
-	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "learnhub",
-		},
+// GenerateToken issues a token for a session, whose ID is the token's jti claim
+func GenerateToken(userID uint, email, role, sessionID string) (string, error) {
+	return sign(&Claims{UserID: userID, Email: email, Role: role}, sessionID, TokenTTL)
+}
+
+// GenerateImpersonationToken issues a token that lets an admin act as the user for ttl
+func GenerateImpersonationToken(userID uint, email, role, sessionID string, impersonatorID uint, ttl time.Duration) (string, error) {
+	return sign(&Claims{UserID: userID, Email: email, Role: role, ImpersonatorID: impersonatorID}, sessionID, ttl)
+}
+
+func sign(claims *Claims, sessionID string, ttl time.Duration) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        sessionID,
+		ExpiresAt: jwt.NewNumericDate(clock.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(clock.Now()),
+		Issuer:    "learnhub",
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
@@ -0,0 +1,132 @@
+// Package logger sets up the structured, leveled logs of the API on log/slog. Code logs with slog and
+// key/value attributes:
+//
+//	slog.ErrorContext(ctx, "Failed to send invitation", "user_id", user.ID, "error", err)
+//
+// Records logged with the context of a request carry its request_id, and its trace_id once traced.
+// Secrets never reach the logs: attributes named like passwords, tokens, secrets or codes are redacted
+// and email addresses are masked.
+package logger
+
+import (
+	"context"
+	"learning_hub/pkg/config"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the value of a sensitive attribute
+const Redacted = "[REDACTED]"
+
+// Init installs the default logger: JSON records in production (or with LOG_FORMAT=json), text otherwise,
+// from LOG_LEVEL up
+func Init(cfg *config.Config) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	format := strings.ToLower(cfg.LogFormat)
+	if format == "" && cfg.ServerEnv == "production" {
+		format = "json"
+	}
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(contextHandler{handler}))
+}
+
+// Fatal logs msg as an error and exits, for failures the API cannot start without
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the ID of its request, added to the records logged with it
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID is the ID of the request of ctx, "" outside requests
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request_id of the context to each record, and its trace_id and span_id when
+// the request is traced, so logs and traces can be matched
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() && sc.IsSampled() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Attribute names whose values are never logged, matched against the whole name or its last word
+// (so "verification_token" and "smtp_password" are redacted too)
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+	"code":          true,
+	"otp":           true,
+	"api_key":       true,
+	"private_key":   true,
+	"signature":     true,
+}
+
+// redact hides the values of sensitive attributes and masks email addresses
+func redact(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	last := key
+	if i := strings.LastIndexAny(key, "_-."); i >= 0 {
+		last = key[i+1:]
+	}
+	switch {
+	case sensitiveKeys[key], sensitiveKeys[last]:
+		return slog.String(a.Key, Redacted)
+	case last == "email" || last == "to" || last == "address":
+		if a.Value.Kind() == slog.KindString {
+			return slog.String(a.Key, MaskEmail(a.Value.String()))
+		}
+	}
+	return a
+}
+
+// MaskEmail keeps enough of an address to tell users apart in the logs, e.g. "ab***@gmail.com"
+func MaskEmail(address string) string {
+	local, domain, ok := strings.Cut(address, "@")
+	if !ok {
+		return address
+	}
+	if len(local) > 2 {
+		local = local[:2]
+	}
+	return local + "***@" + domain
+}
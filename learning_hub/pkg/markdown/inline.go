@@ -0,0 +1,302 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	entityPattern     = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	autolinkPattern   = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^<>\s]*)>`)
+	emailLinkPattern  = regexp.MustCompile(`^<([^\s<>@\\]+@[a-zA-Z0-9](?:[a-zA-Z0-9.-]*[a-zA-Z0-9])?)>`)
+	inlineHTMLPattern = regexp.MustCompile(`^(?:<!--[\s\S]*?-->|</?[a-zA-Z][a-zA-Z0-9-]*(?:\s+[a-zA-Z_:][a-zA-Z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>)`)
+)
+
+// punctuation can be escaped with a backslash
+const punctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// inline renders the inline Markdown of a paragraph or heading
+func inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				b.WriteString("<br>\n")
+				i += 2
+				continue
+			}
+			if i+1 < len(s) && strings.IndexByte(punctuation, s[i+1]) >= 0 {
+				b.WriteString(html.EscapeString(s[i+1 : i+2]))
+				i += 2
+				continue
+			}
+
+		case ' ':
+			// Two or more spaces before a line break make a hard break
+			end := i
+			for end < len(s) && s[end] == ' ' {
+				end++
+			}
+			if end < len(s) && s[end] == '\n' {
+				if end-i >= 2 {
+					b.WriteString("<br>")
+				}
+				i = end
+				continue
+			}
+			b.WriteString(s[i:end])
+			i = end
+			continue
+
+		case '`':
+			n := runLength(s, i, '`')
+			if j := closingBackticks(s, i+n, n); j >= 0 {
+				code := strings.ReplaceAll(s[i+n:j], "\n", " ")
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+					code = code[1 : len(code)-1]
+				}
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i = j + n
+			} else {
+				b.WriteString(s[i : i+n])
+				i += n
+			}
+			continue
+
+		case '!', '[':
+			image := c == '!'
+			if image && (i+1 >= len(s) || s[i+1] != '[') {
+				break
+			}
+			if out, next, ok := link(s, i, image); ok {
+				b.WriteString(out)
+				i = next
+				continue
+			}
+
+		case '<':
+			if m := autolinkPattern.FindStringSubmatch(s[i:]); m != nil {
+				b.WriteString(`<a href="` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + "</a>")
+				i += len(m[0])
+				continue
+			}
+			if m := emailLinkPattern.FindStringSubmatch(s[i:]); m != nil {
+				b.WriteString(`<a href="mailto:` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + "</a>")
+				i += len(m[0])
+				continue
+			}
+			if m := inlineHTMLPattern.FindString(s[i:]); m != "" {
+				b.WriteString(m)
+				i += len(m)
+				continue
+			}
+
+		case '&':
+			if m := entityPattern.FindString(s[i:]); m != "" {
+				b.WriteString(m)
+				i += len(m)
+				continue
+			}
+
+		case '*', '_', '~':
+			if out, next, ok := emphasis(s, i); ok {
+				b.WriteString(out)
+				i = next
+				continue
+			}
+			n := runLength(s, i, c)
+			b.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// closingBackticks finds the run of exactly n backticks closing a code span
+func closingBackticks(s string, from, n int) int {
+	for j := from; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		run := runLength(s, j, '`')
+		if run == n {
+			return j
+		}
+		j += run
+	}
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n'
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// emphasis renders *em*, **strong**, ***both*** (or with _, but not inside words) and ~~strikethrough~~
+// starting at s[i]
+func emphasis(s string, i int) (string, int, bool) {
+	c := s[i]
+	n := runLength(s, i, c)
+	if n > 3 || (c == '~' && n != 2) || i+n >= len(s) || isSpace(s[i+n]) {
+		return "", 0, false
+	}
+	if c == '_' && i > 0 && isAlphanumeric(s[i-1]) {
+		return "", 0, false
+	}
+	for j := i + n; j < len(s); {
+		switch {
+		case s[j] == '\\':
+			j += 2
+			continue
+		case s[j] == '`':
+			// Delimiters inside code spans don't count
+			run := runLength(s, j, '`')
+			if end := closingBackticks(s, j+run, run); end >= 0 {
+				j = end + run
+			} else {
+				j += run
+			}
+			continue
+		case s[j] != c:
+			j++
+			continue
+		}
+		run := runLength(s, j, c)
+		closes := run == n && !isSpace(s[j-1]) && (c != '_' || j+run == len(s) || !isAlphanumeric(s[j+run]))
+		if !closes {
+			j += run
+			continue
+		}
+		content := inline(s[i+n : j])
+		switch {
+		case c == '~':
+			content = "<del>" + content + "</del>"
+		case n == 1:
+			content = "<em>" + content + "</em>"
+		case n == 2:
+			content = "<strong>" + content + "</strong>"
+		default:
+			content = "<em><strong>" + content + "</strong></em>"
+		}
+		return content, j + run, true
+	}
+	return "", 0, false
+}
+
+// link renders [text](url "title") or, for images, ![alt](url "title") starting at s[i]
+func link(s string, i int, image bool) (string, int, bool) {
+	start := i + 1
+	if image {
+		start++
+	}
+	depth, j := 1, start
+	for ; j < len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] == '[' {
+			depth++
+		} else if s[j] == ']' {
+			if depth--; depth == 0 {
+				break
+			}
+		}
+	}
+	if j+1 >= len(s) || s[j+1] != '(' {
+		return "", 0, false
+	}
+	text := s[start:j]
+
+	// The destination, in <> or up to a space or an unbalanced )
+	k := skipSpaces(s, j+2)
+	var destination string
+	if k < len(s) && s[k] == '<' {
+		end := strings.IndexAny(s[k+1:], ">\n")
+		if end < 0 || s[k+1+end] != '>' {
+			return "", 0, false
+		}
+		destination = s[k+1 : k+1+end]
+		k += end + 2
+	} else {
+		from, parens := k, 0
+		for ; k < len(s) && !isSpace(s[k]); k++ {
+			if s[k] == '\\' && k+1 < len(s) {
+				k++
+			} else if s[k] == '(' {
+				parens++
+			} else if s[k] == ')' {
+				if parens == 0 {
+					break
+				}
+				parens--
+			}
+		}
+		destination = s[from:k]
+	}
+
+	// An optional title, in quotes or parentheses
+	title := ""
+	if after := skipSpaces(s, k); after > k && after < len(s) && strings.IndexByte(`"'(`, s[after]) >= 0 {
+		closer := s[after]
+		if closer == '(' {
+			closer = ')'
+		}
+		end := strings.IndexByte(s[after+1:], closer)
+		if end < 0 {
+			return "", 0, false
+		}
+		title = s[after+1 : after+1+end]
+		k = after + end + 2
+	}
+	k = skipSpaces(s, k)
+	if k >= len(s) || s[k] != ')' {
+		return "", 0, false
+	}
+
+	destination = html.EscapeString(unescape(destination))
+	attrTitle := ""
+	if title != "" {
+		attrTitle = ` title="` + html.EscapeString(unescape(title)) + `"`
+	}
+	if image {
+		return `<img src="` + destination + `" alt="` + html.EscapeString(unescape(text)) + `"` + attrTitle + ">", k + 1, true
+	}
+	return `<a href="` + destination + `"` + attrTitle + ">" + inline(text) + "</a>", k + 1, true
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+// unescape removes the backslashes escaping punctuation
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(punctuation, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
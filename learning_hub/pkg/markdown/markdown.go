@@ -0,0 +1,321 @@
+// Package markdown renders lesson content written in Markdown to HTML that is safe to show students.
+// HTML in the source is kept, and everything goes through Sanitize, so content can format text, link and
+// show images but can't run scripts or restyle the page.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ToHTML renders Markdown (CommonMark blocks and inlines, plus tables and ~~strikethrough~~) to sanitized
+// HTML. rewriteURL, when not nil, maps the address of every link and image kept.
+func ToHTML(src string, rewriteURL func(string) string) string {
+	return Sanitize(render(src), rewriteURL)
+}
+
+var (
+	headingPattern      = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ ]+(.*?))?(?:[ ]+#+)?[ ]*$`)
+	rulePattern         = regexp.MustCompile(`^ {0,3}(?:(?:\*[ ]*){3,}|(?:-[ ]*){3,}|(?:_[ ]*){3,})$`)
+	fencePattern        = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ ]*([^`\\s]*)")
+	bulletPattern       = regexp.MustCompile(`^( {0,3})([-*+])( +|$)`)
+	orderedPattern      = regexp.MustCompile(`^( {0,3})(\d{1,9})([.)])( +|$)`)
+	quotePattern        = regexp.MustCompile(`^ {0,3}> ?`)
+	htmlBlockPattern    = regexp.MustCompile(`^ {0,3}(?:<!--|</?[a-zA-Z][a-zA-Z0-9-]*(?:\s|/?>|$))`)
+	delimiterRowPattern = regexp.MustCompile(`^ *\|? *:?-+:? *(?:\| *:?-+:? *)*\|? *$`)
+)
+
+// render converts Markdown to HTML without sanitizing it
+func render(src string) string {
+	src = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\t", "    ").Replace(src)
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), false)
+	return b.String()
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// startsBlock reports whether the line starts a block that ends a paragraph
+func startsBlock(line string) bool {
+	if headingPattern.MatchString(line) || rulePattern.MatchString(line) || fencePattern.MatchString(line) ||
+		quotePattern.MatchString(line) || htmlBlockPattern.MatchString(line) {
+		return true
+	}
+	item, ok := parseListItem(line)
+	return ok && item.rest != "" && (!item.ordered || item.start == 1)
+}
+
+// renderBlocks renders the lines as a sequence of blocks. In tight lists, paragraphs aren't wrapped in <p>.
+func renderBlocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case fencePattern.MatchString(line):
+			m := fencePattern.FindStringSubmatch(line)
+			indent, fence, info := len(m[1]), m[2], m[3]
+			closing := regexp.MustCompile("^ {0,3}" + regexp.QuoteMeta(fence[:1]) + "{" + strconv.Itoa(len(fence)) + ",}[ ]*$")
+			var code []string
+			for i++; i < len(lines) && !closing.MatchString(lines[i]); i++ {
+				l := lines[i]
+				l = l[min(indent, indentOf(l)):]
+				code = append(code, l)
+			}
+			i++ // the closing fence, if any
+			b.WriteString("<pre><code")
+			if info != "" {
+				b.WriteString(` class="language-` + html.EscapeString(info) + `"`)
+			}
+			b.WriteString(">")
+			for _, l := range code {
+				b.WriteString(html.EscapeString(l) + "\n")
+			}
+			b.WriteString("</code></pre>\n")
+
+		case headingPattern.MatchString(line):
+			m := headingPattern.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + inline(strings.TrimSpace(m[2])) + "</h" + level + ">\n")
+			i++
+
+		case rulePattern.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case indentOf(line) >= 4:
+			var code []string
+			for ; i < len(lines) && (isBlank(lines[i]) || indentOf(lines[i]) >= 4); i++ {
+				l := lines[i]
+				code = append(code, l[min(4, indentOf(l)):])
+			}
+			for len(code) > 0 && isBlank(code[len(code)-1]) {
+				code = code[:len(code)-1]
+			}
+			b.WriteString("<pre><code>")
+			for _, l := range code {
+				b.WriteString(html.EscapeString(l) + "\n")
+			}
+			b.WriteString("</code></pre>\n")
+
+		case quotePattern.MatchString(line):
+			// Lines without > continue the quote until a blank line
+			var quoted []string
+			for ; i < len(lines) && !isBlank(lines[i]); i++ {
+				quoted = append(quoted, quotePattern.ReplaceAllString(lines[i], ""))
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted, false)
+			b.WriteString("</blockquote>\n")
+
+		case htmlBlockPattern.MatchString(line):
+			for ; i < len(lines) && !isBlank(lines[i]); i++ {
+				b.WriteString(lines[i] + "\n")
+			}
+
+		default:
+			if _, ok := parseListItem(line); ok {
+				i = renderList(b, lines, i)
+				continue
+			}
+			if i+1 < len(lines) && strings.Contains(line, "|") && delimiterRowPattern.MatchString(lines[i+1]) {
+				if next, ok := renderTable(b, lines, i); ok {
+					i = next
+					continue
+				}
+			}
+			paragraph := []string{strings.TrimLeft(line, " ")}
+			for i++; i < len(lines) && !isBlank(lines[i]) && !startsBlock(lines[i]); i++ {
+				paragraph = append(paragraph, strings.TrimLeft(lines[i], " "))
+			}
+			text := inline(strings.TrimRight(strings.Join(paragraph, "\n"), " "))
+			if tight {
+				b.WriteString(text + "\n")
+			} else {
+				b.WriteString("<p>" + text + "</p>\n")
+			}
+		}
+	}
+}
+
+// listItem is the first line of a list item
+type listItem struct {
+	ordered   bool
+	delimiter string // the bullet, or . or ) after the number
+	start     int
+	width     int // how far the item's content is indented
+	rest      string
+}
+
+func parseListItem(line string) (listItem, bool) {
+	if m := bulletPattern.FindStringSubmatch(line); m != nil && !rulePattern.MatchString(line) {
+		return newListItem(false, m[2], 0, m[1], m[2], m[3], line[len(m[0]):]), true
+	}
+	if m := orderedPattern.FindStringSubmatch(line); m != nil {
+		start, _ := strconv.Atoi(m[2])
+		return newListItem(true, m[3], start, m[1], m[2]+m[3], m[4], line[len(m[0]):]), true
+	}
+	return listItem{}, false
+}
+
+func newListItem(ordered bool, delimiter string, start int, indent, marker, spacing, rest string) listItem {
+	width := len(indent) + len(marker) + len(spacing)
+	// An empty item, or content indented as code, is one space from the marker
+	if spacing == "" || len(spacing) > 4 {
+		width = len(indent) + len(marker) + 1
+		rest = strings.Repeat(" ", max(len(spacing)-1, 0)) + rest
+	}
+	return listItem{ordered: ordered, delimiter: delimiter, start: start, width: width, rest: rest}
+}
+
+// renderList renders the list starting at lines[i] and returns the index of the line after it
+func renderList(b *strings.Builder, lines []string, i int) int {
+	first, _ := parseListItem(lines[i])
+	sameList := func(line string) bool {
+		item, ok := parseListItem(line)
+		return ok && item.ordered == first.ordered && item.delimiter == first.delimiter
+	}
+
+	var items [][]string
+	loose := false
+	for i < len(lines) && sameList(lines[i]) {
+		item, _ := parseListItem(lines[i])
+		content := []string{item.rest}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if isBlank(line) {
+				// Blank lines stay in the item when indented content follows them
+				j := i
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j == len(lines) || indentOf(lines[j]) < item.width {
+					break
+				}
+				for ; i < j; i++ {
+					content = append(content, "")
+				}
+				loose = true
+				line = lines[i]
+			}
+			if indentOf(line) >= item.width {
+				content = append(content, line[item.width:])
+				continue
+			}
+			// A paragraph continues on lines that don't start a block
+			if _, isItem := parseListItem(line); isItem || startsBlock(line) || isBlank(content[len(content)-1]) {
+				break
+			}
+			content = append(content, strings.TrimLeft(line, " "))
+		}
+		items = append(items, content)
+
+		// Blank lines between items make the list loose
+		j := i
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j > i && j < len(lines) && sameList(lines[j]) {
+			loose = true
+			i = j
+		}
+	}
+
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if first.ordered && first.start != 1 {
+		b.WriteString(` start="` + strconv.Itoa(first.start) + `"`)
+	}
+	b.WriteString(">\n")
+	for _, content := range items {
+		b.WriteString("<li>")
+		renderBlocks(b, content, !loose)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// splitRow splits a table row into its trimmed cells
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// renderTable renders the table whose header is lines[i], if the delimiter row matches it
+func renderTable(b *strings.Builder, lines []string, i int) (int, bool) {
+	header, delimiters := splitRow(lines[i]), splitRow(lines[i+1])
+	if len(header) != len(delimiters) {
+		return i, false
+	}
+	aligns := make([]string, len(delimiters))
+	for k, d := range delimiters {
+		switch {
+		case strings.HasPrefix(d, ":") && strings.HasSuffix(d, ":"):
+			aligns[k] = "center"
+		case strings.HasSuffix(d, ":"):
+			aligns[k] = "right"
+		case strings.HasPrefix(d, ":"):
+			aligns[k] = "left"
+		}
+	}
+	row := func(cells []string, tag string) {
+		b.WriteString("<tr>")
+		for k := range aligns {
+			b.WriteString("<" + tag)
+			if aligns[k] != "" {
+				b.WriteString(` align="` + aligns[k] + `"`)
+			}
+			b.WriteString(">")
+			if k < len(cells) {
+				b.WriteString(inline(cells[k]))
+			}
+			b.WriteString("</" + tag + ">")
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n<thead>\n")
+	row(header, "th")
+	b.WriteString("</thead>\n")
+	i += 2
+	if i < len(lines) && !isBlank(lines[i]) && !startsBlock(lines[i]) {
+		b.WriteString("<tbody>\n")
+		for ; i < len(lines) && !isBlank(lines[i]) && !startsBlock(lines[i]); i++ {
+			row(splitRow(lines[i]), "td")
+		}
+		b.WriteString("</tbody>\n")
+	}
+	b.WriteString("</table>\n")
+	return i, true
+}
@@ -0,0 +1,160 @@
+package markdown
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Elements kept by Sanitize. Others are replaced by their content, except those in droppedElements.
+var allowedElements = map[string]bool{
+	"p": true, "br": true, "hr": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"strong": true, "b": true, "em": true, "i": true, "u": true, "s": true, "del": true, "ins": true,
+	"mark": true, "sub": true, "sup": true, "small": true, "abbr": true, "cite": true, "q": true,
+	"blockquote": true, "code": true, "pre": true, "kbd": true, "samp": true, "var": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"a": true, "img": true, "figure": true, "figcaption": true, "details": true, "summary": true,
+	"table": true, "caption": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "th": true, "td": true,
+	"div": true, "span": true,
+}
+
+// droppedElements are removed with their content: scripts, styles, embedded documents and forms
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true, "object": true,
+	"embed": true, "applet": true, "form": true, "input": true, "button": true, "textarea": true,
+	"select": true, "option": true, "template": true, "noscript": true, "noembed": true, "noframes": true,
+	"title": true, "head": true, "meta": true, "link": true, "base": true, "svg": true, "math": true,
+	"xmp": true, "plaintext": true,
+}
+
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true}
+
+var (
+	numberPattern        = regexp.MustCompile(`^[0-9]{1,4}$`)
+	languageClassPattern = regexp.MustCompile(`^language-[A-Za-z0-9_+#.-]{1,40}$`)
+)
+
+// allowedAttribute reports whether the element may keep the attribute with this value. Event handlers,
+// style and id are never kept.
+func allowedAttribute(element, key, value string) bool {
+	switch key {
+	case "title", "lang":
+		return true
+	case "dir":
+		return value == "ltr" || value == "rtl" || value == "auto"
+	case "alt":
+		return element == "img"
+	case "width", "height":
+		return element == "img" && numberPattern.MatchString(value)
+	case "colspan", "rowspan":
+		return (element == "th" || element == "td") && numberPattern.MatchString(value)
+	case "align":
+		return (element == "th" || element == "td") && (value == "left" || value == "center" || value == "right")
+	case "start":
+		return element == "ol" && numberPattern.MatchString(value)
+	case "class":
+		return element == "code" && languageClassPattern.MatchString(value)
+	case "open":
+		return element == "details"
+	}
+	return false
+}
+
+// safeURL reports whether a link or image address can't run code: relative, http(s) or, for links, mailto
+func safeURL(value string, link bool) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		// Browsers ignore control characters in schemes, e.g. "java\tscript:"
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "", "http", "https":
+		return true
+	case "mailto":
+		return link
+	}
+	return false
+}
+
+// Sanitize keeps only formatting, links and images from an HTML fragment: no scripts, event handlers,
+// styles, embedded content or forms, and no links to javascript: or data: URLs. rewriteURL, when not nil,
+// maps the address of every link and image kept.
+func Sanitize(fragment string, rewriteURL func(string) string) string {
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), context)
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+	var b strings.Builder
+	for _, node := range nodes {
+		writeNode(&b, node, rewriteURL)
+	}
+	return b.String()
+}
+
+func writeNode(b *strings.Builder, n *html.Node, rewriteURL func(string) string) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		// Comments and doctypes are dropped
+		return
+	}
+
+	tag := n.Data
+	if droppedElements[tag] || n.Namespace != "" {
+		return
+	}
+	if !allowedElements[tag] {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeNode(b, child, rewriteURL)
+		}
+		return
+	}
+
+	b.WriteString("<" + tag)
+	for _, attr := range n.Attr {
+		key, value := strings.ToLower(attr.Key), attr.Val
+		if attr.Namespace != "" {
+			continue
+		}
+		if (tag == "a" && key == "href") || (tag == "img" && key == "src") {
+			if !safeURL(value, tag == "a") {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if rewriteURL != nil {
+				value = rewriteURL(value)
+			}
+		} else if !allowedAttribute(tag, key, value) {
+			continue
+		}
+		b.WriteString(" " + key + `="` + html.EscapeString(value) + `"`)
+	}
+	if tag == "a" {
+		// Links to other sites don't pass on the lesson page or ranking credit
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	b.WriteString(">")
+	if voidElements[tag] {
+		return
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeNode(b, child, rewriteURL)
+	}
+	b.WriteString("</" + tag + ">")
+}
@@ -0,0 +1,150 @@
+package mediaurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/config"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query parameters carried by signed media URLs
+const (
+	ParamExpires   = "expires"
+	ParamUser      = "uid"
+	ParamSignature = "sig"
+
+	uploadsPrefix = "/uploads/"
+	hlsPrefix     = "/uploads/hls/"
+)
+
+var (
+	secret []byte
+	expiry = 2 * time.Hour
+)
+
+// Init sets the signing key and link lifetime from the configuration
+func Init(cfg *config.Config) {
+	key := cfg.MediaURLSecret
+	if key == "" {
+		key = cfg.JWTSecret
+	}
+	secret = []byte(key)
+	if cfg.MediaURLExpiry > 0 {
+		expiry = cfg.MediaURLExpiry
+	}
+}
+
+// Expiry returns how long signed links stay valid
+func Expiry() time.Duration {
+	return expiry
+}
+
+// scope is what a signature covers: the file itself, or the whole directory of an HLS stream
+// so that playlists and segments share one signature
+func scope(p string) string {
+	if strings.HasPrefix(p, hlsPrefix) {
+		return path.Dir(p) + "/"
+	}
+	return p
+}
+
+func signature(scope string, userID uint, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(scope + "|" + strconv.FormatUint(uint64(userID), 10) + "|" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Query returns the signed query string granting userID access to the file at path p
+func Query(p string, userID uint) (string, time.Time) {
+	expiresAt := clock.Now().Add(expiry).Truncate(time.Second)
+	values := url.Values{}
+	values.Set(ParamExpires, strconv.FormatInt(expiresAt.Unix(), 10))
+	values.Set(ParamUser, strconv.FormatUint(uint64(userID), 10))
+	values.Set(ParamSignature, signature(scope(p), userID, expiresAt.Unix()))
+	return values.Encode(), expiresAt
+}
+
+// Sign returns fileURL with a signature for userID appended when it points at an uploaded file.
+// Other URLs (external videos, empty values) are returned unchanged.
+func Sign(fileURL string, userID uint) string {
+	idx := strings.Index(fileURL, uploadsPrefix)
+	if idx < 0 {
+		return fileURL
+	}
+	p := fileURL[idx:]
+	if strings.ContainsAny(p, "?#") {
+		return fileURL
+	}
+	query, _ := Query(p, userID)
+	return fileURL + "?" + query
+}
+
+// Strip removes a media signature from fileURL, so links handed out to clients are never stored
+func Strip(fileURL string) string {
+	idx := strings.Index(fileURL, "?")
+	if idx < 0 {
+		return fileURL
+	}
+	if query, err := url.ParseQuery(fileURL[idx+1:]); err == nil && Signed(query) {
+		return fileURL[:idx]
+	}
+	return fileURL
+}
+
+// Signed reports whether the request query carries a media signature at all
+func Signed(query url.Values) bool {
+	return query.Get(ParamSignature) != ""
+}
+
+// Verify checks the signature in query for the file at path p and returns the user it was issued to
+func Verify(p string, query url.Values) (uint, bool) {
+	expires, err := strconv.ParseInt(query.Get(ParamExpires), 10, 64)
+	if err != nil || clock.Now().Unix() > expires {
+		return 0, false
+	}
+	userID, err := strconv.ParseUint(query.Get(ParamUser), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	expected := signature(scope(p), uint(userID), expires)
+	if !hmac.Equal([]byte(expected), []byte(query.Get(ParamSignature))) {
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+// PathToken returns a signature for userID over every file under the directory dir, to be carried in
+// the path rather than the query: pages of unpacked packages load each other by relative links, which
+// keep the path but drop the query string
+func PathToken(dir string, userID uint) string {
+	expiresAt := clock.Now().Add(expiry).Truncate(time.Second).Unix()
+	return strconv.FormatInt(expiresAt, 10) + "." + strconv.FormatUint(uint64(userID), 10) + "." +
+		signature(dir, userID, expiresAt)
+}
+
+// VerifyPathToken checks a PathToken for the directory dir and returns the user it was issued to
+func VerifyPathToken(dir, token string) (uint, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || clock.Now().Unix() > expires {
+		return 0, false
+	}
+	userID, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if !hmac.Equal([]byte(signature(dir, uint(userID), expires)), []byte(parts[2])) {
+		return 0, false
+	}
+	return uint(userID), true
+}
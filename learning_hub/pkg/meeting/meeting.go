@@ -0,0 +1,71 @@
+package meeting
+
+import (
+	"context"
+	"errors"
+	"learning_hub/pkg/config"
+	"log/slog"
+	"time"
+)
+
+// ErrDisabled is returned when no meeting provider is configured
+var ErrDisabled = errors.New("live classes are not available")
+
+// Request describes the meeting to create
+type Request struct {
+	Topic    string
+	StartsAt time.Time
+	Duration time.Duration
+}
+
+// Meeting is a created meeting
+type Meeting struct {
+	Provider string
+	ID       string // the provider's meeting ID
+	JoinURL  string // for participants
+	HostURL  string // starts the meeting as host; equal to JoinURL when the provider has no host link
+}
+
+// Provider creates and deletes meetings on a video conferencing service
+type Provider interface {
+	Name() string
+	Create(ctx context.Context, req Request) (*Meeting, error)
+	Delete(ctx context.Context, id string) error
+}
+
+var provider Provider
+
+// Init selects the provider from the configuration; an empty MEETING_PROVIDER disables live classes
+func Init(cfg *config.Config) {
+	switch cfg.MeetingProvider {
+	case "jitsi":
+		provider = newJitsi(cfg.JitsiURL)
+	case "zoom":
+		provider = newZoom(cfg.ZoomAccountID, cfg.ZoomClientID, cfg.ZoomClientSecret)
+	default:
+		provider = nil
+		return
+	}
+	slog.Info("Live classes enabled", "provider", provider.Name())
+}
+
+// Enabled reports whether meetings can be created
+func Enabled() bool {
+	return provider != nil
+}
+
+// Create creates a meeting with the configured provider
+func Create(ctx context.Context, req Request) (*Meeting, error) {
+	if provider == nil {
+		return nil, ErrDisabled
+	}
+	return provider.Create(ctx, req)
+}
+
+// Delete removes a meeting created by the provider named; meetings of another provider are left alone
+func Delete(ctx context.Context, providerName, id string) error {
+	if provider == nil || provider.Name() != providerName || id == "" {
+		return nil
+	}
+	return provider.Delete(ctx, id)
+}
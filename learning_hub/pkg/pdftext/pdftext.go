@@ -0,0 +1,145 @@
+// Package pdftext lays out text in PDFs using the built-in Helvetica fonts, which need no embedding
+package pdftext
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// helveticaWidths are the standard Helvetica glyph widths for ASCII 32-126, in 1/1000 em
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// Width estimates the rendered width of s in points. Bold glyphs are a little wider than regular ones.
+func Width(s []byte, size float64, bold bool) float64 {
+	units := 0
+	for _, b := range s {
+		if b >= 32 && b <= 126 {
+			units += helveticaWidths[b-32]
+		} else {
+			units += 556
+		}
+	}
+	width := float64(units) * size / 1000
+	if bold {
+		width *= 1.1
+	}
+	return width
+}
+
+// winAnsiSpecials maps the characters Windows-1252 places in 0x80-0x9F
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// WinAnsi converts text to the encoding of the standard PDF fonts. Characters they cannot show become '?'.
+func WinAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			out = append(out, ' ')
+		case r >= 32 && r <= 126, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		case winAnsiSpecials[r] != 0:
+			out = append(out, winAnsiSpecials[r])
+		case r < 32:
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// Wrap splits text into lines no wider than width
+func Wrap(text []byte, size, width float64, bold bool) [][]byte {
+	words := bytes.Fields(text)
+	if len(words) == 0 {
+		return [][]byte{{}}
+	}
+
+	var lines [][]byte
+	var line []byte
+	for _, word := range words {
+		candidate := word
+		if len(line) > 0 {
+			candidate = append(append(append([]byte{}, line...), ' '), word...)
+		}
+		if len(line) > 0 && Width(candidate, size, bold) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		// Break words too long for a line on their own
+		for Width(candidate, size, bold) > width && len(candidate) > 1 {
+			cut := len(candidate) - 1
+			for cut > 1 && Width(candidate[:cut], size, bold) > width {
+				cut--
+			}
+			lines = append(lines, candidate[:cut])
+			candidate = candidate[cut:]
+		}
+		line = candidate
+	}
+	return append(lines, line)
+}
+
+// Escape escapes a string for a PDF literal
+func Escape(s []byte) []byte {
+	var out bytes.Buffer
+	for _, b := range s {
+		if b == '(' || b == ')' || b == '\\' {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(b)
+	}
+	return out.Bytes()
+}
+
+// Document assembles pages of content streams into a PDF of width x height points. Content streams
+// select the regular font as /F1 and the bold one as /F2.
+func Document(pages []*bytes.Buffer, width, height int) []byte {
+	// Objects: 1 catalog, 2 page tree, 3-4 fonts, then a page and its content stream per page
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for i, p := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				width, height, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
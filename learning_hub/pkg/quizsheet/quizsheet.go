@@ -0,0 +1,234 @@
+// Package quizsheet renders quizzes as printable documents for offline exams
+package quizsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"learning_hub/pkg/pdftext"
+	"strings"
+)
+
+// Question types that get special treatment on paper
+const (
+	typeMultipleChoice = "multiple_choice"
+	typeTrueFalse      = "true_false"
+	typeCoding         = "coding"
+)
+
+// Question is one numbered question on the sheet
+type Question struct {
+	Text        string
+	Type        string
+	Options     []string
+	Points      int
+	Answer      string // correct answer, only printed on answer keys
+	Explanation string
+}
+
+// Sheet is a quiz laid out for printing
+type Sheet struct {
+	Title        string
+	CourseTitle  string
+	Instructions string
+	TimeLimit    int // minutes, 0 for none
+	Questions    []Question
+	AnswerKey    bool // print correct answers and explanations instead of answer space
+}
+
+// TotalPoints returns the points available on the sheet
+func (s Sheet) TotalPoints() int {
+	total := 0
+	for _, q := range s.Questions {
+		total += q.Points
+	}
+	return total
+}
+
+// block is a paragraph of the sheet, shared by every output format
+type block struct {
+	text   string
+	size   float64 // points
+	bold   bool
+	indent float64 // points from the left margin
+	space  float64 // extra points above the paragraph
+}
+
+// optionLabel returns A, B, C... for the i-th option
+func optionLabel(i int) string {
+	if i < 26 {
+		return string(rune('A' + i))
+	}
+	return fmt.Sprint(i + 1)
+}
+
+// answerText describes the correct answer, with the option letter for multiple choice
+func answerText(q Question) string {
+	if q.Type == typeMultipleChoice {
+		for i, option := range q.Options {
+			if strings.EqualFold(strings.TrimSpace(option), strings.TrimSpace(q.Answer)) {
+				return optionLabel(i) + ". " + option
+			}
+		}
+	}
+	return q.Answer
+}
+
+// blocks lays out the sheet as paragraphs
+func (s Sheet) blocks() []block {
+	title := s.Title
+	if s.AnswerKey {
+		title += " - Answer Key"
+	}
+	out := []block{{text: title, size: 18, bold: true}}
+	if s.CourseTitle != "" {
+		out = append(out, block{text: s.CourseTitle, size: 12, space: 2})
+	}
+
+	details := fmt.Sprintf("%d questions, %d points", len(s.Questions), s.TotalPoints())
+	if s.TimeLimit > 0 {
+		details += fmt.Sprintf(", %d minutes", s.TimeLimit)
+	}
+	out = append(out, block{text: details, size: 10, space: 4})
+	if !s.AnswerKey {
+		out = append(out, block{text: "Name: ______________________________    Date: ______________    Score: ________", size: 11, space: 14})
+	}
+	if s.Instructions != "" {
+		out = append(out, block{text: "Instructions", size: 11, bold: true, space: 14})
+		for _, line := range strings.Split(s.Instructions, "\n") {
+			out = append(out, block{text: line, size: 10.5, space: 2})
+		}
+	}
+
+	for i, q := range s.Questions {
+		points := "points"
+		if q.Points == 1 {
+			points = "point"
+		}
+		out = append(out, block{text: fmt.Sprintf("%d. %s (%d %s)", i+1, q.Text, q.Points, points), size: 11, bold: true, space: 16})
+
+		switch q.Type {
+		case typeMultipleChoice:
+			for j, option := range q.Options {
+				out = append(out, block{text: optionLabel(j) + ". " + option, size: 10.5, indent: 18, space: 3})
+			}
+		case typeTrueFalse:
+			out = append(out, block{text: "True        False", size: 10.5, indent: 18, space: 3})
+		default:
+			if !s.AnswerKey {
+				lines := 3
+				if q.Type == typeCoding {
+					lines = 10
+				}
+				for j := 0; j < lines; j++ {
+					out = append(out, block{text: strings.Repeat("_", 80), size: 10.5, indent: 18, space: 8})
+				}
+			}
+		}
+
+		if s.AnswerKey {
+			out = append(out, block{text: "Answer: " + answerText(q), size: 10.5, bold: true, indent: 18, space: 6})
+			if q.Explanation != "" {
+				out = append(out, block{text: q.Explanation, size: 10, indent: 18, space: 2})
+			}
+		}
+	}
+	return out
+}
+
+// PDF page geometry (A4 portrait, in points)
+const (
+	pageWidth   = 595
+	pageHeight  = 842
+	pageMargin  = 56
+	lineSpacing = 1.35
+)
+
+// RenderPDF renders the sheet as a PDF using the built-in Helvetica fonts
+func RenderPDF(s Sheet) []byte {
+	var pages []*bytes.Buffer
+	var page *bytes.Buffer
+	y := 0.0
+	newPage := func() {
+		page = &bytes.Buffer{}
+		pages = append(pages, page)
+		y = pageHeight - pageMargin
+	}
+	newPage()
+
+	for _, b := range s.blocks() {
+		font := "F1"
+		if b.bold {
+			font = "F2"
+		}
+		leading := b.size * lineSpacing
+		lines := pdftext.Wrap(pdftext.WinAnsi(b.text), b.size, pageWidth-2*pageMargin-b.indent, b.bold)
+
+		y -= b.space
+		for _, line := range lines {
+			if y-leading < pageMargin {
+				newPage()
+			}
+			y -= leading
+			fmt.Fprintf(page, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, b.size, pageMargin+b.indent, y, pdftext.Escape(line))
+		}
+	}
+
+	// Page numbers
+	for i, p := range pages {
+		label := []byte(fmt.Sprintf("Page %d of %d", i+1, len(pages)))
+		fmt.Fprintf(p, "BT /F1 9 Tf %.1f %d Td (%s) Tj ET\n", pageWidth-pageMargin-pdftext.Width(label, 9, false), pageMargin/2, label)
+	}
+
+	return pdftext.Document(pages, pageWidth, pageHeight)
+}
+
+// Package parts of a minimal Word document
+const (
+	docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/></Types>`
+	docxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/></Relationships>`
+)
+
+// RenderDocx renders the sheet as a Word document so instructors can edit it before printing
+func RenderDocx(s Sheet) ([]byte, error) {
+	var doc bytes.Buffer
+	doc.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`)
+	for _, b := range s.blocks() {
+		// Word measures spacing in twentieths of a point and font sizes in half points
+		fmt.Fprintf(&doc, `<w:p><w:pPr><w:spacing w:before="%d" w:after="0"/><w:ind w:left="%d"/></w:pPr><w:r><w:rPr><w:rFonts w:ascii="Arial" w:hAnsi="Arial"/>`,
+			int(b.space*20), int(b.indent*20))
+		if b.bold {
+			doc.WriteString(`<w:b/>`)
+		}
+		fmt.Fprintf(&doc, `<w:sz w:val="%d"/></w:rPr><w:t xml:space="preserve">`, int(b.size*2))
+		if err := xml.EscapeText(&doc, []byte(b.text)); err != nil {
+			return nil, err
+		}
+		doc.WriteString(`</w:t></w:r></w:p>`)
+	}
+	doc.WriteString(`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1134" w:right="1134" w:bottom="1134" w:left="1134" w:header="567" w:footer="567" w:gutter="0"/></w:sectPr></w:body></w:document>`)
+
+	var out bytes.Buffer
+	archive := zip.NewWriter(&out)
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxRels},
+		{"word/document.xml", doc.String()},
+	} {
+		w, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,108 @@
+package realtime
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Events a subscriber can fall behind by before it is disconnected
+const bufferSize = 32
+
+// Event is a message pushed to a user's open connections
+type Event struct {
+	ID   uint   // notification ID, used by clients to resume after reconnecting
+	Type string // e.g. grading, payment
+	Data []byte // JSON payload
+}
+
+var (
+	mu          sync.Mutex
+	subscribers = make(map[uint]map[chan Event]struct{})
+)
+
+// Subscribe registers a connection of userID. The channel is closed when the connection is
+// dropped for falling behind or by CloseAll; call cancel once the connection ends.
+func Subscribe(userID uint) (<-chan Event, func()) {
+	ch := make(chan Event, bufferSize)
+
+	mu.Lock()
+	if subscribers[userID] == nil {
+		subscribers[userID] = make(map[chan Event]struct{})
+	}
+	subscribers[userID][ch] = struct{}{}
+	mu.Unlock()
+
+	return ch, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		remove(userID, ch)
+	}
+}
+
+// Publish sends an event to every open connection of userID. Connections whose buffer is full are
+// closed so the client reconnects and catches up from its last event.
+func Publish(userID uint, event Event) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for ch := range subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+			remove(userID, ch)
+		}
+	}
+}
+
+// Online reports whether userID has an open connection to this instance
+func Online(userID uint) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(subscribers[userID]) > 0
+}
+
+// Connections returns the number of open connections across all users
+func Connections() int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	n := 0
+	for _, chans := range subscribers {
+		n += len(chans)
+	}
+	return n
+}
+
+// CloseAll closes every connection, e.g. when the server shuts down, so clients reconnect to another instance
+func CloseAll() {
+	mu.Lock()
+	defer mu.Unlock()
+	for userID, chans := range subscribers {
+		for ch := range chans {
+			remove(userID, ch)
+		}
+	}
+}
+
+// remove closes and unregisters a subscriber; mu must be held
+func remove(userID uint, ch chan Event) {
+	if _, ok := subscribers[userID][ch]; !ok {
+		return
+	}
+	delete(subscribers[userID], ch)
+	close(ch)
+	if len(subscribers[userID]) == 0 {
+		delete(subscribers, userID)
+	}
+}
+
+func init() {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "realtime_connections",
+		Help: "Open server-sent event connections",
+	}, func() float64 {
+		return float64(Connections())
+	})
+}
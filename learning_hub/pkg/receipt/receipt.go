@@ -0,0 +1,186 @@
+// Package receipt renders payment receipts as one-page PDFs
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"learning_hub/pkg/currency"
+	"learning_hub/pkg/pdftext"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page geometry (A4 portrait, in points)
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 56
+)
+
+// Line is an item bought
+type Line struct {
+	Description string
+	Quantity    int
+	UnitPrice   float64
+	Amount      float64
+}
+
+// Reference is a labelled identifier of the payment, like the transaction reference
+type Reference struct {
+	Label string
+	Value string
+}
+
+// Receipt is a paid payment as shown to the buyer. Amounts include tax.
+type Receipt struct {
+	Number     string
+	IssuedAt   time.Time
+	Issuer     []string // the platform's name first, then its address, tax ID...
+	BilledTo   []string
+	Lines      []Line
+	Total      float64
+	TaxLabel   string  // e.g. VAT
+	TaxRate    float64 // in percent, included in the total
+	Currency   string
+	Locale     string
+	References []Reference
+	Note       string
+}
+
+// SplitTax splits a tax-inclusive amount into its net amount and tax, rounded to cents
+func SplitTax(total, ratePercent float64) (net, tax float64) {
+	if ratePercent <= 0 {
+		return total, 0
+	}
+	tax = math.Round(total*ratePercent/(100+ratePercent)*100) / 100
+	return math.Round((total-tax)*100) / 100, tax
+}
+
+// page writes text at fixed positions of a single page
+type page struct {
+	buf bytes.Buffer
+}
+
+// text writes text starting at x
+func (p *page) text(x, y, size float64, bold bool, s string) {
+	p.encoded(x, y, size, bold, pdftext.WinAnsi(s))
+}
+
+// encoded writes text already converted with pdftext.WinAnsi
+func (p *page) encoded(x, y, size float64, bold bool, s []byte) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.buf, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, size, x, y, pdftext.Escape(s))
+}
+
+// right writes text ending at x
+func (p *page) right(x, y, size float64, bold bool, s string) {
+	p.text(x-pdftext.Width(pdftext.WinAnsi(s), size, bold), y, size, bold, s)
+}
+
+func (p *page) rule(y float64) {
+	fmt.Fprintf(&p.buf, "0.8 G 0.75 w %d %.1f m %d %.1f l S 0 G\n", margin, y, pageWidth-margin, y)
+}
+
+// total is a line of the totals block
+type total struct {
+	label  string
+	amount float64
+	bold   bool
+}
+
+// RenderPDF renders the receipt
+func RenderPDF(r Receipt) []byte {
+	format := func(amount float64) string {
+		return currency.FormatAmount(amount, r.Currency, r.Locale)
+	}
+	p := &page{}
+	y := float64(pageHeight - margin)
+
+	// Issuer on the left, receipt number and date on the right
+	p.right(pageWidth-margin, y-18, 20, true, "RECEIPT")
+	for i, line := range r.Issuer {
+		size, bold := 10.0, false
+		if i == 0 {
+			size, bold = 14, true
+		}
+		y -= size * 1.4
+		p.text(margin, y, size, bold, line)
+	}
+	p.right(pageWidth-margin, pageHeight-margin-36, 10, false, "No. "+r.Number)
+	p.right(pageWidth-margin, pageHeight-margin-50, 10, false, r.IssuedAt.Format("January 2, 2006"))
+	y = math.Min(y, pageHeight-margin-50) - 30
+
+	if len(r.BilledTo) > 0 {
+		p.text(margin, y, 10, true, "Billed to")
+		for _, line := range r.BilledTo {
+			y -= 14
+			p.text(margin, y, 10, false, line)
+		}
+		y -= 30
+	}
+
+	// Line items
+	descWidth := float64(pageWidth - 2*margin - 250)
+	p.text(margin, y, 10, true, "Description")
+	p.right(pageWidth-margin-180, y, 10, true, "Qty")
+	p.right(pageWidth-margin-90, y, 10, true, "Unit price")
+	p.right(pageWidth-margin, y, 10, true, "Amount")
+	y -= 8
+	p.rule(y)
+	for _, line := range r.Lines {
+		y -= 16
+		wrapped := pdftext.Wrap(pdftext.WinAnsi(line.Description), 10, descWidth, false)
+		p.right(pageWidth-margin-180, y, 10, false, fmt.Sprint(line.Quantity))
+		p.right(pageWidth-margin-90, y, 10, false, format(line.UnitPrice))
+		p.right(pageWidth-margin, y, 10, false, format(line.Amount))
+		for i, text := range wrapped {
+			if i > 0 {
+				y -= 13
+			}
+			p.encoded(margin, y, 10, false, text)
+		}
+	}
+	y -= 10
+	p.rule(y)
+
+	// Totals, with the tax included in them
+	net, tax := SplitTax(r.Total, r.TaxRate)
+	totals := []total{{"Subtotal", net, false}}
+	if r.TaxRate > 0 {
+		label := strings.TrimSpace(r.TaxLabel + " " + strconv.FormatFloat(r.TaxRate, 'f', -1, 64) + "%")
+		totals = append(totals, total{label, tax, false})
+	}
+	totals = append(totals, total{"Total paid", r.Total, true})
+	for _, t := range totals {
+		y -= 18
+		p.right(pageWidth-margin-110, y, 10, t.bold, t.label)
+		p.right(pageWidth-margin, y, 10, t.bold, format(t.amount))
+	}
+
+	// Transaction references
+	if len(r.References) > 0 {
+		y -= 40
+		p.text(margin, y, 10, true, "Payment details")
+		for _, ref := range r.References {
+			if ref.Value == "" {
+				continue
+			}
+			y -= 14
+			p.text(margin, y, 9, false, ref.Label+":")
+			p.text(margin+110, y, 9, false, ref.Value)
+		}
+	}
+	// The note sits at the foot of the page
+	if r.Note != "" {
+		lines := pdftext.Wrap(pdftext.WinAnsi(r.Note), 9, pageWidth-2*margin, false)
+		for i, line := range lines {
+			p.encoded(margin, float64(margin+12*(len(lines)-1-i)), 9, false, line)
+		}
+	}
+	return pdftext.Document([]*bytes.Buffer{&p.buf}, pageWidth, pageHeight)
+}
@@ -0,0 +1,198 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"learning_hub/pkg/config"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrDisabled is returned when no execution service is configured
+var ErrDisabled = errors.New("code execution is not available")
+
+// MaxCodeSize caps submitted source code, in bytes
+const MaxCodeSize = 64 * 1024
+
+// maxOutputSize caps the stdout and stderr kept from a run
+const maxOutputSize = 16 * 1024
+
+// Code runs in an external execution service speaking the Piston API (POST /api/v2/execute), which
+// isolates each run in its own container with CPU, memory and time limits. It never runs on the API server.
+var (
+	baseURL    string
+	runTimeout = 3 * time.Second
+	client     = &http.Client{Timeout: 30 * time.Second}
+)
+
+// Init sets the execution service from the configuration; an empty SANDBOX_URL disables code execution
+func Init(cfg *config.Config) {
+	baseURL = strings.TrimRight(cfg.SandboxURL, "/")
+	if cfg.SandboxRunTimeout > 0 {
+		runTimeout = cfg.SandboxRunTimeout
+	}
+}
+
+// Enabled reports whether code can be executed
+func Enabled() bool {
+	return baseURL != ""
+}
+
+// Result is the outcome of one run
+type Result struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	TimedOut bool   `json:"timed_out"`
+	// Compilation output of compiled languages when the build failed
+	CompileError string `json:"compile_error,omitempty"`
+}
+
+// Succeeded reports whether the program compiled, finished in time and exited with status 0
+func (r Result) Succeeded() bool {
+	return r.CompileError == "" && !r.TimedOut && r.ExitCode == 0
+}
+
+type executeRequest struct {
+	Language   string        `json:"language"`
+	Version    string        `json:"version"`
+	Files      []executeFile `json:"files"`
+	Stdin      string        `json:"stdin"`
+	RunTimeout int64         `json:"run_timeout"` // milliseconds
+}
+
+type executeFile struct {
+	Content string `json:"content"`
+}
+
+type stage struct {
+	Stdout string  `json:"stdout"`
+	Stderr string  `json:"stderr"`
+	Code   *int    `json:"code"`
+	Signal *string `json:"signal"`
+}
+
+type executeResponse struct {
+	Message string `json:"message"`
+	Run     stage  `json:"run"`
+	Compile *stage `json:"compile"`
+}
+
+// Run executes code in the given language (e.g. python, javascript, go) with stdin as its input
+func Run(ctx context.Context, language, code, stdin string) (*Result, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+	if len(code) > MaxCodeSize {
+		return nil, fmt.Errorf("code is larger than %d KB", MaxCodeSize/1024)
+	}
+
+	body, err := json.Marshal(executeRequest{
+		Language:   language,
+		Version:    "*",
+		Files:      []executeFile{{Content: code}},
+		Stdin:      stdin,
+		RunTimeout: runTimeout.Milliseconds(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/v2/execute", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execution service unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response executeResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4*maxOutputSize)).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid response from execution service (status %d): %v", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Unknown languages and malformed requests are reported with a message
+		return nil, fmt.Errorf("execution service refused the run: %s", response.Message)
+	}
+
+	result := &Result{
+		Stdout: truncate(response.Run.Stdout),
+		Stderr: truncate(response.Run.Stderr),
+	}
+	if response.Compile != nil && response.Compile.Code != nil && *response.Compile.Code != 0 {
+		result.CompileError = truncate(response.Compile.Stderr + response.Compile.Stdout)
+		return result, nil
+	}
+	if response.Run.Code != nil {
+		result.ExitCode = *response.Run.Code
+	}
+	if response.Run.Signal != nil && *response.Run.Signal == "SIGKILL" {
+		result.TimedOut = true
+		result.ExitCode = -1
+	}
+	return result, nil
+}
+
+// TestCase is an input and the output a correct program prints for it
+type TestCase struct {
+	Name           string `json:"name"`
+	Stdin          string `json:"stdin"`
+	ExpectedOutput string `json:"expected_output"`
+	Hidden         bool   `json:"hidden"` // students only see whether it passed
+}
+
+// TestResult is the outcome of one test case
+type TestResult struct {
+	Name   string  `json:"name"`
+	Passed bool    `json:"passed"`
+	Run    *Result `json:"run,omitempty"`
+}
+
+// Check runs code against each test case in order. Hidden test results carry no output.
+func Check(ctx context.Context, language, code string, tests []TestCase) ([]TestResult, error) {
+	results := make([]TestResult, 0, len(tests))
+	for i, test := range tests {
+		run, err := Run(ctx, language, code, test.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		name := test.Name
+		if name == "" {
+			name = fmt.Sprintf("Test %d", i+1)
+		}
+		result := TestResult{Name: name, Passed: run.Succeeded() && SameOutput(run.Stdout, test.ExpectedOutput)}
+		if !test.Hidden {
+			result.Run = run
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SameOutput compares program output ignoring line endings and trailing whitespace on each line and at the end
+func SameOutput(actual, expected string) bool {
+	return normalizeOutput(actual) == normalizeOutput(expected)
+}
+
+func normalizeOutput(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+func truncate(s string) string {
+	if len(s) <= maxOutputSize {
+		return s
+	}
+	return s[:maxOutputSize] + "\n... output truncated"
+}
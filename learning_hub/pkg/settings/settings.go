@@ -0,0 +1,209 @@
+// Package settings holds the platform settings admins can change at runtime. Values stored in the
+// database override the defaults from the configuration and are cached in memory.
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"learning_hub/pkg/config"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Setting keys
+const (
+	AdminNotificationEmail = "admin_notification_email"
+	DefaultCurrencyKey     = "default_currency"
+	PlatformSharePercent   = "platform_share_percent"
+	FrontendURLKey         = "frontend_url"
+	TaxRatePercent         = "tax_rate_percent"
+	TaxLabelKey            = "tax_label"
+	ReceiptIssuerKey       = "receipt_issuer"
+)
+
+// Value types
+const (
+	TypeString   = "string"
+	TypeEmail    = "email"
+	TypeURL      = "url"
+	TypePercent  = "percent"
+	TypeCurrency = "currency"
+)
+
+// Definition describes a setting
+type Definition struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Default     string `json:"default"`
+}
+
+// How long values are cached. Updates on this instance invalidate the cache right away; other
+// instances pick them up within this time.
+const cacheTTL = time.Minute
+
+// Loader reads the stored values, by key
+type Loader func() (map[string]string, error)
+
+var (
+	mu          sync.RWMutex
+	definitions []Definition
+	byKey       map[string]Definition
+	load        Loader
+	cached      map[string]string
+	loadedAt    time.Time
+)
+
+// Init sets the defaults from the configuration and where stored values are read from
+func Init(cfg *config.Config, loader Loader) {
+	defs := []Definition{
+		{AdminNotificationEmail, TypeEmail, "Where admin notification emails are sent", cfg.AdminEmail},
+		{DefaultCurrencyKey, TypeCurrency, "ISO 4217 currency of course and track prices and payments", "ETB"},
+		{PlatformSharePercent, TypePercent, "Share of course revenue kept by the platform, in percent", strconv.FormatFloat(cfg.PlatformSharePercent, 'f', -1, 64)},
+		{FrontendURLKey, TypeURL, "Base URL of the web app that emails and calendar entries link to", cfg.FrontendURL},
+		{TaxRatePercent, TypePercent, "VAT or sales tax included in prices, in percent; shown on receipts of new payments", "0"},
+		{TaxLabelKey, TypeString, "Name of the tax on receipts", "VAT"},
+		{ReceiptIssuerKey, TypeString, "Business name, then address, tax ID and contact, printed atop receipts; one per line", "LearnHub"},
+	}
+	keyed := make(map[string]Definition, len(defs))
+	for _, def := range defs {
+		keyed[def.Key] = def
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	definitions, byKey, load = defs, keyed, loader
+	cached, loadedAt = nil, time.Time{}
+}
+
+// Definitions lists the settings
+func Definitions() []Definition {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Definition(nil), definitions...)
+}
+
+// Lookup returns a setting's definition
+func Lookup(key string) (Definition, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	def, ok := byKey[key]
+	return def, ok
+}
+
+// Invalidate drops the cached values so the next read loads them again
+func Invalidate() {
+	mu.Lock()
+	defer mu.Unlock()
+	cached = nil
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Validate checks a value for a setting and returns it normalized
+func Validate(key, value string) (string, error) {
+	def, ok := Lookup(key)
+	if !ok {
+		return "", fmt.Errorf("unknown setting %q", key)
+	}
+	value = strings.TrimSpace(value)
+	switch def.Type {
+	case TypeEmail:
+		if _, err := mail.ParseAddress(value); err != nil || strings.Contains(value, "<") {
+			return "", fmt.Errorf("%s must be an email address", key)
+		}
+	case TypeURL:
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return "", fmt.Errorf("%s must be an http or https URL", key)
+		}
+		value = strings.TrimRight(value, "/")
+	case TypePercent:
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return "", fmt.Errorf("%s must be a number between 0 and 100", key)
+		}
+	case TypeCurrency:
+		value = strings.ToUpper(value)
+		if !currencyCode.MatchString(value) {
+			return "", fmt.Errorf("%s must be a 3-letter ISO 4217 code", key)
+		}
+	default:
+		if value == "" {
+			return "", errors.New(key + " cannot be empty")
+		}
+	}
+	return value, nil
+}
+
+// values returns the stored values, loading them when the cache is empty or stale. When loading
+// fails the last values are kept, or the defaults are used.
+func values() map[string]string {
+	mu.RLock()
+	current, fresh := cached, cached != nil && time.Since(loadedAt) < cacheTTL
+	loader := load
+	mu.RUnlock()
+	if fresh || loader == nil {
+		return current
+	}
+
+	stored, err := loader()
+	if err != nil {
+		slog.Error("Failed to load settings", "error", err)
+		return current
+	}
+	mu.Lock()
+	cached, loadedAt = stored, time.Now()
+	mu.Unlock()
+	return stored
+}
+
+// String returns a setting's stored value, or its default
+func String(key string) string {
+	if value, ok := values()[key]; ok {
+		return value
+	}
+	def, _ := Lookup(key)
+	return def.Default
+}
+
+// Float returns a numeric setting, or 0 when it isn't a number
+func Float(key string) float64 {
+	value, _ := strconv.ParseFloat(String(key), 64)
+	return value
+}
+
+// AdminEmail is where admin notification emails go
+func AdminEmail() string { return String(AdminNotificationEmail) }
+
+// DefaultCurrency is the currency of prices and payments
+func DefaultCurrency() string { return String(DefaultCurrencyKey) }
+
+// PlatformShare is the platform's share of course revenue, in percent
+func PlatformShare() float64 { return Float(PlatformSharePercent) }
+
+// TaxRate is the tax included in prices, in percent
+func TaxRate() float64 { return Float(TaxRatePercent) }
+
+// TaxLabel names the tax on receipts
+func TaxLabel() string { return String(TaxLabelKey) }
+
+// ReceiptIssuer is the business details printed atop receipts, one line each
+func ReceiptIssuer() []string {
+	var lines []string
+	for _, line := range strings.Split(String(ReceiptIssuerKey), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// FrontendURL is the web app's base URL, without trailing slash
+func FrontendURL() string { return strings.TrimRight(String(FrontendURLKey), "/") }
@@ -0,0 +1,135 @@
+package slo
+
+import (
+	"learning_hub/pkg/clock"
+	"sync"
+	"time"
+)
+
+// Critical flows
+const (
+	FlowLogin      = "login"
+	FlowEnrollment = "enrollment"
+	FlowCheckout   = "checkout"
+	FlowWebhook    = "webhook"
+	FlowLessonGet  = "lesson_fetch"
+)
+
+// Objective is the service level a flow must meet over Window: at least Availability of the requests
+// succeed (no 5xx) and at least LatencyTarget of them are answered within LatencyThreshold
+type Objective struct {
+	Flow             string        `json:"flow"`
+	Availability     float64       `json:"availability"`
+	LatencyThreshold time.Duration `json:"-"`
+	LatencyTarget    float64       `json:"latency_target"`
+}
+
+// ErrorBudget is the share of requests allowed to fail
+func (o Objective) ErrorBudget() float64 {
+	return 1 - o.Availability
+}
+
+// Window is the period objectives are measured over
+const Window = 30 * 24 * time.Hour
+
+// Objectives lists the critical flows with their objectives
+var Objectives = []Objective{
+	{Flow: FlowLogin, Availability: 0.999, LatencyThreshold: 500 * time.Millisecond, LatencyTarget: 0.99},
+	{Flow: FlowEnrollment, Availability: 0.999, LatencyThreshold: time.Second, LatencyTarget: 0.99},
+	{Flow: FlowCheckout, Availability: 0.995, LatencyThreshold: 3 * time.Second, LatencyTarget: 0.95}, // waits on Chapa
+	{Flow: FlowWebhook, Availability: 0.999, LatencyThreshold: 3 * time.Second, LatencyTarget: 0.99},
+	{Flow: FlowLessonGet, Availability: 0.999, LatencyThreshold: 300 * time.Millisecond, LatencyTarget: 0.99},
+}
+
+// ObjectiveFor returns the objective of a flow
+func ObjectiveFor(flow string) (Objective, bool) {
+	for _, objective := range Objectives {
+		if objective.Flow == flow {
+			return objective, true
+		}
+	}
+	return Objective{}, false
+}
+
+// Sample aggregates the requests of one flow in one minute
+type Sample struct {
+	Flow      string
+	Minute    time.Time
+	Total     int
+	Errors    int
+	Slow      int
+	LatencyMs int64 // sum, for the average
+	MaxMs     int64
+}
+
+type key struct {
+	flow   string
+	minute time.Time
+}
+
+var (
+	mu      sync.Mutex
+	pending = map[key]*Sample{}
+)
+
+// Record counts one request of a flow. Server errors (5xx) burn the error budget; client errors don't.
+func Record(flow string, status int, latency time.Duration) {
+	objective, ok := ObjectiveFor(flow)
+	if !ok {
+		return
+	}
+	minute := clock.Now().UTC().Truncate(time.Minute)
+	ms := latency.Milliseconds()
+
+	mu.Lock()
+	defer mu.Unlock()
+	sample, ok := pending[key{flow, minute}]
+	if !ok {
+		sample = &Sample{Flow: flow, Minute: minute}
+		pending[key{flow, minute}] = sample
+	}
+	sample.Total++
+	if status >= 500 {
+		sample.Errors++
+	}
+	if latency > objective.LatencyThreshold {
+		sample.Slow++
+	}
+	sample.LatencyMs += ms
+	if ms > sample.MaxMs {
+		sample.MaxMs = ms
+	}
+}
+
+// Drain returns the samples recorded since the last call and forgets them
+func Drain() []Sample {
+	mu.Lock()
+	defer mu.Unlock()
+	samples := make([]Sample, 0, len(pending))
+	for k, sample := range pending {
+		samples = append(samples, *sample)
+		delete(pending, k)
+	}
+	return samples
+}
+
+// Restore puts samples back, e.g. when persisting them failed, so they are retried on the next Drain
+func Restore(samples []Sample) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range samples {
+		sample, ok := pending[key{s.Flow, s.Minute}]
+		if !ok {
+			copied := s
+			pending[key{s.Flow, s.Minute}] = &copied
+			continue
+		}
+		sample.Total += s.Total
+		sample.Errors += s.Errors
+		sample.Slow += s.Slow
+		sample.LatencyMs += s.LatencyMs
+		if s.MaxMs > sample.MaxMs {
+			sample.MaxMs = s.MaxMs
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package spam
+
+import (
+	"fmt"
+	"learning_hub/pkg/config"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Score at which content is held for moderation instead of being published
+const FlagThreshold = 2
+
+// Posting more than VelocityLimit items within VelocityWindow counts as a spam signal
+const (
+	VelocityWindow = 10 * time.Minute
+	VelocityLimit  = 3
+)
+
+var (
+	linkPattern      = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+	postingLimit     = 10
+	duplicateMinSize = 10 // shorter texts such as "Great course" legitimately repeat
+)
+
+// Init sets the hard per-user posting limit from the configuration
+func Init(cfg *config.Config) {
+	postingLimit = cfg.PostingLimitPerHour
+}
+
+// PostingLimit returns how many items a user may post per hour (0 means unlimited)
+func PostingLimit() int {
+	return postingLimit
+}
+
+// Result is the spam verdict for a piece of content
+type Result struct {
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// Flagged reports whether the content should be held for moderation
+func (r Result) Flagged() bool {
+	return r.Score >= FlagThreshold
+}
+
+func (r *Result) add(score int, reason string) {
+	r.Score += score
+	r.Reasons = append(r.Reasons, reason)
+}
+
+// Check scores text for links, repetition and posting velocity. recent holds the author's
+// recent posts and recentCount how many they made within VelocityWindow.
+func Check(text string, recent []string, recentCount int) Result {
+	result := Result{Reasons: []string{}}
+
+	if links := len(linkPattern.FindAllString(text, -1)); links > 1 {
+		result.add(2, fmt.Sprintf("contains %d links", links))
+	} else if links == 1 {
+		result.add(1, "contains a link")
+	}
+
+	if repeatedRun(text, 8) {
+		result.add(1, "repeated characters")
+	}
+	if word, ok := dominantWord(text); ok {
+		result.add(2, fmt.Sprintf("repeats %q", word))
+	}
+	if shouting(text) {
+		result.add(1, "written in capitals")
+	}
+
+	normalized := normalize(text)
+	if len(normalized) >= duplicateMinSize {
+		for _, r := range recent {
+			if normalize(r) == normalized {
+				result.add(2, "duplicate of a recent post")
+				break
+			}
+		}
+	}
+
+	if recentCount >= VelocityLimit {
+		result.add(2, fmt.Sprintf("%d posts in the last %s", recentCount, VelocityWindow))
+	}
+
+	return result
+}
+
+// dominantWord returns a word making up more than 40% of a text of at least 8 words
+func dominantWord(text string) (string, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) < 8 {
+		return "", false
+	}
+
+	counts := make(map[string]int)
+	for _, w := range words {
+		if len(w) >= 3 {
+			counts[w]++
+		}
+	}
+	for w, n := range counts {
+		if n*10 > len(words)*4 {
+			return w, true
+		}
+	}
+	return "", false
+}
+
+// repeatedRun reports whether text has n or more identical characters in a row
+func repeatedRun(text string, n int) bool {
+	var prev rune
+	run := 0
+	for _, r := range text {
+		if r == prev {
+			run++
+		} else {
+			prev, run = r, 1
+		}
+		if run >= n {
+			return true
+		}
+	}
+	return false
+}
+
+// shouting reports whether a text with at least 20 letters is almost entirely upper case
+func shouting(text string) bool {
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	return letters >= 20 && upper*10 >= letters*9
+}
+
+func normalize(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
@@ -0,0 +1,233 @@
+// Package stripe starts card payments on Stripe Checkout and verifies the webhooks Stripe sends about them
+package stripe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"learning_hub/pkg/config"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// apiURL is the Stripe API
+const apiURL = "https://api.stripe.com/v1"
+
+// webhookTolerance is how old a webhook's signature timestamp may be
+const webhookTolerance = 5 * time.Minute
+
+// ErrDisabled is returned when no Stripe secret key is configured
+var ErrDisabled = errors.New("Stripe payments are not available")
+
+// ErrInvalidSignature is returned for webhooks not signed with the endpoint's secret
+var ErrInvalidSignature = errors.New("invalid Stripe webhook signature")
+
+var (
+	secretKey     string
+	webhookSecret string
+	client        = &http.Client{Timeout: 15 * time.Second, Transport: otelhttp.NewTransport(nil, otelhttp.WithSpanNameFormatter(spanName))}
+)
+
+// spanName names the client spans of the calls to Stripe, e.g. "stripe POST"
+func spanName(_ string, r *http.Request) string {
+	return "stripe " + r.Method
+}
+
+// Init sets the API and webhook keys from the configuration; an empty STRIPE_SECRET_KEY disables Stripe
+func Init(cfg *config.Config) {
+	secretKey = cfg.StripeSecretKey
+	webhookSecret = cfg.StripeWebhookSecret
+}
+
+// Enabled reports whether payments can be taken with Stripe
+func Enabled() bool {
+	return secretKey != ""
+}
+
+// CheckoutRequest describes a one-off payment for a single item
+type CheckoutRequest struct {
+	Amount      float64 // in the currency's major unit, e.g. dollars
+	Currency    string
+	Name        string // shown on the checkout page
+	Description string
+	Email       string
+	Reference   string // our transaction reference, sent back in webhooks as client_reference_id
+	SuccessURL  string
+	CancelURL   string
+	ExpiresAt   time.Time // when the session can no longer be paid, at least 30 minutes ahead; zero for Stripe's 24 hours
+}
+
+// Session is a Stripe Checkout session
+type Session struct {
+	ID                string            `json:"id"`
+	URL               string            `json:"url"`
+	Status            string            `json:"status"`         // open, complete or expired
+	PaymentStatus     string            `json:"payment_status"` // paid, unpaid or no_payment_required
+	ClientReferenceID string            `json:"client_reference_id"`
+	AmountTotal       int64             `json:"amount_total"`
+	Currency          string            `json:"currency"`
+	Metadata          map[string]string `json:"metadata"`
+}
+
+// Paid reports whether the customer's payment went through
+func (s Session) Paid() bool {
+	return s.PaymentStatus == "paid" || s.PaymentStatus == "no_payment_required"
+}
+
+// CreateCheckoutSession starts a hosted checkout and returns the session with the URL to send the customer to
+func CreateCheckoutSession(ctx context.Context, req CheckoutRequest) (*Session, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", req.SuccessURL)
+	form.Set("cancel_url", req.CancelURL)
+	form.Set("client_reference_id", req.Reference)
+	form.Set("metadata[tx_ref]", req.Reference)
+	if req.Email != "" {
+		form.Set("customer_email", req.Email)
+	}
+	if !req.ExpiresAt.IsZero() {
+		form.Set("expires_at", strconv.FormatInt(req.ExpiresAt.Unix(), 10))
+	}
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", strings.ToLower(req.Currency))
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(minorUnits(req.Amount), 10))
+	form.Set("line_items[0][price_data][product_data][name]", req.Name)
+	if req.Description != "" {
+		form.Set("line_items[0][price_data][product_data][description]", req.Description)
+	}
+
+	var session Session
+	if err := call(ctx, http.MethodPost, "/checkout/sessions", form, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetSession fetches a checkout session, e.g. to confirm its payment status
+func GetSession(ctx context.Context, id string) (*Session, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+	var session Session
+	if err := call(ctx, http.MethodGet, "/checkout/sessions/"+url.PathEscape(id), nil, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// minorUnits converts an amount to cents, the unit Stripe takes for two-decimal currencies like USD and EUR
+func minorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func call(ctx context.Context, method, path string, form url.Values, out interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(secretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Stripe: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read Stripe response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &failure) == nil && failure.Error.Message != "" {
+			return fmt.Errorf("Stripe error (status %d): %s", resp.StatusCode, failure.Error.Message)
+		}
+		return fmt.Errorf("Stripe error (status %d)", resp.StatusCode)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Event is a webhook event. For checkout.session.* events, Data.Object is the session.
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// Session decodes the checkout session of a checkout.session.* event
+func (e Event) Session() (Session, error) {
+	var session Session
+	err := json.Unmarshal(e.Data.Object, &session)
+	return session, err
+}
+
+// ParseWebhook checks the Stripe-Signature header of a webhook against the endpoint's secret and decodes it
+func ParseWebhook(payload []byte, signatureHeader string) (Event, error) {
+	var event Event
+	if webhookSecret == "" {
+		return event, ErrDisabled
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(signatureHeader, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return event, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > webhookTolerance || age < -webhookTolerance {
+		return event, ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(webhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+	valid := false
+	for _, signature := range signatures {
+		if decoded, err := hex.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+			valid = true
+		}
+	}
+	if !valid {
+		return event, ErrInvalidSignature
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return event, fmt.Errorf("invalid Stripe webhook: %v", err)
+	}
+	return event, nil
+}
@@ -0,0 +1,74 @@
+// Package tracing sets up OpenTelemetry, so requests, database statements and calls to other services,
+// such as Chapa and the SMTP server, are recorded as spans and sent to a collector (Jaeger, Tempo...)
+// over OTLP/HTTP, and a slow request can be followed end to end:
+//
+//	ctx, span := tracing.Tracer.Start(ctx, "chapa.verify", trace.WithSpanKind(trace.SpanKindClient))
+//	defer span.End()
+//
+// Spans started with the context of a span are its children; requests carrying a W3C traceparent header
+// continue the caller's trace. Tracing is off until OTEL_EXPORTER_OTLP_ENDPOINT is set: until then the
+// global tracer provider of OpenTelemetry records nothing.
+package tracing
+
+import (
+	"context"
+	"learning_hub/pkg/config"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer starts the spans of the API. It follows the provider set by Init, so it can be kept in a
+// package variable.
+var Tracer = otel.Tracer("learning_hub")
+
+var provider *sdktrace.TracerProvider
+
+// Init sends spans to cfg.OTelEndpoint, keeping cfg.OTelSampleRatio of the traces that don't continue
+// a caller's; without an endpoint, tracing stays off. Trace context is read from and written to the
+// traceparent header either way.
+func Init(cfg *config.Config) error {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if cfg.OTelEndpoint == "" {
+		return nil
+	}
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.OTelEndpoint, "/")+"/v1/traces"))
+	if err != nil {
+		return err
+	}
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.OTelSampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(cfg.OTelServiceName),
+			semconv.DeploymentEnvironmentName(cfg.ServerEnv),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return nil
+}
+
+// Shutdown sends the spans not exported yet, waiting at most until ctx is done
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
+
+// Fail records err on span and marks the span failed; a nil err is ignored
+func Fail(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
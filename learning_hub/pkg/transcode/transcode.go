@@ -0,0 +1,203 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MasterPlaylist is the file name of the HLS manifest that lists every rendition
+const MasterPlaylist = "master.m3u8"
+
+// Rendition is one quality level of the HLS ladder
+type Rendition struct {
+	Name        string // also the variant playlist name, e.g. "720p" -> 720p.m3u8
+	Height      int
+	VideoKbps   int
+	AudioKbps   int
+	outputWidth int
+}
+
+// DefaultRenditions is the full bitrate ladder; renditions taller than the source are skipped
+var DefaultRenditions = []Rendition{
+	{Name: "360p", Height: 360, VideoKbps: 800, AudioKbps: 96},
+	{Name: "480p", Height: 480, VideoKbps: 1400, AudioKbps: 128},
+	{Name: "720p", Height: 720, VideoKbps: 2800, AudioKbps: 128},
+	{Name: "1080p", Height: 1080, VideoKbps: 5000, AudioKbps: 192},
+}
+
+// ParseRenditions picks renditions from DefaultRenditions by name, e.g. "360p,720p"
+func ParseRenditions(names string) ([]Rendition, error) {
+	var renditions []Rendition
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, r := range DefaultRenditions {
+			if r.Name == name {
+				renditions = append(renditions, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown rendition %q", name)
+		}
+	}
+	if len(renditions) == 0 {
+		return DefaultRenditions, nil
+	}
+	return renditions, nil
+}
+
+// Transcoder converts videos into HLS renditions with ffmpeg
+type Transcoder struct {
+	FFmpegPath  string
+	FFprobePath string
+	Renditions  []Rendition
+	SegmentSecs int
+}
+
+// New creates a transcoder, failing when ffmpeg or ffprobe can't be found
+func New(ffmpegPath, ffprobePath string, renditions []Rendition) (*Transcoder, error) {
+	ffmpeg, err := exec.LookPath(ffmpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found: %v", err)
+	}
+	ffprobe, err := exec.LookPath(ffprobePath)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe not found: %v", err)
+	}
+	if len(renditions) == 0 {
+		renditions = DefaultRenditions
+	}
+	return &Transcoder{FFmpegPath: ffmpeg, FFprobePath: ffprobe, Renditions: renditions, SegmentSecs: 6}, nil
+}
+
+// Probe returns the width and height of the first video stream
+func (t *Transcoder) Probe(ctx context.Context, src string) (width, height int, err error) {
+	out, err := t.run(ctx, t.FFprobePath,
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height",
+		"-of", "csv=p=0:s=x",
+		src,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	parts := strings.Split(strings.TrimSpace(out), "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("no video stream found")
+	}
+	width, errW := strconv.Atoi(parts[0])
+	height, errH := strconv.Atoi(parts[1])
+	if errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("unexpected ffprobe output %q", out)
+	}
+	return width, height, nil
+}
+
+// HLS transcodes src into one playlist per rendition plus a master playlist in outDir.
+// It returns the renditions that were produced.
+func (t *Transcoder) HLS(ctx context.Context, src, outDir string) ([]Rendition, error) {
+	width, height, err := t.Probe(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	ladder := t.ladderFor(width, height)
+	for _, r := range ladder {
+		if err := t.encode(ctx, src, outDir, r); err != nil {
+			return nil, fmt.Errorf("%s: %v", r.Name, err)
+		}
+	}
+
+	if err := writeMasterPlaylist(filepath.Join(outDir, MasterPlaylist), ladder); err != nil {
+		return nil, err
+	}
+	return ladder, nil
+}
+
+// ladderFor keeps the renditions that don't upscale the source, or the smallest one at source size
+func (t *Transcoder) ladderFor(width, height int) []Rendition {
+	var ladder []Rendition
+	for _, r := range t.Renditions {
+		if r.Height <= height {
+			r.outputWidth = evenWidth(width, height, r.Height)
+			ladder = append(ladder, r)
+		}
+	}
+	if len(ladder) == 0 {
+		r := t.Renditions[0]
+		r.Height = height - height%2
+		r.Name = fmt.Sprintf("%dp", r.Height)
+		r.outputWidth = evenWidth(width, height, r.Height)
+		ladder = append(ladder, r)
+	}
+	return ladder
+}
+
+func (t *Transcoder) encode(ctx context.Context, src, outDir string, r Rendition) error {
+	_, err := t.run(ctx, t.FFmpegPath,
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", src,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", fmt.Sprintf("scale=%d:%d", r.outputWidth, r.Height),
+		"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "main",
+		"-b:v", fmt.Sprintf("%dk", r.VideoKbps),
+		"-maxrate", fmt.Sprintf("%dk", r.VideoKbps*107/100),
+		"-bufsize", fmt.Sprintf("%dk", r.VideoKbps*3/2),
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", t.SegmentSecs),
+		"-c:a", "aac", "-ac", "2", "-b:a", fmt.Sprintf("%dk", r.AudioKbps),
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(t.SegmentSecs),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(outDir, r.Name+"_%04d.ts"),
+		filepath.Join(outDir, r.Name+".m3u8"),
+	)
+	return err
+}
+
+// run executes a command and returns its stdout, including stderr in the error
+func (t *Transcoder) run(ctx context.Context, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		detail := strings.TrimSpace(stderr.String())
+		if len(detail) > 500 {
+			detail = detail[len(detail)-500:]
+		}
+		return "", fmt.Errorf("%s failed: %v: %s", filepath.Base(name), err, detail)
+	}
+	return stdout.String(), nil
+}
+
+func writeMasterPlaylist(path string, ladder []Rendition) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, r := range ladder {
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d,NAME=\"%s\"\n%s.m3u8\n",
+			(r.VideoKbps+r.AudioKbps)*1000, r.outputWidth, r.Height, r.Name, r.Name)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// evenWidth scales the source width to height h keeping the aspect ratio; x264 needs even sizes
+func evenWidth(srcWidth, srcHeight, h int) int {
+	w := (srcWidth*h + srcHeight/2) / srcHeight
+	if w%2 != 0 {
+		w++
+	}
+	return w
+}
@@ -2,25 +2,16 @@ package utils
 
 import (
 	"crypto/rand"
-	"encoding/hex"
 	"fmt"
-	mathrand "math/rand"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/idgen"
 	"time"
 )
 
-// Initialize the math/rand seed
-func init() {
-	mathrand.Seed(time.Now().UnixNano())
-}
-
 // GenerateVerificationToken creates a secure random token for email verification
 func GenerateVerificationToken() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", fmt.Errorf("failed to generate token: %v", err)
-	}
 	// Add prefix to make verification tokens unique from reset tokens
-	return "verify_" + hex.EncodeToString(bytes), nil
+	return idgen.Token("verify_", 32)
 }
 
 // IsTokenExpired checks if a verification token has expired (24 hours)
@@ -28,7 +19,7 @@ func IsTokenExpired(sentAt *time.Time) bool {
 	if sentAt == nil {
 		return true
 	}
-	return time.Since(*sentAt) > 24*time.Hour
+	return clock.Since(*sentAt) > 24*time.Hour
 }
 
 // GenerateRandomCode generates a numeric code for alternative verification
@@ -60,19 +51,17 @@ func IsResetTokenExpired(expiresAt *time.Time) bool {
 	if expiresAt == nil {
 		return true
 	}
-	return time.Now().After(*expiresAt)
+	return clock.Now().After(*expiresAt)
 }
 
 // CalculateResetExpiry calculates when a reset token should expire
 func CalculateResetExpiry() time.Time {
-	return time.Now().Add(1 * time.Hour) // 1 hour expiry
+	return clock.Now().Add(1 * time.Hour) // 1 hour expiry
 }
 
 // GenerateVerificationCode generates a 6-digit numeric verification code
 func GenerateVerificationCode() (string, error) {
-	// Generate a 6-digit code using math/rand (already seeded in init)
-	code := fmt.Sprintf("%06d", mathrand.Intn(1000000))
-	return code, nil
+	return idgen.Code(6), nil
 }
 
 // IsVerificationCodeExpired checks if a verification code has expired
@@ -80,7 +69,7 @@ func IsVerificationCodeExpired(sentAt *time.Time) bool {
 	if sentAt == nil {
 		return true
 	}
-	return time.Since(*sentAt) > 1*time.Hour // 1 hour expiry for codes
+	return clock.Since(*sentAt) > 1*time.Hour // 1 hour expiry for codes
 }
 
 // GenerateSecureCode generates a cryptographically secure numeric code
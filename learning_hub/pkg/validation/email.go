@@ -61,6 +61,11 @@ var allowedDomains = map[string]bool{
 // Email validation regex
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
 
+// IsEmailFormat checks only the format of an address, for addresses admins vouch for
+func IsEmailFormat(email string) bool {
+	return emailRegex.MatchString(email)
+}
+
 // IsValidEmail validates email format and domain
 func IsValidEmail(email string) (bool, string) {
 	// Basic format validation
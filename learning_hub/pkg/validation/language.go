@@ -0,0 +1,19 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Language tags like "en", "am", "pt-br"
+var languageTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+
+// NormalizeLanguage lowercases a language tag and checks it looks like a BCP 47 language[-region] tag
+func NormalizeLanguage(tag string) (string, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if !languageTagPattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid language code: %q", tag)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,181 @@
+package validation
+
+import (
+	"learning_hub/pkg/i18n"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Problem is what is wrong with one field of a request
+type Problem struct {
+	Field string       // path of the field in the request, e.g. questions[0].points
+	Rule  string       // the rule it breaks, a validator tag such as required or min
+	Param string       // the rule's parameter, e.g. 6 for min=6
+	Kind  reflect.Kind // limits read differently for text, lists and numbers
+}
+
+// English messages of each rule, translated by the i18n bundles. A key is a rule, or a rule and kind
+// ("min.string") where the wording depends on the kind; {param} is replaced by the rule's parameter.
+var messages = map[string]string{
+	"invalid":      "is invalid",
+	"required":     "is required",
+	"email":        "must be a valid email address",
+	"url":          "must be a valid URL",
+	"oneof":        "must be one of {param}",
+	"min":          "must be at least {param}",
+	"min.string":   "must be at least {param} characters long",
+	"min.items":    "must have at least {param} items",
+	"max":          "must be at most {param}",
+	"max.string":   "must be at most {param} characters long",
+	"max.items":    "must have at most {param} items",
+	"gt":           "must be more than {param}",
+	"gt.string":    "must be more than {param} characters long",
+	"gt.items":     "must have more than {param} items",
+	"lt":           "must be less than {param}",
+	"lt.string":    "must be less than {param} characters long",
+	"lt.items":     "must have less than {param} items",
+	"len":          "must be exactly {param}",
+	"len.string":   "must be exactly {param} characters long",
+	"len.items":    "must have exactly {param} items",
+	"after":        "must be after {param}",
+	"type.boolean": "must be a boolean",
+	"type.integer": "must be an integer",
+	"type.number":  "must be a number",
+	"type.string":  "must be a string",
+	"type.array":   "must be an array",
+	"type.object":  "must be an object",
+}
+
+// Tags sharing the message of another
+var ruleAliases = map[string]string{
+	"required_if":      "required",
+	"required_with":    "required",
+	"required_without": "required",
+	"http_url":         "url",
+	"uri":              "url",
+	"gte":              "min",
+	"lte":              "max",
+	"gtfield":          "after",
+	"dive":             "invalid",
+}
+
+// message is the English message of key, falling back to the message of the rule alone
+func message(key string) string {
+	rule, _, _ := strings.Cut(key, ".")
+	for _, k := range []string{key, rule} {
+		if m, ok := messages[k]; ok {
+			return m
+		}
+	}
+	return messages["invalid"]
+}
+
+// Message is the problem in words, e.g. "must be at least 6 characters long"
+func (p Problem) Message(locale string) string {
+	rule := p.Rule
+	if alias, ok := ruleAliases[rule]; ok {
+		rule = alias
+	}
+	key := rule
+	switch p.Kind {
+	case reflect.String:
+		key += ".string"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		key += ".items"
+	}
+	param := p.Param
+	if rule == "oneof" {
+		param = strings.Join(strings.Fields(param), ", ")
+	}
+	return strings.ReplaceAll(i18n.T(locale, message(key)), "{param}", param)
+}
+
+// Messages are the problems in words by field, for the "fields" of an error answer
+func Messages(problems []Problem, locale string) map[string]string {
+	fields := make(map[string]string, len(problems))
+	for _, p := range problems {
+		fields[p.Field] = p.Message(locale)
+	}
+	return fields
+}
+
+// Summary sums the problems up in one sentence, e.g. "Invalid request: email is required, password ..."
+func Summary(problems []Problem, locale string) string {
+	list := make([]string, 0, len(problems))
+	for field, problem := range Messages(problems, locale) {
+		list = append(list, field+" "+problem)
+	}
+	sort.Strings(list)
+	return i18n.T(locale, "Invalid request: %s", strings.Join(list, ", "))
+}
+
+// Problems lists the fields failing validation
+func Problems(errs validator.ValidationErrors) []Problem {
+	problems := make([]Problem, 0, len(errs))
+	for _, fe := range errs {
+		kind := fe.Kind()
+		if kind == reflect.Ptr {
+			kind = fe.Type().Elem().Kind()
+		}
+		problems = append(problems, Problem{Field: fieldName(fe), Rule: fe.Tag(), Param: fe.Param(), Kind: kind})
+	}
+	return problems
+}
+
+// TypeProblem is a field of the wrong JSON type, e.g. a string sent for a number
+func TypeProblem(field string, t reflect.Type) Problem {
+	return Problem{Field: field, Rule: "type." + jsonType(t)}
+}
+
+func init() {
+	// Name fields as clients send them, e.g. "questions[0].correct_answer" rather than "Questions[0].CorrectAnswer"
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				name, _, _ = strings.Cut(f.Tag.Get("form"), ",")
+			}
+			if name == "" {
+				return f.Name
+			}
+			return name
+		})
+	}
+}
+
+// fieldName is the path of the field in the request, e.g. questions[0].points. Named structs start both
+// namespaces with their type name, where field names would differ in case.
+func fieldName(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if top, path, ok := strings.Cut(namespace, "."); ok {
+		if structTop, _, _ := strings.Cut(fe.StructNamespace(), "."); top == structTop {
+			return path
+		}
+	}
+	return namespace
+}
+
+// jsonType names the JSON type of a Go type
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
@@ -0,0 +1,118 @@
+// Package webhooks delivers signed platform events to the endpoints integrators register
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Events sent to endpoints
+const (
+	EnrollmentCreated = "enrollment.created"
+	PaymentSucceeded  = "payment.succeeded"
+	CertificateIssued = "certificate.issued"
+	QuizCompleted     = "quiz.completed"
+	Ping              = "ping" // sent on demand to test an endpoint, whatever it subscribed to
+)
+
+// Events are the events endpoints can subscribe to
+var Events = []string{EnrollmentCreated, PaymentSucceeded, CertificateIssued, QuizCompleted}
+
+// Headers of a delivery
+const (
+	SignatureHeader = "X-LearnHub-Signature" // t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">
+	EventHeader     = "X-LearnHub-Event"
+	DeliveryHeader  = "X-LearnHub-Delivery" // the event ID, the same on every attempt
+)
+
+// MaxAttempts is how many times a delivery is tried before it is given up
+const MaxAttempts = 8
+
+// retryDelays are the waits after each failed attempt
+var retryDelays = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+}
+
+// maxResponseBody caps the part of an endpoint's answer that is kept
+const maxResponseBody = 2000
+
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	// Redirects aren't followed: the endpoint is the registered URL
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// RetryDelay returns how long to wait after the given failed attempt (1 for the first)
+func RetryDelay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > len(retryDelays) {
+		return retryDelays[len(retryDelays)-1]
+	}
+	return retryDelays[attempt-1]
+}
+
+// ValidateURL checks that an endpoint URL is an absolute http(s) URL
+func ValidateURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if parsed.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	return nil
+}
+
+// Sign returns the signature header of a body sent at the given time. Endpoints recompute the HMAC with
+// their secret and should reject timestamps more than a few minutes old.
+func Sign(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Result is an endpoint's answer to a delivery
+type Result struct {
+	StatusCode int
+	Body       string
+}
+
+// Deliver POSTs a signed event to an endpoint. Answers other than 2xx are returned as errors with the result.
+func Deliver(ctx context.Context, endpointURL, secret, eventType, eventID string, body []byte) (Result, error) {
+	var result Result
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "LearnHub-Webhooks/1.0")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, eventID)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	result.StatusCode, result.Body = resp.StatusCode, string(answer)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("endpoint answered %d", resp.StatusCode)
+	}
+	return result, nil
+}
@@ -0,0 +1,263 @@
+package youtube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learning_hub/pkg/config"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDisabled is returned when no YouTube Data API key is configured
+var ErrDisabled = errors.New("YouTube import is not available")
+
+// ErrNotFound is returned for playlists that don't exist or aren't public
+var ErrNotFound = errors.New("playlist not found or not public")
+
+// apiURL is the YouTube Data API v3
+const apiURL = "https://www.googleapis.com/youtube/v3"
+
+// MaxItems caps the videos read from one playlist
+const MaxItems = 200
+
+var (
+	apiKey string
+	client = &http.Client{Timeout: 15 * time.Second}
+)
+
+// Init sets the API key from the configuration; an empty YOUTUBE_API_KEY disables imports
+func Init(cfg *config.Config) {
+	apiKey = cfg.YouTubeAPIKey
+}
+
+// Enabled reports whether playlists can be read
+func Enabled() bool {
+	return apiKey != ""
+}
+
+// Playlist is a public playlist with its videos in playlist order
+type Playlist struct {
+	ID           string
+	Title        string
+	Description  string
+	ThumbnailURL string
+	Videos       []Video
+	Skipped      int // private, deleted or unavailable videos left out
+}
+
+// Video is one playlist item
+type Video struct {
+	ID          string
+	Title       string
+	Description string
+	Duration    time.Duration
+}
+
+// EmbedURL is the privacy-enhanced player URL of the video
+func (v Video) EmbedURL() string {
+	return "https://www.youtube-nocookie.com/embed/" + v.ID
+}
+
+var playlistIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{10,64}$`)
+
+// ParsePlaylistID extracts the playlist ID from a playlist or watch URL (the list parameter),
+// or accepts a bare playlist ID
+func ParsePlaylistID(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if playlistIDPattern.MatchString(raw) {
+		return raw, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", errors.New("not a YouTube playlist URL")
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if host != "youtube.com" && host != "m.youtube.com" && host != "music.youtube.com" && host != "youtu.be" {
+		return "", errors.New("not a YouTube playlist URL")
+	}
+	id := u.Query().Get("list")
+	if !playlistIDPattern.MatchString(id) {
+		return "", errors.New("the URL has no playlist (list=) parameter")
+	}
+	return id, nil
+}
+
+// FetchPlaylist reads a public playlist and the durations of its videos, up to MaxItems videos
+func FetchPlaylist(ctx context.Context, id string) (*Playlist, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+
+	var playlists struct {
+		Items []struct {
+			Snippet snippet `json:"snippet"`
+		} `json:"items"`
+	}
+	if err := get(ctx, "playlists", url.Values{"part": {"snippet"}, "id": {id}}, &playlists); err != nil {
+		return nil, err
+	}
+	if len(playlists.Items) == 0 {
+		return nil, ErrNotFound
+	}
+	playlist := &Playlist{
+		ID:           id,
+		Title:        playlists.Items[0].Snippet.Title,
+		Description:  playlists.Items[0].Snippet.Description,
+		ThumbnailURL: playlists.Items[0].Snippet.Thumbnails.best(),
+	}
+
+	var videos []Video
+	pageToken := ""
+	for len(videos) < MaxItems {
+		var page struct {
+			NextPageToken string `json:"nextPageToken"`
+			Items         []struct {
+				Snippet        snippet `json:"snippet"`
+				ContentDetails struct {
+					VideoID string `json:"videoId"`
+				} `json:"contentDetails"`
+				Status struct {
+					PrivacyStatus string `json:"privacyStatus"`
+				} `json:"status"`
+			} `json:"items"`
+		}
+		params := url.Values{"part": {"snippet,contentDetails,status"}, "playlistId": {id}, "maxResults": {"50"}}
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+		if err := get(ctx, "playlistItems", params, &page); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			if item.Status.PrivacyStatus == "private" || item.ContentDetails.VideoID == "" {
+				playlist.Skipped++
+				continue
+			}
+			videos = append(videos, Video{
+				ID:          item.ContentDetails.VideoID,
+				Title:       item.Snippet.Title,
+				Description: item.Snippet.Description,
+			})
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	if len(videos) > MaxItems {
+		videos = videos[:MaxItems]
+	}
+
+	// Durations come from the videos themselves, 50 at a time. Deleted videos are not returned.
+	durations := make(map[string]time.Duration, len(videos))
+	for start := 0; start < len(videos); start += 50 {
+		end := start + 50
+		if end > len(videos) {
+			end = len(videos)
+		}
+		ids := make([]string, 0, end-start)
+		for _, video := range videos[start:end] {
+			ids = append(ids, video.ID)
+		}
+		var details struct {
+			Items []struct {
+				ID             string `json:"id"`
+				ContentDetails struct {
+					Duration string `json:"duration"`
+				} `json:"contentDetails"`
+			} `json:"items"`
+		}
+		if err := get(ctx, "videos", url.Values{"part": {"contentDetails"}, "id": {strings.Join(ids, ",")}}, &details); err != nil {
+			return nil, err
+		}
+		for _, item := range details.Items {
+			duration, _ := ParseDuration(item.ContentDetails.Duration)
+			durations[item.ID] = duration
+		}
+	}
+	for _, video := range videos {
+		duration, ok := durations[video.ID]
+		if !ok {
+			playlist.Skipped++
+			continue
+		}
+		video.Duration = duration
+		playlist.Videos = append(playlist.Videos, video)
+	}
+	return playlist, nil
+}
+
+type snippet struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Thumbnails  thumbnails `json:"thumbnails"`
+}
+
+type thumbnails map[string]struct {
+	URL string `json:"url"`
+}
+
+// best returns the largest thumbnail offered
+func (t thumbnails) best() string {
+	for _, size := range []string{"maxres", "standard", "high", "medium", "default"} {
+		if thumbnail, ok := t[size]; ok {
+			return thumbnail.URL
+		}
+	}
+	return ""
+}
+
+func get(ctx context.Context, resource string, params url.Values, out interface{}) error {
+	params.Set("key", apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"/"+resource+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("YouTube API unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiError struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiError)
+		return fmt.Errorf("YouTube API error (status %d): %s", resp.StatusCode, apiError.Error.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+var durationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParseDuration parses the ISO 8601 durations of the API, e.g. PT1H2M10S
+func ParseDuration(s string) (time.Duration, error) {
+	match := durationPattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var total time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return 0, err
+		}
+		total += time.Duration(n) * unit
+	}
+	return total, nil
+}
@@ -0,0 +1,354 @@
+// Package seed fills a database with an admin account and, outside production, demo data to explore the
+// API with: an instructor, students, published courses with modules, lessons and quizzes, and payments
+// in each status. Seeding is idempotent: records are looked up by their email, title or transaction
+// reference and only created when missing, so it can run on every start of a development server.
+package seed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learning_hub/models"
+	"learning_hub/pkg/chapa"
+	"learning_hub/pkg/clock"
+	"learning_hub/pkg/config"
+	"learning_hub/pkg/settings"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// DemoPassword is the password of every demo user
+const DemoPassword = "Password123!"
+
+// defaultAdminPassword is only used outside production, where SEED_ADMIN_PASSWORD is required
+const defaultAdminPassword = "Admin123!"
+
+// ErrProduction is returned when demo data is asked for with SERVER_ENV=production
+var ErrProduction = errors.New("demo data is never seeded in production, use --admin-only")
+
+// Options select what is seeded
+type Options struct {
+	AdminOnly bool // only the admin account, e.g. to bootstrap a production database
+}
+
+// Report counts the records created; records that already existed are not counted
+type Report struct {
+	Users       int `json:"users"`
+	Courses     int `json:"courses"`
+	Enrollments int `json:"enrollments"`
+	Payments    int `json:"payments"`
+}
+
+// Run seeds the database in one transaction
+func Run(db *gorm.DB, cfg *config.Config, opts Options) (*Report, error) {
+	production := cfg.ServerEnv == "production"
+	if production && !opts.AdminOnly {
+		return nil, ErrProduction
+	}
+	adminPassword := cfg.SeedAdminPassword
+	if adminPassword == "" {
+		if production {
+			return nil, errors.New("SEED_ADMIN_PASSWORD is required to seed the admin in production")
+		}
+		adminPassword = defaultAdminPassword
+	}
+
+	report := &Report{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		s := &seeder{tx: tx, report: report}
+		if _, err := s.user(cfg.SeedAdminEmail, "Ada", "Admin", "admin", adminPassword); err != nil {
+			return err
+		}
+		if opts.AdminOnly {
+			return nil
+		}
+		return s.demo()
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Seeded the database", "users", report.Users, "courses", report.Courses,
+		"enrollments", report.Enrollments, "payments", report.Payments)
+	return report, nil
+}
+
+type seeder struct {
+	tx     *gorm.DB
+	report *Report
+}
+
+// demoCourse is a course with its content, created together the first time
+type demoCourse struct {
+	Title, Description, Category, Level string
+	Price                               float64
+	Modules                             []demoModule
+}
+
+type demoModule struct {
+	Title   string
+	Lessons []string
+	Quiz    []models.QuizQuestion // a required quiz ending the module when set
+}
+
+var demoCourses = []demoCourse{
+	{
+		Title:       "Go for Beginners",
+		Description: "Learn the Go programming language from variables to goroutines.",
+		Category:    "Programming",
+		Level:       "beginner",
+		Modules: []demoModule{
+			{
+				Title:   "Getting Started",
+				Lessons: []string{"Installing Go", "Hello, World", "Variables and Types"},
+				Quiz: []models.QuizQuestion{
+					{Question: "Which keyword declares a variable?", QuestionType: models.QuestionTypeMultipleChoice,
+						Options: jsonOptions("var", "let", "dim"), CorrectAnswer: "var", Points: 1},
+					{Question: "Go is statically typed.", QuestionType: models.QuestionTypeTrueFalse, CorrectAnswer: "true", Points: 1},
+				},
+			},
+			{
+				Title:   "Concurrency",
+				Lessons: []string{"Goroutines", "Channels", "Select"},
+			},
+		},
+	},
+	{
+		Title:       "Web APIs with Gin",
+		Description: "Build, test and deploy a REST API with Gin and GORM.",
+		Category:    "Web Development",
+		Level:       "intermediate",
+		Price:       1500,
+		Modules: []demoModule{
+			{
+				Title:   "Routing",
+				Lessons: []string{"Routes and Handlers", "Middleware", "Binding and Validation"},
+			},
+			{
+				Title:   "Persistence",
+				Lessons: []string{"Models and Migrations", "Transactions"},
+				Quiz: []models.QuizQuestion{
+					{Question: "Which method runs statements in a transaction?", QuestionType: models.QuestionTypeMultipleChoice,
+						Options: jsonOptions("Transaction", "Begin", "Atomic"), CorrectAnswer: "Transaction", Points: 1},
+					{Question: "Name the ORM used in this course.", QuestionType: models.QuestionTypeShortAnswer, CorrectAnswer: "GORM", Points: 2},
+				},
+			},
+		},
+	},
+	{
+		Title:       "Data Science Fundamentals",
+		Description: "Statistics, data cleaning and visualisation for beginners.",
+		Category:    "Data Science",
+		Level:       "beginner",
+		Price:       900,
+		Modules: []demoModule{
+			{
+				Title:   "Working with Data",
+				Lessons: []string{"Collecting Data", "Cleaning Data", "Describing Data"},
+			},
+		},
+	},
+}
+
+func (s *seeder) demo() error {
+	instructor, err := s.user("instructor@learnhub.local", "Ivan", "Instructor", "instructor", DemoPassword)
+	if err != nil {
+		return err
+	}
+	alice, err := s.user("alice@learnhub.local", "Alice", "Student", "student", DemoPassword)
+	if err != nil {
+		return err
+	}
+	bob, err := s.user("bob@learnhub.local", "Bob", "Student", "student", DemoPassword)
+	if err != nil {
+		return err
+	}
+
+	courses := make([]*models.Course, len(demoCourses))
+	for i, demo := range demoCourses {
+		if courses[i], err = s.course(instructor.ID, demo); err != nil {
+			return err
+		}
+	}
+	free, paid, other := courses[0], courses[1], courses[2]
+
+	// Alice joined the free course and bought the paid one; Bob's payments are pending and failed
+	if _, err := s.enroll(alice.ID, free.ID, nil); err != nil {
+		return err
+	}
+	payment, err := s.payment("seed-payment-success", alice.ID, paid, models.PaymentStatusSuccess, chapa.MethodTelebirr)
+	if err != nil {
+		return err
+	}
+	if _, err := s.enroll(alice.ID, paid.ID, &payment.ID); err != nil {
+		return err
+	}
+	if _, err := s.payment("seed-payment-pending", bob.ID, paid, models.PaymentStatusPending, ""); err != nil {
+		return err
+	}
+	if _, err := s.payment("seed-payment-failed", bob.ID, other, models.PaymentStatusFailed, chapa.MethodCard); err != nil {
+		return err
+	}
+	return nil
+}
+
+// user finds the user with email or creates them, verified, with the password
+func (s *seeder) user(email, firstName, lastName, role, password string) (*models.User, error) {
+	var user models.User
+	err := s.tx.Where("email = ?", email).First(&user).Error
+	if err == nil {
+		return &user, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	user = models.User{
+		FirstName:     firstName,
+		LastName:      lastName,
+		Email:         email,
+		Role:          role,
+		Password:      password,
+		EmailVerified: true,
+	}
+	if err := user.HashPassword(); err != nil {
+		return nil, err
+	}
+	if err := s.tx.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", email, err)
+	}
+	s.report.Users++
+	return &user, nil
+}
+
+// course finds the instructor's course with the demo's title or creates it with its modules, lessons and quizzes
+func (s *seeder) course(instructorID uint, demo demoCourse) (*models.Course, error) {
+	var course models.Course
+	err := s.tx.Where("instructor_id = ? AND title = ?", instructorID, demo.Title).First(&course).Error
+	if err == nil {
+		return &course, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	course = models.Course{
+		Title:        demo.Title,
+		Description:  demo.Description,
+		Price:        demo.Price,
+		Category:     demo.Category,
+		Level:        demo.Level,
+		Published:    true,
+		InstructorID: instructorID,
+	}
+	if err := s.tx.Create(&course).Error; err != nil {
+		return nil, fmt.Errorf("failed to create course %q: %w", demo.Title, err)
+	}
+	for i, demoModule := range demo.Modules {
+		module := models.Module{Title: demoModule.Title, CourseID: course.ID, OrderIndex: i + 1}
+		if err := s.tx.Create(&module).Error; err != nil {
+			return nil, err
+		}
+		lessons := make([]models.Lesson, len(demoModule.Lessons))
+		for j, title := range demoModule.Lessons {
+			lessons[j] = models.Lesson{
+				Title:      title,
+				Content:    "# " + title + "\n\nDemo lesson of *" + demo.Title + "*.",
+				Duration:   10,
+				OrderIndex: j + 1,
+				IsPreview:  i == 0 && j == 0,
+				ModuleID:   module.ID,
+			}
+		}
+		if err := s.tx.Create(&lessons).Error; err != nil {
+			return nil, err
+		}
+		if len(demoModule.Quiz) == 0 {
+			continue
+		}
+		quiz := models.Quiz{
+			Title:        demoModule.Title + " Quiz",
+			CourseID:     course.ID,
+			ModuleID:     &module.ID,
+			MaxAttempts:  3,
+			PassingScore: 70,
+			IsPublished:  true,
+			IsRequired:   true,
+		}
+		if err := s.tx.Create(&quiz).Error; err != nil {
+			return nil, err
+		}
+		questions := make([]models.QuizQuestion, len(demoModule.Quiz))
+		for j, question := range demoModule.Quiz {
+			question.QuizID, question.OrderIndex = quiz.ID, j+1
+			questions[j] = question
+		}
+		if err := s.tx.Create(&questions).Error; err != nil {
+			return nil, err
+		}
+	}
+	s.report.Courses++
+	return &course, nil
+}
+
+// enroll enrolls the user in the course unless they already are
+func (s *seeder) enroll(userID, courseID uint, paymentID *uint) (*models.Enrollment, error) {
+	var enrollment models.Enrollment
+	err := s.tx.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error
+	if err == nil {
+		return &enrollment, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var lessons int64
+	if err := s.tx.Model(&models.Lesson{}).Joins("JOIN modules ON modules.id = lessons.module_id").
+		Where("modules.course_id = ?", courseID).Count(&lessons).Error; err != nil {
+		return nil, err
+	}
+	now := clock.Now()
+	enrollment = models.Enrollment{
+		UserID:         userID,
+		CourseID:       courseID,
+		PaymentID:      paymentID,
+		IsActive:       true,
+		TotalLessons:   int(lessons),
+		EnrolledAt:     now,
+		LastActivityAt: now,
+	}
+	if err := s.tx.Create(&enrollment).Error; err != nil {
+		return nil, err
+	}
+	s.report.Enrollments++
+	return &enrollment, nil
+}
+
+// payment finds the payment with the transaction reference or creates it for the course's price
+func (s *seeder) payment(txRef string, userID uint, course *models.Course, status models.PaymentStatus, method string) (*models.Payment, error) {
+	var payment models.Payment
+	err := s.tx.Where("chapa_tx_ref = ?", txRef).First(&payment).Error
+	if err == nil {
+		return &payment, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	payment = models.Payment{
+		UserID:        userID,
+		CourseID:      course.ID,
+		Amount:        course.Price,
+		Currency:      settings.DefaultCurrency(),
+		ChapaTxRef:    txRef,
+		Provider:      "chapa",
+		Status:        status,
+		PaymentMethod: method,
+	}
+	if err := s.tx.Create(&payment).Error; err != nil {
+		return nil, fmt.Errorf("failed to create payment %s: %w", txRef, err)
+	}
+	s.report.Payments++
+	return &payment, nil
+}
+
+func jsonOptions(options ...string) models.JSON {
+	encoded, _ := json.Marshal(options)
+	return models.JSON(encoded)
+}